@@ -0,0 +1,300 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultAgentMaxSteps is the default maximum number of model calls in an agent run
+const DefaultAgentMaxSteps = 10
+
+// AgentOption is a functional option for configuring agents
+type AgentOption func(*AgentOptions)
+
+// AgentOptions contains configuration options for agents
+type AgentOptions struct {
+	MaxSteps          int
+	CompletionOptions *CompletionOptions
+	// Approve decides on calls of tools that require approval, see WithToolApproval
+	Approve ApprovalFunc
+}
+
+// WithMaxSteps sets the maximum number of model calls in an agent run
+func WithMaxSteps(maxSteps int) AgentOption {
+	return func(o *AgentOptions) {
+		o.MaxSteps = maxSteps
+	}
+}
+
+// WithAgentCompletionOptions sets completion options that control the agent loop,
+// such as WithParallelToolCalls
+func WithAgentCompletionOptions(opts ...CompletionOption) AgentOption {
+	return func(o *AgentOptions) {
+		o.CompletionOptions = ApplyCompletionOptions(opts)
+	}
+}
+
+// ApplyAgentOptions applies all options to create an AgentOptions struct
+func ApplyAgentOptions(opts []AgentOption) *AgentOptions {
+	options := &AgentOptions{
+		MaxSteps:          DefaultAgentMaxSteps,
+		CompletionOptions: &CompletionOptions{},
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// Agent runs a completion model in a loop, executing the tools it calls until
+// the model produces a final answer.
+//
+// Tool calls use the text convention the providers already render for ToolCall
+// messages: the model writes "call tool: ```{json}```". When parallel tool calls
+// are enabled with WithParallelToolCalls, the model is instructed to emit a JSON
+// array of calls which are executed concurrently, emulating native parallel tool
+// calling for models that lack it.
+//
+// Calls of tools that require approval, see RequireApproval, are put to the ApprovalFunc set
+// with WithToolApproval before they run; denied calls are reported back to the model.
+type Agent struct {
+	model   CompletionModel
+	tools   []ModelTool
+	options *AgentOptions
+}
+
+// NewAgent creates a new agent for the given model and tools
+func NewAgent(model CompletionModel, tools []ModelTool, opts ...AgentOption) (*Agent, error) {
+	if model == nil {
+		return nil, NewValidationError("model", "cannot be nil", nil)
+	}
+	return &Agent{
+		model:   model,
+		tools:   tools,
+		options: ApplyAgentOptions(opts),
+	}, nil
+}
+
+// TraceStep is one model call of an agent run and the tool calls it requested
+type TraceStep struct {
+	// Output is the model's output of the step, which is the final answer in the last step
+	Output string `json:"output"`
+	// ToolCalls are the calls of the step with their inputs, outputs, errors and timings.
+	// Calls denied approval fail with ToolErrorCodeDenied.
+	ToolCalls []*ToolCall `json:"toolCalls,omitempty"`
+	Usage     *TokenUsage `json:"usage,omitempty"`
+	Cost      *float64    `json:"cost,omitempty"`
+	// StartAt and EndAt time the model call of the step
+	StartAt time.Time `json:"startAt"`
+	EndAt   time.Time `json:"endAt"`
+}
+
+// Run executes the agent loop for the request. The returned response contains the final
+// model output, usage and cost summed over all steps, tool execution metadata, and the
+// Trace of every step. When the run fails after its first step, including with
+// ErrMaxStepsExceeded, the error is returned along with the response of the steps run so
+// far, whose Output is that of the last step.
+func (a *Agent) Run(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, NewValidationError("request", "cannot be nil", nil)
+	}
+
+	parallel := a.parallelToolCalls()
+	stepReq := &CompletionRequest{
+		Instructions: a.instructions(req.Instructions, parallel),
+		Messages:     append([]*ModelMessage(nil), req.Messages...),
+	}
+
+	metadata := &ResponseMetadata{}
+	var total usageTotal
+	var trace []*TraceStep
+	var output string
+	// response returns the response of the steps run so far, the last one being the final
+	// step when err is nil
+	response := func(err error) (*CompletionResponse, error) {
+		if len(trace) == 0 {
+			return nil, err
+		}
+		return &CompletionResponse{
+			Output:        output,
+			Usage:         total.usage,
+			Cost:          total.cost,
+			CostBreakdown: total.breakdown,
+			Metadata:      metadata,
+			Trace:         trace,
+		}, err
+	}
+
+	for step := 0; step < a.options.MaxSteps; step++ {
+		start := time.Now()
+		resp, err := a.model.Complete(ctx, stepReq)
+		if err != nil {
+			return response(err)
+		}
+		output = resp.Output
+		total.addResponse(resp)
+		traceStep := &TraceStep{Output: resp.Output, Usage: resp.Usage, Cost: resp.Cost, StartAt: start, EndAt: time.Now()}
+		trace = append(trace, traceStep)
+
+		calls := ParseToolCalls(resp.Output)
+		if len(calls) == 0 || len(a.tools) == 0 {
+			return response(nil)
+		}
+		if !parallel && len(calls) > 1 {
+			calls = calls[:1]
+		}
+		traceStep.ToolCalls = calls
+
+		for i, call := range calls {
+			if call.ID == "" {
+				call.ID = fmt.Sprintf("call_%d_%d", step+1, i+1)
+			}
+			stepReq.Messages = append(stepReq.Messages, &ModelMessage{Role: RoleAssistant, ToolCall: call})
+		}
+
+		allowed, err := approveToolCalls(ctx, a.tools, calls, a.options.Approve, metadata)
+		if err != nil {
+			return response(err)
+		}
+		if parallel {
+			ExecuteToolCallsParallel(ctx, a.tools, allowed, metadata)
+		} else {
+			ExecuteToolCalls(ctx, a.tools, allowed, metadata)
+		}
+		if err := ctx.Err(); err != nil {
+			return response(err)
+		}
+
+		for _, call := range calls {
+			stepReq.Messages = append(stepReq.Messages, NewToolResultMessage(call))
+		}
+	}
+
+	return response(ErrMaxStepsExceeded)
+}
+
+func (a *Agent) parallelToolCalls() bool {
+	opts := a.options.CompletionOptions
+	return opts != nil && opts.ParallelToolCalls != nil && *opts.ParallelToolCalls
+}
+
+// instructions appends the tool descriptions and calling convention to the instructions
+func (a *Agent) instructions(instructions string, parallel bool) string {
+	if len(a.tools) == 0 {
+		return instructions
+	}
+
+	var sb strings.Builder
+	if instructions != "" {
+		sb.WriteString(instructions)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("You can use the following tools:\n")
+	for _, tool := range a.tools {
+		fmt.Fprintf(&sb, "\n- %s: %s", tool.Name(), tool.Description())
+		if schema := tool.InputSchema(); schema != nil {
+			if data, err := json.Marshal(schema); err == nil {
+				fmt.Fprintf(&sb, "\n  input schema: %s", data)
+			}
+		}
+	}
+	sb.WriteString("\n\nTo call a tool, reply with only:\ncall tool: ```{\"name\": \"<tool name>\", \"input\": {<tool input>}}```\n")
+	if parallel {
+		sb.WriteString("When several independent tool calls are needed, request them all at once as a JSON array:\n" +
+			"call tools: ```[{\"name\": \"<tool name>\", \"input\": {<tool input>}}, ...]```\n")
+	}
+	sb.WriteString("Tool results are returned as \"call tool results\". When you have the final answer, reply without calling a tool.")
+	return sb.String()
+}
+
+// ExecuteToolCallsParallel runs the calls concurrently against the matching tools and records
+// their latency on metadata when provided. Tool failures are recorded on the calls.
+func ExecuteToolCallsParallel(ctx context.Context, tools []ModelTool, calls []*ToolCall, metadata *ResponseMetadata) {
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		if call == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(call *ToolCall) {
+			defer wg.Done()
+			_ = ExecuteToolCall(ctx, FindTool(tools, call.Name), call)
+		}(call)
+	}
+	wg.Wait()
+
+	for _, call := range calls {
+		if call != nil {
+			metadata.AddToolCall(call)
+		}
+	}
+}
+
+// ParseToolCalls extracts tool calls from model output. It accepts the fenced JSON blocks
+// that follow the "call tool:" or "call tools:" marker the agent prompt asks for, containing a
+// single call object or an array of calls; other fenced blocks, such as JSON examples in an
+// answer, are ignored.
+func ParseToolCalls(output string) []*ToolCall {
+	var calls []*ToolCall
+	rest := output
+	for {
+		start := strings.Index(rest, "```")
+		if start < 0 {
+			break
+		}
+		marked := hasToolCallMarker(rest[:start])
+		body := rest[start+3:]
+		end := strings.Index(body, "```")
+		if end < 0 {
+			break
+		}
+		block := strings.TrimSpace(body[:end])
+		rest = body[end+3:]
+		if !marked {
+			continue
+		}
+
+		// Skip an optional language tag such as ```json
+		if i := strings.IndexAny(block, "[{"); i > 0 {
+			block = block[i:]
+		}
+		calls = append(calls, decodeToolCalls(block)...)
+	}
+	return calls
+}
+
+// hasToolCallMarker reports whether text preceding a fence ends with the tool call marker
+func hasToolCallMarker(text string) bool {
+	text = strings.ToLower(strings.TrimRightFunc(text, unicode.IsSpace))
+	return strings.HasSuffix(text, "call tool:") || strings.HasSuffix(text, "call tools:")
+}
+
+func decodeToolCalls(block string) []*ToolCall {
+	if strings.HasPrefix(block, "[") {
+		var many []*ToolCall
+		if err := json.Unmarshal([]byte(block), &many); err != nil {
+			return nil
+		}
+		calls := make([]*ToolCall, 0, len(many))
+		for _, call := range many {
+			if call != nil && call.Name != "" {
+				calls = append(calls, call)
+			}
+		}
+		return calls
+	}
+
+	var one ToolCall
+	if err := json.Unmarshal([]byte(block), &one); err != nil || one.Name == "" {
+		return nil
+	}
+	return []*ToolCall{&one}
+}
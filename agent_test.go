@@ -0,0 +1,183 @@
+package llm
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedModel returns its outputs in order and records the requests it receives
+type scriptedModel struct {
+	outputs  []string
+	requests []*CompletionRequest
+}
+
+func (m *scriptedModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	return nil, NewUnsupportedCapabilityError("scripted", "streaming")
+}
+
+func (m *scriptedModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	m.requests = append(m.requests, &CompletionRequest{
+		Instructions: req.Instructions,
+		Messages:     append([]*ModelMessage(nil), req.Messages...),
+	})
+	output := m.outputs[len(m.requests)-1]
+	cost := 0.001
+	return &CompletionResponse{
+		Output: output,
+		Usage:  &TokenUsage{TotalInputTokens: 10, TotalOutputTokens: 5, TotalRequests: 1},
+		Cost:   &cost,
+	}, nil
+}
+
+func TestParseToolCalls(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{name: "none", output: "The answer is 42.", want: nil},
+		{name: "single", output: "call tool: ```{\"name\": \"weather\", \"input\": {\"city\": \"Paris\"}}```", want: []string{"weather"}},
+		{name: "language_tag", output: "call tool: ```json\n{\"name\": \"weather\", \"input\": {}}\n```", want: []string{"weather"}},
+		{name: "array", output: "call tools: ```[{\"name\": \"a\"}, {\"name\": \"b\"}]```", want: []string{"a", "b"}},
+		{name: "not_a_call", output: "```json\n{\"city\": \"Paris\"}\n```", want: nil},
+		{name: "invalid_json", output: "call tool: ```{name: weather}```", want: nil},
+		{name: "unmarked", output: "Send a request like:\n```json\n{\"name\": \"weather\", \"input\": {\"city\": \"Paris\"}}\n```", want: nil},
+		{name: "marked_after_example", output: "```{\"name\": \"example\"}```\ncall tool:\n```{\"name\": \"weather\"}```", want: []string{"weather"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := ParseToolCalls(tt.output)
+			var names []string
+			for _, call := range calls {
+				names = append(names, call.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestAgent_ParallelToolCalls(t *testing.T) {
+	var running, maxRunning int32
+	slow := func(ctx context.Context, input map[string]any) (any, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return input["city"], nil
+	}
+	tools := []ModelTool{&testTool{name: "weather", run: slow}}
+	model := &scriptedModel{outputs: []string{
+		"call tools: ```[{\"name\": \"weather\", \"input\": {\"city\": \"Paris\"}}, {\"name\": \"weather\", \"input\": {\"city\": \"Rome\"}}]```",
+		"Sunny in both.",
+	}}
+
+	agent, err := NewAgent(model, tools, WithAgentCompletionOptions(WithParallelToolCalls(true)))
+	require.NoError(t, err)
+
+	resp, err := agent.Run(context.Background(), &CompletionRequest{
+		Messages: []*ModelMessage{{Role: RoleUser, Content: "Weather in Paris and Rome?"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Sunny in both.", resp.Output)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning), "calls should run concurrently")
+	assert.Equal(t, 2, resp.Metadata.ToolCalls)
+	assert.Equal(t, 0, resp.Metadata.ToolErrors)
+	assert.Equal(t, int64(20), resp.Usage.TotalInputTokens)
+	assert.Equal(t, 2, resp.Usage.TotalRequests)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 0.002, *resp.Cost, 1e-12)
+
+	require.Len(t, model.requests, 2)
+	assert.Contains(t, model.requests[0].Instructions, "JSON array")
+	followUp := model.requests[1].Messages
+	require.Len(t, followUp, 5)
+	assert.Equal(t, RoleAssistant, followUp[1].Role)
+	assert.Equal(t, RoleAssistant, followUp[2].Role)
+	assert.Equal(t, RoleTool, followUp[3].Role)
+	assert.Equal(t, "Paris", followUp[3].ToolCall.Output)
+	assert.Equal(t, "Rome", followUp[4].ToolCall.Output)
+}
+
+func TestAgent_SequentialByDefault(t *testing.T) {
+	tools := []ModelTool{&testTool{name: "echo", run: func(ctx context.Context, input map[string]any) (any, error) {
+		return "ok", nil
+	}}}
+	model := &scriptedModel{outputs: []string{
+		"call tools: ```[{\"name\": \"echo\"}, {\"name\": \"echo\"}]```",
+		"done",
+	}}
+
+	agent, err := NewAgent(model, tools)
+	require.NoError(t, err)
+
+	resp, err := agent.Run(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Metadata.ToolCalls, "only the first call runs without parallel tool calls")
+	assert.NotContains(t, model.requests[0].Instructions, "JSON array")
+}
+
+func TestAgent_MaxSteps(t *testing.T) {
+	tools := []ModelTool{&testTool{name: "echo", run: func(ctx context.Context, input map[string]any) (any, error) {
+		return "ok", nil
+	}}}
+	loop := "call tool: ```{\"name\": \"echo\"}```"
+	model := &scriptedModel{outputs: []string{loop, loop}}
+
+	agent, err := NewAgent(model, tools, WithMaxSteps(2))
+	require.NoError(t, err)
+
+	resp, err := agent.Run(context.Background(), &CompletionRequest{})
+	assert.ErrorIs(t, err, ErrMaxStepsExceeded)
+	require.NotNil(t, resp, "the partial run is returned with the error")
+	require.Len(t, resp.Trace, 2)
+	assert.Equal(t, loop, resp.Output)
+	assert.Equal(t, int64(20), resp.Usage.TotalInputTokens)
+	assert.Equal(t, 2, resp.Metadata.ToolCalls)
+}
+
+func TestAgent_Trace(t *testing.T) {
+	tools := []ModelTool{&testTool{name: "weather", run: func(ctx context.Context, input map[string]any) (any, error) {
+		return "sunny in " + input["city"].(string), nil
+	}}}
+	model := &scriptedModel{outputs: []string{
+		"call tool: ```{\"name\": \"weather\", \"input\": {\"city\": \"Paris\"}}```",
+		"call tool: ```{\"name\": \"forecast\"}```",
+		"Sunny.",
+	}}
+
+	agent, err := NewAgent(model, tools)
+	require.NoError(t, err)
+
+	resp, err := agent.Run(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Trace, 3)
+
+	first := resp.Trace[0]
+	assert.Equal(t, model.outputs[0], first.Output)
+	require.Len(t, first.ToolCalls, 1)
+	assert.Equal(t, "call_1_1", first.ToolCalls[0].ID)
+	assert.Equal(t, map[string]any{"city": "Paris"}, first.ToolCalls[0].Input)
+	assert.Equal(t, "sunny in Paris", first.ToolCalls[0].Output)
+	assert.False(t, first.ToolCalls[0].EndAt.Before(first.ToolCalls[0].StartAt))
+	assert.False(t, first.EndAt.Before(first.StartAt))
+	assert.Equal(t, int64(10), first.Usage.TotalInputTokens)
+
+	require.Len(t, resp.Trace[1].ToolCalls, 1)
+	require.NotNil(t, resp.Trace[1].ToolCalls[0].ErrorMessage, "failed calls are traced with their error")
+
+	last := resp.Trace[2]
+	assert.Equal(t, "Sunny.", last.Output)
+	assert.Empty(t, last.ToolCalls)
+}
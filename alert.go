@@ -0,0 +1,283 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AlertKind identifies the metric an alert is about
+type AlertKind string
+
+const (
+	// AlertSlowRequests fires when the 95th percentile latency of successful requests exceeds
+	// the threshold
+	AlertSlowRequests AlertKind = "slow_requests"
+	// AlertHighCost fires when the average cost per request exceeds the threshold
+	AlertHighCost AlertKind = "high_cost"
+	// AlertErrorRate fires when the fraction of failed requests exceeds the threshold
+	AlertErrorRate AlertKind = "error_rate"
+)
+
+// Alert reports that a metric crossed its threshold over the sliding window. An alert fires
+// once when the metric breaches the threshold and once more, with Resolved set, when it
+// recovers.
+type Alert struct {
+	Time  time.Time `json:"time"`
+	Model string    `json:"model"`
+	Kind  AlertKind `json:"kind"`
+	// Value is the observed metric: latency in seconds, cost in USD or the error rate
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	// Requests is the number of requests in the window the value was computed from
+	Requests int           `json:"requests"`
+	Window   time.Duration `json:"window"`
+	Resolved bool          `json:"resolved"`
+}
+
+func (a *Alert) String() string {
+	state := "firing"
+	if a.Resolved {
+		state = "resolved"
+	}
+	return fmt.Sprintf("%s %s %s: %.4g (threshold %.4g, %d requests in %s)",
+		a.Model, a.Kind, state, a.Value, a.Threshold, a.Requests, a.Window)
+}
+
+// AlertHook is called when an alert fires or resolves, e.g. to page an operator. Hooks run
+// synchronously after the request that triggered them and should not block.
+type AlertHook func(ctx context.Context, alert *Alert)
+
+// AlertOption is a functional option for configuring alerts
+type AlertOption func(*AlertOptions)
+
+// AlertOptions contains configuration options for alerts. A zero threshold disables the alert.
+type AlertOptions struct {
+	// Window is the sliding window metrics are computed over
+	Window time.Duration
+	// MinRequests is the number of requests the window must hold before alerts fire, so a
+	// single slow or failed request does not page anyone
+	MinRequests int
+	// LatencyThreshold is the 95th percentile latency that triggers AlertSlowRequests
+	LatencyThreshold time.Duration
+	// CostThreshold is the average cost per request in USD that triggers AlertHighCost
+	CostThreshold float64
+	// ErrorRateThreshold is the fraction of failed requests that triggers AlertErrorRate
+	ErrorRateThreshold float64
+	// Hooks are called for every alert
+	Hooks []AlertHook
+}
+
+// WithAlertWindow sets the sliding window and the number of requests it must hold before
+// alerts fire
+func WithAlertWindow(window time.Duration, minRequests int) AlertOption {
+	return func(o *AlertOptions) {
+		o.Window = window
+		o.MinRequests = minRequests
+	}
+}
+
+// WithLatencyAlert alerts when the 95th percentile latency over the window exceeds threshold
+func WithLatencyAlert(threshold time.Duration) AlertOption {
+	return func(o *AlertOptions) {
+		o.LatencyThreshold = threshold
+	}
+}
+
+// WithCostAlert alerts when the average cost per request over the window exceeds threshold USD.
+// Only requests that report a cost, see WithCost, are counted.
+func WithCostAlert(threshold float64) AlertOption {
+	return func(o *AlertOptions) {
+		o.CostThreshold = threshold
+	}
+}
+
+// WithErrorRateAlert alerts when the fraction of failed requests over the window exceeds
+// threshold. Requests canceled by the caller are not counted.
+func WithErrorRateAlert(threshold float64) AlertOption {
+	return func(o *AlertOptions) {
+		o.ErrorRateThreshold = threshold
+	}
+}
+
+// WithAlertHook adds a hook called for every alert
+func WithAlertHook(hook AlertHook) AlertOption {
+	return func(o *AlertOptions) {
+		o.Hooks = append(o.Hooks, hook)
+	}
+}
+
+// ApplyAlertOptions applies all options to create an AlertOptions struct
+func ApplyAlertOptions(opts []AlertOption) *AlertOptions {
+	options := &AlertOptions{
+		Window:      5 * time.Minute,
+		MinRequests: 10,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.MinRequests < 1 {
+		options.MinRequests = 1
+	}
+	return options
+}
+
+// alertSample is the outcome of one request in the window
+type alertSample struct {
+	time    time.Time
+	latency time.Duration
+	cost    *float64
+	failed  bool
+}
+
+// AlertCompletionModel is a middleware that tracks latency, cost and errors of a completion
+// model over a sliding window and calls the configured hooks when they exceed thresholds
+type AlertCompletionModel struct {
+	name    string
+	model   CompletionModel
+	options *AlertOptions
+	now     func() time.Time
+
+	mu      sync.Mutex
+	samples []alertSample
+	firing  map[AlertKind]bool
+}
+
+var _ CompletionModel = (*AlertCompletionModel)(nil)
+
+// NewAlertCompletionModel wraps model with alerting. name identifies the model in alerts.
+func NewAlertCompletionModel(name string, model CompletionModel, opts ...AlertOption) *AlertCompletionModel {
+	return &AlertCompletionModel{
+		name:    name,
+		model:   model,
+		options: ApplyAlertOptions(opts),
+		now:     time.Now,
+		firing:  make(map[AlertKind]bool),
+	}
+}
+
+func (m *AlertCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	start := m.now()
+	resp, err := m.model.Complete(ctx, req)
+	var cost *float64
+	if resp != nil {
+		cost = resp.Cost
+	}
+	m.observe(ctx, start, cost, err)
+	return resp, err
+}
+
+func (m *AlertCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	start := m.now()
+	stream, err := m.model.StreamComplete(ctx, req)
+	if err != nil {
+		m.observe(ctx, start, nil, err)
+		return nil, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer close(out)
+
+		var cost *float64
+		var streamErr error
+		for chunk := range stream {
+			switch c := chunk.(type) {
+			case StreamUsageChunk:
+				cost = c.Cost
+			case StreamErrorChunk:
+				streamErr = c
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+		m.observe(ctx, start, cost, streamErr)
+	}()
+	return out, nil
+}
+
+// observe records a finished request and calls the hooks for alerts that changed state
+func (m *AlertCompletionModel) observe(ctx context.Context, start time.Time, cost *float64, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	m.mu.Lock()
+	now := m.now()
+	m.samples = append(m.samples, alertSample{time: now, latency: now.Sub(start), cost: cost, failed: err != nil})
+	cutoff := now.Add(-m.options.Window)
+	expired := 0
+	for expired < len(m.samples) && m.samples[expired].time.Before(cutoff) {
+		expired++
+	}
+	m.samples = m.samples[expired:]
+	alerts := m.evaluate(now)
+	m.mu.Unlock()
+
+	for _, alert := range alerts {
+		for _, hook := range m.options.Hooks {
+			hook(ctx, alert)
+		}
+	}
+}
+
+// evaluate computes the window metrics and returns the alerts that fired or resolved. The
+// caller must hold m.mu.
+func (m *AlertCompletionModel) evaluate(now time.Time) []*Alert {
+	o := m.options
+	var alerts []*Alert
+	check := func(kind AlertKind, value, threshold float64, requests int) {
+		if threshold <= 0 {
+			return
+		}
+		breached := requests >= o.MinRequests && value > threshold
+		if breached == m.firing[kind] {
+			return
+		}
+		m.firing[kind] = breached
+		alerts = append(alerts, &Alert{
+			Time:      now,
+			Model:     m.name,
+			Kind:      kind,
+			Value:     value,
+			Threshold: threshold,
+			Requests:  requests,
+			Window:    o.Window,
+			Resolved:  !breached,
+		})
+	}
+
+	latencies := make([]time.Duration, 0, len(m.samples))
+	var failed, costed int
+	var totalCost MicroCents
+	for _, sample := range m.samples {
+		if sample.failed {
+			failed++
+			continue
+		}
+		latencies = append(latencies, sample.latency)
+		if sample.cost != nil {
+			costed++
+			totalCost += MicroCentsFromUSD(*sample.cost)
+		}
+	}
+
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		p95 := latencies[(len(latencies)*95-1)/100]
+		check(AlertSlowRequests, p95.Seconds(), o.LatencyThreshold.Seconds(), len(latencies))
+	}
+	if costed > 0 {
+		check(AlertHighCost, totalCost.USD()/float64(costed), o.CostThreshold, costed)
+	}
+	check(AlertErrorRate, float64(failed)/float64(len(m.samples)), o.ErrorRateThreshold, len(m.samples))
+	return alerts
+}
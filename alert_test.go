@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clockedCompletionModel advances a fake clock by latency on every request
+type clockedCompletionModel struct {
+	now     *time.Time
+	latency time.Duration
+	cost    float64
+	err     error
+}
+
+func (m *clockedCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	*m.now = m.now.Add(m.latency)
+	if m.err != nil {
+		return nil, m.err
+	}
+	cost := m.cost
+	return &CompletionResponse{Output: "ok", Cost: &cost}, nil
+}
+
+func (m *clockedCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	*m.now = m.now.Add(m.latency)
+	ch := make(chan StreamChunk, 2)
+	ch <- StreamTextChunk{Text: "ok"}
+	if m.err != nil {
+		ch <- StreamErrorChunk{Provider: "test", Err: m.err}
+	} else {
+		cost := m.cost
+		ch <- StreamUsageChunk{Cost: &cost}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func newTestAlertModel(inner *clockedCompletionModel, opts ...AlertOption) (*AlertCompletionModel, *[]*Alert) {
+	var alerts []*Alert
+	opts = append(opts, WithAlertHook(func(ctx context.Context, alert *Alert) {
+		alerts = append(alerts, alert)
+	}))
+	model := NewAlertCompletionModel("test-model", inner, opts...)
+	model.now = func() time.Time { return *inner.now }
+	return model, &alerts
+}
+
+func TestAlertCompletionModel_ErrorRate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	inner := &clockedCompletionModel{now: &now, latency: time.Second}
+	model, alerts := newTestAlertModel(inner, WithAlertWindow(time.Minute, 4), WithErrorRateAlert(0.5))
+
+	for range 2 {
+		_, _ = model.Complete(ctx, &CompletionRequest{})
+	}
+	inner.err = errors.New("unavailable")
+	for range 2 {
+		_, _ = model.Complete(ctx, &CompletionRequest{})
+	}
+	// Half the requests failed, which does not exceed the threshold
+	assert.Empty(t, *alerts)
+
+	_, _ = model.Complete(ctx, &CompletionRequest{})
+	require.Len(t, *alerts, 1)
+	alert := (*alerts)[0]
+	assert.Equal(t, AlertErrorRate, alert.Kind)
+	assert.Equal(t, "test-model", alert.Model)
+	assert.InDelta(t, 0.6, alert.Value, 1e-9)
+	assert.Equal(t, 5, alert.Requests)
+	assert.False(t, alert.Resolved)
+
+	// Still failing: the alert does not fire again
+	_, _ = model.Complete(ctx, &CompletionRequest{})
+	assert.Len(t, *alerts, 1)
+
+	// The failures leave the window and the alert resolves
+	now = now.Add(2 * time.Minute)
+	inner.err = nil
+	for range 4 {
+		_, _ = model.Complete(ctx, &CompletionRequest{})
+	}
+	require.Len(t, *alerts, 2)
+	assert.True(t, (*alerts)[1].Resolved)
+	assert.Equal(t, 0.0, (*alerts)[1].Value)
+}
+
+func TestAlertCompletionModel_LatencyAndCost(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	inner := &clockedCompletionModel{now: &now, latency: time.Second, cost: 0.01}
+	model, alerts := newTestAlertModel(inner,
+		WithAlertWindow(time.Hour, 2),
+		WithLatencyAlert(5*time.Second),
+		WithCostAlert(0.05))
+
+	stream, err := model.StreamComplete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	for range stream {
+	}
+	assert.Empty(t, *alerts)
+
+	inner.latency = 10 * time.Second
+	inner.cost = 0.2
+	stream, err = model.StreamComplete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	for range stream {
+	}
+
+	require.Len(t, *alerts, 2)
+	assert.Equal(t, AlertSlowRequests, (*alerts)[0].Kind)
+	assert.Equal(t, 10.0, (*alerts)[0].Value)
+	assert.Equal(t, AlertHighCost, (*alerts)[1].Kind)
+	assert.InDelta(t, 0.105, (*alerts)[1].Value, 1e-9)
+}
+
+func TestAlertCompletionModel_IgnoresCanceled(t *testing.T) {
+	now := time.Unix(0, 0)
+	inner := &clockedCompletionModel{now: &now, err: context.Canceled}
+	model, alerts := newTestAlertModel(inner, WithAlertWindow(time.Minute, 1), WithErrorRateAlert(0.1))
+
+	_, err := model.Complete(context.Background(), &CompletionRequest{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, *alerts)
+	assert.Empty(t, model.samples)
+}
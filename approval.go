@@ -0,0 +1,108 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ToolErrorCodeDenied is reported back to the model when a tool call was not approved
+const ToolErrorCodeDenied = "tool_denied"
+
+// ApprovalRequired is implemented by tools whose calls must be approved before they run,
+// e.g. tools with side effects such as payments or deletes
+type ApprovalRequired interface {
+	// RequiresApproval reports whether a call with the given input must be approved
+	RequiresApproval(input map[string]any) bool
+}
+
+// Approval is the decision on a proposed tool call
+type Approval struct {
+	Approved bool
+	// Reason is sent back to the model when the call is denied
+	Reason string
+	// Input replaces the input of an approved call when set, e.g. with a corrected amount
+	Input map[string]any
+}
+
+// ApprovalFunc decides on a proposed tool call, typically by asking a human. The agent loop
+// waits for it to return. An error aborts the agent run.
+type ApprovalFunc func(ctx context.Context, call *ToolCall) (*Approval, error)
+
+// WithToolApproval sets the callback that decides on calls of tools that require approval.
+// Without it such calls are denied.
+func WithToolApproval(approve ApprovalFunc) AgentOption {
+	return func(o *AgentOptions) {
+		o.Approve = approve
+	}
+}
+
+// approvalTool marks a tool as requiring approval for every call
+type approvalTool struct {
+	ModelTool
+}
+
+func (t approvalTool) RequiresApproval(map[string]any) bool {
+	return true
+}
+
+// RequireApproval wraps tool so that an agent asks for approval before every call of it
+func RequireApproval(tool ModelTool) ModelTool {
+	return approvalTool{ModelTool: tool}
+}
+
+// requiresApproval reports whether a call of tool must be approved
+func requiresApproval(tool ModelTool, call *ToolCall) bool {
+	gated, ok := tool.(ApprovalRequired)
+	return ok && gated.RequiresApproval(call.Input)
+}
+
+// approveToolCalls asks approve about every call that requires approval and returns the calls
+// that may run. Denied calls are finished with a ToolErrorCodeDenied error and counted on
+// metadata. Calls are decided one at a time in order.
+func approveToolCalls(ctx context.Context, tools []ModelTool, calls []*ToolCall, approve ApprovalFunc, metadata *ResponseMetadata) ([]*ToolCall, error) {
+	allowed := make([]*ToolCall, 0, len(calls))
+	for _, call := range calls {
+		if call == nil {
+			continue
+		}
+		tool := FindTool(tools, call.Name)
+		if tool == nil || !requiresApproval(tool, call) {
+			allowed = append(allowed, call)
+			continue
+		}
+
+		approval := &Approval{Reason: "no approver is configured"}
+		if approve != nil {
+			var err error
+			if approval, err = approve(ctx, call); err != nil {
+				return nil, fmt.Errorf("tool call approval: %w", err)
+			}
+			if approval == nil {
+				approval = &Approval{}
+			}
+		}
+		if approval.Approved {
+			if approval.Input != nil {
+				call.Input = approval.Input
+			}
+			allowed = append(allowed, call)
+			continue
+		}
+
+		message := fmt.Sprintf("call of tool %q was denied", call.Name)
+		if approval.Reason != "" {
+			message += ": " + approval.Reason
+		}
+		call.StartAt = time.Now()
+		call.EndAt = call.StartAt
+		call.SetError(NewToolError(ToolErrorCodeDenied, message, nil))
+		if metadata != nil {
+			metadata.ToolDenials++
+		}
+	}
+	return allowed, nil
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent_ToolApproval(t *testing.T) {
+	var charged []any
+	pay := RequireApproval(&testTool{name: "pay", run: func(ctx context.Context, input map[string]any) (any, error) {
+		charged = append(charged, input["amount"])
+		return "paid", nil
+	}})
+	lookup := &testTool{name: "lookup", run: func(ctx context.Context, input map[string]any) (any, error) {
+		return "invoice 7: 120 EUR", nil
+	}}
+
+	model := &scriptedModel{outputs: []string{
+		"call tools: ```[{\"name\": \"lookup\", \"input\": {}}, {\"name\": \"pay\", \"input\": {\"amount\": 1200}}]```",
+		"call tool: ```{\"name\": \"pay\", \"input\": {\"amount\": 120}}```",
+		"Paid 120 EUR.",
+	}}
+	var proposed []*ToolCall
+	agent, err := NewAgent(model, []ModelTool{lookup, pay},
+		WithAgentCompletionOptions(WithParallelToolCalls(true)),
+		WithToolApproval(func(ctx context.Context, call *ToolCall) (*Approval, error) {
+			proposed = append(proposed, call)
+			if call.Input["amount"] == 1200.0 {
+				return &Approval{Reason: "amount does not match the invoice"}, nil
+			}
+			return &Approval{Approved: true, Input: map[string]any{"amount": 120.0, "currency": "EUR"}}, nil
+		}),
+	)
+	require.NoError(t, err)
+
+	resp, err := agent.Run(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Paid 120 EUR.", resp.Output)
+	require.Len(t, proposed, 2, "only calls of the gated tool are proposed")
+	assert.Equal(t, []any{120.0}, charged)
+	assert.Equal(t, 1, resp.Metadata.ToolDenials)
+	assert.Equal(t, 2, resp.Metadata.ToolCalls)
+	assert.Equal(t, 0, resp.Metadata.ToolErrors)
+
+	// The denial is reported back to the model
+	denied := model.requests[1].Messages[3].ToolCall
+	assert.Equal(t, "pay", denied.Name)
+	require.NotNil(t, denied.ErrorMessage)
+	assert.Equal(t, `call of tool "pay" was denied: amount does not match the invoice`, *denied.ErrorMessage)
+	assert.Equal(t, ToolErrorCodeDenied, denied.Output.(*ToolErrorEnvelope).Error.Code)
+}
+
+func TestAgent_ToolApprovalWithoutApprover(t *testing.T) {
+	ran := false
+	tools := []ModelTool{RequireApproval(&testTool{name: "delete", run: func(ctx context.Context, input map[string]any) (any, error) {
+		ran = true
+		return nil, nil
+	}})}
+	model := &scriptedModel{outputs: []string{"call tool: ```{\"name\": \"delete\", \"input\": {}}```", "I could not delete it."}}
+	agent, err := NewAgent(model, tools)
+	require.NoError(t, err)
+
+	resp, err := agent.Run(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.False(t, ran)
+	assert.Equal(t, 1, resp.Metadata.ToolDenials)
+	assert.Contains(t, *model.requests[1].Messages[1].ToolCall.ErrorMessage, "no approver is configured")
+}
+
+func TestAgent_ToolApprovalError(t *testing.T) {
+	errUnavailable := errors.New("approver unavailable")
+	tools := []ModelTool{RequireApproval(&testTool{name: "delete", run: func(ctx context.Context, input map[string]any) (any, error) {
+		return nil, nil
+	}})}
+	model := &scriptedModel{outputs: []string{"call tool: ```{\"name\": \"delete\", \"input\": {}}```"}}
+	agent, err := NewAgent(model, tools, WithToolApproval(func(ctx context.Context, call *ToolCall) (*Approval, error) {
+		return nil, errUnavailable
+	}))
+	require.NoError(t, err)
+
+	_, err = agent.Run(context.Background(), &CompletionRequest{})
+	assert.ErrorIs(t, err, errUnavailable)
+}
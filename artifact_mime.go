@@ -0,0 +1,131 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // decode GIF images for transcoding
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	_ "golang.org/x/image/tiff" // decode TIFF images for transcoding
+)
+
+// SniffContentType returns the MIME type of content detected from its leading bytes, or ""
+// when it is not recognized. Besides the types of http.DetectContentType it recognizes HEIC,
+// HEIF, AVIF and TIFF images.
+func SniffContentType(content []byte) string {
+	if len(content) == 0 {
+		return ""
+	}
+	if len(content) >= 12 && string(content[4:8]) == "ftyp" {
+		switch string(content[8:12]) {
+		case "heic", "heix", "hevc", "hevx", "heim", "heis":
+			return "image/heic"
+		case "mif1", "msf1":
+			return "image/heif"
+		case "avif", "avis":
+			return "image/avif"
+		}
+	}
+	if bytes.HasPrefix(content, []byte("II*\x00")) || bytes.HasPrefix(content, []byte("MM\x00*")) {
+		return "image/tiff"
+	}
+	contentType := http.DetectContentType(content)
+	if contentType == "application/octet-stream" {
+		return ""
+	}
+	return baseContentType(contentType)
+}
+
+// NormalizeArtifact returns artifact with the content type sniffed from its content when the
+// declared type is missing or names another image or document format. When supported is not
+// empty and the type is not one of them, GIF and TIFF images are transcoded to PNG, or JPEG
+// when PNG is not supported. HEIC, HEIF and AVIF photos are recognized but not transcoded, as
+// there is no decoder for them. Images that cannot be transcoded and other unsupported types
+// fail with a ValidationError.
+//
+// Remote artifacts and artifacts without content are returned unchanged, and artifact itself
+// is never modified.
+func NormalizeArtifact(artifact *ModelArtifact, supported ...string) (*ModelArtifact, error) {
+	if artifact == nil || len(artifact.Content) == 0 {
+		return artifact, nil
+	}
+
+	normalized := artifact
+	declared := baseContentType(artifact.ContentType)
+	if sniffed := SniffContentType(artifact.Content); sniffed != "" && sniffed != declared && trustSniffed(declared, sniffed) {
+		copied := *artifact
+		copied.ContentType = sniffed
+		normalized = &copied
+	}
+
+	contentType := baseContentType(normalized.ContentType)
+	if len(supported) == 0 || slices.Contains(supported, contentType) {
+		return normalized, nil
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, NewValidationError("contentType", fmt.Sprintf("must be one of %s", strings.Join(supported, ", ")), normalized.ContentType)
+	}
+	return transcodeImage(normalized, contentType, supported)
+}
+
+// trustSniffed reports whether the sniffed type replaces the declared one. Images and PDFs are
+// recognized reliably, while text and media types have aliases such as audio/wav, so they only
+// fill in a missing type.
+func trustSniffed(declared, sniffed string) bool {
+	if declared == "" || declared == "application/octet-stream" {
+		return true
+	}
+	return strings.HasPrefix(sniffed, "image/") || sniffed == "application/pdf"
+}
+
+// transcodeImage re-encodes the image of artifact, of type contentType, to a supported format
+func transcodeImage(artifact *ModelArtifact, contentType string, supported []string) (*ModelArtifact, error) {
+	if contentType == "image/heic" || contentType == "image/heif" || contentType == "image/avif" {
+		return nil, NewValidationError("contentType", fmt.Sprintf("%s cannot be converted, must be one of %s", contentType, strings.Join(supported, ", ")), artifact.Name)
+	}
+	var target string
+	switch {
+	case slices.Contains(supported, "image/png"):
+		target = "image/png"
+	case slices.Contains(supported, "image/jpeg"):
+		target = "image/jpeg"
+	default:
+		return nil, NewValidationError("contentType", fmt.Sprintf("must be one of %s", strings.Join(supported, ", ")), contentType)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(artifact.Content))
+	if err != nil {
+		return nil, NewValidationError("contentType", fmt.Sprintf("%s cannot be converted to %s: %v", contentType, target, err), artifact.Name)
+	}
+	var buf bytes.Buffer
+	if target == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	transcoded := *artifact
+	transcoded.ContentType = target
+	transcoded.Content = buf.Bytes()
+	return &transcoded, nil
+}
+
+// baseContentType returns the lower-case media type of contentType without its parameters
+func baseContentType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bytes"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffContentType(t *testing.T) {
+	pngData := encodeTestImage(t, "png", 4, 4)
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{name: "png", content: pngData, want: "image/png"},
+		{name: "heic", content: []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), want: "image/heic"},
+		{name: "avif", content: []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"), want: "image/avif"},
+		{name: "tiff", content: []byte("II*\x00\x08\x00\x00\x00"), want: "image/tiff"},
+		{name: "pdf", content: []byte("%PDF-1.7\n"), want: "application/pdf"},
+		{name: "text", content: []byte("hello"), want: "text/plain"},
+		{name: "unknown", content: []byte{0x00, 0x01, 0x02}, want: ""},
+		{name: "empty", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SniffContentType(tt.content))
+		})
+	}
+}
+
+func TestNormalizeArtifact(t *testing.T) {
+	pngData := encodeTestImage(t, "png", 4, 4)
+	gifData := encodeTestImage(t, "gif", 4, 4)
+	tiffData := encodeTestImage(t, "tiff", 4, 4)
+
+	// A missing or wrong image type is replaced by the sniffed one, without modifying the artifact
+	for _, declared := range []string{"", "image/jpeg", "application/octet-stream"} {
+		artifact := &ModelArtifact{Name: "a.png", ContentType: declared, Content: pngData}
+		normalized, err := NormalizeArtifact(artifact)
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", normalized.ContentType)
+		assert.Equal(t, declared, artifact.ContentType)
+	}
+
+	// Aliases of media types are kept
+	wav := &ModelArtifact{Name: "a.wav", ContentType: "audio/wav", Content: []byte("RIFF\x00\x00\x00\x00WAVEfmt ")}
+	normalized, err := NormalizeArtifact(wav)
+	require.NoError(t, err)
+	assert.Same(t, wav, normalized)
+
+	// Unsupported images are transcoded
+	normalized, err = NormalizeArtifact(&ModelArtifact{Name: "a.gif", ContentType: "image/gif", Content: gifData}, "image/png", "image/jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", normalized.ContentType)
+	_, err = png.Decode(bytes.NewReader(normalized.Content))
+	assert.NoError(t, err)
+
+	normalized, err = NormalizeArtifact(&ModelArtifact{Name: "a.tiff", Content: tiffData}, "image/jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", normalized.ContentType)
+	_, err = jpeg.Decode(bytes.NewReader(normalized.Content))
+	assert.NoError(t, err)
+
+	// HEIC photos are recognized but cannot be transcoded
+	_, err = NormalizeArtifact(&ModelArtifact{Name: "a.heic", Content: []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00")}, "image/png", "image/jpeg")
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Message, "image/heic cannot be converted")
+
+	_, err = NormalizeArtifact(&ModelArtifact{Name: "a.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.7\n")}, "image/png")
+	assert.ErrorAs(t, err, &validationErr)
+
+	remote := &ModelArtifact{Name: "a.tiff", ContentType: "image/tiff", URL: "https://example.com/a.tiff"}
+	normalized, err = NormalizeArtifact(remote, "image/png")
+	require.NoError(t, err)
+	assert.Same(t, remote, normalized)
+}
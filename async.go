@@ -0,0 +1,523 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerStopped is returned when submitting to a scheduler that is not running
+var ErrSchedulerStopped = errors.New("scheduler is not running")
+
+// Job is a completion request queued for asynchronous processing
+type Job struct {
+	ID       string             `json:"id"`
+	Request  *CompletionRequest `json:"request"`
+	Attempts int                `json:"attempts"`
+	// NotBefore delays the job until this time, e.g. after a rate limit
+	NotBefore   time.Time `json:"notBefore,omitempty"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+// JobResult is the outcome of a job
+type JobResult struct {
+	JobID    string              `json:"jobId"`
+	Response *CompletionResponse `json:"response,omitempty"`
+	// Error is the message of Err, kept for stores that persist results
+	Error       string    `json:"error,omitempty"`
+	Err         error     `json:"-"`
+	Attempts    int       `json:"attempts"`
+	CompletedAt time.Time `json:"completedAt"`
+	// Latency is the duration of the last attempt
+	Latency time.Duration `json:"latency"`
+}
+
+// JobCallback is called with the result of a job submitted with SubmitAsync
+type JobCallback func(ctx context.Context, result *JobResult)
+
+// JobQueue holds jobs until a worker takes them. Queues backed by an external system, e.g.
+// RedisJobQueue, keep jobs across restarts and let several processes share the work.
+type JobQueue interface {
+	// Push adds a job to the queue
+	Push(ctx context.Context, job *Job) error
+	// Pop blocks until a job is due or ctx is done, and returns it with a token identifying
+	// this delivery. The job stays owned by the caller until the delivery is acknowledged, so
+	// durable queues can redeliver it if the worker dies.
+	Pop(ctx context.Context) (*Job, string, error)
+	// Ack marks the delivery with the token returned by Pop as handled. A retried job is
+	// pushed again, with the same ID, before its popped delivery is acknowledged, so every
+	// delivery has its own token.
+	Ack(ctx context.Context, token string) error
+}
+
+// ResultStore persists job results, so results of fire-and-forget jobs can be read later
+type ResultStore interface {
+	StoreResult(ctx context.Context, result *JobResult) error
+}
+
+// MemoryJobQueue is an in-process JobQueue. Jobs are lost when the process exits.
+type MemoryJobQueue struct {
+	mu      sync.Mutex
+	pending []*Job
+	changed chan struct{}
+	// delivered holds the tokens of the deliveries not acknowledged yet
+	delivered  map[string]bool
+	deliveries int64
+}
+
+var _ JobQueue = (*MemoryJobQueue)(nil)
+
+// NewMemoryJobQueue creates an empty in-memory queue
+func NewMemoryJobQueue() *MemoryJobQueue {
+	return &MemoryJobQueue{changed: make(chan struct{}), delivered: make(map[string]bool)}
+}
+
+func (q *MemoryJobQueue) Push(ctx context.Context, job *Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending = append(q.pending, job)
+	// Keep due jobs first, in submission order
+	sort.SliceStable(q.pending, func(a, b int) bool {
+		return q.pending[a].NotBefore.Before(q.pending[b].NotBefore)
+	})
+	close(q.changed)
+	q.changed = make(chan struct{})
+	return nil
+}
+
+func (q *MemoryJobQueue) Pop(ctx context.Context) (*Job, string, error) {
+	for {
+		q.mu.Lock()
+		delay := time.Duration(-1)
+		if len(q.pending) > 0 {
+			delay = time.Until(q.pending[0].NotBefore)
+			if delay <= 0 {
+				job := q.pending[0]
+				q.pending = q.pending[1:]
+				q.deliveries++
+				token := strconv.FormatInt(q.deliveries, 10)
+				q.delivered[token] = true
+				q.mu.Unlock()
+				return job, token, nil
+			}
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		// Wake up when the first job is due or a job is pushed
+		var wait <-chan time.Time
+		var timer *time.Timer
+		if delay > 0 {
+			timer = time.NewTimer(delay)
+			wait = timer.C
+		}
+		select {
+		case <-changed:
+		case <-wait:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+// Ack forgets a delivery. Popped jobs are never redelivered, as they are lost when the
+// process exits anyway.
+func (q *MemoryJobQueue) Ack(ctx context.Context, token string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.delivered[token] {
+		return fmt.Errorf("unknown job delivery %q", token)
+	}
+	delete(q.delivered, token)
+	return nil
+}
+
+// Len returns the number of jobs waiting in the queue
+func (q *MemoryJobQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// MemoryResultStore is an in-process ResultStore
+type MemoryResultStore struct {
+	mu      sync.Mutex
+	results map[string]*JobResult
+}
+
+var _ ResultStore = (*MemoryResultStore)(nil)
+
+// NewMemoryResultStore creates an empty in-memory result store
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{results: make(map[string]*JobResult)}
+}
+
+func (s *MemoryResultStore) StoreResult(ctx context.Context, result *JobResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[result.JobID] = result
+	return nil
+}
+
+// Result returns the result of a job, or false if it has not completed
+func (s *MemoryResultStore) Result(id string) (*JobResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, ok := s.results[id]
+	return result, ok
+}
+
+// SchedulerOption is a functional option for configuring a Scheduler
+type SchedulerOption func(*SchedulerOptions)
+
+// SchedulerOptions contains configuration options for a Scheduler
+type SchedulerOptions struct {
+	// Queue holds submitted jobs, an in-memory queue by default
+	Queue JobQueue
+	// Store receives the result of every job when set
+	Store ResultStore
+	// Workers is the number of jobs processed at once, further limited by Concurrency
+	Workers int
+	// Concurrency limits provider requests and backs off on rate limits
+	Concurrency *AdaptiveConcurrency
+	// MaxAttempts is the total number of attempts per job, including the first one
+	MaxAttempts int
+	// Backoff is the delay before retrying a job when the error carries no Retry-After
+	Backoff Backoff
+	// ErrorHandler receives queue and store errors, which would otherwise be dropped
+	ErrorHandler func(err error)
+	// ReportPath is the file the RunStats of a run are written to on Shutdown, if set
+	ReportPath string
+}
+
+// WithJobQueue sets the queue jobs are persisted in
+func WithJobQueue(queue JobQueue) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Queue = queue
+	}
+}
+
+// WithResultStore sets the store job results are written to
+func WithResultStore(store ResultStore) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Store = store
+	}
+}
+
+// WithWorkers sets the number of jobs processed at once
+func WithWorkers(workers int) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Workers = workers
+	}
+}
+
+// WithSchedulerConcurrency shares a concurrency controller with the scheduler, e.g. one also
+// used by interactive requests to the same provider
+func WithSchedulerConcurrency(controller *AdaptiveConcurrency) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Concurrency = controller
+	}
+}
+
+// WithJobRetries sets the attempts per job and the backoff between them. Only retryable
+// errors, such as rate limits and server errors, are retried.
+func WithJobRetries(maxAttempts int, backoff Backoff) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.MaxAttempts = maxAttempts
+		o.Backoff = backoff
+	}
+}
+
+// WithJobErrorHandler sets the function that receives queue and store errors
+func WithJobErrorHandler(handler func(err error)) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.ErrorHandler = handler
+	}
+}
+
+// WithRunReport writes the statistics of a run as JSON to path when the scheduler shuts down
+func WithRunReport(path string) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.ReportPath = path
+	}
+}
+
+// ApplySchedulerOptions applies all options to create a SchedulerOptions struct
+func ApplySchedulerOptions(opts []SchedulerOption) *SchedulerOptions {
+	options := &SchedulerOptions{
+		Workers:     4,
+		MaxAttempts: 5,
+		Backoff:     DefaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.Queue == nil {
+		options.Queue = NewMemoryJobQueue()
+	}
+	if options.Backoff == nil {
+		options.Backoff = DefaultRetryBackoff
+	}
+	options.Workers = max(options.Workers, 1)
+	options.MaxAttempts = max(options.MaxAttempts, 1)
+	if options.Concurrency == nil {
+		options.Concurrency = NewAdaptiveConcurrency(WithConcurrencyLimits(options.Workers, 1, options.Workers))
+	}
+	return options
+}
+
+// Scheduler processes completion requests in the background for fire-and-forget workloads
+// such as drafting emails. Jobs are persisted in a JobQueue, retried with backoff when the
+// provider rate limits them, and their results are passed to callbacks and a ResultStore.
+// Callbacks live in memory; jobs redelivered after a restart only reach the store.
+type Scheduler struct {
+	model   CompletionModel
+	options *SchedulerOptions
+
+	mu        sync.Mutex
+	running   bool
+	callbacks map[string]JobCallback
+	stop      context.CancelFunc
+	cancelJob context.CancelFunc
+	wg        sync.WaitGroup
+	stats     *RunStatsCollector
+}
+
+// NewScheduler creates a scheduler that completes jobs with model. Call Start to begin
+// processing.
+func NewScheduler(model CompletionModel, opts ...SchedulerOption) (*Scheduler, error) {
+	if model == nil {
+		return nil, NewValidationError("model", "cannot be nil", nil)
+	}
+	return &Scheduler{
+		model:     model,
+		options:   ApplySchedulerOptions(opts),
+		callbacks: make(map[string]JobCallback),
+	}, nil
+}
+
+// Start launches the workers and begins a new run. Jobs run with contexts derived from ctx.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stats = NewRunStatsCollector()
+
+	popCtx, stop := context.WithCancel(ctx)
+	jobCtx, cancelJob := context.WithCancel(ctx)
+	s.stop, s.cancelJob = stop, cancelJob
+	for range s.options.Workers {
+		s.wg.Add(1)
+		go s.work(popCtx, jobCtx)
+	}
+}
+
+// SubmitAsync queues req and returns the job ID. callback, which may be nil, is called with
+// the result once the job completes or runs out of attempts.
+func (s *Scheduler) SubmitAsync(ctx context.Context, req *CompletionRequest, callback JobCallback) (string, error) {
+	if req == nil {
+		return "", NewValidationError("request", "cannot be nil", nil)
+	}
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if !running {
+		return "", ErrSchedulerStopped
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return "", err
+	}
+	if callback != nil {
+		s.mu.Lock()
+		s.callbacks[id] = callback
+		s.mu.Unlock()
+	}
+	job := &Job{ID: id, Request: req, SubmittedAt: time.Now()}
+	if err := s.options.Queue.Push(ctx, job); err != nil {
+		s.mu.Lock()
+		delete(s.callbacks, id)
+		s.mu.Unlock()
+		return "", fmt.Errorf("failed to queue job: %w", err)
+	}
+	return id, nil
+}
+
+// Shutdown stops taking jobs from the queue and waits for running jobs to finish. If ctx
+// expires first, running jobs are canceled and pushed back to the queue, and Shutdown
+// returns ctx.Err() once the workers have stopped.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	stop, cancelJob := s.stop, s.cancelJob
+	s.mu.Unlock()
+
+	stop()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	cancelJob()
+	<-done
+
+	if s.options.ReportPath != "" {
+		if reportErr := s.Stats().WriteReport(s.options.ReportPath); reportErr != nil {
+			s.reportError(reportErr)
+		}
+	}
+	return err
+}
+
+// Stats returns the statistics of the jobs completed in the current or last run, or nil if
+// the scheduler was never started
+func (s *Scheduler) Stats() *RunStats {
+	s.mu.Lock()
+	stats := s.stats
+	s.mu.Unlock()
+	if stats == nil {
+		return nil
+	}
+	return stats.Stats()
+}
+
+func (s *Scheduler) work(popCtx, jobCtx context.Context) {
+	defer s.wg.Done()
+	for popCtx.Err() == nil {
+		job, token, err := s.options.Queue.Pop(popCtx)
+		if err != nil {
+			if popCtx.Err() != nil {
+				return
+			}
+			s.reportError(fmt.Errorf("failed to pop job: %w", err))
+			// Avoid spinning on a queue that keeps failing
+			select {
+			case <-time.After(time.Second):
+			case <-popCtx.Done():
+				return
+			}
+			continue
+		}
+		s.process(jobCtx, job, token)
+	}
+}
+
+// process runs the job of a delivery and either requeues it or delivers its result
+func (s *Scheduler) process(ctx context.Context, job *Job, token string) {
+	start := time.Now()
+	resp, err := s.complete(ctx, job)
+	latency := time.Since(start)
+	if ctx.Err() != nil {
+		// Canceled by Shutdown: hand the job back without counting the attempt
+		s.requeue(job, token, time.Time{})
+		return
+	}
+
+	job.Attempts++
+	if err != nil && job.Attempts < s.options.MaxAttempts && IsRetryableError(err) {
+		delay, ok := RetryAfter(err)
+		if !ok {
+			delay = s.options.Backoff(job.Attempts)
+		}
+		s.requeue(job, token, time.Now().Add(delay))
+		return
+	}
+
+	result := &JobResult{
+		JobID:       job.ID,
+		Response:    resp,
+		Err:         err,
+		Attempts:    job.Attempts,
+		CompletedAt: time.Now(),
+		Latency:     latency,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	s.mu.Lock()
+	s.stats.Record(result)
+	s.mu.Unlock()
+	// Results are delivered even while shutting down, so they are not lost
+	deliverCtx := context.WithoutCancel(ctx)
+	if s.options.Store != nil {
+		if err := s.options.Store.StoreResult(deliverCtx, result); err != nil {
+			s.reportError(fmt.Errorf("failed to store result of job %s: %w", job.ID, err))
+		}
+	}
+	s.mu.Lock()
+	callback := s.callbacks[job.ID]
+	delete(s.callbacks, job.ID)
+	s.mu.Unlock()
+	if callback != nil {
+		callback(deliverCtx, result)
+	}
+	if err := s.options.Queue.Ack(deliverCtx, token); err != nil {
+		s.reportError(fmt.Errorf("failed to acknowledge job %s: %w", job.ID, err))
+	}
+}
+
+func (s *Scheduler) complete(ctx context.Context, job *Job) (*CompletionResponse, error) {
+	release, err := s.options.Concurrency.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.model.Complete(ctx, job.Request)
+	release(err)
+	return resp, err
+}
+
+// requeue pushes the job back to run at notBefore and acknowledges the popped delivery
+func (s *Scheduler) requeue(job *Job, token string, notBefore time.Time) {
+	ctx := context.Background()
+	job.NotBefore = notBefore
+	if err := s.options.Queue.Push(ctx, job); err != nil {
+		s.reportError(fmt.Errorf("failed to requeue job %s: %w", job.ID, err))
+		return
+	}
+	if err := s.options.Queue.Ack(ctx, token); err != nil {
+		s.reportError(fmt.Errorf("failed to acknowledge job %s: %w", job.ID, err))
+	}
+}
+
+func (s *Scheduler) reportError(err error) {
+	if s.options.ErrorHandler != nil {
+		s.options.ErrorHandler(err)
+	}
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
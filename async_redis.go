@@ -0,0 +1,188 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RedisCommand sends a command to Redis and returns its reply: a string for bulk and status
+// replies, an int64 for integers, a []any for arrays, and nil, without an error, for null
+// replies. It adapts the Redis client of the application, e.g. with go-redis:
+//
+//	func(ctx context.Context, args ...any) (any, error) {
+//		reply, err := rdb.Do(ctx, args...).Result()
+//		if errors.Is(err, redis.Nil) {
+//			return nil, nil
+//		}
+//		return reply, err
+//	}
+type RedisCommand func(ctx context.Context, args ...any) (any, error)
+
+// RedisJobQueue is a JobQueue in Redis, shared by every process using the same key. Due jobs
+// are kept in a list and moved atomically to a processing list when popped, where they stay
+// until acknowledged; jobs with a NotBefore time wait in a sorted set. It needs Redis 6.2 or
+// later.
+type RedisJobQueue struct {
+	do  RedisCommand
+	key string
+	// PollInterval bounds how long Pop blocks in Redis before checking for jobs that became
+	// due and for the cancellation of its context
+	PollInterval time.Duration
+}
+
+var _ JobQueue = (*RedisJobQueue)(nil)
+
+// NewRedisJobQueue creates a queue stored under keys prefixed with key
+func NewRedisJobQueue(do RedisCommand, key string) *RedisJobQueue {
+	return &RedisJobQueue{do: do, key: key, PollInterval: time.Second}
+}
+
+// redisDelivery is a queued job. Each push gets its own ID, so the encoded delivery is unique
+// and serves as the token acknowledging it.
+type redisDelivery struct {
+	ID  int64 `json:"id"`
+	Job *Job  `json:"job"`
+}
+
+func (q *RedisJobQueue) Push(ctx context.Context, job *Job) error {
+	reply, err := q.do(ctx, "INCR", q.key+":seq")
+	if err != nil {
+		return fmt.Errorf("failed to push job %s: %w", job.ID, err)
+	}
+	id, ok := reply.(int64)
+	if !ok {
+		return fmt.Errorf("failed to push job %s: unexpected reply %v", job.ID, reply)
+	}
+	data, err := json.Marshal(&redisDelivery{ID: id, Job: job})
+	if err != nil {
+		return fmt.Errorf("failed to encode job %s: %w", job.ID, err)
+	}
+	if job.NotBefore.After(time.Now()) {
+		_, err = q.do(ctx, "ZADD", q.key+":delayed", job.NotBefore.UnixMilli(), string(data))
+	} else {
+		_, err = q.do(ctx, "RPUSH", q.key+":pending", string(data))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to push job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// Pop waits for a due job and moves it to the processing list, returning it with the token
+// acknowledging the delivery. A job moved when ctx is done is still returned; one whose reply
+// is lost with the connection stays in the processing list until Recover.
+func (q *RedisJobQueue) Pop(ctx context.Context) (*Job, string, error) {
+	timeout := q.PollInterval
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	for {
+		if err := q.promote(ctx); err != nil {
+			return nil, "", err
+		}
+		reply, err := q.do(ctx, "BLMOVE", q.key+":pending", q.key+":processing", "LEFT", "RIGHT",
+			strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, "", ctxErr
+			}
+			return nil, "", fmt.Errorf("failed to pop job: %w", err)
+		}
+		if reply == nil {
+			if err := ctx.Err(); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		token, ok := redisString(reply)
+		if !ok {
+			return nil, "", fmt.Errorf("failed to pop job: unexpected reply %v", reply)
+		}
+		var delivery redisDelivery
+		if err := json.Unmarshal([]byte(token), &delivery); err != nil {
+			return nil, "", fmt.Errorf("failed to decode job: %w", err)
+		}
+		return delivery.Job, token, nil
+	}
+}
+
+// redisPromoteScript moves up to 100 delayed jobs due by ARGV[1] from the sorted set KEYS[1]
+// to the list KEYS[2], returning how many were moved. Scripts run atomically, so a job is
+// never lost between the two keys nor promoted twice.
+const redisPromoteScript = `local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, member in ipairs(due) do
+	redis.call('ZREM', KEYS[1], member)
+	redis.call('RPUSH', KEYS[2], member)
+end
+return #due`
+
+// promote moves the delayed jobs that are due to the pending list
+func (q *RedisJobQueue) promote(ctx context.Context) error {
+	_, err := q.do(ctx, "EVAL", redisPromoteScript, 2, q.key+":delayed", q.key+":pending", time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to promote delayed jobs: %w", err)
+	}
+	return nil
+}
+
+// Ack removes a delivery from the processing list
+func (q *RedisJobQueue) Ack(ctx context.Context, token string) error {
+	reply, err := q.do(ctx, "LREM", q.key+":processing", 1, token)
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge job: %w", err)
+	}
+	if reply != int64(1) {
+		return fmt.Errorf("unknown job delivery %q", token)
+	}
+	return nil
+}
+
+// Recover pushes the jobs popped but never acknowledged, e.g. by a worker that died, back to
+// the queue as new deliveries and returns how many were recovered. It is meant to run at
+// startup, while no worker of the queue is running; a job could otherwise run twice.
+func (q *RedisJobQueue) Recover(ctx context.Context) (int, error) {
+	recovered := 0
+	for {
+		reply, err := q.do(ctx, "LINDEX", q.key+":processing", 0)
+		if err != nil {
+			return recovered, fmt.Errorf("failed to recover jobs: %w", err)
+		}
+		if reply == nil {
+			return recovered, nil
+		}
+		token, ok := redisString(reply)
+		if !ok {
+			return recovered, fmt.Errorf("failed to recover jobs: unexpected reply %v", reply)
+		}
+		var delivery redisDelivery
+		if err := json.Unmarshal([]byte(token), &delivery); err != nil {
+			return recovered, fmt.Errorf("failed to decode job: %w", err)
+		}
+		// Push before acknowledging, so a failure in between duplicates the job rather than
+		// losing it
+		if err := q.Push(ctx, delivery.Job); err != nil {
+			return recovered, err
+		}
+		if err := q.Ack(ctx, token); err != nil {
+			return recovered, err
+		}
+		recovered++
+	}
+}
+
+// redisString returns a bulk string reply as a string
+func redisString(reply any) (string, bool) {
+	switch v := reply.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	}
+	return "", false
+}
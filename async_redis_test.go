@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis implements the Redis commands used by RedisJobQueue in memory
+type fakeRedis struct {
+	mu       sync.Mutex
+	counters map[string]int64
+	lists    map[string][]string
+	zsets    map[string]map[string]float64
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		counters: make(map[string]int64),
+		lists:    make(map[string][]string),
+		zsets:    make(map[string]map[string]float64),
+	}
+}
+
+func (r *fakeRedis) do(ctx context.Context, args ...any) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	arg := func(i int) string { return fmt.Sprint(args[i]) }
+	switch arg(0) {
+	case "INCR":
+		r.counters[arg(1)]++
+		return r.counters[arg(1)], nil
+	case "RPUSH":
+		r.lists[arg(1)] = append(r.lists[arg(1)], arg(2))
+		return int64(len(r.lists[arg(1)])), nil
+	case "LINDEX":
+		i, _ := strconv.Atoi(arg(2))
+		if i >= len(r.lists[arg(1)]) {
+			return nil, nil
+		}
+		return r.lists[arg(1)][i], nil
+	case "LREM":
+		list := r.lists[arg(1)]
+		for i, v := range list {
+			if v == arg(3) {
+				r.lists[arg(1)] = append(list[:i:i], list[i+1:]...)
+				return int64(1), nil
+			}
+		}
+		return int64(0), nil
+	case "BLMOVE":
+		list := r.lists[arg(1)]
+		if len(list) == 0 {
+			// Block for a moment rather than for the whole timeout
+			r.mu.Unlock()
+			time.Sleep(time.Millisecond)
+			r.mu.Lock()
+			return nil, nil
+		}
+		r.lists[arg(1)] = list[1:]
+		r.lists[arg(2)] = append(r.lists[arg(2)], list[0])
+		return list[0], nil
+	case "ZADD":
+		if r.zsets[arg(1)] == nil {
+			r.zsets[arg(1)] = make(map[string]float64)
+		}
+		score, _ := strconv.ParseFloat(arg(2), 64)
+		r.zsets[arg(1)][arg(3)] = score
+		return int64(1), nil
+	case "EVAL":
+		// The only script is redisPromoteScript, run here in Go
+		if arg(1) != redisPromoteScript {
+			return nil, fmt.Errorf("unknown script %s", arg(1))
+		}
+		delayed, pending := arg(3), arg(4)
+		max, _ := strconv.ParseFloat(arg(5), 64)
+		var due []string
+		for member, score := range r.zsets[delayed] {
+			if score <= max {
+				due = append(due, member)
+			}
+		}
+		sort.Slice(due, func(a, b int) bool { return r.zsets[delayed][due[a]] < r.zsets[delayed][due[b]] })
+		for _, member := range due {
+			delete(r.zsets[delayed], member)
+			r.lists[pending] = append(r.lists[pending], member)
+		}
+		return int64(len(due)), nil
+	}
+	return nil, fmt.Errorf("unknown command %s", arg(0))
+}
+
+func TestRedisJobQueue(t *testing.T) {
+	redis := newFakeRedis()
+	queue := NewRedisJobQueue(redis.do, "jobs")
+	queue.PollInterval = time.Millisecond
+	ctx := context.Background()
+
+	require.NoError(t, queue.Push(ctx, &Job{ID: "later", NotBefore: time.Now().Add(20 * time.Millisecond)}))
+	require.NoError(t, queue.Push(ctx, &Job{ID: "now", Request: &CompletionRequest{Instructions: "draft"}}))
+
+	job, first, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "now", job.ID)
+	assert.Equal(t, "draft", job.Request.Instructions)
+
+	// A retry is pushed again with the same ID before the first delivery is acknowledged
+	require.NoError(t, queue.Push(ctx, job))
+	require.NoError(t, queue.Ack(ctx, first))
+	job, second, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "now", job.ID)
+	assert.NotEqual(t, first, second)
+	require.NoError(t, queue.Ack(ctx, second))
+	assert.Error(t, queue.Ack(ctx, first))
+
+	start := time.Now()
+	job, _, err = queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "later", job.ID)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	_, _, err = queue.Pop(timeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRedisJobQueue_Recover(t *testing.T) {
+	redis := newFakeRedis()
+	queue := NewRedisJobQueue(redis.do, "jobs")
+	ctx := context.Background()
+
+	require.NoError(t, queue.Push(ctx, &Job{ID: "job"}))
+	_, token, err := queue.Pop(ctx)
+	require.NoError(t, err)
+
+	// The worker died without acknowledging the job
+	recovered, err := queue.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, recovered)
+	assert.Error(t, queue.Ack(ctx, token))
+
+	job, redelivered, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "job", job.ID)
+	assert.NotEqual(t, token, redelivered)
+	require.NoError(t, queue.Ack(ctx, redelivered))
+
+	recovered, err = queue.Recover(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, recovered)
+}
+
+func TestRedisJobQueue_PopCanceled(t *testing.T) {
+	redis := newFakeRedis()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The context is canceled while Redis moves the job
+	queue := NewRedisJobQueue(func(ctx context.Context, args ...any) (any, error) {
+		reply, err := redis.do(ctx, args...)
+		if args[0] == "BLMOVE" {
+			cancel()
+		}
+		return reply, err
+	}, "jobs")
+	require.NoError(t, queue.Push(ctx, &Job{ID: "job"}))
+
+	job, token, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "job", job.ID)
+	assert.Equal(t, []string{token}, redis.lists["jobs:processing"])
+
+	_, _, err = queue.Pop(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyCompletionModel fails the first failures calls with err
+type flakyCompletionModel struct {
+	stubCompletionModel
+	failures atomic.Int32
+	calls    atomic.Int32
+	block    chan struct{}
+}
+
+func (m *flakyCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	m.calls.Add(1)
+	if m.block != nil {
+		select {
+		case <-m.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if m.failures.Add(-1) >= 0 {
+		return nil, m.err
+	}
+	return &CompletionResponse{Output: m.output + " " + req.Instructions}, nil
+}
+
+func TestScheduler_SubmitAsync(t *testing.T) {
+	model := &flakyCompletionModel{stubCompletionModel: stubCompletionModel{
+		output: "draft",
+		err:    NewRequestError("openai", http.StatusTooManyRequests, "rate limited", nil),
+	}}
+	model.failures.Store(1)
+
+	store := NewMemoryResultStore()
+	scheduler, err := NewScheduler(model, WithResultStore(store), WithWorkers(2),
+		WithJobRetries(3, func(int) time.Duration { return time.Millisecond }))
+	require.NoError(t, err)
+
+	_, err = scheduler.SubmitAsync(context.Background(), &CompletionRequest{}, nil)
+	assert.ErrorIs(t, err, ErrSchedulerStopped)
+
+	scheduler.Start(context.Background())
+	defer scheduler.Shutdown(context.Background())
+
+	var mu sync.Mutex
+	results := make(map[string]*JobResult)
+	var wg sync.WaitGroup
+	callback := func(ctx context.Context, result *JobResult) {
+		mu.Lock()
+		results[result.JobID] = result
+		mu.Unlock()
+		wg.Done()
+	}
+	wg.Add(2)
+	first, err := scheduler.SubmitAsync(context.Background(), &CompletionRequest{Instructions: "to Ann"}, callback)
+	require.NoError(t, err)
+	second, err := scheduler.SubmitAsync(context.Background(), &CompletionRequest{Instructions: "to Bob"}, callback)
+	require.NoError(t, err)
+	wg.Wait()
+
+	// One job was rate limited once and retried
+	assert.Equal(t, int32(3), model.calls.Load())
+	assert.Equal(t, 3, results[first].Attempts+results[second].Attempts)
+	assert.Equal(t, "draft to Ann", results[first].Response.Output)
+	assert.Equal(t, "draft to Bob", results[second].Response.Output)
+
+	stored, ok := store.Result(first)
+	require.True(t, ok)
+	assert.NoError(t, stored.Err)
+}
+
+func TestScheduler_NonRetryableError(t *testing.T) {
+	model := &flakyCompletionModel{stubCompletionModel: stubCompletionModel{
+		err: NewRequestError("openai", http.StatusBadRequest, "bad request", nil),
+	}}
+	model.failures.Store(10)
+
+	store := NewMemoryResultStore()
+	scheduler, err := NewScheduler(model, WithResultStore(store))
+	require.NoError(t, err)
+	scheduler.Start(context.Background())
+	defer scheduler.Shutdown(context.Background())
+
+	done := make(chan *JobResult, 1)
+	id, err := scheduler.SubmitAsync(context.Background(), &CompletionRequest{}, func(ctx context.Context, result *JobResult) {
+		done <- result
+	})
+	require.NoError(t, err)
+
+	result := <-done
+	assert.Equal(t, 1, result.Attempts)
+	assert.Contains(t, result.Error, "bad request")
+	stored, ok := store.Result(id)
+	require.True(t, ok)
+	assert.Equal(t, result.Error, stored.Error)
+}
+
+func TestScheduler_ShutdownRequeues(t *testing.T) {
+	model := &flakyCompletionModel{block: make(chan struct{})}
+	queue := NewMemoryJobQueue()
+	scheduler, err := NewScheduler(model, WithJobQueue(queue), WithWorkers(1))
+	require.NoError(t, err)
+	scheduler.Start(context.Background())
+
+	_, err = scheduler.SubmitAsync(context.Background(), &CompletionRequest{}, func(ctx context.Context, result *JobResult) {
+		t.Error("callback called for a canceled job")
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return model.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, scheduler.Shutdown(ctx), context.DeadlineExceeded)
+
+	// The running job went back to the queue without using up an attempt
+	assert.Equal(t, 1, queue.Len())
+	job, _, err := queue.Pop(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, job.Attempts)
+}
+
+func TestMemoryJobQueue_NotBefore(t *testing.T) {
+	queue := NewMemoryJobQueue()
+	ctx := context.Background()
+	require.NoError(t, queue.Push(ctx, &Job{ID: "later", NotBefore: time.Now().Add(20 * time.Millisecond)}))
+	require.NoError(t, queue.Push(ctx, &Job{ID: "now"}))
+
+	job, _, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "now", job.ID)
+
+	start := time.Now()
+	job, _, err = queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "later", job.ID)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+	defer cancel()
+	_, _, err = queue.Pop(timeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMemoryJobQueue_Ack(t *testing.T) {
+	queue := NewMemoryJobQueue()
+	ctx := context.Background()
+	job := &Job{ID: "job"}
+	require.NoError(t, queue.Push(ctx, job))
+	_, first, err := queue.Pop(ctx)
+	require.NoError(t, err)
+
+	// A retry is pushed again with the same ID before the first delivery is acknowledged
+	require.NoError(t, queue.Push(ctx, job))
+	require.NoError(t, queue.Ack(ctx, first))
+	_, second, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	require.NoError(t, queue.Ack(ctx, second))
+
+	assert.Error(t, queue.Ack(ctx, first))
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Partial is a struct being populated from a stream
+type Partial[T any] struct {
+	// Value holds the fields received so far; the string field being streamed holds its
+	// text so far
+	Value T
+	// Complete holds the JSON names of the top-level fields whose values are fully received
+	Complete map[string]bool
+	// Done reports that the whole object has been received
+	Done bool
+	// Usage and Cost are set once the stream reports them
+	Usage *TokenUsage
+	Cost  *float64
+}
+
+// IsComplete reports whether the value of the top-level field with the given JSON name is
+// fully received
+func (p *Partial[T]) IsComplete(field string) bool {
+	return p.Done || p.Complete[field]
+}
+
+// BindStream populates a struct from a stream of JSON text, e.g. a completion requested
+// with WithJSONSchema, and calls onUpdate whenever more of it is known, so form-filling UIs
+// can show fields as they arrive. Incomplete strings are shown as far as they are streamed;
+// incomplete numbers, literals and keys are left out until they end. BindStream returns the
+// final value once the stream closes, or an error if it ends before the object is complete.
+func BindStream[T any](ctx context.Context, stream StreamCompletionResponse, onUpdate func(partial *Partial[T])) (*Partial[T], error) {
+	var buf strings.Builder
+	result := &Partial[T]{Complete: map[string]bool{}}
+	last := ""
+
+	update := func() {
+		repaired, complete, done := repairPartialJSON(buf.String())
+		if repaired == "" || (repaired == last && len(complete) == len(result.Complete) && done == result.Done) {
+			return
+		}
+		var value T
+		if err := json.Unmarshal([]byte(repaired), &value); err != nil {
+			// The partial object does not fit T yet, e.g. a number streamed into a string field
+			return
+		}
+		last = repaired
+		partial := &Partial[T]{Value: value, Complete: make(map[string]bool, len(complete)), Done: done}
+		for _, field := range complete {
+			partial.Complete[field] = true
+		}
+		result = partial
+		if onUpdate != nil {
+			onUpdate(partial)
+		}
+	}
+
+	for {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				if !result.Done {
+					return result, fmt.Errorf("stream ended before the JSON object was complete")
+				}
+				return result, nil
+			}
+			switch c := ownText(chunk).(type) {
+			case StreamTextChunk:
+				if !result.Done {
+					buf.WriteString(c.Text)
+					update()
+				}
+			case StreamUsageChunk:
+				result.Usage, result.Cost = c.Usage, c.Cost
+			case StreamErrorChunk:
+				return result, c
+			}
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+}
+
+// repairPartialJSON turns the prefix of a streamed JSON object into valid JSON by closing
+// the open string, arrays and objects, and dropping a trailing key or unfinished scalar. Text
+// before the first '{', such as a Markdown fence, is skipped. It also returns the top-level
+// keys whose values are complete and whether the object itself is complete.
+func repairPartialJSON(text string) (repaired string, complete []string, done bool) {
+	start := strings.IndexByte(text, '{')
+	if start < 0 {
+		return "", nil, false
+	}
+
+	var (
+		stack       []byte // open '{' and '['
+		expectKey   []bool // per open container, whether an object expects a key next
+		inString    bool
+		isKey       bool
+		escape      bool
+		keyStart    int
+		topKey      string
+		scalarStart = -1
+		// safe is the end of the longest prefix that is valid once safeClosers are appended
+		safe        = -1
+		safeClosers string
+	)
+	closers := func() string {
+		b := make([]byte, len(stack))
+		for i, open := range stack {
+			if open == '{' {
+				b[len(stack)-1-i] = '}'
+			} else {
+				b[len(stack)-1-i] = ']'
+			}
+		}
+		return string(b)
+	}
+	valueDone := func(end int) {
+		safe, safeClosers = end, closers()
+		if len(stack) == 1 {
+			complete = append(complete, topKey)
+		}
+	}
+
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escape:
+				escape = false
+			case c == '\\':
+				escape = true
+			case c == '"':
+				inString = false
+				if !isKey {
+					valueDone(i + 1)
+				} else if len(stack) == 1 {
+					_ = json.Unmarshal([]byte(text[keyStart:i+1]), &topKey)
+				}
+			}
+			continue
+		}
+		if scalarStart >= 0 {
+			if !strings.ContainsRune(",}] \t\r\n", rune(c)) {
+				continue
+			}
+			scalarStart = -1
+			valueDone(i)
+		}
+
+		switch c {
+		case '{', '[':
+			stack = append(stack, c)
+			expectKey = append(expectKey, c == '{')
+			safe, safeClosers = i+1, closers()
+		case '}', ']':
+			if len(stack) == 0 {
+				return "", nil, false
+			}
+			stack, expectKey = stack[:len(stack)-1], expectKey[:len(expectKey)-1]
+			if len(stack) == 0 {
+				return text[start : i+1], complete, true
+			}
+			valueDone(i + 1)
+		case '"':
+			inString = true
+			top := len(stack) - 1
+			isKey = stack[top] == '{' && expectKey[top]
+			if isKey {
+				keyStart = i
+				expectKey[top] = false
+			}
+		case ',':
+			if top := len(stack) - 1; stack[top] == '{' {
+				expectKey[top] = true
+			}
+		case ':', ' ', '\t', '\r', '\n':
+		default:
+			scalarStart = i
+		}
+	}
+
+	// Show the string being streamed when closing it gives valid JSON. A number or literal
+	// being streamed is dropped, as its prefix, e.g. 3 of 36, is a different value.
+	if inString && !isKey {
+		partial := text[start:]
+		if escape {
+			partial = partial[:len(partial)-1]
+		} else if i := strings.LastIndex(partial, `\u`); i >= 0 && len(partial)-i < 6 {
+			partial = partial[:i]
+		}
+		if candidate := partial + `"` + closers(); json.Valid([]byte(candidate)) {
+			return candidate, complete, false
+		}
+	}
+	if safe < 0 {
+		return "", complete, false
+	}
+	return text[start:safe] + safeClosers, complete, false
+}
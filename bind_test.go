@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairPartialJSON(t *testing.T) {
+	tests := []struct {
+		text         string
+		want         string
+		wantComplete []string
+		wantDone     bool
+	}{
+		{text: "```json\n", want: ""},
+		{text: `{`, want: `{}`},
+		{text: `{"na`, want: `{}`},
+		{text: `{"name":`, want: `{}`},
+		{text: `{"name": "Ad`, want: `{"name": "Ad"}`},
+		{text: `{"name": "Ada\`, want: `{"name": "Ada"}`},
+		{text: `{"name": "Ada\u00`, want: `{"name": "Ada"}`},
+		{text: `{"name": "Ada", "age": 3`, want: `{"name": "Ada"}`, wantComplete: []string{"name"}},
+		{text: `{"name": "Ada", "age": 36,`, want: `{"name": "Ada", "age": 36}`, wantComplete: []string{"name", "age"}},
+		{text: `{"scores": [1.5, -2`, want: `{"scores": [1.5]}`},
+		{text: `{"name": "Ada", "age": 36, "vip": tr`, want: `{"name": "Ada", "age": 36}`, wantComplete: []string{"name", "age"}},
+		{text: `{"tags": ["a", "b`, want: `{"tags": ["a", "b"]}`},
+		{text: `{"tags": ["a"], "address": {"city": "Par`, want: `{"tags": ["a"], "address": {"city": "Par"}}`, wantComplete: []string{"tags"}},
+		{text: `{"address": {"city": "Paris"}, "x": 1} trailing`, want: `{"address": {"city": "Paris"}, "x": 1}`, wantComplete: []string{"address", "x"}, wantDone: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			repaired, complete, done := repairPartialJSON(tt.text)
+			assert.Equal(t, tt.want, repaired)
+			assert.Equal(t, tt.wantComplete, complete)
+			assert.Equal(t, tt.wantDone, done)
+		})
+	}
+}
+
+func TestBindStream(t *testing.T) {
+	type contact struct {
+		Name  string   `json:"name"`
+		Email string   `json:"email"`
+		Tags  []string `json:"tags"`
+	}
+
+	text := `{"name": "Ada Lovelace", "email": "ada@example.com", "tags": ["math"]}`
+	stream := make(chan StreamChunk, len(text)+1)
+	for i := 0; i < len(text); i += 7 {
+		stream <- StreamTextChunk{Text: text[i:min(i+7, len(text))]}
+	}
+	stream <- StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 20}}
+	close(stream)
+
+	var updates []*Partial[contact]
+	result, err := BindStream(context.Background(), stream, func(partial *Partial[contact]) {
+		updates = append(updates, partial)
+	})
+	require.NoError(t, err)
+
+	assert.True(t, result.Done)
+	assert.Equal(t, contact{Name: "Ada Lovelace", Email: "ada@example.com", Tags: []string{"math"}}, result.Value)
+	assert.Equal(t, int64(20), result.Usage.TotalOutputTokens)
+
+	// The name streams in before it is complete, then the email follows
+	require.Greater(t, len(updates), 3)
+	assert.Equal(t, "Ada ", updates[1].Value.Name)
+	assert.False(t, updates[1].IsComplete("name"))
+	var sawNameOnly bool
+	for _, partial := range updates {
+		if partial.IsComplete("name") && !partial.IsComplete("email") {
+			sawNameOnly = true
+			assert.Equal(t, "Ada Lovelace", partial.Value.Name)
+		}
+	}
+	assert.True(t, sawNameOnly)
+}
+
+func TestBindStream_Incomplete(t *testing.T) {
+	stream := make(chan StreamChunk, 1)
+	stream <- StreamTextChunk{Text: `{"name": "Ad`}
+	close(stream)
+
+	result, err := BindStream[struct {
+		Name string `json:"name"`
+	}](context.Background(), stream, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "Ad", result.Value.Name)
+	assert.False(t, result.Done)
+}
@@ -0,0 +1,545 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Budget caps the cost of the requests made with a context, see WithBudget. It is safe for
+// concurrent use.
+type Budget struct {
+	limit  MicroCents
+	parent *Budget
+
+	mu    sync.Mutex
+	spent MicroCents
+	// reserved is the estimated cost of the requests in flight
+	reserved MicroCents
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a context whose requests to the models of providers, or to models wrapped
+// with NewCostTrackingCompletionModel and the like, may cost at most usd in total. Requests
+// whose estimated prompt cost would exceed the budget fail with a BudgetExceededError before
+// they are sent, and the estimate is reserved until the request completes, so concurrent
+// requests cannot overshoot the budget together. Only completions have their cost estimated;
+// the requests of other models are rejected once the budget is exhausted. Budgets nest: costs
+// count against every budget of the context. Budgets are in USD: requests to models known to be
+// priced in another currency fail with a CurrencyMismatchError, and costs reported in another
+// currency are not charged.
+func WithBudget(ctx context.Context, usd float64) context.Context {
+	return context.WithValue(ctx, budgetKey{}, &Budget{
+		limit:  MicroCentsFromUSD(usd),
+		parent: BudgetFromContext(ctx),
+	})
+}
+
+// BudgetFromContext returns the innermost budget of ctx, or nil
+func BudgetFromContext(ctx context.Context) *Budget {
+	budget, _ := ctx.Value(budgetKey{}).(*Budget)
+	return budget
+}
+
+// Limit returns the cap of the budget
+func (b *Budget) Limit() MicroCents {
+	return b.limit
+}
+
+// Spent returns the cost charged to the budget
+func (b *Budget) Spent() MicroCents {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+// Remaining returns the cost that may still be spent, which is never negative
+func (b *Budget) Remaining() MicroCents {
+	return max(b.limit-b.Spent(), 0)
+}
+
+// reserve reserves estimate for a request to a model priced in currency, empty if unknown,
+// or returns a BudgetExceededError if it would exceed the budget
+func (b *Budget) reserve(estimate MicroCents, currency string) error {
+	if currency != "" && currencyCode(currency) != "USD" {
+		return &CurrencyMismatchError{Currency: currencyCode(currency), Expected: "USD"}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := exceeds(b.limit, b.spent+b.reserved, estimate); err != nil {
+		return err
+	}
+	b.reserved += estimate
+	return nil
+}
+
+// settle releases a reservation of estimate and charges cost, unless it is in another currency
+func (b *Budget) settle(estimate, cost MicroCents, currency string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserved -= estimate
+	if currency == "" || currencyCode(currency) == "USD" {
+		b.spent += cost
+	}
+}
+
+// BudgetExceededError is returned instead of sending a request that would exceed a budget or
+// the limit of a CostTracker. It matches ErrBudgetExceeded with errors.Is.
+type BudgetExceededError struct {
+	// Limit is the cap in USD
+	Limit float64
+	// Spent is the cost already charged, including the estimates reserved by the requests in
+	// flight, in USD
+	Spent float64
+	// Estimate is the estimated cost of the request in USD, 0 if unknown
+	Estimate float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("request estimated at $%.6f would exceed the budget of $%.6f with $%.6f spent", e.Estimate, e.Limit, e.Spent)
+}
+
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// CurrencyMismatchError is returned instead of sending a request to a model priced in another
+// currency than a budget or the limit of a CostTracker, whose cost could not count against it.
+// It matches ErrCurrencyMismatch with errors.Is.
+type CurrencyMismatchError struct {
+	// Currency is the currency of the pricing of the model
+	Currency string
+	// Expected is the currency of the cap
+	Expected string
+}
+
+func (e *CurrencyMismatchError) Error() string {
+	return fmt.Sprintf("model priced in %s cannot be charged to a spending cap in %s", e.Currency, e.Expected)
+}
+
+func (e *CurrencyMismatchError) Is(target error) bool {
+	return target == ErrCurrencyMismatch
+}
+
+// exceeds returns a BudgetExceededError if spending estimate on top of spent would exceed
+// limit. Once the limit is reached, requests of unknown cost are rejected too.
+func exceeds(limit, spent, estimate MicroCents) error {
+	if spent+estimate > limit || (estimate == 0 && spent >= limit) {
+		return &BudgetExceededError{Limit: limit.USD(), Spent: spent.USD(), Estimate: estimate.USD()}
+	}
+	return nil
+}
+
+// spender charges the requests of a model to a tracker and the budgets of their context. It
+// learns the currency of the model from the cost breakdowns of its responses, so requests
+// to models priced in another currency than a cap are rejected once it is known.
+type spender struct {
+	tracker  *CostTracker
+	currency atomic.Value
+}
+
+// reserve reserves estimate for a request to a model priced in currency, or in the currency
+// learnt from earlier responses when empty, on the tracker and every budget of ctx. It returns
+// an error without reserving anything if the request would exceed one of their caps or their
+// currency differs.
+func (s *spender) reserve(ctx context.Context, estimate MicroCents, currency string) (*spendReservation, error) {
+	if currency == "" {
+		currency, _ = s.currency.Load().(string)
+	}
+	r := &spendReservation{spender: s, estimate: estimate, currency: currency}
+	if s.tracker != nil {
+		if err := s.tracker.reserve(estimate, currency); err != nil {
+			return nil, err
+		}
+		r.tracker = s.tracker
+	}
+	for budget := BudgetFromContext(ctx); budget != nil; budget = budget.parent {
+		if err := budget.reserve(estimate, currency); err != nil {
+			r.settle(nil, nil, nil)
+			return nil, err
+		}
+		r.budgets = append(r.budgets, budget)
+	}
+	return r, nil
+}
+
+// charge records the usage and cost of a response on the tracker and the budgets of ctx
+// without a reservation, e.g. for jobs that complete after the request that started them
+func (s *spender) charge(ctx context.Context, usage *TokenUsage, cost *float64, breakdown *CostBreakdown) {
+	r := &spendReservation{spender: s, tracker: s.tracker, settled: true}
+	for budget := BudgetFromContext(ctx); budget != nil; budget = budget.parent {
+		r.budgets = append(r.budgets, budget)
+	}
+	r.settle(usage, cost, breakdown)
+}
+
+// spendReservation is the estimated cost of a request reserved on a tracker and the budgets
+// of its context until the request is settled
+type spendReservation struct {
+	spender  *spender
+	tracker  *CostTracker
+	budgets  []*Budget
+	estimate MicroCents
+	// currency is the currency of the model, empty if unknown
+	currency string
+	settled  bool
+}
+
+// settle records the usage and cost of a response, nil if the request failed, releasing the
+// reservation the first time. The currency of the cost is the one of breakdown, if any. Streams
+// settle every usage chunk.
+func (r *spendReservation) settle(usage *TokenUsage, cost *float64, breakdown *CostBreakdown) {
+	estimate := r.estimate
+	if r.settled {
+		estimate = 0
+	}
+	r.settled = true
+	currency := r.currency
+	if breakdown != nil {
+		currency = currencyCode(breakdown.Currency)
+		r.spender.currency.Store(currency)
+	}
+	var charged MicroCents
+	if cost != nil {
+		charged = MicroCentsFromUSD(*cost)
+	}
+	if r.tracker != nil {
+		r.tracker.settle(estimate, usage, cost, currency)
+	}
+	for _, budget := range r.budgets {
+		budget.settle(estimate, charged, currency)
+	}
+}
+
+// CostTrackingCompletionModel records the usage and cost of completions on a CostTracker and
+// the budgets of their context, and rejects completions that would exceed a cap. The prompt
+// cost is estimated when the wrapped model implements TokenCounter and reserved on the caps
+// while the completion runs. Costs are only known for
+// models created WithCost or WithUsage.
+type CostTrackingCompletionModel struct {
+	model CompletionModel
+	spend spender
+}
+
+var _ CompletionModel = (*CostTrackingCompletionModel)(nil)
+var _ TokenCounter = (*CostTrackingCompletionModel)(nil)
+
+// NewCostTrackingCompletionModel wraps model with cost tracking. tracker may be nil to only
+// enforce the budgets of contexts.
+func NewCostTrackingCompletionModel(model CompletionModel, tracker *CostTracker) *CostTrackingCompletionModel {
+	return &CostTrackingCompletionModel{model: model, spend: spender{tracker: tracker}}
+}
+
+func (m *CostTrackingCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	reservation, err := m.reserve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.Complete(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+	reservation.settle(resp.Usage, resp.Cost, resp.CostBreakdown)
+	return resp, nil
+}
+
+func (m *CostTrackingCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	reservation, err := m.reserve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := m.model.StreamComplete(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer close(out)
+		// A stream without usage still releases its reservation. One cut short by the
+		// context is charged the estimate, as the provider may bill what it generated.
+		defer func() {
+			if reservation.settled {
+				return
+			}
+			if ctx.Err() != nil {
+				estimate := reservation.estimate.USD()
+				reservation.settle(nil, &estimate, nil)
+				return
+			}
+			reservation.settle(nil, nil, nil)
+		}()
+		for chunk := range stream {
+			if c, ok := chunk.(StreamUsageChunk); ok {
+				reservation.settle(c.Usage, c.Cost, c.CostBreakdown)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				go func() {
+					for range stream {
+					}
+				}()
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *CostTrackingCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
+
+// reserve estimates the prompt cost of req and reserves it on the caps
+func (m *CostTrackingCompletionModel) reserve(ctx context.Context, req *CompletionRequest) (*spendReservation, error) {
+	var estimate MicroCents
+	var currency string
+	if count, err := CountTokens(m.model, req); err == nil && count.Cost != nil {
+		estimate, currency = MicroCentsFromUSD(*count.Cost), currencyCode(count.Currency)
+	}
+	return m.spend.reserve(ctx, estimate, currency)
+}
+
+// CostTrackingEmbeddingModel records the usage and cost of embeddings like a
+// CostTrackingCompletionModel.
+type CostTrackingEmbeddingModel struct {
+	model EmbeddingModel
+	spend spender
+}
+
+var _ EmbeddingModel = (*CostTrackingEmbeddingModel)(nil)
+
+// NewCostTrackingEmbeddingModel wraps model with cost tracking
+func NewCostTrackingEmbeddingModel(model EmbeddingModel, tracker *CostTracker) *CostTrackingEmbeddingModel {
+	return &CostTrackingEmbeddingModel{model: model, spend: spender{tracker: tracker}}
+}
+
+func (m *CostTrackingEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	reservation, err := m.spend.reserve(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.GenerateEmbeddings(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+	reservation.settle(resp.Usage, resp.Cost, resp.CostBreakdown)
+	return resp, nil
+}
+
+// CostTrackingImageModel records the usage and cost of images like a
+// CostTrackingCompletionModel.
+type CostTrackingImageModel struct {
+	model ImageModel
+	spend spender
+}
+
+var _ ImageModel = (*CostTrackingImageModel)(nil)
+
+// NewCostTrackingImageModel wraps model with cost tracking
+func NewCostTrackingImageModel(model ImageModel, tracker *CostTracker) *CostTrackingImageModel {
+	return &CostTrackingImageModel{model: model, spend: spender{tracker: tracker}}
+}
+
+func (m *CostTrackingImageModel) GenerateImage(ctx context.Context, req *ImageRequest) (*ImageResponse, error) {
+	reservation, err := m.spend.reserve(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.GenerateImage(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+	reservation.settle(resp.Usage, resp.Cost, resp.CostBreakdown)
+	return resp, nil
+}
+
+// CostTrackingImageEditModel records the usage and cost of images, edited images and
+// variations like a CostTrackingImageModel
+type CostTrackingImageEditModel struct {
+	*CostTrackingImageModel
+	model ImageEditModel
+}
+
+var _ ImageEditModel = (*CostTrackingImageEditModel)(nil)
+
+// NewCostTrackingImageEditModel wraps model with cost tracking
+func NewCostTrackingImageEditModel(model ImageEditModel, tracker *CostTracker) *CostTrackingImageEditModel {
+	return &CostTrackingImageEditModel{CostTrackingImageModel: NewCostTrackingImageModel(model, tracker), model: model}
+}
+
+func (m *CostTrackingImageEditModel) EditImage(ctx context.Context, req *ImageEditRequest) (*ImageResponse, error) {
+	reservation, err := m.spend.reserve(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.EditImage(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+	reservation.settle(resp.Usage, resp.Cost, resp.CostBreakdown)
+	return resp, nil
+}
+
+func (m *CostTrackingImageEditModel) CreateVariation(ctx context.Context, req *ImageVariationRequest) (*ImageResponse, error) {
+	reservation, err := m.spend.reserve(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.CreateVariation(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+	reservation.settle(resp.Usage, resp.Cost, resp.CostBreakdown)
+	return resp, nil
+}
+
+// CostTrackingImageJobModel records the usage and cost of images like a
+// CostTrackingImageModel, charging jobs when they succeed. A job is charged once by the model,
+// however many times it is resumed.
+type CostTrackingImageJobModel struct {
+	*CostTrackingImageModel
+	model ImageJobModel
+	// charged holds the IDs of the jobs charged
+	charged sync.Map
+}
+
+var _ ImageJobModel = (*CostTrackingImageJobModel)(nil)
+
+// NewCostTrackingImageJobModel wraps model with cost tracking
+func NewCostTrackingImageJobModel(model ImageJobModel, tracker *CostTracker) *CostTrackingImageJobModel {
+	return &CostTrackingImageJobModel{CostTrackingImageModel: NewCostTrackingImageModel(model, tracker), model: model}
+}
+
+func (m *CostTrackingImageJobModel) StartImage(ctx context.Context, req *ImageRequest) (*ImageJob, error) {
+	// Jobs are charged when they succeed, so nothing is reserved while they run
+	reservation, err := m.spend.reserve(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	reservation.settle(nil, nil, nil)
+	job, err := m.model.StartImage(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	m.chargeOnSuccess(job)
+	return job, nil
+}
+
+func (m *CostTrackingImageJobModel) ResumeImage(ctx context.Context, id string, config *ImageModelConfig) (*ImageJob, error) {
+	job, err := m.model.ResumeImage(ctx, id, config)
+	if err != nil {
+		return nil, err
+	}
+	m.chargeOnSuccess(job)
+	return job, nil
+}
+
+// chargeOnSuccess charges the images of job when it succeeds, unless the job was already
+// charged through another ImageJob of the same ID
+func (m *CostTrackingImageJobModel) chargeOnSuccess(job *ImageJob) {
+	id := job.ID()
+	job.OnSuccess(func(ctx context.Context, resp *ImageResponse) {
+		if _, charged := m.charged.LoadOrStore(id, true); charged {
+			return
+		}
+		m.spend.charge(ctx, resp.Usage, resp.Cost, resp.CostBreakdown)
+	})
+}
+
+// CostTrackingSpeechModel records the usage and cost of speech like a
+// CostTrackingCompletionModel.
+type CostTrackingSpeechModel struct {
+	model SpeechModel
+	spend spender
+}
+
+var _ SpeechModel = (*CostTrackingSpeechModel)(nil)
+
+// NewCostTrackingSpeechModel wraps model with cost tracking
+func NewCostTrackingSpeechModel(model SpeechModel, tracker *CostTracker) *CostTrackingSpeechModel {
+	return &CostTrackingSpeechModel{model: model, spend: spender{tracker: tracker}}
+}
+
+func (m *CostTrackingSpeechModel) GenerateSpeech(ctx context.Context, req *SpeechRequest) (*SpeechResponse, error) {
+	reservation, err := m.spend.reserve(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.GenerateSpeech(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+	reservation.settle(resp.Usage, resp.Cost, resp.CostBreakdown)
+	return resp, nil
+}
+
+// CostTrackingVideoModel records the usage and cost of videos like a
+// CostTrackingCompletionModel.
+type CostTrackingVideoModel struct {
+	model VideoModel
+	spend spender
+}
+
+var _ VideoModel = (*CostTrackingVideoModel)(nil)
+
+// NewCostTrackingVideoModel wraps model with cost tracking
+func NewCostTrackingVideoModel(model VideoModel, tracker *CostTracker) *CostTrackingVideoModel {
+	return &CostTrackingVideoModel{model: model, spend: spender{tracker: tracker}}
+}
+
+func (m *CostTrackingVideoModel) GenerateVideo(ctx context.Context, req *VideoRequest) (*VideoResponse, error) {
+	reservation, err := m.spend.reserve(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.GenerateVideo(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+	reservation.settle(resp.Usage, resp.Cost, resp.CostBreakdown)
+	return resp, nil
+}
+
+// CostTrackingRerankModel records the usage and cost of reranking like a
+// CostTrackingCompletionModel.
+type CostTrackingRerankModel struct {
+	model RerankModel
+	spend spender
+}
+
+var _ RerankModel = (*CostTrackingRerankModel)(nil)
+
+// NewCostTrackingRerankModel wraps model with cost tracking
+func NewCostTrackingRerankModel(model RerankModel, tracker *CostTracker) *CostTrackingRerankModel {
+	return &CostTrackingRerankModel{model: model, spend: spender{tracker: tracker}}
+}
+
+func (m *CostTrackingRerankModel) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	reservation, err := m.spend.reserve(ctx, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.model.Rerank(ctx, req)
+	if err != nil {
+		reservation.settle(nil, nil, nil)
+		return nil, err
+	}
+	reservation.settle(resp.Usage, resp.Cost, nil)
+	return resp, nil
+}
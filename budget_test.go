@@ -0,0 +1,267 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pricedModel charges cost per request in currency and estimates its prompt cost at estimate
+type pricedModel struct {
+	cost     float64
+	estimate float64
+	currency string
+	calls    int
+}
+
+func (m *pricedModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	m.calls++
+	cost := m.cost
+	return &CompletionResponse{
+		Output: "ok",
+		Usage:  &TokenUsage{TotalInputTokens: 100, TotalOutputTokens: 10, TotalRequests: 1},
+		Cost:   &cost,
+		CostBreakdown: &CostBreakdown{
+			Output:   MicroCentsFromUSD(cost),
+			Currency: m.currency,
+		},
+	}, nil
+}
+
+func (m *pricedModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	m.calls++
+	ch := make(chan StreamChunk, 2)
+	cost := m.cost
+	ch <- StreamTextChunk{Text: "ok"}
+	ch <- StreamUsageChunk{Usage: &TokenUsage{TotalInputTokens: 100, TotalRequests: 1}, Cost: &cost}
+	close(ch)
+	return ch, nil
+}
+
+func (m *pricedModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	estimate := m.estimate
+	return &TokenCount{InputTokens: 100, Cost: &estimate, Currency: m.currency}, nil
+}
+
+func TestCostTrackingCompletionModel_TrackerLimit(t *testing.T) {
+	tracker := NewCostTracker()
+	tracker.SetLimit(1.00)
+	inner := &pricedModel{cost: 0.40, estimate: 0.25}
+	model := NewCostTrackingCompletionModel(inner, tracker)
+
+	for i := 0; i < 2; i++ {
+		_, err := model.Complete(context.Background(), &CompletionRequest{Instructions: "hi"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, MicroCentsFromUSD(0.80), tracker.Total())
+	assert.Equal(t, int64(200), tracker.Usage().TotalInputTokens)
+	assert.Equal(t, 2, tracker.Usage().TotalRequests)
+
+	// $0.80 spent plus an estimated $0.25 exceeds the $1.00 limit
+	_, err := model.Complete(context.Background(), &CompletionRequest{Instructions: "hi"})
+	require.ErrorIs(t, err, ErrBudgetExceeded)
+	var budgetErr *BudgetExceededError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, &BudgetExceededError{Limit: 1.00, Spent: 0.80, Estimate: 0.25}, budgetErr)
+	assert.Equal(t, 2, inner.calls)
+
+	tracker.Reset()
+	assert.Zero(t, tracker.Usage().TotalRequests)
+	assert.Equal(t, MicroCentsFromUSD(1.00), tracker.Limit())
+}
+
+func TestCostTrackingCompletionModel_Currency(t *testing.T) {
+	tracker := NewCostTracker()
+	inner := &pricedModel{cost: 0.40, estimate: 0.25, currency: "EUR"}
+	model := NewCostTrackingCompletionModel(inner, tracker)
+
+	// Without a limit, costs in another currency are kept apart
+	_, err := model.Complete(context.Background(), &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "USD", tracker.Currency())
+	assert.Zero(t, tracker.Total())
+	assert.Equal(t, MicroCentsFromUSD(0.40), tracker.TotalIn("EUR"))
+	assert.Equal(t, int64(1), tracker.Requests())
+
+	// A limit in USD cannot cap a model priced in EUR
+	tracker.SetLimit(1.00)
+	_, err = model.Complete(context.Background(), &CompletionRequest{Instructions: "hi"})
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+	var currencyErr *CurrencyMismatchError
+	require.True(t, errors.As(err, &currencyErr))
+	assert.Equal(t, &CurrencyMismatchError{Currency: "EUR", Expected: "USD"}, currencyErr)
+	assert.Equal(t, 1, inner.calls)
+
+	tracker.SetCurrency("eur")
+	assert.Equal(t, MicroCentsFromUSD(0.40), tracker.Total())
+	_, err = model.Complete(context.Background(), &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, MicroCentsFromUSD(0.80), tracker.TotalIn("EUR"))
+	assert.Zero(t, tracker.TotalIn("USD"))
+
+	// Budgets are in USD
+	tracker.SetLimit(0)
+	_, err = model.Complete(WithBudget(context.Background(), 5.00), &CompletionRequest{Instructions: "hi"})
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+	assert.Equal(t, 2, inner.calls)
+}
+
+func TestCostTrackingCompletionModel_ContextBudget(t *testing.T) {
+	model := NewCostTrackingCompletionModel(&pricedModel{cost: 0.30}, nil)
+
+	outer := WithBudget(context.Background(), 1.00)
+	inner := WithBudget(outer, 0.50)
+	_, err := model.Complete(inner, &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+	_, err = model.Complete(inner, &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+
+	// Without an estimate requests are allowed until the budget is used up
+	_, err = model.Complete(inner, &CompletionRequest{Instructions: "hi"})
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Equal(t, MicroCentsFromUSD(0.60), BudgetFromContext(inner).Spent())
+	assert.Zero(t, BudgetFromContext(inner).Remaining())
+
+	// The outer budget was charged for the inner requests as well
+	assert.Equal(t, MicroCentsFromUSD(0.40), BudgetFromContext(outer).Remaining())
+	_, err = model.Complete(outer, &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+	assert.Nil(t, BudgetFromContext(context.Background()))
+}
+
+func TestCostTrackingCompletionModel_Stream(t *testing.T) {
+	tracker := NewCostTracker()
+	model := NewCostTrackingCompletionModel(&pricedModel{cost: 0.10}, tracker)
+	ctx := WithBudget(context.Background(), 1.00)
+
+	stream, err := model.StreamComplete(ctx, &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+	var chunks int
+	for range stream {
+		chunks++
+	}
+	assert.Equal(t, 2, chunks)
+	assert.Equal(t, MicroCentsFromUSD(0.10), tracker.Total())
+	assert.Equal(t, MicroCentsFromUSD(0.10), BudgetFromContext(ctx).Spent())
+}
+
+func TestDefaultModelProvider_CostTracker(t *testing.T) {
+	provider := NewDefaultModelProvider("test", nil)
+	model := &pricedModel{cost: 0.30}
+
+	// Budgets are enforced without a tracker
+	ctx := WithBudget(context.Background(), 0.50)
+	wrapped := provider.WrapCompletionModel(model)
+	for range 2 {
+		_, err := wrapped.Complete(ctx, &CompletionRequest{})
+		require.NoError(t, err)
+	}
+	_, err := wrapped.Complete(ctx, &CompletionRequest{})
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	assert.Equal(t, MicroCentsFromUSD(0.60), BudgetFromContext(ctx).Spent())
+
+	tracker := NewCostTracker()
+	provider.SetCostTracker(tracker)
+	assert.Same(t, tracker, provider.CostTracker())
+	assert.IsType(t, &CostTrackingCompletionModel{}, provider.WrapCompletionModel(model))
+	assert.Equal(t, tracker, ApplyOptions([]ModelOption{WithCostTracker(tracker)}).CostTracker)
+}
+
+// blockingPricedModel is a pricedModel whose requests wait for release
+type blockingPricedModel struct {
+	pricedModel
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingPricedModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	m.started <- struct{}{}
+	<-m.release
+	cost := m.cost
+	return &CompletionResponse{Output: "ok", Cost: &cost}, nil
+}
+
+func TestCostTrackingCompletionModel_ConcurrentReservations(t *testing.T) {
+	tracker := NewCostTracker()
+	tracker.SetLimit(1.00)
+	inner := &blockingPricedModel{
+		pricedModel: pricedModel{cost: 0.40, estimate: 0.40},
+		started:     make(chan struct{}, 3),
+		release:     make(chan struct{}),
+	}
+	model := NewCostTrackingCompletionModel(inner, tracker)
+	ctx := WithBudget(context.Background(), 2.00)
+
+	// Two requests in flight reserve $0.80, so a third estimated at $0.40 is rejected
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := model.Complete(ctx, &CompletionRequest{})
+			errs <- err
+		}()
+	}
+	<-inner.started
+	<-inner.started
+	_, err := model.Complete(ctx, &CompletionRequest{})
+	var budgetErr *BudgetExceededError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.Equal(t, 0.80, budgetErr.Spent)
+
+	close(inner.release)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+	assert.Equal(t, MicroCentsFromUSD(0.80), tracker.Total())
+	assert.Equal(t, MicroCentsFromUSD(0.80), BudgetFromContext(ctx).Spent())
+	// Failed and completed requests release their reservations
+	assert.Equal(t, MicroCents(0), tracker.reserved)
+	assert.Equal(t, MicroCents(0), BudgetFromContext(ctx).reserved)
+}
+
+// endlessPricedModel is a pricedModel whose streams emit text until their context is done
+type endlessPricedModel struct {
+	pricedModel
+}
+
+func (m *endlessPricedModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	ch := make(chan StreamChunk)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case ch <- StreamTextChunk{Text: "ok"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestCostTrackingCompletionModel_StreamCanceled(t *testing.T) {
+	tracker := NewCostTracker()
+	tracker.SetLimit(1.00)
+	model := NewCostTrackingCompletionModel(&endlessPricedModel{pricedModel{cost: 0.40, estimate: 0.40}}, tracker)
+
+	// The stream is abandoned without being read
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := model.StreamComplete(ctx, &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+	cancel()
+
+	// The reservation is settled at the estimate, so the limit is not held
+	assert.Eventually(t, func() bool {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		return tracker.reserved == 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, MicroCentsFromUSD(0.40), tracker.Total())
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	_, err = model.StreamComplete(ctx, &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+}
@@ -0,0 +1,43 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import "time"
+
+// CacheTTLHour is the extended cache lifetime supported by Anthropic models
+const CacheTTLHour = time.Hour
+
+// CacheControl marks the end of a prompt prefix the provider should cache. Requests that
+// repeat the prefix read it from the cache, reported in TokenUsage.TotalCacheReadTokens and
+// billed at ModelPricing.InputCacheRead. Anthropic caches only marked prefixes, at most four
+// per request, and longer TTLs must come before shorter ones; OpenAI caches long prefixes
+// automatically and ignores the marks.
+type CacheControl struct {
+	// TTL is how long the prefix stays cached; zero uses the provider default of 5 minutes
+	TTL time.Duration `json:"ttl,omitempty"`
+}
+
+// WithCacheControl marks the message as the end of a cached prompt prefix, e.g. the last
+// message of a long document or of a conversation history that later requests repeat
+func (m *ModelMessage) WithCacheControl(ttl time.Duration) *ModelMessage {
+	m.CacheControl = &CacheControl{TTL: ttl}
+	return m
+}
+
+// WithInstructionsCache caches the request instructions, which providers place before all
+// messages, so repeated system prompts are read from the cache
+func WithInstructionsCache(ttl time.Duration) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.InstructionsCache = &CacheControl{TTL: ttl}
+	}
+}
+
+// WithPromptCacheKey sets the key OpenAI uses to route requests sharing a prompt prefix to the
+// same cache, improving hit rates when many requests share long prefixes. It is ignored by
+// other providers.
+func WithPromptCacheKey(key string) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.PromptCacheKey = &key
+	}
+}
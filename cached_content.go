@@ -0,0 +1,42 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// CachedContent is a handle to a prompt prefix stored by the provider. Requests made with
+// WithCachedContent reference it by Name instead of sending it again, and its tokens are billed
+// at the InputCacheRead price of the model.
+type CachedContent struct {
+	// Name identifies the content at the provider, e.g. cachedContents/abc123
+	Name string `json:"name"`
+	// Model is the model the content was cached for; only requests to it can use the content
+	Model string `json:"model"`
+	// ExpireTime is when the provider deletes the content
+	ExpireTime time.Time `json:"expireTime"`
+	// TokenCount is the number of cached tokens
+	TokenCount int64 `json:"tokenCount"`
+}
+
+// ContentCacheProvider is implemented by providers that store prompt prefixes as named cached
+// content, such as Gemini. Assert it on the ModelProvider to create cached content.
+type ContentCacheProvider interface {
+	// CreateCachedContent caches the instructions and messages of req for model. The content
+	// expires after ttl, or the provider's default when zero.
+	CreateCachedContent(ctx context.Context, model string, req *CompletionRequest, ttl time.Duration) (*CachedContent, error)
+	// DeleteCachedContent deletes the cached content with the given name before it expires
+	DeleteCachedContent(ctx context.Context, name string) error
+}
+
+// WithCachedContent prefixes requests with the cached content with the given name, created by a
+// ContentCacheProvider for the same model. The instructions belong in the cached content, so
+// requests using it should not set their own.
+func WithCachedContent(name string) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.CachedContent = &name
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// classifyInstructions asks the model to answer with the label as JSON, for providers that do
+// not enforce the response schema
+const classifyInstructions = `Classify the text given by the user into exactly one of these labels: %s.
+Respond only with a JSON object of the form {"label": "<label>"}.`
+
+// Classification is the result of Classify
+type Classification struct {
+	// Label is one of the labels passed to Classify
+	Label string `json:"label"`
+	// Confidence is the probability the model assigned to the label tokens, or nil when the
+	// provider does not return logprobs or the model is a reasoning model
+	Confidence *float64    `json:"confidence,omitempty"`
+	Usage      *TokenUsage `json:"usage,omitempty"`
+	Cost       *float64    `json:"cost,omitempty"`
+}
+
+// Classify assigns text one of labels with a model of the provider. The response is constrained
+// to a JSON schema with the labels as an enum, and the result is validated against labels since
+// not every provider enforces schemas. opts are applied to the model before the options
+// Classify sets for structured output and logprobs. Logprobs are not requested from reasoning
+// models, which reject them.
+func Classify(ctx context.Context, provider ModelProvider, model string, text string, labels []string, opts ...CompletionOption) (*Classification, error) {
+	if provider == nil {
+		return nil, NewValidationError("provider", "cannot be nil", nil)
+	}
+	if len(labels) == 0 {
+		return nil, NewValidationError("labels", "cannot be empty", nil)
+	}
+
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"label": map[string]any{"type": "string", "enum": labels},
+		},
+		"required":             []string{"label"},
+		"additionalProperties": false,
+	}
+	opts = append(opts, WithResponseFormat(ResponseFormatJsonSchema), WithJSONSchema(schema))
+	if info := findModelInfo(provider, model); info == nil || !info.Reasoning {
+		opts = append(opts, WithTopLogprobs(0))
+	}
+	completionModel, err := provider.NewCompletionModel(model, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	quoted, err := json.Marshal(labels)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := completionModel.Complete(ctx, &CompletionRequest{
+		Instructions: fmt.Sprintf(classifyInstructions, quoted),
+		Messages:     []*ModelMessage{{Role: RoleUser, Content: text}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := parseLabel(resp.Output)
+	if err != nil {
+		return nil, NewResponseError(provider.Name(), "failed to parse classification", err)
+	}
+	label, ok := matchLabel(value, labels)
+	if !ok {
+		return nil, NewResponseError(provider.Name(), fmt.Sprintf("label %q is not one of the labels", value), nil)
+	}
+
+	return &Classification{
+		Label:      label,
+		Confidence: labelConfidence(resp.Output, value, resp.Logprobs),
+		Usage:      resp.Usage,
+		Cost:       resp.Cost,
+	}, nil
+}
+
+// parseLabel returns the label of the JSON object in output
+func parseLabel(output string) (string, error) {
+	var result struct {
+		Label string `json:"label"`
+	}
+	if err := unmarshalJSONObject(output, &result); err != nil {
+		return "", err
+	}
+	return result.Label, nil
+}
+
+// unmarshalJSONObject decodes the outermost JSON object in output, which may be wrapped in a
+// code fence or surrounded by text
+func unmarshalJSONObject(output string, v any) error {
+	start := strings.Index(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("no JSON object in %q", output)
+	}
+	return json.Unmarshal([]byte(output[start:end+1]), v)
+}
+
+// matchLabel returns the label matching value exactly, or ignoring case and surrounding whitespace
+func matchLabel(value string, labels []string) (string, bool) {
+	for _, label := range labels {
+		if label == value {
+			return label, true
+		}
+	}
+	for _, label := range labels {
+		if strings.EqualFold(strings.TrimSpace(label), strings.TrimSpace(value)) {
+			return label, true
+		}
+	}
+	return "", false
+}
+
+// labelConfidence returns the joint probability of the tokens that spell value in output. It
+// returns nil without logprobs or when the tokens do not reproduce the output.
+func labelConfidence(output, value string, logprobs []TokenLogprob) *float64 {
+	if len(logprobs) == 0 || value == "" {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	// The label is searched for as a JSON string so a key equal to the label is not matched
+	start := strings.LastIndex(output, string(encoded))
+	if start < 0 {
+		return nil
+	}
+	start++
+	end := start + len(encoded) - 2
+
+	var sum float64
+	offset := 0
+	for _, lp := range logprobs {
+		next := offset + len(lp.Token)
+		if next > len(output) || output[offset:next] != lp.Token {
+			return nil
+		}
+		if next > start && offset < end {
+			sum += lp.Logprob
+		}
+		offset = next
+	}
+	confidence := math.Exp(sum)
+	return &confidence
+}
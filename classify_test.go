@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedResponseProvider returns models that answer with a fixed response and records their options
+type fixedResponseProvider struct {
+	*DefaultModelProvider
+	resp    *CompletionResponse
+	options *CompletionOptions
+	req     *CompletionRequest
+}
+
+func (p *fixedResponseProvider) NewCompletionModel(model string, opts ...CompletionOption) (CompletionModel, error) {
+	p.options = ApplyCompletionOptions(opts)
+	return p, nil
+}
+
+func (p *fixedResponseProvider) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p.req = req
+	return p.resp, nil
+}
+
+func (p *fixedResponseProvider) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	return nil, ErrInvalidRequest
+}
+
+func TestClassify(t *testing.T) {
+	labels := []string{"positive", "negative", "neutral"}
+
+	t.Run("confidence from logprobs", func(t *testing.T) {
+		provider := &fixedResponseProvider{
+			DefaultModelProvider: NewDefaultModelProvider("test", nil),
+			resp: &CompletionResponse{
+				Output: `{"label":"negative"}`,
+				Logprobs: []TokenLogprob{
+					{Token: `{"`, Logprob: 0},
+					{Token: `label`, Logprob: 0},
+					{Token: `":"`, Logprob: 0},
+					{Token: `neg`, Logprob: -0.1},
+					{Token: `ative`, Logprob: -0.2},
+					{Token: `"}`, Logprob: 0},
+				},
+			},
+		}
+
+		result, err := Classify(context.Background(), provider, "m", "I hated it", labels, WithTemperature(0))
+		require.NoError(t, err)
+
+		assert.Equal(t, "negative", result.Label)
+		require.NotNil(t, result.Confidence)
+		assert.InDelta(t, 0.7408, *result.Confidence, 1e-4)
+
+		assert.Equal(t, ResponseFormatJsonSchema, *provider.options.ResponseFormat)
+		assert.Equal(t, 0.0, *provider.options.Temperature)
+		assert.Equal(t, 0, *provider.options.TopLogprobs)
+		schema := provider.options.JSONSchema.(map[string]any)
+		assert.Equal(t, labels, schema["properties"].(map[string]any)["label"].(map[string]any)["enum"])
+		assert.Contains(t, provider.req.Instructions, `["positive","negative","neutral"]`)
+		assert.Equal(t, "I hated it", provider.req.Messages[0].Content)
+	})
+
+	t.Run("fenced output without logprobs", func(t *testing.T) {
+		provider := &fixedResponseProvider{
+			DefaultModelProvider: NewDefaultModelProvider("test", nil),
+			resp:                 &CompletionResponse{Output: "```json\n{\"label\": \"Neutral\"}\n```"},
+		}
+
+		result, err := Classify(context.Background(), provider, "m", "It was fine", labels)
+		require.NoError(t, err)
+		assert.Equal(t, "neutral", result.Label)
+		assert.Nil(t, result.Confidence)
+	})
+
+	t.Run("reasoning model without logprobs", func(t *testing.T) {
+		provider := &fixedResponseProvider{
+			DefaultModelProvider: NewDefaultModelProvider("test", []*ModelInfo{{ID: "r", Reasoning: true}}),
+			resp:                 &CompletionResponse{Output: `{"label":"positive"}`},
+		}
+
+		result, err := Classify(context.Background(), provider, "r", "Loved it", labels)
+		require.NoError(t, err)
+		assert.Equal(t, "positive", result.Label)
+		assert.Nil(t, result.Confidence)
+		assert.Nil(t, provider.options.TopLogprobs)
+		assert.Equal(t, ResponseFormatJsonSchema, *provider.options.ResponseFormat)
+	})
+
+	t.Run("unknown label", func(t *testing.T) {
+		provider := &fixedResponseProvider{
+			DefaultModelProvider: NewDefaultModelProvider("test", nil),
+			resp:                 &CompletionResponse{Output: `{"label":"mixed"}`},
+		}
+
+		_, err := Classify(context.Background(), provider, "m", "Good and bad", labels)
+		var respErr *ResponseError
+		require.ErrorAs(t, err, &respErr)
+		assert.Contains(t, err.Error(), `"mixed"`)
+	})
+
+	t.Run("no labels", func(t *testing.T) {
+		_, err := Classify(context.Background(), &fixedResponseProvider{}, "m", "text", nil)
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
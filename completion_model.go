@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"math"
 )
 
 // CompletionModel defines the interface for text completion operations
@@ -39,6 +40,74 @@ type CompletionResponse struct {
 	Output string `json:"output"`
 	Usage  *TokenUsage
 	Cost   *float64
+	// CostBreakdown splits Cost by what was billed, e.g. cached input or reasoning
+	CostBreakdown *CostBreakdown `json:"costBreakdown,omitempty"`
+	Metadata      *ResponseMetadata
+	// WebSearches are the searches the provider ran while generating the output
+	WebSearches []*WebSearch `json:"webSearches,omitempty"`
+	// Logprobs are the output tokens with their log probabilities, when requested with
+	// WithTopLogprobs and supported by the provider
+	Logprobs []TokenLogprob `json:"logprobs,omitempty"`
+	// FinishReason is why the model stopped generating, normalized across providers; empty
+	// when the provider does not report it
+	FinishReason FinishReason `json:"finishReason,omitempty"`
+	// Refusal is the text of a refusal the provider sent apart from the output, e.g. when
+	// OpenAI declines a structured output request; FinishReason is then content_filter
+	Refusal string `json:"refusal,omitempty"`
+	// ID is the provider's identifier of the response, for support requests and logs
+	ID string `json:"id,omitempty"`
+	// Choices are all the candidate completions when more than one was requested with WithN.
+	// The first choice is also reported by Output, FinishReason, Refusal and Logprobs, and Usage
+	// and Cost cover all of them.
+	Choices []CompletionChoice `json:"choices,omitempty"`
+	// Reasoning is the reasoning the model returned apart from its output, e.g. the
+	// reasoning_content of DeepSeek R1; streams send it as StreamReasoningChunk
+	Reasoning string `json:"reasoning,omitempty"`
+	// Trace is every step of an Agent run: the output of each model call and the tool calls
+	// it made, in order
+	Trace []*TraceStep `json:"trace,omitempty"`
+	// Stream holds the chunks of the stream a cached response was collected from, so
+	// CachingCompletionModel replays every chunk of a cached stream, e.g. reasoning and
+	// citations. It is only set on responses in a CacheStore.
+	Stream []StreamEvent `json:"stream,omitempty"`
+}
+
+// CompletionChoice is one of several candidate completions of a request
+type CompletionChoice struct {
+	Index        int            `json:"index"`
+	Text         string         `json:"text"`
+	FinishReason FinishReason   `json:"finishReason,omitempty"`
+	Refusal      string         `json:"refusal,omitempty"`
+	Logprobs     []TokenLogprob `json:"logprobs,omitempty"`
+}
+
+// FinishReason is why a model stopped generating
+type FinishReason string
+
+const (
+	// FinishReasonStop means the model finished its answer or hit a stop sequence
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonLength means the output was cut off at the maximum number of tokens
+	FinishReasonLength FinishReason = "length"
+	// FinishReasonContentFilter means the output was withheld or refused for safety reasons
+	FinishReasonContentFilter FinishReason = "content_filter"
+	// FinishReasonToolCalls means the model stopped to call tools
+	FinishReasonToolCalls FinishReason = "tool_calls"
+)
+
+// TokenLogprob is an output token with its log probability
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	// TopLogprobs are the most likely tokens at this position, most likely first, when requested
+	// with a positive WithTopLogprobs
+	TopLogprobs []TokenLogprob `json:"topLogprobs,omitempty"`
+}
+
+// Probability returns the probability of the token, between 0 and 1, e.g. as the confidence
+// of the model in it
+func (t TokenLogprob) Probability() float64 {
+	return math.Exp(t.Logprob)
 }
 
 // CompletionOption is a functional option for configuring completion requests
@@ -61,6 +130,44 @@ type CompletionOptions struct {
 	MaxOutputTokens   *int
 	ParallelToolCalls *bool
 	TopLogprobs       *int
+	// N is the number of candidate completions to generate, see WithN
+	N *int
+	// OutputLanguage is the ISO 639-1 code of the language the model must respond in
+	OutputLanguage *string
+	// ValidateOutputLanguage enables post-hoc detection of the response language with a single retry on mismatch
+	ValidateOutputLanguage *bool
+	// Deterministic requests reproducible sampling, see WithDeterministic
+	Deterministic *bool
+	// WebSearch enables the provider's server-side web search, see WithWebSearch
+	WebSearch *WebSearchOptions
+	// StreamEvents limits streams to these chunk types, see WithStreamEvents
+	StreamEvents []StreamChunkType
+	// ContextWindowCheck rejects requests that exceed the context window, see WithContextWindowCheck
+	ContextWindowCheck *bool
+	// ExtendedContext enables the extended context window of the model, see WithExtendedContext
+	ExtendedContext *bool
+	// InstructionsCache caches the request instructions, see WithInstructionsCache
+	InstructionsCache *CacheControl
+	// PromptCacheKey groups requests sharing a prompt prefix, see WithPromptCacheKey
+	PromptCacheKey *string
+	// Prediction is the expected output used for speculative decoding, see WithPrediction
+	Prediction *string
+	// ServiceTier is the capacity tier requested from the provider, see WithServiceTier
+	ServiceTier *ServiceTier
+	// PooledTextChunks sends text as pooled chunks, see WithPooledTextChunks
+	PooledTextChunks *bool
+	// SafetySettings override the provider's safety thresholds, see WithSafetySettings
+	SafetySettings []SafetySetting
+	// Grounding grounds responses with Google Search, see WithGrounding
+	Grounding *GroundingOptions
+	// CachedContent is the name of the cached content prefixing requests, see WithCachedContent
+	CachedContent *string
+	// OutputChecks are sanity checks of the output, see WithOutputChecks
+	OutputChecks *OutputChecks
+	// LexiconFilter masks or halts output containing lexicon terms, see WithLexiconFilter
+	LexiconFilter *LexiconFilter
+	// FingerprintHook receives the fingerprints of the output tokens, see WithFingerprintHook
+	FingerprintHook FingerprintHook `json:"-"`
 }
 
 // WithTemperature sets the temperature for sampling
@@ -112,7 +219,8 @@ func WithReasoningEffort(effort ReasoningEffort) CompletionOption {
 	}
 }
 
-// WithStop sets the stop sequences
+// WithStop sets the stop sequences. Models created by providers also enforce them on the
+// client, see StopSequenceCompletionModel.
 func WithStop(stop []string) CompletionOption {
 	return func(o *CompletionOptions) {
 		o.Stop = stop
@@ -160,12 +268,46 @@ func WithParallelToolCalls(enabled bool) CompletionOption {
 	}
 }
 
+// WithTopLogprobs requests the log probabilities of the output tokens and of the given
+// number of most likely alternatives at each position
 func WithTopLogprobs(topLogprobs int) CompletionOption {
 	return func(o *CompletionOptions) {
 		o.TopLogprobs = &topLogprobs
 	}
 }
 
+// WithN requests n candidate completions, returned in CompletionResponse.Choices. Output
+// tokens are billed for every choice. Streams and providers without native support for
+// multiple choices return an UnsupportedCapabilityError when n is greater than 1.
+func WithN(n int) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.N = &n
+	}
+}
+
+// WithOutputLanguage instructs the model to respond in the given ISO 639-1 language (e.g. "de").
+// Pass validate to detect the response language locally and retry once with stronger
+// instructions when it does not match. Languages that cannot be detected, see
+// IsDetectableLanguage, fail with ErrUnsupportedLanguage before a request is sent.
+func WithOutputLanguage(language string, validate ...bool) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.OutputLanguage = &language
+		if len(validate) > 0 {
+			o.ValidateOutputLanguage = &validate[0]
+		}
+	}
+}
+
+// WithPrediction passes the expected output, e.g. the current version of a file being edited,
+// for speculative decoding: tokens that match the prediction are accepted without being
+// generated, which speeds up responses that mostly repeat it. Supported by OpenAI and
+// Fireworks; ignored by other providers.
+func WithPrediction(content string) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.Prediction = &content
+	}
+}
+
 // ApplyCompletionOptions applies all options to create a CompletionOptions struct
 func ApplyCompletionOptions(opts []CompletionOption) *CompletionOptions {
 	options := &CompletionOptions{}
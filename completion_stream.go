@@ -0,0 +1,168 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// CompletionStream reads a streamed completion chunk by chunk while assembling the final
+// response, so callers do not have to fold chunks by hand:
+//
+//	stream, err := llm.StreamCompletion(ctx, model, req)
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//	for stream.Next() {
+//		fmt.Print(stream.Current())
+//	}
+//	resp, err := stream.Final()
+//
+// A CompletionStream is not safe for concurrent use.
+type CompletionStream struct {
+	ctx     context.Context
+	stream  StreamCompletionResponse
+	cancel  context.CancelFunc
+	once    sync.Once
+	current StreamChunk
+	err     error
+	done    bool
+	output  strings.Builder
+	resp    CompletionResponse
+}
+
+// NewCompletionStream wraps stream, as returned by CompletionModel.StreamComplete. Reading
+// stops when ctx is done.
+func NewCompletionStream(ctx context.Context, stream StreamCompletionResponse) *CompletionStream {
+	return &CompletionStream{ctx: ctx, stream: stream}
+}
+
+// StreamCompletion streams the completion of req from model. Closing the stream cancels the
+// request.
+func StreamCompletion(ctx context.Context, model CompletionModel, req *CompletionRequest) (*CompletionStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := model.StreamComplete(ctx, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s := NewCompletionStream(ctx, stream)
+	s.cancel = cancel
+	return s, nil
+}
+
+// Next reads the next chunk, returning false once the stream has ended, failed or been
+// closed. A StreamErrorChunk is not returned as a chunk; it ends the stream and is reported
+// by Err.
+func (s *CompletionStream) Next() bool {
+	if s.done {
+		return false
+	}
+	select {
+	case chunk, ok := <-s.stream:
+		if !ok {
+			s.finish(nil)
+			return false
+		}
+		if errChunk, ok := chunk.(StreamErrorChunk); ok {
+			s.finish(errChunk)
+			return false
+		}
+		s.current = chunk
+		s.add(chunk)
+		return true
+	case <-s.ctx.Done():
+		s.finish(s.ctx.Err())
+		return false
+	}
+}
+
+// Current returns the chunk read by the last call to Next
+func (s *CompletionStream) Current() StreamChunk {
+	return s.current
+}
+
+// Err returns the error that ended the stream: a StreamErrorChunk, or the error of the
+// context. It is nil while the stream is being read and after it ended normally.
+func (s *CompletionStream) Err() error {
+	if errors.Is(s.err, errStreamClosed) {
+		return nil
+	}
+	return s.err
+}
+
+// Final reads the rest of the stream and returns the assembled response: the text of all
+// chunks, including those already read with Next, and the usage, cost, metadata, finish
+// reason, logprobs and web searches the stream reported.
+func (s *CompletionStream) Final() (*CompletionResponse, error) {
+	for s.Next() {
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	resp := s.resp
+	resp.Output = s.output.String()
+	return &resp, nil
+}
+
+// Close stops reading the stream, canceling the request when the stream was opened with
+// StreamCompletion. Unread chunks are drained so the model can finish. Final still returns
+// the chunks read before Close.
+func (s *CompletionStream) Close() error {
+	s.once.Do(func() {
+		if !s.done {
+			s.finish(errStreamClosed)
+			go func() {
+				for range s.stream {
+				}
+			}()
+		}
+	})
+	return nil
+}
+
+// Channels returns the underlying chunk channel, for code written against
+// StreamCompletionResponse. Chunks read from it are not included in Final.
+func (s *CompletionStream) Channels() StreamCompletionResponse {
+	return s.stream
+}
+
+var errStreamClosed = errors.New("stream closed")
+
+func (s *CompletionStream) finish(err error) {
+	s.done, s.current = true, nil
+	if s.err == nil {
+		s.err = err
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// add folds chunk into the response
+func (s *CompletionStream) add(chunk StreamChunk) {
+	switch c := chunk.(type) {
+	case StreamTextChunk:
+		s.output.WriteString(c.Text)
+	case *StreamTextBytesChunk:
+		s.output.Write(c.Text)
+	case StreamUsageChunk:
+		s.resp.Usage, s.resp.Cost, s.resp.CostBreakdown = c.Usage, c.Cost, c.CostBreakdown
+		if c.Metadata != nil {
+			s.resp.Metadata = c.Metadata
+		}
+	case StreamFinishChunk:
+		c.apply(&s.resp)
+	case StreamLogprobChunk:
+		s.resp.Logprobs = append(s.resp.Logprobs, c.Logprobs...)
+	case StreamWebSearchChunk:
+		if c.Search != nil {
+			s.resp.WebSearches = append(s.resp.WebSearches, c.Search)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionStream(t *testing.T) {
+	cost := 0.02
+	search := &WebSearch{Query: "go"}
+	stream := NewCompletionStream(context.Background(), textStream("Hello, world", 5,
+		StreamWebSearchChunk{Search: search},
+		StreamLogprobChunk{Logprobs: []TokenLogprob{{Token: "Hello", Logprob: -0.1}}},
+		StreamLogprobChunk{Logprobs: []TokenLogprob{{Token: ", world", Logprob: -0.2}}},
+		StreamFinishChunk{Reason: FinishReasonLength, ID: "resp_1"},
+		StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 3}, Cost: &cost, Metadata: &ResponseMetadata{}},
+	))
+
+	var texts []string
+	for stream.Next() {
+		if text, ok := stream.Current().(StreamTextChunk); ok {
+			texts = append(texts, text.Text)
+		}
+		if len(texts) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"Hello", ", wor"}, texts)
+
+	resp, err := stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "Hello, world", resp.Output)
+	assert.Equal(t, int64(3), resp.Usage.TotalOutputTokens)
+	assert.Equal(t, &cost, resp.Cost)
+	assert.NotNil(t, resp.Metadata)
+	assert.Equal(t, []*WebSearch{search}, resp.WebSearches)
+	assert.Equal(t, []TokenLogprob{{Token: "Hello", Logprob: -0.1}, {Token: ", world", Logprob: -0.2}}, resp.Logprobs)
+	assert.Equal(t, FinishReasonLength, resp.FinishReason)
+	assert.Equal(t, "resp_1", resp.ID)
+	assert.NoError(t, stream.Err())
+	assert.False(t, stream.Next())
+	assert.Nil(t, stream.Current())
+	assert.NoError(t, stream.Close())
+}
+
+func TestCompletionStreamError(t *testing.T) {
+	failure := errors.New("connection reset")
+	stream := NewCompletionStream(context.Background(), textStream("partial", 10, StreamErrorChunk{Provider: "openai", Err: failure}))
+
+	assert.True(t, stream.Next())
+	assert.False(t, stream.Next())
+	assert.ErrorIs(t, stream.Err(), failure)
+	var errChunk StreamErrorChunk
+	assert.ErrorAs(t, stream.Err(), &errChunk)
+
+	resp, err := stream.Final()
+	assert.Nil(t, resp)
+	assert.ErrorIs(t, err, failure)
+}
+
+func TestCompletionStreamContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks := make(chan StreamChunk)
+	stream := NewCompletionStream(ctx, chunks)
+	cancel()
+
+	assert.False(t, stream.Next())
+	assert.ErrorIs(t, stream.Err(), context.Canceled)
+}
+
+func TestCompletionStreamClose(t *testing.T) {
+	var streamCtx context.Context
+	chunks := make(chan StreamChunk)
+	model := &contextModel{stream: chunks, ctx: &streamCtx}
+
+	stream, err := StreamCompletion(context.Background(), model, &CompletionRequest{})
+	require.NoError(t, err)
+	go func() {
+		chunks <- StreamTextChunk{Text: "Hi"}
+		<-streamCtx.Done()
+		close(chunks)
+	}()
+
+	require.True(t, stream.Next())
+	require.NoError(t, stream.Close())
+	assert.False(t, stream.Next())
+	assert.NoError(t, stream.Err())
+	select {
+	case <-streamCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("closing the stream did not cancel the request")
+	}
+
+	resp, err := stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", resp.Output)
+}
+
+func TestStreamCompletionError(t *testing.T) {
+	invalid := NewRequestError("openai", http.StatusBadRequest, "invalid", nil)
+	stream, err := StreamCompletion(context.Background(), &flakyModel{errs: []error{invalid}}, &CompletionRequest{})
+	assert.Nil(t, stream)
+	assert.ErrorIs(t, err, invalid)
+
+	stream, err = StreamCompletion(context.Background(), &flakyModel{}, &CompletionRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Output)
+	assert.Equal(t, 1, resp.Usage.TotalRequests)
+	_, ok := <-stream.Channels()
+	assert.False(t, ok, "Final reads the channel to the end")
+}
+
+// contextModel streams chunks, recording the context of the request
+type contextModel struct {
+	stream chan StreamChunk
+	ctx    *context.Context
+}
+
+func (m *contextModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *contextModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	*m.ctx = ctx
+	return m.stream, nil
+}
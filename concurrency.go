@@ -0,0 +1,266 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// ConcurrencyOption is a functional option for configuring adaptive concurrency
+type ConcurrencyOption func(*ConcurrencyOptions)
+
+// ConcurrencyOptions contains configuration options for adaptive concurrency
+type ConcurrencyOptions struct {
+	// Initial is the starting concurrency limit
+	Initial int
+	// Min and Max bound the concurrency limit
+	Min int
+	Max int
+	// LatencyTolerance is how far latency may rise above the observed baseline, as a ratio,
+	// before the controller treats the provider as saturated
+	LatencyTolerance float64
+	// Backoff is the factor the limit is multiplied by when the provider rate limits a request
+	Backoff float64
+}
+
+// WithConcurrencyLimits sets the initial, minimum and maximum concurrency
+func WithConcurrencyLimits(initial, min, max int) ConcurrencyOption {
+	return func(o *ConcurrencyOptions) {
+		o.Initial = initial
+		o.Min = min
+		o.Max = max
+	}
+}
+
+// WithLatencyTolerance sets the latency ratio over baseline that is treated as saturation
+func WithLatencyTolerance(tolerance float64) ConcurrencyOption {
+	return func(o *ConcurrencyOptions) {
+		o.LatencyTolerance = tolerance
+	}
+}
+
+// WithRateLimitBackoff sets the factor the limit is multiplied by on rate limit errors
+func WithRateLimitBackoff(backoff float64) ConcurrencyOption {
+	return func(o *ConcurrencyOptions) {
+		o.Backoff = backoff
+	}
+}
+
+// ApplyConcurrencyOptions applies all options to create a ConcurrencyOptions struct
+func ApplyConcurrencyOptions(opts []ConcurrencyOption) *ConcurrencyOptions {
+	options := &ConcurrencyOptions{
+		Initial:          4,
+		Min:              1,
+		Max:              64,
+		LatencyTolerance: 2,
+		Backoff:          0.5,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.Min < 1 {
+		options.Min = 1
+	}
+	if options.Max < options.Min {
+		options.Max = options.Min
+	}
+	options.Initial = min(max(options.Initial, options.Min), options.Max)
+	return options
+}
+
+// AdaptiveConcurrency limits the number of concurrent provider requests and tunes the limit
+// from observed throughput. The limit grows additively while requests succeed at close to
+// baseline latency, shrinks gently when latency rises above the tolerance, and backs off
+// multiplicatively when the provider returns rate limit (429) errors.
+type AdaptiveConcurrency struct {
+	options *ConcurrencyOptions
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	baseline time.Duration
+	changed  chan struct{}
+}
+
+// NewAdaptiveConcurrency creates a new adaptive concurrency controller
+func NewAdaptiveConcurrency(opts ...ConcurrencyOption) *AdaptiveConcurrency {
+	options := ApplyConcurrencyOptions(opts)
+	return &AdaptiveConcurrency{
+		options: options,
+		limit:   float64(options.Initial),
+		changed: make(chan struct{}),
+	}
+}
+
+// Limit returns the current concurrency limit
+func (c *AdaptiveConcurrency) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit)
+}
+
+// Acquire waits until a request may start. The returned release function must be called
+// with the request's error (nil on success) when it finishes, and feeds the controller.
+func (c *AdaptiveConcurrency) Acquire(ctx context.Context) (func(err error), error) {
+	for {
+		c.mu.Lock()
+		if c.inflight < int(c.limit) {
+			c.inflight++
+			c.mu.Unlock()
+			start := time.Now()
+			var once sync.Once
+			return func(err error) {
+				once.Do(func() { c.release(time.Since(start), err) })
+			}, nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *AdaptiveConcurrency) release(latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inflight--
+	switch {
+	case IsRateLimitError(err):
+		c.limit = max(c.limit*c.options.Backoff, float64(c.options.Min))
+	case err != nil:
+		// Other failures say nothing about throughput
+	default:
+		if c.baseline == 0 || latency < c.baseline {
+			c.baseline = latency
+		} else {
+			// Let the baseline drift up slowly so it tracks changing request sizes
+			c.baseline += (latency - c.baseline) / 20
+		}
+		if float64(latency) > float64(c.baseline)*c.options.LatencyTolerance {
+			c.limit = max(c.limit*0.9, float64(c.options.Min))
+		} else {
+			c.limit = min(c.limit+1/c.limit, float64(c.options.Max))
+		}
+	}
+
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// IsRateLimitError reports whether err is a rate limit (HTTP 429) error from a provider
+func IsRateLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	var apiErr *openai.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// DefaultEmbeddingBatchSize is the default number of contents per embedding request
+const DefaultEmbeddingBatchSize = 100
+
+// EmbeddingBatchAttempts is the number of attempts made for each batch of
+// GenerateEmbeddingsConcurrently, including the first one
+const EmbeddingBatchAttempts = 5
+
+// GenerateEmbeddingsConcurrently splits the request contents into batches and embeds them
+// concurrently under the controller's limit. Embeddings are returned in input order with
+// usage and cost summed over all batches. A nil controller uses default options.
+//
+// Batches failing with retryable errors such as rate limits are retried up to
+// EmbeddingBatchAttempts times, after the Retry-After delay or DefaultRetryBackoff, while the
+// controller lowers its limit. The run fails with the first error that is not retried.
+func GenerateEmbeddingsConcurrently(ctx context.Context, model EmbeddingModel, req *EmbeddingRequest, batchSize int, controller *AdaptiveConcurrency) (*EmbeddingResponse, error) {
+	if req == nil {
+		return nil, NewValidationError("request", "cannot be nil", nil)
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultEmbeddingBatchSize
+	}
+	if controller == nil {
+		controller = NewAdaptiveConcurrency()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var batches [][]string
+	for start := 0; start < len(req.Contents); start += batchSize {
+		batches = append(batches, req.Contents[start:min(start+batchSize, len(req.Contents))])
+	}
+	responses := make([]*EmbeddingResponse, len(batches))
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for i, contents := range batches {
+		if ctx.Err() != nil {
+			break
+		}
+		release, err := controller.Acquire(ctx)
+		if err != nil {
+			errOnce.Do(func() { firstErr = err })
+			break
+		}
+		wg.Add(1)
+		go func(i int, contents []string) {
+			defer wg.Done()
+			batch := &EmbeddingRequest{Model: req.Model, Contents: contents, Config: req.Config}
+			resp, attempts, err := retry(ctx, &RetryOptions{MaxAttempts: EmbeddingBatchAttempts}, func() (*EmbeddingResponse, error) {
+				// The first attempt runs in the slot acquired by the loop
+				if release == nil {
+					var err error
+					if release, err = controller.Acquire(ctx); err != nil {
+						return nil, err
+					}
+				}
+				resp, err := model.GenerateEmbeddings(ctx, batch)
+				release(err)
+				release = nil
+				return resp, err
+			})
+			if err != nil {
+				errOnce.Do(func() { firstErr = err })
+				cancel()
+				return
+			}
+			countRetries(resp.Usage, attempts)
+			responses[i] = resp
+		}(i, contents)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &EmbeddingResponse{Embeddings: make([]Embedding, 0, len(req.Contents))}
+	var total usageTotal
+	for i, resp := range responses {
+		for _, embedding := range resp.Embeddings {
+			embedding.Index += i * batchSize
+			result.Embeddings = append(result.Embeddings, embedding)
+		}
+		total.add(resp.Usage, resp.Cost, resp.CostBreakdown)
+	}
+	result.Usage, result.Cost, result.CostBreakdown = total.usage, total.cost, total.breakdown
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveConcurrency_Tuning(t *testing.T) {
+	// A huge tolerance keeps scheduling noise from being read as saturation
+	c := NewAdaptiveConcurrency(WithConcurrencyLimits(4, 1, 8), WithLatencyTolerance(1e9))
+	require.Equal(t, 4, c.Limit())
+
+	// Fast successes grow the limit additively
+	for i := 0; i < 20; i++ {
+		release, err := c.Acquire(context.Background())
+		require.NoError(t, err)
+		release(nil)
+	}
+	grown := c.Limit()
+	assert.Greater(t, grown, 4)
+	assert.LessOrEqual(t, grown, 8)
+
+	// Rate limit errors halve it
+	release, err := c.Acquire(context.Background())
+	require.NoError(t, err)
+	release(NewRequestError("openai", http.StatusTooManyRequests, "rate limited", nil))
+	assert.Equal(t, grown/2, c.Limit())
+
+	// Other errors leave it unchanged
+	release, err = c.Acquire(context.Background())
+	require.NoError(t, err)
+	release(fmt.Errorf("bad request"))
+	assert.Equal(t, grown/2, c.Limit())
+}
+
+func TestAdaptiveConcurrency_Blocks(t *testing.T) {
+	c := NewAdaptiveConcurrency(WithConcurrencyLimits(1, 1, 1))
+
+	release, err := c.Acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = c.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	acquired := make(chan struct{})
+	go func() {
+		release, err := c.Acquire(context.Background())
+		if err == nil {
+			release(nil)
+		}
+		close(acquired)
+	}()
+	release(nil)
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("waiting request was not released")
+	}
+}
+
+type batchEmbeddingModel struct {
+	calls int32
+	// rateLimited is the number of calls failing with a rate limit error first
+	rateLimited int32
+}
+
+func (m *batchEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if atomic.AddInt32(&m.calls, 1) <= m.rateLimited {
+		return nil, &RequestError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Millisecond}
+	}
+	resp := &EmbeddingResponse{Usage: &TokenUsage{TotalInputTokens: int64(len(req.Contents)), TotalRequests: 1}}
+	for i, content := range req.Contents {
+		resp.Embeddings = append(resp.Embeddings, Embedding{Index: i, Embedding: []float64{float64(len(content))}})
+	}
+	return resp, nil
+}
+
+func TestGenerateEmbeddingsConcurrently(t *testing.T) {
+	model := &batchEmbeddingModel{}
+	contents := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+
+	resp, err := GenerateEmbeddingsConcurrently(context.Background(), model, &EmbeddingRequest{Contents: contents}, 2, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&model.calls))
+	require.Len(t, resp.Embeddings, 5)
+	for i, embedding := range resp.Embeddings {
+		assert.Equal(t, i, embedding.Index)
+		assert.Equal(t, float64(len(contents[i])), embedding.Embedding[0])
+	}
+	assert.Equal(t, int64(5), resp.Usage.TotalInputTokens)
+	assert.Equal(t, 3, resp.Usage.TotalRequests)
+}
+
+func TestGenerateEmbeddingsConcurrently_RateLimited(t *testing.T) {
+	model := &batchEmbeddingModel{rateLimited: 2}
+	contents := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	controller := NewAdaptiveConcurrency(WithConcurrencyLimits(4, 1, 4))
+
+	resp, err := GenerateEmbeddingsConcurrently(context.Background(), model, &EmbeddingRequest{Contents: contents}, 2, controller)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(5), atomic.LoadInt32(&model.calls))
+	require.Len(t, resp.Embeddings, 5)
+	for i, embedding := range resp.Embeddings {
+		assert.Equal(t, i, embedding.Index)
+	}
+	assert.Equal(t, 5, resp.Usage.TotalRequests)
+	assert.Less(t, controller.Limit(), 4)
+}
+
+func TestGenerateEmbeddingsConcurrently_Error(t *testing.T) {
+	model := &batchEmbeddingModel{rateLimited: 100}
+
+	_, err := GenerateEmbeddingsConcurrently(context.Background(), model, &EmbeddingRequest{Contents: []string{"a"}}, 2, nil)
+	require.Error(t, err)
+	assert.True(t, IsRateLimitError(err))
+	assert.Equal(t, int32(EmbeddingBatchAttempts), atomic.LoadInt32(&model.calls))
+}
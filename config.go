@@ -0,0 +1,329 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderConfig configures a provider and its default model from a file, so services can
+// change providers, models and limits without code changes. See LoadProviderConfig.
+type ProviderConfig struct {
+	// Provider is the name the provider is registered under, e.g. "openai"
+	Provider string `json:"provider" yaml:"provider"`
+	// APIKeyEnv is the environment variable holding the API key. Without it, and without
+	// APIKey, the API key is read from the environment variables of the provider registration.
+	APIKeyEnv string `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`
+	// APIKey is the API key itself, for local development; prefer APIKeyEnv
+	APIKey     string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	BaseURL    string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	APIVersion string `json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	Project    string `json:"project,omitempty" yaml:"project,omitempty"`
+	Location   string `json:"location,omitempty" yaml:"location,omitempty"`
+	// DefaultModel is the model created by ConfiguredProvider.NewDefaultCompletionModel
+	DefaultModel string `json:"default_model,omitempty" yaml:"default_model,omitempty"`
+	// Options are the default options of the completion models of the provider
+	Options   *CompletionConfig `json:"options,omitempty" yaml:"options,omitempty"`
+	Retry     *RetryConfig      `json:"retry,omitempty" yaml:"retry,omitempty"`
+	RateLimit *RateLimitConfig  `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty"`
+}
+
+// CompletionConfig holds the completion options that can be set in a ProviderConfig
+type CompletionConfig struct {
+	Temperature      *float64         `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	TopP             *float64         `json:"top_p,omitempty" yaml:"top_p,omitempty"`
+	MaxTokens        *int             `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+	PresencePenalty  *float64         `json:"presence_penalty,omitempty" yaml:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64         `json:"frequency_penalty,omitempty" yaml:"frequency_penalty,omitempty"`
+	Seed             *int64           `json:"seed,omitempty" yaml:"seed,omitempty"`
+	ReasoningEffort  *ReasoningEffort `json:"reasoning_effort,omitempty" yaml:"reasoning_effort,omitempty"`
+	Stop             []string         `json:"stop,omitempty" yaml:"stop,omitempty"`
+}
+
+// RetryConfig configures the retries of a ProviderConfig, see WithRetry. The backoff delays
+// are durations such as "500ms" or "30s", defaulting to those of DefaultRetryBackoff.
+type RetryConfig struct {
+	MaxAttempts    int    `json:"max_attempts" yaml:"max_attempts"`
+	InitialBackoff string `json:"initial_backoff,omitempty" yaml:"initial_backoff,omitempty"`
+	MaxBackoff     string `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+}
+
+// RateLimitConfig configures the rate limits of a ProviderConfig, see WithRateLimit and
+// WithRateLimitBurst
+type RateLimitConfig struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty" yaml:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `json:"tokens_per_minute,omitempty" yaml:"tokens_per_minute,omitempty"`
+	RequestBurst      int `json:"request_burst,omitempty" yaml:"request_burst,omitempty"`
+	TokenBurst        int `json:"token_burst,omitempty" yaml:"token_burst,omitempty"`
+}
+
+// LoadProviderConfig reads a provider config from a YAML or JSON file, see
+// ParseProviderConfig
+func LoadProviderConfig(path string) (*ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider config: %w", err)
+	}
+	config, err := ParseProviderConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseProviderConfig parses a provider config in YAML or JSON, which is a subset of YAML.
+// Unknown keys are rejected, so misspelled settings are not silently ignored. The config is
+// validated when the provider is created.
+func ParseProviderConfig(data []byte) (*ProviderConfig, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var config ProviderConfig
+	if err := decoder.Decode(&config); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("failed to parse provider config: empty config")
+		}
+		return nil, fmt.Errorf("failed to parse provider config: %w", err)
+	}
+	return &config, nil
+}
+
+// Validate checks the config, returning a ValidationError for each invalid setting, joined
+// with errors.Join. Fields are named by their keys in the config file, e.g.
+// "options.temperature".
+func (c *ProviderConfig) Validate() error {
+	var errs []error
+	invalid := func(field, message string, value any) {
+		errs = append(errs, NewValidationError(field, message, value))
+	}
+
+	if c.Provider == "" {
+		invalid("provider", "is required", nil)
+	}
+	if c.APIKey != "" && c.APIKeyEnv != "" {
+		invalid("api_key", "cannot be set together with api_key_env", nil)
+	}
+	if c.BaseURL != "" {
+		if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("base_url", "must be an absolute http or https URL", c.BaseURL)
+		}
+	}
+
+	if o := c.Options; o != nil {
+		if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+			invalid("options.temperature", "must be between 0 and 2", *o.Temperature)
+		}
+		if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+			invalid("options.top_p", "must be between 0 and 1", *o.TopP)
+		}
+		if o.MaxTokens != nil && *o.MaxTokens <= 0 {
+			invalid("options.max_tokens", "must be positive", *o.MaxTokens)
+		}
+		if o.PresencePenalty != nil && (*o.PresencePenalty < -2 || *o.PresencePenalty > 2) {
+			invalid("options.presence_penalty", "must be between -2 and 2", *o.PresencePenalty)
+		}
+		if o.FrequencyPenalty != nil && (*o.FrequencyPenalty < -2 || *o.FrequencyPenalty > 2) {
+			invalid("options.frequency_penalty", "must be between -2 and 2", *o.FrequencyPenalty)
+		}
+		if o.ReasoningEffort != nil {
+			switch *o.ReasoningEffort {
+			case ReasoningEffortLow, ReasoningEffortMedium, ReasoningEffortHigh:
+			default:
+				invalid("options.reasoning_effort", "must be low, medium or high", *o.ReasoningEffort)
+			}
+		}
+	}
+
+	if r := c.Retry; r != nil {
+		if r.MaxAttempts < 1 {
+			invalid("retry.max_attempts", "must be at least 1", r.MaxAttempts)
+		}
+		initial, initialErr := parseBackoff(r.InitialBackoff)
+		if initialErr != nil {
+			invalid("retry.initial_backoff", initialErr.Error(), r.InitialBackoff)
+		}
+		maxDelay, maxErr := parseBackoff(r.MaxBackoff)
+		if maxErr != nil {
+			invalid("retry.max_backoff", maxErr.Error(), r.MaxBackoff)
+		}
+		if initialErr == nil && maxErr == nil && initial > 0 && maxDelay > 0 && initial > maxDelay {
+			invalid("retry.initial_backoff", "must not exceed max_backoff", r.InitialBackoff)
+		}
+	}
+
+	if l := c.RateLimit; l != nil {
+		for _, limit := range []struct {
+			field string
+			value int
+		}{
+			{"rate_limit.requests_per_minute", l.RequestsPerMinute},
+			{"rate_limit.tokens_per_minute", l.TokensPerMinute},
+			{"rate_limit.request_burst", l.RequestBurst},
+			{"rate_limit.token_burst", l.TokenBurst},
+		} {
+			if limit.value < 0 {
+				invalid(limit.field, "must not be negative", limit.value)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseBackoff parses a backoff delay of a RetryConfig, 0 when empty
+func parseBackoff(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	delay, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New(`must be a duration such as "500ms" or "30s"`)
+	}
+	if delay <= 0 {
+		return 0, errors.New("must be positive")
+	}
+	return delay, nil
+}
+
+// ConfiguredProvider is a provider created from a ProviderConfig. Its completion models are
+// created with the default options of the config, followed by the options of the caller.
+type ConfiguredProvider struct {
+	ModelProvider
+	// DefaultModel is the default model of the config, if any
+	DefaultModel string
+	options      []CompletionOption
+}
+
+// NewCompletionModel creates model with the default options of the config followed by opts
+func (p *ConfiguredProvider) NewCompletionModel(model string, opts ...CompletionOption) (CompletionModel, error) {
+	return p.ModelProvider.NewCompletionModel(model, append(slices.Clone(p.options), opts...)...)
+}
+
+// NewDefaultCompletionModel creates the default model of the config, see NewCompletionModel
+func (p *ConfiguredProvider) NewDefaultCompletionModel(opts ...CompletionOption) (CompletionModel, error) {
+	if p.DefaultModel == "" {
+		return nil, NewValidationError("default_model", "is not set in the provider config", nil)
+	}
+	return p.NewCompletionModel(p.DefaultModel, opts...)
+}
+
+// NewProviderFromConfig validates config and creates its provider, registered in r, with the
+// settings of config followed by opts, e.g. a logger or a cost tracker. The default model of
+// config must be a completion model of the provider's catalog. Unlike Provider, it creates a
+// new provider on each call and ignores the options set with Configure, and it does not hold
+// the lock of r while the provider is created.
+func (r *Registry) NewProviderFromConfig(config *ProviderConfig, opts ...ModelOption) (*ConfiguredProvider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid provider config: %w", err)
+	}
+	if !slices.Contains(r.Providers(), config.Provider) {
+		return nil, fmt.Errorf("%w: %s (registered: %s)", ErrUnknownProvider, config.Provider, strings.Join(r.Providers(), ", "))
+	}
+
+	var settings []ModelOption
+	if config.APIKeyEnv != "" {
+		apiKey := r.getenv(config.APIKeyEnv)
+		if apiKey == "" {
+			return nil, fmt.Errorf("invalid provider config: %w",
+				NewValidationError("api_key_env", "environment variable is not set", config.APIKeyEnv))
+		}
+		settings = append(settings, WithAPIKey(apiKey))
+	}
+	if config.APIKey != "" {
+		settings = append(settings, WithAPIKey(config.APIKey))
+	}
+	if config.BaseURL != "" {
+		settings = append(settings, WithBaseURL(config.BaseURL))
+	}
+	if config.APIVersion != "" {
+		settings = append(settings, WithAPIVersion(config.APIVersion))
+	}
+	if config.Project != "" {
+		settings = append(settings, WithProject(config.Project))
+	}
+	if config.Location != "" {
+		settings = append(settings, WithLocation(config.Location))
+	}
+	if retry := config.Retry; retry != nil {
+		var backoff Backoff
+		if retry.InitialBackoff != "" || retry.MaxBackoff != "" {
+			initial, _ := parseBackoff(retry.InitialBackoff)
+			maxDelay, _ := parseBackoff(retry.MaxBackoff)
+			backoff = ExponentialBackoff(cmp.Or(initial, 500*time.Millisecond), cmp.Or(maxDelay, 30*time.Second))
+		}
+		settings = append(settings, WithRetry(retry.MaxAttempts, backoff))
+	}
+	if limit := config.RateLimit; limit != nil {
+		settings = append(settings,
+			WithRateLimit(limit.RequestsPerMinute, limit.TokensPerMinute),
+			WithRateLimitBurst(limit.RequestBurst, limit.TokenBurst))
+	}
+
+	provider, err := r.newProvider(config.Provider, append(settings, opts...))
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultModel != "" {
+		info := findModelInfo(provider, config.DefaultModel)
+		if info == nil || !info.HasCapability(ModelCapabilityCompletion) {
+			return nil, fmt.Errorf("invalid provider config: %w", NewValidationError("default_model",
+				"is not a completion model of provider "+config.Provider, config.DefaultModel))
+		}
+	}
+	return &ConfiguredProvider{
+		ModelProvider: provider,
+		DefaultModel:  config.DefaultModel,
+		options:       config.Options.completionOptions(),
+	}, nil
+}
+
+// completionOptions returns the options set in c
+func (c *CompletionConfig) completionOptions() []CompletionOption {
+	if c == nil {
+		return nil
+	}
+	var opts []CompletionOption
+	if c.Temperature != nil {
+		opts = append(opts, WithTemperature(*c.Temperature))
+	}
+	if c.TopP != nil {
+		opts = append(opts, WithTopP(*c.TopP))
+	}
+	if c.MaxTokens != nil {
+		opts = append(opts, WithMaxTokens(*c.MaxTokens))
+	}
+	if c.PresencePenalty != nil {
+		opts = append(opts, WithPresencePenalty(*c.PresencePenalty))
+	}
+	if c.FrequencyPenalty != nil {
+		opts = append(opts, WithFrequencyPenalty(*c.FrequencyPenalty))
+	}
+	if c.Seed != nil {
+		opts = append(opts, WithSeed(*c.Seed))
+	}
+	if c.ReasoningEffort != nil {
+		opts = append(opts, WithReasoningEffort(*c.ReasoningEffort))
+	}
+	if len(c.Stop) > 0 {
+		opts = append(opts, WithStop(c.Stop))
+	}
+	return opts
+}
+
+// NewProviderFromConfig creates the provider of config from DefaultRegistry, see
+// Registry.NewProviderFromConfig. The built-in providers are registered by importing the
+// providers package.
+func NewProviderFromConfig(config *ProviderConfig, opts ...ModelOption) (*ConfiguredProvider, error) {
+	return DefaultRegistry.NewProviderFromConfig(config, opts...)
+}
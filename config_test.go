@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProviderConfig(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "provider.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+provider: test
+api_key_env: MY_TEST_KEY
+base_url: https://gateway.example.com/v1
+default_model: vendor/model-a
+options:
+  temperature: 0.2
+  max_tokens: 512
+  reasoning_effort: low
+retry:
+  max_attempts: 3
+  initial_backoff: 200ms
+  max_backoff: 5s
+rate_limit:
+  requests_per_minute: 60
+`), 0o644))
+	jsonPath := filepath.Join(dir, "provider.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{
+		"provider": "test",
+		"api_key_env": "MY_TEST_KEY",
+		"base_url": "https://gateway.example.com/v1",
+		"default_model": "vendor/model-a",
+		"options": {"temperature": 0.2, "max_tokens": 512, "reasoning_effort": "low"},
+		"retry": {"max_attempts": 3, "initial_backoff": "200ms", "max_backoff": "5s"},
+		"rate_limit": {"requests_per_minute": 60}
+	}`), 0o644))
+
+	fromYAML, err := LoadProviderConfig(yamlPath)
+	require.NoError(t, err)
+	fromJSON, err := LoadProviderConfig(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, fromYAML, fromJSON)
+	assert.Equal(t, 0.2, *fromYAML.Options.Temperature)
+	assert.Equal(t, ReasoningEffortLow, *fromYAML.Options.ReasoningEffort)
+	assert.Equal(t, &RetryConfig{MaxAttempts: 3, InitialBackoff: "200ms", MaxBackoff: "5s"}, fromYAML.Retry)
+	require.NoError(t, fromYAML.Validate())
+
+	// Misspelled keys are rejected
+	_, err = ParseProviderConfig([]byte("provider: test\nbase_ulr: https://example.com\n"))
+	assert.ErrorContains(t, err, "base_ulr")
+
+	_, err = ParseProviderConfig(nil)
+	assert.ErrorContains(t, err, "empty config")
+
+	_, err = LoadProviderConfig(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestProviderConfig_Validate(t *testing.T) {
+	temperature, effort := 3.0, ReasoningEffort("extreme")
+	config := &ProviderConfig{
+		APIKey:    "key",
+		APIKeyEnv: "MY_TEST_KEY",
+		BaseURL:   "gateway.example.com",
+		Options:   &CompletionConfig{Temperature: &temperature, ReasoningEffort: &effort},
+		Retry:     &RetryConfig{MaxAttempts: 0, InitialBackoff: "10s", MaxBackoff: "soon"},
+		RateLimit: &RateLimitConfig{TokensPerMinute: -1},
+	}
+	err := config.Validate()
+	require.Error(t, err)
+
+	var fields []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		fields = append(fields, err.(*ValidationError).Field)
+	}
+	assert.Equal(t, []string{
+		"provider", "api_key", "base_url", "options.temperature", "options.reasoning_effort",
+		"retry.max_attempts", "retry.max_backoff", "rate_limit.tokens_per_minute",
+	}, fields)
+	assert.ErrorContains(t, err, `validation failed for field 'retry.max_backoff': must be a duration such as "500ms" or "30s" (value: soon)`)
+
+	config = &ProviderConfig{Provider: "test", Retry: &RetryConfig{MaxAttempts: 2, InitialBackoff: "10s", MaxBackoff: "1s"}}
+	assert.ErrorContains(t, config.Validate(), "must not exceed max_backoff")
+}
+
+func TestRegistry_NewProviderFromConfig(t *testing.T) {
+	registry, created := newRegistryTestRegistry(map[string]string{
+		"TEST_API_KEY": "registration-key",
+		"MY_TEST_KEY":  "config-key",
+	})
+	temperature := 0.2
+	config := &ProviderConfig{
+		Provider:     "test",
+		APIKeyEnv:    "MY_TEST_KEY",
+		BaseURL:      "https://gateway.example.com/v1",
+		DefaultModel: "vendor/model-a",
+		Options:      &CompletionConfig{Temperature: &temperature},
+		Retry:        &RetryConfig{MaxAttempts: 3},
+		RateLimit:    &RateLimitConfig{RequestsPerMinute: 60},
+	}
+
+	provider, err := registry.NewProviderFromConfig(config, WithRegion("eu"))
+	require.NoError(t, err)
+	options := provider.ModelProvider.(*registryTestProvider).options
+	assert.Equal(t, "config-key", options.APIKey)
+	assert.Equal(t, "https://gateway.example.com/v1", options.BaseURL)
+	assert.Equal(t, 3, options.Retry.MaxAttempts)
+	assert.Equal(t, 60, options.RateLimit.RequestsPerMinute)
+	assert.Equal(t, "eu", options.Region)
+
+	model, err := provider.NewDefaultCompletionModel()
+	require.NoError(t, err)
+	assert.Equal(t, "vendor/model-a", model.(*stubCompletionModel).output)
+
+	// Each call creates a provider, using the API key of the registration without api_key_env
+	config.APIKeyEnv = ""
+	provider, err = registry.NewProviderFromConfig(config)
+	require.NoError(t, err)
+	assert.Equal(t, 2, *created)
+	assert.Equal(t, "registration-key", provider.ModelProvider.(*registryTestProvider).options.APIKey)
+
+	config.APIKeyEnv = "UNSET_KEY"
+	_, err = registry.NewProviderFromConfig(config)
+	assert.ErrorContains(t, err, "api_key_env")
+
+	_, err = registry.NewProviderFromConfig(&ProviderConfig{Provider: "unknown"})
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+	assert.ErrorContains(t, err, "registered: test")
+
+	provider, err = registry.NewProviderFromConfig(&ProviderConfig{Provider: "test", APIKey: "key"})
+	require.NoError(t, err)
+	_, err = provider.NewDefaultCompletionModel()
+	assert.ErrorContains(t, err, "default_model")
+
+	// The default model must be a completion model of the catalog
+	for _, model := range []string{"vendor/unknown", "vendor/embedder"} {
+		_, err = registry.NewProviderFromConfig(&ProviderConfig{Provider: "test", APIKey: "key", DefaultModel: model})
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "default_model", validationErr.Field)
+	}
+}
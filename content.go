@@ -0,0 +1,91 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"strings"
+)
+
+// ContentPartType identifies the kind of a message content part
+type ContentPartType string
+
+const (
+	ContentPartText       ContentPartType = "text"
+	ContentPartImage      ContentPartType = "image"
+	ContentPartAudio      ContentPartType = "audio"
+	ContentPartFile       ContentPartType = "file"
+	ContentPartToolResult ContentPartType = "tool_result"
+)
+
+// ContentPart is one ordered part of a message's content
+type ContentPart struct {
+	Type     ContentPartType `json:"type"`
+	Text     string          `json:"text,omitempty"`     // Text of text parts
+	Artifact *ModelArtifact  `json:"artifact,omitempty"` // Media of image, audio and file parts
+	ToolCall *ToolCall       `json:"toolCall,omitempty"` // Completed call of tool result parts
+}
+
+// NewTextPart creates a text content part
+func NewTextPart(text string) *ContentPart {
+	return &ContentPart{Type: ContentPartText, Text: text}
+}
+
+// NewArtifactPart creates an image, audio or file content part depending on the artifact's content type
+func NewArtifactPart(artifact *ModelArtifact) *ContentPart {
+	partType := ContentPartFile
+	if artifact != nil {
+		switch {
+		case strings.HasPrefix(artifact.ContentType, "image/"):
+			partType = ContentPartImage
+		case strings.HasPrefix(artifact.ContentType, "audio/"):
+			partType = ContentPartAudio
+		}
+	}
+	return &ContentPart{Type: partType, Artifact: artifact}
+}
+
+// NewToolResultPart creates a tool result content part for a completed tool call
+func NewToolResultPart(call *ToolCall) *ContentPart {
+	return &ContentPart{Type: ContentPartToolResult, ToolCall: call}
+}
+
+// NewMessage creates a message from ordered content parts
+func NewMessage(role Role, parts ...*ContentPart) *ModelMessage {
+	return &ModelMessage{Role: role, Parts: parts}
+}
+
+// ContentParts returns the ordered content of the message. Messages built with the flat
+// Content, Artifacts and ToolCall fields are converted to parts in that order: the text,
+// the tool result for tool messages, then the artifacts.
+func (m *ModelMessage) ContentParts() []*ContentPart {
+	if len(m.Parts) > 0 {
+		return m.Parts
+	}
+
+	parts := make([]*ContentPart, 0, len(m.Artifacts)+2)
+	if m.Content != "" {
+		parts = append(parts, NewTextPart(m.Content))
+	}
+	if m.Role == RoleTool && m.ToolCall != nil {
+		parts = append(parts, NewToolResultPart(m.ToolCall))
+	}
+	for _, artifact := range m.Artifacts {
+		parts = append(parts, NewArtifactPart(artifact))
+	}
+	return parts
+}
+
+// Text returns the concatenated text parts of the message, or Content for flat messages
+func (m *ModelMessage) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	var sb strings.Builder
+	for _, part := range m.Parts {
+		if part != nil && part.Type == ContentPartText {
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelMessage_ContentParts(t *testing.T) {
+	image := &ModelArtifact{Name: "a.png", ContentType: "image/png", Content: []byte{1}}
+	audio := &ModelArtifact{Name: "a.wav", ContentType: "audio/wav", Content: []byte{2}}
+	pdf := &ModelArtifact{Name: "a.pdf", ContentType: "application/pdf", Content: []byte{3}}
+	call := &ToolCall{Name: "search", Output: "ok"}
+
+	tests := []struct {
+		name      string
+		msg       *ModelMessage
+		wantTypes []ContentPartType
+		wantText  string
+	}{
+		{
+			name:      "flat_content_and_artifacts",
+			msg:       &ModelMessage{Role: RoleUser, Content: "look", Artifacts: []*ModelArtifact{image, audio, pdf}},
+			wantTypes: []ContentPartType{ContentPartText, ContentPartImage, ContentPartAudio, ContentPartFile},
+			wantText:  "look",
+		},
+		{
+			name:      "flat_tool_result",
+			msg:       &ModelMessage{Role: RoleTool, ToolCall: call, Artifacts: []*ModelArtifact{image}},
+			wantTypes: []ContentPartType{ContentPartToolResult, ContentPartImage},
+		},
+		{
+			name:      "flat_assistant_tool_call",
+			msg:       &ModelMessage{Role: RoleAssistant, ToolCall: call},
+			wantTypes: []ContentPartType{},
+		},
+		{
+			name: "ordered_parts",
+			msg: NewMessage(RoleUser,
+				NewTextPart("compare "),
+				NewArtifactPart(image),
+				NewTextPart("with"),
+				NewArtifactPart(pdf),
+			),
+			wantTypes: []ContentPartType{ContentPartText, ContentPartImage, ContentPartText, ContentPartFile},
+			wantText:  "compare with",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			types := []ContentPartType{}
+			for _, part := range tt.msg.ContentParts() {
+				types = append(types, part.Type)
+			}
+			assert.Equal(t, tt.wantTypes, types)
+			assert.Equal(t, tt.wantText, tt.msg.Text())
+		})
+	}
+}
+
+func TestNewArtifactPart(t *testing.T) {
+	part := NewArtifactPart(&ModelArtifact{ContentType: "audio/mpeg"})
+	require.NotNil(t, part.Artifact)
+	assert.Equal(t, ContentPartAudio, part.Type)
+	assert.Equal(t, ContentPartFile, NewArtifactPart(nil).Type)
+}
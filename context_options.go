@@ -0,0 +1,98 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+)
+
+type optionsKey struct{}
+
+// ContextWithOptions returns a context carrying completion option overrides. Models apply
+// them after the options they were created with, so frameworks that only pass a context
+// through their layers, e.g. HTTP middleware, can change model behavior such as capping
+// output tokens in development. Options added by nested calls are applied last.
+func ContextWithOptions(ctx context.Context, opts ...CompletionOption) context.Context {
+	if len(opts) == 0 {
+		return ctx
+	}
+	parent := OptionsFromContext(ctx)
+	merged := make([]CompletionOption, 0, len(parent)+len(opts))
+	merged = append(append(merged, parent...), opts...)
+	return context.WithValue(ctx, optionsKey{}, merged)
+}
+
+// OptionsFromContext returns the completion option overrides of ctx
+func OptionsFromContext(ctx context.Context) []CompletionOption {
+	opts, _ := ctx.Value(optionsKey{}).([]CompletionOption)
+	return opts
+}
+
+// ApplyContextCompletionOptions applies the options of a model followed by the overrides of ctx
+func ApplyContextCompletionOptions(ctx context.Context, opts []CompletionOption) *CompletionOptions {
+	options := ApplyCompletionOptions(opts)
+	for _, opt := range OptionsFromContext(ctx) {
+		opt(options)
+	}
+	return options
+}
+
+// ApplyContextResponseOptions applies the options of a conversation model followed by the
+// completion option overrides of ctx
+func ApplyContextResponseOptions(ctx context.Context, opts []ResponseOption) *ResponseOptions {
+	options := ApplyResponseOptions(opts)
+	for _, opt := range OptionsFromContext(ctx) {
+		opt(options.CompletionOptions)
+	}
+	return options
+}
+
+type modelsKey struct{}
+
+// ModelOverrides replace the models that requests made with a context are sent to, e.g. to
+// force cheap models in development. Completion models are bound to their model when they
+// are created, so completions are sent to the Completion model instead. Requests of the other
+// kinds name their model, so it is replaced by ID on the model of the same provider. Empty
+// fields keep the model of the request.
+type ModelOverrides struct {
+	// Completion receives the completion requests of every provider instead of their model
+	Completion CompletionModel
+	// Embedding is the ID of the model of embedding requests
+	Embedding string
+	// Image is the ID of the model of image generation, edit, variation and job requests
+	Image string
+	// Speech is the ID of the model of speech requests
+	Speech string
+	// Video is the ID of the model of video requests
+	Video string
+	// Rerank is the ID of the model of rerank requests
+	Rerank string
+}
+
+// ContextWithModels returns a context carrying model overrides. Fields set by nested calls
+// replace those of the parent context. Overridden models are still checked against the
+// policy of their provider.
+func ContextWithModels(ctx context.Context, overrides ModelOverrides) context.Context {
+	merged := ModelsFromContext(ctx)
+	if overrides.Completion != nil {
+		merged.Completion = overrides.Completion
+	}
+	set := func(id *string, override string) {
+		if override != "" {
+			*id = override
+		}
+	}
+	set(&merged.Embedding, overrides.Embedding)
+	set(&merged.Image, overrides.Image)
+	set(&merged.Speech, overrides.Speech)
+	set(&merged.Video, overrides.Video)
+	set(&merged.Rerank, overrides.Rerank)
+	return context.WithValue(ctx, modelsKey{}, merged)
+}
+
+// ModelsFromContext returns the model overrides of ctx
+func ModelsFromContext(ctx context.Context) ModelOverrides {
+	overrides, _ := ctx.Value(modelsKey{}).(ModelOverrides)
+	return overrides
+}
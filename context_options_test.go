@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextWithOptions(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, OptionsFromContext(ctx))
+	assert.Equal(t, ctx, ContextWithOptions(ctx))
+
+	outer := ContextWithOptions(ctx, WithTemperature(0.2), WithMaxTokens(100))
+	inner := ContextWithOptions(outer, WithMaxTokens(50))
+	assert.Len(t, OptionsFromContext(outer), 2)
+
+	// Overrides are applied after the model's options, nested ones last
+	opts := ApplyContextCompletionOptions(inner, []CompletionOption{WithTemperature(0.9), WithUsage(true)})
+	require.NotNil(t, opts.Temperature)
+	assert.Equal(t, 0.2, *opts.Temperature)
+	assert.Equal(t, 50, *opts.MaxTokens)
+	assert.True(t, *opts.WithUsage)
+
+	opts = ApplyContextCompletionOptions(outer, nil)
+	assert.Equal(t, 100, *opts.MaxTokens)
+}
+
+func TestApplyContextResponseOptions(t *testing.T) {
+	ctx := ContextWithOptions(context.Background(), WithReasoningEffort(ReasoningEffortLow))
+
+	opts := ApplyContextResponseOptions(ctx, []ResponseOption{WithStore(true), WithOptions(WithReasoningEffort(ReasoningEffortHigh))})
+	assert.True(t, *opts.Store)
+	assert.Equal(t, ReasoningEffortLow, *opts.CompletionOptions.ReasoningEffort)
+
+	opts = ApplyContextResponseOptions(ctx, nil)
+	assert.Equal(t, ReasoningEffortLow, *opts.CompletionOptions.ReasoningEffort)
+}
+
+// modelRecordingEmbeddingModel records the model of the requests it receives
+type modelRecordingEmbeddingModel struct {
+	models []string
+}
+
+func (m *modelRecordingEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	m.models = append(m.models, req.Model)
+	return &EmbeddingResponse{}, nil
+}
+
+func TestContextWithModels(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, ModelOverrides{}, ModelsFromContext(ctx))
+
+	cheap := &stubCompletionModel{output: "cheap"}
+	outer := ContextWithModels(ctx, ModelOverrides{Completion: cheap, Embedding: "small", Rerank: "rerank-lite"})
+	inner := ContextWithModels(outer, ModelOverrides{Embedding: "tiny"})
+	assert.Equal(t, ModelOverrides{Completion: cheap, Embedding: "tiny", Rerank: "rerank-lite"}, ModelsFromContext(inner))
+	assert.Equal(t, "small", ModelsFromContext(outer).Embedding)
+}
+
+func TestDefaultModelProvider_ModelOverrides(t *testing.T) {
+	info := &ModelInfo{ID: "gpt-4o"}
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{info, {ID: "gpt-4o-mini"}})
+	model := provider.DecorateCompletionModel(&stubCompletionModel{output: "expensive"}, info, nil)
+	cheap := provider.DecorateCompletionModel(&stubCompletionModel{output: "cheap"}, provider.GetModelInfo("gpt-4o-mini"), nil)
+
+	ctx := ContextWithModels(context.Background(), ModelOverrides{Completion: cheap})
+	resp, err := model.Complete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "cheap", resp.Output)
+	stream, err := model.StreamComplete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	var text string
+	for chunk := range stream {
+		if c, ok := chunk.(StreamTextChunk); ok {
+			text += c.Text
+		}
+	}
+	assert.Equal(t, "cheap", text)
+	resp, err = model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "expensive", resp.Output)
+
+	// Models of the other kinds are replaced by ID, without modifying the request
+	inner := &modelRecordingEmbeddingModel{}
+	embedding := provider.WrapEmbeddingModel(inner)
+	req := &EmbeddingRequest{Model: "text-embedding-3-large", Contents: []string{"a"}}
+	_, err = embedding.GenerateEmbeddings(ContextWithModels(context.Background(), ModelOverrides{Embedding: "text-embedding-3-small"}), req)
+	require.NoError(t, err)
+	_, err = embedding.GenerateEmbeddings(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"text-embedding-3-small", "text-embedding-3-large"}, inner.models)
+	assert.Equal(t, "text-embedding-3-large", req.Model)
+}
+
+func TestDefaultModelProvider_ModelOverridesPolicy(t *testing.T) {
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{{ID: "text-embedding-3-large"}})
+	provider.SetPolicy(NewModelPolicy(PolicyRule{Name: "no-ada", Deny: []string{"openai/*ada*"}}), nil)
+	inner := &modelRecordingEmbeddingModel{}
+	embedding := provider.WrapEmbeddingModel(inner)
+
+	ctx := ContextWithModels(context.Background(), ModelOverrides{Embedding: "text-embedding-ada-002"})
+	_, err := embedding.GenerateEmbeddings(ctx, &EmbeddingRequest{Model: "text-embedding-3-large", Contents: []string{"a"}})
+	var violation *PolicyViolation
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, "text-embedding-ada-002", violation.Model)
+	assert.Empty(t, inner.models)
+}
@@ -0,0 +1,407 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const conversationSummaryInstructions = `Summarize the conversation given by the user so that it can be continued without it. Keep facts, decisions, names, numbers and open questions; leave out pleasantries. Respond with the summary only.`
+
+// ConversationOption is a functional option for configuring a Conversation
+type ConversationOption func(*ConversationOptions)
+
+// ConversationOptions contains configuration options for a Conversation
+type ConversationOptions struct {
+	// ContextWindow is the number of tokens the history must fit in. When zero it is taken
+	// from the model if it implements TokenCounter; without either the history is not trimmed.
+	ContextWindow int
+	// ReservedTokens are kept free in the context window for the reply
+	ReservedTokens int
+	// Tokenizer counts the tokens of the history instead of the model
+	Tokenizer Tokenizer
+	// Summarizer summarizes trimmed turns into the instructions instead of discarding them
+	Summarizer CompletionModel
+	// TitleModel generates the title of the conversation, see WithAutoTitle
+	TitleModel CompletionModel
+	// SummaryModel keeps a rolling summary of the conversation, see WithAutoSummary
+	SummaryModel CompletionModel
+	// SummaryTurns is the number of turns between updates of the rolling summary
+	SummaryTurns int
+	// MetadataHook is called when the title or summary was generated, see WithMetadataHook
+	MetadataHook ConversationMetadataHook
+}
+
+// WithConversationContextWindow sets the number of tokens the history must fit in
+func WithConversationContextWindow(tokens int) ConversationOption {
+	return func(o *ConversationOptions) {
+		o.ContextWindow = tokens
+	}
+}
+
+// WithReservedTokens keeps tokens free in the context window for the reply, typically the
+// maximum output tokens of the model
+func WithReservedTokens(tokens int) ConversationOption {
+	return func(o *ConversationOptions) {
+		o.ReservedTokens = tokens
+	}
+}
+
+// WithConversationTokenizer counts the tokens of the history with tokenizer
+func WithConversationTokenizer(tokenizer Tokenizer) ConversationOption {
+	return func(o *ConversationOptions) {
+		o.Tokenizer = tokenizer
+	}
+}
+
+// WithSummarizer summarizes trimmed turns with model, e.g. a small fast one, and keeps the
+// summary in the instructions
+func WithSummarizer(model CompletionModel) ConversationOption {
+	return func(o *ConversationOptions) {
+		o.Summarizer = model
+	}
+}
+
+// ApplyConversationOptions applies the given options and returns the resulting configuration
+func ApplyConversationOptions(opts []ConversationOption) *ConversationOptions {
+	options := &ConversationOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// Conversation keeps the message history of a chat with a completion model. Every turn adds
+// the user message and the reply. Before a request is sent the oldest turns are dropped, or
+// summarized with WithSummarizer, until the history fits the context window. With
+// WithAutoTitle and WithAutoSummary it also generates a title and summary for chat UIs.
+type Conversation struct {
+	model        CompletionModel
+	instructions string
+	options      *ConversationOptions
+
+	mu          sync.Mutex
+	messages    []*ModelMessage
+	summary     string
+	total       usageTotal
+	checkpoints []*conversationCheckpoint
+
+	// metadata is generated in the background by afterTurn
+	metadata     ConversationMetadata
+	turns        int
+	unsummarized []*ModelMessage
+	titling      bool
+	summarizing  bool
+	// summarizingTurns holds the turns being summarized
+	summarizingTurns []*ModelMessage
+	// rollbacks counts the rollbacks, so metadata generated from undone turns is discarded
+	rollbacks int
+	// background is canceled by Close, ending the metadata being generated
+	background context.Context
+	stop       context.CancelFunc
+	closed     bool
+	pending    sync.WaitGroup
+}
+
+// conversationCheckpoint is the state of a conversation saved by Checkpoint
+type conversationCheckpoint struct {
+	name         string
+	messages     []*ModelMessage
+	summary      string
+	total        usageTotal
+	metadata     ConversationMetadata
+	turns        int
+	unsummarized []*ModelMessage
+}
+
+// NewConversation starts a conversation with model
+func NewConversation(model CompletionModel, instructions string, opts ...ConversationOption) *Conversation {
+	background, stop := context.WithCancel(context.Background())
+	return &Conversation{
+		model:        model,
+		instructions: instructions,
+		options:      ApplyConversationOptions(opts),
+		background:   background,
+		stop:         stop,
+	}
+}
+
+// Messages returns a copy of the history
+func (c *Conversation) Messages() []*ModelMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*ModelMessage(nil), c.messages...)
+}
+
+// Summary returns the summary of the trimmed turns, empty if none were summarized
+func (c *Conversation) Summary() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.summary
+}
+
+// Usage returns the total usage and cost of the conversation, including summaries
+func (c *Conversation) Usage() (*TokenUsage, *float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total.usage, c.total.cost
+}
+
+// CostBreakdown returns the breakdown of the total cost of the conversation, see Usage
+func (c *Conversation) CostBreakdown() *CostBreakdown {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total.breakdown
+}
+
+// Append adds messages to the history without sending them, e.g. tool results or turns of
+// an earlier session
+func (c *Conversation) Append(messages ...*ModelMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, messages...)
+}
+
+// Checkpoint saves the history, summary, usage and metadata of the conversation under name,
+// replacing an earlier checkpoint of the same name
+func (c *Conversation) Checkpoint(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	checkpoint := &conversationCheckpoint{
+		name:     name,
+		messages: append([]*ModelMessage(nil), c.messages...),
+		summary:  c.summary,
+		total:    c.total.clone(),
+		metadata: c.metadata,
+		turns:    c.turns,
+		// The turns being summarized are not covered by the metadata saved yet
+		unsummarized: append(append([]*ModelMessage(nil), c.summarizingTurns...), c.unsummarized...),
+	}
+	for i, existing := range c.checkpoints {
+		if existing.name == name {
+			c.checkpoints = append(c.checkpoints[:i], c.checkpoints[i+1:]...)
+			break
+		}
+	}
+	c.checkpoints = append(c.checkpoints, checkpoint)
+}
+
+// RollbackTo restores the history, summary, usage and metadata saved by Checkpoint under
+// name, undoing the turns since. Metadata still being generated from the undone turns is
+// discarded. Checkpoints saved later are kept, so a rollback can be redone. Money spent on
+// the undone turns is still counted by cost trackers.
+func (c *Conversation) RollbackTo(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, checkpoint := range c.checkpoints {
+		if checkpoint.name == name {
+			c.messages = append([]*ModelMessage(nil), checkpoint.messages...)
+			c.summary = checkpoint.summary
+			c.total = checkpoint.total.clone()
+			c.metadata = checkpoint.metadata
+			c.turns = checkpoint.turns
+			c.unsummarized = append([]*ModelMessage(nil), checkpoint.unsummarized...)
+			c.rollbacks++
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrCheckpointNotFound, name)
+}
+
+// Checkpoints returns the names of the saved checkpoints, oldest first
+func (c *Conversation) Checkpoints() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, len(c.checkpoints))
+	for i, checkpoint := range c.checkpoints {
+		names[i] = checkpoint.name
+	}
+	return names
+}
+
+// Complete sends message with the history and adds both message and reply to it. On error
+// the history is left as it was, apart from trimming.
+func (c *Conversation) Complete(ctx context.Context, message *ModelMessage) (*CompletionResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	req, err := c.prepare(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.model.Complete(ctx, req)
+	if err != nil {
+		c.rollback(message)
+		return nil, err
+	}
+	reply := &ModelMessage{Role: RoleAssistant, Content: resp.Output}
+	c.messages = append(c.messages, reply)
+	c.total.addResponse(resp)
+	c.afterTurn(ctx, message, reply)
+	return resp, nil
+}
+
+// StreamComplete sends message with the history and streams the reply, adding both to the
+// history once the stream ends without an error chunk. The conversation is locked until the
+// stream is drained.
+func (c *Conversation) StreamComplete(ctx context.Context, message *ModelMessage) (StreamCompletionResponse, error) {
+	c.mu.Lock()
+
+	req, err := c.prepare(ctx, message)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	stream, err := c.model.StreamComplete(ctx, req)
+	if err != nil {
+		c.rollback(message)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer c.mu.Unlock()
+
+		var output strings.Builder
+		failed := false
+		for chunk := range stream {
+			switch chunk := chunk.(type) {
+			case StreamTextChunk:
+				output.WriteString(chunk.Text)
+			case *StreamTextBytesChunk:
+				output.Write(chunk.Text)
+			case StreamUsageChunk:
+				c.total.add(chunk.Usage, chunk.Cost, chunk.CostBreakdown)
+			case StreamErrorChunk:
+				failed = true
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				failed = true
+			}
+		}
+		if failed {
+			c.rollback(message)
+			return
+		}
+		reply := &ModelMessage{Role: RoleAssistant, Content: output.String()}
+		c.messages = append(c.messages, reply)
+		c.afterTurn(ctx, message, reply)
+	}()
+	return out, nil
+}
+
+// prepare adds message to the history, trims it to the context window and returns the request
+func (c *Conversation) prepare(ctx context.Context, message *ModelMessage) (*CompletionRequest, error) {
+	if message == nil {
+		return nil, NewValidationError("message", "cannot be nil", nil)
+	}
+	c.messages = append(c.messages, message)
+	if err := c.trim(ctx); err != nil {
+		c.rollback(message)
+		return nil, err
+	}
+	return c.request(), nil
+}
+
+// rollback removes message from the end of the history
+func (c *Conversation) rollback(message *ModelMessage) {
+	if n := len(c.messages); n > 0 && c.messages[n-1] == message {
+		c.messages = c.messages[:n-1]
+	}
+}
+
+func (c *Conversation) request() *CompletionRequest {
+	instructions := c.instructions
+	if c.summary != "" {
+		if instructions != "" {
+			instructions += "\n\n"
+		}
+		instructions += "Summary of the earlier conversation:\n" + c.summary
+	}
+	return &CompletionRequest{Instructions: instructions, Messages: append([]*ModelMessage(nil), c.messages...)}
+}
+
+// trim drops the oldest turns until the request fits the context window. A turn is a user
+// message with the replies and tool results that follow it, so tool results are never
+// separated from their calls. The latest turn is always kept.
+func (c *Conversation) trim(ctx context.Context) error {
+	var dropped []*ModelMessage
+	for {
+		tokens, window, err := c.countTokens(c.request())
+		if err != nil {
+			return err
+		}
+		if window <= 0 || tokens+c.options.ReservedTokens <= window {
+			if len(dropped) == 0 || c.options.Summarizer == nil {
+				return nil
+			}
+			// The summary takes room as well, so check again with it
+			if err := c.summarize(ctx, dropped); err != nil {
+				c.messages = append(dropped, c.messages...)
+				return err
+			}
+			dropped = nil
+			continue
+		}
+
+		n := c.oldestTurn()
+		if n == 0 {
+			return fmt.Errorf("%w: the latest turn needs about %d tokens of %d", ErrContextWindowExceeded, tokens+c.options.ReservedTokens, window)
+		}
+		dropped = append(dropped, c.messages[:n]...)
+		c.messages = c.messages[n:]
+	}
+}
+
+// oldestTurn returns the number of messages in the oldest turn, or 0 if it is the latest
+func (c *Conversation) oldestTurn() int {
+	for i := 1; i < len(c.messages); i++ {
+		if c.messages[i].Role == RoleUser {
+			return i
+		}
+	}
+	return 0
+}
+
+func (c *Conversation) countTokens(req *CompletionRequest) (tokens int, window int, err error) {
+	window = c.options.ContextWindow
+	if c.options.Tokenizer != nil {
+		return CountRequestTokens(c.options.Tokenizer, req), window, nil
+	}
+	if counter, ok := c.model.(TokenCounter); ok {
+		count, err := counter.CountTokens(req)
+		if err != nil {
+			return 0, 0, err
+		}
+		if window == 0 {
+			window = count.ContextWindow
+		}
+		return count.InputTokens, window, nil
+	}
+	return CountRequestTokens(HeuristicTokenizer{}, req), window, nil
+}
+
+// summarize merges the dropped messages into the summary
+func (c *Conversation) summarize(ctx context.Context, dropped []*ModelMessage) error {
+	resp, err := c.options.Summarizer.Complete(ctx, &CompletionRequest{
+		Instructions: conversationSummaryInstructions,
+		Messages:     []*ModelMessage{{Role: RoleUser, Content: conversationTranscript(c.summary, dropped)}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	c.summary = strings.TrimSpace(resp.Output)
+	c.total.addResponse(resp)
+	return nil
+}
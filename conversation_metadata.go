@@ -0,0 +1,215 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const conversationTitleInstructions = `Write a title of at most six words for the conversation given by the user, in its language. Respond with the title only, without quotes or a final period.`
+
+const conversationRollingSummaryInstructions = `Summarize the conversation given by the user in at most three sentences, for a list of chats. Cover what it is about and what was concluded. Respond with the summary only.`
+
+// ConversationMetadata describes a conversation for chat UIs, generated with WithAutoTitle
+// and WithAutoSummary
+type ConversationMetadata struct {
+	// Title is a short title of the conversation, generated after its first turn
+	Title string `json:"title,omitempty"`
+	// Summary is a rolling summary of the whole conversation. Unlike Conversation.Summary, which
+	// only covers trimmed turns and is sent to the model, it is meant to be shown to users.
+	Summary string `json:"summary,omitempty"`
+	// Turns is the number of turns the summary covers
+	Turns int `json:"turns,omitempty"`
+}
+
+// ConversationMetadataHook is called after the metadata of a conversation was generated, e.g.
+// to store it with the session. err is set when generating the title or summary failed; the
+// metadata then holds the values that were generated.
+type ConversationMetadataHook func(ctx context.Context, metadata ConversationMetadata, err error)
+
+// WithAutoTitle generates a title for the conversation with model, e.g. a small cheap one,
+// after its first turn
+func WithAutoTitle(model CompletionModel) ConversationOption {
+	return func(o *ConversationOptions) {
+		o.TitleModel = model
+	}
+}
+
+// WithAutoSummary keeps a rolling summary of the conversation, updated with model, e.g. a small
+// cheap one, after every turns turns. The summary is updated from the previous summary and
+// the turns since, so each update costs about the same.
+func WithAutoSummary(model CompletionModel, turns int) ConversationOption {
+	return func(o *ConversationOptions) {
+		o.SummaryModel = model
+		o.SummaryTurns = max(turns, 1)
+	}
+}
+
+// WithMetadataHook calls hook whenever the title or summary of the conversation was generated
+func WithMetadataHook(hook ConversationMetadataHook) ConversationOption {
+	return func(o *ConversationOptions) {
+		o.MetadataHook = hook
+	}
+}
+
+// Metadata returns the title and summary generated so far
+func (c *Conversation) Metadata() ConversationMetadata {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.metadata
+}
+
+// SetMetadata restores the metadata of an earlier session, e.g. together with Append. A
+// conversation with a title does not generate a new one.
+func (c *Conversation) SetMetadata(metadata ConversationMetadata) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metadata = metadata
+}
+
+// Wait waits for the metadata being generated, e.g. before the session is stored
+func (c *Conversation) Wait() {
+	c.pending.Wait()
+}
+
+// Close cancels the metadata being generated and waits for it to end. Turns completed after
+// Close do not generate metadata.
+func (c *Conversation) Close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.stop()
+	c.pending.Wait()
+}
+
+// metadataJob is the metadata to generate after a turn
+type metadataJob struct {
+	// title holds the first turn when a title is due
+	title []*ModelMessage
+	// turns holds the turns not yet summarized when a summary is due
+	turns []*ModelMessage
+	count int
+	// rollbacks is the number of rollbacks when the job started
+	rollbacks int
+}
+
+// afterTurn records a completed turn and generates the metadata that is due in the
+// background, so replies are not delayed. It is called with c.mu held.
+func (c *Conversation) afterTurn(ctx context.Context, message, reply *ModelMessage) {
+	if c.options.TitleModel == nil && c.options.SummaryModel == nil || c.closed {
+		return
+	}
+	c.turns++
+	c.unsummarized = append(c.unsummarized, message, reply)
+
+	job := metadataJob{rollbacks: c.rollbacks}
+	if c.options.TitleModel != nil && c.metadata.Title == "" && !c.titling {
+		job.title = []*ModelMessage{message, reply}
+		c.titling = true
+	}
+	if c.options.SummaryModel != nil && c.turns-c.metadata.Turns >= c.options.SummaryTurns && !c.summarizing {
+		job.turns, job.count = c.unsummarized, c.turns
+		c.summarizingTurns, c.unsummarized = c.unsummarized, nil
+		c.summarizing = true
+	}
+	if job.title == nil && job.turns == nil {
+		return
+	}
+	// The job outlives the request of the turn, but not the conversation
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(c.background, cancel)
+	c.pending.Add(1)
+	go func() {
+		defer c.pending.Done()
+		defer stop()
+		defer cancel()
+		c.generateMetadata(ctx, job)
+	}()
+}
+
+// generateMetadata generates the title and summary of job and reports them to the hook
+func (c *Conversation) generateMetadata(ctx context.Context, job metadataJob) {
+	var errs []error
+	var title, summary string
+	var total usageTotal
+	if job.title != nil {
+		resp, err := c.options.TitleModel.Complete(ctx, &CompletionRequest{
+			Instructions: conversationTitleInstructions,
+			Messages:     []*ModelMessage{{Role: RoleUser, Content: conversationTranscript("", job.title)}},
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to generate conversation title: %w", err))
+		} else {
+			title = strings.Trim(strings.TrimSpace(resp.Output), `"'.`)
+			total.addResponse(resp)
+		}
+	}
+	if job.turns != nil {
+		c.mu.Lock()
+		previous := c.metadata.Summary
+		c.mu.Unlock()
+		resp, err := c.options.SummaryModel.Complete(ctx, &CompletionRequest{
+			Instructions: conversationRollingSummaryInstructions,
+			Messages:     []*ModelMessage{{Role: RoleUser, Content: conversationTranscript(previous, job.turns)}},
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to generate conversation summary: %w", err))
+		} else {
+			summary = strings.TrimSpace(resp.Output)
+			total.addResponse(resp)
+		}
+	}
+
+	c.mu.Lock()
+	// Metadata generated from turns undone by a rollback is discarded
+	current := job.rollbacks == c.rollbacks
+	if job.title != nil {
+		c.titling = false
+		if title != "" && current {
+			c.metadata.Title = title
+		}
+	}
+	if job.turns != nil {
+		c.summarizing = false
+		c.summarizingTurns = nil
+		switch {
+		case !current:
+			// The restored checkpoint holds the turns to summarize
+		case summary != "":
+			c.metadata.Summary, c.metadata.Turns = summary, job.count
+		default:
+			// The turns are summarized with the next update
+			c.unsummarized = append(job.turns, c.unsummarized...)
+		}
+	}
+	c.total.addTotal(total)
+	metadata := c.metadata
+	c.mu.Unlock()
+
+	if c.options.MetadataHook != nil {
+		c.options.MetadataHook(ctx, metadata, errors.Join(errs...))
+	}
+}
+
+// conversationTranscript formats messages as a transcript for a summarizer, after summary of
+// the earlier conversation if any
+func conversationTranscript(summary string, messages []*ModelMessage) string {
+	var transcript strings.Builder
+	if summary != "" {
+		fmt.Fprintf(&transcript, "Summary of the conversation so far:\n%s\n\n", summary)
+	}
+	for _, msg := range messages {
+		text := msg.Text()
+		if msg.ToolCall != nil {
+			text = strings.TrimSpace(fmt.Sprintf("%s [tool %s]", text, msg.ToolCall.Name))
+		}
+		if text != "" {
+			fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, text)
+		}
+	}
+	return transcript.String()
+}
@@ -0,0 +1,204 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataUpdate is a call of a ConversationMetadataHook
+type metadataUpdate struct {
+	metadata ConversationMetadata
+	err      error
+}
+
+// metadataHook returns a hook sending its calls on a channel, and a function waiting for the next
+func metadataHook(t *testing.T) (ConversationMetadataHook, func() metadataUpdate) {
+	updates := make(chan metadataUpdate, 8)
+	hook := func(ctx context.Context, metadata ConversationMetadata, err error) {
+		updates <- metadataUpdate{metadata, err}
+	}
+	return hook, func() metadataUpdate {
+		t.Helper()
+		select {
+		case update := <-updates:
+			return update
+		case <-time.After(time.Second):
+			t.Fatal("metadata was not generated")
+			return metadataUpdate{}
+		}
+	}
+}
+
+func TestConversation_Metadata(t *testing.T) {
+	ctx := context.Background()
+	model := &scriptedModel{outputs: []string{"Paris in spring.", "The Louvre.", "Take the metro.", "Enjoy!"}}
+	titler := &scriptedModel{outputs: []string{`"Trip to Paris."`}}
+	summarizer := &scriptedModel{outputs: []string{"Planning a trip to Paris.", "Planning a trip to Paris by metro."}}
+	hook, next := metadataHook(t)
+	conv := NewConversation(model, "Be brief.", WithAutoTitle(titler), WithAutoSummary(summarizer, 2), WithMetadataHook(hook))
+
+	_, err := conv.Complete(ctx, userMessage("When should I visit Paris?"))
+	require.NoError(t, err)
+	update := next()
+	require.NoError(t, update.err)
+	assert.Equal(t, ConversationMetadata{Title: "Trip to Paris"}, update.metadata)
+	assert.Equal(t, "user: When should I visit Paris?\nassistant: Paris in spring.\n", titler.requests[0].Messages[0].Content)
+
+	_, err = conv.Complete(ctx, userMessage("What should I see?"))
+	require.NoError(t, err)
+	update = next()
+	require.NoError(t, update.err)
+	assert.Equal(t, ConversationMetadata{Title: "Trip to Paris", Summary: "Planning a trip to Paris.", Turns: 2}, update.metadata)
+	assert.Contains(t, summarizer.requests[0].Messages[0].Content, "user: When should I visit Paris?")
+	assert.Contains(t, summarizer.requests[0].Messages[0].Content, "assistant: The Louvre.")
+
+	// The summary is updated from the previous one and the turns since
+	_, err = conv.Complete(ctx, userMessage("How do I get around?"))
+	require.NoError(t, err)
+	_, err = conv.Complete(ctx, userMessage("Thanks"))
+	require.NoError(t, err)
+	update = next()
+	assert.Equal(t, "Planning a trip to Paris by metro.", update.metadata.Summary)
+	assert.Equal(t, 4, update.metadata.Turns)
+	transcript := summarizer.requests[1].Messages[0].Content
+	assert.Contains(t, transcript, "Summary of the conversation so far:\nPlanning a trip to Paris.")
+	assert.NotContains(t, transcript, "When should I visit Paris?")
+	assert.Contains(t, transcript, "user: How do I get around?")
+
+	assert.Equal(t, update.metadata, conv.Metadata())
+	assert.Len(t, titler.requests, 1)
+	usage, cost := conv.Usage()
+	assert.Equal(t, 7, usage.TotalRequests, "turns, title and summaries")
+	assert.InDelta(t, 0.007, *cost, 1e-12)
+}
+
+// failingModel fails every request with err
+type failingModel struct {
+	err error
+}
+
+func (m *failingModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	return nil, m.err
+}
+
+func (m *failingModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	return nil, m.err
+}
+
+func TestConversation_MetadataFailure(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("rate limited")
+	titler := &failingModel{err: failure}
+	summarizer := &failingModel{err: failure}
+	hook, next := metadataHook(t)
+	conv := NewConversation(&streamingModel{output: "Hello there"}, "", WithAutoTitle(titler), WithAutoSummary(summarizer, 1), WithMetadataHook(hook))
+
+	stream, err := conv.StreamComplete(ctx, userMessage("Hi"))
+	require.NoError(t, err)
+	for range stream {
+	}
+	update := next()
+	assert.ErrorIs(t, update.err, failure)
+	assert.ErrorContains(t, update.err, "failed to generate conversation title")
+	assert.ErrorContains(t, update.err, "failed to generate conversation summary")
+	assert.Equal(t, ConversationMetadata{}, update.metadata)
+
+	// Both are retried after the next turn, with the turns that were not summarized
+	conv.options.TitleModel = &scriptedModel{outputs: []string{"Greetings"}}
+	retry := &scriptedModel{outputs: []string{"Two greetings."}}
+	conv.options.SummaryModel = retry
+	stream, err = conv.StreamComplete(ctx, userMessage("Hi again"))
+	require.NoError(t, err)
+	for range stream {
+	}
+	update = next()
+	require.NoError(t, update.err)
+	assert.Equal(t, ConversationMetadata{Title: "Greetings", Summary: "Two greetings.", Turns: 2}, update.metadata)
+	assert.Contains(t, retry.requests[0].Messages[0].Content, "user: Hi\n")
+	assert.Contains(t, retry.requests[0].Messages[0].Content, "user: Hi again\n")
+}
+
+func TestConversation_SetMetadata(t *testing.T) {
+	titler := &scriptedModel{}
+	conv := NewConversation(&scriptedModel{outputs: []string{"Hello"}}, "", WithAutoTitle(titler))
+	conv.SetMetadata(ConversationMetadata{Title: "Restored"})
+
+	_, err := conv.Complete(context.Background(), userMessage("Hi"))
+	require.NoError(t, err)
+	assert.Equal(t, ConversationMetadata{Title: "Restored"}, conv.Metadata())
+	assert.Empty(t, titler.requests)
+}
+
+// blockingModel answers once release is closed, or fails when its context ends first
+type blockingModel struct {
+	release chan struct{}
+	output  string
+}
+
+func (m *blockingModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	select {
+	case <-m.release:
+		return &CompletionResponse{Output: m.output}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (m *blockingModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	return nil, NewUnsupportedCapabilityError("blocking", "streaming")
+}
+
+func TestConversation_Close(t *testing.T) {
+	ctx := context.Background()
+	titler := &scriptedModel{outputs: []string{"Greetings"}}
+	hook, next := metadataHook(t)
+	conv := NewConversation(&scriptedModel{outputs: []string{"Hello", "Bye"}}, "", WithAutoTitle(&blockingModel{release: make(chan struct{})}), WithMetadataHook(hook))
+
+	_, err := conv.Complete(ctx, userMessage("Hi"))
+	require.NoError(t, err)
+	conv.Close()
+	update := next()
+	assert.ErrorIs(t, update.err, context.Canceled)
+
+	// Turns after Close do not generate metadata
+	conv.options.TitleModel = titler
+	_, err = conv.Complete(ctx, userMessage("Bye"))
+	require.NoError(t, err)
+	conv.Wait()
+	assert.Empty(t, titler.requests)
+	assert.Equal(t, ConversationMetadata{}, conv.Metadata())
+}
+
+func TestConversation_RollbackMetadata(t *testing.T) {
+	ctx := context.Background()
+	summarizer := &blockingModel{release: make(chan struct{}), output: "Greetings."}
+	conv := NewConversation(&scriptedModel{outputs: []string{"Hello", "Hi again", "Welcome"}}, "", WithAutoSummary(summarizer, 1))
+	conv.Checkpoint("start")
+
+	// A summary generated from an undone turn is discarded
+	_, err := conv.Complete(ctx, userMessage("Hi"))
+	require.NoError(t, err)
+	require.NoError(t, conv.RollbackTo("start"))
+	close(summarizer.release)
+	conv.Wait()
+	assert.Equal(t, ConversationMetadata{}, conv.Metadata())
+
+	_, err = conv.Complete(ctx, userMessage("Hello"))
+	require.NoError(t, err)
+	conv.Wait()
+	assert.Equal(t, ConversationMetadata{Summary: "Greetings.", Turns: 1}, conv.Metadata())
+
+	// Rolling back restores the metadata and the turns of the checkpoint
+	conv.Checkpoint("greeted")
+	_, err = conv.Complete(ctx, userMessage("Good day"))
+	require.NoError(t, err)
+	conv.Wait()
+	assert.Equal(t, 2, conv.Metadata().Turns)
+	require.NoError(t, conv.RollbackTo("greeted"))
+	assert.Equal(t, ConversationMetadata{Summary: "Greetings.", Turns: 1}, conv.Metadata())
+}
@@ -12,9 +12,12 @@ type ConversationModel interface {
 	Response(ctx context.Context, req *ConversationRequest) (*ConversationResponse, error)
 }
 
-// ConversationRequest represents a request to the conversation/responses API
+// ConversationRequest represents a request to the conversation/responses API.
+// Input is sent as a plain user message; Messages allows multi-turn input with
+// artifacts (images and files). When both are set, Input is appended after Messages.
 type ConversationRequest struct {
-	Input string
+	Input    string
+	Messages []*ModelMessage
 }
 
 // ConversationResponse represents a complete response from the conversation/responses API
@@ -22,6 +25,8 @@ type ConversationResponse struct {
 	Output string `json:"output"`
 	Usage  *TokenUsage
 	Cost   *float64
+	// CostBreakdown splits Cost by what was billed, see CompletionResponse.CostBreakdown
+	CostBreakdown *CostBreakdown `json:"costBreakdown,omitempty"`
 }
 
 // StreamConversationResponse represents a stream of response chunks
@@ -67,7 +72,9 @@ func WithOptions(opts ...CompletionOption) ResponseOption {
 
 // ApplyResponseOptions applies all options to create a ResponseOptions struct
 func ApplyResponseOptions(opts []ResponseOption) *ResponseOptions {
-	options := &ResponseOptions{}
+	options := &ResponseOptions{
+		CompletionOptions: &CompletionOptions{},
+	}
 	for _, opt := range opts {
 		opt(options)
 	}
@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wordTokenizer counts one token per word
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+// streamingModel streams its output word by word and ends with an error chunk if err is set
+type streamingModel struct {
+	output string
+	err    error
+}
+
+func (m *streamingModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	ch := make(chan StreamChunk, 8)
+	go func() {
+		defer close(ch)
+		for _, word := range strings.SplitAfter(m.output, " ") {
+			ch <- StreamTextChunk{Text: word}
+		}
+		if m.err != nil {
+			ch <- StreamErrorChunk{Provider: "test", Err: m.err}
+		}
+	}()
+	return ch, nil
+}
+
+func (m *streamingModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	return nil, NewUnsupportedCapabilityError("streaming", "completion")
+}
+
+func userMessage(text string) *ModelMessage {
+	return &ModelMessage{Role: RoleUser, Content: text}
+}
+
+func messageContents(messages []*ModelMessage) []string {
+	contents := make([]string, len(messages))
+	for i, msg := range messages {
+		contents[i] = msg.Content
+	}
+	return contents
+}
+
+func TestConversation_Complete(t *testing.T) {
+	model := &scriptedModel{outputs: []string{"Hi Ada.", "Your name is Ada."}}
+	conv := NewConversation(model, "Be brief.")
+
+	_, err := conv.Complete(context.Background(), userMessage("I am Ada."))
+	require.NoError(t, err)
+	resp, err := conv.Complete(context.Background(), userMessage("What is my name?"))
+	require.NoError(t, err)
+	assert.Equal(t, "Your name is Ada.", resp.Output)
+
+	require.Len(t, model.requests, 2)
+	assert.Equal(t, "Be brief.", model.requests[1].Instructions)
+	assert.Equal(t, []string{"I am Ada.", "Hi Ada.", "What is my name?"}, messageContents(model.requests[1].Messages))
+	assert.Equal(t, []string{"I am Ada.", "Hi Ada.", "What is my name?", "Your name is Ada."}, messageContents(conv.Messages()))
+
+	usage, cost := conv.Usage()
+	assert.Equal(t, 2, usage.TotalRequests)
+	assert.InDelta(t, 0.002, *cost, 1e-9)
+}
+
+func TestConversation_TrimsOldestTurns(t *testing.T) {
+	model := &scriptedModel{outputs: []string{"e f g h", "e f g h"}}
+	// Every message takes 7 tokens and the reply 3, so two turns and a question fit
+	conv := NewConversation(model, "", WithConversationTokenizer(wordTokenizer{}), WithConversationContextWindow(30))
+
+	// A turn with a tool call is dropped as a whole
+	call := &ToolCall{ID: "1", Name: "lookup"}
+	conv.Append(
+		userMessage("q1 b c d"),
+		&ModelMessage{Role: RoleAssistant, ToolCall: call},
+		&ModelMessage{Role: RoleTool, ToolCall: call},
+		&ModelMessage{Role: RoleAssistant, Content: "e f g h"},
+	)
+	_, err := conv.Complete(context.Background(), userMessage("q2 b c d"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"q2 b c d"}, messageContents(model.requests[0].Messages))
+
+	_, err = conv.Complete(context.Background(), userMessage("q3 b c d"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"q2 b c d", "e f g h", "q3 b c d"}, messageContents(model.requests[1].Messages))
+	assert.Empty(t, conv.Summary())
+}
+
+func TestConversation_Summarizes(t *testing.T) {
+	model := &scriptedModel{outputs: []string{"e f g h", "e f g h", "e f g h"}}
+	summarizer := &scriptedModel{outputs: []string{"x y"}}
+	conv := NewConversation(model, "", WithConversationTokenizer(wordTokenizer{}), WithConversationContextWindow(35), WithSummarizer(summarizer))
+
+	for _, question := range []string{"q1 b c d", "q2 b c d", "q3 b c d"} {
+		_, err := conv.Complete(context.Background(), userMessage(question))
+		require.NoError(t, err)
+	}
+
+	require.Len(t, summarizer.requests, 1)
+	assert.Equal(t, "user: q1 b c d\nassistant: e f g h\n", summarizer.requests[0].Messages[0].Content)
+	assert.Equal(t, "x y", conv.Summary())
+	assert.Equal(t, "Summary of the earlier conversation:\nx y", model.requests[2].Instructions)
+	assert.Equal(t, []string{"q2 b c d", "e f g h", "q3 b c d"}, messageContents(model.requests[2].Messages))
+
+	usage, _ := conv.Usage()
+	assert.Equal(t, 4, usage.TotalRequests)
+}
+
+func TestConversation_LatestTurnTooLarge(t *testing.T) {
+	model := &scriptedModel{}
+	conv := NewConversation(model, "", WithConversationTokenizer(wordTokenizer{}), WithConversationContextWindow(10), WithReservedTokens(4))
+
+	_, err := conv.Complete(context.Background(), userMessage("a b c d"))
+	assert.ErrorIs(t, err, ErrContextWindowExceeded)
+	assert.Empty(t, conv.Messages())
+	assert.Empty(t, model.requests)
+}
+
+func TestConversation_StreamComplete(t *testing.T) {
+	conv := NewConversation(&streamingModel{output: "Hello there"}, "")
+
+	stream, err := conv.StreamComplete(context.Background(), userMessage("Hi"))
+	require.NoError(t, err)
+	var output strings.Builder
+	for chunk := range stream {
+		output.WriteString(chunk.String())
+	}
+	assert.Equal(t, "Hello there", output.String())
+	assert.Equal(t, []string{"Hi", "Hello there"}, messageContents(conv.Messages()))
+}
+
+func TestConversation_StreamErrorRollsBack(t *testing.T) {
+	conv := NewConversation(&streamingModel{output: "Hel", err: errors.New("connection reset")}, "")
+
+	stream, err := conv.StreamComplete(context.Background(), userMessage("Hi"))
+	require.NoError(t, err)
+	var last StreamChunk
+	for chunk := range stream {
+		last = chunk
+	}
+	assert.Equal(t, ErrorChunkType, last.Type())
+	assert.Empty(t, conv.Messages())
+}
+
+func TestConversation_Checkpoints(t *testing.T) {
+	model := &scriptedModel{outputs: []string{"e f g h", "e f g h", "e f g h"}}
+	summarizer := &scriptedModel{outputs: []string{"x y"}}
+	conv := NewConversation(model, "", WithConversationTokenizer(wordTokenizer{}), WithConversationContextWindow(35), WithSummarizer(summarizer))
+
+	_, err := conv.Complete(context.Background(), userMessage("q1 b c d"))
+	require.NoError(t, err)
+	conv.Checkpoint("first")
+	for _, question := range []string{"q2 b c d", "q3 b c d"} {
+		_, err := conv.Complete(context.Background(), userMessage(question))
+		require.NoError(t, err)
+	}
+	conv.Checkpoint("third")
+	assert.Equal(t, "x y", conv.Summary())
+
+	// Rolling back restores the history, summary and usage before the summary was made
+	require.NoError(t, conv.RollbackTo("first"))
+	assert.Equal(t, []string{"q1 b c d", "e f g h"}, messageContents(conv.Messages()))
+	assert.Empty(t, conv.Summary())
+	usage, _ := conv.Usage()
+	assert.Equal(t, 1, usage.TotalRequests)
+
+	// Later checkpoints survive the rollback and later turns do not change the checkpoint
+	assert.Equal(t, []string{"first", "third"}, conv.Checkpoints())
+	require.NoError(t, conv.RollbackTo("third"))
+	assert.Equal(t, "x y", conv.Summary())
+	usage, _ = conv.Usage()
+	assert.Equal(t, 4, usage.TotalRequests)
+	require.NoError(t, conv.RollbackTo("first"))
+	usage, _ = conv.Usage()
+	assert.Equal(t, 1, usage.TotalRequests)
+
+	// Saving a checkpoint again replaces it
+	conv.Append(userMessage("q4"))
+	conv.Checkpoint("first")
+	assert.Equal(t, []string{"third", "first"}, conv.Checkpoints())
+
+	err = conv.RollbackTo("missing")
+	assert.ErrorIs(t, err, ErrCheckpointNotFound)
+}
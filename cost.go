@@ -0,0 +1,363 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MicroCents is an exact cost amount in millionths of a US cent (1 USD = 100,000,000 MicroCents).
+// Costs are accumulated as integers to avoid float rounding drift across many calls,
+// and only converted to floats or rounded when displayed. Costs of models priced in another
+// currency, see ModelPricing.Currency, are millionths of a cent of that currency.
+type MicroCents int64
+
+const (
+	// MicroCentsPerUSD is the number of MicroCents in one US dollar
+	MicroCentsPerUSD MicroCents = 100_000_000
+)
+
+// RoundingMode controls how costs are rounded for display
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to nearest, ties away from zero
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to nearest, ties to even (banker's rounding)
+	RoundHalfEven
+	// RoundDown truncates towards zero
+	RoundDown
+	// RoundUp rounds away from zero
+	RoundUp
+)
+
+// MicroCentsFromUSD converts a USD amount into MicroCents, rounding to the nearest unit
+func MicroCentsFromUSD(usd float64) MicroCents {
+	return MicroCents(math.Round(usd * float64(MicroCentsPerUSD)))
+}
+
+// USD returns the amount in US dollars
+func (c MicroCents) USD() float64 {
+	return float64(c) / float64(MicroCentsPerUSD)
+}
+
+// Round returns the amount in US dollars rounded to the given number of decimal places (0-8)
+func (c MicroCents) Round(places int, mode RoundingMode) float64 {
+	if places < 0 {
+		places = 0
+	}
+	if places >= 8 {
+		return c.USD()
+	}
+
+	unit := int64(1)
+	for i := places; i < 8; i++ {
+		unit *= 10
+	}
+
+	v := int64(c)
+	neg := v < 0
+	if neg {
+		v = -v
+	}
+	q, r := v/unit, v%unit
+
+	switch mode {
+	case RoundHalfUp:
+		if r*2 >= unit {
+			q++
+		}
+	case RoundHalfEven:
+		if r*2 > unit || (r*2 == unit && q%2 == 1) {
+			q++
+		}
+	case RoundUp:
+		if r > 0 {
+			q++
+		}
+	case RoundDown:
+	}
+
+	if neg {
+		q = -q
+	}
+	return float64(q) / math.Pow10(places)
+}
+
+// Format renders the amount as a dollar string with the given precision and rounding mode
+func (c MicroCents) Format(places int, mode RoundingMode) string {
+	if places < 0 {
+		places = 0
+	}
+	if places > 8 {
+		places = 8
+	}
+	return fmt.Sprintf("$%.*f", places, c.Round(places, mode))
+}
+
+func (c MicroCents) String() string {
+	return c.Format(6, RoundHalfUp)
+}
+
+// CostTracker accumulates costs and usage exactly across many calls, e.g. all calls of a
+// provider configured WithCostTracker. With a limit set it also caps spending, see SetLimit.
+// Costs are accumulated in the currency of the tracker, USD unless SetCurrency changes it, and
+// costs reported in other currencies are kept apart, see TotalIn. It is safe for concurrent use.
+type CostTracker struct {
+	total    atomic.Int64
+	requests atomic.Int64
+	limit    atomic.Int64
+
+	mu    sync.Mutex
+	usage TokenUsage
+	// reserved is the estimated cost of the requests in flight
+	reserved MicroCents
+	// currency is the currency of total, USD when empty
+	currency string
+	// others holds the costs reported in other currencies, by currency
+	others map[string]MicroCents
+}
+
+// NewCostTracker creates a new, empty cost tracker
+func NewCostTracker() *CostTracker {
+	return &CostTracker{}
+}
+
+// Add records a cost reported in the currency of the tracker, as returned on responses. Nil
+// costs are ignored.
+func (t *CostTracker) Add(cost *float64) {
+	if cost == nil {
+		return
+	}
+	t.AddMicroCents(MicroCentsFromUSD(*cost))
+}
+
+// AddMicroCents records an exact cost in the currency of the tracker
+func (t *CostTracker) AddMicroCents(cost MicroCents) {
+	t.total.Add(int64(cost))
+	t.requests.Add(1)
+}
+
+// Record records the usage and cost of a response, the cost in the currency of the tracker.
+// Nil values are ignored.
+func (t *CostTracker) Record(usage *TokenUsage, cost *float64) {
+	t.record(usage, cost, "")
+}
+
+// record records the usage and cost of a response whose cost is in currency, or in the
+// currency of the tracker when currency is empty. Costs in other currencies are kept apart.
+func (t *CostTracker) record(usage *TokenUsage, cost *float64, currency string) {
+	t.mu.Lock()
+	if usage != nil {
+		t.usage.Append(usage)
+	}
+	if cost != nil && currency != "" && currencyCode(currency) != currencyCode(t.currency) {
+		if t.others == nil {
+			t.others = make(map[string]MicroCents)
+		}
+		t.others[currencyCode(currency)] += MicroCentsFromUSD(*cost)
+		t.requests.Add(1)
+		cost = nil
+	}
+	t.mu.Unlock()
+	t.Add(cost)
+}
+
+// reserve reserves estimate for a request to a model priced in currency, empty if unknown. It
+// returns a BudgetExceededError if the request would exceed the limit, or a
+// CurrencyMismatchError if the limit is in another currency.
+func (t *CostTracker) reserve(estimate MicroCents, currency string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if limit := t.Limit(); limit > 0 {
+		if currency != "" && currencyCode(currency) != currencyCode(t.currency) {
+			return &CurrencyMismatchError{Currency: currencyCode(currency), Expected: currencyCode(t.currency)}
+		}
+		if err := exceeds(limit, t.Total()+t.reserved, estimate); err != nil {
+			return err
+		}
+	}
+	t.reserved += estimate
+	return nil
+}
+
+// settle records the usage and cost of a request, releasing a reservation of estimate
+func (t *CostTracker) settle(estimate MicroCents, usage *TokenUsage, cost *float64, currency string) {
+	// The cost is recorded before the reservation is released, so a concurrent reserve always
+	// counts the request
+	t.record(usage, cost, currency)
+	t.mu.Lock()
+	t.reserved -= estimate
+	t.mu.Unlock()
+}
+
+// Total returns the exact accumulated cost in the currency of the tracker
+func (t *CostTracker) Total() MicroCents {
+	return MicroCents(t.total.Load())
+}
+
+// TotalUSD returns the accumulated cost as a decimal amount, in US dollars unless the currency
+// of the tracker is set
+func (t *CostTracker) TotalUSD() float64 {
+	return t.Total().USD()
+}
+
+// TotalIn returns the exact accumulated cost of the responses priced in currency, an ISO 4217
+// code
+func (t *CostTracker) TotalIn(currency string) MicroCents {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if currencyCode(currency) == currencyCode(t.currency) {
+		return t.Total()
+	}
+	return t.others[currencyCode(currency)]
+}
+
+// Currency returns the ISO 4217 code of the currency of Total and of the limit, USD by default
+func (t *CostTracker) Currency() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return currencyCode(t.currency)
+}
+
+// SetCurrency sets the currency of Total and of the limit to an ISO 4217 code, e.g. for
+// providers whose models are priced in EUR. The costs already recorded are kept in their own
+// currency. While a limit is set, models tracked by t return a CurrencyMismatchError instead of
+// issuing requests to models priced in another currency, as their costs cannot count against
+// it.
+func (t *CostTracker) SetCurrency(currency string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	code, previous := currencyCode(currency), currencyCode(t.currency)
+	if code == previous {
+		return
+	}
+	if t.others == nil {
+		t.others = make(map[string]MicroCents)
+	}
+	if total := t.Total(); total != 0 {
+		t.others[previous] = total
+	}
+	t.total.Store(int64(t.others[code]))
+	delete(t.others, code)
+	t.currency = code
+}
+
+// Requests returns the number of recorded costs, in any currency
+func (t *CostTracker) Requests() int64 {
+	return t.requests.Load()
+}
+
+// Usage returns the accumulated usage
+func (t *CostTracker) Usage() TokenUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// SetLimit caps the total cost at usd, an amount in the currency of the tracker. Models tracked
+// by t return a BudgetExceededError instead of issuing requests whose estimated prompt cost
+// would exceed it, counting the estimates of the requests in flight. Zero removes the limit.
+func (t *CostTracker) SetLimit(usd float64) {
+	t.limit.Store(int64(MicroCentsFromUSD(usd)))
+}
+
+// Limit returns the cost limit, or 0 if there is none
+func (t *CostTracker) Limit() MicroCents {
+	return MicroCents(t.limit.Load())
+}
+
+// Reset clears the accumulated totals. The limit and currency are kept.
+func (t *CostTracker) Reset() {
+	t.total.Store(0)
+	t.requests.Store(0)
+	t.mu.Lock()
+	t.usage = TokenUsage{}
+	t.others = nil
+	t.mu.Unlock()
+}
+
+// currencyCode returns the ISO 4217 code of currency, USD when empty
+func currencyCode(currency string) string {
+	if currency == "" {
+		return "USD"
+	}
+	return strings.ToUpper(currency)
+}
+
+// usageTotal accumulates the usage, cost and cost breakdown of several requests, so the Cost
+// and CostBreakdown of aggregates agree. Costs in different currencies cannot be summed: once
+// responses priced in two currencies are added, the total has no cost or breakdown.
+type usageTotal struct {
+	usage     *TokenUsage
+	cost      *float64
+	breakdown *CostBreakdown
+	// mixed is set once costs in different currencies were added
+	mixed bool
+}
+
+// add accumulates the usage, cost and cost breakdown of a response. Usage and breakdowns are
+// copied, not retained.
+func (t *usageTotal) add(usage *TokenUsage, cost *float64, breakdown *CostBreakdown) {
+	if usage != nil {
+		if t.usage == nil {
+			t.usage = &TokenUsage{}
+		}
+		t.usage.Append(usage)
+	}
+	if t.mixed {
+		return
+	}
+	if breakdown != nil && t.breakdown != nil && currencyCode(breakdown.Currency) != currencyCode(t.breakdown.Currency) {
+		t.cost, t.breakdown, t.mixed = nil, nil, true
+		return
+	}
+	if cost != nil {
+		sum := *cost
+		if t.cost != nil {
+			sum = (MicroCentsFromUSD(*t.cost) + MicroCentsFromUSD(*cost)).USD()
+		}
+		t.cost = &sum
+	}
+	if breakdown != nil {
+		if t.breakdown == nil {
+			t.breakdown = &CostBreakdown{}
+		}
+		t.breakdown.Append(breakdown)
+	}
+}
+
+// addTotal accumulates another total
+func (t *usageTotal) addTotal(other usageTotal) {
+	t.add(other.usage, other.cost, other.breakdown)
+	if other.mixed {
+		t.cost, t.breakdown, t.mixed = nil, nil, true
+	}
+}
+
+// addResponse accumulates the usage and cost of resp
+func (t *usageTotal) addResponse(resp *CompletionResponse) {
+	t.add(resp.Usage, resp.Cost, resp.CostBreakdown)
+}
+
+// clone returns a copy of the total that is not changed by adding to t
+func (t usageTotal) clone() usageTotal {
+	if t.usage != nil {
+		usage := *t.usage
+		t.usage = &usage
+	}
+	if t.cost != nil {
+		cost := *t.cost
+		t.cost = &cost
+	}
+	if t.breakdown != nil {
+		breakdown := *t.breakdown
+		t.breakdown = &breakdown
+	}
+	return t
+}
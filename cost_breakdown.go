@@ -0,0 +1,123 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+// CostBreakdown splits the cost of a response by what was billed, in exact MicroCents, so
+// spend can be attributed to cached input, reasoning or web searches without recomputing it
+// from the usage and pricing. Its Total is the cost reported with it.
+type CostBreakdown struct {
+	// Input is the cost of the input tokens billed at the prompt rate. Cached and audio input
+	// tokens are included when the model has no rate of their own for them.
+	Input MicroCents `json:"input"`
+	// CachedInput is the cost of the input tokens read from the prompt cache
+	CachedInput MicroCents `json:"cachedInput"`
+	// CacheWrite is the cost of the input tokens written to the prompt cache
+	CacheWrite MicroCents `json:"cacheWrite"`
+	AudioInput MicroCents `json:"audioInput"`
+	// Output is the cost of the output tokens billed at the completion rate, including the
+	// reasoning tokens of models without a reasoning rate
+	Output      MicroCents `json:"output"`
+	AudioOutput MicroCents `json:"audioOutput"`
+	// Reasoning is the cost of the reasoning tokens of models with a reasoning rate
+	Reasoning MicroCents `json:"reasoning"`
+	WebSearch MicroCents `json:"webSearch"`
+	Images    MicroCents `json:"images"`
+	// Speech is the cost of the characters synthesized by speech models
+	Speech MicroCents `json:"speech"`
+	Video  MicroCents `json:"video"`
+	// FreeTokens is the number of input and output tokens billed at a zero rate, e.g. by free
+	// models or promotional prices
+	FreeTokens int64 `json:"freeTokens"`
+	// Currency is the currency of the pricing of the model, USD when empty
+	Currency string `json:"currency,omitempty"`
+}
+
+// Total returns the sum of the components
+func (b *CostBreakdown) Total() MicroCents {
+	if b == nil {
+		return 0
+	}
+	return b.Input + b.CachedInput + b.CacheWrite + b.AudioInput + b.Output + b.AudioOutput +
+		b.Reasoning + b.WebSearch + b.Images + b.Speech + b.Video
+}
+
+// Append adds the components of other, e.g. of the retries of a request
+func (b *CostBreakdown) Append(other *CostBreakdown) {
+	if other == nil {
+		return
+	}
+	b.Input += other.Input
+	b.CachedInput += other.CachedInput
+	b.CacheWrite += other.CacheWrite
+	b.AudioInput += other.AudioInput
+	b.Output += other.Output
+	b.AudioOutput += other.AudioOutput
+	b.Reasoning += other.Reasoning
+	b.WebSearch += other.WebSearch
+	b.Images += other.Images
+	b.Speech += other.Speech
+	b.Video += other.Video
+	b.FreeTokens += other.FreeTokens
+	if b.Currency == "" {
+		b.Currency = other.Currency
+	}
+}
+
+// CostBreakdown prices usage with the pricing of the model, or returns nil when either is
+// nil. Inputs longer than the long context threshold are billed entirely at the long context
+// rates. Cached and audio tokens are included in the input and output tokens of usage, and
+// billed at their own rates when the model has them.
+func (m *ModelInfo) CostBreakdown(usage *TokenUsage) *CostBreakdown {
+	if m == nil || usage == nil {
+		return nil
+	}
+	pricing := m.ForInputTokens(usage.TotalInputTokens).Pricing
+	b := &CostBreakdown{Currency: pricing.Currency}
+
+	inputTokens := usage.TotalInputTokens
+	if !pricing.InputCacheRead.IsZero() {
+		inputTokens -= usage.TotalCacheReadTokens
+		b.CachedInput = TokenCost(usage.TotalCacheReadTokens, pricing.InputCacheRead)
+	}
+	if !pricing.InputCacheWrite.IsZero() {
+		inputTokens -= usage.TotalCacheWriteTokens
+		b.CacheWrite = TokenCost(usage.TotalCacheWriteTokens, pricing.InputCacheWrite)
+	}
+	if !pricing.AudioInput.IsZero() {
+		inputTokens -= usage.TotalAudioInputTokens
+		b.AudioInput = TokenCost(usage.TotalAudioInputTokens, pricing.AudioInput)
+	}
+	b.Input = TokenCost(inputTokens, pricing.Prompt)
+	if pricing.Prompt.IsZero() {
+		b.FreeTokens += inputTokens
+	}
+
+	if !pricing.InternalReasoning.IsZero() {
+		b.Reasoning = TokenCost(usage.TotalReasoningTokens, pricing.InternalReasoning)
+	}
+
+	outputTokens := usage.TotalOutputTokens
+	if !pricing.AudioOutput.IsZero() {
+		outputTokens -= usage.TotalAudioOutputTokens
+		b.AudioOutput = TokenCost(usage.TotalAudioOutputTokens, pricing.AudioOutput)
+	}
+	b.Output = TokenCost(outputTokens, pricing.Completion)
+	if pricing.Completion.IsZero() {
+		b.FreeTokens += outputTokens
+	}
+
+	if !pricing.Characters.IsZero() {
+		b.Speech = TokenCost(usage.TotalCharacters, pricing.Characters)
+	}
+	if !pricing.VideoSecond.IsZero() {
+		b.Video = pricing.VideoSecond.Cost(usage.TotalVideoSeconds)
+	}
+	if !pricing.WebSearch.IsZero() {
+		b.WebSearch = pricing.WebSearch.Cost(float64(usage.TotalWebSearches))
+	}
+	if !pricing.Image.IsZero() {
+		b.Images = pricing.Image.Cost(float64(usage.TotalImages))
+	}
+	return b
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelInfo_CostBreakdown(t *testing.T) {
+	info := &ModelInfo{Pricing: ModelPricing{
+		Prompt:            NewPrice(2),
+		Completion:        NewPrice(8),
+		InputCacheRead:    NewPrice(0.5),
+		InputCacheWrite:   NewPrice(2.5),
+		InternalReasoning: NewPrice(8),
+		WebSearch:         NewPrice(0.01),
+	}}
+	usage := &TokenUsage{
+		TotalInputTokens:      1_000_000,
+		TotalCacheReadTokens:  400_000,
+		TotalCacheWriteTokens: 100_000,
+		TotalOutputTokens:     200_000,
+		TotalReasoningTokens:  50_000,
+		TotalWebSearches:      3,
+	}
+
+	breakdown := info.CostBreakdown(usage)
+	require.NotNil(t, breakdown)
+	assert.Equal(t, &CostBreakdown{
+		Input:       MicroCentsFromUSD(1.0),
+		CachedInput: MicroCentsFromUSD(0.2),
+		CacheWrite:  MicroCentsFromUSD(0.25),
+		Output:      MicroCentsFromUSD(1.6),
+		Reasoning:   MicroCentsFromUSD(0.4),
+		WebSearch:   MicroCentsFromUSD(0.03),
+	}, breakdown)
+	assert.Equal(t, MicroCentsFromUSD(3.48), breakdown.Total())
+
+	// Cached tokens are billed as input tokens by models without a cache rate
+	info.Pricing.InputCacheRead, info.Pricing.InputCacheWrite = Price{}, Price{}
+	breakdown = info.CostBreakdown(usage)
+	assert.Equal(t, MicroCentsFromUSD(2.0), breakdown.Input)
+	assert.Zero(t, breakdown.CachedInput)
+
+	assert.Nil(t, info.CostBreakdown(nil))
+	assert.Nil(t, (*ModelInfo)(nil).CostBreakdown(usage))
+	assert.Zero(t, (*CostBreakdown)(nil).Total())
+}
+
+func TestModelInfo_CostBreakdownFreeTokens(t *testing.T) {
+	free := &ModelInfo{}
+	breakdown := free.CostBreakdown(&TokenUsage{TotalInputTokens: 120, TotalOutputTokens: 30})
+	assert.Equal(t, int64(150), breakdown.FreeTokens)
+	assert.Zero(t, breakdown.Total())
+
+	// Only the output of a model with free input is billed
+	freeInput := &ModelInfo{Pricing: ModelPricing{Completion: NewPrice(1)}}
+	breakdown = freeInput.CostBreakdown(&TokenUsage{TotalInputTokens: 120, TotalOutputTokens: 30})
+	assert.Equal(t, int64(120), breakdown.FreeTokens)
+	assert.Equal(t, TokenCost(30, NewPrice(1)), breakdown.Output)
+}
+
+func TestCostBreakdown_Append(t *testing.T) {
+	images := &ModelInfo{Pricing: ModelPricing{Image: NewPrice(0.04)}}
+	total := &CostBreakdown{}
+	total.Append(images.CostBreakdown(&TokenUsage{TotalImages: 2}))
+	total.Append(images.CostBreakdown(&TokenUsage{TotalImages: 1}))
+	total.Append(nil)
+	assert.Equal(t, MicroCentsFromUSD(0.12), total.Images)
+	assert.Equal(t, MicroCentsFromUSD(0.12), total.Total())
+
+	data, err := json.Marshal(total)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"images":12000000`)
+}
+
+func TestUsageTotal(t *testing.T) {
+	usd := &ModelInfo{Pricing: ModelPricing{Prompt: NewPrice(1), Completion: NewPrice(2)}}
+	eur := &ModelInfo{Pricing: ModelPricing{Prompt: NewPrice(1), Currency: "EUR"}}
+	response := func(model *ModelInfo, usage *TokenUsage) *CompletionResponse {
+		breakdown := model.CostBreakdown(usage)
+		cost := breakdown.Total().USD()
+		return &CompletionResponse{Usage: usage, Cost: &cost, CostBreakdown: breakdown}
+	}
+
+	var total usageTotal
+	total.addResponse(response(usd, &TokenUsage{TotalInputTokens: 1000, TotalOutputTokens: 500}))
+	total.addResponse(response(usd, &TokenUsage{TotalInputTokens: 1000}))
+	total.add(&TokenUsage{TotalRequests: 1}, nil, nil)
+	require.NotNil(t, total.cost)
+	assert.Equal(t, MicroCentsFromUSD(*total.cost), total.breakdown.Total(), "cost and breakdown agree")
+	assert.Equal(t, int64(2000), total.usage.TotalInputTokens)
+
+	checkpoint := total.clone()
+	total.addResponse(response(eur, &TokenUsage{TotalInputTokens: 1000}))
+	assert.Nil(t, total.cost, "costs in different currencies are not summed")
+	assert.Nil(t, total.breakdown)
+	assert.Equal(t, int64(3000), total.usage.TotalInputTokens)
+	total.addResponse(response(usd, &TokenUsage{TotalInputTokens: 1000}))
+	assert.Nil(t, total.cost, "a mixed total stays without cost")
+
+	assert.Equal(t, int64(2000), checkpoint.usage.TotalInputTokens, "clones are not changed")
+	assert.NotNil(t, checkpoint.cost)
+	var merged usageTotal
+	merged.addTotal(checkpoint)
+	merged.addTotal(total)
+	assert.Nil(t, merged.cost)
+	assert.Equal(t, int64(6000), merged.usage.TotalInputTokens)
+}
+
+// breakdownModel adds a cost breakdown to the responses of attemptsModel
+type breakdownModel struct {
+	*attemptsModel
+}
+
+func (m breakdownModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, err := m.attemptsModel.Complete(ctx, req)
+	if err == nil {
+		resp.CostBreakdown = &CostBreakdown{Input: 10, Output: 5}
+	}
+	return resp, err
+}
+
+func TestOutputCheckCompletionModel_SumsCostBreakdown(t *testing.T) {
+	model := breakdownModel{&attemptsModel{outputs: []string{" ", "answer"}}}
+	checked := NewOutputCheckCompletionModel(model, []CompletionOption{WithOutputChecks(DefaultOutputChecks())})
+
+	resp, err := checked.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, &CostBreakdown{Input: 20, Output: 10}, resp.CostBreakdown)
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens int64
+		price  float64
+		want   MicroCents
+	}{
+		{name: "zero_tokens", tokens: 0, price: 2.5, want: 0},
+		{name: "zero_price", tokens: 1000, price: 0, want: 0},
+		{name: "one_million", tokens: 1_000_000, price: 2.5, want: 250_000_000},
+		{name: "single_token", tokens: 1, price: 0.15, want: 15},
+		{name: "fractional_price", tokens: 1234, price: 0.075, want: 9255},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, TokenCost(tt.tokens, NewPrice(tt.price)))
+		})
+	}
+}
+
+func TestMicroCents_Round(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  MicroCents
+		places int
+		mode   RoundingMode
+		want   float64
+	}{
+		{name: "half_up", value: 1_250_000, places: 2, mode: RoundHalfUp, want: 0.01},
+		{name: "half_up_tie", value: 500_000, places: 2, mode: RoundHalfUp, want: 0.01},
+		{name: "half_even_tie_down", value: 500_000, places: 2, mode: RoundHalfEven, want: 0.0},
+		{name: "half_even_tie_up", value: 1_500_000, places: 2, mode: RoundHalfEven, want: 0.02},
+		{name: "down", value: 1_999_999, places: 2, mode: RoundDown, want: 0.01},
+		{name: "up", value: 1_000_001, places: 2, mode: RoundUp, want: 0.02},
+		{name: "negative_half_up", value: -1_500_000, places: 2, mode: RoundHalfUp, want: -0.02},
+		{name: "full_precision", value: 123, places: 8, mode: RoundHalfUp, want: 0.00000123},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.value.Round(tt.places, tt.mode))
+		})
+	}
+}
+
+func TestMicroCents_Format(t *testing.T) {
+	assert.Equal(t, "$0.000123", MicroCents(12_345).String())
+	assert.Equal(t, "$0.01", MicroCents(1_250_000).Format(2, RoundHalfUp))
+	assert.Equal(t, "$1", MicroCentsPerUSD.Format(0, RoundHalfUp))
+}
+
+func TestCostTracker_AggregationPrecision(t *testing.T) {
+	// One million calls costing $0.0000001 each must total exactly $0.1,
+	// which naive float64 accumulation does not produce.
+	const calls = 1_000_000
+	cost := 0.0000001
+
+	tracker := NewCostTracker()
+	naive := 0.0
+	for i := 0; i < calls; i++ {
+		tracker.Add(&cost)
+		naive += cost
+	}
+
+	assert.Equal(t, MicroCents(10_000_000), tracker.Total())
+	assert.Equal(t, 0.1, tracker.TotalUSD())
+	assert.NotEqual(t, 0.1, naive, "float accumulation is expected to drift")
+	assert.Equal(t, int64(calls), tracker.Requests())
+}
+
+func TestCostTracker_SimulatedWorkload(t *testing.T) {
+	// Simulate a mixed workload of token-priced calls and verify the
+	// tracker total equals the sum of per-call exact costs.
+	tracker := NewCostTracker()
+	var want MicroCents
+	for i := int64(1); i <= 100_000; i++ {
+		c := TokenCost(i%4096, NewPrice(2.5)) + TokenCost(i%512, NewPrice(10))
+		want += c
+		tracker.AddMicroCents(c)
+	}
+
+	assert.Equal(t, want, tracker.Total())
+	assert.Equal(t, want.Round(2, RoundHalfUp), tracker.Total().Round(2, RoundHalfUp))
+}
+
+func TestCostTracker_Concurrent(t *testing.T) {
+	tracker := NewCostTracker()
+	cost := 0.000123
+
+	var wg sync.WaitGroup
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				tracker.Add(&cost)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, MicroCents(50*1000*12_300), tracker.Total())
+
+	tracker.Add(nil)
+	assert.Equal(t, int64(50*1000), tracker.Requests())
+
+	tracker.Reset()
+	assert.Equal(t, MicroCents(0), tracker.Total())
+}
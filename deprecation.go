@@ -0,0 +1,55 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ModelDeprecatedError is returned when creating a model past its deprecation or sunset date
+// with strict deprecation enabled, see WithStrictDeprecation
+type ModelDeprecatedError struct {
+	Provider     string
+	Model        string
+	DeprecatesAt *time.Time
+	SunsetsAt    *time.Time
+	Replacement  string
+	// Sunset is set when the provider no longer serves the model
+	Sunset bool
+}
+
+func (e *ModelDeprecatedError) Error() string {
+	msg := fmt.Sprintf("%s model %s is deprecated", e.Provider, e.Model)
+	switch {
+	case e.Sunset:
+		msg = fmt.Sprintf("%s model %s was sunset on %s", e.Provider, e.Model, e.SunsetsAt.Format(time.DateOnly))
+	case e.SunsetsAt != nil:
+		msg += fmt.Sprintf(" and sunsets on %s", e.SunsetsAt.Format(time.DateOnly))
+	}
+	if e.Replacement != "" {
+		msg += ", use " + e.Replacement + " instead"
+	}
+	return msg
+}
+
+func (e *ModelDeprecatedError) Is(target error) bool {
+	return target == ErrModelDeprecated
+}
+
+// deprecationAttrs returns the structured attributes of the deprecation warning of info
+func deprecationAttrs(provider string, info *ModelInfo) []any {
+	attrs := []any{slog.String("provider", provider), slog.String("model", info.ID)}
+	if info.DeprecatesAt != nil {
+		attrs = append(attrs, slog.Time("deprecatesAt", *info.DeprecatesAt))
+	}
+	if info.SunsetsAt != nil {
+		attrs = append(attrs, slog.Time("sunsetsAt", *info.SunsetsAt))
+	}
+	if info.Replacement != "" {
+		attrs = append(attrs, slog.String("replacement", info.Replacement))
+	}
+	return attrs
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelInfoDeprecation(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.AddDate(0, -1, 0), now.AddDate(0, 1, 0)
+
+	assert.False(t, (&ModelInfo{}).IsDeprecated(now))
+	assert.True(t, (&ModelInfo{DeprecatesAt: &past}).IsDeprecated(now))
+	assert.False(t, (&ModelInfo{DeprecatesAt: &future}).IsDeprecated(now))
+
+	sunset := &ModelInfo{SunsetsAt: &past}
+	assert.True(t, sunset.IsDeprecated(now))
+	assert.True(t, sunset.IsSunset(now))
+	assert.False(t, (&ModelInfo{DeprecatesAt: &past, SunsetsAt: &future}).IsSunset(now))
+}
+
+func TestCheckDeprecation(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	deprecatesAt, sunsetsAt := now.AddDate(0, -1, 0), now.AddDate(0, 1, 0)
+	current := &ModelInfo{ID: "current"}
+	deprecated := &ModelInfo{ID: "old", DeprecatesAt: &deprecatesAt, SunsetsAt: &sunsetsAt, Replacement: "current"}
+
+	var buf bytes.Buffer
+	provider := NewDefaultModelProvider("test", []*ModelInfo{current, deprecated})
+	provider.now = func() time.Time { return now }
+	provider.SetDeprecationPolicy(slog.New(slog.NewTextHandler(&buf, nil)), false)
+
+	require.NoError(t, provider.CheckDeprecation(current))
+	assert.Empty(t, buf.String())
+
+	require.NoError(t, provider.CheckDeprecation(deprecated))
+	require.NoError(t, provider.CheckDeprecation(deprecated))
+	assert.Equal(t, 1, strings.Count(buf.String(), "level=WARN"), "the warning is logged once per model")
+	assert.Contains(t, buf.String(), "model=old")
+	assert.Contains(t, buf.String(), "sunsetsAt=2025-07-01T00:00:00.000Z")
+	assert.Contains(t, buf.String(), "replacement=current")
+
+	provider.SetDeprecationPolicy(nil, true)
+	err := provider.CheckDeprecation(deprecated)
+	assert.ErrorIs(t, err, ErrModelDeprecated)
+	var deprecatedErr *ModelDeprecatedError
+	require.True(t, errors.As(err, &deprecatedErr))
+	assert.False(t, deprecatedErr.Sunset)
+	assert.Equal(t, "test model old is deprecated and sunsets on 2025-07-01, use current instead", err.Error())
+
+	provider.now = func() time.Time { return sunsetsAt }
+	err = provider.CheckDeprecation(deprecated)
+	require.True(t, errors.As(err, &deprecatedErr))
+	assert.True(t, deprecatedErr.Sunset)
+	assert.Equal(t, "test model old was sunset on 2025-07-01, use current instead", err.Error())
+}
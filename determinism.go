@@ -0,0 +1,61 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+// DeterminismLevel describes how a provider honored a deterministic completion request.
+//
+// Seeds behave differently across providers: OpenAI, Azure OpenAI, Gemini, Vertex AI and
+// OpenRouter sample on a best-effort basis with a seed, while Anthropic and DeepSeek ignore it.
+// Where the seed is ignored, sampling is pinned to greedy decoding instead. Neither guarantees
+// identical outputs; compare ResponseMetadata.SystemFingerprint across responses to detect backend
+// changes that break reproducibility.
+type DeterminismLevel string
+
+const (
+	// DeterminismSeeded means the seed was sent to a provider that samples with it
+	DeterminismSeeded DeterminismLevel = "seeded"
+	// DeterminismPinned means the seed is ignored by the provider, so temperature was pinned
+	// to 0 and top_p left at its default of 1
+	DeterminismPinned DeterminismLevel = "pinned"
+	// DeterminismNone means neither a seed nor pinned sampling is supported, e.g. for
+	// reasoning models that only accept the default sampling parameters
+	DeterminismNone DeterminismLevel = "none"
+)
+
+// WithDeterministic requests reproducible sampling with the given seed. How the request is
+// honored depends on the provider and is reported in ResponseMetadata.Determinism.
+func WithDeterministic(seed int64) CompletionOption {
+	return func(o *CompletionOptions) {
+		deterministic := true
+		o.Deterministic = &deterministic
+		o.Seed = &seed
+	}
+}
+
+// IsDeterministic reports whether deterministic sampling was requested
+func (o *CompletionOptions) IsDeterministic() bool {
+	return o != nil && o.Deterministic != nil && *o.Deterministic
+}
+
+// NormalizeDeterminism adapts deterministic options to what a provider supports. It returns
+// a copy of opts with sampling pinned when the provider ignores seeds but accepts sampling
+// parameters, and the resulting level. Options that are not deterministic are returned as is
+// with an empty level.
+func NormalizeDeterminism(opts *CompletionOptions, seedSupported, samplingSupported bool) (*CompletionOptions, DeterminismLevel) {
+	if !opts.IsDeterministic() {
+		return opts, ""
+	}
+	if seedSupported {
+		return opts, DeterminismSeeded
+	}
+	if !samplingSupported {
+		return opts, DeterminismNone
+	}
+
+	normalized := *opts
+	temperature := 0.0
+	normalized.Temperature = &temperature
+	normalized.TopP = nil
+	return &normalized, DeterminismPinned
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDeterminism(t *testing.T) {
+	tests := []struct {
+		name              string
+		opts              []CompletionOption
+		seedSupported     bool
+		samplingSupported bool
+		wantLevel         DeterminismLevel
+		wantTemperature   *float64
+	}{
+		{name: "not requested", opts: []CompletionOption{WithTemperature(0.7)}, samplingSupported: true, wantTemperature: ptr(0.7)},
+		{name: "seeded", opts: []CompletionOption{WithTemperature(0.7), WithDeterministic(42)}, seedSupported: true, samplingSupported: true, wantLevel: DeterminismSeeded, wantTemperature: ptr(0.7)},
+		{name: "pinned", opts: []CompletionOption{WithTemperature(0.7), WithTopP(0.9), WithDeterministic(42)}, samplingSupported: true, wantLevel: DeterminismPinned, wantTemperature: ptr(0.0)},
+		{name: "unsupported", opts: []CompletionOption{WithDeterministic(42)}, wantLevel: DeterminismNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ApplyCompletionOptions(tt.opts)
+
+			normalized, level := NormalizeDeterminism(opts, tt.seedSupported, tt.samplingSupported)
+
+			assert.Equal(t, tt.wantLevel, level)
+			assert.Equal(t, tt.wantTemperature, normalized.Temperature)
+			if level == DeterminismPinned {
+				assert.Nil(t, normalized.TopP)
+				// The caller's options are left untouched
+				assert.Equal(t, 0.7, *opts.Temperature)
+			}
+			if tt.wantLevel != "" {
+				require.NotNil(t, normalized.Seed)
+				assert.Equal(t, int64(42), *normalized.Seed)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,344 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+)
+
+// DriftOption is a functional option for configuring embedding drift detection
+type DriftOption func(*DriftOptions)
+
+// DriftOptions contains configuration options for embedding drift detection
+type DriftOptions struct {
+	// Neighbors is the number of nearest neighbors compared per document
+	Neighbors int
+	// BatchSize is the number of documents per embedding request
+	BatchSize int
+}
+
+// WithDriftNeighbors sets the number of nearest neighbors compared per document
+func WithDriftNeighbors(k int) DriftOption {
+	return func(o *DriftOptions) {
+		o.Neighbors = k
+	}
+}
+
+// WithDriftBatchSize sets the number of documents per embedding request
+func WithDriftBatchSize(size int) DriftOption {
+	return func(o *DriftOptions) {
+		o.BatchSize = size
+	}
+}
+
+// ApplyDriftOptions applies all options to create a DriftOptions struct
+func ApplyDriftOptions(opts []DriftOption) *DriftOptions {
+	options := &DriftOptions{
+		Neighbors: 10,
+		BatchSize: DefaultEmbeddingBatchSize,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// SimilarityStats summarizes a distribution of cosine similarities
+type SimilarityStats struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	Min    float64 `json:"min"`
+	P5     float64 `json:"p5"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+}
+
+// DocumentDrift is the drift of a single document of the corpus
+type DocumentDrift struct {
+	// Index is the position of the document in the corpus
+	Index int `json:"index"`
+	// NeighborOverlap is the fraction of the document's nearest neighbors shared by both models
+	NeighborOverlap float64 `json:"neighborOverlap"`
+	// Similarity is the cosine similarity of the document's two embeddings, or nil when the
+	// models have different dimensions
+	Similarity *float64 `json:"similarity,omitempty"`
+}
+
+// DriftReport compares how two embedding models represent the same corpus. Vectors of
+// different models usually live in different spaces, so the report focuses on whether the
+// models agree on which documents are similar: the correlation of pairwise similarities and
+// the overlap of nearest neighbors.
+type DriftReport struct {
+	Documents int `json:"documents"`
+	Neighbors int `json:"neighbors"`
+	// ReferenceSimilarity and CandidateSimilarity are the distributions of pairwise cosine
+	// similarities between documents under each model
+	ReferenceSimilarity SimilarityStats `json:"referenceSimilarity"`
+	CandidateSimilarity SimilarityStats `json:"candidateSimilarity"`
+	// SimilarityCorrelation is the Pearson correlation of pairwise similarities between the
+	// models; 1 means they rank document pairs identically
+	SimilarityCorrelation float64 `json:"similarityCorrelation"`
+	// NeighborOverlap is the mean fraction of nearest neighbors shared by both models
+	NeighborOverlap float64 `json:"neighborOverlap"`
+	// DirectSimilarity is the distribution of cosine similarities between the two embeddings
+	// of each document, only set when both models have the same dimensions
+	DirectSimilarity *SimilarityStats `json:"directSimilarity,omitempty"`
+	// Drift holds the per-document results, ordered by increasing neighbor overlap so the
+	// documents that moved most come first
+	Drift []*DocumentDrift `json:"drift"`
+	Usage *TokenUsage      `json:"usage,omitempty"`
+	Cost  *float64         `json:"cost,omitempty"`
+	// CostBreakdown splits Cost by what was billed, see CompletionResponse.CostBreakdown
+	CostBreakdown *CostBreakdown `json:"costBreakdown,omitempty"`
+}
+
+// CompareEmbeddings embeds corpus with a reference and a candidate model and reports how much
+// the candidate drifts from the reference, e.g. before migrating a vector index to a new
+// embedding model version. The corpus needs at least two documents.
+func CompareEmbeddings(ctx context.Context, reference, candidate EmbeddingModel, corpus []string, opts ...DriftOption) (*DriftReport, error) {
+	if reference == nil || candidate == nil {
+		return nil, NewValidationError("model", "cannot be nil", nil)
+	}
+	if len(corpus) < 2 {
+		return nil, NewValidationError("corpus", "must contain at least two documents", len(corpus))
+	}
+	options := ApplyDriftOptions(opts)
+	k := min(max(options.Neighbors, 1), len(corpus)-1)
+
+	refResp, err := GenerateEmbeddingsConcurrently(ctx, reference, &EmbeddingRequest{Contents: corpus}, options.BatchSize, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed corpus with reference model: %w", err)
+	}
+	candResp, err := GenerateEmbeddingsConcurrently(ctx, candidate, &EmbeddingRequest{Contents: corpus}, options.BatchSize, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed corpus with candidate model: %w", err)
+	}
+	refVectors, err := orderedEmbeddings(refResp, len(corpus))
+	if err != nil {
+		return nil, err
+	}
+	candVectors, err := orderedEmbeddings(candResp, len(corpus))
+	if err != nil {
+		return nil, err
+	}
+
+	refSims := pairwiseSimilarities(refVectors)
+	candSims := pairwiseSimilarities(candVectors)
+
+	report := &DriftReport{
+		Documents:             len(corpus),
+		Neighbors:             k,
+		ReferenceSimilarity:   similarityStats(upperTriangle(refSims)),
+		CandidateSimilarity:   similarityStats(upperTriangle(candSims)),
+		SimilarityCorrelation: pearson(upperTriangle(refSims), upperTriangle(candSims)),
+		Drift:                 make([]*DocumentDrift, len(corpus)),
+	}
+	var total usageTotal
+	total.add(refResp.Usage, refResp.Cost, refResp.CostBreakdown)
+	total.add(candResp.Usage, candResp.Cost, candResp.CostBreakdown)
+	report.Usage, report.Cost, report.CostBreakdown = total.usage, total.cost, total.breakdown
+
+	sameDimensions := len(refVectors[0]) == len(candVectors[0])
+	var direct []float64
+	var overlapSum float64
+	for i := range corpus {
+		drift := &DocumentDrift{
+			Index:           i,
+			NeighborOverlap: neighborOverlap(nearestNeighbors(refSims, i, k), nearestNeighbors(candSims, i, k)),
+		}
+		if sameDimensions {
+			similarity := cosineSimilarity(refVectors[i], candVectors[i])
+			drift.Similarity = &similarity
+			direct = append(direct, similarity)
+		}
+		overlapSum += drift.NeighborOverlap
+		report.Drift[i] = drift
+	}
+	report.NeighborOverlap = overlapSum / float64(len(corpus))
+	if sameDimensions {
+		stats := similarityStats(direct)
+		report.DirectSimilarity = &stats
+	}
+	sort.SliceStable(report.Drift, func(a, b int) bool {
+		return report.Drift[a].NeighborOverlap < report.Drift[b].NeighborOverlap
+	})
+	return report, nil
+}
+
+// RenderDriftReport writes a plain text summary of the report and the documents that
+// drifted most
+func RenderDriftReport(w io.Writer, report *DriftReport, corpus []string, top int) error {
+	stats := func(s SimilarityStats) string {
+		return fmt.Sprintf("mean %.3f, median %.3f, p5 %.3f, p95 %.3f", s.Mean, s.Median, s.P5, s.P95)
+	}
+	if _, err := fmt.Fprintf(w, "Documents: %d\nNeighbor overlap@%d: %.3f\nSimilarity correlation: %.3f\nReference similarity: %s\nCandidate similarity: %s\n",
+		report.Documents, report.Neighbors, report.NeighborOverlap, report.SimilarityCorrelation,
+		stats(report.ReferenceSimilarity), stats(report.CandidateSimilarity)); err != nil {
+		return err
+	}
+	if report.DirectSimilarity != nil {
+		if _, err := fmt.Fprintf(w, "Direct similarity: %s\n", stats(*report.DirectSimilarity)); err != nil {
+			return err
+		}
+	}
+	for _, drift := range report.Drift[:max(0, min(top, len(report.Drift)))] {
+		text := ""
+		if drift.Index < len(corpus) {
+			text = truncateRunes(corpus[drift.Index], 60)
+		}
+		if _, err := fmt.Fprintf(w, "  #%d overlap %.2f  %q\n", drift.Index, drift.NeighborOverlap, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderedEmbeddings returns the vectors of resp by input index
+func orderedEmbeddings(resp *EmbeddingResponse, n int) ([][]float64, error) {
+	vectors := make([][]float64, n)
+	for _, embedding := range resp.Embeddings {
+		if embedding.Index < 0 || embedding.Index >= n {
+			return nil, fmt.Errorf("embedding index %d out of range", embedding.Index)
+		}
+		vectors[embedding.Index] = embedding.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("missing embedding for document %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range min(len(a), len(b)) {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// pairwiseSimilarities returns the matrix of cosine similarities between vectors
+func pairwiseSimilarities(vectors [][]float64) [][]float64 {
+	sims := make([][]float64, len(vectors))
+	for i := range vectors {
+		sims[i] = make([]float64, len(vectors))
+	}
+	for i := range vectors {
+		sims[i][i] = 1
+		for j := i + 1; j < len(vectors); j++ {
+			s := cosineSimilarity(vectors[i], vectors[j])
+			sims[i][j], sims[j][i] = s, s
+		}
+	}
+	return sims
+}
+
+// upperTriangle returns the similarities of all distinct pairs
+func upperTriangle(sims [][]float64) []float64 {
+	values := make([]float64, 0, len(sims)*(len(sims)-1)/2)
+	for i := range sims {
+		values = append(values, sims[i][i+1:]...)
+	}
+	return values
+}
+
+// nearestNeighbors returns the indices of the k documents most similar to document i
+func nearestNeighbors(sims [][]float64, i, k int) []int {
+	neighbors := make([]int, 0, len(sims)-1)
+	for j := range sims {
+		if j != i {
+			neighbors = append(neighbors, j)
+		}
+	}
+	sort.SliceStable(neighbors, func(a, b int) bool {
+		return sims[i][neighbors[a]] > sims[i][neighbors[b]]
+	})
+	return neighbors[:k]
+}
+
+// neighborOverlap returns the fraction of a's neighbors that are also in b
+func neighborOverlap(a, b []int) float64 {
+	shared := 0
+	for _, n := range a {
+		if slices.Contains(b, n) {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a))
+}
+
+func similarityStats(values []float64) SimilarityStats {
+	if len(values) == 0 {
+		return SimilarityStats{}
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+	var variance float64
+	for _, v := range sorted {
+		variance += (v - mean) * (v - mean)
+	}
+	percentile := func(p float64) float64 {
+		return sorted[int(math.Round(p*float64(len(sorted)-1)))]
+	}
+	return SimilarityStats{
+		Mean:   mean,
+		StdDev: math.Sqrt(variance / float64(len(sorted))),
+		Min:    sorted[0],
+		P5:     percentile(0.05),
+		Median: percentile(0.5),
+		P95:    percentile(0.95),
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// pearson returns the correlation coefficient of a and b, or 0 when either is constant
+func pearson(a, b []float64) float64 {
+	n := float64(len(a))
+	if n == 0 {
+		return 0
+	}
+	var sumA, sumB float64
+	for i := range a {
+		sumA += a[i]
+		sumB += b[i]
+	}
+	meanA, meanB := sumA/n, sumB/n
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with an ellipsis
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
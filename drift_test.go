@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// vectorEmbeddingModel embeds each content with a fixed vector
+type vectorEmbeddingModel struct {
+	vectors map[string][]float64
+}
+
+func (m *vectorEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	resp := &EmbeddingResponse{Usage: &TokenUsage{TotalInputTokens: int64(len(req.Contents))}}
+	for i, content := range req.Contents {
+		resp.Embeddings = append(resp.Embeddings, Embedding{Index: i, Embedding: m.vectors[content]})
+	}
+	return resp, nil
+}
+
+func TestCompareEmbeddings(t *testing.T) {
+	corpus := []string{"cat", "kitten", "car", "truck"}
+	reference := &vectorEmbeddingModel{vectors: map[string][]float64{
+		"cat":    {1, 0.1},
+		"kitten": {1, 0.2},
+		"car":    {0.1, 1},
+		"truck":  {0.2, 1},
+	}}
+
+	t.Run("identical models", func(t *testing.T) {
+		report, err := CompareEmbeddings(context.Background(), reference, reference, corpus, WithDriftNeighbors(1), WithDriftBatchSize(3))
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, report.Documents)
+		assert.Equal(t, 1.0, report.NeighborOverlap)
+		assert.InDelta(t, 1.0, report.SimilarityCorrelation, 1e-9)
+		require.NotNil(t, report.DirectSimilarity)
+		assert.InDelta(t, 1.0, report.DirectSimilarity.Min, 1e-9)
+		assert.Equal(t, int64(8), report.Usage.TotalInputTokens)
+	})
+
+	t.Run("candidate mixes up a document", func(t *testing.T) {
+		// The candidate has other dimensions and places "kitten" next to the vehicles
+		candidate := &vectorEmbeddingModel{vectors: map[string][]float64{
+			"cat":    {1, 0, 0},
+			"kitten": {0, 1, 0.1},
+			"car":    {0, 1, 0},
+			"truck":  {0, 0.9, 0.1},
+		}}
+		report, err := CompareEmbeddings(context.Background(), reference, candidate, corpus, WithDriftNeighbors(1))
+		require.NoError(t, err)
+
+		assert.Nil(t, report.DirectSimilarity)
+		assert.Less(t, report.NeighborOverlap, 1.0)
+		assert.Less(t, report.SimilarityCorrelation, 0.9)
+		assert.Equal(t, 0.0, report.Drift[0].NeighborOverlap)
+		assert.Nil(t, report.Drift[0].Similarity)
+
+		var out bytes.Buffer
+		require.NoError(t, RenderDriftReport(&out, report, corpus, 2))
+		assert.Contains(t, out.String(), "Neighbor overlap@1")
+	})
+
+	t.Run("corpus too small", func(t *testing.T) {
+		_, err := CompareEmbeddings(context.Background(), reference, reference, corpus[:1])
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
@@ -20,6 +20,9 @@ type EmbeddingModelConfig struct {
 	EncodingFormat EmbeddingEncodingFormat `json:"encoding_format,omitempty"`
 	Dimensions     int                     `json:"dimensions,omitempty"`
 	User           string                  `json:"user,omitempty"`
+	// TaskType optimizes the embeddings for their use, e.g. documents and queries are
+	// embedded differently for retrieval. Providers without task types ignore it.
+	TaskType EmbeddingTaskType `json:"task_type,omitempty"`
 }
 
 type Embedding struct {
@@ -32,4 +35,6 @@ type EmbeddingResponse struct {
 	Embeddings []Embedding `json:"embeddings"`
 	Usage      *TokenUsage `json:"usage,omitempty"`
 	Cost       *float64    `json:"cost,omitempty"`
+	// CostBreakdown splits Cost by what was billed, see CompletionResponse.CostBreakdown
+	CostBreakdown *CostBreakdown `json:"costBreakdown,omitempty"`
 }
@@ -0,0 +1,329 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// encryptionVersion is the first byte of every sealed payload, so the format can evolve
+const encryptionVersion byte = 1
+
+// ErrDecryptionFailed is returned when a payload cannot be decrypted, e.g. because it was
+// tampered with or sealed with another key
+var ErrDecryptionFailed = errors.New("failed to decrypt payload")
+
+// KeyProvider supplies the AES keys used to encrypt persisted data, e.g. from a KMS or a
+// secret manager. Keys are identified by an ID stored with each payload so keys can be
+// rotated without re-encrypting existing data.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the key new payloads are encrypted with
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the key with the given ID, for decrypting existing payloads
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider with a fixed set of keys
+type StaticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+var _ KeyProvider = (*StaticKeyProvider)(nil)
+
+// NewStaticKeyProvider creates a key provider that encrypts with the key currentID. keys maps
+// IDs to 16, 24 or 32 byte AES keys and must contain currentID; older keys are kept for
+// decryption only.
+func NewStaticKeyProvider(currentID string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	if _, ok := keys[currentID]; !ok {
+		return nil, NewValidationError("currentID", "no key with this ID", currentID)
+	}
+	for id, key := range keys {
+		if err := checkKeySize(key); err != nil {
+			return nil, NewValidationError("keys", err.Error(), id)
+		}
+	}
+	return &StaticKeyProvider{current: currentID, keys: keys}, nil
+}
+
+func (p *StaticKeyProvider) CurrentKey(ctx context.Context) (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *StaticKeyProvider) Key(ctx context.Context, id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// Encryptor encrypts data at rest with AES-GCM. A sealed payload holds a version byte, the
+// key ID, the nonce and the ciphertext, so it can be decrypted after the current key changes.
+type Encryptor struct {
+	keys KeyProvider
+}
+
+// NewEncryptor creates an encryptor with keys from the provider
+func NewEncryptor(keys KeyProvider) (*Encryptor, error) {
+	if keys == nil {
+		return nil, NewValidationError("keys", "cannot be nil", nil)
+	}
+	return &Encryptor{keys: keys}, nil
+}
+
+// Seal encrypts plaintext with the current key
+func (e *Encryptor) Seal(ctx context.Context, plaintext []byte) ([]byte, error) {
+	id, key, err := e.keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %w", err)
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("encryption key ID %q is longer than 255 bytes", id)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, 2+len(id)+gcm.NonceSize())
+	header = append(header, encryptionVersion, byte(len(id)))
+	header = append(header, id...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// The version and key ID are authenticated so they cannot be swapped
+	sealed := append(header, nonce...)
+	return gcm.Seal(sealed, nonce, plaintext, header), nil
+}
+
+// Open decrypts a payload sealed by Seal
+func (e *Encryptor) Open(ctx context.Context, sealed []byte) ([]byte, error) {
+	if len(sealed) < 2 || sealed[0] != encryptionVersion {
+		return nil, ErrDecryptionFailed
+	}
+	idEnd := 2 + int(sealed[1])
+	if len(sealed) < idEnd {
+		return nil, ErrDecryptionFailed
+	}
+	header, id := sealed[:idEnd], string(sealed[2:idEnd])
+
+	key, err := e.keys.Key(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < idEnd+gcm.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	nonce, ciphertext := sealed[idEnd:idEnd+gcm.NonceSize()], sealed[idEnd+gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// SealJSON encodes v as JSON and encrypts it
+func (e *Encryptor) SealJSON(ctx context.Context, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return e.Seal(ctx, data)
+}
+
+// OpenJSON decrypts a payload sealed by SealJSON into v
+func (e *Encryptor) OpenJSON(ctx context.Context, sealed []byte, v any) error {
+	data, err := e.Open(ctx, sealed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func checkKeySize(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return fmt.Errorf("AES key must be 16, 24 or 32 bytes, got %d", len(key))
+}
+
+// EncryptedPayloadSink is a PayloadSink that encrypts payload records before handing them to
+// write, so prompts and responses persisted by the logging middleware are encrypted at rest.
+// Records are read back with Encryptor.OpenJSON.
+type EncryptedPayloadSink struct {
+	encryptor *Encryptor
+	write     func(ctx context.Context, sealed []byte) error
+}
+
+var _ PayloadSink = (*EncryptedPayloadSink)(nil)
+
+// NewEncryptedPayloadSink creates a sink that stores records encrypted with encryptor by
+// calling write, e.g. to put an object in a bucket
+func NewEncryptedPayloadSink(encryptor *Encryptor, write func(ctx context.Context, sealed []byte) error) *EncryptedPayloadSink {
+	return &EncryptedPayloadSink{encryptor: encryptor, write: write}
+}
+
+func (s *EncryptedPayloadSink) StorePayload(ctx context.Context, record *PayloadRecord) error {
+	sealed, err := s.encryptor.SealJSON(ctx, record)
+	if err != nil {
+		return err
+	}
+	return s.write(ctx, sealed)
+}
+
+// BlobStore stores opaque values by key, e.g. in Redis or an object bucket. It backs the
+// encrypted stores, which only hand it sealed payloads.
+type BlobStore interface {
+	// Get returns the value stored under key, or false when there is none or it expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl; a zero ttl keeps it until it is evicted
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// EncryptedCacheStore is a CacheStore that keeps responses encrypted in a BlobStore, so the
+// prompts and responses of a shared response cache are encrypted at rest. Keys are already
+// hashes of the requests, see CacheKey.
+type EncryptedCacheStore struct {
+	encryptor *Encryptor
+	blobs     BlobStore
+}
+
+var _ CacheStore = (*EncryptedCacheStore)(nil)
+
+// NewEncryptedCacheStore creates a cache store encrypting responses with encryptor in blobs
+func NewEncryptedCacheStore(encryptor *Encryptor, blobs BlobStore) *EncryptedCacheStore {
+	return &EncryptedCacheStore{encryptor: encryptor, blobs: blobs}
+}
+
+func (s *EncryptedCacheStore) Get(ctx context.Context, key string) (*CompletionResponse, bool, error) {
+	sealed, ok, err := s.blobs.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var resp CompletionResponse
+	if err := s.encryptor.OpenJSON(ctx, sealed, &resp); err != nil {
+		return nil, false, err
+	}
+	return &resp, true, nil
+}
+
+func (s *EncryptedCacheStore) Set(ctx context.Context, key string, resp *CompletionResponse, ttl time.Duration) error {
+	sealed, err := s.encryptor.SealJSON(ctx, resp)
+	if err != nil {
+		return err
+	}
+	return s.blobs.Set(ctx, key, sealed, ttl)
+}
+
+// EncryptedStreamStore is a StreamStore that encrypts the data of events before appending
+// them to another store and decrypts them when they are read, so persisted streams are
+// encrypted at rest. Cursors and chunk types are kept in clear for the wrapped store.
+type EncryptedStreamStore struct {
+	StreamStore
+	encryptor *Encryptor
+}
+
+var _ StreamStore = (*EncryptedStreamStore)(nil)
+
+// NewEncryptedStreamStore wraps store to encrypt the events of its streams with encryptor
+func NewEncryptedStreamStore(store StreamStore, encryptor *Encryptor) *EncryptedStreamStore {
+	return &EncryptedStreamStore{StreamStore: store, encryptor: encryptor}
+}
+
+func (s *EncryptedStreamStore) Append(ctx context.Context, id string, events ...StreamEvent) error {
+	sealed := make([]StreamEvent, len(events))
+	for i, event := range events {
+		data, err := s.encryptor.Seal(ctx, event.Data)
+		if err != nil {
+			return err
+		}
+		// The sealed data is stored as a base64 JSON string, so the event stays valid JSON
+		event.Data, err = json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		sealed[i] = event
+	}
+	return s.StreamStore.Append(ctx, id, sealed...)
+}
+
+func (s *EncryptedStreamStore) Read(ctx context.Context, id string, cursor int64) ([]StreamEvent, bool, error) {
+	events, complete, err := s.StreamStore.Read(ctx, id, cursor)
+	if err != nil {
+		return nil, false, err
+	}
+	opened := make([]StreamEvent, len(events))
+	for i, event := range events {
+		var sealed []byte
+		if err := json.Unmarshal(event.Data, &sealed); err != nil {
+			return nil, false, ErrDecryptionFailed
+		}
+		if event.Data, err = s.encryptor.Open(ctx, sealed); err != nil {
+			return nil, false, err
+		}
+		opened[i] = event
+	}
+	return opened, complete, nil
+}
+
+// EncryptedTranscriptStore keeps transcripts encrypted in a BlobStore, e.g. the sessions of a
+// chat application saved for later, which are restored into a Conversation with Append and
+// SetMetadata.
+type EncryptedTranscriptStore struct {
+	encryptor *Encryptor
+	blobs     BlobStore
+}
+
+// NewEncryptedTranscriptStore creates a transcript store encrypting transcripts with encryptor
+// in blobs
+func NewEncryptedTranscriptStore(encryptor *Encryptor, blobs BlobStore) *EncryptedTranscriptStore {
+	return &EncryptedTranscriptStore{encryptor: encryptor, blobs: blobs}
+}
+
+// Save stores transcript under id, replacing the transcript stored before
+func (s *EncryptedTranscriptStore) Save(ctx context.Context, id string, transcript *Transcript) error {
+	sealed, err := s.encryptor.SealJSON(ctx, transcript)
+	if err != nil {
+		return err
+	}
+	return s.blobs.Set(ctx, id, sealed, 0)
+}
+
+// Load returns the transcript stored under id, or false when there is none
+func (s *EncryptedTranscriptStore) Load(ctx context.Context, id string) (*Transcript, bool, error) {
+	sealed, ok, err := s.blobs.Get(ctx, id)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var transcript Transcript
+	if err := s.encryptor.OpenJSON(ctx, sealed, &transcript); err != nil {
+		return nil, false, err
+	}
+	return &transcript, true, nil
+}
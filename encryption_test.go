@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptor_SealOpen(t *testing.T) {
+	ctx := context.Background()
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 16)
+
+	oldKeys, err := NewStaticKeyProvider("k1", map[string][]byte{"k1": oldKey})
+	require.NoError(t, err)
+	oldEncryptor, err := NewEncryptor(oldKeys)
+	require.NoError(t, err)
+
+	sealed, err := oldEncryptor.Seal(ctx, []byte("secret prompt"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "secret prompt")
+
+	// After rotation new payloads use the new key and old payloads still open
+	rotated, err := NewStaticKeyProvider("k2", map[string][]byte{"k1": oldKey, "k2": newKey})
+	require.NoError(t, err)
+	encryptor, err := NewEncryptor(rotated)
+	require.NoError(t, err)
+
+	plaintext, err := encryptor.Open(ctx, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "secret prompt", string(plaintext))
+
+	resealed, err := encryptor.Seal(ctx, []byte("secret prompt"))
+	require.NoError(t, err)
+	_, err = oldEncryptor.Open(ctx, resealed)
+	assert.ErrorContains(t, err, `unknown encryption key "k2"`)
+
+	// Tampering with the ciphertext or the key ID is detected
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	_, err = encryptor.Open(ctx, tampered)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	_, err = encryptor.Open(ctx, []byte{9, 0})
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+}
+
+func TestNewStaticKeyProvider_Validation(t *testing.T) {
+	_, err := NewStaticKeyProvider("missing", map[string][]byte{"k1": make([]byte, 32)})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	_, err = NewStaticKeyProvider("k1", map[string][]byte{"k1": make([]byte, 10)})
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestEncryptedPayloadSink(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewStaticKeyProvider("k1", map[string][]byte{"k1": make([]byte, 32)})
+	require.NoError(t, err)
+	encryptor, err := NewEncryptor(keys)
+	require.NoError(t, err)
+
+	var stored [][]byte
+	sink := NewEncryptedPayloadSink(encryptor, func(ctx context.Context, sealed []byte) error {
+		stored = append(stored, sealed)
+		return nil
+	})
+	model := NewLoggingCompletionModel("test", &stubCompletionModel{output: "the answer"}, nil,
+		WithPayloadSampling(1), WithPayloadSink(sink))
+
+	_, err = model.Complete(ctx, &CompletionRequest{Instructions: "the question"})
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.NotContains(t, string(stored[0]), "the answer")
+
+	var record PayloadRecord
+	require.NoError(t, encryptor.OpenJSON(ctx, stored[0], &record))
+	assert.Equal(t, "the question", record.Request.Instructions)
+	assert.Equal(t, "the answer", record.Response.Output)
+}
+
+// mapBlobStore is a BlobStore recording the values handed to it
+type mapBlobStore map[string][]byte
+
+func (s mapBlobStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, ok := s[key]
+	return value, ok, nil
+}
+
+func (s mapBlobStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s[key] = value
+	return nil
+}
+
+func testEncryptor(t *testing.T) *Encryptor {
+	keys, err := NewStaticKeyProvider("k1", map[string][]byte{"k1": make([]byte, 32)})
+	require.NoError(t, err)
+	encryptor, err := NewEncryptor(keys)
+	require.NoError(t, err)
+	return encryptor
+}
+
+func TestEncryptedCacheStore(t *testing.T) {
+	ctx := context.Background()
+	blobs := mapBlobStore{}
+	store := NewEncryptedCacheStore(testEncryptor(t), blobs)
+
+	require.NoError(t, store.Set(ctx, "key", &CompletionResponse{Output: "the answer"}, time.Minute))
+	assert.NotContains(t, string(blobs["key"]), "the answer")
+
+	resp, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "the answer", resp.Output)
+
+	_, ok, err = store.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestEncryptedStreamStore(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryStreamStore(time.Minute)
+	store := NewEncryptedStreamStore(inner, testEncryptor(t))
+
+	require.NoError(t, store.Create(ctx, "s1"))
+	event, err := NewStreamEvent(1, StreamTextChunk{Text: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ctx, "s1", event))
+	require.NoError(t, store.Close(ctx, "s1"))
+
+	raw, _, err := inner.Read(ctx, "s1", 0)
+	require.NoError(t, err)
+	require.Len(t, raw, 1)
+	assert.NotContains(t, string(raw[0].Data), "secret")
+
+	events, complete, err := store.Read(ctx, "s1", 0)
+	require.NoError(t, err)
+	assert.True(t, complete)
+	require.Len(t, events, 1)
+	chunk, err := events[0].Chunk()
+	require.NoError(t, err)
+	assert.Equal(t, StreamTextChunk{Text: "secret"}, chunk)
+}
+
+func TestEncryptedTranscriptStore(t *testing.T) {
+	ctx := context.Background()
+	blobs := mapBlobStore{}
+	store := NewEncryptedTranscriptStore(testEncryptor(t), blobs)
+
+	transcript := &Transcript{Title: "Support", Messages: []*ModelMessage{{Role: RoleUser, Content: "my password is hunter2"}}}
+	require.NoError(t, store.Save(ctx, "session-1", transcript))
+	assert.NotContains(t, string(blobs["session-1"]), "hunter2")
+
+	loaded, ok, err := store.Load(ctx, "session-1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "Support", loaded.Title)
+	assert.Equal(t, "my password is hunter2", loaded.Messages[0].Content)
+}
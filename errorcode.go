@@ -0,0 +1,101 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrorCode is a provider-independent classification of an error, so that retry and failover
+// policies can be written once for every provider
+type ErrorCode string
+
+const (
+	// ErrCodeUnknown is any error that has no normalized code
+	ErrCodeUnknown ErrorCode = "unknown"
+	// ErrCodeRateLimited means the provider rejected the request for exceeding a rate limit or quota
+	ErrCodeRateLimited ErrorCode = "rate_limited"
+	// ErrCodeContextLength means the request does not fit the model's context window
+	ErrCodeContextLength ErrorCode = "context_length"
+	// ErrCodeAuth means the API key is missing, invalid or not allowed to use the model
+	ErrCodeAuth ErrorCode = "auth"
+	// ErrCodeContentFilter means the request or the output was blocked, or refused, for safety reasons
+	ErrCodeContentFilter ErrorCode = "content_filter"
+	// ErrCodeOverloaded means the provider is temporarily unable to serve the request
+	ErrCodeOverloaded ErrorCode = "overloaded"
+)
+
+// statusErrorCodes maps the HTTP status codes that identify an error on their own
+var statusErrorCodes = map[int]ErrorCode{
+	http.StatusUnauthorized:          ErrCodeAuth,
+	http.StatusForbidden:             ErrCodeAuth,
+	http.StatusRequestEntityTooLarge: ErrCodeContextLength,
+	http.StatusTooManyRequests:       ErrCodeRateLimited,
+	http.StatusServiceUnavailable:    ErrCodeOverloaded,
+	529:                              ErrCodeOverloaded, // Anthropic overloaded_error
+}
+
+// messageErrorCodes maps phrases of provider error messages, for errors whose status code is
+// ambiguous such as 400 Bad Request
+var messageErrorCodes = []struct {
+	phrase string
+	code   ErrorCode
+}{
+	{"context_length_exceeded", ErrCodeContextLength},
+	{"maximum context length", ErrCodeContextLength},
+	{"prompt is too long", ErrCodeContextLength},
+	{"input is too long", ErrCodeContextLength},
+	{"exceeds the context window", ErrCodeContextLength},
+	{"content_filter", ErrCodeContentFilter},
+	{"content management policy", ErrCodeContentFilter},
+	{"blocked due to safety", ErrCodeContentFilter},
+	{"overloaded", ErrCodeOverloaded},
+}
+
+// ClassifyError returns the normalized code of err, ErrCodeUnknown when err is nil or has no
+// code. Errors of this package, such as ContextWindowError, are classified by type; API errors
+// by their status code, then by the phrases providers use in their messages.
+func ClassifyError(err error) ErrorCode {
+	switch {
+	case err == nil:
+		return ErrCodeUnknown
+	case errors.Is(err, ErrContextWindowExceeded):
+		return ErrCodeContextLength
+	case errors.Is(err, ErrRefusal):
+		return ErrCodeContentFilter
+	case errors.Is(err, ErrAPIKeyEmpty):
+		return ErrCodeAuth
+	}
+
+	statusCode, _, ok := requestErrorStatus(err)
+	if !ok {
+		return ErrCodeUnknown
+	}
+	if code, ok := statusErrorCodes[statusCode]; ok {
+		return code
+	}
+	message := strings.ToLower(err.Error())
+	for _, entry := range messageErrorCodes {
+		if strings.Contains(message, entry.phrase) {
+			return entry.code
+		}
+	}
+	return ErrCodeUnknown
+}
+
+// HasErrorCode returns a predicate reporting whether an error has one of codes, e.g. for
+// WithFailoverOn(HasErrorCode(ErrCodeRateLimited, ErrCodeOverloaded))
+func HasErrorCode(codes ...ErrorCode) func(err error) bool {
+	return func(err error) bool {
+		code := ClassifyError(err)
+		for _, c := range codes {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+}
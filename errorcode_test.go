@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{name: "nil", err: nil, want: ErrCodeUnknown},
+		{name: "rate limit", err: NewRequestError("openai", http.StatusTooManyRequests, "slow down", nil), want: ErrCodeRateLimited},
+		{name: "unauthorized", err: NewRequestError("anthropic", http.StatusUnauthorized, "invalid x-api-key", nil), want: ErrCodeAuth},
+		{name: "forbidden", err: NewRequestError("vertex", http.StatusForbidden, "permission denied", nil), want: ErrCodeAuth},
+		{name: "missing API key", err: fmt.Errorf("create provider: %w", ErrAPIKeyEmpty), want: ErrCodeAuth},
+		{name: "service unavailable", err: NewRequestError("openai", http.StatusServiceUnavailable, "unavailable", nil), want: ErrCodeOverloaded},
+		{name: "anthropic overloaded", err: NewRequestError("anthropic", 529, "overloaded_error", nil), want: ErrCodeOverloaded},
+		{name: "context window", err: &ContextWindowError{Model: "m", Tokens: 200, ContextWindow: 100}, want: ErrCodeContextLength},
+		{name: "context length message", err: NewRequestError("openai", http.StatusBadRequest, "failed to complete",
+			errors.New("This model's maximum context length is 128000 tokens")), want: ErrCodeContextLength},
+		{name: "prompt too long", err: NewRequestError("anthropic", http.StatusBadRequest, "prompt is too long: 210000 tokens > 200000 maximum", nil), want: ErrCodeContextLength},
+		{name: "content filter message", err: NewRequestError("azure", http.StatusBadRequest, "code: content_filter", nil), want: ErrCodeContentFilter},
+		{name: "refusal", err: fmt.Errorf("attempt 1: %w", ErrRefusal), want: ErrCodeContentFilter},
+		{name: "bad request", err: NewRequestError("openai", http.StatusBadRequest, "invalid temperature", nil), want: ErrCodeUnknown},
+		{name: "server error", err: NewRequestError("openai", http.StatusInternalServerError, "oops", nil), want: ErrCodeUnknown},
+		{name: "plain error", err: errors.New("overloaded"), want: ErrCodeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ClassifyError(tt.err))
+		})
+	}
+}
+
+func TestHasErrorCode(t *testing.T) {
+	transient := HasErrorCode(ErrCodeRateLimited, ErrCodeOverloaded)
+	assert.True(t, transient(NewRequestError("openai", http.StatusTooManyRequests, "slow down", nil)))
+	assert.True(t, transient(NewRequestError("anthropic", 529, "overloaded", nil)))
+	assert.False(t, transient(NewRequestError("openai", http.StatusUnauthorized, "invalid key", nil)))
+	assert.False(t, transient(nil))
+}
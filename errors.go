@@ -6,6 +6,7 @@ package llm
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Standard error types for better error handling
@@ -19,12 +20,18 @@ var (
 	// ErrAPIVersionEmpty is returned when API version is empty
 	ErrAPIVersionEmpty = errors.New("API version cannot be empty")
 
+	// ErrProjectEmpty is returned when a Google Cloud project is required but not set
+	ErrProjectEmpty = errors.New("project cannot be empty")
+
 	// ErrInvalidRequest is returned when request validation fails
 	ErrInvalidRequest = errors.New("invalid request")
 
 	// ErrEmptyContent is returned when content is empty
 	ErrEmptyContent = errors.New("content cannot be empty")
 
+	// ErrEmptyOutput is matched by an EmptyOutputError when model output fails its OutputChecks
+	ErrEmptyOutput = errors.New("model output is empty")
+
 	ErrEmptyInstructions = errors.New("instructions cannot be empty")
 
 	// ErrInvalidModel is returned when model is invalid
@@ -35,6 +42,49 @@ var (
 
 	// ErrTimeout is returned when operation times out
 	ErrTimeout = errors.New("operation timeout")
+
+	// ErrShuttingDown is returned when a request is made to, or canceled by, a provider that is shutting down
+	ErrShuttingDown = errors.New("provider is shutting down")
+
+	// ErrContextWindowExceeded is matched by a ContextWindowError when a request does not fit the model's context window
+	ErrContextWindowExceeded = errors.New("context window exceeded")
+
+	// ErrMaxStepsExceeded is returned when an agent does not finish within its maximum number of steps
+	ErrMaxStepsExceeded = errors.New("agent exceeded maximum number of steps")
+
+	// ErrRefusal is returned by a JitterCompletionModel when the model declined to answer
+	ErrRefusal = errors.New("model refused to answer")
+
+	// ErrCheckpointNotFound is returned when rolling a conversation back to a checkpoint that was not saved
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+	// ErrModelDeprecated is matched by a ModelDeprecatedError when a deprecated model is used in strict mode
+	ErrModelDeprecated = errors.New("model is deprecated")
+
+	// ErrInvalidPrice is returned when a price in a model list is malformed or negative
+	ErrInvalidPrice = errors.New("invalid price")
+
+	// ErrPricingMissing is matched by a MissingPricingWarning when a model without pricing is used in strict mode
+	ErrPricingMissing = errors.New("model pricing is missing")
+
+	// ErrBudgetExceeded is matched by a BudgetExceededError when a request would exceed a spending cap
+	ErrBudgetExceeded = errors.New("budget exceeded")
+
+	// ErrCurrencyMismatch is matched by a CurrencyMismatchError when a request to a model priced in
+	// another currency would be counted against a spending cap
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+
+	// ErrPolicyViolation is matched by a PolicyViolation when a policy refuses a model
+	ErrPolicyViolation = errors.New("policy violation")
+
+	// ErrJobFailed is returned when a provider job, such as an ImageJob, failed
+	ErrJobFailed = errors.New("job failed")
+
+	// ErrJobCanceled is returned when a provider job, such as an ImageJob, was canceled
+	ErrJobCanceled = errors.New("job canceled")
+
+	// ErrUnsupportedLanguage is returned when the output language of a request cannot be validated
+	ErrUnsupportedLanguage = errors.New("unsupported output language")
 )
 
 // ValidationError represents a validation error with field details
@@ -66,6 +116,8 @@ type RequestError struct {
 	StatusCode int
 	Message    string
 	Err        error
+	// RetryAfter is the delay the provider asked for before retrying, if any
+	RetryAfter time.Duration
 }
 
 func (e *RequestError) Error() string {
@@ -57,6 +57,8 @@ func main() {
 			fmt.Print(c.Reasoning)
 		case llm.StreamTextChunk:
 			fmt.Print(c.Text)
+		case llm.StreamErrorChunk:
+			log.Fatalf("Stream failed: %v", c.Err)
 		}
 	}
 	fmt.Println()
@@ -54,6 +54,8 @@ func main() {
 		switch c := chunk.(type) {
 		case llm.StreamTextChunk:
 			fmt.Print(c.Text)
+		case llm.StreamErrorChunk:
+			log.Fatalf("Stream failed: %v", c.Err)
 		}
 	}
 	fmt.Println()
@@ -0,0 +1,48 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+// LongContextPricing is the pricing of requests with long inputs, which providers bill
+// entirely at higher rates once the input exceeds a threshold
+type LongContextPricing struct {
+	// InputTokens is the number of input tokens, including cached tokens, above which Pricing
+	// applies
+	InputTokens int64        `json:"inputTokens"`
+	Pricing     ModelPricing `json:"pricing"`
+}
+
+// WithExtendedContext enables the extended context window of the model, such as the 1M token
+// window of Claude Sonnet, which Anthropic serves behind a beta header. Models serving their
+// full window without a flag, such as GPT-4.1, have it as their ContextWindow. Requests fail with an UnsupportedCapabilityError for
+// models without an extended window, and are checked against it with WithContextWindowCheck.
+func WithExtendedContext(enabled bool) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.ExtendedContext = &enabled
+	}
+}
+
+// UsesExtendedContext reports whether the extended context window is enabled
+func (o *CompletionOptions) UsesExtendedContext() bool {
+	return o != nil && o.ExtendedContext != nil && *o.ExtendedContext
+}
+
+// ContextWindowFor returns the context window of the model for requests with opts: the
+// extended window when it is enabled and the model has one
+func (m *ModelInfo) ContextWindowFor(opts *CompletionOptions) int {
+	if opts.UsesExtendedContext() && m.ExtendedContextWindow > 0 {
+		return m.ExtendedContextWindow
+	}
+	return m.ContextWindow
+}
+
+// ForInputTokens returns the model priced for a request of inputTokens, or the model itself
+// when the input does not reach its long context pricing
+func (m *ModelInfo) ForInputTokens(inputTokens int64) *ModelInfo {
+	if m.LongContextPricing == nil || inputTokens <= m.LongContextPricing.InputTokens {
+		return m
+	}
+	priced := *m
+	priced.Pricing = m.LongContextPricing.Pricing
+	return &priced
+}
@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelInfo_ContextWindowFor(t *testing.T) {
+	info := &ModelInfo{ContextWindow: 200000, ExtendedContextWindow: 1000000}
+	assert.Equal(t, 200000, info.ContextWindowFor(nil))
+	assert.Equal(t, 200000, info.ContextWindowFor(ApplyCompletionOptions([]CompletionOption{WithExtendedContext(false)})))
+	assert.Equal(t, 1000000, info.ContextWindowFor(ApplyCompletionOptions([]CompletionOption{WithExtendedContext(true)})))
+
+	info.ExtendedContextWindow = 0
+	assert.Equal(t, 200000, info.ContextWindowFor(ApplyCompletionOptions([]CompletionOption{WithExtendedContext(true)})))
+}
+
+func TestModelInfo_ForInputTokens(t *testing.T) {
+	info := &ModelInfo{
+		ID:      "claude-sonnet-4-5",
+		Pricing: ModelPricing{Prompt: NewPrice(3), Completion: NewPrice(15)},
+		LongContextPricing: &LongContextPricing{
+			InputTokens: 200000,
+			Pricing:     ModelPricing{Prompt: NewPrice(6), Completion: NewPrice(22.5)},
+		},
+	}
+	assert.Same(t, info, info.ForInputTokens(200000))
+	long := info.ForInputTokens(200001)
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(6), Completion: NewPrice(22.5)}, long.Pricing)
+	assert.Equal(t, "claude-sonnet-4-5", long.ID)
+	assert.Equal(t, 3.0, info.Pricing.Prompt.Float64(), "the model is not modified")
+
+	info.LongContextPricing = nil
+	assert.Same(t, info, info.ForInputTokens(1000000))
+}
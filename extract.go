@@ -0,0 +1,200 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// extractInstructions asks the model to cite the source of every field it extracts
+const extractInstructions = `Extract the requested fields from the document given by the user.
+Respond only with a JSON object with two properties:
+- "data": the extracted fields.
+- "spans": for every extracted field, an object with "field" (the JSON path of the field, e.g. "name" or "items.0.price"), "text" (the exact text of the document the value was taken from), "start" and "end" (the 0-based character offsets of that text in the document, end exclusive).
+Only cite text that appears verbatim in the document.`
+
+// Span is the part of the source document a field was extracted from
+type Span struct {
+	// Field is the JSON path of the extracted field, such as "name" or "items.0.price"
+	Field string `json:"field"`
+	// Start and End are character (rune) offsets into the document, End exclusive
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Text is the cited text of the document
+	Text string `json:"text"`
+	// Grounded reports whether Text was found in the document. Offsets cited by the model are
+	// corrected to the nearest occurrence of Text when they do not match.
+	Grounded bool `json:"grounded"`
+}
+
+// Extraction is the result of Extract
+type Extraction[T any] struct {
+	Data  T           `json:"data"`
+	Spans []*Span     `json:"spans"`
+	Usage *TokenUsage `json:"usage,omitempty"`
+	Cost  *float64    `json:"cost,omitempty"`
+}
+
+// FieldSpans returns the spans cited for field
+func (e *Extraction[T]) FieldSpans(field string) []*Span {
+	var spans []*Span
+	for _, span := range e.Spans {
+		if span.Field == field {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Grounded reports whether every span was found in the document
+func (e *Extraction[T]) Grounded() bool {
+	for _, span := range e.Spans {
+		if !span.Grounded {
+			return false
+		}
+	}
+	return true
+}
+
+// Extract extracts a T from document with a model of the provider, along with the span of the
+// document each field was taken from. The model is asked to cite character offsets, which are
+// validated against the document so callers can check every value against its source. opts
+// are applied to the model before the options Extract sets for structured output.
+func Extract[T any](ctx context.Context, provider ModelProvider, model string, document string, opts ...CompletionOption) (*Extraction[T], error) {
+	if provider == nil {
+		return nil, NewValidationError("provider", "cannot be nil", nil)
+	}
+
+	dataSchema, err := extractDataSchema[T]()
+	if err != nil {
+		return nil, err
+	}
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"data": dataSchema,
+			"spans": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"field": map[string]any{"type": "string"},
+						"start": map[string]any{"type": "integer"},
+						"end":   map[string]any{"type": "integer"},
+						"text":  map[string]any{"type": "string"},
+					},
+					"required":             []string{"field", "start", "end", "text"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"data", "spans"},
+		"additionalProperties": false,
+	}
+	opts = append(opts,
+		WithResponseFormat(ResponseFormatJsonSchema),
+		WithJSONSchema(schema),
+	)
+	completionModel, err := provider.NewCompletionModel(model, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := completionModel.Complete(ctx, &CompletionRequest{
+		Instructions: extractInstructions,
+		Messages:     []*ModelMessage{{Role: RoleUser, Content: document}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data  T       `json:"data"`
+		Spans []*Span `json:"spans"`
+	}
+	if err := unmarshalJSONObject(resp.Output, &result); err != nil {
+		return nil, NewResponseError(provider.Name(), "failed to parse extraction", err)
+	}
+
+	runes := []rune(document)
+	for _, span := range result.Spans {
+		groundSpan(runes, span)
+	}
+
+	return &Extraction[T]{
+		Data:  result.Data,
+		Spans: result.Spans,
+		Usage: resp.Usage,
+		Cost:  resp.Cost,
+	}, nil
+}
+
+// extractDataSchema returns the JSON schema of T as a map, without the document keywords that
+// are not allowed in a nested schema
+func extractDataSchema[T any]() (map[string]any, error) {
+	data, err := json.Marshal(GenerateSchema[T]())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate schema: %w", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to generate schema: %w", err)
+	}
+	delete(schema, "$schema")
+	delete(schema, "$id")
+	return schema, nil
+}
+
+// groundSpan checks span against the document. When the cited offsets do not match Text, they
+// are moved to the occurrence of Text closest to Start; when Text does not occur, the span is
+// left as cited and marked ungrounded.
+func groundSpan(document []rune, span *Span) {
+	span.Grounded = false
+	if span.Text == "" {
+		return
+	}
+	if span.Start >= 0 && span.Start <= span.End && span.End <= len(document) &&
+		string(document[span.Start:span.End]) == span.Text {
+		span.Grounded = true
+		return
+	}
+
+	text := []rune(span.Text)
+	best := -1
+	for i := 0; i+len(text) <= len(document); i++ {
+		if !runesHavePrefix(document[i:], text) {
+			continue
+		}
+		if best < 0 || abs(i-span.Start) < abs(best-span.Start) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return
+	}
+	span.Start = best
+	span.End = best + len(text)
+	span.Grounded = true
+}
+
+func runesHavePrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
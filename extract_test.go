@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type extractedInvoice struct {
+	Number   string  `json:"number"`
+	Customer string  `json:"customer"`
+	Total    float64 `json:"total"`
+}
+
+func TestExtract(t *testing.T) {
+	document := "Invoice № 2024-17 for Zoë Müller. Total due: 99.50 EUR. Customer: Zoë Müller."
+
+	t.Run("grounds spans", func(t *testing.T) {
+		provider := &fixedResponseProvider{
+			DefaultModelProvider: NewDefaultModelProvider("test", nil),
+			resp: &CompletionResponse{
+				Output: `{"data":{"number":"2024-17","customer":"Zoë Müller","total":99.5},"spans":[` +
+					`{"field":"number","start":10,"end":17,"text":"2024-17"},` +
+					`{"field":"customer","start":62,"end":72,"text":"Zoë Müller"},` +
+					`{"field":"total","start":40,"end":45,"text":"99.50"},` +
+					`{"field":"total","start":0,"end":5,"text":"100.00"}]}`,
+				Usage: &TokenUsage{TotalInputTokens: 10},
+			},
+		}
+
+		result, err := Extract[extractedInvoice](context.Background(), provider, "m", document)
+		require.NoError(t, err)
+
+		assert.Equal(t, extractedInvoice{Number: "2024-17", Customer: "Zoë Müller", Total: 99.5}, result.Data)
+		assert.Equal(t, int64(10), result.Usage.TotalInputTokens)
+		require.Len(t, result.Spans, 4)
+
+		// Offsets are runes, so the cited span matches despite the multi-byte "№"
+		assert.Equal(t, &Span{Field: "number", Start: 10, End: 17, Text: "2024-17", Grounded: true}, result.Spans[0])
+		// The cited text occurs twice; the occurrence closest to the cited offsets wins
+		assert.Equal(t, &Span{Field: "customer", Start: 66, End: 76, Text: "Zoë Müller", Grounded: true}, result.Spans[1])
+		// Wrong offsets are moved to the cited text
+		assert.Equal(t, &Span{Field: "total", Start: 45, End: 50, Text: "99.50", Grounded: true}, result.Spans[2])
+		// Text that is not in the document is kept as cited
+		assert.Equal(t, &Span{Field: "total", Start: 0, End: 5, Text: "100.00", Grounded: false}, result.Spans[3])
+
+		assert.Len(t, result.FieldSpans("total"), 2)
+		assert.False(t, result.Grounded())
+
+		assert.Equal(t, ResponseFormatJsonSchema, *provider.options.ResponseFormat)
+		schema := provider.options.JSONSchema.(map[string]any)
+		data := schema["properties"].(map[string]any)["data"].(map[string]any)
+		assert.NotContains(t, data, "$schema")
+		assert.Contains(t, data["properties"], "customer")
+		assert.Equal(t, document, provider.req.Messages[0].Content)
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		provider := &fixedResponseProvider{
+			DefaultModelProvider: NewDefaultModelProvider("test", nil),
+			resp:                 &CompletionResponse{Output: "I could not find an invoice."},
+		}
+
+		_, err := Extract[extractedInvoice](context.Background(), provider, "m", document)
+		var respErr *ResponseError
+		assert.ErrorAs(t, err, &respErr)
+	})
+}
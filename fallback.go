@@ -0,0 +1,365 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// FallbackEmbeddingModel tries an ordered chain of embedding models and returns the first
+// successful response, e.g. a hosted model followed by a local ONNX model that keeps the
+// application working, degraded, while the provider is down. Embeddings of different models
+// live in different vector spaces, so callers should only compare vectors from the same model.
+type FallbackEmbeddingModel struct {
+	models []EmbeddingModel
+}
+
+var _ EmbeddingModel = (*FallbackEmbeddingModel)(nil)
+
+// NewFallbackEmbeddingModel creates an embedding model that fails over along models in order
+func NewFallbackEmbeddingModel(models ...EmbeddingModel) (*FallbackEmbeddingModel, error) {
+	if len(models) == 0 {
+		return nil, NewValidationError("models", "must contain at least one model", nil)
+	}
+	return &FallbackEmbeddingModel{models: models}, nil
+}
+
+// GenerateEmbeddings calls each model in turn until one succeeds. Validation errors and
+// context cancellation are returned immediately; other errors are joined if every model fails.
+// The request model is left to each model's default.
+func (m *FallbackEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if req == nil {
+		return nil, NewValidationError("request", "cannot be nil", nil)
+	}
+
+	var errs []error
+	for _, model := range m.models {
+		attempt := *req
+		attempt.Model = ""
+		resp, err := model.GenerateEmbeddings(ctx, &attempt)
+		if err == nil {
+			return resp, nil
+		}
+		if !shouldFailover(ctx, err) {
+			return nil, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// shouldFailover reports whether another model may succeed where err occurred
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var validationErr *ValidationError
+	return !errors.As(err, &validationErr)
+}
+
+// FallbackOption is a functional option for configuring a FallbackCompletionModel
+type FallbackOption func(*FallbackOptions)
+
+// FallbackOptions contains configuration options for a FallbackCompletionModel
+type FallbackOptions struct {
+	// FirstTokenDeadline is how long a stream may take to send its first chunk before the
+	// request is reissued to the next model. Zero waits indefinitely.
+	FirstTokenDeadline time.Duration
+	// Names identify the models of the chain, by position, in ResponseMetadata.ServedBy
+	Names []string
+	// Timeouts bound the attempts of the models of the chain, by position. Zero or missing
+	// entries leave the attempt unbounded.
+	Timeouts []time.Duration
+	// FailoverOn reports whether an error fails over to the next model. When nil
+	// IsRetryableError does, so rate limits and server errors fail over but client errors,
+	// such as authentication or content errors, are returned. Timeouts always fail over.
+	FailoverOn func(err error) bool
+}
+
+// WithFirstTokenDeadline switches a stream to the next model when no chunk arrives within d.
+// The slow stream is canceled. The last model of the chain is always waited for.
+func WithFirstTokenDeadline(d time.Duration) FallbackOption {
+	return func(o *FallbackOptions) {
+		o.FirstTokenDeadline = d
+	}
+}
+
+// WithModelNames names the models of the chain in order, e.g. "openai", "azure" and
+// "openrouter". The name of the model that produced a response is reported in
+// ResponseMetadata.ServedBy.
+func WithModelNames(names ...string) FallbackOption {
+	return func(o *FallbackOptions) {
+		o.Names = names
+	}
+}
+
+// WithModelTimeouts bounds each attempt of the models of the chain in order; an attempt that
+// times out fails over with SwitchTimeout. For streams the timeout bounds the wait for the
+// first chunk.
+func WithModelTimeouts(timeouts ...time.Duration) FallbackOption {
+	return func(o *FallbackOptions) {
+		o.Timeouts = timeouts
+	}
+}
+
+// WithFailoverOn fails over only on errors for which failover returns true, e.g.
+// HasErrorCode(ErrCodeRateLimited) to fail over on rate limits only. The default is
+// IsRetryableError.
+func WithFailoverOn(failover func(err error) bool) FallbackOption {
+	return func(o *FallbackOptions) {
+		o.FailoverOn = failover
+	}
+}
+
+// ApplyFallbackOptions applies all options to create a FallbackOptions struct
+func ApplyFallbackOptions(opts []FallbackOption) *FallbackOptions {
+	options := &FallbackOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// FallbackCompletionModel tries an ordered chain of completion models, e.g. of OpenAI, Azure
+// and OpenRouter, reissuing the request to the next model when one fails, times out or, for
+// streams, misses the first-token deadline. The switches made are recorded in
+// ResponseMetadata.Switches and the name of the model that served the request in
+// ResponseMetadata.ServedBy; for streams they are reported in the metadata of the usage chunk.
+type FallbackCompletionModel struct {
+	models  []CompletionModel
+	options *FallbackOptions
+}
+
+var _ CompletionModel = (*FallbackCompletionModel)(nil)
+
+// NewFallbackCompletionModel creates a completion model that fails over along models in order
+func NewFallbackCompletionModel(models []CompletionModel, opts ...FallbackOption) (*FallbackCompletionModel, error) {
+	if len(models) == 0 {
+		return nil, NewValidationError("models", "must contain at least one model", nil)
+	}
+	return &FallbackCompletionModel{models: models, options: ApplyFallbackOptions(opts)}, nil
+}
+
+// Complete calls each model in turn until one succeeds. Validation errors and context
+// cancellation are returned immediately; other errors are joined if every model fails.
+func (m *FallbackCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	var switches []*ModelSwitch
+	var errs []error
+	for i, model := range m.models {
+		start := time.Now()
+		attemptCtx, cancel := m.attemptContext(ctx, i)
+		resp, err := model.Complete(attemptCtx, req)
+		timedOut := err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded)
+		cancel()
+		if err == nil {
+			if servedBy := m.servedBy(i); len(switches) > 0 || servedBy != "" {
+				if resp.Metadata == nil {
+					resp.Metadata = &ResponseMetadata{}
+				}
+				resp.Metadata.Switches = append(switches, resp.Metadata.Switches...)
+				resp.Metadata.ServedBy = servedBy
+			}
+			return resp, nil
+		}
+		reason := SwitchError
+		if timedOut {
+			reason = SwitchTimeout
+		} else if !m.failover(ctx, err) {
+			return nil, err
+		}
+		errs = append(errs, err)
+		if i < len(m.models)-1 {
+			switches = append(switches, &ModelSwitch{From: i, To: i + 1, Reason: reason, Error: err.Error(), Elapsed: time.Since(start)})
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// attemptContext returns the context of an attempt of the model at position i, bounded by
+// its timeout
+func (m *FallbackCompletionModel) attemptContext(ctx context.Context, i int) (context.Context, context.CancelFunc) {
+	if timeout := m.timeout(i); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (m *FallbackCompletionModel) timeout(i int) time.Duration {
+	if i < len(m.options.Timeouts) {
+		return m.options.Timeouts[i]
+	}
+	return 0
+}
+
+// servedBy returns the name of the model at position i, or its position if the chain is
+// named only in part, or "" if the chain has no names
+func (m *FallbackCompletionModel) servedBy(i int) string {
+	switch {
+	case len(m.options.Names) == 0:
+		return ""
+	case i < len(m.options.Names) && m.options.Names[i] != "":
+		return m.options.Names[i]
+	}
+	return strconv.Itoa(i)
+}
+
+// failover reports whether the request should be reissued to the next model after err
+func (m *FallbackCompletionModel) failover(ctx context.Context, err error) bool {
+	if !shouldFailover(ctx, err) {
+		return false
+	}
+	if m.options.FailoverOn == nil {
+		return IsRetryableError(err)
+	}
+	return m.options.FailoverOn(err)
+}
+
+// streamStart is the outcome of opening a stream and waiting for its first chunk
+type streamStart struct {
+	stream StreamCompletionResponse
+	first  StreamChunk
+	ok     bool
+	err    error
+}
+
+// StreamComplete opens a stream on each model in turn until one sends its first chunk. A
+// model fails over when opening the stream fails, when the first chunk is an error, or when
+// no chunk arrives before the first-token deadline or the model's timeout.
+func (m *FallbackCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	var switches []*ModelSwitch
+	var errs []error
+	for i, model := range m.models {
+		last := i == len(m.models)-1
+		start := time.Now()
+		attemptCtx, cancel := context.WithCancel(ctx)
+
+		// The stream is opened in the background since providers may block until the
+		// response headers arrive
+		started := make(chan streamStart, 1)
+		go func() {
+			stream, err := model.StreamComplete(attemptCtx, req)
+			if err != nil {
+				started <- streamStart{err: err}
+				return
+			}
+			first, ok := <-stream
+			if errChunk, isErr := first.(StreamErrorChunk); isErr {
+				started <- streamStart{err: errChunk}
+				return
+			}
+			started <- streamStart{stream: stream, first: first, ok: ok}
+		}()
+
+		// The first-token deadline does not apply to the last model, its timeout does
+		wait, reason := m.options.FirstTokenDeadline, SwitchFirstTokenDeadline
+		if last {
+			wait = 0
+		}
+		if timeout := m.timeout(i); timeout > 0 && (wait == 0 || timeout < wait) {
+			wait, reason = timeout, SwitchTimeout
+		}
+		var deadline <-chan time.Time
+		var timer *time.Timer
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			deadline = timer.C
+		}
+
+		select {
+		case result := <-started:
+			if timer != nil {
+				timer.Stop()
+			}
+			if result.err != nil {
+				cancel()
+				if !m.failover(ctx, result.err) {
+					return nil, result.err
+				}
+				errs = append(errs, result.err)
+				if !last {
+					switches = append(switches, &ModelSwitch{From: i, To: i + 1, Reason: SwitchError, Error: result.err.Error(), Elapsed: time.Since(start)})
+				}
+				continue
+			}
+			return forwardStream(ctx, result, switches, m.servedBy(i), cancel), nil
+		case <-deadline:
+			cancel()
+			go drainStreamStart(started)
+			if last {
+				errs = append(errs, fmt.Errorf("no chunk within %s: %w", wait, context.DeadlineExceeded))
+				continue
+			}
+			switches = append(switches, &ModelSwitch{From: i, To: i + 1, Reason: reason, Elapsed: time.Since(start)})
+		case <-ctx.Done():
+			cancel()
+			go drainStreamStart(started)
+			return nil, ctx.Err()
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// forwardStream sends the first chunk and the rest of the stream, adding switches and the
+// name of the serving model to the metadata of the usage chunks. A stream without usage ends
+// with a usage chunk holding only the metadata, so it is not lost; it is not sent first, as
+// a nested fallback would take it for the first token. cancel is called when the stream ends
+// or ctx is done.
+func forwardStream(ctx context.Context, result streamStart, switches []*ModelSwitch, servedBy string, cancel context.CancelFunc) StreamCompletionResponse {
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer cancel()
+		defer close(out)
+		if !result.ok {
+			return
+		}
+		annotate := len(switches) > 0 || servedBy != ""
+		annotated := false
+		send := func(chunk StreamChunk) bool {
+			if usage, ok := chunk.(StreamUsageChunk); ok && annotate {
+				metadata := ResponseMetadata{}
+				if usage.Metadata != nil {
+					metadata = *usage.Metadata
+				}
+				metadata.Switches = append(slices.Clone(switches), metadata.Switches...)
+				metadata.ServedBy = servedBy
+				usage.Metadata = &metadata
+				chunk = usage
+				annotated = true
+			}
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if !send(result.first) {
+			return
+		}
+		for chunk := range result.stream {
+			if !send(chunk) {
+				return
+			}
+		}
+		if annotate && !annotated {
+			send(StreamUsageChunk{})
+		}
+	}()
+	return out
+}
+
+// drainStreamStart consumes the stream of an abandoned attempt so its producer can exit
+func drainStreamStart(started <-chan streamStart) {
+	result := <-started
+	if result.stream == nil {
+		return
+	}
+	for range result.stream {
+	}
+}
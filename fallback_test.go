@@ -0,0 +1,287 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubEmbeddingModel struct {
+	err   error
+	calls int
+	model string
+}
+
+func (m *stubEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	m.calls++
+	m.model = req.Model
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &EmbeddingResponse{Embeddings: []Embedding{{Index: 0, Embedding: []float64{1}}}}, nil
+}
+
+func TestFallbackEmbeddingModel(t *testing.T) {
+	_, err := NewFallbackEmbeddingModel()
+	assert.Error(t, err)
+
+	tests := []struct {
+		name       string
+		primaryErr error
+		wantErr    bool
+		wantCalls  int
+	}{
+		{name: "primary succeeds", wantCalls: 0},
+		{name: "fails over on provider error", primaryErr: NewRequestError("openai", 503, "unavailable", nil), wantCalls: 1},
+		{name: "validation error is returned", primaryErr: NewValidationError("contents", "bad", nil), wantErr: true, wantCalls: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubEmbeddingModel{err: tt.primaryErr}
+			local := &stubEmbeddingModel{}
+			model, err := NewFallbackEmbeddingModel(primary, local)
+			require.NoError(t, err)
+
+			resp, err := model.GenerateEmbeddings(context.Background(), &EmbeddingRequest{Model: "text-embedding-3-small", Contents: []string{"hi"}})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Len(t, resp.Embeddings, 1)
+			}
+			assert.Equal(t, 1, primary.calls)
+			assert.Equal(t, tt.wantCalls, local.calls)
+			assert.Empty(t, local.model)
+		})
+	}
+
+	failing := &stubEmbeddingModel{err: errors.New("down")}
+	model, err := NewFallbackEmbeddingModel(failing, failing)
+	require.NoError(t, err)
+	_, err = model.GenerateEmbeddings(context.Background(), &EmbeddingRequest{Contents: []string{"hi"}})
+	assert.ErrorContains(t, err, "down")
+	assert.Equal(t, 2, failing.calls)
+}
+
+// delayedCompletionModel sends its output after delay, or fails with err. Its streams end
+// with a usage chunk unless noUsage is set.
+type delayedCompletionModel struct {
+	output  string
+	delay   time.Duration
+	err     error
+	noUsage bool
+	calls   int
+}
+
+func (m *delayedCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &CompletionResponse{Output: m.output}, nil
+}
+
+func (m *delayedCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	ch := make(chan StreamChunk)
+	go func() {
+		defer close(ch)
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return
+		}
+		chunks := []StreamChunk{StreamTextChunk{Text: m.output}, StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 1}}}
+		if m.noUsage {
+			chunks = chunks[:1]
+		}
+		for _, chunk := range chunks {
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestFallbackCompletionModel_Complete(t *testing.T) {
+	_, err := NewFallbackCompletionModel(nil)
+	assert.Error(t, err)
+
+	primary := &delayedCompletionModel{err: NewRequestError("openai", 503, "unavailable", nil)}
+	secondary := &delayedCompletionModel{output: "from secondary"}
+	model, err := NewFallbackCompletionModel([]CompletionModel{primary, secondary})
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "from secondary", resp.Output)
+	require.Len(t, resp.Metadata.Switches, 1)
+	assert.Equal(t, SwitchError, resp.Metadata.Switches[0].Reason)
+	assert.Equal(t, 1, resp.Metadata.Switches[0].To)
+	assert.Contains(t, resp.Metadata.Switches[0].Error, "unavailable")
+
+	validation := &delayedCompletionModel{err: NewValidationError("messages", "bad", nil)}
+	model, err = NewFallbackCompletionModel([]CompletionModel{validation, secondary})
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), &CompletionRequest{})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, 1, secondary.calls)
+}
+
+func TestFallbackCompletionModel_FirstTokenDeadline(t *testing.T) {
+	collect := func(t *testing.T, model CompletionModel) (string, *StreamUsageChunk) {
+		stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+		require.NoError(t, err)
+		var text string
+		var usage *StreamUsageChunk
+		for chunk := range stream {
+			switch c := chunk.(type) {
+			case StreamTextChunk:
+				text += c.Text
+			case StreamUsageChunk:
+				usage = &c
+			}
+		}
+		return text, usage
+	}
+
+	t.Run("switches on a slow first token", func(t *testing.T) {
+		slow := &delayedCompletionModel{output: "slow", delay: time.Second}
+		fast := &delayedCompletionModel{output: "fast"}
+		model, err := NewFallbackCompletionModel([]CompletionModel{slow, fast}, WithFirstTokenDeadline(20*time.Millisecond))
+		require.NoError(t, err)
+
+		text, usage := collect(t, model)
+		assert.Equal(t, "fast", text)
+		require.NotNil(t, usage.Metadata)
+		require.Len(t, usage.Metadata.Switches, 1)
+		assert.Equal(t, SwitchFirstTokenDeadline, usage.Metadata.Switches[0].Reason)
+		assert.GreaterOrEqual(t, usage.Metadata.Switches[0].Elapsed, 20*time.Millisecond)
+	})
+
+	t.Run("keeps a stream that starts in time", func(t *testing.T) {
+		primary := &delayedCompletionModel{output: "primary"}
+		secondary := &delayedCompletionModel{output: "secondary"}
+		model, err := NewFallbackCompletionModel([]CompletionModel{primary, secondary}, WithFirstTokenDeadline(time.Second))
+		require.NoError(t, err)
+
+		text, usage := collect(t, model)
+		assert.Equal(t, "primary", text)
+		assert.Nil(t, usage.Metadata)
+		assert.Equal(t, 0, secondary.calls)
+	})
+
+	t.Run("keeps the metadata of a stream without usage", func(t *testing.T) {
+		failing := &delayedCompletionModel{err: NewRequestError("openai", 503, "unavailable", nil)}
+		secondary := &delayedCompletionModel{output: "secondary", noUsage: true}
+		model, err := NewFallbackCompletionModel([]CompletionModel{failing, secondary}, WithModelNames("primary", "secondary"))
+		require.NoError(t, err)
+
+		text, usage := collect(t, model)
+		assert.Equal(t, "secondary", text)
+		require.NotNil(t, usage)
+		assert.Nil(t, usage.Usage)
+		assert.Equal(t, "secondary", usage.Metadata.ServedBy)
+		require.Len(t, usage.Metadata.Switches, 1)
+	})
+
+	t.Run("waits for the last model", func(t *testing.T) {
+		failing := &delayedCompletionModel{err: NewRequestError("openai", 503, "unavailable", nil)}
+		slow := &delayedCompletionModel{output: "slow", delay: 50 * time.Millisecond}
+		model, err := NewFallbackCompletionModel([]CompletionModel{failing, slow}, WithFirstTokenDeadline(10*time.Millisecond))
+		require.NoError(t, err)
+
+		text, usage := collect(t, model)
+		assert.Equal(t, "slow", text)
+		require.Len(t, usage.Metadata.Switches, 1)
+		assert.Equal(t, SwitchError, usage.Metadata.Switches[0].Reason)
+	})
+}
+
+func TestFallbackCompletionModel_Routing(t *testing.T) {
+	unavailable := &delayedCompletionModel{err: NewRequestError("openai", 503, "unavailable", nil)}
+	badRequest := &delayedCompletionModel{err: NewRequestError("openai", 400, "bad request", nil)}
+	azure := &delayedCompletionModel{output: "from azure"}
+
+	model, err := NewFallbackCompletionModel([]CompletionModel{unavailable, azure},
+		WithModelNames("openai", "azure"), WithFailoverOn(IsRetryableError))
+	require.NoError(t, err)
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "from azure", resp.Output)
+	assert.Equal(t, "azure", resp.Metadata.ServedBy)
+
+	// Client errors are returned instead of failing over, by default too
+	model, err = NewFallbackCompletionModel([]CompletionModel{badRequest, azure}, WithModelNames("openai", "azure"))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), &CompletionRequest{})
+	assert.ErrorContains(t, err, "bad request")
+	assert.Equal(t, 1, azure.calls)
+
+	// Models without a name are reported by position
+	model, err = NewFallbackCompletionModel([]CompletionModel{azure, azure}, WithModelNames("", "backup"))
+	require.NoError(t, err)
+	resp, err = model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "0", resp.Metadata.ServedBy)
+}
+
+func TestFallbackCompletionModel_Timeouts(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		slow := &delayedCompletionModel{output: "slow", delay: time.Second}
+		fast := &delayedCompletionModel{output: "fast"}
+		model, err := NewFallbackCompletionModel([]CompletionModel{slow, fast},
+			WithModelTimeouts(20*time.Millisecond), WithFailoverOn(IsRetryableError))
+		require.NoError(t, err)
+
+		resp, err := model.Complete(context.Background(), &CompletionRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "fast", resp.Output)
+		require.Len(t, resp.Metadata.Switches, 1)
+		assert.Equal(t, SwitchTimeout, resp.Metadata.Switches[0].Reason)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		slow := &delayedCompletionModel{output: "slow", delay: time.Second}
+		fast := &delayedCompletionModel{output: "fast"}
+		model, err := NewFallbackCompletionModel([]CompletionModel{slow, fast}, WithModelTimeouts(20*time.Millisecond))
+		require.NoError(t, err)
+
+		stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+		require.NoError(t, err)
+		var usage StreamUsageChunk
+		for chunk := range stream {
+			if c, ok := chunk.(StreamUsageChunk); ok {
+				usage = c
+			}
+		}
+		require.Len(t, usage.Metadata.Switches, 1)
+		assert.Equal(t, SwitchTimeout, usage.Metadata.Switches[0].Reason)
+	})
+
+	t.Run("last model", func(t *testing.T) {
+		slow := &delayedCompletionModel{output: "slow", delay: time.Second}
+		model, err := NewFallbackCompletionModel([]CompletionModel{slow}, WithModelTimeouts(20*time.Millisecond))
+		require.NoError(t, err)
+
+		_, err = model.StreamComplete(context.Background(), &CompletionRequest{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
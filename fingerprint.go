@@ -0,0 +1,207 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"math"
+)
+
+// TokenFingerprint holds the statistics of an output token that watermark detectors and
+// output analytics work with, computed from its logprob and top alternatives
+type TokenFingerprint struct {
+	Token       string  `json:"token"`
+	Logprob     float64 `json:"logprob"`
+	Probability float64 `json:"probability"`
+	// TopK is the number of alternatives the statistics below are computed from, zero when
+	// WithTopLogprobs requested none
+	TopK int `json:"topK"`
+	// Entropy is the Shannon entropy in nats of the alternatives, with the probability outside
+	// them counted as one more outcome, a lower bound of the entropy of the full distribution
+	Entropy float64 `json:"entropy"`
+	// TopKMass is the probability of the alternatives together
+	TopKMass float64 `json:"topKMass"`
+	// Rank is the 1-based rank of the token among the alternatives, or 0 when it is not one
+	// of them
+	Rank int `json:"rank"`
+}
+
+// NewTokenFingerprint computes the fingerprint of a token
+func NewTokenFingerprint(lp TokenLogprob) TokenFingerprint {
+	fp := TokenFingerprint{
+		Token:       lp.Token,
+		Logprob:     lp.Logprob,
+		Probability: lp.Probability(),
+		TopK:        len(lp.TopLogprobs),
+	}
+	for i, top := range lp.TopLogprobs {
+		p := top.Probability()
+		fp.TopKMass += p
+		if p > 0 {
+			fp.Entropy -= p * top.Logprob
+		}
+		if fp.Rank == 0 && top.Token == lp.Token {
+			fp.Rank = i + 1
+		}
+	}
+	// Rounding can push the mass of near-certain tokens slightly above 1
+	fp.TopKMass = min(fp.TopKMass, 1)
+	if rest := 1 - fp.TopKMass; rest > 0 && fp.TopK > 0 {
+		fp.Entropy -= rest * math.Log(rest)
+	}
+	return fp
+}
+
+// OutputFingerprint aggregates the token fingerprints of an output
+type OutputFingerprint struct {
+	Tokens      int     `json:"tokens"`
+	MeanLogprob float64 `json:"meanLogprob"`
+	// Perplexity is the exponential of the negative mean logprob
+	Perplexity float64 `json:"perplexity"`
+	// MeanEntropy and MeanTopKMass average the tokens with alternatives
+	MeanEntropy  float64 `json:"meanEntropy"`
+	MeanTopKMass float64 `json:"meanTopKMass"`
+	// TopRankRate is the fraction of the tokens with alternatives that were the most likely one
+	TopRankRate float64 `json:"topRankRate"`
+
+	sumLogprob, sumEntropy, sumTopKMass float64
+	withTopK, topRank                   int
+}
+
+// Add adds the fingerprints of tokens to the aggregate
+func (f *OutputFingerprint) Add(tokens ...TokenFingerprint) {
+	for _, token := range tokens {
+		f.Tokens++
+		f.sumLogprob += token.Logprob
+		if token.TopK > 0 {
+			f.withTopK++
+			f.sumEntropy += token.Entropy
+			f.sumTopKMass += token.TopKMass
+			if token.Rank == 1 {
+				f.topRank++
+			}
+		}
+	}
+	if f.Tokens > 0 {
+		f.MeanLogprob = f.sumLogprob / float64(f.Tokens)
+		f.Perplexity = math.Exp(-f.MeanLogprob)
+	}
+	if f.withTopK > 0 {
+		f.MeanEntropy = f.sumEntropy / float64(f.withTopK)
+		f.MeanTopKMass = f.sumTopKMass / float64(f.withTopK)
+		f.TopRankRate = float64(f.topRank) / float64(f.withTopK)
+	}
+}
+
+// FingerprintEvent reports the fingerprints of the tokens of a response. Streams report each
+// StreamLogprobChunk as it arrives, followed by a final event without tokens; complete
+// responses report all their tokens in a single final event.
+type FingerprintEvent struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Tokens are the fingerprints of the tokens received since the previous event
+	Tokens []TokenFingerprint `json:"tokens,omitempty"`
+	// Output aggregates the tokens of the response so far
+	Output OutputFingerprint `json:"output"`
+	// Final is set on the last event of a response. A final event of an output without tokens
+	// means the provider returned no logprobs.
+	Final bool `json:"final"`
+}
+
+// FingerprintHook receives the fingerprint events of responses. Hooks run synchronously as
+// the stream is read and should not block.
+type FingerprintHook func(ctx context.Context, event *FingerprintEvent)
+
+// WithFingerprintHook calls hook with the fingerprints of the output tokens of models created
+// by providers, for watermark detection and output analytics. It needs the logprobs requested
+// with WithTopLogprobs, and is ignored without them; the entropy and top-k mass of tokens
+// need a positive number of alternatives.
+func WithFingerprintHook(hook FingerprintHook) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.FingerprintHook = hook
+	}
+}
+
+// FingerprintCompletionModel calls the fingerprint hook set with WithFingerprintHook with the
+// logprobs of the responses of a model, which it passes through unchanged
+type FingerprintCompletionModel struct {
+	model    CompletionModel
+	options  []CompletionOption
+	provider string
+	name     string
+}
+
+var _ CompletionModel = (*FingerprintCompletionModel)(nil)
+var _ TokenCounter = (*FingerprintCompletionModel)(nil)
+
+// NewFingerprintCompletionModel wraps model, created with opts, with the fingerprint hook of
+// opts and of the option overrides of request contexts
+func NewFingerprintCompletionModel(model CompletionModel, opts []CompletionOption) *FingerprintCompletionModel {
+	return &FingerprintCompletionModel{model: model, options: opts}
+}
+
+func (m *FingerprintCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, err := m.model.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	hook := m.hook(ctx)
+	if hook == nil {
+		return resp, nil
+	}
+	event := &FingerprintEvent{Provider: m.provider, Model: m.name, Final: true}
+	event.Tokens = make([]TokenFingerprint, len(resp.Logprobs))
+	for i, lp := range resp.Logprobs {
+		event.Tokens[i] = NewTokenFingerprint(lp)
+	}
+	event.Output.Add(event.Tokens...)
+	hook(ctx, event)
+	return resp, nil
+}
+
+func (m *FingerprintCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	hook := m.hook(ctx)
+	stream, err := m.model.StreamComplete(ctx, req)
+	if err != nil || hook == nil {
+		return stream, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer close(out)
+		var output OutputFingerprint
+		for chunk := range stream {
+			if c, ok := chunk.(StreamLogprobChunk); ok && len(c.Logprobs) > 0 {
+				tokens := make([]TokenFingerprint, len(c.Logprobs))
+				for i, lp := range c.Logprobs {
+					tokens[i] = NewTokenFingerprint(lp)
+				}
+				output.Add(tokens...)
+				hook(ctx, &FingerprintEvent{Provider: m.provider, Model: m.name, Tokens: tokens, Output: output})
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+		hook(ctx, &FingerprintEvent{Provider: m.provider, Model: m.name, Output: output, Final: true})
+	}()
+	return out, nil
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *FingerprintCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
+
+// hook returns the fingerprint hook of a request, or nil when it has none or requests no
+// logprobs
+func (m *FingerprintCompletionModel) hook(ctx context.Context) FingerprintHook {
+	options := ApplyContextCompletionOptions(ctx, m.options)
+	if options.TopLogprobs == nil {
+		return nil
+	}
+	return options.FingerprintHook
+}
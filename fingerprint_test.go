@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokenFingerprint(t *testing.T) {
+	fp := NewTokenFingerprint(TokenLogprob{
+		Token:   " blue",
+		Logprob: math.Log(0.25),
+		TopLogprobs: []TokenLogprob{
+			{Token: " gray", Logprob: math.Log(0.5)},
+			{Token: " blue", Logprob: math.Log(0.25)},
+		},
+	})
+	assert.Equal(t, 2, fp.TopK)
+	assert.Equal(t, 2, fp.Rank)
+	assert.InDelta(t, 0.25, fp.Probability, 1e-9)
+	assert.InDelta(t, 0.75, fp.TopKMass, 1e-9)
+	// The remaining 0.25 counts as one more outcome
+	assert.InDelta(t, -0.5*math.Log(0.5)-2*0.25*math.Log(0.25), fp.Entropy, 1e-9)
+
+	// Without alternatives only the logprob is known
+	fp = NewTokenFingerprint(TokenLogprob{Token: "a", Logprob: 0})
+	assert.Equal(t, TokenFingerprint{Token: "a", Probability: 1}, fp)
+}
+
+func TestOutputFingerprint_Add(t *testing.T) {
+	var output OutputFingerprint
+	output.Add(
+		TokenFingerprint{Logprob: math.Log(0.5), TopK: 2, Entropy: 0.6, TopKMass: 0.9, Rank: 1},
+		TokenFingerprint{Logprob: math.Log(0.125), TopK: 2, Entropy: 1.0, TopKMass: 0.7, Rank: 2},
+		TokenFingerprint{Logprob: math.Log(0.25)},
+	)
+	assert.Equal(t, 3, output.Tokens)
+	assert.InDelta(t, 4, output.Perplexity, 1e-9)
+	assert.InDelta(t, 0.8, output.MeanEntropy, 1e-9)
+	assert.InDelta(t, 0.8, output.MeanTopKMass, 1e-9)
+	assert.InDelta(t, 0.5, output.TopRankRate, 1e-9)
+}
+
+func TestFingerprintCompletionModel_Stream(t *testing.T) {
+	top := func(token string, p float64) TokenLogprob {
+		return TokenLogprob{Token: token, Logprob: math.Log(p), TopLogprobs: []TokenLogprob{{Token: token, Logprob: math.Log(p)}}}
+	}
+	chunks := []StreamChunk{
+		StreamTextChunk{Text: "Hi"},
+		StreamLogprobChunk{Logprobs: []TokenLogprob{top("Hi", 0.5)}},
+		StreamTextChunk{Text: " there"},
+		StreamLogprobChunk{Logprobs: []TokenLogprob{top(" there", 0.25)}},
+		StreamFinishChunk{Reason: FinishReasonStop},
+	}
+	var events []*FingerprintEvent
+	hook := WithFingerprintHook(func(ctx context.Context, event *FingerprintEvent) {
+		events = append(events, event)
+	})
+
+	model := NewFingerprintCompletionModel(&chunkModel{chunks: chunks}, []CompletionOption{WithTopLogprobs(1), hook})
+	model.provider, model.name = "openai", "gpt-4o-mini"
+	stream, err := StreamCompletion(context.Background(), model, &CompletionRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "Hi there", resp.Output)
+	assert.Len(t, resp.Logprobs, 2)
+
+	require.Len(t, events, 3)
+	assert.Equal(t, "Hi", events[0].Tokens[0].Token)
+	assert.Equal(t, 1, events[0].Output.Tokens)
+	assert.Equal(t, " there", events[1].Tokens[0].Token)
+	assert.True(t, events[2].Final)
+	assert.Empty(t, events[2].Tokens)
+	assert.Equal(t, 2, events[2].Output.Tokens)
+	assert.InDelta(t, math.Sqrt(8), events[2].Output.Perplexity, 1e-9)
+	assert.Equal(t, "gpt-4o-mini", events[2].Model)
+
+	// Without logprobs the hook is not called
+	events = nil
+	model = NewFingerprintCompletionModel(&chunkModel{chunks: chunks}, []CompletionOption{hook})
+	stream, err = StreamCompletion(context.Background(), model, &CompletionRequest{})
+	require.NoError(t, err)
+	_, err = stream.Final()
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestFingerprintCompletionModel_Complete(t *testing.T) {
+	var events []*FingerprintEvent
+	hook := WithFingerprintHook(func(ctx context.Context, event *FingerprintEvent) {
+		events = append(events, event)
+	})
+	info := &ModelInfo{ID: "gpt-4o-mini"}
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{info})
+	model := provider.DecorateCompletionModel(&stubCompletionModel{output: "ok"}, info, []CompletionOption{hook})
+
+	// Logprobs requested by the request context enable the hook
+	ctx := ContextWithOptions(context.Background(), WithTopLogprobs(0))
+	_, err := model.Complete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.True(t, events[0].Final)
+	assert.Equal(t, "openai", events[0].Provider)
+	// The stub returns no logprobs
+	assert.Zero(t, events[0].Output.Tokens)
+
+	_, err = model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	// A hook set per request applies to models created without one
+	model = provider.DecorateCompletionModel(&stubCompletionModel{output: "ok"}, info, nil)
+	_, err = model.Complete(ContextWithOptions(ctx, hook), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func TestCacheKey_FingerprintHook(t *testing.T) {
+	options := ApplyCompletionOptions([]CompletionOption{WithFingerprintHook(func(context.Context, *FingerprintEvent) {})})
+	key, err := CacheKey("openai/gpt-4o", &CompletionRequest{}, options)
+	require.NoError(t, err)
+	unhooked, err := CacheKey("openai/gpt-4o", &CompletionRequest{}, &CompletionOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, unhooked, key)
+}
@@ -3,13 +3,21 @@ module github.com/easyagent-dev/llm
 go 1.24.4
 
 require (
+	github.com/anthropics/anthropic-sdk-go v1.13.0
 	github.com/invopop/jsonschema v0.13.0
 	github.com/openai/openai-go/v3 v3.0.1
 	github.com/replicate/replicate-go v0.26.0
 	github.com/stretchr/testify v1.11.1
+	github.com/yalue/onnxruntime_go v1.36.0
+	golang.org/x/image v0.25.0
+	golang.org/x/oauth2 v0.30.0
+	golang.org/x/sync v0.16.0
+	golang.org/x/text v0.27.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	cloud.google.com/go/compute/metadata v0.5.0 // indirect
 	github.com/bahlo/generic-list-go v0.2.0 // indirect
 	github.com/buger/jsonparser v1.1.1 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -21,6 +29,4 @@ require (
 	github.com/tidwall/sjson v1.2.5 // indirect
 	github.com/vincent-petithory/dataurl v1.0.0 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
-	golang.org/x/sync v0.6.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,23 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+// GroundingOptions configures grounding with Google Search, where the model searches the web
+// and bases its response on the results. The searches and the pages used are reported in
+// CompletionResponse.WebSearches, and each grounded request counts as one web search in
+// TokenUsage.TotalWebSearches.
+type GroundingOptions struct {
+	// DynamicThreshold lets the model search only when its predicted need for grounding, from 0
+	// to 1, is at least the threshold. It uses dynamic retrieval, which only Gemini 1.5 models
+	// support; nil lets the model decide.
+	DynamicThreshold *float64
+}
+
+// WithGrounding grounds responses with Google Search. It is supported by the Gemini provider
+// and ignored by other providers; see WithWebSearch for Anthropic.
+func WithGrounding(options GroundingOptions) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.Grounding = &options
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+)
+
+// ImageEditModel is an image model that also edits images and creates variations of them
+type ImageEditModel interface {
+	ImageModel
+	// EditImage edits an image following a prompt, only where the mask allows if one is given
+	EditImage(ctx context.Context, req *ImageEditRequest) (*ImageResponse, error)
+	// CreateVariation creates images similar to an image, without a prompt
+	CreateVariation(ctx context.Context, req *ImageVariationRequest) (*ImageResponse, error)
+}
+
+type ImageEditRequest struct {
+	Model string `json:"model"`
+	// Instructions is the prompt describing the edited image
+	Instructions string `json:"instructions"`
+	// Image is the image to edit
+	Image *ModelArtifact `json:"image"`
+	// Mask is an optional image of the same size whose fully transparent areas mark where
+	// Image is edited; the whole image may be changed without one
+	Mask   *ModelArtifact    `json:"mask,omitempty"`
+	Config *ImageModelConfig `json:"config,omitempty"`
+	// Inputs are model-specific parameters passed through to the model, see
+	// ImageRequest.Inputs
+	Inputs map[string]any `json:"inputs,omitempty"`
+}
+
+type ImageVariationRequest struct {
+	Model string `json:"model"`
+	// Image is the image to create variations of
+	Image  *ModelArtifact    `json:"image"`
+	Config *ImageModelConfig `json:"config,omitempty"`
+	// Inputs are model-specific parameters passed through to the model, see
+	// ImageRequest.Inputs
+	Inputs map[string]any `json:"inputs,omitempty"`
+}
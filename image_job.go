@@ -0,0 +1,185 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultJobPollInterval is how often ImageJob.Wait checks the status of a job by default
+const DefaultJobPollInterval = 2 * time.Second
+
+// jobProgressBuffer is the number of progress updates an ImageJob keeps for a slow reader
+const jobProgressBuffer = 16
+
+// ImageJobModel is an image model that can also run generations as jobs, for models taking
+// minutes. A job is identified by its ID, which can be persisted to resume the job after a
+// restart.
+type ImageJobModel interface {
+	ImageModel
+	// StartImage starts generating the images of req and returns the job without waiting
+	StartImage(ctx context.Context, req *ImageRequest) (*ImageJob, error)
+	// ResumeImage returns the job with the given ID, started by StartImage, whose images are
+	// delivered in the response format of config
+	ResumeImage(ctx context.Context, id string, config *ImageModelConfig) (*ImageJob, error)
+}
+
+// ImageJobBackend polls and cancels the image jobs of a provider, see NewImageJob
+type ImageJobBackend interface {
+	// PollImageJob returns the progress of job id, and its images once it has succeeded
+	PollImageJob(ctx context.Context, id string) (*JobProgress, *ImageResponse, error)
+	// CancelImageJob cancels job id
+	CancelImageJob(ctx context.Context, id string) error
+}
+
+// JobStatus is the normalized status of a provider job
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCanceled  JobStatus = "canceled"
+)
+
+// Done reports whether the job has finished, successfully or not
+func (s JobStatus) Done() bool {
+	return s == JobStatusSucceeded || s == JobStatusFailed || s == JobStatusCanceled
+}
+
+// JobProgress is the status of a provider job when it was polled
+type JobProgress struct {
+	ID     string    `json:"id"`
+	Status JobStatus `json:"status"`
+	// Percent is the completed percentage from 0 to 100, or nil when the provider does not
+	// report it
+	Percent *float64 `json:"percent,omitempty"`
+	// Logs are the logs of the job so far, when the provider reports them
+	Logs string `json:"logs,omitempty"`
+	// Error is the reason a failed job failed
+	Error string `json:"error,omitempty"`
+}
+
+// ImageJob is an image generation running at the provider. Poll checks it once and Wait polls
+// it until it is done; every status is also sent to the Progress channel. It is safe for
+// concurrent use.
+type ImageJob struct {
+	id       string
+	backend  ImageJobBackend
+	progress chan *JobProgress
+
+	mu        sync.Mutex
+	last      *JobProgress
+	resp      *ImageResponse
+	err       error
+	onSuccess []func(ctx context.Context, resp *ImageResponse)
+}
+
+// NewImageJob returns the job id polled and canceled with backend
+func NewImageJob(id string, backend ImageJobBackend) *ImageJob {
+	return &ImageJob{id: id, backend: backend, progress: make(chan *JobProgress, jobProgressBuffer)}
+}
+
+// ID returns the provider's ID of the job, with which it can be resumed
+func (j *ImageJob) ID() string {
+	return j.id
+}
+
+// Progress returns the channel receiving the status of the job every time it is polled.
+// Statuses are dropped while the channel is full, and it is closed once the job is done.
+func (j *ImageJob) Progress() <-chan *JobProgress {
+	return j.progress
+}
+
+// OnSuccess registers fn to be called with the images of the job once it has succeeded, e.g.
+// to record their cost
+func (j *ImageJob) OnSuccess(fn func(ctx context.Context, resp *ImageResponse)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.onSuccess = append(j.onSuccess, fn)
+}
+
+// Poll checks the status of the job once. The images are returned once the job has
+// succeeded, and a failed or canceled job returns an error matching ErrJobFailed or
+// ErrJobCanceled. A job that is done is not polled again. The provider is polled without
+// holding the lock of the job, so concurrent polls do not wait for each other.
+func (j *ImageJob) Poll(ctx context.Context) (*JobProgress, *ImageResponse, error) {
+	j.mu.Lock()
+	if j.last != nil && j.last.Status.Done() {
+		defer j.mu.Unlock()
+		return j.last, j.resp, j.err
+	}
+	j.mu.Unlock()
+
+	progress, resp, err := j.backend.PollImageJob(ctx, j.id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	j.mu.Lock()
+	if j.last != nil && j.last.Status.Done() {
+		// A concurrent poll saw the job finish first
+		defer j.mu.Unlock()
+		return j.last, j.resp, j.err
+	}
+	j.last = progress
+	select {
+	case j.progress <- progress:
+	default:
+	}
+	if !progress.Status.Done() {
+		j.mu.Unlock()
+		return progress, nil, nil
+	}
+
+	close(j.progress)
+	var onSuccess []func(ctx context.Context, resp *ImageResponse)
+	switch progress.Status {
+	case JobStatusSucceeded:
+		j.resp = resp
+		onSuccess = j.onSuccess
+	case JobStatusCanceled:
+		j.err = fmt.Errorf("%w: job %s", ErrJobCanceled, j.id)
+	default:
+		j.err = fmt.Errorf("%w: job %s: %s", ErrJobFailed, j.id, progress.Error)
+	}
+	resp, err = j.resp, j.err
+	j.mu.Unlock()
+
+	for _, fn := range onSuccess {
+		fn(ctx, resp)
+	}
+	return progress, resp, err
+}
+
+// Wait polls the job every interval, DefaultJobPollInterval when zero, until it is done and
+// returns its images. When ctx ends first the job keeps running, and may be resumed.
+func (j *ImageJob) Wait(ctx context.Context, interval time.Duration) (*ImageResponse, error) {
+	if interval <= 0 {
+		interval = DefaultJobPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		progress, resp, err := j.Poll(ctx)
+		if err != nil || progress.Status.Done() {
+			return resp, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cancel cancels the job at the provider, which stops billing it. The next poll reports it
+// canceled.
+func (j *ImageJob) Cancel(ctx context.Context) error {
+	return j.backend.CancelImageJob(ctx, j.id)
+}
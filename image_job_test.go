@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedImageJob reports its statuses in order, then the last one
+type scriptedImageJob struct {
+	mu       sync.Mutex
+	statuses []JobStatus
+	polls    int
+	canceled bool
+}
+
+func (b *scriptedImageJob) PollImageJob(ctx context.Context, id string) (*JobProgress, *ImageResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := b.statuses[min(b.polls, len(b.statuses)-1)]
+	b.polls++
+	if b.canceled {
+		status = JobStatusCanceled
+	}
+	percent := float64(b.polls) * 10
+	progress := &JobProgress{ID: id, Status: status, Percent: &percent, Error: "out of memory"}
+	if status != JobStatusSucceeded {
+		return progress, nil, nil
+	}
+	cost := 0.01
+	return progress, NewImageResponse([]GeneratedImage{{URL: "https://example.com/1.png"}}, &TokenUsage{TotalImages: 1}, &cost), nil
+}
+
+func (b *scriptedImageJob) CancelImageJob(ctx context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.canceled = true
+	return nil
+}
+
+func TestImageJob_Wait(t *testing.T) {
+	backend := &scriptedImageJob{statuses: []JobStatus{JobStatusQueued, JobStatusRunning, JobStatusSucceeded}}
+	job := NewImageJob("p1", backend)
+	var charged []*ImageResponse
+	job.OnSuccess(func(ctx context.Context, resp *ImageResponse) { charged = append(charged, resp) })
+
+	resp, err := job.Wait(context.Background(), time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/1.png", resp.Images[0].URL)
+	assert.Equal(t, []*ImageResponse{resp}, charged)
+
+	var statuses []JobStatus
+	for progress := range job.Progress() {
+		statuses = append(statuses, progress.Status)
+	}
+	assert.Equal(t, []JobStatus{JobStatusQueued, JobStatusRunning, JobStatusSucceeded}, statuses)
+
+	// A job that is done is not polled again
+	progress, again, err := job.Poll(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, resp, again)
+	assert.Equal(t, JobStatusSucceeded, progress.Status)
+	assert.Equal(t, 3, backend.polls)
+	assert.Len(t, charged, 1)
+}
+
+func TestImageJob_FailedAndCanceled(t *testing.T) {
+	job := NewImageJob("p1", &scriptedImageJob{statuses: []JobStatus{JobStatusRunning, JobStatusFailed}})
+	_, err := job.Wait(context.Background(), time.Millisecond)
+	assert.ErrorIs(t, err, ErrJobFailed)
+	assert.ErrorContains(t, err, "out of memory")
+
+	backend := &scriptedImageJob{statuses: []JobStatus{JobStatusRunning}}
+	job = NewImageJob("p2", backend)
+	progress, resp, err := job.Poll(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+	assert.Equal(t, 10.0, *progress.Percent)
+	require.NoError(t, job.Cancel(context.Background()))
+	_, err = job.Wait(context.Background(), time.Millisecond)
+	assert.ErrorIs(t, err, ErrJobCanceled)
+}
+
+func TestImageJob_WaitContextDone(t *testing.T) {
+	backend := &scriptedImageJob{statuses: []JobStatus{JobStatusRunning}}
+	job := NewImageJob("p1", backend)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := job.Wait(ctx, time.Millisecond)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, backend.canceled, "the job keeps running to be resumed")
+}
+
+// blockingImageJob blocks every poll until release is closed
+type blockingImageJob struct {
+	polling chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingImageJob) PollImageJob(ctx context.Context, id string) (*JobProgress, *ImageResponse, error) {
+	close(b.polling)
+	<-b.release
+	return &JobProgress{ID: id, Status: JobStatusRunning}, nil, nil
+}
+
+func (b *blockingImageJob) CancelImageJob(ctx context.Context, id string) error {
+	return nil
+}
+
+func TestImageJob_PollDoesNotHoldLock(t *testing.T) {
+	backend := &blockingImageJob{polling: make(chan struct{}), release: make(chan struct{})}
+	job := NewImageJob("p1", backend)
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		_, _, _ = job.Poll(context.Background())
+	}()
+	<-backend.polling
+
+	registered := make(chan struct{})
+	go func() {
+		job.OnSuccess(func(ctx context.Context, resp *ImageResponse) {})
+		close(registered)
+	}()
+	select {
+	case <-registered:
+	case <-time.After(time.Second):
+		t.Fatal("OnSuccess waited for the poll")
+	}
+	close(backend.release)
+	<-polled
+}
+
+// jobModel starts and resumes the jobs of backend
+type jobModel struct {
+	backend ImageJobBackend
+	errs    []error
+	starts  int
+}
+
+func (m *jobModel) GenerateImage(ctx context.Context, req *ImageRequest) (*ImageResponse, error) {
+	return nil, ErrInvalidRequest
+}
+
+func (m *jobModel) StartImage(ctx context.Context, req *ImageRequest) (*ImageJob, error) {
+	m.starts++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return nil, err
+	}
+	return NewImageJob("p1", m.backend), nil
+}
+
+func (m *jobModel) ResumeImage(ctx context.Context, id string, config *ImageModelConfig) (*ImageJob, error) {
+	return NewImageJob(id, m.backend), nil
+}
+
+func TestCostTrackingImageJobModel_ResumeChargesOnce(t *testing.T) {
+	tracker := NewCostTracker()
+	model := NewCostTrackingImageJobModel(&jobModel{backend: &scriptedImageJob{statuses: []JobStatus{JobStatusSucceeded}}}, tracker)
+
+	job, err := model.StartImage(context.Background(), &ImageRequest{Instructions: "a cat"})
+	require.NoError(t, err)
+	resumed, err := model.ResumeImage(context.Background(), job.ID(), nil)
+	require.NoError(t, err)
+
+	_, err = job.Wait(context.Background(), time.Millisecond)
+	require.NoError(t, err)
+	_, err = resumed.Wait(context.Background(), time.Millisecond)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.01, tracker.TotalUSD(), 1e-9)
+	assert.Equal(t, int64(1), tracker.Requests())
+}
+
+func TestRetryImageJobModel_StartImage(t *testing.T) {
+	backend := &scriptedImageJob{statuses: []JobStatus{JobStatusSucceeded}}
+	options := &RetryOptions{MaxAttempts: 3, Backoff: func(int) time.Duration { return 0 }}
+
+	// A rate limited start created nothing, so it is retried
+	inner := &jobModel{backend: backend, errs: []error{&RequestError{StatusCode: http.StatusTooManyRequests}}}
+	_, err := NewRetryImageJobModel(inner, options).StartImage(context.Background(), &ImageRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.starts)
+
+	// A server error may follow the creation of a billed job, so it is not retried
+	inner = &jobModel{backend: backend, errs: []error{&RequestError{StatusCode: http.StatusBadGateway}}}
+	_, err = NewRetryImageJobModel(inner, options).StartImage(context.Background(), &ImageRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, inner.starts)
+}
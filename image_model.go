@@ -2,6 +2,12 @@ package llm
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
 )
 
 // ImageModel defines the interface for image generation operations
@@ -15,17 +21,160 @@ type ImageRequest struct {
 	Instructions string            `json:"instructions"`
 	Artifacts    []*ModelArtifact  `json:"artifacts"`
 	Config       *ImageModelConfig `json:"config,omitempty"`
+	// Inputs are model-specific parameters passed through to the model as they are, e.g. the
+	// guidance_scale or negative_prompt of Replicate models. They take precedence over the
+	// parameters mapped from Instructions and Config.
+	Inputs map[string]any `json:"inputs,omitempty"`
 }
 
+// Response formats of generated images
+const (
+	// ImageResponseFormatBytes returns the image in ImageResponse.Output
+	ImageResponseFormatBytes = "bytes"
+	// ImageResponseFormatFile writes the image to a temporary file named by ImageResponse.Path,
+	// which the caller removes when done
+	ImageResponseFormatFile = "file"
+	// ImageResponseFormatDataURI returns the image inline as a data URI in ImageResponse.DataURI
+	ImageResponseFormatDataURI = "data_uri"
+	// ImageResponseFormatURL returns the provider's URL of the image without downloading it,
+	// for models whose provider hosts generated images
+	ImageResponseFormatURL = "url"
+)
+
 type ImageModelConfig struct {
-	Size           string `json:"size,omitempty"`
-	Quality        string `json:"quality,omitempty"`
-	Style          string `json:"style,omitempty"`
+	Size    string `json:"size,omitempty"`
+	Quality string `json:"quality,omitempty"`
+	Style   string `json:"style,omitempty"`
+	// ResponseFormat is one of the ImageResponseFormat constants; bytes by default
 	ResponseFormat string `json:"response_format,omitempty"`
+	// Count is the number of images to generate; one when zero
+	Count int `json:"n,omitempty"`
+	// N is the number of images to generate when Count is zero.
+	//
+	// Deprecated: use Count.
+	N int `json:"-"`
+}
+
+// ImageCount returns the number of images requested by config, validating it
+func ImageCount(config *ImageModelConfig) (int, error) {
+	if config == nil {
+		return 1, nil
+	}
+	count := config.Count
+	if count == 0 {
+		count = config.N
+	}
+	if count == 0 {
+		return 1, nil
+	}
+	if count < 0 {
+		return 0, NewValidationError("count", "must be positive", count)
+	}
+	return count, nil
+}
+
+// ImageResponseFormat returns the response format of config, validating it. The OpenAI
+// format b64_json, which configs used before the response formats were supported, is read as
+// bytes.
+func ImageResponseFormat(config *ImageModelConfig) (string, error) {
+	if config == nil || config.ResponseFormat == "" || config.ResponseFormat == "b64_json" {
+		return ImageResponseFormatBytes, nil
+	}
+	switch config.ResponseFormat {
+	case ImageResponseFormatBytes, ImageResponseFormatFile, ImageResponseFormatDataURI, ImageResponseFormatURL:
+		return config.ResponseFormat, nil
+	}
+	return "", NewValidationError("response_format", "must be bytes, file, data_uri or url", config.ResponseFormat)
+}
+
+// GeneratedImage is an image of an ImageResponse, delivered in the requested response format
+type GeneratedImage struct {
+	// Data is the image when requested as bytes, the default
+	Data []byte `json:"data,omitempty"`
+	// Path is the temporary file holding the image when requested as a file
+	Path string `json:"path,omitempty"`
+	// DataURI is the image when requested as a data URI
+	DataURI string `json:"dataUri,omitempty"`
+	// URL is the provider's URL of the image when requested as a URL
+	URL string `json:"url,omitempty"`
+	// MimeType is the MIME type of the image, e.g. image/png
+	MimeType string `json:"mimeType,omitempty"`
+	// RevisedPrompt is the prompt the provider rewrote the instructions into, e.g. DALL·E 3
+	RevisedPrompt string `json:"revisedPrompt,omitempty"`
+	// Seed is the random seed the image was generated with, when the provider reports it
+	Seed *int64 `json:"seed,omitempty"`
 }
 
 type ImageResponse struct {
-	Output []byte      `json:"output"`
-	Usage  *TokenUsage `json:"usage,omitempty"`
-	Cost   *float64    `json:"cost,omitempty"`
+	// Images are the generated images, as many as requested with ImageModelConfig.Count
+	Images []GeneratedImage `json:"images"`
+	// Usage and Cost cover all the images
+	Usage *TokenUsage `json:"usage,omitempty"`
+	Cost  *float64    `json:"cost,omitempty"`
+	// CostBreakdown splits Cost by what was billed, see CompletionResponse.CostBreakdown
+	CostBreakdown *CostBreakdown `json:"costBreakdown,omitempty"`
+
+	// Output is the first image when requested as bytes.
+	//
+	// Deprecated: use Images[0].Data.
+	Output []byte `json:"output,omitempty"`
+	// Path is the temporary file holding the first image when requested as a file.
+	//
+	// Deprecated: use Images[0].Path.
+	Path string `json:"path,omitempty"`
+	// DataURI is the first image when requested as a data URI.
+	//
+	// Deprecated: use Images[0].DataURI.
+	DataURI string `json:"dataUri,omitempty"`
+	// URL is the provider's URL of the first image.
+	//
+	// Deprecated: use Images[0].URL.
+	URL string `json:"url,omitempty"`
+	// ContentType is the MIME type of the first image.
+	//
+	// Deprecated: use Images[0].MimeType.
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// NewImageResponse returns the response reporting images, with usage and cost covering all
+// of them. The deprecated fields are set from the first image.
+func NewImageResponse(images []GeneratedImage, usage *TokenUsage, cost *float64) *ImageResponse {
+	resp := &ImageResponse{Images: images, Usage: usage, Cost: cost}
+	if len(images) > 0 {
+		first := images[0]
+		resp.Output, resp.Path, resp.DataURI, resp.URL, resp.ContentType = first.Data, first.Path, first.DataURI, first.URL, first.MimeType
+	}
+	return resp
+}
+
+// DeliverImage moves the image in image.Data to the delivery of format: it is kept in Data
+// for bytes, and written to a temporary file or encoded as a data URI otherwise. MimeType is
+// detected from the image when empty. Providers call it after downloading or decoding the image;
+// the url format is handled by providers since it skips the download.
+func DeliverImage(image *GeneratedImage, format string) error {
+	if image.MimeType == "" {
+		image.MimeType = http.DetectContentType(image.Data)
+	}
+	switch format {
+	case ImageResponseFormatFile:
+		ext := ""
+		if exts, _ := mime.ExtensionsByType(image.MimeType); len(exts) > 0 {
+			ext = exts[len(exts)-1]
+		}
+		file, err := os.CreateTemp("", "image-*"+ext)
+		if err != nil {
+			return fmt.Errorf("failed to create image file: %w", err)
+		}
+		defer file.Close()
+		if _, err := file.Write(image.Data); err != nil {
+			os.Remove(file.Name())
+			return fmt.Errorf("failed to write image file: %w", err)
+		}
+		image.Path, image.Data = file.Name(), nil
+	case ImageResponseFormatDataURI:
+		mediaType, _, _ := strings.Cut(image.MimeType, ";")
+		image.DataURI = "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(image.Data)
+		image.Data = nil
+	}
+	return nil
 }
@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageResponseFormat(t *testing.T) {
+	format, err := ImageResponseFormat(nil)
+	require.NoError(t, err)
+	assert.Equal(t, ImageResponseFormatBytes, format)
+
+	format, err = ImageResponseFormat(&ImageModelConfig{ResponseFormat: ImageResponseFormatDataURI})
+	require.NoError(t, err)
+	assert.Equal(t, ImageResponseFormatDataURI, format)
+
+	format, err = ImageResponseFormat(&ImageModelConfig{ResponseFormat: "b64_json"})
+	require.NoError(t, err)
+	assert.Equal(t, ImageResponseFormatBytes, format)
+
+	_, err = ImageResponseFormat(&ImageModelConfig{ResponseFormat: "tiff"})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestDeliverImage(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+
+	image := &GeneratedImage{Data: jpeg}
+	require.NoError(t, DeliverImage(image, ImageResponseFormatBytes))
+	assert.Equal(t, jpeg, image.Data)
+	assert.Equal(t, "image/jpeg", image.MimeType)
+
+	image = &GeneratedImage{Data: []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), MimeType: "image/webp"}
+	require.NoError(t, DeliverImage(image, ImageResponseFormatDataURI))
+	assert.Nil(t, image.Data)
+	assert.Equal(t, "data:image/webp;base64,UklGRgAAAABXRUJQVlA4IA==", image.DataURI)
+
+	image = &GeneratedImage{Data: jpeg}
+	require.NoError(t, DeliverImage(image, ImageResponseFormatFile))
+	defer os.Remove(image.Path)
+	assert.Nil(t, image.Data)
+	assert.True(t, strings.HasPrefix(image.Path, os.TempDir()))
+	data, err := os.ReadFile(image.Path)
+	require.NoError(t, err)
+	assert.Equal(t, jpeg, data)
+}
+
+func TestImageCount(t *testing.T) {
+	n, err := ImageCount(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = ImageCount(&ImageModelConfig{Count: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = ImageCount(&ImageModelConfig{N: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	_, err = ImageCount(&ImageModelConfig{Count: -1})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	// Count keeps the JSON name of the field it replaces
+	data, err := json.Marshal(&ImageModelConfig{Count: 3})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"n": 3}`, string(data))
+}
+
+func TestNewImageResponse(t *testing.T) {
+	usage := &TokenUsage{TotalImages: 2}
+	seed := int64(42)
+	images := []GeneratedImage{
+		{URL: "https://example.com/1.png", MimeType: "image/png", RevisedPrompt: "A red fox", Seed: &seed},
+		{URL: "https://example.com/2.png", MimeType: "image/png"},
+	}
+
+	resp := NewImageResponse(images, usage, nil)
+	assert.Equal(t, images, resp.Images)
+	assert.Same(t, usage, resp.Usage)
+	assert.Equal(t, "https://example.com/1.png", resp.URL)
+	assert.Equal(t, "image/png", resp.ContentType)
+
+	// The deprecated fields keep their JSON names for existing consumers
+	data, err := json.Marshal(NewImageResponse(images, nil, nil))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"images": [
+		{"url": "https://example.com/1.png", "mimeType": "image/png", "revisedPrompt": "A red fox", "seed": 42},
+		{"url": "https://example.com/2.png", "mimeType": "image/png"}
+	], "url": "https://example.com/1.png", "contentType": "image/png"}`, string(data))
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+)
+
+// APIClient sends JSON requests to the REST API of a provider that authenticates with the API
+// key as a bearer token, such as the Cohere, Jina and Voyage APIs
+type APIClient struct {
+	// Provider names the provider in errors
+	Provider string
+	// BaseURL is prefixed to the paths of requests, without a trailing slash
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewAPIClient creates a client of the API of provider at baseURL, or defaultBaseURL when empty
+func NewAPIClient(provider, baseURL, defaultBaseURL, apiKey string) *APIClient {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &APIClient{
+		Provider:   provider,
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Post sends body to the API path and returns the response for the caller to close. Error
+// statuses are returned as a llm.RequestError with message.
+func (c *APIClient) Post(ctx context.Context, path string, body any, message string) (*http.Response, error) {
+	return c.post(ctx, path, body, "", message)
+}
+
+// PostJSON sends body to the API path and decodes the response into out. Error statuses are
+// returned as a llm.RequestError with message.
+func (c *APIClient) PostJSON(ctx context.Context, path string, body any, out any, message string) error {
+	resp, err := c.post(ctx, path, body, "application/json", message)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return llm.NewResponseError(c.Provider, "failed to parse response", err)
+	}
+	return nil
+}
+
+func (c *APIClient) post(ctx context.Context, path string, body any, accept string, message string) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		httpReq.Header.Set("Accept", accept)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", message, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &llm.RequestError{
+			Provider:   c.Provider,
+			StatusCode: resp.StatusCode,
+			Message:    message,
+			Err:        errors.New(strings.TrimSpace(string(respBody))),
+			RetryAfter: llm.ParseRetryAfter(resp.Header),
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+)
+
+// IsImageArtifact reports whether the artifact holds an image
+func IsImageArtifact(artifact *llm.ModelArtifact) bool {
+	return artifact != nil && strings.HasPrefix(strings.ToLower(artifact.ContentType), "image/")
+}
+
+// ArtifactDataURL encodes the artifact content as a base64 data URL
+func ArtifactDataURL(artifact *llm.ModelArtifact) string {
+	if artifact == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("data:")
+	sb.WriteString(artifact.ContentType)
+	sb.WriteString(";base64,")
+	sb.WriteString(base64.StdEncoding.EncodeToString(artifact.Content))
+	return sb.String()
+}
+
+// ArtifactURL returns the remote URL of the artifact if set, otherwise its content as a data URL
+func ArtifactURL(artifact *llm.ModelArtifact) string {
+	if artifact == nil {
+		return ""
+	}
+	if artifact.URL != "" {
+		return artifact.URL
+	}
+	return ArtifactDataURL(artifact)
+}
+
+// NormalizeRequestArtifacts returns req with the artifacts of its messages normalized by
+// NormalizeMessageArtifacts. req is never modified, and is returned itself when nothing changes.
+func NormalizeRequestArtifacts(req *llm.CompletionRequest) (*llm.CompletionRequest, error) {
+	if req == nil {
+		return nil, nil
+	}
+	messages, err := normalizeMessages(req.Messages)
+	if err != nil || messages == nil {
+		return req, err
+	}
+	copied := *req
+	copied.Messages = messages
+	return &copied, nil
+}
+
+// NormalizeMessageArtifacts returns messages with their artifacts normalized by
+// llm.NormalizeArtifact: content types are sniffed, and inline images are transcoded to a
+// format vision models accept. Messages that change are copied, and messages itself is
+// returned when nothing changes.
+func NormalizeMessageArtifacts(messages []*llm.ModelMessage) ([]*llm.ModelMessage, error) {
+	normalized, err := normalizeMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+	if normalized == nil {
+		return messages, nil
+	}
+	return normalized, nil
+}
+
+// normalizeMessages returns the normalized copy of messages, or nil when nothing changes
+func normalizeMessages(messages []*llm.ModelMessage) ([]*llm.ModelMessage, error) {
+	var normalizedMessages []*llm.ModelMessage
+	for i, msg := range messages {
+		normalized, err := normalizeMessageArtifacts(msg, i)
+		if err != nil {
+			return nil, err
+		}
+		if normalized == msg {
+			continue
+		}
+		if normalizedMessages == nil {
+			normalizedMessages = append([]*llm.ModelMessage(nil), messages...)
+		}
+		normalizedMessages[i] = normalized
+	}
+	return normalizedMessages, nil
+}
+
+// normalizeMessageArtifacts returns msg, or a copy of it with its artifacts normalized
+func normalizeMessageArtifacts(msg *llm.ModelMessage, index int) (*llm.ModelMessage, error) {
+	if msg == nil {
+		return msg, nil
+	}
+	var copied *llm.ModelMessage
+	partsCopied := false
+	for j, artifact := range msg.Artifacts {
+		normalized, err := normalizeArtifact(artifact, fmt.Sprintf("messages[%d].artifacts[%d]", index, j))
+		if err != nil {
+			return nil, err
+		}
+		if normalized == artifact {
+			continue
+		}
+		if copied == nil {
+			c := *msg
+			c.Artifacts = append([]*llm.ModelArtifact(nil), msg.Artifacts...)
+			copied = &c
+		}
+		copied.Artifacts[j] = normalized
+	}
+	for j, part := range msg.Parts {
+		if part == nil || part.Artifact == nil {
+			continue
+		}
+		normalized, err := normalizeArtifact(part.Artifact, fmt.Sprintf("messages[%d].parts[%d]", index, j))
+		if err != nil {
+			return nil, err
+		}
+		if normalized == part.Artifact {
+			continue
+		}
+		if copied == nil {
+			c := *msg
+			copied = &c
+		}
+		if !partsCopied {
+			copied.Parts = append([]*llm.ContentPart(nil), msg.Parts...)
+			partsCopied = true
+		}
+		// The part type follows the sniffed content type
+		copied.Parts[j] = llm.NewArtifactPart(normalized)
+	}
+	if copied == nil {
+		return msg, nil
+	}
+	return copied, nil
+}
+
+// normalizeArtifact normalizes an artifact of a message, transcoding images to the formats
+// vision models accept
+func normalizeArtifact(artifact *llm.ModelArtifact, field string) (*llm.ModelArtifact, error) {
+	normalized, err := llm.NormalizeArtifact(artifact)
+	if err != nil {
+		return nil, err
+	}
+	if !IsImageArtifact(normalized) {
+		return normalized, nil
+	}
+	transcoded, err := llm.NormalizeArtifact(normalized, supportedImageTypes...)
+	if err != nil {
+		var validationErr *llm.ValidationError
+		if errors.As(err, &validationErr) {
+			return nil, llm.NewValidationError(field, "image must be PNG, JPEG, GIF or WebP: "+validationErr.Message, validationErr.Value)
+		}
+		return nil, err
+	}
+	return transcoded, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"github.com/easyagent-dev/llm"
+)
+
+// TotalTokens is the token usage reported by the Jina and Voyage APIs
+type TotalTokens struct {
+	TotalTokens int64 `json:"total_tokens"`
+}
+
+// Usage returns the usage of a request that consumed the tokens as input
+func (t TotalTokens) Usage() *llm.TokenUsage {
+	return &llm.TokenUsage{
+		TotalInputTokens: t.TotalTokens,
+		TotalRequests:    1,
+	}
+}
+
+// DefaultEmbeddingModel returns req with its model set to model when empty. The request is
+// copied, so a request reused by the caller keeps its empty model.
+func DefaultEmbeddingModel(req *llm.EmbeddingRequest, model string) *llm.EmbeddingRequest {
+	if req == nil || req.Model != "" {
+		return req
+	}
+	copied := *req
+	copied.Model = model
+	return &copied
+}
+
+// EmbeddingsResponse is the response of the OpenAI style embeddings endpoints of the Jina and
+// Voyage APIs
+type EmbeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Usage TotalTokens `json:"usage"`
+}
+
+// EmbeddingResponse converts the response to an llm.EmbeddingResponse priced with info
+func (r *EmbeddingsResponse) EmbeddingResponse(info *llm.ModelInfo) *llm.EmbeddingResponse {
+	embeddings := make([]llm.Embedding, len(r.Data))
+	for i, data := range r.Data {
+		embeddings[i] = llm.Embedding{
+			Index:     data.Index,
+			Embedding: data.Embedding,
+			Object:    "embedding",
+		}
+	}
+	usage := r.Usage.Usage()
+	cost, breakdown := CalculateCostBreakdown(info, usage)
+	return &llm.EmbeddingResponse{
+		Embeddings:    embeddings,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}
+}
@@ -0,0 +1,21 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultEmbeddingModel(t *testing.T) {
+	assert.Nil(t, DefaultEmbeddingModel(nil, "m"))
+
+	req := &llm.EmbeddingRequest{Contents: []string{"a"}}
+	defaulted := DefaultEmbeddingModel(req, "m")
+	assert.Equal(t, "m", defaulted.Model)
+	assert.Equal(t, req.Contents, defaulted.Contents)
+	assert.Empty(t, req.Model, "the request of the caller is not modified")
+
+	named := &llm.EmbeddingRequest{Model: "other"}
+	assert.Same(t, named, DefaultEmbeddingModel(named, "m"))
+}
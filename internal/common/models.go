@@ -0,0 +1,50 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// ListModelsTimeout bounds ListModels when its context has no deadline
+const ListModelsTimeout = 30 * time.Second
+
+// ListModels gets the models endpoint of an OpenAI compatible API and decodes the response
+// into out. The request is made with the request options of the provider, so its base URL,
+// API key, HTTP client and SDK retries apply. Error statuses are returned as a
+// llm.RequestError.
+func ListModels(ctx context.Context, provider string, requestOpts []option.RequestOption, out any) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ListModelsTimeout)
+		defer cancel()
+	}
+
+	client := openai.NewClient(requestOpts...)
+	err := client.Get(ctx, "models", nil, out)
+	if err == nil {
+		return nil
+	}
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		reqErr := &llm.RequestError{
+			Provider:   provider,
+			StatusCode: apiErr.StatusCode,
+			Message:    "failed to list models",
+			Err:        err,
+		}
+		if apiErr.Response != nil {
+			reqErr.RetryAfter = llm.ParseRetryAfter(apiErr.Response.Header)
+		}
+		return reqErr
+	}
+	return fmt.Errorf("failed to list models: %w", err)
+}
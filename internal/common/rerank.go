@@ -0,0 +1,55 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/easyagent-dev/llm"
+)
+
+// ValidateRerankRequest validates a rerank request with detailed errors
+func ValidateRerankRequest(req *llm.RerankRequest) error {
+	if req == nil {
+		return llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	if req.Query == "" {
+		return llm.NewValidationError("query", "cannot be empty", nil)
+	}
+	if len(req.Documents) == 0 {
+		return llm.NewValidationError("documents", "must contain at least one item", nil)
+	}
+	if req.TopN < 0 {
+		return llm.NewValidationError("topN", "must be a positive number or 0 for all documents", req.TopN)
+	}
+	return nil
+}
+
+// ScoredDocument is the relevance score a provider returned for the document at Index, in the
+// JSON form shared by the Cohere, Jina and Voyage rerank APIs
+type ScoredDocument struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+// RerankResults resolves scored documents of a rerank request to results ordered by descending
+// relevance. Indexes outside of the request's documents are returned as a ResponseError.
+func RerankResults(provider string, req *llm.RerankRequest, scored []ScoredDocument) ([]llm.RerankResult, error) {
+	results := make([]llm.RerankResult, 0, len(scored))
+	for _, s := range scored {
+		if s.Index < 0 || s.Index >= len(req.Documents) {
+			return nil, llm.NewResponseError(provider, fmt.Sprintf("result index %d is out of range", s.Index), nil)
+		}
+		results = append(results, llm.RerankResult{
+			Index:          s.Index,
+			Document:       req.Documents[s.Index],
+			RelevanceScore: s.RelevanceScore,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].RelevanceScore > results[j].RelevanceScore
+	})
+	return results, nil
+}
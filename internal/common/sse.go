@@ -0,0 +1,103 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// IsEventStream reports whether resp is a server-sent events stream
+func IsEventStream(resp *http.Response) bool {
+	return resp != nil && strings.HasPrefix(strings.ToLower(resp.Header.Get("Content-Type")), "text/event-stream")
+}
+
+// NewEventStreamReader wraps the body of a server-sent events stream to drop the frames that
+// carry no data, so decoders that dispatch every frame do not fail on them: comments and
+// keep-alives such as ": ping", empty events from repeated blank lines, and events with an
+// empty data field. JSON lines sent without a "data:" prefix are read as data, and a last
+// frame that is not followed by a blank line is terminated.
+func NewEventStreamReader(body io.ReadCloser) io.ReadCloser {
+	return &eventStreamReader{body: body, reader: bufio.NewReader(body)}
+}
+
+type eventStreamReader struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	// frame and line are reused across frames, pending is the unread part of frame
+	frame   bytes.Buffer
+	line    []byte
+	pending []byte
+	err     error
+}
+
+func (r *eventStreamReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.pending, r.err = r.nextFrame()
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// nextFrame reads the next frame, returning it with the blank line that ends it, or nothing
+// if it carries no data
+func (r *eventStreamReader) nextFrame() ([]byte, error) {
+	frame := &r.frame
+	frame.Reset()
+	hasData := false
+	for {
+		line, err := r.readLine()
+		content := bytes.TrimRight(line, "\r\n")
+		switch {
+		case len(content) == 0:
+		case content[0] == ':':
+			// Comments are keep-alives
+		case content[0] == '{' || content[0] == '[':
+			frame.WriteString("data: ")
+			frame.Write(content)
+			frame.WriteByte('\n')
+			hasData = true
+		default:
+			if value, ok := bytes.CutPrefix(content, []byte("data:")); ok && len(bytes.TrimSpace(value)) > 0 {
+				hasData = true
+			}
+			frame.Write(content)
+			frame.WriteByte('\n')
+		}
+
+		if err != nil || len(content) == 0 {
+			if !hasData {
+				return nil, err
+			}
+			frame.WriteByte('\n')
+			return frame.Bytes(), err
+		}
+	}
+}
+
+// readLine reads the next line, which is only valid until the next read. Lines that fit in the
+// read buffer are not copied.
+func (r *eventStreamReader) readLine() ([]byte, error) {
+	line, err := r.reader.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return line, err
+	}
+	r.line = append(r.line[:0], line...)
+	for err == bufio.ErrBufferFull {
+		line, err = r.reader.ReadSlice('\n')
+		r.line = append(r.line, line...)
+	}
+	return r.line, err
+}
+
+func (r *eventStreamReader) Close() error {
+	return r.body.Close()
+}
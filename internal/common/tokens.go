@@ -0,0 +1,51 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"github.com/easyagent-dev/llm"
+)
+
+// CountTokens estimates the size and input cost of req for a model. Counts of a
+// HeuristicTokenizer are marked Estimated.
+func CountTokens(modelInfo *llm.ModelInfo, tokenizer llm.Tokenizer, req *llm.CompletionRequest) *llm.TokenCount {
+	_, estimated := tokenizer.(llm.HeuristicTokenizer)
+	count := &llm.TokenCount{
+		InputTokens:   llm.CountRequestTokens(tokenizer, req),
+		Estimated:     estimated,
+		ContextWindow: modelInfo.ContextWindow,
+		Currency:      modelInfo.Pricing.Currency,
+	}
+	count.Cost = CalculateCost(modelInfo, &llm.TokenUsage{TotalInputTokens: int64(count.InputTokens)})
+	return count
+}
+
+// CheckContextWindow returns a ContextWindowError when the check is enabled and req, plus the
+// requested maximum output tokens, does not fit the model's context window, the extended one
+// when it is enabled
+func CheckContextWindow(modelInfo *llm.ModelInfo, tokenizer llm.Tokenizer, req *llm.CompletionRequest, opts *llm.CompletionOptions) error {
+	window := modelInfo.ContextWindowFor(opts)
+	if !opts.ChecksContextWindow() || window <= 0 {
+		return nil
+	}
+	tokens := llm.CountRequestTokens(tokenizer, req)
+	if opts.MaxOutputTokens != nil {
+		tokens += *opts.MaxOutputTokens
+	} else if opts.MaxTokens != nil {
+		tokens += *opts.MaxTokens
+	}
+	if tokens > window {
+		return &llm.ContextWindowError{Model: modelInfo.ID, Tokens: tokens, ContextWindow: window}
+	}
+	return nil
+}
+
+// CheckExtendedContext returns an UnsupportedCapabilityError when the extended context window
+// is enabled for a model without one
+func CheckExtendedContext(provider string, modelInfo *llm.ModelInfo, opts *llm.CompletionOptions) error {
+	if opts.UsesExtendedContext() && modelInfo.ExtendedContextWindow <= 0 {
+		return llm.NewUnsupportedCapabilityError(provider, "extended context with "+modelInfo.ID)
+	}
+	return nil
+}
@@ -10,33 +10,29 @@ import (
 
 // CalculateCost calculates the cost based on token usage and model pricing information
 // This function is shared across all model implementations
-// With float64 pricing, no parsing or caching needed - direct calculation!
+// Components are accumulated as exact MicroCents so totals are deterministic
 func CalculateCost(modelInfo *llm.ModelInfo, usage *llm.TokenUsage) *float64 {
 	if modelInfo == nil || usage == nil {
 		return nil
 	}
 
-	const tokensPerMillion = 1000000.0
-	totalCost := 0.0
-
-	// Calculate input token costs
-	if modelInfo.Pricing.InputCacheRead > 0.0 {
-		totalInputTokens := usage.TotalInputTokens - usage.TotalCacheReadTokens
-		totalCost += (float64(totalInputTokens) / tokensPerMillion) * modelInfo.Pricing.Prompt
-		totalCost += (float64(usage.TotalCacheReadTokens) / tokensPerMillion) * modelInfo.Pricing.InputCacheRead
-	} else {
-		totalCost += (float64(usage.TotalInputTokens) / tokensPerMillion) * modelInfo.Pricing.Prompt
-	}
+	totalCost := CalculateCostMicroCents(modelInfo, usage).USD()
+	return &totalCost
+}
 
-	// Calculate internal reasoning token costs
-	if modelInfo.Pricing.InternalReasoning > 0.0 {
-		totalCost += (float64(usage.TotalReasoningTokens) / tokensPerMillion) * modelInfo.Pricing.InternalReasoning
+// CalculateCostBreakdown calculates the cost like CalculateCost, along with its breakdown
+func CalculateCostBreakdown(modelInfo *llm.ModelInfo, usage *llm.TokenUsage) (*float64, *llm.CostBreakdown) {
+	breakdown := modelInfo.CostBreakdown(usage)
+	if breakdown == nil {
+		return nil, nil
 	}
+	totalCost := breakdown.Total().USD()
+	return &totalCost, breakdown
+}
 
-	// Calculate completion token costs
-	totalCost += (float64(usage.TotalOutputTokens) / tokensPerMillion) * modelInfo.Pricing.Completion
-
-	return &totalCost
+// CalculateCostMicroCents calculates the exact cost based on token usage and model pricing information
+func CalculateCostMicroCents(modelInfo *llm.ModelInfo, usage *llm.TokenUsage) llm.MicroCents {
+	return modelInfo.CostBreakdown(usage).Total()
 }
 
 // ValidateEmbeddingRequest validates llm request fields
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculateCostMicroCents(t *testing.T) {
+	pricing := llm.ModelPricing{Prompt: llm.NewPrice(3), Completion: llm.NewPrice(15), InputCacheRead: llm.NewPrice(0.3), InputCacheWrite: llm.NewPrice(3.75)}
+	usage := &llm.TokenUsage{
+		TotalInputTokens:      1_000_000,
+		TotalCacheReadTokens:  200_000,
+		TotalCacheWriteTokens: 100_000,
+		TotalOutputTokens:     10_000,
+	}
+
+	tests := []struct {
+		name    string
+		pricing llm.ModelPricing
+		want    float64
+	}{
+		// 700k prompt + 200k read + 100k written + 10k output
+		{name: "cache_read_and_write", pricing: pricing, want: 2.1 + 0.06 + 0.375 + 0.15},
+		// Cache writes without a price are billed as prompt tokens
+		{name: "cache_read", pricing: llm.ModelPricing{Prompt: llm.NewPrice(3), Completion: llm.NewPrice(15), InputCacheRead: llm.NewPrice(0.3)}, want: 2.4 + 0.06 + 0.15},
+		{name: "no_cache_pricing", pricing: llm.ModelPricing{Prompt: llm.NewPrice(3), Completion: llm.NewPrice(15)}, want: 3 + 0.15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost := CalculateCostMicroCents(&llm.ModelInfo{Pricing: tt.pricing}, usage)
+			assert.Equal(t, llm.MicroCentsFromUSD(tt.want), cost)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/easyagent-dev/llm"
 	"net/url"
+	"slices"
 	"strings"
 )
 
@@ -69,6 +70,15 @@ func validateMessage(msg *llm.ModelMessage, index int) error {
 		)
 	}
 
+	if len(msg.Parts) > 0 {
+		for j, part := range msg.Parts {
+			if err := validateContentPart(part, fmt.Sprintf("messages[%d].parts[%d]", index, j)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	// Content can be empty for tool calls, but check if both content and tool call are empty
 	if msg.Content == "" && msg.ToolCall == nil && len(msg.Artifacts) == 0 {
 		return llm.NewValidationError(
@@ -80,7 +90,7 @@ func validateMessage(msg *llm.ModelMessage, index int) error {
 
 	// Validate artifacts if present
 	for j, artifact := range msg.Artifacts {
-		if err := validateArtifact(artifact, index, j); err != nil {
+		if err := validateArtifact(artifact, fmt.Sprintf("messages[%d].artifacts[%d]", index, j)); err != nil {
 			return err
 		}
 	}
@@ -88,11 +98,34 @@ func validateMessage(msg *llm.ModelMessage, index int) error {
 	return nil
 }
 
+// validateContentPart validates a message content part
+func validateContentPart(part *llm.ContentPart, field string) error {
+	if part == nil {
+		return llm.NewValidationError(field, "cannot be nil", nil)
+	}
+
+	switch part.Type {
+	case llm.ContentPartText:
+		if part.Text == "" {
+			return llm.NewValidationError(field+".text", "cannot be empty", "")
+		}
+	case llm.ContentPartImage, llm.ContentPartAudio, llm.ContentPartFile:
+		return validateArtifact(part.Artifact, field+".artifact")
+	case llm.ContentPartToolResult:
+		if part.ToolCall == nil {
+			return llm.NewValidationError(field+".toolCall", "cannot be nil", nil)
+		}
+	default:
+		return llm.NewValidationError(field+".type", "must be one of: text, image, audio, file, tool_result", string(part.Type))
+	}
+	return nil
+}
+
 // validateArtifact validates a model artifact
-func validateArtifact(artifact *llm.ModelArtifact, msgIndex, artifactIndex int) error {
+func validateArtifact(artifact *llm.ModelArtifact, field string) error {
 	if artifact == nil {
 		return llm.NewValidationError(
-			fmt.Sprintf("messages[%d].artifacts[%d]", msgIndex, artifactIndex),
+			field,
 			"cannot be nil",
 			nil,
 		)
@@ -100,7 +133,7 @@ func validateArtifact(artifact *llm.ModelArtifact, msgIndex, artifactIndex int)
 
 	if artifact.Name == "" {
 		return llm.NewValidationError(
-			fmt.Sprintf("messages[%d].artifacts[%d].name", msgIndex, artifactIndex),
+			field+".name",
 			"cannot be empty",
 			"",
 		)
@@ -108,12 +141,64 @@ func validateArtifact(artifact *llm.ModelArtifact, msgIndex, artifactIndex int)
 
 	if artifact.ContentType == "" {
 		return llm.NewValidationError(
-			fmt.Sprintf("messages[%d].artifacts[%d].contentType", msgIndex, artifactIndex),
+			field+".contentType",
 			"cannot be empty",
 			"",
 		)
 	}
 
+	if len(artifact.Content) == 0 && artifact.URL == "" {
+		return llm.NewValidationError(
+			field,
+			"must have either content or url",
+			nil,
+		)
+	}
+
+	return nil
+}
+
+// supportedImageTypes are the inline image formats accepted by vision models
+var supportedImageTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// ValidateMessageInputs checks that image and audio content in messages is supported by the
+// model's declared input media types, and that inline images use a supported format
+func ValidateMessageInputs(modelInfo *llm.ModelInfo, messages []*llm.ModelMessage) error {
+	if modelInfo == nil {
+		return nil
+	}
+	for i, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		artifactIndex := -1
+		for j, part := range msg.ContentParts() {
+			if part == nil || part.Artifact == nil {
+				continue
+			}
+			artifactIndex++
+			field := fmt.Sprintf("messages[%d].parts[%d]", i, j)
+			if len(msg.Parts) == 0 {
+				field = fmt.Sprintf("messages[%d].artifacts[%d]", i, artifactIndex)
+			}
+
+			var mediaType llm.ModelMediaType
+			switch part.Type {
+			case llm.ContentPartImage:
+				mediaType = llm.ModelMediaTypeImage
+				if len(part.Artifact.Content) > 0 && !slices.Contains(supportedImageTypes, part.Artifact.ContentType) {
+					return llm.NewValidationError(field, "image must be PNG, JPEG, GIF or WebP", part.Artifact.ContentType)
+				}
+			case llm.ContentPartAudio:
+				mediaType = llm.ModelMediaTypeAudio
+			default:
+				continue
+			}
+			if !modelInfo.AcceptsInput(mediaType) {
+				return llm.NewValidationError(field, fmt.Sprintf("model %s does not accept %s input", modelInfo.ID, mediaType), part.Artifact.Name)
+			}
+		}
+	}
 	return nil
 }
 
@@ -286,6 +371,24 @@ func validateEmbeddingConfig(config *llm.EmbeddingModelConfig) error {
 		}
 	}
 
+	// Validate task type
+	if config.TaskType != "" {
+		validTaskTypes := map[llm.EmbeddingTaskType]bool{
+			llm.EmbeddingTaskSearchDocument:     true,
+			llm.EmbeddingTaskSearchQuery:        true,
+			llm.EmbeddingTaskClassification:     true,
+			llm.EmbeddingTaskClustering:         true,
+			llm.EmbeddingTaskSemanticSimilarity: true,
+		}
+		if !validTaskTypes[config.TaskType] {
+			return llm.NewValidationError(
+				"taskType",
+				"must be one of: search_document, search_query, classification, clustering, semantic_similarity",
+				string(config.TaskType),
+			)
+		}
+	}
+
 	return nil
 }
 
@@ -313,6 +416,17 @@ func ValidateImageRequestWithDetails(req *llm.ImageRequest) error {
 	return nil
 }
 
+// ValidateImageInput validates the image, named field, of an image edit or variation request
+func ValidateImageInput(field string, image *llm.ModelArtifact) error {
+	if image == nil || (len(image.Content) == 0 && image.URL == "") {
+		return llm.NewValidationError(field, "cannot be empty", nil)
+	}
+	if image.ContentType != "" && !IsImageArtifact(image) {
+		return llm.NewValidationError(field, "must be an image", image.ContentType)
+	}
+	return nil
+}
+
 // validateImageConfig validates llm configuration
 func validateImageConfig(config *llm.ImageModelConfig) error {
 	if config == nil {
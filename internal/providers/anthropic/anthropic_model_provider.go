@@ -0,0 +1,755 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package anthropic
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+)
+
+//go:embed anthropic.json
+var anthropicModels []byte
+
+// contextBeta enables the 1M token context window of the models with an extended window
+const contextBeta = "context-1m-2025-08-07"
+
+// defaultMaxTokens is used when neither the options nor the model catalog set an output limit
+const defaultMaxTokens = 4096
+
+// thinkingBudgets maps reasoning effort to the extended thinking token budget
+var thinkingBudgets = map[llm.ReasoningEffort]int64{
+	llm.ReasoningEffortLow:    1024,
+	llm.ReasoningEffortMedium: 8192,
+	llm.ReasoningEffortHigh:   24576,
+}
+
+// AnthropicModelProvider provides Claude models through the native Anthropic Messages API
+type AnthropicModelProvider struct {
+	*llm.DefaultModelProvider
+	client anthropic.Client
+}
+
+var _ llm.ModelProvider = (*AnthropicModelProvider)(nil)
+
+// NewAnthropicModelProvider creates a new Anthropic model provider. Request options from the
+// OpenAI SDK set with WithRequestOption do not apply to this provider.
+func NewAnthropicModelProvider(opts ...llm.ModelOption) (*AnthropicModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+
+	requestOpts := []option.RequestOption{
+		option.WithAPIKey(config.APIKey),
+	}
+	if config.BaseURL != "" {
+		requestOpts = append(requestOpts, option.WithBaseURL(config.BaseURL))
+	}
+	if config.Retry != nil {
+		requestOpts = append(requestOpts, option.WithMaxRetries(0))
+	}
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(anthropicModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	provider := llm.NewDefaultModelProvider("anthropic", models)
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+
+	anthropicProvider := &AnthropicModelProvider{
+		DefaultModelProvider: provider,
+		client:               anthropic.NewClient(requestOpts...),
+	}
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, anthropicProvider)
+	return anthropicProvider, nil
+}
+
+func (p *AnthropicModelProvider) NewCompletionModel(model string, opts ...llm.CompletionOption) (llm.CompletionModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityCompletion) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "completions")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.DecorateCompletionModel(&AnthropicCompletionModel{
+		name:      model,
+		modelInfo: info,
+		client:    p.client,
+		options:   opts,
+		inflight:  p.InFlight(),
+	}, info, opts), nil
+}
+
+// AnthropicCompletionModel implements CompletionModel with the Anthropic Messages API
+type AnthropicCompletionModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	client    anthropic.Client
+	options   []llm.CompletionOption
+	inflight  *llm.InFlight
+}
+
+var _ llm.CompletionModel = (*AnthropicCompletionModel)(nil)
+
+// CountTokens estimates the size and input cost of req with the tokenizer registered for Claude models
+func (p *AnthropicCompletionModel) CountTokens(req *llm.CompletionRequest) (*llm.TokenCount, error) {
+	return common.CountTokens(p.modelInfo, p.tokenizer(), req), nil
+}
+
+func (p *AnthropicCompletionModel) tokenizer() llm.Tokenizer {
+	return llm.GetTokenizer(llm.EncodingClaude)
+}
+
+func (p *AnthropicCompletionModel) StreamComplete(ctx context.Context, req *llm.CompletionRequest) (llm.StreamCompletionResponse, error) {
+	opts, _ := normalizeDeterminism(p.modelInfo, llm.ApplyContextCompletionOptions(ctx, p.options))
+
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	req, err := common.NormalizeRequestArtifacts(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.ValidateMessageInputs(p.modelInfo, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := common.CheckExtendedContext("anthropic", p.modelInfo, opts); err != nil {
+		return nil, err
+	}
+	if err := common.CheckContextWindow(p.modelInfo, p.tokenizer(), req, opts); err != nil {
+		return nil, err
+	}
+
+	params, err := ToMessageNewParams(p.modelInfo, req, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message params: %w", err)
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := p.client.Messages.NewStreaming(ctx, params, requestOptions(opts)...)
+	// The request is sent when the stream is created, so failures to open it can be returned
+	if err := stream.Err(); err != nil {
+		done()
+		return nil, requestError("failed to stream message", err)
+	}
+	chunkChan := make(chan llm.StreamChunk, 1)
+	sendUsage := opts.WithUsage != nil && *opts.WithUsage && opts.StreamsEvent(llm.UsageChunkType)
+
+	go func() {
+		defer done()
+		defer close(chunkChan)
+		defer llm.SendStreamCanceled(ctx, chunkChan, "anthropic")
+		defer llm.CloseOnDone(ctx, stream)()
+
+		message := anthropic.Message{}
+		for stream.Next() {
+			event := stream.Current()
+			if err := message.Accumulate(event); err != nil {
+				select {
+				case chunkChan <- llm.StreamErrorChunk{Provider: "anthropic", Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			var chunk llm.StreamChunk
+			switch event.Type {
+			case "content_block_delta":
+				switch event.Delta.Type {
+				case "text_delta":
+					chunk = opts.TextChunk(event.Delta.Text)
+				case "thinking_delta":
+					chunk = llm.StreamReasoningChunk{Reasoning: event.Delta.Thinking}
+				}
+			case "content_block_stop":
+				if search := webSearch(message.Content, len(message.Content)-1); search != nil {
+					chunk = llm.StreamWebSearchChunk{Search: search}
+				} else if block := message.Content[len(message.Content)-1]; block.Type == "tool_use" {
+					text, err := toolUseText(block)
+					if err != nil {
+						chunk = llm.StreamErrorChunk{Provider: "anthropic", Err: err}
+					} else {
+						chunk = opts.TextChunk(text)
+					}
+				}
+			case "message_delta":
+				// The accumulated message only tracks output tokens from the final usage
+				if event.Usage.JSON.ServerToolUse.Valid() {
+					message.Usage.ServerToolUse = event.Usage.ServerToolUse
+				}
+			}
+			if chunk == nil || !opts.StreamsEvent(chunk.Type()) {
+				continue
+			}
+			select {
+			case chunkChan <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := stream.Err(); err != nil {
+			if ctx.Err() != nil {
+				if llm.IsShuttingDown(ctx) && sendUsage {
+					select {
+					case chunkChan <- p.usageChunk(message.Usage, 1, opts):
+					default:
+					}
+				}
+				return
+			}
+			select {
+			case chunkChan <- llm.StreamErrorChunk{Provider: "anthropic", Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		if opts.StreamsEvent(llm.FinishChunkType) {
+			select {
+			case chunkChan <- llm.StreamFinishChunk{Reason: finishReason(message.StopReason), ID: message.ID}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if sendUsage {
+			select {
+			case chunkChan <- p.usageChunk(message.Usage, 1, opts):
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return chunkChan, nil
+}
+
+func (p *AnthropicCompletionModel) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	opts, determinism := normalizeDeterminism(p.modelInfo, llm.ApplyContextCompletionOptions(ctx, p.options))
+
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	req, err := common.NormalizeRequestArtifacts(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.ValidateMessageInputs(p.modelInfo, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := common.CheckExtendedContext("anthropic", p.modelInfo, opts); err != nil {
+		return nil, err
+	}
+	if err := common.CheckContextWindow(p.modelInfo, p.tokenizer(), req, opts); err != nil {
+		return nil, err
+	}
+
+	params, err := ToMessageNewParams(p.modelInfo, req, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message params: %w", err)
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	resp, err := p.client.Messages.New(ctx, params, requestOptions(opts)...)
+	if err != nil {
+		return nil, requestError("failed to create message", err)
+	}
+
+	var sb strings.Builder
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			sb.WriteString(block.Text)
+		case "tool_use":
+			text, err := toolUseText(block)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(text)
+		}
+	}
+	// A refusal may come without text, and is reported by its finish reason
+	if sb.Len() == 0 && resp.StopReason != anthropic.StopReasonRefusal {
+		return nil, llm.ErrEmptyContent
+	}
+
+	response := &llm.CompletionResponse{Output: sb.String(), FinishReason: finishReason(resp.StopReason), ID: resp.ID}
+	for i := range resp.Content {
+		if search := webSearch(resp.Content, i); search != nil {
+			response.WebSearches = append(response.WebSearches, search)
+		}
+	}
+	if opts.WithUsage != nil && *opts.WithUsage {
+		chunk := p.usageChunk(resp.Usage, 1, opts)
+		response.Usage = chunk.Usage
+		response.Cost = chunk.Cost
+		response.CostBreakdown = chunk.CostBreakdown
+	}
+	if tier := serviceTier(resp.Usage.ServiceTier); determinism != "" || tier != "" {
+		response.Metadata = &llm.ResponseMetadata{Determinism: determinism, ServiceTier: tier}
+	}
+	return response, nil
+}
+
+// requestOptions returns the beta headers of the features enabled by opts
+func requestOptions(opts *llm.CompletionOptions) []option.RequestOption {
+	var reqOpts []option.RequestOption
+	if opts.UsesExtendedContext() {
+		reqOpts = append(reqOpts, option.WithHeaderAdd("anthropic-beta", contextBeta))
+	}
+	return reqOpts
+}
+
+// finishReason normalizes the stop reason of a message
+func finishReason(reason anthropic.StopReason) llm.FinishReason {
+	switch reason {
+	case anthropic.StopReasonEndTurn, anthropic.StopReasonStopSequence:
+		return llm.FinishReasonStop
+	case anthropic.StopReasonMaxTokens:
+		return llm.FinishReasonLength
+	case anthropic.StopReasonRefusal:
+		return llm.FinishReasonContentFilter
+	case anthropic.StopReasonToolUse:
+		return llm.FinishReasonToolCalls
+	}
+	return ""
+}
+
+// normalizeDeterminism pins sampling for deterministic requests since Anthropic has no seed.
+// Sampling parameters cannot be changed while extended thinking is enabled.
+func normalizeDeterminism(modelInfo *llm.ModelInfo, opts *llm.CompletionOptions) (*llm.CompletionOptions, llm.DeterminismLevel) {
+	thinking := opts.ReasoningEffort != nil && modelInfo.Reasoning
+	return llm.NormalizeDeterminism(opts, false, !thinking)
+}
+
+// usageChunk converts Anthropic usage, where input tokens exclude cache reads and writes, into
+// token usage with cost at the price of the service tier that served it if requested
+func (p *AnthropicCompletionModel) usageChunk(u anthropic.Usage, requests int, opts *llm.CompletionOptions) llm.StreamUsageChunk {
+	usage := &llm.TokenUsage{
+		TotalInputTokens:      u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens,
+		TotalOutputTokens:     u.OutputTokens,
+		TotalWebSearches:      int(u.ServerToolUse.WebSearchRequests),
+		TotalRequests:         requests,
+		TotalCacheReadTokens:  u.CacheReadInputTokens,
+		TotalCacheWriteTokens: u.CacheCreationInputTokens,
+	}
+
+	var cost *float64
+	var breakdown *llm.CostBreakdown
+	if opts.WithCost != nil && *opts.WithCost {
+		cost, breakdown = common.CalculateCostBreakdown(p.modelInfo.ForServiceTier(serviceTier(u.ServiceTier)), usage)
+	}
+	return llm.StreamUsageChunk{
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}
+}
+
+// serviceTier normalizes the service tier Anthropic reports in the usage of a message
+func serviceTier(tier anthropic.UsageServiceTier) llm.ServiceTier {
+	if tier == anthropic.UsageServiceTierStandard {
+		return llm.ServiceTierDefault
+	}
+	return llm.ServiceTier(tier)
+}
+
+// ToMessageNewParams converts a completion request into Anthropic message parameters. Extended
+// thinking is enabled for reasoning models when a reasoning effort is set.
+func ToMessageNewParams(modelInfo *llm.ModelInfo, req *llm.CompletionRequest, opts *llm.CompletionOptions) (anthropic.MessageNewParams, error) {
+	if req == nil {
+		return anthropic.MessageNewParams{}, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	if opts.N != nil && *opts.N > 1 {
+		return anthropic.MessageNewParams{}, llm.NewUnsupportedCapabilityError("anthropic", "multiple choices")
+	}
+
+	messages, err := ToMessageParams(req.Messages)
+	if err != nil {
+		return anthropic.MessageNewParams{}, err
+	}
+
+	maxTokens := int64(defaultMaxTokens)
+	if modelInfo.MaxOutputTokens > 0 {
+		maxTokens = int64(modelInfo.MaxOutputTokens)
+	}
+	if opts.MaxTokens != nil && *opts.MaxTokens > 0 {
+		maxTokens = int64(*opts.MaxTokens)
+	} else if opts.MaxOutputTokens != nil && *opts.MaxOutputTokens > 0 {
+		maxTokens = int64(*opts.MaxOutputTokens)
+	}
+
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.Model(modelInfo.ID),
+		MaxTokens: maxTokens,
+		Messages:  messages,
+	}
+
+	instructions := req.Instructions
+	if opts.OutputLanguage != nil && *opts.OutputLanguage != "" {
+		if instructions != "" {
+			instructions += "\n\n"
+		}
+		instructions += llm.OutputLanguageInstruction(*opts.OutputLanguage, false)
+	}
+	if instructions != "" {
+		system := anthropic.TextBlockParam{Text: instructions}
+		if opts.InstructionsCache != nil {
+			system.CacheControl = cacheControlParam(opts.InstructionsCache)
+		}
+		params.System = []anthropic.TextBlockParam{system}
+	}
+
+	thinking := false
+	if opts.ReasoningEffort != nil && modelInfo.Reasoning {
+		if budget, ok := thinkingBudgets[*opts.ReasoningEffort]; ok {
+			// The thinking budget counts towards max_tokens and must stay below it
+			if params.MaxTokens <= budget {
+				params.MaxTokens = budget + defaultMaxTokens
+			}
+			params.Thinking = anthropic.ThinkingConfigParamOfEnabled(budget)
+			thinking = true
+		}
+	}
+
+	// Sampling parameters cannot be changed while extended thinking is enabled
+	if !thinking {
+		if opts.Temperature != nil {
+			params.Temperature = anthropic.Float(*opts.Temperature)
+		}
+		if opts.TopP != nil {
+			params.TopP = anthropic.Float(*opts.TopP)
+		}
+	}
+	if len(opts.Stop) > 0 {
+		params.StopSequences = opts.Stop
+	}
+	// Priority capacity is used when available; Anthropic has no flex tier
+	if opts.ServiceTier != nil {
+		switch *opts.ServiceTier {
+		case llm.ServiceTierAuto, llm.ServiceTierPriority:
+			params.ServiceTier = anthropic.MessageNewParamsServiceTierAuto
+		case llm.ServiceTierDefault:
+			params.ServiceTier = anthropic.MessageNewParamsServiceTierStandardOnly
+		}
+	}
+	params.Tools = historyTools(req.Messages)
+	if opts.WebSearch != nil {
+		tool := &anthropic.WebSearchTool20250305Param{
+			AllowedDomains: opts.WebSearch.AllowedDomains,
+			BlockedDomains: opts.WebSearch.BlockedDomains,
+		}
+		if opts.WebSearch.MaxUses > 0 {
+			tool.MaxUses = anthropic.Int(int64(opts.WebSearch.MaxUses))
+		}
+		params.Tools = append(params.Tools, anthropic.ToolUnionParam{OfWebSearchTool20250305: tool})
+	}
+
+	return params, nil
+}
+
+// webSearch converts the web search result block at index into a WebSearch with the query
+// from its server tool use block. It returns nil for other blocks.
+func webSearch(content []anthropic.ContentBlockUnion, index int) *llm.WebSearch {
+	if index < 0 || index >= len(content) || content[index].Type != "web_search_tool_result" {
+		return nil
+	}
+	block := content[index].AsWebSearchToolResult()
+
+	search := &llm.WebSearch{Results: []*llm.WebSearchResult{}}
+	for _, c := range content[:index] {
+		if c.Type == "server_tool_use" && c.ID == block.ToolUseID {
+			var input struct {
+				Query string `json:"query"`
+			}
+			if err := json.Unmarshal(c.Input, &input); err == nil {
+				search.Query = input.Query
+			}
+		}
+	}
+
+	if block.Content.ErrorCode != "" {
+		search.Error = string(block.Content.ErrorCode)
+		return search
+	}
+	for _, r := range block.Content.AsWebSearchResultBlockArray() {
+		search.Results = append(search.Results, &llm.WebSearchResult{
+			URL:     r.URL,
+			Title:   r.Title,
+			PageAge: r.PageAge,
+		})
+	}
+	return search
+}
+
+// ToMessageParams converts messages into Anthropic message parameters. Tool calls of assistant
+// messages become tool_use blocks and tool results tool_result blocks, paired by the ID of the
+// call, or in order by tool name for calls without an ID. Results without a matching call are
+// sent as the fenced JSON text convention of the other providers.
+func ToMessageParams(messages []*llm.ModelMessage) ([]anthropic.MessageParam, error) {
+	result := make([]anthropic.MessageParam, 0, len(messages))
+	ids := &toolUseIDs{pending: make(map[string][]string)}
+	for i, msg := range messages {
+		if msg == nil {
+			return nil, llm.NewValidationError(fmt.Sprintf("messages[%d]", i), "cannot be nil", nil)
+		}
+
+		switch msg.Role {
+		case llm.RoleUser, llm.RoleTool:
+			blocks, err := contentBlocks(msg.ContentParts(), ids)
+			if err != nil {
+				return nil, err
+			}
+			if msg.CacheControl != nil && len(blocks) > 0 {
+				setCacheControl(&blocks[len(blocks)-1], msg.CacheControl)
+			}
+			result = append(result, anthropic.NewUserMessage(blocks...))
+
+		case llm.RoleAssistant:
+			var blocks []anthropic.ContentBlockParamUnion
+			if text := msg.Text(); text != "" || msg.ToolCall == nil {
+				blocks = append(blocks, anthropic.NewTextBlock(text))
+			}
+			if call := msg.ToolCall; call != nil {
+				input := call.Input
+				if input == nil {
+					input = map[string]any{}
+				}
+				blocks = append(blocks, anthropic.NewToolUseBlock(ids.use(call), input, call.Name))
+			}
+			if msg.CacheControl != nil {
+				setCacheControl(&blocks[len(blocks)-1], msg.CacheControl)
+			}
+			result = append(result, anthropic.NewAssistantMessage(blocks...))
+
+		default:
+			return nil, llm.NewValidationError("role", "unknown role", string(msg.Role))
+		}
+	}
+	return result, nil
+}
+
+// toolUseIDs pairs the tool_result blocks of a conversation with its tool_use blocks
+type toolUseIDs struct {
+	pending map[string][]string // IDs of the calls without a result, by tool name
+	next    int
+}
+
+// use returns the ID of the tool_use block of call, generating one for calls without an ID
+func (t *toolUseIDs) use(call *llm.ToolCall) string {
+	id := call.ID
+	if id == "" {
+		id = fmt.Sprintf("toolu_%d", t.next)
+		t.next++
+	}
+	t.pending[call.Name] = append(t.pending[call.Name], id)
+	return id
+}
+
+// result returns the ID of the tool_use block call is the result of, if any
+func (t *toolUseIDs) result(call *llm.ToolCall) (string, bool) {
+	pending := t.pending[call.Name]
+	for i, id := range pending {
+		if call.ID == "" || id == call.ID {
+			t.pending[call.Name] = append(pending[:i:i], pending[i+1:]...)
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// historyTools declares the tools called in messages, which Anthropic requires of requests
+// containing tool_use blocks. Their inputs are described by the instructions, so any object
+// is accepted.
+func historyTools(messages []*llm.ModelMessage) []anthropic.ToolUnionParam {
+	var tools []anthropic.ToolUnionParam
+	seen := make(map[string]bool)
+	for _, msg := range messages {
+		if msg == nil || msg.Role != llm.RoleAssistant || msg.ToolCall == nil || seen[msg.ToolCall.Name] {
+			continue
+		}
+		seen[msg.ToolCall.Name] = true
+		tools = append(tools, anthropic.ToolUnionParam{OfTool: &anthropic.ToolParam{
+			Name:        msg.ToolCall.Name,
+			InputSchema: anthropic.ToolInputSchemaParam{},
+		}})
+	}
+	return tools
+}
+
+// setCacheControl marks block as the end of a cached prefix
+func setCacheControl(block *anthropic.ContentBlockParamUnion, cc *llm.CacheControl) {
+	if param := block.GetCacheControl(); param != nil {
+		*param = cacheControlParam(cc)
+	}
+}
+
+// cacheControlParam converts a cache hint into an ephemeral cache breakpoint. Anthropic
+// supports lifetimes of 5 minutes and 1 hour; longer TTLs use the hour.
+func cacheControlParam(cc *llm.CacheControl) anthropic.CacheControlEphemeralParam {
+	param := anthropic.NewCacheControlEphemeralParam()
+	if cc.TTL > 5*time.Minute {
+		param.TTL = anthropic.CacheControlEphemeralTTLTTL1h
+	}
+	return param
+}
+
+// ToContentBlocks converts ordered message content parts into Anthropic content blocks.
+// Images become image blocks, PDFs and text files become document blocks, and other files
+// are referenced by name. Tool results with an ID become tool_result blocks.
+func ToContentBlocks(parts []*llm.ContentPart) ([]anthropic.ContentBlockParamUnion, error) {
+	return contentBlocks(parts, nil)
+}
+
+// contentBlocks converts content parts like ToContentBlocks, pairing tool results with the
+// tool_use blocks of ids when set. Tool results come first, as Anthropic requires.
+func contentBlocks(parts []*llm.ContentPart, ids *toolUseIDs) ([]anthropic.ContentBlockParamUnion, error) {
+	results := make([]anthropic.ContentBlockParamUnion, 0, 1)
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(parts))
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+		switch part.Type {
+		case llm.ContentPartText:
+			blocks = append(blocks, anthropic.NewTextBlock(part.Text))
+		case llm.ContentPartToolResult:
+			id, ok := part.ToolCall.ID, part.ToolCall.ID != ""
+			if ids != nil {
+				id, ok = ids.result(part.ToolCall)
+			}
+			if !ok {
+				text, err := toolCallText("call tool results: ", part.ToolCall)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal tool call results: %w", err)
+				}
+				blocks = append(blocks, anthropic.NewTextBlock(text))
+				continue
+			}
+			block, err := toolResultBlock(id, part.ToolCall)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tool call results: %w", err)
+			}
+			results = append(results, block)
+		default:
+			if part.Artifact != nil {
+				blocks = append(blocks, toArtifactBlock(part.Artifact))
+			}
+		}
+	}
+	return append(results, blocks...), nil
+}
+
+// toolResultBlock converts the result of a completed call into a tool_result block, flagged as
+// an error when the call failed
+func toolResultBlock(id string, call *llm.ToolCall) (anthropic.ContentBlockParamUnion, error) {
+	if call.ErrorMessage != nil {
+		return anthropic.NewToolResultBlock(id, *call.ErrorMessage, true), nil
+	}
+	content, ok := call.Output.(string)
+	if !ok {
+		output, err := json.Marshal(call.Output)
+		if err != nil {
+			return anthropic.ContentBlockParamUnion{}, err
+		}
+		content = string(output)
+	}
+	return anthropic.NewToolResultBlock(id, content, false), nil
+}
+
+func toArtifactBlock(artifact *llm.ModelArtifact) anthropic.ContentBlockParamUnion {
+	switch {
+	case common.IsImageArtifact(artifact):
+		if artifact.URL != "" {
+			return anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: artifact.URL})
+		}
+		return anthropic.NewImageBlockBase64(artifact.ContentType, base64.StdEncoding.EncodeToString(artifact.Content))
+	case artifact.ContentType == "application/pdf":
+		if artifact.URL != "" {
+			return anthropic.NewDocumentBlock(anthropic.URLPDFSourceParam{URL: artifact.URL})
+		}
+		return anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: base64.StdEncoding.EncodeToString(artifact.Content)})
+	case strings.HasPrefix(artifact.ContentType, "text/") && len(artifact.Content) > 0:
+		return anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: string(artifact.Content)})
+	}
+	return anthropic.NewTextBlock(fmt.Sprintf("[attachment %s (%s) is not supported]", artifact.Name, artifact.ContentType))
+}
+
+// toolUseText formats a tool_use block the model replied with as the fenced JSON tool call
+// text of the other providers, which ParseToolCalls reads
+func toolUseText(block anthropic.ContentBlockUnion) (string, error) {
+	call := &llm.ToolCall{ID: block.ID, Name: block.Name}
+	if len(block.Input) > 0 {
+		if err := json.Unmarshal(block.Input, &call.Input); err != nil {
+			return "", fmt.Errorf("failed to decode tool call input: %w", err)
+		}
+	}
+	text, err := toolCallText("call tool: ", call)
+	if err != nil {
+		return "", err
+	}
+	return "\n" + text + "\n", nil
+}
+
+// toolCallText formats a tool call as the fenced JSON text sent to the model
+func toolCallText(prefix string, call *llm.ToolCall) (string, error) {
+	jsonBytes, err := json.Marshal(call)
+	if err != nil {
+		return "", err
+	}
+	return prefix + "```" + string(jsonBytes) + "```", nil
+}
+
+// requestError converts Anthropic API errors to RequestError so callers and retries can
+// inspect the status code and Retry-After delay
+func requestError(message string, err error) error {
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+	reqErr := &llm.RequestError{
+		Provider:   "anthropic",
+		StatusCode: apiErr.StatusCode,
+		Message:    message,
+		Err:        err,
+	}
+	if apiErr.Response != nil {
+		reqErr.RetryAfter = llm.ParseRetryAfter(apiErr.Response.Header)
+	}
+	return reqErr
+}
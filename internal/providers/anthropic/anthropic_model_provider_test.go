@@ -0,0 +1,659 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAnthropicModelProvider(t *testing.T) {
+	_, err := NewAnthropicModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	provider, err := NewAnthropicModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "anthropic", provider.Name())
+	assert.NotEmpty(t, provider.SupportedModels())
+
+	_, err = provider.NewCompletionModel("unknown-model")
+	assert.ErrorIs(t, err, llm.ErrInvalidModel)
+	_, err = provider.NewEmbeddingModel("claude-sonnet-4-5")
+	assert.Error(t, err)
+}
+
+func TestAnthropicCompletionModel_NilRequest(t *testing.T) {
+	provider, err := NewAnthropicModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("claude-3-5-haiku-latest")
+	require.NoError(t, err)
+
+	var validationErr *llm.ValidationError
+	_, err = model.Complete(context.Background(), nil)
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = model.StreamComplete(context.Background(), nil)
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestToMessageNewParams(t *testing.T) {
+	info := &llm.ModelInfo{ID: "claude-sonnet-4-5", Reasoning: true, MaxOutputTokens: 64000}
+	req := &llm.CompletionRequest{
+		Instructions: "Be brief.",
+		Messages: []*llm.ModelMessage{
+			{Role: llm.RoleUser, Content: "Describe", Artifacts: []*llm.ModelArtifact{
+				{Name: "a.png", ContentType: "image/png", Content: []byte{0x89}},
+				{Name: "a.pdf", ContentType: "application/pdf", URL: "https://example.com/a.pdf"},
+			}},
+			{Role: llm.RoleAssistant, ToolCall: &llm.ToolCall{ID: "1", Name: "search"}},
+			{Role: llm.RoleTool, ToolCall: &llm.ToolCall{ID: "1", Name: "search", Output: "ok"}},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		opts         []llm.CompletionOption
+		wantThinking bool
+		wantTemp     bool
+		wantMax      int64
+	}{
+		{name: "defaults", wantMax: 64000},
+		{name: "sampling", opts: []llm.CompletionOption{llm.WithTemperature(0.2), llm.WithMaxTokens(512)}, wantTemp: true, wantMax: 512},
+		{
+			name:         "thinking",
+			opts:         []llm.CompletionOption{llm.WithReasoningEffort(llm.ReasoningEffortLow), llm.WithTemperature(0.2), llm.WithMaxTokens(512)},
+			wantThinking: true,
+			wantMax:      1024 + defaultMaxTokens,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := ToMessageNewParams(info, req, llm.ApplyCompletionOptions(tt.opts))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantMax, params.MaxTokens)
+			assert.Equal(t, tt.wantThinking, params.Thinking.OfEnabled != nil)
+			assert.Equal(t, tt.wantTemp, params.Temperature.Valid())
+			require.Len(t, params.System, 1)
+			assert.Equal(t, "Be brief.", params.System[0].Text)
+
+			require.Len(t, params.Messages, 3)
+			blocks := params.Messages[0].Content
+			require.Len(t, blocks, 3)
+			assert.NotNil(t, blocks[1].OfImage)
+			assert.NotNil(t, blocks[2].OfDocument)
+			require.NotNil(t, params.Messages[1].Content[0].OfToolUse)
+			assert.Equal(t, "1", params.Messages[1].Content[0].OfToolUse.ID)
+			require.NotNil(t, params.Messages[2].Content[0].OfToolResult)
+			assert.Equal(t, "1", params.Messages[2].Content[0].OfToolResult.ToolUseID)
+			require.Len(t, params.Tools, 1)
+			assert.Equal(t, "search", params.Tools[0].OfTool.Name)
+		})
+	}
+}
+
+func TestToMessageParams_ToolCalls(t *testing.T) {
+	failed := "city not found"
+	messages, err := ToMessageParams([]*llm.ModelMessage{
+		{Role: llm.RoleUser, Content: "Weather in Paris and Rome?"},
+		{Role: llm.RoleAssistant, Content: "Checking.", ToolCall: &llm.ToolCall{Name: "weather", Input: map[string]any{"city": "Paris"}}},
+		{Role: llm.RoleAssistant, ToolCall: &llm.ToolCall{Name: "weather", Input: map[string]any{"city": "Rome"}}},
+		{Role: llm.RoleTool, ToolCall: &llm.ToolCall{Name: "weather", Output: map[string]any{"temp": 21}}},
+		{Role: llm.RoleTool, ToolCall: &llm.ToolCall{Name: "weather", ErrorMessage: &failed}},
+		// A result without a call is kept as text
+		{Role: llm.RoleTool, ToolCall: &llm.ToolCall{Name: "clock", Output: "noon"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, messages, 6)
+
+	// Parallel calls without IDs are paired with their results in order
+	require.Len(t, messages[1].Content, 2)
+	assert.Equal(t, "Checking.", messages[1].Content[0].OfText.Text)
+	paris := messages[1].Content[1].OfToolUse
+	rome := messages[2].Content[0].OfToolUse
+	require.NotNil(t, paris)
+	require.NotNil(t, rome)
+	assert.Equal(t, map[string]any{"city": "Paris"}, paris.Input)
+
+	result := messages[3].Content[0].OfToolResult
+	require.NotNil(t, result)
+	assert.Equal(t, paris.ID, result.ToolUseID)
+	assert.Equal(t, `{"temp":21}`, result.Content[0].OfText.Text)
+	failure := messages[4].Content[0].OfToolResult
+	require.NotNil(t, failure)
+	assert.Equal(t, rome.ID, failure.ToolUseID)
+	assert.True(t, failure.IsError.Value)
+	assert.Contains(t, messages[5].Content[0].OfText.Text, "call tool results: ")
+}
+
+func TestAnthropicCompletionModel_ToolUse(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-latest",
+			"content":[{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}},
+				{"type":"tool_use","id":"toolu_2","name":"weather","input":{"city":"Rome"}}],
+			"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`)
+	})
+	model, err := provider.NewCompletionModel("claude-3-5-haiku-latest")
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Weather?"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, llm.FinishReasonToolCalls, resp.FinishReason)
+	calls := llm.ParseToolCalls(resp.Output)
+	require.Len(t, calls, 2)
+	assert.Equal(t, "toolu_1", calls[0].ID)
+	assert.Equal(t, map[string]any{"city": "Rome"}, calls[1].Input)
+}
+
+func TestToMessageNewParams_CacheControl(t *testing.T) {
+	info := &llm.ModelInfo{ID: "claude-sonnet-4-5"}
+	document := &llm.ModelMessage{Role: llm.RoleUser, Content: "Long document"}
+	req := &llm.CompletionRequest{
+		Instructions: "Answer questions about the document.",
+		Messages: []*llm.ModelMessage{
+			document.WithCacheControl(llm.CacheTTLHour),
+			{Role: llm.RoleAssistant, Content: "Read it.", CacheControl: &llm.CacheControl{}},
+			{Role: llm.RoleUser, Content: "Summarize"},
+		},
+	}
+
+	params, err := ToMessageNewParams(info, req, llm.ApplyCompletionOptions([]llm.CompletionOption{
+		llm.WithInstructionsCache(llm.CacheTTLHour),
+	}))
+	require.NoError(t, err)
+
+	body, err := json.Marshal(params)
+	require.NoError(t, err)
+	var sent struct {
+		System []struct {
+			CacheControl map[string]any `json:"cache_control"`
+		} `json:"system"`
+		Messages []struct {
+			Content []struct {
+				CacheControl map[string]any `json:"cache_control"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(body, &sent))
+
+	require.Len(t, sent.System, 1)
+	assert.Equal(t, map[string]any{"type": "ephemeral", "ttl": "1h"}, sent.System[0].CacheControl)
+	require.Len(t, sent.Messages, 3)
+	assert.Equal(t, map[string]any{"type": "ephemeral", "ttl": "1h"}, sent.Messages[0].Content[0].CacheControl)
+	assert.Equal(t, map[string]any{"type": "ephemeral"}, sent.Messages[1].Content[0].CacheControl)
+	assert.Nil(t, sent.Messages[2].Content[0].CacheControl)
+}
+
+func TestNormalizeDeterminism(t *testing.T) {
+	info := &llm.ModelInfo{ID: "claude-sonnet-4-5", Reasoning: true}
+
+	opts, level := normalizeDeterminism(info, llm.ApplyCompletionOptions([]llm.CompletionOption{
+		llm.WithTemperature(0.7), llm.WithDeterministic(1),
+	}))
+	assert.Equal(t, llm.DeterminismPinned, level)
+	params, err := ToMessageNewParams(info, &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, params.Temperature.Value)
+	assert.True(t, params.Temperature.Valid())
+
+	_, level = normalizeDeterminism(info, llm.ApplyCompletionOptions([]llm.CompletionOption{
+		llm.WithReasoningEffort(llm.ReasoningEffortLow), llm.WithDeterministic(1),
+	}))
+	assert.Equal(t, llm.DeterminismNone, level)
+}
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *AnthropicModelProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewAnthropicModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	return provider
+}
+
+func TestAnthropicCompletionModel_Complete(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var params map[string]any
+		require.NoError(t, json.Unmarshal(body, &params))
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "claude-3-5-haiku-latest", params["model"])
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-latest",
+			"content":[{"type":"text","text":"Hello there"}],"stop_reason":"end_turn",
+			"usage":{"input_tokens":1000,"output_tokens":500,"cache_read_input_tokens":2000,"cache_creation_input_tokens":1000}}`)
+	})
+
+	model, err := provider.NewCompletionModel("claude-3-5-haiku-latest", llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hello there", resp.Output)
+	assert.Equal(t, llm.FinishReasonStop, resp.FinishReason)
+	assert.Equal(t, "msg_1", resp.ID)
+	require.NotNil(t, resp.Usage)
+	assert.Equal(t, int64(4000), resp.Usage.TotalInputTokens)
+	assert.Equal(t, int64(2000), resp.Usage.TotalCacheReadTokens)
+	assert.Equal(t, int64(1000), resp.Usage.TotalCacheWriteTokens)
+	require.NotNil(t, resp.Cost)
+	// 1000*0.8 + 2000*0.08 + 1000*1 + 500*4 per million tokens
+	assert.InDelta(t, 0.00396, *resp.Cost, 1e-12)
+}
+
+func TestAnthropicCompletionModel_SniffsArtifactTypes(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Messages []struct {
+				Content []struct {
+					Type   string `json:"type"`
+					Source struct {
+						MediaType string `json:"media_type"`
+					} `json:"source"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		require.Len(t, params.Messages, 1)
+		require.Len(t, params.Messages[0].Content, 2)
+		assert.Equal(t, "image", params.Messages[0].Content[1].Type)
+		assert.Equal(t, "image/png", params.Messages[0].Content[1].Source.MediaType)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-latest",
+			"content":[{"type":"text","text":"A chart"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":2}}`)
+	})
+
+	model, err := provider.NewCompletionModel("claude-3-5-haiku-latest")
+	require.NoError(t, err)
+
+	artifact := &llm.ModelArtifact{Name: "chart.jpg", ContentType: "image/jpeg", Content: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")}
+	_, err = model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Describe", Artifacts: []*llm.ModelArtifact{artifact}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", artifact.ContentType, "the request is not modified")
+}
+
+func TestAnthropicCompletionModel_Refusal(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_2","type":"message","role":"assistant","model":"claude-3-5-haiku-latest",
+			"content":[],"stop_reason":"refusal","usage":{"input_tokens":10,"output_tokens":0}}`)
+	})
+
+	model, err := provider.NewCompletionModel("claude-3-5-haiku-latest")
+	require.NoError(t, err)
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Output)
+	assert.Equal(t, llm.FinishReasonContentFilter, resp.FinishReason)
+	assert.Equal(t, "msg_2", resp.ID)
+}
+
+func TestAnthropicCompletionModel_N(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request is not sent")
+	})
+
+	model, err := provider.NewCompletionModel("claude-3-5-haiku-latest", llm.WithN(2))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	var unsupported *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &unsupported)
+}
+
+func TestAnthropicCompletionModel_ServiceTier(t *testing.T) {
+	var params map[string]any
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-latest",
+			"content":[{"type":"text","text":"Hello there"}],"stop_reason":"end_turn",
+			"usage":{"input_tokens":10,"output_tokens":5,"service_tier":"priority"}}`)
+	})
+
+	model, err := provider.NewCompletionModel("claude-3-5-haiku-latest", llm.WithServiceTier(llm.ServiceTierPriority))
+	require.NoError(t, err)
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "auto", params["service_tier"])
+	require.NotNil(t, resp.Metadata)
+	assert.Equal(t, llm.ServiceTierPriority, resp.Metadata.ServiceTier)
+
+	model, err = provider.NewCompletionModel("claude-3-5-haiku-latest", llm.WithServiceTier(llm.ServiceTierDefault))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "standard_only", params["service_tier"])
+}
+
+func TestAnthropicCompletionModel_ExtendedContext(t *testing.T) {
+	var betas []string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		betas = r.Header.Values("anthropic-beta")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5",
+			"content":[{"type":"text","text":"Summary"}],"stop_reason":"end_turn",
+			"usage":{"input_tokens":300000,"output_tokens":1000}}`)
+	})
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Summarize"}}}
+
+	model, err := provider.NewCompletionModel("claude-sonnet-4-5", llm.WithMaxTokens(1024), llm.WithExtendedContext(true), llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+	resp, err := model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{contextBeta}, betas)
+	require.NotNil(t, resp.Cost)
+	// The input exceeds 200K tokens, so all tokens are billed at the long context rates:
+	// 300000*6 + 1000*22.5 per million tokens
+	assert.InDelta(t, 1.8225, *resp.Cost, 1e-12)
+
+	model, err = provider.NewCompletionModel("claude-sonnet-4-5", llm.WithMaxTokens(1024))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, betas)
+
+	// Models without an extended window reject it before sending the request
+	betas = nil
+	model, err = provider.NewCompletionModel("claude-3-5-haiku-latest", llm.WithExtendedContext(true))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), req)
+	var unsupported *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &unsupported)
+}
+
+func TestAnthropicCompletionModel_ExtendedContextWindowCheck(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5",
+			"content":[{"type":"text","text":"Done"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
+	})
+	// About 250K tokens, beyond the standard window of 200K
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: strings.Repeat("lorem ipsum ", 250000)}}}
+
+	model, err := provider.NewCompletionModel("claude-sonnet-4-5", llm.WithMaxTokens(1024), llm.WithContextWindowCheck(true))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), req)
+	var windowErr *llm.ContextWindowError
+	require.ErrorAs(t, err, &windowErr)
+	assert.Equal(t, 200000, windowErr.ContextWindow)
+
+	model, err = provider.NewCompletionModel("claude-sonnet-4-5", llm.WithMaxTokens(1024), llm.WithContextWindowCheck(true), llm.WithExtendedContext(true))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), req)
+	assert.NoError(t, err)
+}
+
+func TestAnthropicCompletionModel_StreamComplete(t *testing.T) {
+	events := []string{
+		`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":"","signature":""}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me think."}}`,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":0}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":" world"}}`,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":1}`,
+		`event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":20}}`,
+		`event: message_stop
+data: {"type":"message_stop"}`,
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+		}
+	})
+
+	model, err := provider.NewCompletionModel("claude-sonnet-4-5", llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	var text, reasoning string
+	var finish llm.StreamFinishChunk
+	var usage llm.StreamUsageChunk
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case llm.StreamTextChunk:
+			text += c.Text
+		case llm.StreamReasoningChunk:
+			reasoning += c.Reasoning
+		case llm.StreamFinishChunk:
+			finish = c
+		case llm.StreamUsageChunk:
+			usage = c
+		}
+	}
+
+	assert.Equal(t, "Hello world", text)
+	assert.Equal(t, "Let me think.", reasoning)
+	assert.Equal(t, llm.StreamFinishChunk{Reason: llm.FinishReasonStop, ID: "msg_1"}, finish)
+	require.NotNil(t, usage.Usage)
+	assert.Equal(t, int64(10), usage.Usage.TotalInputTokens)
+	assert.Equal(t, int64(20), usage.Usage.TotalOutputTokens)
+	require.NotNil(t, usage.Cost)
+	assert.InDelta(t, 0.00033, *usage.Cost, 1e-12)
+}
+
+func TestAnthropicCompletionModel_StreamCompleteError(t *testing.T) {
+	events := []string{
+		`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+		}
+	})
+
+	model, err := provider.NewCompletionModel("claude-sonnet-4-5")
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	var chunks []llm.StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+
+	require.Len(t, chunks, 2)
+	assert.Equal(t, llm.StreamTextChunk{Text: "Hello"}, chunks[0])
+	errChunk, ok := chunks[1].(llm.StreamErrorChunk)
+	require.True(t, ok, "stream should end with an error chunk")
+	assert.Equal(t, "anthropic", errChunk.Provider)
+	assert.ErrorContains(t, errChunk, "Overloaded")
+}
+
+func TestAnthropicCompletionModel_WebSearch(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var params struct {
+			Tools []map[string]any `json:"tools"`
+		}
+		require.NoError(t, json.Unmarshal(body, &params))
+		require.Len(t, params.Tools, 1)
+		assert.Equal(t, "web_search_20250305", params.Tools[0]["type"])
+		assert.Equal(t, float64(2), params.Tools[0]["max_uses"])
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-haiku-latest",
+			"content":[
+				{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{"query":"go release"}},
+				{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[
+					{"type":"web_search_result","url":"https://go.dev/doc/devel/release","title":"Release History","encrypted_content":"x","page_age":"1 day ago"}
+				]},
+				{"type":"text","text":"Go 1.25 is out."}
+			],"stop_reason":"end_turn",
+			"usage":{"input_tokens":1000,"output_tokens":500,"server_tool_use":{"web_search_requests":1}}}`)
+	})
+
+	model, err := provider.NewCompletionModel("claude-3-5-haiku-latest", llm.WithUsage(true), llm.WithCost(true),
+		llm.WithWebSearch(llm.WebSearchOptions{MaxUses: 2}))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Latest Go release?"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Go 1.25 is out.", resp.Output)
+	require.Len(t, resp.WebSearches, 1)
+	assert.Equal(t, "go release", resp.WebSearches[0].Query)
+	assert.Equal(t, []*llm.WebSearchResult{
+		{URL: "https://go.dev/doc/devel/release", Title: "Release History", PageAge: "1 day ago"},
+	}, resp.WebSearches[0].Results)
+	assert.Equal(t, 1, resp.Usage.TotalWebSearches)
+	require.NotNil(t, resp.Cost)
+	// 1000*0.8 + 500*4 per million tokens + one search at $0.01
+	assert.InDelta(t, 0.0128, *resp.Cost, 1e-12)
+}
+
+func TestAnthropicCompletionModel_StreamWebSearch(t *testing.T) {
+	events := []string{
+		`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{}}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\":\"go release\"}"}}`,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":0}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"web_search_tool_result_error","error_code":"max_uses_exceeded"}}}`,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":1}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Unknown."}}`,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":2}`,
+		`event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":20,"server_tool_use":{"web_search_requests":1}}}`,
+		`event: message_stop
+data: {"type":"message_stop"}`,
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+		}
+	})
+
+	model, err := provider.NewCompletionModel("claude-sonnet-4-5", llm.WithUsage(true),
+		llm.WithWebSearch(llm.WebSearchOptions{}))
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Latest Go release?"}},
+	})
+	require.NoError(t, err)
+
+	var searches []*llm.WebSearch
+	var usage llm.StreamUsageChunk
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case llm.StreamWebSearchChunk:
+			searches = append(searches, c.Search)
+		case llm.StreamUsageChunk:
+			usage = c
+		}
+	}
+
+	require.Len(t, searches, 1)
+	assert.Equal(t, "go release", searches[0].Query)
+	assert.Equal(t, "max_uses_exceeded", searches[0].Error)
+	assert.Empty(t, searches[0].Results)
+	require.NotNil(t, usage.Usage)
+	assert.Equal(t, 1, usage.Usage.TotalWebSearches)
+}
+
+func TestAnthropicCompletionModel_StreamToolUse(t *testing.T) {
+	events := []string{
+		`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"weather","input":{}}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\": \"Pa"}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"ris\"}"}}`,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":0}`,
+		`event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+		`event: message_stop
+data: {"type":"message_stop"}`,
+	}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+		}
+	})
+	model, err := provider.NewCompletionModel("claude-sonnet-4-5")
+	require.NoError(t, err)
+
+	stream, err := llm.StreamCompletion(context.Background(), model, &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Weather?"}},
+	})
+	require.NoError(t, err)
+	resp, err := stream.Final()
+	require.NoError(t, err)
+	calls := llm.ParseToolCalls(resp.Output)
+	require.Len(t, calls, 1)
+	assert.Equal(t, map[string]any{"city": "Paris"}, calls[0].Input)
+}
@@ -0,0 +1,36 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package anthropic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/easyagent-dev/llm"
+)
+
+var _ llm.ModelSource = (*AnthropicModelProvider)(nil)
+
+// ListModels lists the models of the models endpoint, the source of the catalog refreshed
+// with llm.WithModelRefresh. Models missing from the embedded list are not priced.
+func (p *AnthropicModelProvider) ListModels(ctx context.Context) ([]*llm.ModelInfo, error) {
+	var models []*llm.ModelInfo
+	pager := p.client.Models.ListAutoPaging(ctx, anthropic.ModelListParams{})
+	for pager.Next() {
+		model := pager.Current()
+		models = append(models, &llm.ModelInfo{
+			ID:           model.ID,
+			Name:         model.DisplayName,
+			Capabilities: []llm.ModelCapability{llm.ModelCapabilityCompletion},
+			Input:        []llm.ModelMediaType{llm.ModelMediaTypeText, llm.ModelMediaTypeImage},
+			Output:       []llm.ModelMediaType{llm.ModelMediaTypeText},
+			UpdatedAt:    model.CreatedAt,
+		})
+	}
+	if err := pager.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	return models, nil
+}
@@ -4,12 +4,20 @@
 package azure
 
 import (
+	"bytes"
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
 	"github.com/easyagent-dev/llm"
 	"github.com/easyagent-dev/llm/internal/providers/openai"
 	"github.com/openai/openai-go/v3/option"
+	"golang.org/x/oauth2"
 )
 
 //go:embed openai.json
@@ -19,10 +27,14 @@ type AzureOpenAIModelProvider struct {
 	*openai.OpenAIModelProvider
 }
 
+// NewAzureOpenAIModelProvider creates an Azure OpenAI provider authenticating with the API key,
+// sent in the api-key header, or with the bearer tokens of WithTokenSource. With
+// WithDeploymentMap the base URL is the /openai/ path of the resource endpoint and requests
+// are routed to the deployment of their model; otherwise it is the URL of a deployment.
 func NewAzureOpenAIModelProvider(opts ...llm.ModelOption) (*AzureOpenAIModelProvider, error) {
 	config := llm.ApplyOptions(opts)
 
-	if config.APIKey == "" {
+	if config.APIKey == "" && config.TokenSource == nil {
 		return nil, llm.ErrAPIKeyEmpty
 	}
 	if config.BaseURL == "" {
@@ -37,6 +49,20 @@ func NewAzureOpenAIModelProvider(opts ...llm.ModelOption) (*AzureOpenAIModelProv
 		option.WithBaseURL(config.BaseURL),
 		option.WithQuery("api-version", config.APIVersion),
 	}
+	if config.TokenSource != nil {
+		// The OAuth transport sets the Authorization header of every request
+		source := oauth2.ReuseTokenSource(nil, config.TokenSource)
+		requestOpts = append(requestOpts, option.WithHTTPClient(oauth2.NewClient(context.Background(), source)))
+	} else {
+		requestOpts = append(requestOpts, option.WithHeader("api-key", config.APIKey))
+	}
+	if config.DeploymentMap != nil {
+		base, err := url.Parse(config.BaseURL)
+		if err != nil {
+			return nil, llm.NewValidationError("baseURL", "invalid URL", config.BaseURL)
+		}
+		requestOpts = append(requestOpts, option.WithMiddleware(deploymentRouter(base.Path, config.DeploymentMap)))
+	}
 
 	// Append any custom options
 	requestOpts = append(requestOpts, config.Options...)
@@ -49,12 +75,59 @@ func NewAzureOpenAIModelProvider(opts ...llm.ModelOption) (*AzureOpenAIModelProv
 	if err != nil {
 		return nil, err
 	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, nil)
 
 	return &AzureOpenAIModelProvider{
 		OpenAIModelProvider: provider,
 	}, nil
 }
 
+// deploymentRouter returns a middleware inserting the deployment of the model of JSON requests
+// in their path after basePath, e.g. /openai/chat/completions becomes
+// /openai/deployments/<deployment>/chat/completions
+func deploymentRouter(basePath string, deployments map[string]string) option.Middleware {
+	basePath = strings.TrimSuffix(basePath, "/") + "/"
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		rest, ok := strings.CutPrefix(req.URL.Path, basePath)
+		if !ok || req.Body == nil || strings.HasPrefix(rest, "deployments/") ||
+			!strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+			return next(req)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+
+		var payload struct {
+			Model string `json:"model"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil || payload.Model == "" {
+			return next(req)
+		}
+		deployment, ok := deployments[payload.Model]
+		if !ok {
+			deployment = payload.Model
+		}
+		req.URL.Path = basePath + "deployments/" + deployment + "/" + rest
+		req.URL.RawPath = ""
+		return next(req)
+	}
+}
+
 func (p *AzureOpenAIModelProvider) Name() string {
 	return "azure_openai"
 }
@@ -4,8 +4,16 @@
 package azure
 
 import (
-	"github.com/easyagent-dev/llm"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"golang.org/x/oauth2"
 
 	"github.com/openai/openai-go/v3/option"
 	"github.com/stretchr/testify/assert"
@@ -209,6 +217,121 @@ func TestNewAzureOpenAIModel_MultipleInstances(t *testing.T) {
 	assert.NotSame(t, model1, model2, "Different instances should be created")
 }
 
+// chatServer serves chat completions, recording the path and authentication of each request
+func chatServer(t *testing.T, paths, auths *[]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*paths = append(*paths, r.URL.Path)
+		*auths = append(*auths, r.Header.Get("api-key")+r.Header.Get("Authorization"))
+		assert.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4o",
+			"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func complete(t *testing.T, provider *AzureOpenAIModelProvider, model string) {
+	completionModel, err := provider.NewCompletionModel(model)
+	require.NoError(t, err)
+	resp, err := completionModel.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hello"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", resp.Output)
+}
+
+func TestAzureOpenAIModelProvider_DeploymentMap(t *testing.T) {
+	var paths, auths []string
+	server := chatServer(t, &paths, &auths)
+	provider, err := NewAzureOpenAIModelProvider(
+		llm.WithAPIKey("test-api-key"),
+		llm.WithBaseURL(server.URL+"/openai/"),
+		llm.WithAPIVersion("2024-10-21"),
+		llm.WithDeploymentMap(map[string]string{"gpt-4o": "prod-gpt4o"}),
+	)
+	require.NoError(t, err)
+
+	complete(t, provider, "gpt-4o")
+	complete(t, provider, "gpt-4o-mini")
+	assert.Equal(t, []string{
+		"/openai/deployments/prod-gpt4o/chat/completions",
+		"/openai/deployments/gpt-4o-mini/chat/completions",
+	}, paths)
+	assert.Equal(t, []string{"test-api-key", "test-api-key"}, auths)
+
+	// Without a map, the base URL is the URL of a deployment
+	paths, auths = nil, nil
+	provider, err = NewAzureOpenAIModelProvider(
+		llm.WithAPIKey("test-api-key"),
+		llm.WithBaseURL(server.URL+"/openai/deployments/gpt-4o/"),
+		llm.WithAPIVersion("2024-10-21"),
+	)
+	require.NoError(t, err)
+	complete(t, provider, "gpt-4o")
+	assert.Equal(t, []string{"/openai/deployments/gpt-4o/chat/completions"}, paths)
+}
+
+// countingTokenSource issues a new token, valid for an hour, on every call
+type countingTokenSource struct {
+	calls atomic.Int32
+}
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	n := s.calls.Add(1)
+	return &oauth2.Token{AccessToken: fmt.Sprintf("token-%d", n), Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAzureOpenAIModelProvider_TokenSource(t *testing.T) {
+	var paths, auths []string
+	server := chatServer(t, &paths, &auths)
+	source := &countingTokenSource{}
+	provider, err := NewAzureOpenAIModelProvider(
+		llm.WithTokenSource(source),
+		llm.WithBaseURL(server.URL+"/openai/"),
+		llm.WithAPIVersion("2024-10-21"),
+		llm.WithDeploymentMap(map[string]string{}),
+	)
+	require.NoError(t, err)
+
+	complete(t, provider, "gpt-4o")
+	complete(t, provider, "gpt-4o")
+	// The token is reused until it expires
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-1"}, auths)
+	assert.Equal(t, int32(1), source.calls.Load())
+}
+
+func TestManagedIdentityTokenSource(t *testing.T) {
+	expiresOn := time.Now().Add(time.Hour).Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "https://cognitiveservices.azure.com", r.URL.Query().Get("resource"))
+		if r.Header.Get("X-IDENTITY-HEADER") != "" {
+			assert.Equal(t, "secret", r.Header.Get("X-IDENTITY-HEADER"))
+			assert.Equal(t, "2019-08-01", r.URL.Query().Get("api-version"))
+			fmt.Fprintf(w, `{"access_token":"app-service-token","token_type":"Bearer","expires_on":%d}`, expiresOn.Unix())
+			return
+		}
+		assert.Equal(t, "true", r.Header.Get("Metadata"))
+		assert.Equal(t, "client-1", r.URL.Query().Get("client_id"))
+		fmt.Fprintf(w, `{"access_token":"imds-token","token_type":"Bearer","expires_on":"%d"}`, expiresOn.Unix())
+	}))
+	defer server.Close()
+
+	source := NewManagedIdentityTokenSource("client-1").(*managedIdentityTokenSource)
+	source.endpoint = server.URL
+	token, err := source.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "imds-token", token.AccessToken)
+	assert.Equal(t, expiresOn, token.Expiry)
+
+	t.Setenv("IDENTITY_ENDPOINT", server.URL)
+	t.Setenv("IDENTITY_HEADER", "secret")
+	token, err = NewManagedIdentityTokenSource("").Token()
+	require.NoError(t, err)
+	assert.Equal(t, "app-service-token", token.AccessToken)
+	assert.Equal(t, expiresOn, token.Expiry)
+}
+
 // Benchmark tests
 func BenchmarkNewAzureOpenAIModel_Success(b *testing.B) {
 	opts := []llm.ModelOption{
@@ -0,0 +1,118 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	// cognitiveServicesResource is the resource Azure OpenAI tokens are issued for
+	cognitiveServicesResource = "https://cognitiveservices.azure.com"
+	// imdsEndpoint is the token endpoint of the Azure Instance Metadata Service, serving the
+	// managed identities of virtual machines and AKS pods
+	imdsEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// NewClientSecretTokenSource returns a source of Microsoft Entra ID tokens for Azure OpenAI,
+// issued to the application clientID of tenantID with a client secret
+func NewClientSecretTokenSource(tenantID, clientID, clientSecret string) oauth2.TokenSource {
+	config := &clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     "https://login.microsoftonline.com/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token",
+		Scopes:       []string{cognitiveServicesResource + "/.default"},
+	}
+	return config.TokenSource(context.Background())
+}
+
+// NewManagedIdentityTokenSource returns a source of managed identity tokens for Azure OpenAI.
+// App Service and Azure Functions tokens are requested from the endpoint set in the
+// IDENTITY_ENDPOINT and IDENTITY_HEADER environment variables, and others from the Instance
+// Metadata Service. clientID selects a user-assigned identity; an empty clientID uses the
+// system-assigned identity.
+func NewManagedIdentityTokenSource(clientID string) oauth2.TokenSource {
+	source := &managedIdentityTokenSource{clientID: clientID, endpoint: imdsEndpoint, client: http.DefaultClient}
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		source.endpoint, source.identityHeader = endpoint, header
+	}
+	return source
+}
+
+type managedIdentityTokenSource struct {
+	clientID string
+	endpoint string
+	// identityHeader is the secret of the App Service endpoint, empty for IMDS
+	identityHeader string
+	client         *http.Client
+}
+
+type managedIdentityToken struct {
+	AccessToken string          `json:"access_token"`
+	TokenType   string          `json:"token_type"`
+	ExpiresOn   json.RawMessage `json:"expires_on"`
+}
+
+func (s *managedIdentityTokenSource) Token() (*oauth2.Token, error) {
+	query := url.Values{"resource": {cognitiveServicesResource}}
+	if s.identityHeader != "" {
+		query.Set("api-version", "2019-08-01")
+	} else {
+		query.Set("api-version", "2018-02-01")
+	}
+	if s.clientID != "" {
+		query.Set("client_id", s.clientID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	if s.identityHeader != "" {
+		req.Header.Set("X-IDENTITY-HEADER", s.identityHeader)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request managed identity token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, llm.NewRequestError("azure_openai", resp.StatusCode, "failed to request managed identity token",
+			fmt.Errorf("%s", strings.TrimSpace(string(body))))
+	}
+
+	var token managedIdentityToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, llm.NewResponseError("azure_openai", "failed to parse managed identity token", err)
+	}
+	// expires_on is in Unix seconds, sent as a string by IMDS
+	expiresOn, err := strconv.ParseInt(strings.Trim(string(token.ExpiresOn), `"`), 10, 64)
+	if err != nil {
+		return nil, llm.NewResponseError("azure_openai", "failed to parse managed identity token expiry", err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Unix(expiresOn, 0),
+	}, nil
+}
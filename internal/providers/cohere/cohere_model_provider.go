@@ -0,0 +1,666 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package cohere
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+)
+
+//go:embed cohere.json
+var cohereModels []byte
+
+const defaultBaseURL = "https://api.cohere.com"
+
+// CohereModelProvider provides Command chat, Embed and Rerank models through the Cohere v2 API
+type CohereModelProvider struct {
+	*llm.DefaultModelProvider
+	client *common.APIClient
+}
+
+var _ llm.ModelProvider = (*CohereModelProvider)(nil)
+
+// NewCohereModelProvider creates a new Cohere model provider
+func NewCohereModelProvider(opts ...llm.ModelOption) (*CohereModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(cohereModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	provider := llm.NewDefaultModelProvider("cohere", models)
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, nil)
+
+	return &CohereModelProvider{
+		DefaultModelProvider: provider,
+		client:               common.NewAPIClient("cohere", config.BaseURL, defaultBaseURL, config.APIKey),
+	}, nil
+}
+
+func (p *CohereModelProvider) NewCompletionModel(model string, opts ...llm.CompletionOption) (llm.CompletionModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityCompletion) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "completions")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.DecorateCompletionModel(&CohereCompletionModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		options:   opts,
+		inflight:  p.InFlight(),
+	}, info, opts), nil
+}
+
+func (p *CohereModelProvider) NewEmbeddingModel(model string) (llm.EmbeddingModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityEmbedding) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "embeddings")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.EnforceEmbeddingPolicy(p.WrapEmbeddingModel(&CohereEmbeddingModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		inflight:  p.InFlight(),
+	}), info), nil
+}
+
+func (p *CohereModelProvider) NewRerankModel(model string) (llm.RerankModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityRerank) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "reranking")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.EnforceRerankPolicy(p.WrapRerankModel(&CohereRerankModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		inflight:  p.InFlight(),
+	}), info), nil
+}
+
+// billedUnits are the units Cohere bills a request for
+type billedUnits struct {
+	InputTokens  float64 `json:"input_tokens"`
+	OutputTokens float64 `json:"output_tokens"`
+	SearchUnits  float64 `json:"search_units"`
+}
+
+// CohereCompletionModel implements CompletionModel with the Cohere v2 Chat API
+type CohereCompletionModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *CohereModelProvider
+	options   []llm.CompletionOption
+	inflight  *llm.InFlight
+}
+
+var _ llm.CompletionModel = (*CohereCompletionModel)(nil)
+
+type chatRequest struct {
+	Model            string          `json:"model"`
+	Messages         []chatMessage   `json:"messages"`
+	Stream           bool            `json:"stream,omitempty"`
+	Temperature      *float64        `json:"temperature,omitempty"`
+	P                *float64        `json:"p,omitempty"`
+	MaxTokens        *int            `json:"max_tokens,omitempty"`
+	Seed             *int64          `json:"seed,omitempty"`
+	StopSequences    []string        `json:"stop_sequences,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	ResponseFormat   *responseFormat `json:"response_format,omitempty"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type responseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema any    `json:"json_schema,omitempty"`
+}
+
+type chatUsage struct {
+	BilledUnits billedUnits `json:"billed_units"`
+}
+
+type chatResponse struct {
+	FinishReason string `json:"finish_reason"`
+	Message      struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"message"`
+	Usage chatUsage `json:"usage"`
+}
+
+// streamEvent is an event of a chat stream. Content deltas carry text and the message end
+// carries the finish reason and usage.
+type streamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Message struct {
+			Content struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"message"`
+		FinishReason string    `json:"finish_reason"`
+		Usage        chatUsage `json:"usage"`
+	} `json:"delta"`
+}
+
+// CountTokens estimates the size and input cost of req with the tokenizer registered for Cohere models
+func (p *CohereCompletionModel) CountTokens(req *llm.CompletionRequest) (*llm.TokenCount, error) {
+	return common.CountTokens(p.modelInfo, p.tokenizer(), req), nil
+}
+
+func (p *CohereCompletionModel) tokenizer() llm.Tokenizer {
+	return llm.GetTokenizer(llm.EncodingCohere)
+}
+
+func (p *CohereCompletionModel) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	opts, determinism := llm.NormalizeDeterminism(llm.ApplyContextCompletionOptions(ctx, p.options), true, true)
+	body, err := p.chatRequest(req, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	resp, err := p.provider.client.Post(ctx, "/v2/chat", body, "failed to create chat completion")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chat chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chat); err != nil {
+		return nil, llm.NewResponseError("cohere", "failed to parse chat response", err)
+	}
+	var sb strings.Builder
+	for _, content := range chat.Message.Content {
+		if content.Type == "text" {
+			sb.WriteString(content.Text)
+		}
+	}
+	if sb.Len() == 0 {
+		return nil, llm.ErrEmptyContent
+	}
+
+	response := &llm.CompletionResponse{Output: sb.String(), FinishReason: finishReason(chat.FinishReason)}
+	if opts.WithUsage != nil && *opts.WithUsage {
+		chunk := p.usageChunk(chat.Usage, opts)
+		response.Usage = chunk.Usage
+		response.Cost = chunk.Cost
+		response.CostBreakdown = chunk.CostBreakdown
+	}
+	if determinism != "" {
+		response.Metadata = &llm.ResponseMetadata{Determinism: determinism}
+	}
+	return response, nil
+}
+
+func (p *CohereCompletionModel) StreamComplete(ctx context.Context, req *llm.CompletionRequest) (llm.StreamCompletionResponse, error) {
+	opts, _ := llm.NormalizeDeterminism(llm.ApplyContextCompletionOptions(ctx, p.options), true, true)
+	body, err := p.chatRequest(req, opts)
+	if err != nil {
+		return nil, err
+	}
+	body.Stream = true
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.provider.client.Post(ctx, "/v2/chat", body, "failed to stream chat completion")
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	chunkChan := make(chan llm.StreamChunk, 1)
+	sendUsage := opts.WithUsage != nil && *opts.WithUsage && opts.StreamsEvent(llm.UsageChunkType)
+
+	go func() {
+		defer done()
+		defer close(chunkChan)
+		defer llm.SendStreamCanceled(ctx, chunkChan, "cohere")
+		defer llm.CloseOnDone(ctx, resp.Body)()
+
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case chunkChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var usage chatUsage
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			// Comments, keep-alives and empty data lines carry no event
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if data = strings.TrimSpace(data); !ok || data == "" {
+				continue
+			}
+			var event streamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(llm.StreamErrorChunk{Provider: "cohere", Err: llm.NewResponseError("cohere", "failed to parse stream event", err)})
+				return
+			}
+			switch event.Type {
+			case "content-delta":
+				if event.Delta.Message.Content.Text != "" && opts.StreamsEvent(llm.TextChunkType) {
+					if !send(opts.TextChunk(event.Delta.Message.Content.Text)) {
+						return
+					}
+				}
+			case "message-end":
+				usage = event.Delta.Usage
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() == nil {
+				send(llm.StreamErrorChunk{Provider: "cohere", Err: err})
+			}
+			return
+		}
+		if sendUsage {
+			send(p.usageChunk(usage, opts))
+		}
+	}()
+
+	return chunkChan, nil
+}
+
+// chatRequest validates req and converts it into a chat request
+func (p *CohereCompletionModel) chatRequest(req *llm.CompletionRequest, opts *llm.CompletionOptions) (*chatRequest, error) {
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	req, err := common.NormalizeRequestArtifacts(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.ValidateMessageInputs(p.modelInfo, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := common.CheckContextWindow(p.modelInfo, p.tokenizer(), req, opts); err != nil {
+		return nil, err
+	}
+	if opts.N != nil && *opts.N > 1 {
+		return nil, llm.NewUnsupportedCapabilityError(p.provider.Name(), "multiple choices")
+	}
+
+	messages, err := toChatMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	instructions := req.Instructions
+	if opts.OutputLanguage != nil && *opts.OutputLanguage != "" {
+		if instructions != "" {
+			instructions += "\n\n"
+		}
+		instructions += llm.OutputLanguageInstruction(*opts.OutputLanguage, false)
+	}
+	if instructions != "" {
+		messages = append([]chatMessage{{Role: "system", Content: instructions}}, messages...)
+	}
+
+	body := &chatRequest{
+		Model:            p.modelInfo.ID,
+		Messages:         messages,
+		Temperature:      opts.Temperature,
+		P:                opts.TopP,
+		Seed:             opts.Seed,
+		StopSequences:    opts.Stop,
+		FrequencyPenalty: opts.FrequencyPenalty,
+		PresencePenalty:  opts.PresencePenalty,
+	}
+	if opts.MaxTokens != nil && *opts.MaxTokens > 0 {
+		body.MaxTokens = opts.MaxTokens
+	} else if opts.MaxOutputTokens != nil && *opts.MaxOutputTokens > 0 {
+		body.MaxTokens = opts.MaxOutputTokens
+	}
+	if opts.ResponseFormat != nil {
+		switch *opts.ResponseFormat {
+		case llm.ResponseFormatJson:
+			body.ResponseFormat = &responseFormat{Type: "json_object"}
+		case llm.ResponseFormatJsonSchema:
+			body.ResponseFormat = &responseFormat{Type: "json_object", JSONSchema: opts.JSONSchema}
+		}
+	}
+	return body, nil
+}
+
+// usageChunk converts billed units into token usage with cost if requested
+func (p *CohereCompletionModel) usageChunk(u chatUsage, opts *llm.CompletionOptions) llm.StreamUsageChunk {
+	usage := &llm.TokenUsage{
+		TotalInputTokens:  int64(u.BilledUnits.InputTokens),
+		TotalOutputTokens: int64(u.BilledUnits.OutputTokens),
+		TotalRequests:     1,
+	}
+	var cost *float64
+	var breakdown *llm.CostBreakdown
+	if opts.WithCost != nil && *opts.WithCost {
+		cost, breakdown = common.CalculateCostBreakdown(p.modelInfo, usage)
+	}
+	return llm.StreamUsageChunk{Usage: usage, Cost: cost, CostBreakdown: breakdown}
+}
+
+// finishReason normalizes the finish reason of a chat response
+func finishReason(reason string) llm.FinishReason {
+	switch reason {
+	case "COMPLETE", "STOP_SEQUENCE":
+		return llm.FinishReasonStop
+	case "MAX_TOKENS":
+		return llm.FinishReasonLength
+	case "TOOL_CALL":
+		return llm.FinishReasonToolCalls
+	case "ERROR_TOXIC":
+		return llm.FinishReasonContentFilter
+	}
+	return ""
+}
+
+// toChatMessages converts messages into Cohere chat messages. Tool calls use the same fenced
+// JSON text convention as the other providers so agent loops behave identically.
+func toChatMessages(messages []*llm.ModelMessage) ([]chatMessage, error) {
+	result := make([]chatMessage, 0, len(messages))
+	for i, msg := range messages {
+		if msg == nil {
+			return nil, llm.NewValidationError(fmt.Sprintf("messages[%d]", i), "cannot be nil", nil)
+		}
+
+		switch msg.Role {
+		case llm.RoleUser, llm.RoleTool:
+			var texts []string
+			for _, part := range msg.ContentParts() {
+				text, err := partText(part)
+				if err != nil {
+					return nil, err
+				}
+				if text != "" {
+					texts = append(texts, text)
+				}
+			}
+			result = append(result, chatMessage{Role: "user", Content: strings.Join(texts, "\n\n")})
+
+		case llm.RoleAssistant:
+			text := msg.Text()
+			if msg.ToolCall != nil {
+				var err error
+				text, err = toolCallText("call tool: ", msg.ToolCall)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal tool call: %w", err)
+				}
+			}
+			result = append(result, chatMessage{Role: "assistant", Content: text})
+
+		default:
+			return nil, llm.NewValidationError("role", "unknown role", string(msg.Role))
+		}
+	}
+	return result, nil
+}
+
+// partText converts a content part into message text. Text files are inlined and other
+// attachments are referenced by name.
+func partText(part *llm.ContentPart) (string, error) {
+	if part == nil {
+		return "", nil
+	}
+	switch part.Type {
+	case llm.ContentPartText:
+		return part.Text, nil
+	case llm.ContentPartToolResult:
+		text, err := toolCallText("call tool results: ", part.ToolCall)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal tool call results: %w", err)
+		}
+		return text, nil
+	}
+	artifact := part.Artifact
+	if artifact == nil {
+		return "", nil
+	}
+	if strings.HasPrefix(artifact.ContentType, "text/") && len(artifact.Content) > 0 {
+		return string(artifact.Content), nil
+	}
+	return fmt.Sprintf("[attachment %s (%s) is not supported]", artifact.Name, artifact.ContentType), nil
+}
+
+// toolCallText formats a tool call as the fenced JSON text sent to the model
+func toolCallText(prefix string, call *llm.ToolCall) (string, error) {
+	jsonBytes, err := json.Marshal(call)
+	if err != nil {
+		return "", err
+	}
+	return prefix + "```" + string(jsonBytes) + "```", nil
+}
+
+// CohereEmbeddingModel implements EmbeddingModel with the Cohere v2 Embed API
+type CohereEmbeddingModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *CohereModelProvider
+	inflight  *llm.InFlight
+}
+
+var _ llm.EmbeddingModel = (*CohereEmbeddingModel)(nil)
+
+type embedRequest struct {
+	Model           string   `json:"model"`
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	EmbeddingTypes  []string `json:"embedding_types"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+}
+
+type embedResponse struct {
+	Embeddings struct {
+		Float [][]float64 `json:"float"`
+	} `json:"embeddings"`
+	Meta struct {
+		BilledUnits billedUnits `json:"billed_units"`
+	} `json:"meta"`
+}
+
+// GenerateEmbeddings embeds req.Contents. The task type is sent as the input type, which Cohere
+// requires; texts are embedded as documents for search by default.
+func (p *CohereEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	// Default to the model this instance was created for
+	req = common.DefaultEmbeddingModel(req, p.modelInfo.ID)
+
+	if err := common.ValidateEmbeddingRequest(req); err != nil {
+		return nil, err
+	}
+	body := embedRequest{
+		Model:          req.Model,
+		Texts:          req.Contents,
+		InputType:      inputType(""),
+		EmbeddingTypes: []string{"float"},
+	}
+	if req.Config != nil {
+		if req.Config.EncodingFormat == llm.EmbeddingEncodingFormatBase64 {
+			return nil, llm.NewValidationError("encoding_format", "base64 is not supported by Cohere", req.Config.EncodingFormat)
+		}
+		if req.Config.TaskType != "" {
+			if body.InputType = inputType(req.Config.TaskType); body.InputType == "" {
+				return nil, llm.NewValidationError("task_type", "is not supported by Cohere", req.Config.TaskType)
+			}
+		}
+		body.OutputDimension = req.Config.Dimensions
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	resp, err := p.provider.client.Post(ctx, "/v2/embed", body, "failed to generate embeddings")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embed embedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embed); err != nil {
+		return nil, llm.NewResponseError("cohere", "failed to parse embedding response", err)
+	}
+	embeddings := make([]llm.Embedding, len(embed.Embeddings.Float))
+	for i, values := range embed.Embeddings.Float {
+		embeddings[i] = llm.Embedding{
+			Index:     i,
+			Embedding: values,
+			Object:    "embedding",
+		}
+	}
+
+	usage := &llm.TokenUsage{
+		TotalInputTokens: int64(embed.Meta.BilledUnits.InputTokens),
+		TotalRequests:    1,
+	}
+	cost, breakdown := common.CalculateCostBreakdown(p.provider.GetModelInfo(req.Model), usage)
+	return &llm.EmbeddingResponse{
+		Embeddings:    embeddings,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}, nil
+}
+
+// inputType maps a task type to a Cohere input type. Texts compared with each other are
+// embedded like texts to be clustered, and texts without a task type as search documents.
+func inputType(taskType llm.EmbeddingTaskType) string {
+	switch taskType {
+	case "", llm.EmbeddingTaskSearchDocument:
+		return "search_document"
+	case llm.EmbeddingTaskSearchQuery:
+		return "search_query"
+	case llm.EmbeddingTaskClassification:
+		return "classification"
+	case llm.EmbeddingTaskClustering, llm.EmbeddingTaskSemanticSimilarity:
+		return "clustering"
+	}
+	return ""
+}
+
+// CohereRerankModel implements RerankModel with the Cohere v2 Rerank API
+type CohereRerankModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *CohereModelProvider
+	inflight  *llm.InFlight
+}
+
+var _ llm.RerankModel = (*CohereRerankModel)(nil)
+
+type rerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n,omitempty"`
+}
+
+type rerankResponse struct {
+	Results []common.ScoredDocument `json:"results"`
+	Meta    struct {
+		BilledUnits billedUnits `json:"billed_units"`
+	} `json:"meta"`
+}
+
+// Rerank orders req.Documents by relevance to req.Query. Cohere bills per search unit, a query
+// with up to 100 documents, at the model's request price.
+func (p *CohereRerankModel) Rerank(ctx context.Context, req *llm.RerankRequest) (*llm.RerankResponse, error) {
+	if err := common.ValidateRerankRequest(req); err != nil {
+		return nil, err
+	}
+	model := req.Model
+	if model == "" {
+		model = p.modelInfo.ID
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	resp, err := p.provider.client.Post(ctx, "/v2/rerank", rerankRequest{
+		Model:     model,
+		Query:     req.Query,
+		Documents: req.Documents,
+		TopN:      req.TopN,
+	}, "failed to rerank documents")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var rerank rerankResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rerank); err != nil {
+		return nil, llm.NewResponseError("cohere", "failed to parse rerank response", err)
+	}
+	results, err := common.RerankResults("cohere", req, rerank.Results)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &llm.RerankResponse{
+		Results: results,
+		Usage:   &llm.TokenUsage{TotalRequests: 1},
+	}
+	if info := p.provider.GetModelInfo(model); info != nil {
+		cost := info.Pricing.Request.Cost(rerank.Meta.BilledUnits.SearchUnits).USD()
+		response.Cost = &cost
+	}
+	return response, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package cohere
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *CohereModelProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewCohereModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	return provider
+}
+
+func TestNewCohereModelProvider(t *testing.T) {
+	_, err := NewCohereModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	provider, err := NewCohereModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "cohere", provider.Name())
+	assert.NotEmpty(t, provider.ModelsWithCapability(llm.ModelCapabilityRerank))
+
+	_, err = provider.NewCompletionModel("unknown-model")
+	assert.ErrorIs(t, err, llm.ErrInvalidModel)
+	var capErr *llm.UnsupportedCapabilityError
+	_, err = provider.NewCompletionModel("embed-english-v3.0")
+	assert.ErrorAs(t, err, &capErr)
+	_, err = provider.NewRerankModel("command-r-08-2024")
+	assert.ErrorAs(t, err, &capErr)
+}
+
+func TestCohereCompletionModel_Complete(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/chat", r.URL.Path)
+		assert.Equal(t, "Bearer test-api-key", r.Header.Get("Authorization"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "command-r-08-2024", body["model"])
+		assert.Equal(t, []any{
+			map[string]any{"role": "system", "content": "Be brief."},
+			map[string]any{"role": "user", "content": "Hi"},
+		}, body["messages"])
+		assert.Equal(t, 0.5, body["p"])
+		assert.Equal(t, float64(64), body["max_tokens"])
+		assert.Equal(t, map[string]any{"type": "json_object"}, body["response_format"])
+		assert.Nil(t, body["stream"])
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","finish_reason":"MAX_TOKENS","message":{"role":"assistant","content":[{"type":"text","text":"{\"a\":"}]},
+			"usage":{"billed_units":{"input_tokens":1000,"output_tokens":500},"tokens":{"input_tokens":1200,"output_tokens":500}}}`)
+	})
+
+	model, err := provider.NewCompletionModel("command-r-08-2024",
+		llm.WithTopP(0.5), llm.WithMaxTokens(64), llm.WithResponseFormat(llm.ResponseFormatJson),
+		llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Instructions: "Be brief.",
+		Messages:     []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":`, resp.Output)
+	assert.Equal(t, llm.FinishReasonLength, resp.FinishReason)
+	assert.Equal(t, int64(1000), resp.Usage.TotalInputTokens)
+	assert.Equal(t, int64(500), resp.Usage.TotalOutputTokens)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, (1000*0.15+500*0.6)/1e6, *resp.Cost, 1e-12)
+}
+
+func TestCohereCompletionModel_StreamComplete(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["stream"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message-start","id":"1","delta":{"message":{"role":"assistant"}}}`,
+			`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"Hello"}}}}`,
+			`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":" there"}}}}`,
+			`{"type":"message-end","delta":{"finish_reason":"COMPLETE","usage":{"billed_units":{"input_tokens":10,"output_tokens":2}}}}`,
+		} {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", event)
+			fmt.Fprint(w, ": keep-alive\n\ndata:\n\n")
+		}
+	})
+
+	model, err := provider.NewCompletionModel("command-r-08-2024", llm.WithUsage(true))
+	require.NoError(t, err)
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	var text string
+	var usage *llm.TokenUsage
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case llm.StreamTextChunk:
+			text += c.Text
+		case llm.StreamUsageChunk:
+			usage = c.Usage
+		case llm.StreamErrorChunk:
+			t.Fatal(c.Err)
+		}
+	}
+	assert.Equal(t, "Hello there", text)
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(12), usage.TotalInputTokens+usage.TotalOutputTokens)
+}
+
+func TestCohereCompletionModel_RequestError(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"message":"trial key rate limit exceeded"}`)
+	})
+
+	model, err := provider.NewCompletionModel("command-r-08-2024")
+	require.NoError(t, err)
+	_, err = model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	var reqErr *llm.RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusTooManyRequests, reqErr.StatusCode)
+	assert.Equal(t, "2s", reqErr.RetryAfter.String())
+	assert.ErrorContains(t, err, "trial key rate limit exceeded")
+}
+
+func TestToChatMessages(t *testing.T) {
+	errMsg := "not found"
+	messages, err := toChatMessages([]*llm.ModelMessage{
+		{Role: llm.RoleAssistant, ToolCall: &llm.ToolCall{Name: "search", Input: map[string]any{"q": "go"}}},
+		{Role: llm.RoleTool, ToolCall: &llm.ToolCall{Name: "search", ErrorMessage: &errMsg}},
+		llm.NewMessage(llm.RoleUser,
+			llm.NewTextPart("Summarize"),
+			llm.NewArtifactPart(&llm.ModelArtifact{Name: "notes.txt", ContentType: "text/plain", Content: []byte("notes")})),
+	})
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	assert.Equal(t, "assistant", messages[0].Role)
+	assert.Contains(t, messages[0].Content, "call tool: ```")
+	assert.Equal(t, "user", messages[1].Role)
+	assert.Contains(t, messages[1].Content, "call tool results: ```")
+	assert.Equal(t, "Summarize\n\nnotes", messages[2].Content)
+
+	_, err = toChatMessages([]*llm.ModelMessage{{Role: "system"}})
+	assert.Error(t, err)
+}
+
+func TestFinishReason(t *testing.T) {
+	assert.Equal(t, llm.FinishReasonStop, finishReason("COMPLETE"))
+	assert.Equal(t, llm.FinishReasonStop, finishReason("STOP_SEQUENCE"))
+	assert.Equal(t, llm.FinishReasonLength, finishReason("MAX_TOKENS"))
+	assert.Equal(t, llm.FinishReasonToolCalls, finishReason("TOOL_CALL"))
+	assert.Equal(t, llm.FinishReason(""), finishReason("ERROR"))
+}
+
+func TestCohereEmbeddingModel_GenerateEmbeddings(t *testing.T) {
+	var inputTypes []string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/embed", r.URL.Path)
+		var body embedRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "embed-english-v3.0", body.Model)
+		assert.Equal(t, []string{"a", "b"}, body.Texts)
+		assert.Equal(t, []string{"float"}, body.EmbeddingTypes)
+		inputTypes = append(inputTypes, body.InputType)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","embeddings":{"float":[[0.1,0.2],[0.3,0.4]]},"texts":["a","b"],"meta":{"billed_units":{"input_tokens":2}}}`)
+	})
+
+	model, err := provider.NewEmbeddingModel("embed-english-v3.0")
+	require.NoError(t, err)
+
+	req := &llm.EmbeddingRequest{Contents: []string{"a", "b"}}
+	resp, err := model.GenerateEmbeddings(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, req.Model, "the request of the caller is not modified")
+	require.Len(t, resp.Embeddings, 2)
+	assert.Equal(t, []float64{0.3, 0.4}, resp.Embeddings[1].Embedding)
+	assert.Equal(t, 1, resp.Embeddings[1].Index)
+	assert.Equal(t, int64(2), resp.Usage.TotalInputTokens)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 2*0.1/1e6, *resp.Cost, 1e-12)
+
+	_, err = model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"a", "b"},
+		Config:   &llm.EmbeddingModelConfig{TaskType: llm.EmbeddingTaskSearchQuery},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search_document", "search_query"}, inputTypes)
+
+	_, err = model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"a"},
+		Config:   &llm.EmbeddingModelConfig{EncodingFormat: llm.EmbeddingEncodingFormatBase64},
+	})
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestCohereRerankModel_Rerank(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/rerank", r.URL.Path)
+		var body rerankRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, rerankRequest{Model: "rerank-v3.5", Query: "capital of France", Documents: []string{"Berlin", "Paris", "Rome"}, TopN: 2}, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","results":[{"index":1,"relevance_score":0.98},{"index":2,"relevance_score":0.1}],"meta":{"billed_units":{"search_units":1}}}`)
+	})
+
+	model, err := provider.NewRerankModel("rerank-v3.5")
+	require.NoError(t, err)
+
+	resp, err := model.Rerank(context.Background(), &llm.RerankRequest{
+		Query:     "capital of France",
+		Documents: []string{"Berlin", "Paris", "Rome"},
+		TopN:      2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []llm.RerankResult{
+		{Index: 1, Document: "Paris", RelevanceScore: 0.98},
+		{Index: 2, Document: "Rome", RelevanceScore: 0.1},
+	}, resp.Results)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 0.002, *resp.Cost, 1e-12)
+
+	_, err = model.Rerank(context.Background(), &llm.RerankRequest{Query: "capital of France"})
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
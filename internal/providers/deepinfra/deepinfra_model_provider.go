@@ -0,0 +1,86 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package deepinfra
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/openai"
+	"github.com/openai/openai-go/v3/option"
+)
+
+type DeepInfraModelProvider struct {
+	*openai.OpenAIModelProvider
+}
+
+var _ llm.ModelProvider = (*DeepInfraModelProvider)(nil)
+
+//go:embed deepinfra.json
+var deepInfraModels []byte
+
+func NewDeepInfraModelProvider(opts ...llm.ModelOption) (*DeepInfraModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+
+	// Build request options list
+	requestOpts := []option.RequestOption{}
+	requestOpts = append(requestOpts, option.WithAPIKey(config.APIKey))
+
+	// Set base URL (use default if not provided)
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.deepinfra.com/v1/openai/"
+	}
+	requestOpts = append(requestOpts, option.WithBaseURL(baseURL))
+
+	// Append any custom options
+	requestOpts = append(requestOpts, config.Options...)
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(deepInfraModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	// Create the completion model with DeepInfra's API endpoint
+	provider, err := openai.NewBaseOpenAIModelProvider("deepinfra", models, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, provider)
+	provider.SetCompletionFields(config.CompletionFields)
+
+	return &DeepInfraModelProvider{
+		OpenAIModelProvider: provider,
+	}, nil
+}
+
+// WithTopK samples only from the k most likely tokens
+func WithTopK(k int) llm.ModelOption {
+	return llm.WithCompletionField("top_k", k)
+}
+
+// WithMinP drops tokens less likely than p times the most likely token
+func WithMinP(p float64) llm.ModelOption {
+	return llm.WithCompletionField("min_p", p)
+}
+
+// WithRepetitionPenalty penalizes repeated tokens; 1 disables the penalty
+func WithRepetitionPenalty(penalty float64) llm.ModelOption {
+	return llm.WithCompletionField("repetition_penalty", penalty)
+}
@@ -0,0 +1,66 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package deepinfra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeepInfraModelProvider(t *testing.T) {
+	_, err := NewDeepInfraModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	provider, err := NewDeepInfraModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "deepinfra", provider.Name())
+	assert.NotEmpty(t, provider.SupportedModels())
+
+	_, err = provider.NewCompletionModel("deepseek-ai/DeepSeek-V3")
+	assert.NoError(t, err)
+	_, err = provider.NewEmbeddingModel("BAAI/bge-large-en-v1.5")
+	assert.NoError(t, err)
+}
+
+func TestDeepInfraCompletionModel_Options(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":1000000,"completion_tokens":1000000,"total_tokens":2000000}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewDeepInfraModelProvider(
+		llm.WithAPIKey("test-api-key"),
+		llm.WithBaseURL(server.URL),
+		WithTopK(40),
+		WithMinP(0.05),
+		WithRepetitionPenalty(1.1),
+	)
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("meta-llama/Meta-Llama-3.1-8B-Instruct", llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 40.0, body["top_k"])
+	assert.Equal(t, 0.05, body["min_p"])
+	assert.Equal(t, 1.1, body["repetition_penalty"])
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 0.08, *resp.Cost, 1e-9)
+}
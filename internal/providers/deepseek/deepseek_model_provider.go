@@ -52,6 +52,16 @@ func NewDeepSeekModelProvider(opts ...llm.ModelOption) (*DeepSeekModelProvider,
 	if err != nil {
 		return nil, err
 	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, provider)
 
 	return &DeepSeekModelProvider{
 		OpenAIModelProvider: provider,
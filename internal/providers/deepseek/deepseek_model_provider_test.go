@@ -1,9 +1,14 @@
 package deepseek
 
 import (
-	"github.com/easyagent-dev/llm"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/easyagent-dev/llm"
+
 	"github.com/openai/openai-go/v3/option"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -200,3 +205,36 @@ func BenchmarkDeepSeekModel_SupportedModels(b *testing.B) {
 		_ = model.SupportedModels()
 	}
 }
+
+func TestDeepSeekCompletionModel_ReasoningAndCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"r1","object":"chat.completion","created":1,"model":"deepseek-reasoner",
+			"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"42",
+			"reasoning_content":"Six times seven\nis 42."}}],
+			"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100,
+			"prompt_cache_hit_tokens":800,"prompt_cache_miss_tokens":200}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewDeepSeekModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("deepseek-reasoner", llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "What is six times seven?"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "42", resp.Output)
+	assert.Equal(t, "Six times seven\nis 42.", resp.Reasoning)
+	assert.Equal(t, int64(800), resp.Usage.TotalCacheReadTokens)
+	require.NotNil(t, resp.Cost)
+	// 200*0.28 + 800*0.028 + 100*0.42 per million tokens
+	assert.InDelta(t, 0.0001204, *resp.Cost, 1e-12)
+	assert.Equal(t, &llm.CostBreakdown{
+		Input:       llm.TokenCost(200, llm.NewPrice(0.28)),
+		CachedInput: llm.TokenCost(800, llm.NewPrice(0.028)),
+		Output:      llm.TokenCost(100, llm.NewPrice(0.42)),
+	}, resp.CostBreakdown)
+}
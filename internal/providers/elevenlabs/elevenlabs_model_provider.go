@@ -0,0 +1,229 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package elevenlabs
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+)
+
+//go:embed elevenlabs.json
+var elevenlabsModels []byte
+
+const (
+	defaultBaseURL = "https://api.elevenlabs.io"
+	// defaultVoice is the voice ID of Rachel, one of the premade voices
+	defaultVoice = "21m00Tcm4TlvDq8ikWAM"
+)
+
+// outputFormats maps speech formats to ElevenLabs output formats
+var outputFormats = map[string]string{
+	llm.SpeechFormatMP3:  "mp3_44100_128",
+	llm.SpeechFormatOpus: "opus_48000_128",
+	llm.SpeechFormatPCM:  "pcm_24000",
+}
+
+// ElevenLabsModelProvider provides speech models through the ElevenLabs text-to-speech API
+type ElevenLabsModelProvider struct {
+	*llm.DefaultModelProvider
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+var _ llm.ModelProvider = (*ElevenLabsModelProvider)(nil)
+
+// NewElevenLabsModelProvider creates a new ElevenLabs model provider
+func NewElevenLabsModelProvider(opts ...llm.ModelOption) (*ElevenLabsModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(elevenlabsModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	provider := llm.NewDefaultModelProvider("elevenlabs", models)
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, nil)
+
+	return &ElevenLabsModelProvider{
+		DefaultModelProvider: provider,
+		apiKey:               config.APIKey,
+		baseURL:              strings.TrimSuffix(baseURL, "/"),
+		httpClient:           http.DefaultClient,
+	}, nil
+}
+
+func (p *ElevenLabsModelProvider) NewSpeechModel(model string) (llm.SpeechModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilitySpeech) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "speech generation")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.EnforceSpeechPolicy(p.WrapSpeechModel(&ElevenLabsSpeechModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		inflight:  p.InFlight(),
+	}), info), nil
+}
+
+// ElevenLabsSpeechModel implements SpeechModel with the ElevenLabs text-to-speech API
+type ElevenLabsSpeechModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *ElevenLabsModelProvider
+	inflight  *llm.InFlight
+}
+
+var _ llm.SpeechModel = (*ElevenLabsSpeechModel)(nil)
+
+type speechRequest struct {
+	Text          string         `json:"text"`
+	ModelID       string         `json:"model_id"`
+	VoiceSettings *voiceSettings `json:"voice_settings,omitempty"`
+}
+
+type voiceSettings struct {
+	Speed float64 `json:"speed,omitempty"`
+}
+
+// GenerateSpeech synthesizes req.Text. The voice is an ElevenLabs voice ID, and the format
+// is one of MP3, Opus and PCM or a native ElevenLabs output format such as mp3_22050_32.
+// Instructions are not supported.
+func (p *ElevenLabsSpeechModel) GenerateSpeech(ctx context.Context, req *llm.SpeechRequest) (*llm.SpeechResponse, error) {
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	if req.Text == "" {
+		return nil, llm.NewValidationError("text", "cannot be empty", nil)
+	}
+
+	body := speechRequest{Text: req.Text, ModelID: req.Model}
+	if body.ModelID == "" {
+		body.ModelID = p.modelInfo.ID
+	}
+	voice, format, outputFormat := defaultVoice, llm.SpeechFormatMP3, outputFormats[llm.SpeechFormatMP3]
+	if req.Config != nil {
+		if req.Config.Voice != "" {
+			voice = req.Config.Voice
+		}
+		if req.Config.Speed != 0 {
+			if req.Config.Speed < 0.7 || req.Config.Speed > 1.2 {
+				return nil, llm.NewValidationError("speed", "must be between 0.7 and 1.2", req.Config.Speed)
+			}
+			body.VoiceSettings = &voiceSettings{Speed: req.Config.Speed}
+		}
+		if req.Config.Format != "" {
+			var err error
+			if format, outputFormat, err = toOutputFormat(req.Config.Format); err != nil {
+				return nil, err
+			}
+		}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal speech request: %w", err)
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	endpoint := fmt.Sprintf("%s/v1/text-to-speech/%s?output_format=%s", p.provider.baseURL, url.PathEscape(voice), url.QueryEscape(outputFormat))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speech request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("xi-api-key", p.provider.apiKey)
+
+	resp, err := p.provider.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate speech: %w", err)
+	}
+	defer resp.Body.Close()
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read speech: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, &llm.RequestError{
+			Provider:   "elevenlabs",
+			StatusCode: resp.StatusCode,
+			Message:    "failed to generate speech",
+			Err:        errors.New(strings.TrimSpace(string(audio))),
+			RetryAfter: llm.ParseRetryAfter(resp.Header),
+		}
+	}
+	if len(audio) == 0 {
+		return nil, llm.ErrEmptyContent
+	}
+
+	// Speech is billed per input character
+	usage := &llm.TokenUsage{
+		TotalCharacters: int64(utf8.RuneCountInString(req.Text)),
+		TotalRequests:   1,
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = llm.SpeechContentType(format)
+	}
+
+	cost, breakdown := common.CalculateCostBreakdown(p.modelInfo, usage)
+	return &llm.SpeechResponse{
+		Output:        audio,
+		ContentType:   contentType,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}, nil
+}
+
+// toOutputFormat converts a speech format to an ElevenLabs output format. Native formats,
+// which name a codec and sample rate, are passed through.
+func toOutputFormat(format string) (string, string, error) {
+	if outputFormat, ok := outputFormats[format]; ok {
+		return format, outputFormat, nil
+	}
+	if codec, _, ok := strings.Cut(format, "_"); ok {
+		return codec, format, nil
+	}
+	return "", "", llm.NewValidationError("format", "not supported by ElevenLabs", format)
+}
@@ -0,0 +1,88 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package elevenlabs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewElevenLabsModelProvider(t *testing.T) {
+	_, err := NewElevenLabsModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	provider, err := NewElevenLabsModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "elevenlabs", provider.Name())
+	assert.NotEmpty(t, provider.SupportedModels())
+
+	_, err = provider.NewSpeechModel("unknown-model")
+	assert.ErrorIs(t, err, llm.ErrInvalidModel)
+	_, err = provider.NewCompletionModel("eleven_multilingual_v2")
+	assert.Error(t, err)
+}
+
+func TestElevenLabsSpeechModel_GenerateSpeech(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/text-to-speech/voice-1", r.URL.Path)
+		assert.Equal(t, "opus_48000_128", r.URL.Query().Get("output_format"))
+		assert.Equal(t, "test-api-key", r.Header.Get("xi-api-key"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "audio/ogg")
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	provider, err := NewElevenLabsModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := provider.NewSpeechModel("eleven_flash_v2_5")
+	require.NoError(t, err)
+
+	resp, err := model.GenerateSpeech(context.Background(), &llm.SpeechRequest{
+		Text:   "Hello, world",
+		Config: &llm.SpeechModelConfig{Voice: "voice-1", Speed: 1.1, Format: llm.SpeechFormatOpus},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "eleven_flash_v2_5", body["model_id"])
+	assert.Equal(t, map[string]any{"speed": 1.1}, body["voice_settings"])
+	assert.Equal(t, []byte("audio"), resp.Output)
+	assert.Equal(t, "audio/ogg", resp.ContentType)
+	assert.Equal(t, int64(12), resp.Usage.TotalCharacters)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 12*50/1e6, *resp.Cost, 1e-12)
+	require.NotNil(t, resp.CostBreakdown)
+	assert.Equal(t, llm.MicroCentsFromUSD(*resp.Cost), resp.CostBreakdown.Speech)
+}
+
+func TestElevenLabsSpeechModel_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		http.Error(w, `{"detail":"too many requests"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	provider, err := NewElevenLabsModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := provider.NewSpeechModel("eleven_multilingual_v2")
+	require.NoError(t, err)
+
+	_, err = model.GenerateSpeech(context.Background(), &llm.SpeechRequest{Text: "Hi"})
+	var reqErr *llm.RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusTooManyRequests, reqErr.StatusCode)
+	assert.True(t, llm.IsRetryableError(err))
+
+	_, err = model.GenerateSpeech(context.Background(), &llm.SpeechRequest{Text: "Hi", Config: &llm.SpeechModelConfig{Format: llm.SpeechFormatFLAC}})
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
@@ -0,0 +1,182 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fireworks
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+	"github.com/easyagent-dev/llm/internal/providers/openai"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// FireworksModelInfo is a model listed by the Fireworks models endpoint
+type FireworksModelInfo struct {
+	ID                 string `json:"id"`
+	SupportsChat       bool   `json:"supports_chat"`
+	SupportsImageInput bool   `json:"supports_image_input"`
+	ContextLength      int    `json:"context_length"`
+}
+
+type FireworksModelsResponse struct {
+	Data []FireworksModelInfo `json:"data"`
+}
+
+// FireworksModelProvider provides open-weight models through the OpenAI compatible API of
+// Fireworks AI. It starts with the embedded catalog, and the other chat models of the account
+// are discovered from the models endpoint in the background on first use, then once a day,
+// unless set otherwise with llm.WithModelRefresh. Discovered models are priced from their size.
+type FireworksModelProvider struct {
+	*openai.OpenAIModelProvider
+}
+
+var _ llm.ModelProvider = (*FireworksModelProvider)(nil)
+
+//go:embed fireworks.json
+var fireworksModels []byte
+
+// discoveryTTL is how long discovered models are used before the models endpoint is listed
+// again, unless set with llm.WithModelRefresh
+const discoveryTTL = 24 * time.Hour
+
+func NewFireworksModelProvider(opts ...llm.ModelOption) (*FireworksModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+
+	// Build request options list
+	requestOpts := []option.RequestOption{}
+	requestOpts = append(requestOpts, option.WithAPIKey(config.APIKey))
+
+	// Set base URL (use default if not provided)
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.fireworks.ai/inference/v1/"
+	}
+	requestOpts = append(requestOpts, option.WithBaseURL(baseURL))
+
+	// Append any custom options
+	requestOpts = append(requestOpts, config.Options...)
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(fireworksModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	// Create the completion model with Fireworks' API endpoint
+	provider, err := openai.NewBaseOpenAIModelProvider("fireworks", models, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	refresh := config.ModelRefresh
+	if refresh == nil {
+		refresh = &llm.ModelRefreshOptions{TTL: discoveryTTL}
+	}
+	provider.SetModelRefresh(refresh, llm.ModelSourceFunc(func(ctx context.Context) ([]*llm.ModelInfo, error) {
+		return loadModels(ctx, requestOpts, models)
+	}))
+	provider.SetCompletionFields(config.CompletionFields)
+
+	return &FireworksModelProvider{
+		OpenAIModelProvider: provider,
+	}, nil
+}
+
+// WithPromptCacheMaxLen limits how many prompt tokens Fireworks caches for reuse by later
+// requests; zero disables prompt caching
+func WithPromptCacheMaxLen(tokens int) llm.ModelOption {
+	return llm.WithCompletionField("prompt_cache_max_len", tokens)
+}
+
+// WithSessionAffinity routes requests with the same session ID to the same replica, which
+// raises prompt cache hit rates for multi-turn conversations
+func WithSessionAffinity(sessionID string) llm.ModelOption {
+	return llm.WithRequestOption(option.WithHeader("x-session-affinity", sessionID))
+}
+
+// WithSpeculation sets the text Fireworks uses to speculate the start of every response.
+// Use llm.WithPrediction for a prediction of a single request.
+func WithSpeculation(text string) llm.ModelOption {
+	return llm.WithCompletionField("speculation", text)
+}
+
+// loadModels adds the chat models the Fireworks API lists to the catalog models, using the
+// request options of the provider. Listed models missing from the catalog are priced with
+// estimatePricing.
+func loadModels(ctx context.Context, requestOpts []option.RequestOption, catalog []*llm.ModelInfo) ([]*llm.ModelInfo, error) {
+	var listed FireworksModelsResponse
+	if err := common.ListModels(ctx, "fireworks", requestOpts, &listed); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(catalog))
+	for _, model := range catalog {
+		known[model.ID] = true
+	}
+	models := append([]*llm.ModelInfo{}, catalog...)
+	for _, model := range listed.Data {
+		if !model.SupportsChat || known[model.ID] {
+			continue
+		}
+		input := []llm.ModelMediaType{llm.ModelMediaTypeText}
+		if model.SupportsImageInput {
+			input = append(input, llm.ModelMediaTypeImage)
+		}
+		models = append(models, &llm.ModelInfo{
+			ID:            model.ID,
+			Name:          model.ID,
+			Capabilities:  []llm.ModelCapability{llm.ModelCapabilityCompletion},
+			Input:         input,
+			Output:        []llm.ModelMediaType{llm.ModelMediaTypeText},
+			ContextWindow: model.ContextLength,
+			Pricing:       estimatePricing(model.ID),
+		})
+	}
+	return models, nil
+}
+
+// sizePattern matches the parameter count in a model ID in billions, e.g. "8b", "1p5b", or
+// "8x22b" for a mixture of experts
+var sizePattern = regexp.MustCompile(`(?:^|[-_/])(?:(\d+)x)?(\d+(?:p\d+)?)b(?:[-_]|$)`)
+
+// estimatePricing prices a model by the parameter count in its ID, following the serverless
+// pricing tiers of Fireworks. Models of unknown size are priced at the tier of models above
+// 16B parameters. Use llm.WithPricingOverride for the exact price of a model.
+func estimatePricing(id string) llm.ModelPricing {
+	price := 0.9
+	if m := sizePattern.FindStringSubmatch(id); m != nil {
+		params, _ := strconv.ParseFloat(strings.Replace(m[2], "p", ".", 1), 64)
+		if m[1] != "" {
+			experts, _ := strconv.ParseFloat(m[1], 64)
+			price = 1.2
+			if experts*params <= 56 {
+				price = 0.5
+			}
+		} else if params < 4 {
+			price = 0.1
+		} else if params <= 16 {
+			price = 0.2
+		}
+	}
+	return llm.ModelPricing{Prompt: llm.NewPrice(price), Completion: llm.NewPrice(price)}
+}
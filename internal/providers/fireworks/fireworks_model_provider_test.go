@@ -0,0 +1,179 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package fireworks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFireworksModelProvider(t *testing.T) {
+	_, err := NewFireworksModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	// The provider is created offline, on the embedded catalog
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	provider, err := NewFireworksModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, "fireworks", provider.Name())
+	assert.NotEmpty(t, provider.SupportedModels())
+
+	_, err = provider.NewCompletionModel("accounts/fireworks/models/llama-v3p3-70b-instruct")
+	assert.NoError(t, err)
+	_, err = provider.NewEmbeddingModel("nomic-ai/nomic-embed-text-v1.5")
+	assert.NoError(t, err)
+}
+
+func TestFireworksModelDiscovery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models", r.URL.Path)
+		assert.Equal(t, "Bearer test-api-key", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[`+
+			`{"id":"accounts/fireworks/models/llama-v3p1-8b-instruct","supports_chat":true,"context_length":131072},`+
+			`{"id":"accounts/fireworks/models/qwen2p5-vl-32b-instruct","supports_chat":true,"supports_image_input":true,"context_length":128000},`+
+			`{"id":"accounts/fireworks/models/flux-1-dev-fp8","supports_chat":false}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewFireworksModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+
+	// The models are discovered in the background on first use
+	require.Eventually(t, func() bool {
+		return provider.GetModelInfo("accounts/fireworks/models/qwen2p5-vl-32b-instruct") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, provider.ModelCatalog().Err())
+
+	catalog := provider.GetModelInfo("accounts/fireworks/models/llama-v3p1-8b-instruct")
+	require.NotNil(t, catalog)
+	assert.Equal(t, 0.2, catalog.Pricing.Prompt.Float64())
+
+	discovered := provider.GetModelInfo("accounts/fireworks/models/qwen2p5-vl-32b-instruct")
+	require.NotNil(t, discovered)
+	assert.Equal(t, 128000, discovered.ContextWindow)
+	assert.True(t, discovered.AcceptsInput(llm.ModelMediaTypeImage))
+	assert.Equal(t, 0.9, discovered.Pricing.Prompt.Float64(), "discovered models are priced by size")
+	assert.Nil(t, provider.GetModelInfo("accounts/fireworks/models/flux-1-dev-fp8"))
+	assert.NotNil(t, provider.GetModelInfo("nomic-ai/nomic-embed-text-v1.5"), "catalog models are kept")
+}
+
+func TestFireworksModelDiscovery_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid api key"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	provider, err := NewFireworksModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+
+	// A failed discovery keeps the embedded catalog
+	err = provider.ModelCatalog().Refresh(context.Background())
+	var reqErr *llm.RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusUnauthorized, reqErr.StatusCode)
+	assert.NotNil(t, provider.GetModelInfo("accounts/fireworks/models/llama-v3p3-70b-instruct"))
+}
+
+func TestFireworksModelDiscovery_HTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "fireworks-test", r.Header.Get("X-Client"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[]}`)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header.Set("X-Client", "fireworks-test")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	provider, err := NewFireworksModelProvider(
+		llm.WithAPIKey("test-api-key"),
+		llm.WithBaseURL(server.URL),
+		llm.WithRequestOptions(option.WithHTTPClient(client)),
+	)
+	require.NoError(t, err)
+	require.NoError(t, provider.ModelCatalog().Refresh(context.Background()))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestEstimatePricing(t *testing.T) {
+	tests := []struct {
+		id    string
+		price float64
+	}{
+		{"accounts/fireworks/models/llama-v3p2-3b-instruct", 0.1},
+		{"accounts/fireworks/models/qwen2p5-1p5b-instruct", 0.1},
+		{"accounts/fireworks/models/llama-v3p1-8b-instruct", 0.2},
+		{"accounts/fireworks/models/qwen2p5-vl-32b-instruct", 0.9},
+		{"accounts/fireworks/models/mixtral-8x7b-instruct", 0.5},
+		{"accounts/fireworks/models/mixtral-8x22b-instruct", 1.2},
+		{"accounts/fireworks/models/kimi-k2-instruct", 0.9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			pricing := estimatePricing(tt.id)
+			assert.Equal(t, tt.price, pricing.Prompt.Float64())
+			assert.Equal(t, tt.price, pricing.Completion.Float64())
+		})
+	}
+}
+
+func TestFireworksCompletionModel_Options(t *testing.T) {
+	var body map[string]any
+	var affinity string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"object":"list","data":[]}`)
+			return
+		}
+		affinity = r.Header.Get("x-session-affinity")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":1000000,"completion_tokens":1000000,"total_tokens":2000000}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewFireworksModelProvider(
+		llm.WithAPIKey("test-api-key"),
+		llm.WithBaseURL(server.URL),
+		WithPromptCacheMaxLen(2048),
+		WithSessionAffinity("session-1"),
+		WithSpeculation("Hello"),
+	)
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("accounts/fireworks/models/llama-v3p1-8b-instruct", llm.WithPrediction("Hello"), llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "session-1", affinity)
+	assert.Equal(t, 2048.0, body["prompt_cache_max_len"])
+	assert.Equal(t, "Hello", body["speculation"])
+	assert.Equal(t, map[string]any{"type": "content", "content": "Hello"}, body["prediction"])
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 0.4, *resp.Cost, 1e-9)
+}
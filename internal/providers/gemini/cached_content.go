@@ -0,0 +1,92 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+)
+
+type cachedContentRequest struct {
+	Model             string    `json:"model"`
+	Contents          []content `json:"contents,omitempty"`
+	SystemInstruction *content  `json:"systemInstruction,omitempty"`
+	TTL               string    `json:"ttl,omitempty"`
+}
+
+type cachedContentResponse struct {
+	Name          string    `json:"name"`
+	Model         string    `json:"model"`
+	ExpireTime    time.Time `json:"expireTime"`
+	UsageMetadata struct {
+		TotalTokenCount int64 `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// CreateCachedContent caches the instructions and messages of req for model. Gemini requires
+// a minimum number of tokens, which depends on the model, for content to be cached.
+func (p *GeminiModelProvider) CreateCachedContent(ctx context.Context, model string, req *llm.CompletionRequest, ttl time.Duration) (*llm.CachedContent, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	if ttl < 0 {
+		return nil, llm.NewValidationError("ttl", "cannot be negative", ttl)
+	}
+	req, err := common.NormalizeRequestArtifacts(req)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := toContents(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &cachedContentRequest{Model: "models/" + info.ID, Contents: contents}
+	if req.Instructions != "" {
+		body.SystemInstruction = &content{Parts: []part{{Text: req.Instructions}}}
+	}
+	if ttl > 0 {
+		body.TTL = fmt.Sprintf("%.3fs", ttl.Seconds())
+	}
+
+	resp, err := p.send(ctx, http.MethodPost, "/cachedContents", body, "failed to create cached content")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var cached cachedContentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cached); err != nil {
+		return nil, llm.NewResponseError("gemini", "failed to parse cached content response", err)
+	}
+	return &llm.CachedContent{
+		Name:       cached.Name,
+		Model:      strings.TrimPrefix(cached.Model, "models/"),
+		ExpireTime: cached.ExpireTime,
+		TokenCount: cached.UsageMetadata.TotalTokenCount,
+	}, nil
+}
+
+// DeleteCachedContent deletes the cached content with the given name
+func (p *GeminiModelProvider) DeleteCachedContent(ctx context.Context, name string) error {
+	if !strings.HasPrefix(name, "cachedContents/") {
+		return llm.NewValidationError("name", "must start with cachedContents/", name)
+	}
+	resp, err := p.send(ctx, http.MethodDelete, "/"+name, nil, "failed to delete cached content")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
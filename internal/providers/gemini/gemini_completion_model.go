@@ -0,0 +1,591 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package gemini
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+)
+
+// thinkingBudgets maps reasoning effort to the thinking token budget
+var thinkingBudgets = map[llm.ReasoningEffort]int{
+	llm.ReasoningEffortLow:    1024,
+	llm.ReasoningEffortMedium: 8192,
+	llm.ReasoningEffortHigh:   24576,
+}
+
+// GeminiCompletionModel implements CompletionModel with the native Gemini generateContent API
+type GeminiCompletionModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *GeminiModelProvider
+	options   []llm.CompletionOption
+	inflight  *llm.InFlight
+}
+
+var _ llm.CompletionModel = (*GeminiCompletionModel)(nil)
+
+type generateRequest struct {
+	Contents          []content         `json:"contents"`
+	SystemInstruction *content          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []safetySetting   `json:"safetySettings,omitempty"`
+	Tools             []tool            `json:"tools,omitempty"`
+	CachedContent     string            `json:"cachedContent,omitempty"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text       string      `json:"text,omitempty"`
+	Thought    bool        `json:"thought,omitempty"`
+	InlineData *inlineData `json:"inlineData,omitempty"`
+	FileData   *fileData   `json:"fileData,omitempty"`
+}
+
+type inlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type fileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type generationConfig struct {
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               *float64        `json:"topP,omitempty"`
+	MaxOutputTokens    *int            `json:"maxOutputTokens,omitempty"`
+	StopSequences      []string        `json:"stopSequences,omitempty"`
+	Seed               *int64          `json:"seed,omitempty"`
+	PresencePenalty    *float64        `json:"presencePenalty,omitempty"`
+	FrequencyPenalty   *float64        `json:"frequencyPenalty,omitempty"`
+	CandidateCount     *int            `json:"candidateCount,omitempty"`
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema any             `json:"responseJsonSchema,omitempty"`
+	ResponseLogprobs   bool            `json:"responseLogprobs,omitempty"`
+	Logprobs           *int            `json:"logprobs,omitempty"`
+	ThinkingConfig     *thinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type thinkingConfig struct {
+	ThinkingBudget int `json:"thinkingBudget"`
+}
+
+type safetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type tool struct {
+	GoogleSearch          *struct{}              `json:"googleSearch,omitempty"`
+	GoogleSearchRetrieval *googleSearchRetrieval `json:"googleSearchRetrieval,omitempty"`
+}
+
+type googleSearchRetrieval struct {
+	DynamicRetrievalConfig dynamicRetrievalConfig `json:"dynamicRetrievalConfig"`
+}
+
+type dynamicRetrievalConfig struct {
+	Mode             string  `json:"mode"`
+	DynamicThreshold float64 `json:"dynamicThreshold"`
+}
+
+type generateResponse struct {
+	Candidates     []candidate    `json:"candidates"`
+	PromptFeedback promptFeedback `json:"promptFeedback"`
+	UsageMetadata  usageMetadata  `json:"usageMetadata"`
+	ResponseID     string         `json:"responseId"`
+}
+
+type candidate struct {
+	Content           content            `json:"content"`
+	FinishReason      string             `json:"finishReason"`
+	Index             int                `json:"index"`
+	GroundingMetadata *groundingMetadata `json:"groundingMetadata"`
+	LogprobsResult    *logprobsResult    `json:"logprobsResult"`
+}
+
+type promptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount        int64 `json:"promptTokenCount"`
+	CandidatesTokenCount    int64 `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int64 `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+}
+
+type groundingMetadata struct {
+	WebSearchQueries []string `json:"webSearchQueries"`
+	GroundingChunks  []struct {
+		Web *struct {
+			URI   string `json:"uri"`
+			Title string `json:"title"`
+		} `json:"web"`
+	} `json:"groundingChunks"`
+}
+
+type logprobsResult struct {
+	TopCandidates []struct {
+		Candidates []logprobCandidate `json:"candidates"`
+	} `json:"topCandidates"`
+	ChosenCandidates []logprobCandidate `json:"chosenCandidates"`
+}
+
+type logprobCandidate struct {
+	Token          string  `json:"token"`
+	LogProbability float64 `json:"logProbability"`
+}
+
+// CountTokens estimates the size and input cost of req with the tokenizer registered for the model
+func (p *GeminiCompletionModel) CountTokens(req *llm.CompletionRequest) (*llm.TokenCount, error) {
+	return common.CountTokens(p.modelInfo, p.tokenizer(), req), nil
+}
+
+func (p *GeminiCompletionModel) tokenizer() llm.Tokenizer {
+	return llm.GetTokenizer(llm.OpenAIEncoding(p.name))
+}
+
+func (p *GeminiCompletionModel) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	opts, determinism := llm.NormalizeDeterminism(llm.ApplyContextCompletionOptions(ctx, p.options), true, !p.modelInfo.Reasoning)
+	body, err := p.generateRequest(req, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	resp, err := p.provider.send(ctx, http.MethodPost, "/models/"+p.modelInfo.ID+":generateContent", body, "failed to generate content")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var generated generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&generated); err != nil {
+		return nil, llm.NewResponseError("gemini", "failed to parse generate content response", err)
+	}
+
+	response := &llm.CompletionResponse{ID: generated.ResponseID}
+	if reason := generated.PromptFeedback.BlockReason; reason != "" {
+		response.FinishReason = llm.FinishReasonContentFilter
+		response.Refusal = "prompt blocked: " + reason
+	} else {
+		if len(generated.Candidates) == 0 {
+			return nil, llm.ErrEmptyContent
+		}
+		choices := make([]llm.CompletionChoice, len(generated.Candidates))
+		for i, c := range generated.Candidates {
+			choices[i] = llm.CompletionChoice{
+				Index:        c.Index,
+				Text:         candidateText(c),
+				FinishReason: finishReason(c.FinishReason),
+				Logprobs:     toTokenLogprobs(c.LogprobsResult),
+			}
+		}
+		// A candidate stopped by a safety filter may come without text, and is reported by its
+		// finish reason
+		if choices[0].Text == "" && choices[0].FinishReason != llm.FinishReasonContentFilter {
+			return nil, llm.ErrEmptyContent
+		}
+		response.Output = choices[0].Text
+		response.FinishReason = choices[0].FinishReason
+		response.Logprobs = choices[0].Logprobs
+		if len(choices) > 1 {
+			response.Choices = choices
+		}
+		if search := toWebSearch(generated.Candidates[0].GroundingMetadata); search != nil {
+			response.WebSearches = []*llm.WebSearch{search}
+		}
+	}
+
+	if opts.WithUsage != nil && *opts.WithUsage {
+		chunk := p.usageChunk(generated.UsageMetadata, len(response.WebSearches), opts)
+		response.Usage = chunk.Usage
+		response.Cost = chunk.Cost
+		response.CostBreakdown = chunk.CostBreakdown
+	}
+	if determinism != "" {
+		response.Metadata = &llm.ResponseMetadata{Determinism: determinism}
+	}
+	return response, nil
+}
+
+func (p *GeminiCompletionModel) StreamComplete(ctx context.Context, req *llm.CompletionRequest) (llm.StreamCompletionResponse, error) {
+	opts, _ := llm.NormalizeDeterminism(llm.ApplyContextCompletionOptions(ctx, p.options), true, !p.modelInfo.Reasoning)
+	// Chunks do not tell candidates apart
+	if opts.N != nil && *opts.N > 1 {
+		return nil, llm.NewUnsupportedCapabilityError(p.provider.Name(), "streams with multiple choices")
+	}
+	body, err := p.generateRequest(req, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.provider.send(ctx, http.MethodPost, "/models/"+p.modelInfo.ID+":streamGenerateContent?alt=sse", body, "failed to stream content")
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	chunkChan := make(chan llm.StreamChunk, 1)
+	sendUsage := opts.WithUsage != nil && *opts.WithUsage && opts.StreamsEvent(llm.UsageChunkType)
+
+	go func() {
+		defer done()
+		defer close(chunkChan)
+		defer llm.SendStreamCanceled(ctx, chunkChan, "gemini")
+		defer llm.CloseOnDone(ctx, resp.Body)()
+
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case chunkChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var usage usageMetadata
+		var id string
+		var finish llm.FinishReason
+		var refusal string
+		var search *llm.WebSearch
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			// Comments, keep-alives and empty data lines carry no event
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if data = strings.TrimSpace(data); !ok || data == "" {
+				continue
+			}
+			var event generateResponse
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(llm.StreamErrorChunk{Provider: "gemini", Err: llm.NewResponseError("gemini", "failed to parse stream event", err)})
+				return
+			}
+			if event.ResponseID != "" {
+				id = event.ResponseID
+			}
+			if event.UsageMetadata != (usageMetadata{}) {
+				usage = event.UsageMetadata
+			}
+			if reason := event.PromptFeedback.BlockReason; reason != "" {
+				finish, refusal = llm.FinishReasonContentFilter, "prompt blocked: "+reason
+			}
+			if len(event.Candidates) == 0 {
+				continue
+			}
+			c := event.Candidates[0]
+			if text := candidateText(c); text != "" && opts.StreamsEvent(llm.TextChunkType) {
+				if !send(opts.TextChunk(text)) {
+					return
+				}
+			}
+			if logprobs := toTokenLogprobs(c.LogprobsResult); logprobs != nil && opts.StreamsEvent(llm.LogprobChunkType) {
+				if !send(llm.StreamLogprobChunk{Logprobs: logprobs}) {
+					return
+				}
+			}
+			if s := toWebSearch(c.GroundingMetadata); s != nil {
+				search = s
+			}
+			if c.FinishReason != "" {
+				finish = finishReason(c.FinishReason)
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() == nil {
+				send(llm.StreamErrorChunk{Provider: "gemini", Err: err})
+			}
+			return
+		}
+		searches := 0
+		if search != nil {
+			searches = 1
+			if opts.StreamsEvent(llm.WebSearchChunkType) && !send(llm.StreamWebSearchChunk{Search: search}) {
+				return
+			}
+		}
+		if opts.StreamsEvent(llm.FinishChunkType) && !send(llm.StreamFinishChunk{Reason: finish, Refusal: refusal, ID: id}) {
+			return
+		}
+		if sendUsage {
+			send(p.usageChunk(usage, searches, opts))
+		}
+	}()
+
+	return chunkChan, nil
+}
+
+// generateRequest validates req and converts it into a generate content request
+func (p *GeminiCompletionModel) generateRequest(req *llm.CompletionRequest, opts *llm.CompletionOptions) (*generateRequest, error) {
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	req, err := common.NormalizeRequestArtifacts(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.ValidateMessageInputs(p.modelInfo, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := common.CheckExtendedContext(p.provider.Name(), p.modelInfo, opts); err != nil {
+		return nil, err
+	}
+	if err := common.CheckContextWindow(p.modelInfo, p.tokenizer(), req, opts); err != nil {
+		return nil, err
+	}
+
+	contents, err := toContents(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	body := &generateRequest{Contents: contents}
+
+	instructions := req.Instructions
+	if opts.OutputLanguage != nil && *opts.OutputLanguage != "" {
+		if instructions != "" {
+			instructions += "\n\n"
+		}
+		instructions += llm.OutputLanguageInstruction(*opts.OutputLanguage, false)
+	}
+	if instructions != "" {
+		body.SystemInstruction = &content{Parts: []part{{Text: instructions}}}
+	}
+	if opts.CachedContent != nil {
+		body.CachedContent = *opts.CachedContent
+	}
+
+	config := &generationConfig{
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		StopSequences:    opts.Stop,
+		Seed:             opts.Seed,
+		PresencePenalty:  opts.PresencePenalty,
+		FrequencyPenalty: opts.FrequencyPenalty,
+		CandidateCount:   opts.N,
+	}
+	if opts.MaxTokens != nil && *opts.MaxTokens > 0 {
+		config.MaxOutputTokens = opts.MaxTokens
+	} else if opts.MaxOutputTokens != nil && *opts.MaxOutputTokens > 0 {
+		config.MaxOutputTokens = opts.MaxOutputTokens
+	}
+	if opts.ResponseFormat != nil {
+		switch *opts.ResponseFormat {
+		case llm.ResponseFormatJson:
+			config.ResponseMimeType = "application/json"
+		case llm.ResponseFormatJsonSchema:
+			config.ResponseMimeType = "application/json"
+			config.ResponseJSONSchema = opts.JSONSchema
+		}
+	}
+	if opts.TopLogprobs != nil {
+		config.ResponseLogprobs = true
+		if *opts.TopLogprobs > 0 {
+			config.Logprobs = opts.TopLogprobs
+		}
+	}
+	if opts.ReasoningEffort != nil && p.modelInfo.Reasoning {
+		if budget, ok := thinkingBudgets[*opts.ReasoningEffort]; ok {
+			config.ThinkingConfig = &thinkingConfig{ThinkingBudget: budget}
+		}
+	}
+	body.GenerationConfig = config
+
+	for _, setting := range opts.SafetySettings {
+		body.SafetySettings = append(body.SafetySettings, safetySetting{Category: string(setting.Category), Threshold: string(setting.Threshold)})
+	}
+	if opts.Grounding != nil {
+		if threshold := opts.Grounding.DynamicThreshold; threshold != nil {
+			body.Tools = append(body.Tools, tool{GoogleSearchRetrieval: &googleSearchRetrieval{
+				DynamicRetrievalConfig: dynamicRetrievalConfig{Mode: "MODE_DYNAMIC", DynamicThreshold: *threshold},
+			}})
+		} else {
+			body.Tools = append(body.Tools, tool{GoogleSearch: &struct{}{}})
+		}
+	}
+	return body, nil
+}
+
+// usageChunk converts usage metadata into token usage with cost if requested. Grounded
+// requests are billed as one web search.
+func (p *GeminiCompletionModel) usageChunk(u usageMetadata, searches int, opts *llm.CompletionOptions) llm.StreamUsageChunk {
+	usage := &llm.TokenUsage{
+		TotalInputTokens:     u.PromptTokenCount,
+		TotalOutputTokens:    u.CandidatesTokenCount,
+		TotalReasoningTokens: u.ThoughtsTokenCount,
+		TotalCacheReadTokens: u.CachedContentTokenCount,
+		TotalWebSearches:     searches,
+		TotalRequests:        1,
+	}
+	var cost *float64
+	var breakdown *llm.CostBreakdown
+	if opts.WithCost != nil && *opts.WithCost {
+		cost, breakdown = common.CalculateCostBreakdown(p.modelInfo, usage)
+	}
+	return llm.StreamUsageChunk{Usage: usage, Cost: cost, CostBreakdown: breakdown}
+}
+
+// candidateText returns the text of a candidate without its thoughts
+func candidateText(c candidate) string {
+	var sb strings.Builder
+	for _, part := range c.Content.Parts {
+		if !part.Thought {
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String()
+}
+
+// finishReason normalizes the finish reason of a candidate
+func finishReason(reason string) llm.FinishReason {
+	switch reason {
+	case "STOP":
+		return llm.FinishReasonStop
+	case "MAX_TOKENS":
+		return llm.FinishReasonLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return llm.FinishReasonContentFilter
+	}
+	return ""
+}
+
+// toWebSearch converts the grounding metadata of a candidate into the searches it ran, or nil
+// when the response was not grounded
+func toWebSearch(metadata *groundingMetadata) *llm.WebSearch {
+	if metadata == nil || (len(metadata.WebSearchQueries) == 0 && len(metadata.GroundingChunks) == 0) {
+		return nil
+	}
+	search := &llm.WebSearch{Query: strings.Join(metadata.WebSearchQueries, "; ")}
+	for _, chunk := range metadata.GroundingChunks {
+		if chunk.Web != nil {
+			search.Results = append(search.Results, &llm.WebSearchResult{URL: chunk.Web.URI, Title: chunk.Web.Title})
+		}
+	}
+	return search
+}
+
+// toTokenLogprobs converts the log probabilities of a candidate
+func toTokenLogprobs(result *logprobsResult) []llm.TokenLogprob {
+	if result == nil || len(result.ChosenCandidates) == 0 {
+		return nil
+	}
+	logprobs := make([]llm.TokenLogprob, len(result.ChosenCandidates))
+	for i, chosen := range result.ChosenCandidates {
+		logprobs[i] = llm.TokenLogprob{Token: chosen.Token, Logprob: chosen.LogProbability}
+		if i < len(result.TopCandidates) {
+			for _, top := range result.TopCandidates[i].Candidates {
+				logprobs[i].TopLogprobs = append(logprobs[i].TopLogprobs, llm.TokenLogprob{Token: top.Token, Logprob: top.LogProbability})
+			}
+		}
+	}
+	return logprobs
+}
+
+// toContents converts messages into Gemini contents, merging consecutive messages of the same
+// role. Tool calls use the same fenced JSON text convention as the other providers so agent
+// loops behave identically.
+func toContents(messages []*llm.ModelMessage) ([]content, error) {
+	result := make([]content, 0, len(messages))
+	for i, msg := range messages {
+		if msg == nil {
+			return nil, llm.NewValidationError(fmt.Sprintf("messages[%d]", i), "cannot be nil", nil)
+		}
+
+		var c content
+		switch msg.Role {
+		case llm.RoleUser, llm.RoleTool:
+			c.Role = "user"
+			for _, contentPart := range msg.ContentParts() {
+				converted, err := toPart(contentPart)
+				if err != nil {
+					return nil, err
+				}
+				if converted != nil {
+					c.Parts = append(c.Parts, *converted)
+				}
+			}
+
+		case llm.RoleAssistant:
+			c.Role = "model"
+			text := msg.Text()
+			if msg.ToolCall != nil {
+				var err error
+				text, err = toolCallText("call tool: ", msg.ToolCall)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal tool call: %w", err)
+				}
+			}
+			c.Parts = []part{{Text: text}}
+
+		default:
+			return nil, llm.NewValidationError("role", "unknown role", string(msg.Role))
+		}
+
+		if n := len(result); n > 0 && result[n-1].Role == c.Role {
+			result[n-1].Parts = append(result[n-1].Parts, c.Parts...)
+			continue
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// toPart converts a content part. Media is sent inline, or by URI when it is remote.
+func toPart(contentPart *llm.ContentPart) (*part, error) {
+	if contentPart == nil {
+		return nil, nil
+	}
+	switch contentPart.Type {
+	case llm.ContentPartText:
+		return &part{Text: contentPart.Text}, nil
+	case llm.ContentPartToolResult:
+		text, err := toolCallText("call tool results: ", contentPart.ToolCall)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal tool call results: %w", err)
+		}
+		return &part{Text: text}, nil
+	}
+	artifact := contentPart.Artifact
+	if artifact == nil {
+		return nil, nil
+	}
+	if len(artifact.Content) == 0 && artifact.URL != "" {
+		return &part{FileData: &fileData{MimeType: artifact.ContentType, FileURI: artifact.URL}}, nil
+	}
+	return &part{InlineData: &inlineData{MimeType: artifact.ContentType, Data: base64.StdEncoding.EncodeToString(artifact.Content)}}, nil
+}
+
+// toolCallText formats a tool call as the fenced JSON text sent to the model
+func toolCallText(prefix string, call *llm.ToolCall) (string, error) {
+	jsonBytes, err := json.Marshal(call)
+	if err != nil {
+		return "", err
+	}
+	return prefix + "```" + string(jsonBytes) + "```", nil
+}
@@ -4,19 +4,38 @@
 package gemini
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
 	"github.com/easyagent-dev/llm"
 	"github.com/easyagent-dev/llm/internal/providers/openai"
+	sdk "github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
 )
 
+const defaultBaseURL = "https://generativelanguage.googleapis.com/v1beta/openai/"
+
+// GeminiModelProvider provides Gemini models. Completions use the native Gemini API, which
+// supports safety settings, grounding and cached content; the other models use its OpenAI
+// compatible endpoint.
 type GeminiModelProvider struct {
 	*openai.OpenAIModelProvider
+	// native sends requests to the native API with the request options of the provider, so a
+	// client configured with option.WithHTTPClient or middlewares serve both APIs
+	native sdk.Client
 }
 
-var _ llm.ModelProvider = (*GeminiModelProvider)(nil)
+var (
+	_ llm.ModelProvider        = (*GeminiModelProvider)(nil)
+	_ llm.ContentCacheProvider = (*GeminiModelProvider)(nil)
+)
 
 //go:embed gemini.json
 var geminiModels []byte
@@ -31,13 +50,17 @@ func NewGeminiModelProvider(opts ...llm.ModelOption) (*GeminiModelProvider, erro
 	// Build request options list
 	requestOpts := []option.RequestOption{}
 	requestOpts = append(requestOpts, option.WithAPIKey(config.APIKey))
+	if config.Retry != nil {
+		requestOpts = append(requestOpts, option.WithMaxRetries(0))
+	}
 
 	// Set base URL (use default if not provided)
 	baseURL := config.BaseURL
 	if baseURL == "" {
-		baseURL = "https://generativelanguage.googleapis.com/v1beta/openai/"
+		baseURL = defaultBaseURL
 	}
-	requestOpts = append(requestOpts, option.WithBaseURL(baseURL))
+	compatibleURL, nativeURL := baseURLs(baseURL)
+	requestOpts = append(requestOpts, option.WithBaseURL(compatibleURL))
 
 	// Append any custom options
 	requestOpts = append(requestOpts, config.Options...)
@@ -47,13 +70,95 @@ func NewGeminiModelProvider(opts ...llm.ModelOption) (*GeminiModelProvider, erro
 		return nil, errors.New("failed to read model info")
 	}
 
-	// Create the completion model with DeepSeek's API endpoint
+	// The models other than completions use the OpenAI compatible endpoint
 	provider, err := openai.NewBaseOpenAIModelProvider("gemini", models, requestOpts)
 	if err != nil {
 		return nil, err
 	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
 
-	return &GeminiModelProvider{
+	// The native API takes the key in its own header rather than as a bearer token
+	nativeOpts := slices.Concat(requestOpts, []option.RequestOption{
+		option.WithBaseURL(nativeURL),
+		option.WithHeaderDel("authorization"),
+		option.WithHeader("x-goog-api-key", config.APIKey),
+	})
+	gemini := &GeminiModelProvider{
 		OpenAIModelProvider: provider,
-	}, nil
+		native:              sdk.NewClient(nativeOpts...),
+	}
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, gemini)
+	return gemini, nil
+}
+
+// baseURLs returns the base URLs of the OpenAI compatible endpoint and of the native API. The
+// compatible endpoint is the native one with an /openai suffix, so baseURL may be either.
+func baseURLs(baseURL string) (compatible, native string) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if native, ok := strings.CutSuffix(baseURL, "/openai"); ok {
+		return baseURL + "/", native + "/"
+	}
+	return baseURL + "/openai/", baseURL + "/"
+}
+
+// NewCompletionModel creates a completion model using the native Gemini API
+func (p *GeminiModelProvider) NewCompletionModel(model string, opts ...llm.CompletionOption) (llm.CompletionModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityCompletion) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "completions")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.DecorateCompletionModel(&GeminiCompletionModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		options:   opts,
+		inflight:  p.InFlight(),
+	}, info, opts), nil
+}
+
+// send sends body, if any, to the native API path with method and returns the response for
+// the caller to close. Error statuses are returned as a RequestError.
+func (p *GeminiModelProvider) send(ctx context.Context, method, path string, body any, message string) (*http.Response, error) {
+	var opts []option.RequestOption
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		opts = append(opts, option.WithRequestBody("application/json", data))
+	}
+
+	var resp *http.Response
+	opts = append(opts, option.WithResponseInto(&resp))
+	err := p.native.Execute(ctx, method, path, nil, &resp, opts...)
+	if err == nil {
+		return resp, nil
+	}
+	if resp != nil && resp.StatusCode >= http.StatusBadRequest {
+		// The SDK keeps the body of error responses readable
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &llm.RequestError{
+			Provider:   "gemini",
+			StatusCode: resp.StatusCode,
+			Message:    message,
+			Err:        errors.New(strings.TrimSpace(string(respBody))),
+			RetryAfter: llm.ParseRetryAfter(resp.Header),
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", message, err)
 }
@@ -1,8 +1,15 @@
 package gemini
 
 import (
-	"github.com/easyagent-dev/llm"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
 
 	"github.com/openai/openai-go/v3/option"
 	"github.com/stretchr/testify/assert"
@@ -143,6 +150,244 @@ func TestNewGeminiModel_MultipleInstances(t *testing.T) {
 	assert.NotSame(t, model1, model2, "Different instances should be created")
 }
 
+// newTestProvider returns a provider whose native API is served by handler
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *GeminiModelProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	provider, err := NewGeminiModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL+"/v1beta/openai/"))
+	require.NoError(t, err)
+	return provider
+}
+
+func TestGeminiCompletionModel_Complete(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models/gemini-2.5-pro:generateContent", r.URL.Path)
+		assert.Equal(t, "test-api-key", r.Header.Get("x-goog-api-key"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "cachedContents/abc", body["cachedContent"])
+		assert.Equal(t, []any{map[string]any{"category": "HARM_CATEGORY_HATE_SPEECH", "threshold": "BLOCK_ONLY_HIGH"}}, body["safetySettings"])
+		assert.Equal(t, []any{map[string]any{"googleSearch": map[string]any{}}}, body["tools"])
+		assert.Equal(t, map[string]any{"parts": []any{map[string]any{"text": "Be brief."}}}, body["systemInstruction"])
+		contents := body["contents"].([]any)
+		require.Len(t, contents, 1)
+		parts := contents[0].(map[string]any)["parts"].([]any)
+		require.Len(t, parts, 2)
+		assert.Equal(t, map[string]any{"mimeType": "image/png", "data": "iVBORw0KGgo="}, parts[1].(map[string]any)["inlineData"])
+		assert.Equal(t, 0.2, body["generationConfig"].(map[string]any)["temperature"])
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"thinking","thought":true},{"text":"Sunny in Paris."}]},
+			"finishReason":"STOP","index":0,"groundingMetadata":{"webSearchQueries":["weather paris"],
+			"groundingChunks":[{"web":{"uri":"https://weather.example/paris","title":"weather.example"}}]}}],
+			"usageMetadata":{"promptTokenCount":1000,"candidatesTokenCount":100,"cachedContentTokenCount":800},"responseId":"r1"}`)
+	})
+
+	model, err := provider.NewCompletionModel("gemini-2.5-pro", llm.WithUsage(true), llm.WithCost(true), llm.WithTemperature(0.2),
+		llm.WithCachedContent("cachedContents/abc"),
+		llm.WithSafetySettings(llm.SafetySetting{Category: llm.HarmCategoryHateSpeech, Threshold: llm.HarmBlockOnlyHigh}),
+		llm.WithGrounding(llm.GroundingOptions{}))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Instructions: "Be brief.",
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Weather in Paris?", Artifacts: []*llm.ModelArtifact{
+			{Name: "map.png", ContentType: "image/png", Content: []byte("\x89PNG\r\n\x1a\n")},
+		}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Sunny in Paris.", resp.Output)
+	assert.Equal(t, llm.FinishReasonStop, resp.FinishReason)
+	assert.Equal(t, "r1", resp.ID)
+	require.Len(t, resp.WebSearches, 1)
+	assert.Equal(t, "weather paris", resp.WebSearches[0].Query)
+	assert.Equal(t, "https://weather.example/paris", resp.WebSearches[0].Results[0].URL)
+	assert.Equal(t, int64(800), resp.Usage.TotalCacheReadTokens)
+	assert.Equal(t, 1, resp.Usage.TotalWebSearches)
+	require.NotNil(t, resp.Cost)
+	// 200*1.25 + 800*0.31 + 100*10 per million tokens, plus 0.035 for the grounded request
+	assert.InDelta(t, 0.036498, *resp.Cost, 1e-9)
+}
+
+func TestGeminiCompletionModel_Blocked(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"promptFeedback":{"blockReason":"SAFETY"},"usageMetadata":{"promptTokenCount":10}}`)
+	})
+	model, err := provider.NewCompletionModel("gemini-2.0-flash")
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, llm.FinishReasonContentFilter, resp.FinishReason)
+	assert.Equal(t, "prompt blocked: SAFETY", resp.Refusal)
+}
+
+func TestGeminiCompletionModel_EmptyCandidate(t *testing.T) {
+	finish := "STOP"
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"candidates":[{"content":{"parts":[]},"finishReason":%q}]}`, finish)
+	})
+	model, err := provider.NewCompletionModel("gemini-2.0-flash")
+	require.NoError(t, err)
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+
+	_, err = model.Complete(context.Background(), req)
+	assert.ErrorIs(t, err, llm.ErrEmptyContent)
+
+	// A candidate stopped by a safety filter is reported by its finish reason
+	finish = "SAFETY"
+	resp, err := model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, llm.FinishReasonContentFilter, resp.FinishReason)
+}
+
+// roundTripFunc is an http.RoundTripper calling the function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestGeminiModelProvider_NativeClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models/gemini-2.0-flash:generateContent", r.URL.Path)
+		assert.Equal(t, "test-api-key", r.Header.Get("x-goog-api-key"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+		if r.Header.Get("X-Attempt") == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "overloaded")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"Hello"}]},"finishReason":"STOP"}]}`)
+	}))
+	defer server.Close()
+
+	// The configured client serves the native API, which the base URL names directly
+	var requests int
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		if requests > 1 {
+			req.Header.Set("X-Attempt", fmt.Sprint(requests))
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	provider, err := NewGeminiModelProvider(
+		llm.WithAPIKey("test-api-key"),
+		llm.WithBaseURL(server.URL+"/v1beta"),
+		llm.WithRequestOptions(option.WithHTTPClient(client), option.WithMaxRetries(0)),
+	)
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("gemini-2.0-flash")
+	require.NoError(t, err)
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+
+	_, err = model.Complete(context.Background(), req)
+	var reqErr *llm.RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusServiceUnavailable, reqErr.StatusCode)
+	assert.Contains(t, reqErr.Error(), "overloaded")
+
+	resp, err := model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Output)
+	assert.Equal(t, 2, requests)
+}
+
+func TestBaseURLs(t *testing.T) {
+	compatible, native := baseURLs(defaultBaseURL)
+	assert.Equal(t, "https://generativelanguage.googleapis.com/v1beta/openai/", compatible)
+	assert.Equal(t, "https://generativelanguage.googleapis.com/v1beta/", native)
+
+	compatible, native = baseURLs("https://proxy.example.com/gemini")
+	assert.Equal(t, "https://proxy.example.com/gemini/openai/", compatible)
+	assert.Equal(t, "https://proxy.example.com/gemini/", native)
+}
+
+func TestGeminiCompletionModel_StreamComplete(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1beta/models/gemini-2.0-flash:streamGenerateContent", r.URL.Path)
+		assert.Equal(t, "sse", r.URL.Query().Get("alt"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hello\"}]}}],\"responseId\":\"r2\"}\n\n")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\" there\"}]},\"finishReason\":\"MAX_TOKENS\"}],"+
+			"\"usageMetadata\":{\"promptTokenCount\":10,\"candidatesTokenCount\":2}}\n\n")
+	})
+	model, err := provider.NewCompletionModel("gemini-2.0-flash", llm.WithUsage(true))
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	var text string
+	var finish llm.StreamFinishChunk
+	var usage llm.StreamUsageChunk
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case llm.StreamTextChunk:
+			text += c.Text
+		case llm.StreamFinishChunk:
+			finish = c
+		case llm.StreamUsageChunk:
+			usage = c
+		case llm.StreamErrorChunk:
+			t.Fatal(c.Err)
+		}
+	}
+	assert.Equal(t, "Hello there", text)
+	assert.Equal(t, llm.FinishReasonLength, finish.Reason)
+	assert.Equal(t, "r2", finish.ID)
+	require.NotNil(t, usage.Usage)
+	assert.Equal(t, int64(2), usage.Usage.TotalOutputTokens)
+}
+
+func TestGeminiModelProvider_CachedContent(t *testing.T) {
+	deleted := false
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1beta/cachedContents":
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "models/gemini-2.5-flash", body["model"])
+			assert.Equal(t, "3600.000s", body["ttl"])
+			assert.NotNil(t, body["systemInstruction"])
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"name":"cachedContents/abc","model":"models/gemini-2.5-flash","expireTime":"2025-01-01T01:00:00Z","usageMetadata":{"totalTokenCount":4096}}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/v1beta/cachedContents/abc":
+			deleted = true
+			fmt.Fprint(w, `{}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	var cacher llm.ContentCacheProvider = provider
+	cached, err := cacher.CreateCachedContent(context.Background(), "gemini-2.5-flash", &llm.CompletionRequest{
+		Instructions: "You answer questions about the manual.",
+		Messages:     []*llm.ModelMessage{{Role: llm.RoleUser, Content: "The manual..."}},
+	}, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "cachedContents/abc", cached.Name)
+	assert.Equal(t, "gemini-2.5-flash", cached.Model)
+	assert.Equal(t, int64(4096), cached.TokenCount)
+	assert.Equal(t, time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC), cached.ExpireTime)
+
+	require.NoError(t, cacher.DeleteCachedContent(context.Background(), cached.Name))
+	assert.True(t, deleted)
+
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, cacher.DeleteCachedContent(context.Background(), "abc"), &validationErr)
+	_, err = cacher.CreateCachedContent(context.Background(), "unknown", &llm.CompletionRequest{}, 0)
+	assert.ErrorIs(t, err, llm.ErrInvalidModel)
+}
+
 // Benchmark tests
 func BenchmarkNewGeminiModel_Success(b *testing.B) {
 	opts := []llm.ModelOption{
@@ -0,0 +1,72 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+)
+
+type listModelsResponse struct {
+	Models []struct {
+		Name                       string   `json:"name"`
+		DisplayName                string   `json:"displayName"`
+		InputTokenLimit            int      `json:"inputTokenLimit"`
+		OutputTokenLimit           int      `json:"outputTokenLimit"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// ListModels lists the models of the native models endpoint, the source of the catalog
+// refreshed with llm.WithModelRefresh. Models that neither generate content nor embed it are
+// skipped; the others are not priced.
+func (p *GeminiModelProvider) ListModels(ctx context.Context) ([]*llm.ModelInfo, error) {
+	var models []*llm.ModelInfo
+	query := url.Values{"pageSize": {"1000"}}
+	for {
+		resp, err := p.send(ctx, http.MethodGet, "/models?"+query.Encode(), nil, "failed to list models")
+		if err != nil {
+			return nil, err
+		}
+		var page listModelsResponse
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, llm.NewResponseError("gemini", "failed to parse models response", err)
+		}
+
+		for _, model := range page.Models {
+			info := &llm.ModelInfo{
+				ID:              strings.TrimPrefix(model.Name, "models/"),
+				Name:            model.DisplayName,
+				Input:           []llm.ModelMediaType{llm.ModelMediaTypeText},
+				ContextWindow:   model.InputTokenLimit,
+				MaxOutputTokens: model.OutputTokenLimit,
+			}
+			switch {
+			case slices.Contains(model.SupportedGenerationMethods, "generateContent"):
+				info.Capabilities = []llm.ModelCapability{llm.ModelCapabilityCompletion}
+				info.Output = []llm.ModelMediaType{llm.ModelMediaTypeText}
+			case slices.Contains(model.SupportedGenerationMethods, "embedContent"):
+				info.Capabilities = []llm.ModelCapability{llm.ModelCapabilityEmbedding}
+				info.Embedding = true
+			default:
+				continue
+			}
+			models = append(models, info)
+		}
+
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
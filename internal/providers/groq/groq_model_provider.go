@@ -0,0 +1,130 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package groq
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/openai"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// GroqModelProvider provides low-latency Llama, Mixtral and Gemma models through Groq's
+// OpenAI compatible API
+type GroqModelProvider struct {
+	*openai.OpenAIModelProvider
+}
+
+var _ llm.ModelProvider = (*GroqModelProvider)(nil)
+
+//go:embed groq.json
+var groqModels []byte
+
+func NewGroqModelProvider(opts ...llm.ModelOption) (*GroqModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+
+	// Build request options list
+	requestOpts := []option.RequestOption{}
+	requestOpts = append(requestOpts, option.WithAPIKey(config.APIKey))
+
+	// Set base URL (use default if not provided)
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.groq.com/openai/v1/"
+	}
+	requestOpts = append(requestOpts, option.WithBaseURL(baseURL))
+
+	// Append any custom options
+	requestOpts = append(requestOpts, config.Options...)
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(groqModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	// Create the completion model with Groq's API endpoint
+	provider, err := openai.NewBaseOpenAIModelProvider("groq", models, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, provider)
+	provider.SetCompletionFields(config.CompletionFields)
+	provider.SetMetadataHook(serverTiming)
+
+	return &GroqModelProvider{
+		OpenAIModelProvider: provider,
+	}, nil
+}
+
+// groqUsage is the timing Groq reports in the usage of a completion, in seconds
+type groqUsage struct {
+	QueueTime      float64 `json:"queue_time"`
+	PromptTime     float64 `json:"prompt_time"`
+	CompletionTime float64 `json:"completion_time"`
+	TotalTime      float64 `json:"total_time"`
+}
+
+// serverTiming records the queue, prompt and completion times Groq reports in the usage of a
+// completion, with the request ID and the region from the x-groq-region header. Streams report
+// them in the x_groq field of their last chunk, so the timing of every chunk is merged.
+func serverTiming(metadata *llm.ResponseMetadata, body string, header http.Header) {
+	var resp struct {
+		Usage *groqUsage `json:"usage"`
+		XGroq struct {
+			ID    string     `json:"id"`
+			Usage *groqUsage `json:"usage"`
+		} `json:"x_groq"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return
+	}
+
+	var timing llm.ServerTiming
+	if metadata.ServerTiming != nil {
+		timing = *metadata.ServerTiming
+	}
+	for _, usage := range []*groqUsage{resp.XGroq.Usage, resp.Usage} {
+		if usage == nil {
+			continue
+		}
+		setSeconds(&timing.Queue, usage.QueueTime)
+		setSeconds(&timing.Prompt, usage.PromptTime)
+		setSeconds(&timing.Completion, usage.CompletionTime)
+		setSeconds(&timing.Total, usage.TotalTime)
+	}
+	timing.Region = header.Get("x-groq-region")
+	if resp.XGroq.ID != "" {
+		timing.RequestID = resp.XGroq.ID
+	} else if timing.RequestID == "" {
+		timing.RequestID = header.Get("x-request-id")
+	}
+	if timing != (llm.ServerTiming{}) {
+		metadata.ServerTiming = &timing
+	}
+}
+
+// setSeconds sets d to a duration of seconds, unless it is zero
+func setSeconds(d *time.Duration, seconds float64) {
+	if seconds > 0 {
+		*d = time.Duration(seconds * float64(time.Second))
+	}
+}
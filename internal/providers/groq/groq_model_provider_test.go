@@ -0,0 +1,123 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package groq
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGroqModelProvider(t *testing.T) {
+	_, err := NewGroqModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	provider, err := NewGroqModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "groq", provider.Name())
+	assert.NotEmpty(t, provider.SupportedModels())
+
+	_, err = provider.NewCompletionModel("llama-3.3-70b-versatile")
+	assert.NoError(t, err)
+	_, err = provider.NewCompletionModel("mixtral-8x7b-32768")
+	assert.NoError(t, err)
+
+	strict, err := NewGroqModelProvider(llm.WithAPIKey("test-api-key"), llm.WithStrictDeprecation(true))
+	require.NoError(t, err)
+	_, err = strict.NewCompletionModel("mixtral-8x7b-32768")
+	assert.ErrorIs(t, err, llm.ErrModelDeprecated)
+	_, err = strict.NewCompletionModel("llama-3.3-70b-versatile")
+	assert.NoError(t, err)
+}
+
+func TestGroqCompletionModel_Complete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer test-api-key", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("x-groq-region", "us-east-1")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"llama-3.1-8b-instant","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],`+
+			`"usage":{"queue_time":0.025,"prompt_tokens":1000000,"prompt_time":0.004,"completion_tokens":1000000,"completion_time":0.12,"total_tokens":2000000,"total_time":0.124},`+
+			`"x_groq":{"id":"req_01abc"}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewGroqModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("llama-3.1-8b-instant", llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Output)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 0.13, *resp.Cost, 1e-9)
+
+	require.NotNil(t, resp.Metadata)
+	assert.Equal(t, &llm.ServerTiming{
+		Queue:      25 * time.Millisecond,
+		Prompt:     4 * time.Millisecond,
+		Completion: 120 * time.Millisecond,
+		Total:      124 * time.Millisecond,
+		Region:     "us-east-1",
+		RequestID:  "req_01abc",
+	}, resp.Metadata.ServerTiming)
+}
+
+func TestGroqCompletionModel_StreamComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("x-groq-region", "us-east-1")
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"llama-3.1-8b-instant\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}],\"x_groq\":{\"id\":\"req_01abc\"}}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"llama-3.1-8b-instant\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" there\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"llama-3.1-8b-instant\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],"+
+			"\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":2,\"total_tokens\":12},"+
+			"\"x_groq\":{\"id\":\"req_01abc\",\"usage\":{\"queue_time\":0.025,\"prompt_time\":0.004,\"completion_time\":0.12,\"total_time\":0.124}}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewGroqModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("llama-3.1-8b-instant", llm.WithUsage(true))
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	var text strings.Builder
+	var usage *llm.StreamUsageChunk
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case llm.StreamTextChunk:
+			text.WriteString(c.Text)
+		case llm.StreamUsageChunk:
+			usage = &c
+		}
+	}
+	assert.Equal(t, "Hello there", text.String())
+
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(10), usage.Usage.TotalInputTokens)
+	require.NotNil(t, usage.Metadata)
+	assert.Equal(t, &llm.ServerTiming{
+		Queue:      25 * time.Millisecond,
+		Prompt:     4 * time.Millisecond,
+		Completion: 120 * time.Millisecond,
+		Total:      124 * time.Millisecond,
+		Region:     "us-east-1",
+		RequestID:  "req_01abc",
+	}, usage.Metadata.ServerTiming)
+}
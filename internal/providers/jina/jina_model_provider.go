@@ -0,0 +1,231 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package jina
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+)
+
+//go:embed jina.json
+var jinaModels []byte
+
+const defaultBaseURL = "https://api.jina.ai"
+
+// JinaModelProvider provides Jina embedding and reranker models through the Jina AI API
+type JinaModelProvider struct {
+	*llm.DefaultModelProvider
+	client *common.APIClient
+}
+
+var _ llm.ModelProvider = (*JinaModelProvider)(nil)
+
+// NewJinaModelProvider creates a new Jina AI model provider
+func NewJinaModelProvider(opts ...llm.ModelOption) (*JinaModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(jinaModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	provider := llm.NewDefaultModelProvider("jina", models)
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, nil)
+
+	return &JinaModelProvider{
+		DefaultModelProvider: provider,
+		client:               common.NewAPIClient("jina", config.BaseURL, defaultBaseURL, config.APIKey),
+	}, nil
+}
+
+func (p *JinaModelProvider) NewEmbeddingModel(model string) (llm.EmbeddingModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityEmbedding) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "embeddings")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.EnforceEmbeddingPolicy(p.WrapEmbeddingModel(&JinaEmbeddingModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		inflight:  p.InFlight(),
+	}), info), nil
+}
+
+func (p *JinaModelProvider) NewRerankModel(model string) (llm.RerankModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityRerank) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "reranking")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.EnforceRerankPolicy(p.WrapRerankModel(&JinaRerankModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		inflight:  p.InFlight(),
+	}), info), nil
+}
+
+// JinaEmbeddingModel implements EmbeddingModel with the Jina Embeddings API
+type JinaEmbeddingModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *JinaModelProvider
+	inflight  *llm.InFlight
+}
+
+var _ llm.EmbeddingModel = (*JinaEmbeddingModel)(nil)
+
+type embedRequest struct {
+	Model         string   `json:"model"`
+	Input         []string `json:"input"`
+	Task          string   `json:"task,omitempty"`
+	Dimensions    int      `json:"dimensions,omitempty"`
+	EmbeddingType string   `json:"embedding_type"`
+}
+
+// GenerateEmbeddings embeds req.Contents. The task type selects the task adapter of
+// jina-embeddings-v3; texts without a task type use the general purpose embeddings.
+func (p *JinaEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	// Default to the model this instance was created for
+	req = common.DefaultEmbeddingModel(req, p.modelInfo.ID)
+
+	if err := common.ValidateEmbeddingRequest(req); err != nil {
+		return nil, err
+	}
+	body := embedRequest{
+		Model:         req.Model,
+		Input:         req.Contents,
+		EmbeddingType: "float",
+	}
+	if req.Config != nil {
+		if req.Config.EncodingFormat == llm.EmbeddingEncodingFormatBase64 {
+			return nil, llm.NewValidationError("encoding_format", "base64 is not supported by Jina", req.Config.EncodingFormat)
+		}
+		if req.Config.TaskType != "" {
+			if body.Task = task(req.Config.TaskType); body.Task == "" {
+				return nil, llm.NewValidationError("task_type", "is not supported by Jina", req.Config.TaskType)
+			}
+		}
+		body.Dimensions = req.Config.Dimensions
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var embed common.EmbeddingsResponse
+	if err := p.provider.client.PostJSON(ctx, "/v1/embeddings", body, &embed, "failed to generate embeddings"); err != nil {
+		return nil, err
+	}
+	return embed.EmbeddingResponse(p.provider.GetModelInfo(req.Model)), nil
+}
+
+// task maps a task type to a jina-embeddings-v3 task adapter
+func task(taskType llm.EmbeddingTaskType) string {
+	switch taskType {
+	case llm.EmbeddingTaskSearchDocument:
+		return "retrieval.passage"
+	case llm.EmbeddingTaskSearchQuery:
+		return "retrieval.query"
+	case llm.EmbeddingTaskClassification:
+		return "classification"
+	case llm.EmbeddingTaskClustering:
+		return "separation"
+	case llm.EmbeddingTaskSemanticSimilarity:
+		return "text-matching"
+	}
+	return ""
+}
+
+// JinaRerankModel implements RerankModel with the Jina Reranker API
+type JinaRerankModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *JinaModelProvider
+	inflight  *llm.InFlight
+}
+
+var _ llm.RerankModel = (*JinaRerankModel)(nil)
+
+type rerankRequest struct {
+	Model           string   `json:"model"`
+	Query           string   `json:"query"`
+	Documents       []string `json:"documents"`
+	TopN            int      `json:"top_n,omitempty"`
+	ReturnDocuments bool     `json:"return_documents"`
+}
+
+type rerankResponse struct {
+	Results []common.ScoredDocument `json:"results"`
+	Usage   common.TotalTokens      `json:"usage"`
+}
+
+// Rerank orders req.Documents by relevance to req.Query. Jina bills the tokens of the query and
+// documents at the model's prompt price.
+func (p *JinaRerankModel) Rerank(ctx context.Context, req *llm.RerankRequest) (*llm.RerankResponse, error) {
+	if err := common.ValidateRerankRequest(req); err != nil {
+		return nil, err
+	}
+	model := req.Model
+	if model == "" {
+		model = p.modelInfo.ID
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var rerank rerankResponse
+	if err := p.provider.client.PostJSON(ctx, "/v1/rerank", rerankRequest{
+		Model:     model,
+		Query:     req.Query,
+		Documents: req.Documents,
+		TopN:      req.TopN,
+	}, &rerank, "failed to rerank documents"); err != nil {
+		return nil, err
+	}
+	results, err := common.RerankResults("jina", req, rerank.Results)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := rerank.Usage.Usage()
+	return &llm.RerankResponse{
+		Results: results,
+		Usage:   usage,
+		Cost:    common.CalculateCost(p.provider.GetModelInfo(model), usage),
+	}, nil
+}
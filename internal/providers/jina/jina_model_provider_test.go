@@ -0,0 +1,132 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package jina
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *JinaModelProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewJinaModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	return provider
+}
+
+func TestNewJinaModelProvider(t *testing.T) {
+	_, err := NewJinaModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	provider, err := NewJinaModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "jina", provider.Name())
+
+	_, err = provider.NewCompletionModel("jina-embeddings-v3")
+	assert.Error(t, err)
+	var capErr *llm.UnsupportedCapabilityError
+	_, err = provider.NewRerankModel("jina-embeddings-v3")
+	assert.ErrorAs(t, err, &capErr)
+	_, err = provider.NewEmbeddingModel("jina-reranker-m0")
+	assert.ErrorAs(t, err, &capErr)
+}
+
+func TestJinaEmbeddingModel_GenerateEmbeddings(t *testing.T) {
+	var tasks []string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer test-api-key", r.Header.Get("Authorization"))
+		var body embedRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "jina-embeddings-v3", body.Model)
+		assert.Equal(t, []string{"a", "b"}, body.Input)
+		assert.Equal(t, "float", body.EmbeddingType)
+		tasks = append(tasks, body.Task)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"jina-embeddings-v3","object":"list","usage":{"total_tokens":4,"prompt_tokens":4},
+			"data":[{"object":"embedding","index":0,"embedding":[0.1,0.2]},{"object":"embedding","index":1,"embedding":[0.3,0.4]}]}`)
+	})
+
+	model, err := provider.NewEmbeddingModel("jina-embeddings-v3")
+	require.NoError(t, err)
+
+	req := &llm.EmbeddingRequest{Contents: []string{"a", "b"}}
+	resp, err := model.GenerateEmbeddings(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, req.Model, "the request of the caller is not modified")
+	require.Len(t, resp.Embeddings, 2)
+	assert.Equal(t, []float64{0.3, 0.4}, resp.Embeddings[1].Embedding)
+	assert.Equal(t, int64(4), resp.Usage.TotalInputTokens)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 4*0.05/1e6, *resp.Cost, 1e-12)
+
+	_, err = model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"a", "b"},
+		Config:   &llm.EmbeddingModelConfig{TaskType: llm.EmbeddingTaskSearchQuery},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "retrieval.query"}, tasks)
+
+	_, err = model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"a"},
+		Config:   &llm.EmbeddingModelConfig{TaskType: "summarization"},
+	})
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestJinaRerankModel_Rerank(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/rerank", r.URL.Path)
+		var body rerankRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, rerankRequest{Model: "jina-reranker-m0", Query: "capital of France", Documents: []string{"Berlin", "Paris", "Rome"}, TopN: 2}, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"model":"jina-reranker-m0","usage":{"total_tokens":20},"results":[{"index":1,"relevance_score":0.9},{"index":0,"relevance_score":0.2}]}`)
+	})
+
+	model, err := provider.NewRerankModel("jina-reranker-m0")
+	require.NoError(t, err)
+
+	resp, err := model.Rerank(context.Background(), &llm.RerankRequest{
+		Query:     "capital of France",
+		Documents: []string{"Berlin", "Paris", "Rome"},
+		TopN:      2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []llm.RerankResult{
+		{Index: 1, Document: "Paris", RelevanceScore: 0.9},
+		{Index: 0, Document: "Berlin", RelevanceScore: 0.2},
+	}, resp.Results)
+	assert.Equal(t, int64(20), resp.Usage.TotalInputTokens)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 20*0.05/1e6, *resp.Cost, 1e-12)
+}
+
+func TestJinaRerankModel_RequestError(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"detail":"Invalid API key"}`)
+	})
+
+	model, err := provider.NewRerankModel("jina-reranker-v2-base-multilingual")
+	require.NoError(t, err)
+	_, err = model.Rerank(context.Background(), &llm.RerankRequest{Query: "q", Documents: []string{"d"}})
+	var reqErr *llm.RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusUnauthorized, reqErr.StatusCode)
+	assert.Equal(t, llm.ErrCodeAuth, llm.ClassifyError(err))
+}
@@ -0,0 +1,78 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package mistral
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/openai"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// MistralModelProvider provides Mistral and Mixtral chat models and mistral-embed through the
+// OpenAI compatible Mistral AI API
+type MistralModelProvider struct {
+	*openai.OpenAIModelProvider
+}
+
+var _ llm.ModelProvider = (*MistralModelProvider)(nil)
+
+//go:embed mistral.json
+var mistralModels []byte
+
+func NewMistralModelProvider(opts ...llm.ModelOption) (*MistralModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+
+	// Build request options list
+	requestOpts := []option.RequestOption{}
+	requestOpts = append(requestOpts, option.WithAPIKey(config.APIKey))
+
+	// Set base URL (use default if not provided)
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.mistral.ai/v1/"
+	}
+	requestOpts = append(requestOpts, option.WithBaseURL(baseURL))
+
+	// Append any custom options
+	requestOpts = append(requestOpts, config.Options...)
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(mistralModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	// Create the completion model with Mistral's API endpoint
+	provider, err := openai.NewBaseOpenAIModelProvider("mistral", models, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, provider)
+	provider.SetCompletionFields(config.CompletionFields)
+
+	return &MistralModelProvider{
+		OpenAIModelProvider: provider,
+	}, nil
+}
+
+// WithSafePrompt prepends Mistral's safety system prompt to every conversation
+func WithSafePrompt(enabled bool) llm.ModelOption {
+	return llm.WithCompletionField("safe_prompt", enabled)
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package mistral
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMistralModelProvider(t *testing.T) {
+	_, err := NewMistralModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	provider, err := NewMistralModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "mistral", provider.Name())
+	assert.NotEmpty(t, provider.SupportedModels())
+
+	_, err = provider.NewCompletionModel("mistral-large-latest")
+	assert.NoError(t, err)
+	_, err = provider.NewCompletionModel("open-mixtral-8x22b")
+	assert.NoError(t, err)
+	_, err = provider.NewEmbeddingModel("mistral-embed")
+	assert.NoError(t, err)
+	var capErr *llm.UnsupportedCapabilityError
+	_, err = provider.NewCompletionModel("mistral-embed")
+	assert.ErrorAs(t, err, &capErr)
+}
+
+func TestMistralCompletionModel_Complete(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer test-api-key", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"mistral-large-latest","choices":[{"index":0,"message":{"role":"assistant","content":"{\"city\":\"Paris\"}"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":1000000,"completion_tokens":1000000,"total_tokens":2000000}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewMistralModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL), WithSafePrompt(true))
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("mistral-large-latest",
+		llm.WithResponseFormat(llm.ResponseFormatJson), llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Capital of France as JSON"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"city":"Paris"}`, resp.Output)
+	assert.Equal(t, map[string]any{"type": "json_object"}, body["response_format"])
+	assert.Equal(t, true, body["safe_prompt"])
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 8.0, *resp.Cost, 1e-9)
+}
+
+func TestMistralCompletionModel_StreamComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, text := range []string{"Hello", " there"} {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"open-mixtral-8x7b\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", text)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewMistralModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("open-mixtral-8x7b")
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	var text strings.Builder
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case llm.StreamTextChunk:
+			text.WriteString(c.Text)
+		case llm.StreamErrorChunk:
+			t.Fatal(c.Err)
+		}
+	}
+	assert.Equal(t, "Hello there", text.String())
+}
@@ -0,0 +1,219 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package onnx
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sync"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+)
+
+//go:embed onnx.json
+var onnxModels []byte
+
+// ErrRuntimeUnavailable is returned when the package is built without the onnx build tag
+var ErrRuntimeUnavailable = errors.New("onnx: built without ONNX Runtime support, rebuild with -tags onnx")
+
+// ONNXModelProvider runs sentence embedding models locally with ONNX Runtime. Each model is
+// read from <modelDir>/<model id>/model.onnx with its vocabulary in vocab.txt next to it.
+type ONNXModelProvider struct {
+	*llm.DefaultModelProvider
+	modelDir    string
+	libraryPath string
+
+	mu       sync.Mutex
+	sessions []session
+}
+
+var _ llm.ModelProvider = (*ONNXModelProvider)(nil)
+
+// NewONNXModelProvider creates a local embedding provider. libraryPath is the path of the
+// ONNX Runtime shared library; when empty the platform default library name is used.
+func NewONNXModelProvider(modelDir, libraryPath string) (*ONNXModelProvider, error) {
+	if modelDir == "" {
+		return nil, llm.NewValidationError("modelDir", "cannot be empty", modelDir)
+	}
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(onnxModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	return &ONNXModelProvider{
+		DefaultModelProvider: llm.NewDefaultModelProvider("onnx", models),
+		modelDir:             modelDir,
+		libraryPath:          libraryPath,
+	}, nil
+}
+
+func (p *ONNXModelProvider) NewEmbeddingModel(model string) (llm.EmbeddingModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityEmbedding) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "embeddings")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(p.modelDir, info.ID)
+	tokenizer, err := loadVocab(filepath.Join(dir, "vocab.txt"))
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSession(p.libraryPath, filepath.Join(dir, "model.onnx"))
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.sessions = append(p.sessions, s)
+	p.mu.Unlock()
+
+	return p.EnforceEmbeddingPolicy(&ONNXEmbeddingModel{
+		name:      model,
+		modelInfo: info,
+		tokenizer: tokenizer,
+		session:   s,
+		inflight:  p.InFlight(),
+	}, info), nil
+}
+
+// Shutdown waits for in-flight requests and then releases the loaded models
+func (p *ONNXModelProvider) Shutdown(ctx context.Context) error {
+	err := p.DefaultModelProvider.Shutdown(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.sessions {
+		err = errors.Join(err, s.destroy())
+	}
+	p.sessions = nil
+	return err
+}
+
+// ONNXEmbeddingModel implements EmbeddingModel with a local sentence-transformers model.
+// Embeddings are mean pooled over the tokens and L2 normalized.
+type ONNXEmbeddingModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	tokenizer *wordPieceTokenizer
+	session   session
+	inflight  *llm.InFlight
+}
+
+var _ llm.EmbeddingModel = (*ONNXEmbeddingModel)(nil)
+
+func (p *ONNXEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	// Default to the model this instance was created for
+	req = common.DefaultEmbeddingModel(req, p.modelInfo.ID)
+
+	if err := common.ValidateEmbeddingRequest(req); err != nil {
+		return nil, err
+	}
+	if req.Model != p.name && req.Model != p.modelInfo.ID {
+		return nil, llm.NewValidationError("model", "must match the loaded local model", req.Model)
+	}
+	if req.Config != nil && req.Config.EncodingFormat == llm.EmbeddingEncodingFormatBase64 {
+		return nil, llm.NewValidationError("encoding_format", "base64 is not supported by local models", req.Config.EncodingFormat)
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// Tokenize and pad the batch to its longest input
+	encoded := make([][]int64, len(req.Contents))
+	var seqLen, inputTokens int64
+	for i, content := range req.Contents {
+		encoded[i] = p.tokenizer.encode(content, p.modelInfo.ContextWindow)
+		seqLen = max(seqLen, int64(len(encoded[i])))
+		inputTokens += int64(len(encoded[i]))
+	}
+	batch := int64(len(encoded))
+	inputIDs := make([]int64, batch*seqLen)
+	attentionMask := make([]int64, batch*seqLen)
+	for i, ids := range encoded {
+		offset := int64(i) * seqLen
+		copy(inputIDs[offset:], ids)
+		for j := range ids {
+			attentionMask[offset+int64(j)] = 1
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	hidden, dim, err := p.session.run(inputIDs, attentionMask, make([]int64, batch*seqLen), batch, seqLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	if req.Config != nil && req.Config.Dimensions > 0 && int64(req.Config.Dimensions) != dim {
+		return nil, llm.NewValidationError("dimensions", fmt.Sprintf("local model only produces %d dimensions", dim), req.Config.Dimensions)
+	}
+
+	embeddings := make([]llm.Embedding, batch)
+	for i := range embeddings {
+		embeddings[i] = llm.Embedding{
+			Index:     i,
+			Embedding: meanPool(hidden, attentionMask, int64(i), seqLen, dim),
+			Object:    "embedding",
+		}
+	}
+
+	usage := &llm.TokenUsage{
+		TotalInputTokens: inputTokens,
+		TotalRequests:    1,
+	}
+	cost, breakdown := common.CalculateCostBreakdown(p.modelInfo, usage)
+	return &llm.EmbeddingResponse{
+		Embeddings:    embeddings,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}, nil
+}
+
+// meanPool averages the hidden states of the unmasked tokens of one batch row and L2 normalizes the result
+func meanPool(hidden []float32, attentionMask []int64, row, seqLen, dim int64) []float64 {
+	embedding := make([]float64, dim)
+	var count float64
+	for t := int64(0); t < seqLen; t++ {
+		if attentionMask[row*seqLen+t] == 0 {
+			continue
+		}
+		count++
+		offset := (row*seqLen + t) * dim
+		for d := int64(0); d < dim; d++ {
+			embedding[d] += float64(hidden[offset+d])
+		}
+	}
+	if count == 0 {
+		return embedding
+	}
+
+	var norm float64
+	for d := range embedding {
+		embedding[d] /= count
+		norm += embedding[d] * embedding[d]
+	}
+	if norm = math.Sqrt(norm); norm > 0 {
+		for d := range embedding {
+			embedding[d] /= norm
+		}
+	}
+	return embedding
+}
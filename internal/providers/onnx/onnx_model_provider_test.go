@@ -0,0 +1,110 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package onnx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSession returns the token id as every hidden state value of a token
+type fakeSession struct {
+	dim     int64
+	batches [][]int64
+}
+
+func (s *fakeSession) run(inputIDs, attentionMask, tokenTypeIDs []int64, batch, seqLen int64) ([]float32, int64, error) {
+	s.batches = append(s.batches, inputIDs)
+	hidden := make([]float32, 0, batch*seqLen*s.dim)
+	for _, id := range inputIDs {
+		for d := int64(0); d < s.dim; d++ {
+			hidden = append(hidden, float32(id))
+		}
+	}
+	return hidden, s.dim, nil
+}
+
+func (s *fakeSession) destroy() error {
+	return nil
+}
+
+func TestNewONNXModelProvider(t *testing.T) {
+	_, err := NewONNXModelProvider("", "")
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	provider, err := NewONNXModelProvider(dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "onnx", provider.Name())
+
+	_, err = provider.NewEmbeddingModel("unknown-model")
+	assert.ErrorIs(t, err, llm.ErrInvalidModel)
+	_, err = provider.NewCompletionModel("all-MiniLM-L6-v2")
+	assert.Error(t, err)
+
+	// Missing vocabulary
+	_, err = provider.NewEmbeddingModel("all-MiniLM-L6-v2")
+	assert.Error(t, err)
+
+	modelDir := filepath.Join(dir, "all-MiniLM-L6-v2")
+	require.NoError(t, os.MkdirAll(modelDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(modelDir, "vocab.txt"), []byte(testVocab), 0o644))
+	// Vocabulary without model.onnx, or without the ONNX Runtime in default builds
+	_, err = provider.NewEmbeddingModel("all-MiniLM-L6-v2")
+	assert.Error(t, err)
+}
+
+func TestONNXEmbeddingModel_GenerateEmbeddings(t *testing.T) {
+	tokenizer, err := newWordPieceTokenizer(strings.NewReader(testVocab))
+	require.NoError(t, err)
+	session := &fakeSession{dim: 2}
+	model := &ONNXEmbeddingModel{
+		name:      "all-MiniLM-L6-v2",
+		modelInfo: &llm.ModelInfo{ID: "all-MiniLM-L6-v2", ContextWindow: 256},
+		tokenizer: tokenizer,
+		session:   session,
+		inflight:  llm.NewInFlight(),
+	}
+
+	resp, err := model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"hello", "hello world"},
+	})
+	require.NoError(t, err)
+
+	// Inputs are padded to the longest one
+	require.Len(t, session.batches, 1)
+	assert.Equal(t, []int64{2, 4, 3, 0, 2, 4, 6, 3}, session.batches[0])
+
+	require.Len(t, resp.Embeddings, 2)
+	assert.Equal(t, 1, resp.Embeddings[1].Index)
+	for _, embedding := range resp.Embeddings {
+		require.Len(t, embedding.Embedding, 2)
+		assert.InDelta(t, 1/1.4142135623730951, embedding.Embedding[0], 1e-9)
+	}
+	assert.Equal(t, int64(7), resp.Usage.TotalInputTokens)
+
+	_, err = model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"hello"},
+		Config:   &llm.EmbeddingModelConfig{Dimensions: 384},
+	})
+	assert.Error(t, err)
+}
+
+func TestMeanPool(t *testing.T) {
+	hidden := []float32{3, 4, 1, 1, 9, 9}
+	mask := []int64{1, 1, 0}
+
+	embedding := meanPool(hidden, mask, 0, 3, 2)
+
+	// Mean of (3,4) and (1,1) is (2,2.5), normalized by its length
+	assert.InDelta(t, 2/3.2015621187164243, embedding[0], 1e-9)
+	assert.InDelta(t, 2.5/3.2015621187164243, embedding[1], 1e-9)
+}
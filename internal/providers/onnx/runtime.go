@@ -0,0 +1,11 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package onnx
+
+// session runs a transformer encoder on a padded batch of token ids
+type session interface {
+	// run returns the flattened last hidden state of shape [batch, seqLen, dim] and dim
+	run(inputIDs, attentionMask, tokenTypeIDs []int64, batch, seqLen int64) ([]float32, int64, error)
+	destroy() error
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build onnx
+
+package onnx
+
+import (
+	"fmt"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+var (
+	initOnce sync.Once
+	initErr  error
+)
+
+// ortSession runs a model with ONNX Runtime, which is loaded from a shared library
+type ortSession struct {
+	session      *ort.DynamicAdvancedSession
+	tokenTypeIDs bool
+}
+
+func newSession(libraryPath, modelPath string) (session, error) {
+	initOnce.Do(func() {
+		if libraryPath != "" {
+			ort.SetSharedLibraryPath(libraryPath)
+		}
+		initErr = ort.InitializeEnvironment()
+	})
+	if initErr != nil {
+		return nil, fmt.Errorf("failed to initialize ONNX Runtime: %w", initErr)
+	}
+
+	inputs, outputs, err := ort.GetInputOutputInfo(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect model: %w", err)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("model %s has no outputs", modelPath)
+	}
+
+	s := &ortSession{}
+	inputNames := []string{"input_ids", "attention_mask"}
+	for _, input := range inputs {
+		if input.Name == "token_type_ids" {
+			s.tokenTypeIDs = true
+			inputNames = append(inputNames, input.Name)
+		}
+	}
+
+	s.session, err = ort.NewDynamicAdvancedSession(modelPath, inputNames, []string{outputs[0].Name}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return s, nil
+}
+
+func (s *ortSession) run(inputIDs, attentionMask, tokenTypeIDs []int64, batch, seqLen int64) ([]float32, int64, error) {
+	shape := ort.NewShape(batch, seqLen)
+	inputs := make([]ort.Value, 0, 3)
+	defer func() {
+		for _, input := range inputs {
+			input.Destroy()
+		}
+	}()
+
+	data := [][]int64{inputIDs, attentionMask}
+	if s.tokenTypeIDs {
+		data = append(data, tokenTypeIDs)
+	}
+	for _, d := range data {
+		tensor, err := ort.NewTensor(shape, d)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create input tensor: %w", err)
+		}
+		inputs = append(inputs, tensor)
+	}
+
+	outputs := []ort.Value{nil}
+	if err := s.session.Run(inputs, outputs); err != nil {
+		return nil, 0, fmt.Errorf("failed to run model: %w", err)
+	}
+	defer outputs[0].Destroy()
+
+	hidden, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, 0, fmt.Errorf("unexpected output type %T", outputs[0])
+	}
+	outShape := hidden.GetShape()
+	if len(outShape) != 3 {
+		return nil, 0, fmt.Errorf("unexpected output shape %v", outShape)
+	}
+	// Copy the data since it is owned by the output tensor
+	return append([]float32(nil), hidden.GetData()...), outShape[2], nil
+}
+
+func (s *ortSession) destroy() error {
+	return s.session.Destroy()
+}
@@ -0,0 +1,11 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !onnx
+
+package onnx
+
+// newSession is unavailable unless built with the onnx build tag
+func newSession(libraryPath, modelPath string) (session, error) {
+	return nil, ErrRuntimeUnavailable
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package onnx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	clsToken = "[CLS]"
+	sepToken = "[SEP]"
+	unkToken = "[UNK]"
+
+	// maxWordChars is the longest word split into word pieces; longer words become [UNK]
+	maxWordChars = 100
+)
+
+// wordPieceTokenizer is the uncased BERT WordPiece tokenizer used by sentence-transformers models
+type wordPieceTokenizer struct {
+	vocab map[string]int64
+	cls   int64
+	sep   int64
+	unk   int64
+}
+
+// loadVocab reads a vocab.txt file with one token per line
+func loadVocab(path string) (*wordPieceTokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocabulary: %w", err)
+	}
+	defer f.Close()
+	return newWordPieceTokenizer(f)
+}
+
+func newWordPieceTokenizer(r io.Reader) (*wordPieceTokenizer, error) {
+	vocab := make(map[string]int64)
+	scanner := bufio.NewScanner(r)
+	for id := int64(0); scanner.Scan(); id++ {
+		token := strings.TrimRight(scanner.Text(), "\r")
+		if _, exists := vocab[token]; !exists {
+			vocab[token] = id
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+
+	t := &wordPieceTokenizer{vocab: vocab}
+	for _, special := range []struct {
+		token string
+		id    *int64
+	}{{clsToken, &t.cls}, {sepToken, &t.sep}, {unkToken, &t.unk}} {
+		id, ok := vocab[special.token]
+		if !ok {
+			return nil, fmt.Errorf("vocabulary is missing %s", special.token)
+		}
+		*special.id = id
+	}
+	return t, nil
+}
+
+// encode returns the token ids of text wrapped in [CLS] and [SEP], truncated to maxLength
+func (t *wordPieceTokenizer) encode(text string, maxLength int) []int64 {
+	ids := []int64{t.cls}
+	for _, word := range basicTokenize(text) {
+		ids = append(ids, t.wordPieces(word)...)
+	}
+	if maxLength > 1 && len(ids) > maxLength-1 {
+		ids = ids[:maxLength-1]
+	}
+	return append(ids, t.sep)
+}
+
+// wordPieces greedily splits a word into the longest matching vocabulary pieces
+func (t *wordPieceTokenizer) wordPieces(word string) []int64 {
+	runes := []rune(word)
+	if len(runes) > maxWordChars {
+		return []int64{t.unk}
+	}
+
+	var ids []int64
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		id, found := int64(0), false
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = "##" + piece
+			}
+			if id, found = t.vocab[piece]; found {
+				break
+			}
+		}
+		if !found {
+			return []int64{t.unk}
+		}
+		ids = append(ids, id)
+		start = end
+	}
+	return ids
+}
+
+// basicTokenize lowercases text, strips accents and splits it on whitespace and punctuation.
+// CJK characters become words of their own.
+func basicTokenize(text string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar || unicode.Is(unicode.Mn, r):
+			// Drop invalid characters and the combining marks left by accent decomposition
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			flush()
+		case isPunctuation(r) || isCJK(r):
+			flush()
+			words = append(words, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// isPunctuation treats all non-alphanumeric ASCII as punctuation, like BERT does
+func isPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
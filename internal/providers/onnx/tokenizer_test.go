@@ -0,0 +1,48 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package onnx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVocab = "[PAD]\n[UNK]\n[CLS]\n[SEP]\nhello\n,\nworld\n!\nun\n##aff\n##able\ncafe\n中\n"
+
+func TestBasicTokenize(t *testing.T) {
+	assert.Equal(t, []string{"hello", ",", "world", "!"}, basicTokenize("Hello,  World!"))
+	assert.Equal(t, []string{"cafe", "au", "lait"}, basicTokenize("Café\tau\nLAIT"))
+	assert.Equal(t, []string{"中", "文"}, basicTokenize("中文"))
+}
+
+func TestWordPieceTokenizer_Encode(t *testing.T) {
+	tokenizer, err := newWordPieceTokenizer(strings.NewReader(testVocab))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      []int64
+	}{
+		{name: "words", text: "Hello, world!", want: []int64{2, 4, 5, 6, 7, 3}},
+		{name: "word pieces", text: "unaffable", want: []int64{2, 8, 9, 10, 3}},
+		{name: "unknown", text: "café xyz", want: []int64{2, 11, 1, 3}},
+		{name: "truncated", text: "hello world hello world", maxLength: 4, want: []int64{2, 4, 6, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tokenizer.encode(tt.text, tt.maxLength))
+		})
+	}
+}
+
+func TestNewWordPieceTokenizer_MissingSpecialTokens(t *testing.T) {
+	_, err := newWordPieceTokenizer(strings.NewReader("hello\nworld\n"))
+	assert.Error(t, err)
+}
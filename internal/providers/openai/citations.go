@@ -0,0 +1,178 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/easyagent-dev/llm"
+)
+
+// citationMarker matches numbered citation markers such as [1] written inline by Perplexity models
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// urlCitation is a url_citation annotation as sent by OpenAI-compatible APIs
+type urlCitation struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
+// citationAnnotation is a chat completion annotation, where the citation is nested under url_citation
+type citationAnnotation struct {
+	Type        string      `json:"type"`
+	URLCitation urlCitation `json:"url_citation"`
+}
+
+// citationTracker turns citations reported alongside streamed text into StreamCitationChunks
+// positioned by character offset in the text streamed so far. It understands url_citation
+// annotations (OpenAI search models, OpenRouter web search, Responses API) and numbered
+// citation lists with inline [n] markers (Perplexity).
+type citationTracker struct {
+	text    strings.Builder
+	scanned int
+	urls    []string
+	next    int
+	indexes map[string]int
+	emitted map[string]bool
+}
+
+func newCitationTracker() *citationTracker {
+	return &citationTracker{
+		indexes: make(map[string]int),
+		emitted: make(map[string]bool),
+	}
+}
+
+// offset returns the number of characters streamed so far
+func (t *citationTracker) offset() int {
+	return utf8.RuneCountInString(t.text.String())
+}
+
+// addText records streamed text and returns citations for any complete [n] markers it closes
+func (t *citationTracker) addText(text string) []llm.StreamCitationChunk {
+	t.text.WriteString(text)
+	if len(t.urls) == 0 {
+		return nil
+	}
+
+	full := t.text.String()
+	pending := full[t.scanned:]
+	var chunks []llm.StreamCitationChunk
+	for _, m := range citationMarker.FindAllStringSubmatchIndex(pending, -1) {
+		n, err := strconv.Atoi(pending[m[2]:m[3]])
+		if err != nil || n < 1 || n > len(t.urls) {
+			continue
+		}
+		end := t.scanned + m[1]
+		if chunk, ok := t.citation(n, t.urls[n-1], "", utf8.RuneCountInString(full[:end])); ok {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	// Keep a trailing unclosed marker for the next chunk
+	t.scanned = len(full)
+	if i := strings.LastIndexByte(pending, '['); i >= 0 && !strings.Contains(pending[i:], "]") {
+		t.scanned = len(full) - len(pending) + i
+	}
+	return chunks
+}
+
+// setURLs records a numbered citation list, such as the citations field sent by Perplexity
+func (t *citationTracker) setURLs(raw string) {
+	var urls []string
+	if err := json.Unmarshal([]byte(raw), &urls); err == nil && len(urls) > len(t.urls) {
+		t.urls = urls
+	}
+}
+
+// addAnnotations returns citations for the url_citation entries of a chat completion annotations field
+func (t *citationTracker) addAnnotations(raw string) []llm.StreamCitationChunk {
+	var annotations []citationAnnotation
+	if err := json.Unmarshal([]byte(raw), &annotations); err != nil {
+		return nil
+	}
+	var chunks []llm.StreamCitationChunk
+	for _, annotation := range annotations {
+		if annotation.Type != "url_citation" {
+			continue
+		}
+		if chunk, ok := t.urlCitation(annotation.URLCitation); ok {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// addAnnotation returns the citation for a single Responses API annotation
+func (t *citationTracker) addAnnotation(raw string) (llm.StreamCitationChunk, bool) {
+	var annotation struct {
+		Type string `json:"type"`
+		urlCitation
+	}
+	if err := json.Unmarshal([]byte(raw), &annotation); err != nil || annotation.Type != "url_citation" {
+		return llm.StreamCitationChunk{}, false
+	}
+	return t.urlCitation(annotation.urlCitation)
+}
+
+// remaining returns citations from the numbered list that were never referenced inline,
+// positioned at the end of the text
+func (t *citationTracker) remaining() []llm.StreamCitationChunk {
+	var chunks []llm.StreamCitationChunk
+	offset := t.offset()
+	for i, url := range t.urls {
+		if _, ok := t.indexes[url]; ok {
+			continue
+		}
+		if chunk, ok := t.citation(i+1, url, "", offset); ok {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+func (t *citationTracker) urlCitation(c urlCitation) (llm.StreamCitationChunk, bool) {
+	if c.URL == "" {
+		return llm.StreamCitationChunk{}, false
+	}
+	offset := c.EndIndex
+	if offset <= 0 {
+		offset = t.offset()
+	}
+	return t.citation(0, c.URL, c.Title, offset)
+}
+
+// citation numbers and deduplicates a citation. An index of 0 reuses the number already
+// assigned to the URL or assigns the next free one.
+func (t *citationTracker) citation(index int, url, title string, offset int) (llm.StreamCitationChunk, bool) {
+	key := strconv.Itoa(offset) + " " + url
+	if t.emitted[key] {
+		return llm.StreamCitationChunk{}, false
+	}
+	t.emitted[key] = true
+
+	if index == 0 {
+		index = t.indexes[url]
+	}
+	if index == 0 {
+		t.next++
+		index = t.next
+	} else if index > t.next {
+		t.next = index
+	}
+	t.indexes[url] = index
+
+	return llm.StreamCitationChunk{
+		Index:  index,
+		URL:    url,
+		Title:  title,
+		Offset: offset,
+	}, true
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCitationTracker_Markers tests that inline [n] markers split across chunks are positioned by character offset
+func TestCitationTracker_Markers(t *testing.T) {
+	tracker := newCitationTracker()
+	tracker.setURLs(`["https://a.example","https://b.example","https://c.example"]`)
+
+	assert.Empty(t, tracker.addText("Café is French[")) // marker not closed yet
+	chunks := tracker.addText("1]. Tea is too[2][1].")
+	require.Len(t, chunks, 3)
+	assert.Equal(t, llm.StreamCitationChunk{Index: 1, URL: "https://a.example", Offset: 17}, chunks[0])
+	assert.Equal(t, 2, chunks[1].Index)
+	assert.Equal(t, 32, chunks[1].Offset)
+	assert.Equal(t, 1, chunks[2].Index)
+	assert.Equal(t, 35, chunks[2].Offset)
+
+	remaining := tracker.remaining()
+	require.Len(t, remaining, 1)
+	assert.Equal(t, llm.StreamCitationChunk{Index: 3, URL: "https://c.example", Offset: 36}, remaining[0])
+}
+
+// TestCitationTracker_Annotations tests numbering and deduplication of url_citation annotations
+func TestCitationTracker_Annotations(t *testing.T) {
+	tracker := newCitationTracker()
+	tracker.addText("Paris is the capital of France.")
+
+	chunks := tracker.addAnnotations(`[
+		{"type":"url_citation","url_citation":{"url":"https://a.example","title":"A","start_index":0,"end_index":30}},
+		{"type":"url_citation","url_citation":{"url":"https://a.example","title":"A","start_index":0,"end_index":30}},
+		{"type":"url_citation","url_citation":{"url":"https://b.example","title":"B"}}
+	]`)
+	require.Len(t, chunks, 2)
+	assert.Equal(t, llm.StreamCitationChunk{Index: 1, URL: "https://a.example", Title: "A", Offset: 30}, chunks[0])
+	assert.Equal(t, llm.StreamCitationChunk{Index: 2, URL: "https://b.example", Title: "B", Offset: 31}, chunks[1])
+
+	chunk, ok := tracker.addAnnotation(`{"type":"url_citation","url":"https://a.example","title":"A","end_index":12}`)
+	require.True(t, ok)
+	assert.Equal(t, 1, chunk.Index, "repeated URLs keep their footnote number")
+	assert.Equal(t, 12, chunk.Offset)
+}
+
+// TestOpenAICompletionModel_StreamCitations tests that citations are interleaved with streamed text
+func TestOpenAICompletionModel_StreamCitations(t *testing.T) {
+	events := []string{
+		`{"id":"1","object":"chat.completion.chunk","model":"sonar","citations":["https://a.example"],"choices":[{"index":0,"delta":{"content":"Paris[1]"}}]}`,
+		`{"id":"1","object":"chat.completion.chunk","model":"sonar","citations":["https://a.example"],"choices":[{"index":0,"delta":{"content":" is nice."}}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("perplexity", []*llm.ModelInfo{{ID: "sonar", Name: "Sonar"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("sonar")
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Capital of France?"}},
+	})
+	require.NoError(t, err)
+
+	var types []llm.StreamChunkType
+	var citation llm.StreamCitationChunk
+	for chunk := range stream {
+		types = append(types, chunk.Type())
+		if c, ok := chunk.(llm.StreamCitationChunk); ok {
+			citation = c
+		}
+	}
+
+	assert.Equal(t, []llm.StreamChunkType{llm.TextChunkType, llm.CitationChunkType, llm.TextChunkType, llm.FinishChunkType}, types)
+	assert.Equal(t, llm.StreamCitationChunk{Index: 1, URL: "https://a.example", Offset: 8}, citation)
+}
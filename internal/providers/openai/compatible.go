@@ -0,0 +1,65 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// NewOpenAICompatibleModelProvider creates a provider for an OpenAI compatible gateway at the
+// base URL, such as LiteLLM, Portkey or an API gateway in front of OpenAI. The gateway serves the
+// OpenAI models unless others are given with WithModels, and receives the API key where its
+// AuthStyle says.
+func NewOpenAICompatibleModelProvider(opts ...llm.ModelOption) (*OpenAIModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+	if config.BaseURL == "" {
+		return nil, llm.ErrBaseURLEmpty
+	}
+
+	models := config.Models
+	if models == nil {
+		var err error
+		if models, err = getOpenAIModels(); err != nil {
+			return nil, err
+		}
+	}
+
+	requestOpts := authRequestOptions(config.APIKey, config.AuthStyle)
+	requestOpts = append(requestOpts, option.WithBaseURL(config.BaseURL))
+	requestOpts = append(requestOpts, config.Options...)
+
+	provider, err := NewBaseOpenAIModelProvider("openai_compatible", models, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, provider)
+	provider.SetCompletionFields(config.CompletionFields)
+	return provider, nil
+}
+
+// authRequestOptions sends apiKey as style says. The bearer token the SDK reads from
+// OPENAI_API_KEY is removed for other styles, so the key is not leaked to the gateway.
+func authRequestOptions(apiKey string, style *llm.AuthStyle) []option.RequestOption {
+	if style == nil || *style == llm.AuthStyleBearer {
+		return []option.RequestOption{option.WithAPIKey(apiKey)}
+	}
+	requestOpts := []option.RequestOption{option.WithHeaderDel("authorization")}
+	if style.Query {
+		return append(requestOpts, option.WithQuery(style.Name, style.Prefix+apiKey))
+	}
+	return append(requestOpts, option.WithHeader(style.Name, style.Prefix+apiKey))
+}
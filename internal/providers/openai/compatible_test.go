@@ -0,0 +1,85 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOpenAICompatibleModelProvider(t *testing.T) {
+	_, err := NewOpenAICompatibleModelProvider(llm.WithBaseURL("https://gateway.example.com/v1"))
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+	_, err = NewOpenAICompatibleModelProvider(llm.WithAPIKey("test-api-key"))
+	assert.ErrorIs(t, err, llm.ErrBaseURLEmpty)
+
+	provider, err := NewOpenAICompatibleModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL("https://gateway.example.com/v1"))
+	require.NoError(t, err)
+	assert.Equal(t, "openai_compatible", provider.Name())
+	assert.NotNil(t, provider.GetModelInfo("gpt-4o-mini"))
+
+	provider, err = NewOpenAICompatibleModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL("https://gateway.example.com/v1"),
+		llm.WithModels(&llm.ModelInfo{ID: "llama-3-70b", Capabilities: []llm.ModelCapability{llm.ModelCapabilityCompletion}}))
+	require.NoError(t, err)
+	assert.Nil(t, provider.GetModelInfo("gpt-4o-mini"))
+	_, err = provider.NewCompletionModel("llama-3-70b")
+	assert.NoError(t, err)
+}
+
+func TestOpenAICompatibleModelProvider_AuthStyles(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "env-api-key")
+
+	tests := []struct {
+		name   string
+		style  *llm.AuthStyle
+		header string
+		value  string
+		query  string
+	}{
+		{name: "default", header: "Authorization", value: "Bearer test-api-key"},
+		{name: "bearer", style: &llm.AuthStyleBearer, header: "Authorization", value: "Bearer test-api-key"},
+		{name: "api-key header", style: &llm.AuthStyleAPIKeyHeader, header: "api-key", value: "test-api-key"},
+		{name: "custom header", style: &llm.AuthStyle{Name: "x-api-key"}, header: "x-api-key", value: "test-api-key"},
+		{name: "query param", style: &llm.AuthStyleQueryParam, query: "api_key", value: "test-api-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.query != "" {
+					assert.Equal(t, tt.value, r.URL.Query().Get(tt.query))
+				} else {
+					assert.Equal(t, tt.value, r.Header.Get(tt.header))
+				}
+				if tt.header != "Authorization" {
+					assert.Empty(t, r.Header.Get("Authorization"))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`)
+			}))
+			defer server.Close()
+
+			opts := []llm.ModelOption{llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL)}
+			if tt.style != nil {
+				opts = append(opts, llm.WithAuthStyle(*tt.style))
+			}
+			provider, err := NewOpenAICompatibleModelProvider(opts...)
+			require.NoError(t, err)
+			model, err := provider.NewCompletionModel("gpt-4o-mini")
+			require.NoError(t, err)
+
+			resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+				Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "Hello", resp.Output)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+	"github.com/openai/openai-go/v3"
+)
+
+var _ llm.ImageEditModel = (*OpenAIImageModel)(nil)
+
+// EditImage edits an image with gpt-image-1 or dall-e-2, where the mask is transparent if one
+// is given
+func (p *OpenAIImageModel) EditImage(ctx context.Context, req *llm.ImageEditRequest) (*llm.ImageResponse, error) {
+	if req.Instructions == "" {
+		return nil, llm.ErrEmptyInstructions
+	}
+	if err := common.ValidateImageInput("image", req.Image); err != nil {
+		return nil, err
+	}
+	format, err := llm.ImageResponseFormat(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	n, err := llm.ImageCount(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	responseFormat, err := p.responseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	image, err := imageFile(req.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	params := openai.ImageEditParams{
+		Image:          openai.ImageEditParamsImageUnion{OfFile: image},
+		Prompt:         req.Instructions,
+		Model:          p.modelID(),
+		N:              openai.Int(int64(n)),
+		ResponseFormat: openai.ImageEditParamsResponseFormat(responseFormat),
+	}
+	if req.Mask != nil {
+		if err := common.ValidateImageInput("mask", req.Mask); err != nil {
+			return nil, err
+		}
+		if params.Mask, err = imageFile(req.Mask); err != nil {
+			return nil, err
+		}
+	}
+	if req.Config != nil {
+		params.Size = openai.ImageEditParamsSize(req.Config.Size)
+		params.Quality = openai.ImageEditParamsQuality(req.Config.Quality)
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	resp, err := p.client.Images.Edit(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit image: %w", err)
+	}
+	return p.imageResponse(resp, format)
+}
+
+// CreateVariation creates variations of an image with dall-e-2
+func (p *OpenAIImageModel) CreateVariation(ctx context.Context, req *llm.ImageVariationRequest) (*llm.ImageResponse, error) {
+	if model := p.modelID(); model != "dall-e-2" {
+		return nil, llm.NewUnsupportedCapabilityError("openai", "image variations with "+model)
+	}
+	if err := common.ValidateImageInput("image", req.Image); err != nil {
+		return nil, err
+	}
+	format, err := llm.ImageResponseFormat(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	n, err := llm.ImageCount(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	responseFormat, err := p.responseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	image, err := imageFile(req.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	params := openai.ImageNewVariationParams{
+		Image:          image,
+		Model:          p.modelID(),
+		N:              openai.Int(int64(n)),
+		ResponseFormat: openai.ImageNewVariationParamsResponseFormat(responseFormat),
+	}
+	if req.Config != nil {
+		params.Size = openai.ImageNewVariationParamsSize(req.Config.Size)
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	resp, err := p.client.Images.NewVariation(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image variation: %w", err)
+	}
+	return p.imageResponse(resp, format)
+}
+
+// imageFile returns the content of an image artifact as a file upload. The images API only
+// accepts uploads, so artifacts referring to a remote URL are rejected.
+func imageFile(artifact *llm.ModelArtifact) (io.Reader, error) {
+	if len(artifact.Content) == 0 {
+		return nil, llm.NewUnsupportedCapabilityError("openai", "remote image URLs for edits")
+	}
+	contentType := artifact.ContentType
+	if contentType == "" {
+		contentType = "image/png"
+	}
+	// The API detects the image type from the file extension
+	name := artifact.Name
+	if name == "" {
+		name = "image"
+	}
+	if !strings.Contains(name, ".") {
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			name += exts[len(exts)-1]
+		}
+	}
+	return openai.File(bytes.NewReader(artifact.Content), name, contentType), nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAIImageModel_EditAndVariation tests that edits and variations upload the images and
+// return every generated image
+func TestOpenAIImageModel_EditAndVariation(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	type upload struct {
+		path   string
+		fields map[string]string
+		files  map[string]string
+	}
+	var uploads []upload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		u := upload{path: r.URL.Path, fields: map[string]string{}, files: map[string]string{}}
+		for name, values := range r.MultipartForm.Value {
+			u.fields[name] = values[0]
+		}
+		for name, files := range r.MultipartForm.File {
+			f, err := files[0].Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, png, data)
+			u.files[name] = files[0].Filename
+		}
+		uploads = append(uploads, u)
+		b64 := base64.StdEncoding.EncodeToString(png)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"created":1,"data":[{"b64_json":"%s","revised_prompt":"A cat wearing a hat"},{"b64_json":"%s"}]}`, b64, b64)
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{
+		{ID: "gpt-image-1", Name: "GPT Image 1", Capabilities: []llm.ModelCapability{llm.ModelCapabilityImage, llm.ModelCapabilityImageEdit}, Pricing: llm.ModelPricing{Image: llm.NewPrice(0.1)}},
+		{ID: "dall-e-2", Name: "DALL·E 2", Capabilities: []llm.ModelCapability{llm.ModelCapabilityImage, llm.ModelCapabilityImageEdit}},
+		{ID: "dall-e-3", Name: "DALL·E 3", Capabilities: []llm.ModelCapability{llm.ModelCapabilityImage}},
+	}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	image := &llm.ModelArtifact{Name: "photo", ContentType: "image/png", Content: png}
+
+	model, err := provider.NewImageEditModel("gpt-image-1")
+	require.NoError(t, err)
+	resp, err := model.EditImage(context.Background(), &llm.ImageEditRequest{
+		Instructions: "Add a hat",
+		Image:        image,
+		Mask:         &llm.ModelArtifact{ContentType: "image/png", Content: png},
+		Config:       &llm.ImageModelConfig{Count: 2, Size: "1024x1536"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, png, resp.Output)
+	require.Len(t, resp.Images, 2)
+	assert.Equal(t, png, resp.Images[1].Data)
+	assert.Equal(t, "A cat wearing a hat", resp.Images[0].RevisedPrompt)
+	assert.Equal(t, "image/png", resp.Images[0].MimeType)
+	assert.Equal(t, 2, resp.Usage.TotalImages)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 0.2, *resp.Cost, 1e-9)
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "/images/edits", uploads[0].path)
+	assert.Equal(t, map[string]string{"prompt": "Add a hat", "model": "gpt-image-1", "n": "2", "size": "1024x1536"}, uploads[0].fields)
+	assert.Equal(t, map[string]string{"image": "photo.png", "mask": "image.png"}, uploads[0].files)
+
+	_, err = model.CreateVariation(context.Background(), &llm.ImageVariationRequest{Image: image})
+	var capErr *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &capErr)
+
+	_, err = model.EditImage(context.Background(), &llm.ImageEditRequest{
+		Instructions: "Add a hat",
+		Image:        &llm.ModelArtifact{ContentType: "image/png", URL: "https://example.com/photo.png"},
+	})
+	assert.ErrorAs(t, err, &capErr)
+	assert.Len(t, uploads, 1)
+
+	model, err = provider.NewImageEditModel("dall-e-2")
+	require.NoError(t, err)
+	resp, err = model.CreateVariation(context.Background(), &llm.ImageVariationRequest{Image: image})
+	require.NoError(t, err)
+	assert.Equal(t, png, resp.Output)
+	require.Len(t, uploads, 2)
+	assert.Equal(t, "/images/variations", uploads[1].path)
+	assert.Equal(t, map[string]string{"model": "dall-e-2", "n": "1", "response_format": "b64_json"}, uploads[1].fields)
+
+	_, err = provider.NewImageEditModel("dall-e-3")
+	assert.ErrorAs(t, err, &capErr)
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+)
+
+var _ llm.ModelSource = (*OpenAIModelProvider)(nil)
+
+// ListModels lists the models of the models endpoint, the source of the catalog refreshed
+// with llm.WithModelRefresh. The endpoint only reports model IDs, so the capabilities of
+// models missing from the embedded list are inferred from their IDs, and they are not priced.
+func (p *OpenAIModelProvider) ListModels(ctx context.Context) ([]*llm.ModelInfo, error) {
+	var models []*llm.ModelInfo
+	pager := p.client.Models.ListAutoPaging(ctx)
+	for pager.Next() {
+		model := pager.Current()
+		capabilities, input, output := listedModelCapabilities(model.ID)
+		if len(capabilities) == 0 {
+			continue
+		}
+		info := &llm.ModelInfo{
+			ID:           model.ID,
+			Name:         model.ID,
+			Capabilities: capabilities,
+			Embedding:    capabilities[0] == llm.ModelCapabilityEmbedding,
+			Input:        input,
+			Output:       output,
+		}
+		if model.Created > 0 {
+			info.UpdatedAt = time.Unix(model.Created, 0).UTC()
+		}
+		models = append(models, info)
+	}
+	if err := pager.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+	return models, nil
+}
+
+// listedModelCapabilities infers the capabilities and media types of a listed model from its
+// ID. Models used through APIs this module does not support, such as transcription and
+// moderation, have none.
+func listedModelCapabilities(id string) ([]llm.ModelCapability, []llm.ModelMediaType, []llm.ModelMediaType) {
+	text := []llm.ModelMediaType{llm.ModelMediaTypeText}
+	switch id := strings.ToLower(id); {
+	case strings.Contains(id, "whisper"), strings.Contains(id, "transcribe"), strings.Contains(id, "moderation"),
+		strings.Contains(id, "realtime"), strings.Contains(id, "search-api"):
+		return nil, nil, nil
+	case strings.Contains(id, "embed"):
+		return []llm.ModelCapability{llm.ModelCapabilityEmbedding}, text, nil
+	case strings.Contains(id, "tts"):
+		return []llm.ModelCapability{llm.ModelCapabilitySpeech}, text, []llm.ModelMediaType{llm.ModelMediaTypeAudio}
+	case strings.Contains(id, "dall-e"), strings.Contains(id, "gpt-image"):
+		return []llm.ModelCapability{llm.ModelCapabilityImage}, text, []llm.ModelMediaType{llm.ModelMediaTypeImage}
+	case strings.Contains(id, "sora"):
+		return []llm.ModelCapability{llm.ModelCapabilityVideo}, text, []llm.ModelMediaType{llm.ModelMediaTypeVideo}
+	}
+	return []llm.ModelCapability{llm.ModelCapabilityCompletion}, text, text
+}
@@ -10,13 +10,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/easyagent-dev/llm"
 	"github.com/easyagent-dev/llm/internal/common"
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/packages/respjson"
 	"github.com/openai/openai-go/v3/responses"
 	"github.com/openai/openai-go/v3/shared"
 )
@@ -41,12 +47,33 @@ func getOpenAIModels() ([]*llm.ModelInfo, error) {
 	return openaiModelsList, openaiModelsErr
 }
 
+// seedProviders are the OpenAI compatible providers that sample with the seed parameter;
+// the others accept but ignore it
+var seedProviders = map[string]bool{
+	"openai":       true,
+	"azure_openai": true,
+	"gemini":       true,
+	"openrouter":   true,
+	"groq":         true,
+	"vertex":       true,
+	"together":     true,
+}
+
 // OpenAIModelProvider provides base functionality for OpenAI models
 type OpenAIModelProvider struct {
 	*llm.DefaultModelProvider
 	client openai.Client
+	// completionFields are extra body fields of every chat completion request
+	completionFields map[string]any
+	// metadataHook adds provider specific metadata to completion responses
+	metadataHook MetadataHook
 }
 
+// MetadataHook adds provider specific details of a chat completion, read from its JSON body or
+// response headers, to the response metadata. Streams call it with the body of every chunk,
+// and send the metadata with their usage chunk.
+type MetadataHook func(metadata *llm.ResponseMetadata, body string, header http.Header)
+
 var _ llm.ModelProvider = (*OpenAIModelProvider)(nil)
 
 func NewOpenAIModelProvider(opts ...llm.ModelOption) (*OpenAIModelProvider, error) {
@@ -57,12 +84,29 @@ func NewOpenAIModelProvider(opts ...llm.ModelOption) (*OpenAIModelProvider, erro
 	config := llm.ApplyOptions(opts)
 	requestOpts := []option.RequestOption{}
 	requestOpts = append(requestOpts, option.WithAPIKey(config.APIKey))
+	if config.Retry != nil {
+		requestOpts = append(requestOpts, option.WithMaxRetries(0))
+	}
 
-	return NewBaseOpenAIModelProvider("openai", models, requestOpts)
+	provider, err := NewBaseOpenAIModelProvider("openai", models, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, provider)
+	return provider, nil
 }
 
 func NewBaseOpenAIModelProvider(name string, models []*llm.ModelInfo, reqOpts []option.RequestOption) (*OpenAIModelProvider, error) {
-	client := openai.NewClient(reqOpts...)
+	client := openai.NewClient(append(slices.Clip(reqOpts), option.WithMiddleware(tolerateEventStreams))...)
 
 	provider := llm.NewDefaultModelProvider(name, models)
 
@@ -72,12 +116,48 @@ func NewBaseOpenAIModelProvider(name string, models []*llm.ModelInfo, reqOpts []
 	}, nil
 }
 
+// tolerateEventStreams drops the keep-alives and empty events that OpenAI compatible gateways
+// send in event streams, which the SDK would fail to parse
+func tolerateEventStreams(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	resp, err := next(req)
+	if err == nil && common.IsEventStream(resp) {
+		resp.Body = common.NewEventStreamReader(resp.Body)
+	}
+	return resp, err
+}
+
+// SetCompletionFields sets extra body fields sent with every chat completion request, for
+// parameters specific to an OpenAI compatible provider
+func (p *OpenAIModelProvider) SetCompletionFields(fields map[string]any) {
+	p.completionFields = fields
+}
+
+// SetMetadataHook sets the hook that adds provider specific metadata to completion responses
+func (p *OpenAIModelProvider) SetMetadataHook(hook MetadataHook) {
+	p.metadataHook = hook
+}
+
 func (p *OpenAIModelProvider) NewCompletionModel(model string, opts ...llm.CompletionOption) (llm.CompletionModel, error) {
 	info := p.GetModelInfo(model)
 	if info == nil {
 		return nil, errors.New("model not found")
 	}
-	return NewOpenAICompletionModel(model, info, p.client, opts...)
+	if !info.HasCapability(llm.ModelCapabilityCompletion) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "completions")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	completionModel, err := NewOpenAICompletionModel(model, info, p.client, opts...)
+	if err != nil {
+		return nil, err
+	}
+	completionModel.inflight = p.InFlight()
+	completionModel.seedSupported = seedProviders[p.Name()]
+	completionModel.provider = p.Name()
+	completionModel.extraFields = p.completionFields
+	completionModel.metadataHook = p.metadataHook
+	return p.DecorateCompletionModel(completionModel, info, opts), nil
 }
 
 func (p *OpenAIModelProvider) NewEmbeddingModel(model string) (llm.EmbeddingModel, error) {
@@ -85,7 +165,19 @@ func (p *OpenAIModelProvider) NewEmbeddingModel(model string) (llm.EmbeddingMode
 	if info == nil {
 		return nil, errors.New("model not found")
 	}
-	return NewOpenAIEmbeddingModel(model, info, p.client)
+	if !info.HasCapability(llm.ModelCapabilityEmbedding) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "embeddings")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	embeddingModel, err := NewOpenAIEmbeddingModel(model, info, p.client)
+	if err != nil {
+		return nil, err
+	}
+	embeddingModel.lookup = p.GetModelInfo
+	embeddingModel.inflight = p.InFlight()
+	return p.EnforceEmbeddingPolicy(p.WrapEmbeddingModel(embeddingModel), info), nil
 }
 
 func (p *OpenAIModelProvider) NewImageModel(model string) (llm.ImageModel, error) {
@@ -93,7 +185,71 @@ func (p *OpenAIModelProvider) NewImageModel(model string) (llm.ImageModel, error
 	if info == nil {
 		return nil, errors.New("model not found")
 	}
-	return NewOpenAIImageModel(model, info, p.client)
+	if !info.HasCapability(llm.ModelCapabilityImage) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "image generation")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	imageModel, err := NewOpenAIImageModel(model, info, p.client)
+	if err != nil {
+		return nil, err
+	}
+	imageModel.inflight = p.InFlight()
+	return p.EnforceImagePolicy(p.WrapImageModel(imageModel), info), nil
+}
+
+// NewImageEditModel creates an image model that also edits images, for gpt-image-1 and
+// dall-e-2, and creates variations of them, for dall-e-2
+func (p *OpenAIModelProvider) NewImageEditModel(model string) (llm.ImageEditModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, errors.New("model not found")
+	}
+	if !info.HasCapability(llm.ModelCapabilityImageEdit) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "image editing")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	imageModel, err := NewOpenAIImageModel(model, info, p.client)
+	if err != nil {
+		return nil, err
+	}
+	imageModel.inflight = p.InFlight()
+	return p.EnforceImageEditPolicy(p.WrapImageEditModel(imageModel), info), nil
+}
+
+func (p *OpenAIModelProvider) NewSpeechModel(model string) (llm.SpeechModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, errors.New("model not found")
+	}
+	if !info.HasCapability(llm.ModelCapabilitySpeech) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "speech generation")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	speechModel := NewOpenAISpeechModel(model, info, p.client)
+	speechModel.inflight = p.InFlight()
+	return p.EnforceSpeechPolicy(p.WrapSpeechModel(speechModel), info), nil
+}
+
+func (p *OpenAIModelProvider) NewVideoModel(model string) (llm.VideoModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, errors.New("model not found")
+	}
+	if !info.HasCapability(llm.ModelCapabilityVideo) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "video generation")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	videoModel := NewOpenAIVideoModel(model, info, p.client)
+	videoModel.inflight = p.InFlight()
+	return p.EnforceVideoPolicy(p.WrapVideoModel(videoModel), info), nil
 }
 
 func (p *OpenAIModelProvider) NewConversationModel(model string, opts ...llm.ResponseOption) (llm.ConversationModel, error) {
@@ -101,15 +257,35 @@ func (p *OpenAIModelProvider) NewConversationModel(model string, opts ...llm.Res
 	if info == nil {
 		return nil, errors.New("model not found")
 	}
-	return NewOpenAIConversationModel(model, info, p.client, opts...)
+	if !info.HasCapability(llm.ModelCapabilityConversation) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "conversations")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	conversationModel, err := NewOpenAIConversationModel(model, info, p.client, opts...)
+	if err != nil {
+		return nil, err
+	}
+	conversationModel.inflight = p.InFlight()
+	conversationModel.provider = p.Name()
+	return p.EnforceConversationPolicy(conversationModel, info), nil
 }
 
 // OpenAICompletionModel implements CompletionModel interface
 type OpenAICompletionModel struct {
-	name      string
-	modelInfo *llm.ModelInfo
-	client    openai.Client
-	options   []llm.CompletionOption
+	name          string
+	modelInfo     *llm.ModelInfo
+	client        openai.Client
+	options       []llm.CompletionOption
+	inflight      *llm.InFlight
+	seedSupported bool
+	// provider is the name reported in stream errors
+	provider string
+	// extraFields are provider specific body fields added to every request
+	extraFields map[string]any
+	// metadataHook adds provider specific metadata to responses
+	metadataHook MetadataHook
 }
 
 func NewOpenAICompletionModel(name string, modelInfo *llm.ModelInfo, client openai.Client, opts ...llm.CompletionOption) (*OpenAICompletionModel, error) {
@@ -118,38 +294,115 @@ func NewOpenAICompletionModel(name string, modelInfo *llm.ModelInfo, client open
 		modelInfo: modelInfo,
 		client:    client,
 		options:   opts,
+		provider:  "openai",
 	}, nil
 }
 
+// setProviderFields adds the provider specific body fields to params. The service tier is
+// only sent to OpenAI, as other compatible backends may reject unknown fields.
+func (p *OpenAICompletionModel) setProviderFields(params *openai.ChatCompletionNewParams) {
+	if p.provider != "openai" {
+		params.ServiceTier = ""
+	}
+	if len(p.extraFields) > 0 {
+		params.SetExtraFields(p.extraFields)
+	}
+}
+
+// CountTokens estimates the size and input cost of req with the tiktoken encoding of the model
+func (p *OpenAICompletionModel) CountTokens(req *llm.CompletionRequest) (*llm.TokenCount, error) {
+	return common.CountTokens(p.modelInfo, p.tokenizer(), req), nil
+}
+
+func (p *OpenAICompletionModel) tokenizer() llm.Tokenizer {
+	return llm.GetTokenizer(llm.OpenAIEncoding(p.name))
+}
+
 func (p *OpenAICompletionModel) StreamComplete(ctx context.Context, req *llm.CompletionRequest) (llm.StreamCompletionResponse, error) {
 	// Parse options
-	opts := llm.ApplyCompletionOptions(p.options)
+	opts, _ := llm.NormalizeDeterminism(llm.ApplyContextCompletionOptions(ctx, p.options), p.seedSupported, !p.modelInfo.Reasoning)
+
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	req, err := common.NormalizeRequestArtifacts(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.ValidateMessageInputs(p.modelInfo, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := common.CheckExtendedContext(p.provider, p.modelInfo, opts); err != nil {
+		return nil, err
+	}
+	if err := common.CheckContextWindow(p.modelInfo, p.tokenizer(), req, opts); err != nil {
+		return nil, err
+	}
+	// Chunks do not tell choices apart
+	if opts.N != nil && *opts.N > 1 {
+		return nil, llm.NewUnsupportedCapabilityError(p.provider, "streams with multiple choices")
+	}
 
 	params, err := ToChatCompletionParams(p.name, req.Instructions, req.Messages, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat llm params: %w", err)
 	}
+	p.setProviderFields(&params)
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response headers are only kept for the metadata hook
+	var httpResp *http.Response
+	var callOpts []option.RequestOption
+	if p.metadataHook != nil {
+		callOpts = append(callOpts, option.WithResponseInto(&httpResp))
+	}
 
-	stream := p.client.Chat.Completions.NewStreaming(ctx, params)
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params, callOpts...)
+	// The request is sent when the stream is created, so failures to open it can be returned
+	if err := stream.Err(); err != nil {
+		done()
+		return nil, fmt.Errorf("failed to stream chat: %w", err)
+	}
+	var metadata *llm.ResponseMetadata
+	var header http.Header
+	if p.metadataHook != nil {
+		metadata = &llm.ResponseMetadata{}
+		if httpResp != nil {
+			header = httpResp.Header
+		}
+	}
 	chunkChan := make(chan llm.StreamChunk, 1) // Increased buffer to reduce blocking
+	sendUsage := opts.WithUsage != nil && *opts.WithUsage && opts.StreamsEvent(llm.UsageChunkType)
+	sendCitations := opts.StreamsEvent(llm.CitationChunkType)
 
 	go func() {
+		defer done()
 		defer close(chunkChan)
+		defer llm.SendStreamCanceled(ctx, chunkChan, p.provider)
+		defer llm.CloseOnDone(ctx, stream)()
 
-		// Use an accumulator to track the full content
-		acc := openai.ChatCompletionAccumulator{}
+		// Usage is reported on the last chunk, or cumulatively on every chunk by some gateways.
+		// Only the usage is kept: accumulating the content would copy it on every chunk.
+		var usage openai.CompletionUsage
+		citations := newCitationTracker()
+		var tier llm.ServiceTier
+		var id, reason string
+		var refusal strings.Builder
 
 		for stream.Next() {
 			// Check for context cancellation
 			select {
 			case <-ctx.Done():
-				// Context was canceled, send error and return
-				select {
-				case chunkChan <- llm.StreamTextChunk{
-					Text: fmt.Sprintf("Stream canceled: %v", ctx.Err()),
-				}:
-				default:
-					// Channel full or closed, just return
+				// Report the usage accumulated so far when canceled by a provider shutdown
+				if llm.IsShuttingDown(ctx) && sendUsage {
+					select {
+					case chunkChan <- p.usageChunk(&usage, tier, metadata, opts):
+					default:
+					}
 				}
 				return
 			default:
@@ -157,28 +410,71 @@ func (p *OpenAICompletionModel) StreamComplete(ctx context.Context, req *llm.Com
 			}
 
 			chunk := stream.Current()
-			acc.AddChunk(chunk)
+			if chunk.JSON.Usage.Valid() {
+				usage = chunk.Usage
+			}
+			if p.metadataHook != nil {
+				p.metadataHook(metadata, chunk.RawJSON(), header)
+			}
+			if chunk.ServiceTier != "" {
+				tier = llm.ServiceTier(chunk.ServiceTier)
+			}
+			if chunk.ID != "" {
+				id = chunk.ID
+			}
+
+			// Numbered citation lists (Perplexity) are sent on the chunk itself
+			if f, ok := chunk.JSON.ExtraFields["citations"]; ok {
+				citations.setURLs(f.Raw())
+			}
 
 			if len(chunk.Choices) > 0 {
+				if chunk.Choices[0].FinishReason != "" {
+					reason = chunk.Choices[0].FinishReason
+				}
+				refusal.WriteString(chunk.Choices[0].Delta.Refusal)
+
+				var citationChunks []llm.StreamCitationChunk
 				if chunk.Choices[0].Delta.Content != "" {
 					text := chunk.Choices[0].Delta.Content
+					citationChunks = citations.addText(text)
+					if opts.StreamsEvent(llm.TextChunkType) {
+						select {
+						case chunkChan <- opts.TextChunk(text):
+						case <-ctx.Done():
+							// Context canceled while sending
+							return
+						}
+					}
+					if logprobs := chunk.Choices[0].Logprobs.Content; len(logprobs) > 0 && opts.StreamsEvent(llm.LogprobChunkType) {
+						select {
+						case chunkChan <- llm.StreamLogprobChunk{Logprobs: toTokenLogprobs(logprobs)}:
+						case <-ctx.Done():
+							return
+						}
+					}
+				} else if reasoning := extraString(chunk.Choices[0].Delta.JSON.ExtraFields, "reasoning_content"); reasoning != "" && opts.StreamsEvent(llm.ReasoningChunkType) {
 					select {
-					case chunkChan <- llm.StreamTextChunk{
-						Text: text,
+					case chunkChan <- llm.StreamReasoningChunk{
+						Reasoning: reasoning,
 					}:
 					case <-ctx.Done():
 						// Context canceled while sending
 						return
 					}
-				} else if f, ok := chunk.Choices[0].Delta.JSON.ExtraFields["reasoning_content"]; ok {
-					reasoning := f.Raw()
-					reasoning = reasoning[1 : len(reasoning)-1]
+				}
+
+				// Annotations (OpenAI search models, OpenRouter web search) carry url citations
+				if f, ok := chunk.Choices[0].Delta.JSON.ExtraFields["annotations"]; ok {
+					citationChunks = append(citationChunks, citations.addAnnotations(f.Raw())...)
+				}
+				if !sendCitations {
+					citationChunks = nil
+				}
+				for _, c := range citationChunks {
 					select {
-					case chunkChan <- llm.StreamReasoningChunk{
-						Reasoning: reasoning,
-					}:
+					case chunkChan <- c:
 					case <-ctx.Done():
-						// Context canceled while sending
 						return
 					}
 				}
@@ -189,45 +485,51 @@ func (p *OpenAICompletionModel) StreamComplete(ctx context.Context, req *llm.Com
 		if err := stream.Err(); err != nil {
 			// Check if error is due to context cancellation
 			if ctx.Err() != nil {
+				if llm.IsShuttingDown(ctx) && sendUsage {
+					select {
+					case chunkChan <- p.usageChunk(&usage, tier, metadata, opts):
+					default:
+					}
+				}
 				return
 			}
 
 			select {
-			case chunkChan <- llm.StreamTextChunk{
-				Text: fmt.Sprintf("Error from OpenAI API: %v", err),
-			}:
+			case chunkChan <- llm.StreamErrorChunk{Provider: p.provider, Err: err}:
 			case <-ctx.Done():
 				return
 			}
 			return
 		}
 
-		// Check if usage information should be included
-		if opts.WithUsage != nil && *opts.WithUsage {
-			// Create usage information
-			usage := &llm.TokenUsage{
-				TotalInputTokens:      acc.ChatCompletion.Usage.PromptTokens,
-				TotalOutputTokens:     acc.ChatCompletion.Usage.CompletionTokens,
-				TotalReasoningTokens:  acc.ChatCompletion.Usage.CompletionTokensDetails.ReasoningTokens,
-				TotalImages:           0,
-				TotalWebSearches:      0,
-				TotalRequests:         1,
-				TotalCacheReadTokens:  acc.ChatCompletion.Usage.PromptTokensDetails.CachedTokens,
-				TotalCacheWriteTokens: 0,
+		// Send citations from the numbered list that were never referenced inline
+		if sendCitations {
+			for _, c := range citations.remaining() {
+				select {
+				case chunkChan <- c:
+				case <-ctx.Done():
+					return
+				}
 			}
+		}
 
-			// Calculate cost if requested
-			var cost *float64
-			if opts.WithCost != nil && *opts.WithCost {
-				cost = common.CalculateCost(p.modelInfo, usage)
+		if opts.StreamsEvent(llm.FinishChunkType) {
+			select {
+			case chunkChan <- llm.StreamFinishChunk{
+				Reason:  finishReason(reason, refusal.String()),
+				Refusal: refusal.String(),
+				ID:      id,
+			}:
+			case <-ctx.Done():
+				return
 			}
+		}
 
+		// Check if usage information should be included
+		if sendUsage {
 			// Send usage information at the end
 			select {
-			case chunkChan <- llm.StreamUsageChunk{
-				Usage: usage,
-				Cost:  cost,
-			}:
+			case chunkChan <- p.usageChunk(&usage, tier, metadata, opts):
 			case <-ctx.Done():
 				return
 			}
@@ -237,15 +539,86 @@ func (p *OpenAICompletionModel) StreamComplete(ctx context.Context, req *llm.Com
 	return chunkChan, nil
 }
 
+// usageChunk builds the usage chunk sent at the end of a stream, with cost at the price of the
+// service tier that served it if requested, and the metadata of the metadata hook if any
+func (p *OpenAICompletionModel) usageChunk(completionUsage *openai.CompletionUsage, tier llm.ServiceTier, metadata *llm.ResponseMetadata, opts *llm.CompletionOptions) llm.StreamUsageChunk {
+	usage := &llm.TokenUsage{
+		TotalInputTokens:       completionUsage.PromptTokens,
+		TotalOutputTokens:      completionUsage.CompletionTokens,
+		TotalReasoningTokens:   completionUsage.CompletionTokensDetails.ReasoningTokens,
+		TotalImages:            0,
+		TotalWebSearches:       0,
+		TotalRequests:          1,
+		TotalCacheReadTokens:   cacheReadTokens(*completionUsage),
+		TotalCacheWriteTokens:  0,
+		TotalAudioInputTokens:  completionUsage.PromptTokensDetails.AudioTokens,
+		TotalAudioOutputTokens: completionUsage.CompletionTokensDetails.AudioTokens,
+	}
+
+	// Calculate cost if requested
+	var cost *float64
+	var breakdown *llm.CostBreakdown
+	if opts.WithCost != nil && *opts.WithCost {
+		cost, breakdown = common.CalculateCostBreakdown(p.modelInfo.ForServiceTier(tier), usage)
+	}
+
+	chunk := llm.StreamUsageChunk{
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}
+	if metadata != nil && !reflect.ValueOf(*metadata).IsZero() {
+		copied := *metadata
+		chunk.Metadata = &copied
+	}
+	return chunk
+}
+
 func (p *OpenAICompletionModel) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
 	// Parse options
-	opts := llm.ApplyCompletionOptions(p.options)
+	opts, determinism := llm.NormalizeDeterminism(llm.ApplyContextCompletionOptions(ctx, p.options), p.seedSupported, !p.modelInfo.Reasoning)
+
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	req, err := common.NormalizeRequestArtifacts(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.ValidateMessageInputs(p.modelInfo, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := common.CheckExtendedContext(p.provider, p.modelInfo, opts); err != nil {
+		return nil, err
+	}
+	if err := common.CheckContextWindow(p.modelInfo, p.tokenizer(), req, opts); err != nil {
+		return nil, err
+	}
+	if err := llm.CheckOutputLanguage(opts); err != nil {
+		return nil, err
+	}
 
 	params, err := ToChatCompletionParams(p.name, req.Instructions, req.Messages, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat llm params: %w", err)
 	}
-	resp, err := p.client.Chat.Completions.New(ctx, params)
+	p.setProviderFields(&params)
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// The response headers are only kept for the metadata hook
+	var httpResp *http.Response
+	var callOpts []option.RequestOption
+	if p.metadataHook != nil {
+		callOpts = append(callOpts, option.WithResponseInto(&httpResp))
+	}
+
+	requests := 1
+	resp, err := p.client.Chat.Completions.New(ctx, params, callOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to complete chat: %w", err)
 	}
@@ -255,34 +628,168 @@ func (p *OpenAICompletionModel) Complete(ctx context.Context, req *llm.Completio
 		return nil, llm.ErrEmptyContent
 	}
 
+	// Retry once with stricter instructions if the response is in the wrong language
+	if opts.OutputLanguage != nil && opts.ValidateOutputLanguage != nil && *opts.ValidateOutputLanguage &&
+		llm.IsOutputLanguageMismatch(*opts.OutputLanguage, resp.Choices[0].Message.Content) {
+		retryParams, err := ToChatCompletionParams(p.name, appendInstructions(req.Instructions,
+			llm.OutputLanguageInstruction(*opts.OutputLanguage, true)), req.Messages, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create chat llm params: %w", err)
+		}
+		p.setProviderFields(&retryParams)
+		retryResp, err := p.client.Chat.Completions.New(ctx, retryParams, callOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete chat: %w", err)
+		}
+		if len(retryResp.Choices) == 0 {
+			return nil, llm.ErrEmptyContent
+		}
+		retryResp.Usage.PromptTokens += resp.Usage.PromptTokens
+		retryResp.Usage.CompletionTokens += resp.Usage.CompletionTokens
+		retryResp.Usage.CompletionTokensDetails.ReasoningTokens += resp.Usage.CompletionTokensDetails.ReasoningTokens
+		retryResp.Usage.PromptTokensDetails.CachedTokens = cacheReadTokens(retryResp.Usage) + cacheReadTokens(resp.Usage)
+		retryResp.Usage.PromptTokensDetails.AudioTokens += resp.Usage.PromptTokensDetails.AudioTokens
+		retryResp.Usage.CompletionTokensDetails.AudioTokens += resp.Usage.CompletionTokensDetails.AudioTokens
+		resp = retryResp
+		requests++
+	}
+
 	var usage *llm.TokenUsage
 	var cost *float64
+	var breakdown *llm.CostBreakdown
 
 	// Include usage information if requested
 	if opts.WithUsage != nil && *opts.WithUsage {
 		usage = &llm.TokenUsage{
-			TotalInputTokens:      resp.Usage.PromptTokens,
-			TotalOutputTokens:     resp.Usage.CompletionTokens,
-			TotalReasoningTokens:  resp.Usage.CompletionTokensDetails.ReasoningTokens,
-			TotalImages:           0,
-			TotalWebSearches:      0,
-			TotalRequests:         1,
-			TotalCacheReadTokens:  resp.Usage.PromptTokensDetails.CachedTokens,
-			TotalCacheWriteTokens: 0,
+			TotalInputTokens:       resp.Usage.PromptTokens,
+			TotalOutputTokens:      resp.Usage.CompletionTokens,
+			TotalReasoningTokens:   resp.Usage.CompletionTokensDetails.ReasoningTokens,
+			TotalImages:            0,
+			TotalWebSearches:       0,
+			TotalRequests:          requests,
+			TotalCacheReadTokens:   cacheReadTokens(resp.Usage),
+			TotalCacheWriteTokens:  0,
+			TotalAudioInputTokens:  resp.Usage.PromptTokensDetails.AudioTokens,
+			TotalAudioOutputTokens: resp.Usage.CompletionTokensDetails.AudioTokens,
 		}
 
-		// Calculate cost if requested
+		// Calculate cost if requested, at the price of the service tier that served the request
 		if opts.WithCost != nil && *opts.WithCost {
-			cost = common.CalculateCost(p.modelInfo, usage)
+			cost, breakdown = common.CalculateCostBreakdown(p.modelInfo.ForServiceTier(llm.ServiceTier(resp.ServiceTier)), usage)
 		}
 	}
 
-	output := resp.Choices[0].Message.Content
-	return &llm.CompletionResponse{
-		Output: output,
-		Usage:  usage,
-		Cost:   cost,
-	}, nil
+	// Record the fingerprint so callers can detect backend changes between deterministic requests
+	var metadata *llm.ResponseMetadata
+	if determinism != "" || resp.SystemFingerprint != "" || resp.ServiceTier != "" {
+		metadata = &llm.ResponseMetadata{
+			SystemFingerprint: resp.SystemFingerprint,
+			Determinism:       determinism,
+			ServiceTier:       llm.ServiceTier(resp.ServiceTier),
+		}
+	}
+	if p.metadataHook != nil {
+		if metadata == nil {
+			metadata = &llm.ResponseMetadata{}
+		}
+		var header http.Header
+		if httpResp != nil {
+			header = httpResp.Header
+		}
+		p.metadataHook(metadata, resp.RawJSON(), header)
+	}
+
+	choices := make([]llm.CompletionChoice, len(resp.Choices))
+	for i, choice := range resp.Choices {
+		choices[i] = toCompletionChoice(choice)
+	}
+	response := &llm.CompletionResponse{
+		Output:        choices[0].Text,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+		Metadata:      metadata,
+		Logprobs:      choices[0].Logprobs,
+		FinishReason:  choices[0].FinishReason,
+		Refusal:       choices[0].Refusal,
+		ID:            resp.ID,
+		Reasoning:     extraString(resp.Choices[0].Message.JSON.ExtraFields, "reasoning_content"),
+	}
+	if len(choices) > 1 {
+		response.Choices = choices
+	}
+	return response, nil
+}
+
+// cacheReadTokens returns the prompt tokens read from the cache, which DeepSeek reports as its
+// prompt cache hits
+func cacheReadTokens(usage openai.CompletionUsage) int64 {
+	if usage.PromptTokensDetails.CachedTokens > 0 {
+		return usage.PromptTokensDetails.CachedTokens
+	}
+	var hits int64
+	if f, ok := usage.JSON.ExtraFields["prompt_cache_hit_tokens"]; ok {
+		_ = json.Unmarshal([]byte(f.Raw()), &hits)
+	}
+	return hits
+}
+
+// extraString returns the string value of a field the SDK does not know, such as the
+// reasoning_content of DeepSeek, or an empty string if it is missing or null
+func extraString(fields map[string]respjson.Field, name string) string {
+	var value string
+	if f, ok := fields[name]; ok {
+		_ = json.Unmarshal([]byte(f.Raw()), &value)
+	}
+	return value
+}
+
+// toCompletionChoice converts a chat completion choice
+func toCompletionChoice(choice openai.ChatCompletionChoice) llm.CompletionChoice {
+	return llm.CompletionChoice{
+		Index:        int(choice.Index),
+		Text:         choice.Message.Content,
+		FinishReason: finishReason(choice.FinishReason, choice.Message.Refusal),
+		Refusal:      choice.Message.Refusal,
+		Logprobs:     toTokenLogprobs(choice.Logprobs.Content),
+	}
+}
+
+// toTokenLogprobs converts the log probabilities of chat completion tokens
+func toTokenLogprobs(content []openai.ChatCompletionTokenLogprob) []llm.TokenLogprob {
+	if len(content) == 0 {
+		return nil
+	}
+	logprobs := make([]llm.TokenLogprob, len(content))
+	for i, lp := range content {
+		logprobs[i] = llm.TokenLogprob{Token: lp.Token, Logprob: lp.Logprob}
+		if len(lp.TopLogprobs) > 0 {
+			logprobs[i].TopLogprobs = make([]llm.TokenLogprob, len(lp.TopLogprobs))
+			for j, top := range lp.TopLogprobs {
+				logprobs[i].TopLogprobs[j] = llm.TokenLogprob{Token: top.Token, Logprob: top.Logprob}
+			}
+		}
+	}
+	return logprobs
+}
+
+// finishReason normalizes the finish reason of a chat completion choice. Refusals are reported
+// with a stop reason, so a choice with a refusal is reported as filtered.
+func finishReason(reason, refusal string) llm.FinishReason {
+	if refusal != "" {
+		return llm.FinishReasonContentFilter
+	}
+	switch reason {
+	case "stop":
+		return llm.FinishReasonStop
+	case "length":
+		return llm.FinishReasonLength
+	case "content_filter":
+		return llm.FinishReasonContentFilter
+	case "tool_calls", "function_call":
+		return llm.FinishReasonToolCalls
+	}
+	return ""
 }
 
 // OpenAIEmbeddingModel implements EmbeddingModel interface
@@ -290,6 +797,8 @@ type OpenAIEmbeddingModel struct {
 	name      string
 	modelInfo *llm.ModelInfo
 	client    openai.Client
+	lookup    func(model string) *llm.ModelInfo
+	inflight  *llm.InFlight
 }
 
 func NewOpenAIEmbeddingModel(name string, modelInfo *llm.ModelInfo, client openai.Client) (*OpenAIEmbeddingModel, error) {
@@ -301,6 +810,13 @@ func NewOpenAIEmbeddingModel(name string, modelInfo *llm.ModelInfo, client opena
 }
 
 func (p *OpenAIEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	// Default to the model this instance was created for
+	model := p.name
+	if p.modelInfo != nil && p.modelInfo.ID != "" {
+		model = p.modelInfo.ID
+	}
+	req = common.DefaultEmbeddingModel(req, model)
+
 	// Validate the request
 	if err := common.ValidateEmbeddingRequest(req); err != nil {
 		return nil, err
@@ -311,14 +827,16 @@ func (p *OpenAIEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.
 		Model: req.Model,
 	}
 
-	// Handle input - use any interface{} for the union type
-	var input any
-	if len(req.Contents) == 1 {
-		input = req.Contents[0]
+	// Handle input - a single string or an array of strings
+	contents := req.Contents
+	if req.Config != nil {
+		contents = taskPrefixedContents(req.Model, req.Config.TaskType, contents)
+	}
+	if len(contents) == 1 {
+		params.Input = openai.EmbeddingNewParamsInputUnion{OfString: openai.String(contents[0])}
 	} else {
-		input = req.Contents
+		params.Input = openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: contents}
 	}
-	params.Input = input.(openai.EmbeddingNewParamsInputUnion)
 
 	// Apply config if provided
 	if req.Config != nil {
@@ -335,6 +853,12 @@ func (p *OpenAIEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.
 		}
 	}
 
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	// Generate llms
 	resp, err := p.client.Embeddings.New(ctx, params)
 	if err != nil {
@@ -358,24 +882,39 @@ func (p *OpenAIEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.
 		TotalRequests:     1,
 	}
 
-	// Calculate cost if model info is available
+	// Calculate cost using the pricing of the model actually requested
 	var cost *float64
-	if p.modelInfo != nil {
-		cost = common.CalculateCost(p.modelInfo, usage)
+	var breakdown *llm.CostBreakdown
+	if modelInfo := p.requestedModelInfo(req.Model); modelInfo != nil {
+		cost, breakdown = common.CalculateCostBreakdown(modelInfo, usage)
 	}
 
 	return &llm.EmbeddingResponse{
-		Embeddings: llms,
-		Usage:      usage,
-		Cost:       cost,
+		Embeddings:    llms,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
 	}, nil
 }
 
+// requestedModelInfo returns the model info for the requested model, which may
+// differ from the model this instance was created for
+func (p *OpenAIEmbeddingModel) requestedModelInfo(model string) *llm.ModelInfo {
+	if model == p.name || p.modelInfo != nil && model == p.modelInfo.ID {
+		return p.modelInfo
+	}
+	if p.lookup != nil {
+		return p.lookup(model)
+	}
+	return nil
+}
+
 // OpenAIImageModel implements ImageModel interface
 type OpenAIImageModel struct {
 	name      string
 	modelInfo *llm.ModelInfo
 	client    openai.Client
+	inflight  *llm.InFlight
 }
 
 func NewOpenAIImageModel(name string, modelInfo *llm.ModelInfo, client openai.Client) (*OpenAIImageModel, error) {
@@ -390,14 +929,26 @@ func (p *OpenAIImageModel) GenerateImage(ctx context.Context, req *llm.ImageRequ
 	if req.Instructions == "" {
 		return nil, llm.ErrEmptyInstructions
 	}
+	format, err := llm.ImageResponseFormat(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	n, err := llm.ImageCount(req.Config)
+	if err != nil {
+		return nil, err
+	}
 
 	// Set up parameters for llm generation using instructions as prompt
 	params := openai.ImageGenerateParams{
-		Prompt:         req.Instructions,
-		Model:          openai.ImageModelDallE3,                         // Default to DALL-E 3
-		ResponseFormat: openai.ImageGenerateParamsResponseFormatB64JSON, // Return base64 to get []byte
-		N:              openai.Int(1),
+		Prompt: req.Instructions,
+		Model:  p.modelID(),
+		N:      openai.Int(int64(n)),
+	}
+	responseFormat, err := p.responseFormat(format)
+	if err != nil {
+		return nil, err
 	}
+	params.ResponseFormat = openai.ImageGenerateParamsResponseFormat(responseFormat)
 
 	// Apply config if provided
 	if req.Config != nil {
@@ -430,41 +981,183 @@ func (p *OpenAIImageModel) GenerateImage(ctx context.Context, req *llm.ImageRequ
 		}
 	}
 
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	// Generate the llm
 	resp, err := p.client.Images.Generate(ctx, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate llm: %w", err)
 	}
+	return p.imageResponse(resp, format)
+}
 
-	if len(resp.Data) == 0 {
-		return nil, llm.ErrEmptyContent
+// responseFormat returns the response_format parameter for images delivered in format.
+// gpt-image models always return base64 and reject response_format; DALL·E models host the
+// image for an hour when asked for a URL.
+func (p *OpenAIImageModel) responseFormat(format string) (string, error) {
+	switch model := p.modelID(); {
+	case strings.HasPrefix(model, "gpt-image"):
+		if format == llm.ImageResponseFormatURL {
+			return "", llm.NewUnsupportedCapabilityError("openai", "image URLs with "+model)
+		}
+		return "", nil
+	case format == llm.ImageResponseFormatURL:
+		return "url", nil
+	default:
+		return "b64_json", nil
 	}
+}
 
-	// Decode base64 llm data
-	llmBytes, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode llm data: %w", err)
+// imageResponse delivers the images of resp in format, priced per image of the requested model
+func (p *OpenAIImageModel) imageResponse(resp *openai.ImagesResponse, format string) (*llm.ImageResponse, error) {
+	if len(resp.Data) == 0 {
+		return nil, llm.ErrEmptyContent
 	}
 
 	// Create usage information
 	usage := &llm.TokenUsage{
-		TotalImages:   1,
+		TotalImages:   len(resp.Data),
 		TotalRequests: 1,
 	}
 
-	// Calculate cost if requested - image generation has fixed pricing
 	var cost *float64
-	if req.Config != nil && p.modelInfo != nil {
-		if p.modelInfo.Pricing.Image > 0 {
-			totalCost := p.modelInfo.Pricing.Image
-			cost = &totalCost
+	var breakdown *llm.CostBreakdown
+	if p.modelInfo != nil && !p.modelInfo.Pricing.Image.IsZero() {
+		cost, breakdown = common.CalculateCostBreakdown(p.modelInfo, usage)
+	}
+
+	images := make([]llm.GeneratedImage, len(resp.Data))
+	for i, data := range resp.Data {
+		image := &images[i]
+		image.RevisedPrompt = data.RevisedPrompt
+		if format == llm.ImageResponseFormatURL {
+			if data.URL == "" {
+				return nil, llm.ErrEmptyContent
+			}
+			image.URL = data.URL
+			image.MimeType = "image/png"
+			continue
+		}
+		// Decode base64 llm data
+		var err error
+		if image.Data, err = base64.StdEncoding.DecodeString(data.B64JSON); err != nil {
+			return nil, fmt.Errorf("failed to decode llm data: %w", err)
+		}
+		if err := llm.DeliverImage(image, format); err != nil {
+			return nil, err
+		}
+	}
+	response := llm.NewImageResponse(images, usage, cost)
+	response.CostBreakdown = breakdown
+	return response, nil
+}
+
+// modelID returns the API model identifier for this image model
+func (p *OpenAIImageModel) modelID() string {
+	if p.modelInfo != nil && p.modelInfo.ID != "" {
+		return p.modelInfo.ID
+	}
+	return p.name
+}
+
+// defaultVoice is used when a speech request names no voice
+const defaultVoice = "alloy"
+
+// OpenAISpeechModel implements SpeechModel interface
+type OpenAISpeechModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	client    openai.Client
+	inflight  *llm.InFlight
+}
+
+func NewOpenAISpeechModel(name string, modelInfo *llm.ModelInfo, client openai.Client) *OpenAISpeechModel {
+	return &OpenAISpeechModel{
+		name:      name,
+		modelInfo: modelInfo,
+		client:    client,
+	}
+}
+
+func (p *OpenAISpeechModel) GenerateSpeech(ctx context.Context, req *llm.SpeechRequest) (*llm.SpeechResponse, error) {
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	if req.Text == "" {
+		return nil, llm.NewValidationError("text", "cannot be empty", nil)
+	}
+
+	model := req.Model
+	if model == "" {
+		model = p.modelInfo.ID
+	}
+	params := openai.AudioSpeechNewParams{
+		Input:          req.Text,
+		Model:          openai.SpeechModel(model),
+		Voice:          defaultVoice,
+		ResponseFormat: openai.AudioSpeechNewParamsResponseFormatMP3,
+	}
+	if req.Instructions != "" {
+		params.Instructions = openai.String(req.Instructions)
+	}
+	format := llm.SpeechFormatMP3
+	if req.Config != nil {
+		if req.Config.Voice != "" {
+			params.Voice = openai.AudioSpeechNewParamsVoice(req.Config.Voice)
+		}
+		if req.Config.Speed != 0 {
+			if req.Config.Speed < 0.25 || req.Config.Speed > 4 {
+				return nil, llm.NewValidationError("speed", "must be between 0.25 and 4", req.Config.Speed)
+			}
+			params.Speed = openai.Float(req.Config.Speed)
+		}
+		if req.Config.Format != "" {
+			format = req.Config.Format
+			params.ResponseFormat = openai.AudioSpeechNewParamsResponseFormat(format)
 		}
 	}
 
-	return &llm.ImageResponse{
-		Output: llmBytes,
-		Usage:  usage,
-		Cost:   cost,
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	resp, err := p.client.Audio.Speech.New(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate speech: %w", err)
+	}
+	defer resp.Body.Close()
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read speech: %w", err)
+	}
+	if len(audio) == 0 {
+		return nil, llm.ErrEmptyContent
+	}
+
+	// Speech is billed per input character
+	usage := &llm.TokenUsage{
+		TotalCharacters: int64(utf8.RuneCountInString(req.Text)),
+		TotalRequests:   1,
+	}
+	var cost *float64
+	var breakdown *llm.CostBreakdown
+	if p.modelInfo != nil && !p.modelInfo.Pricing.Characters.IsZero() {
+		cost, breakdown = common.CalculateCostBreakdown(p.modelInfo, usage)
+	}
+
+	return &llm.SpeechResponse{
+		Output:        audio,
+		ContentType:   llm.SpeechContentType(format),
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
 	}, nil
 }
 
@@ -474,6 +1167,9 @@ type OpenAIConversationModel struct {
 	modelInfo *llm.ModelInfo
 	client    openai.Client
 	options   []llm.ResponseOption
+	inflight  *llm.InFlight
+	// provider is the name reported in stream errors
+	provider string
 }
 
 func NewOpenAIConversationModel(name string, modelInfo *llm.ModelInfo, client openai.Client, opts ...llm.ResponseOption) (*OpenAIConversationModel, error) {
@@ -482,34 +1178,63 @@ func NewOpenAIConversationModel(name string, modelInfo *llm.ModelInfo, client op
 		modelInfo: modelInfo,
 		client:    client,
 		options:   opts,
+		provider:  "openai",
 	}, nil
 }
 
 func (p *OpenAIConversationModel) StreamResponse(ctx context.Context, req *llm.ConversationRequest) (llm.StreamConversationResponse, error) {
 	// Parse options
-	opts := llm.ApplyResponseOptions(p.options)
+	opts := llm.ApplyContextResponseOptions(ctx, p.options)
+
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	messages, err := common.NormalizeMessageArtifacts(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	normalized := *req
+	normalized.Messages = messages
+	req = &normalized
+	if err := common.ValidateMessageInputs(p.modelInfo, req.Messages); err != nil {
+		return nil, err
+	}
 
-	params, err := ToResponseNewParams(p.name, req.Input, opts)
+	params, err := ToResponseNewParams(p.name, req, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create response params: %w", err)
 	}
+	if p.provider != "openai" {
+		params.ServiceTier = ""
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	stream := p.client.Responses.NewStreaming(ctx, params)
+	if err := stream.Err(); err != nil {
+		done()
+		return nil, fmt.Errorf("failed to stream response: %w", err)
+	}
 	chunkChan := make(chan llm.StreamChunk, 1)
+	sendUsage := opts.CompletionOptions.WithUsage != nil && *opts.CompletionOptions.WithUsage &&
+		opts.CompletionOptions.StreamsEvent(llm.UsageChunkType)
 
 	go func() {
+		defer done()
 		defer close(chunkChan)
+		defer llm.SendStreamCanceled(ctx, chunkChan, p.provider)
+		defer llm.CloseOnDone(ctx, stream)()
 
+		citations := newCitationTracker()
+		var usage *responses.ResponseUsage
+		var tier llm.ServiceTier
 		for stream.Next() {
 			// Check for context cancellation
 			select {
 			case <-ctx.Done():
-				select {
-				case chunkChan <- llm.StreamTextChunk{
-					Text: fmt.Sprintf("Stream canceled: %v", ctx.Err()),
-				}:
-				default:
-				}
 				return
 			default:
 			}
@@ -518,17 +1243,33 @@ func (p *OpenAIConversationModel) StreamResponse(ctx context.Context, req *llm.C
 
 			// Send delta content
 			if data.Delta != "" {
-				select {
-				case chunkChan <- llm.StreamTextChunk{
-					Text: data.Delta,
-				}:
-				case <-ctx.Done():
-					return
+				citations.addText(data.Delta)
+				if opts.CompletionOptions.StreamsEvent(llm.TextChunkType) {
+					select {
+					case chunkChan <- llm.StreamTextChunk{
+						Text: data.Delta,
+					}:
+					case <-ctx.Done():
+						return
+					}
 				}
 			}
 
-			// Check if we have complete text (end of stream)
-			if data.JSON.Text.Valid() {
+			// Send citations as soon as they are annotated on the output text
+			if data.Type == "response.output_text.annotation.added" && opts.CompletionOptions.StreamsEvent(llm.CitationChunkType) {
+				if c, ok := citations.addAnnotation(data.JSON.Annotation.Raw()); ok {
+					select {
+					case chunkChan <- c:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			// The final response carries the token usage of the whole request
+			if data.Type == "response.completed" || data.Type == "response.incomplete" {
+				usage = &data.Response.Usage
+				tier = llm.ServiceTier(data.Response.ServiceTier)
 				break
 			}
 		}
@@ -540,30 +1281,87 @@ func (p *OpenAIConversationModel) StreamResponse(ctx context.Context, req *llm.C
 			}
 
 			select {
-			case chunkChan <- llm.StreamTextChunk{
-				Text: fmt.Sprintf("Error from OpenAI API: %v", err),
-			}:
+			case chunkChan <- llm.StreamErrorChunk{Provider: p.provider, Err: err}:
 			case <-ctx.Done():
 				return
 			}
 			return
 		}
 
-		// Note: Usage information is not easily accessible from the streaming response
-		// TODO: Implement usage tracking once the proper SDK field structure is clarified
+		if sendUsage && usage != nil {
+			// Send usage information at the end
+			select {
+			case chunkChan <- p.usageChunk(usage, tier, opts.CompletionOptions):
+			case <-ctx.Done():
+				return
+			}
+		}
 	}()
 
 	return chunkChan, nil
 }
 
+// usageChunk builds the usage chunk sent at the end of a stream, with cost at the price of the
+// service tier that served it if requested
+func (p *OpenAIConversationModel) usageChunk(responseUsage *responses.ResponseUsage, tier llm.ServiceTier, opts *llm.CompletionOptions) llm.StreamUsageChunk {
+	usage := toResponseTokenUsage(responseUsage)
+
+	// Calculate cost if requested
+	var cost *float64
+	var breakdown *llm.CostBreakdown
+	if opts.WithCost != nil && *opts.WithCost {
+		cost, breakdown = common.CalculateCostBreakdown(p.modelInfo.ForServiceTier(tier), usage)
+	}
+
+	return llm.StreamUsageChunk{
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}
+}
+
+// toResponseTokenUsage converts the usage of a Responses API response
+func toResponseTokenUsage(responseUsage *responses.ResponseUsage) *llm.TokenUsage {
+	return &llm.TokenUsage{
+		TotalInputTokens:     responseUsage.InputTokens,
+		TotalOutputTokens:    responseUsage.OutputTokens,
+		TotalReasoningTokens: responseUsage.OutputTokensDetails.ReasoningTokens,
+		TotalRequests:        1,
+		TotalCacheReadTokens: responseUsage.InputTokensDetails.CachedTokens,
+	}
+}
+
 func (p *OpenAIConversationModel) Response(ctx context.Context, req *llm.ConversationRequest) (*llm.ConversationResponse, error) {
 	// Parse options
-	opts := llm.ApplyResponseOptions(p.options)
+	opts := llm.ApplyContextResponseOptions(ctx, p.options)
+
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	messages, err := common.NormalizeMessageArtifacts(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	normalized := *req
+	normalized.Messages = messages
+	req = &normalized
+	if err := common.ValidateMessageInputs(p.modelInfo, req.Messages); err != nil {
+		return nil, err
+	}
 
-	params, err := ToResponseNewParams(p.name, req.Input, opts)
+	params, err := ToResponseNewParams(p.name, req, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create response params: %w", err)
 	}
+	if p.provider != "openai" {
+		params.ServiceTier = ""
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
 
 	resp, err := p.client.Responses.New(ctx, params)
 	if err != nil {
@@ -577,23 +1375,21 @@ func (p *OpenAIConversationModel) Response(ctx context.Context, req *llm.Convers
 
 	var usage *llm.TokenUsage
 	var cost *float64
+	var breakdown *llm.CostBreakdown
 
 	if opts.CompletionOptions.WithUsage != nil && *opts.CompletionOptions.WithUsage {
-		usage = &llm.TokenUsage{
-			TotalInputTokens:  resp.Usage.InputTokens,
-			TotalOutputTokens: resp.Usage.OutputTokens,
-			TotalRequests:     1,
-		}
+		usage = toResponseTokenUsage(&resp.Usage)
 
 		if opts.CompletionOptions.WithCost != nil && *opts.CompletionOptions.WithCost {
-			cost = common.CalculateCost(p.modelInfo, usage)
+			cost, breakdown = common.CalculateCostBreakdown(p.modelInfo.ForServiceTier(llm.ServiceTier(resp.ServiceTier)), usage)
 		}
 	}
 
 	return &llm.ConversationResponse{
-		Output: output,
-		Usage:  usage,
-		Cost:   cost,
+		Output:        output,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
 	}, nil
 }
 
@@ -601,6 +1397,11 @@ func (p *OpenAIConversationModel) Response(ctx context.Context, req *llm.Convers
 func ToChatCompletionParams(model string, instructions string, messages []*llm.ModelMessage, opts *llm.CompletionOptions) (openai.ChatCompletionNewParams, error) {
 	openaiMessages := make([]openai.ChatCompletionMessageParamUnion, 0, len(messages)+1)
 
+	// Ask for the configured output language as part of the system instructions
+	if opts != nil && opts.OutputLanguage != nil && *opts.OutputLanguage != "" {
+		instructions = appendInstructions(instructions, llm.OutputLanguageInstruction(*opts.OutputLanguage, false))
+	}
+
 	// Add system content if provided
 	if instructions != "" {
 		openaiMessages = append(openaiMessages, openai.SystemMessage(instructions))
@@ -621,7 +1422,8 @@ func ToChatCompletionParams(model string, instructions string, messages []*llm.M
 	}
 
 	if opts != nil {
-		if opts.Temperature != nil && *opts.Temperature != 0 {
+		// A zero temperature is only sent when pinned for determinism, otherwise it means unset
+		if opts.Temperature != nil && (*opts.Temperature != 0 || opts.IsDeterministic()) {
 			params.Temperature = openai.Float(*opts.Temperature)
 		}
 		if opts.TopP != nil && *opts.TopP != 0 {
@@ -636,9 +1438,20 @@ func ToChatCompletionParams(model string, instructions string, messages []*llm.M
 		if opts.FrequencyPenalty != nil && *opts.FrequencyPenalty != 0 {
 			params.FrequencyPenalty = openai.Float(*opts.FrequencyPenalty)
 		}
-		if opts.Seed != nil && *opts.Seed != 0 {
+		if opts.Seed != nil && (*opts.Seed != 0 || opts.IsDeterministic()) {
 			params.Seed = openai.Int(*opts.Seed)
 		}
+		if opts.PromptCacheKey != nil && *opts.PromptCacheKey != "" {
+			params.PromptCacheKey = openai.String(*opts.PromptCacheKey)
+		}
+		if opts.ServiceTier != nil && *opts.ServiceTier != "" {
+			params.ServiceTier = openai.ChatCompletionNewParamsServiceTier(*opts.ServiceTier)
+		}
+		if opts.Prediction != nil && *opts.Prediction != "" {
+			params.Prediction = openai.ChatCompletionPredictionContentParam{
+				Content: openai.ChatCompletionPredictionContentContentUnionParam{OfString: openai.String(*opts.Prediction)},
+			}
+		}
 		if opts.ReasoningEffort != nil {
 			switch *opts.ReasoningEffort {
 			case llm.ReasoningEffortLow:
@@ -656,6 +1469,20 @@ func ToChatCompletionParams(model string, instructions string, messages []*llm.M
 				OfStringArray: opts.Stop,
 			}
 		}
+		if opts.N != nil {
+			if *opts.N < 1 {
+				return openai.ChatCompletionNewParams{}, llm.NewValidationError("n", "must be at least 1", *opts.N)
+			}
+			if *opts.N > 1 {
+				params.N = openai.Int(int64(*opts.N))
+			}
+		}
+		if opts.TopLogprobs != nil {
+			params.Logprobs = openai.Bool(true)
+			if *opts.TopLogprobs > 0 {
+				params.TopLogprobs = openai.Int(int64(*opts.TopLogprobs))
+			}
+		}
 		if opts.ResponseFormat != nil {
 			if *opts.ResponseFormat == llm.ResponseFormatJson {
 				params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
@@ -683,45 +1510,79 @@ func ToChatCompletionMessage(msg *llm.ModelMessage) (openai.ChatCompletionMessag
 	}
 
 	switch msg.Role {
-	case llm.RoleUser:
-		return openai.UserMessage(msg.Content), nil
+	case llm.RoleUser, llm.RoleTool:
+		parts, err := ToChatCompletionContentParts(msg.ContentParts())
+		if err != nil {
+			return openai.UserMessage(""), err
+		}
+		if len(parts) == 1 && parts[0].OfText != nil {
+			return openai.UserMessage(parts[0].OfText.Text), nil
+		}
+		return openai.UserMessage(parts), nil
 
 	case llm.RoleAssistant:
 		if msg.ToolCall == nil {
-			return openai.AssistantMessage(msg.Content), nil
+			return openai.AssistantMessage(msg.Text()), nil
 		}
-		jsonBytes, err := json.Marshal(msg.ToolCall)
+		text, err := toolCallText("call tool: ", msg.ToolCall)
 		if err != nil {
 			return openai.AssistantMessage(""), fmt.Errorf("failed to marshal tool call: %w", err)
 		}
-		var sb strings.Builder
-		sb.WriteString("call tool: ```")
-		sb.Write(jsonBytes)
-		sb.WriteString("```")
-		return openai.AssistantMessage(sb.String()), nil
-
-	case llm.RoleTool:
-		jsonBytes, err := json.Marshal(msg.ToolCall)
-		if err != nil {
-			return openai.UserMessage(""), fmt.Errorf("failed to marshal tool call results: %w", err)
-		}
-		var sb strings.Builder
-		sb.WriteString("call tool results: ```")
-		sb.Write(jsonBytes)
-		sb.WriteString("```")
-		return openai.UserMessage(sb.String()), nil
+		return openai.AssistantMessage(text), nil
 
 	default:
 		return openai.UserMessage(""), llm.NewValidationError("role", "unknown role", string(msg.Role))
 	}
 }
 
-func ToResponseNewParams(model string, input string, opts *llm.ResponseOptions) (responses.ResponseNewParams, error) {
+// ToChatCompletionContentParts converts ordered message content parts into chat completion
+// content parts. Tool results are sent as fenced JSON text.
+func ToChatCompletionContentParts(parts []*llm.ContentPart) ([]openai.ChatCompletionContentPartUnionParam, error) {
+	result := make([]openai.ChatCompletionContentPartUnionParam, 0, len(parts))
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+		switch part.Type {
+		case llm.ContentPartText:
+			result = append(result, openai.TextContentPart(part.Text))
+		case llm.ContentPartToolResult:
+			text, err := toolCallText("call tool results: ", part.ToolCall)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal tool call results: %w", err)
+			}
+			result = append(result, openai.TextContentPart(text))
+		default:
+			if part.Artifact != nil {
+				result = append(result, ToChatCompletionContentPart(part.Artifact))
+			}
+		}
+	}
+	return result, nil
+}
+
+func ToResponseNewParams(model string, req *llm.ConversationRequest, opts *llm.ResponseOptions) (responses.ResponseNewParams, error) {
+	if req == nil {
+		return responses.ResponseNewParams{}, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+
 	params := responses.ResponseNewParams{
-		Input: responses.ResponseNewParamsInputUnion{OfString: openai.String(input)},
 		Model: model,
 	}
 
+	if len(req.Messages) == 0 {
+		params.Input = responses.ResponseNewParamsInputUnion{OfString: openai.String(req.Input)}
+	} else {
+		items, err := ToResponseInputItems(req.Messages)
+		if err != nil {
+			return responses.ResponseNewParams{}, err
+		}
+		if req.Input != "" {
+			items = append(items, responses.ResponseInputItemParamOfMessage(req.Input, responses.EasyInputMessageRoleUser))
+		}
+		params.Input = responses.ResponseNewParamsInputUnion{OfInputItemList: items}
+	}
+
 	if opts != nil {
 		completionOptions := opts.CompletionOptions
 		if completionOptions.Temperature != nil && *completionOptions.Temperature != 0 {
@@ -749,7 +1610,170 @@ func ToResponseNewParams(model string, input string, opts *llm.ResponseOptions)
 		if completionOptions.TopLogprobs != nil && *completionOptions.TopLogprobs != 0 {
 			params.TopLogprobs = openai.Int(int64(*completionOptions.TopLogprobs))
 		}
+		if completionOptions.PromptCacheKey != nil && *completionOptions.PromptCacheKey != "" {
+			params.PromptCacheKey = openai.String(*completionOptions.PromptCacheKey)
+		}
+		if completionOptions.ServiceTier != nil && *completionOptions.ServiceTier != "" {
+			params.ServiceTier = responses.ResponseNewParamsServiceTier(*completionOptions.ServiceTier)
+		}
+		if completionOptions.OutputLanguage != nil && *completionOptions.OutputLanguage != "" {
+			params.Instructions = openai.String(llm.OutputLanguageInstruction(*completionOptions.OutputLanguage, false))
+		}
 	}
 
 	return params, nil
 }
+
+// ToResponseInputItems converts messages into Responses API input items, mapping
+// artifacts to input_image and input_file content parts
+func ToResponseInputItems(messages []*llm.ModelMessage) (responses.ResponseInputParam, error) {
+	items := make(responses.ResponseInputParam, 0, len(messages))
+	for i, msg := range messages {
+		if msg == nil {
+			return nil, llm.NewValidationError(fmt.Sprintf("messages[%d]", i), "cannot be nil", nil)
+		}
+
+		switch msg.Role {
+		case llm.RoleUser, llm.RoleTool:
+			parts := msg.ContentParts()
+			content := make(responses.ResponseInputMessageContentListParam, 0, len(parts))
+			for _, part := range parts {
+				if part == nil {
+					continue
+				}
+				switch part.Type {
+				case llm.ContentPartText:
+					content = append(content, responses.ResponseInputContentUnionParam{
+						OfInputText: &responses.ResponseInputTextParam{Text: part.Text},
+					})
+				case llm.ContentPartToolResult:
+					text, err := toolCallText("call tool results: ", part.ToolCall)
+					if err != nil {
+						return nil, fmt.Errorf("failed to marshal tool call results: %w", err)
+					}
+					content = append(content, responses.ResponseInputContentUnionParam{
+						OfInputText: &responses.ResponseInputTextParam{Text: text},
+					})
+				default:
+					if part.Artifact != nil {
+						content = append(content, ToResponseInputContent(part.Artifact))
+					}
+				}
+			}
+			if len(content) == 1 && content[0].OfInputText != nil {
+				items = append(items, responses.ResponseInputItemParamOfMessage(content[0].OfInputText.Text, responses.EasyInputMessageRoleUser))
+				continue
+			}
+			items = append(items, responses.ResponseInputItemParamOfMessage(content, responses.EasyInputMessageRoleUser))
+
+		case llm.RoleAssistant:
+			text := msg.Text()
+			if msg.ToolCall != nil {
+				var err error
+				text, err = toolCallText("call tool: ", msg.ToolCall)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal tool call: %w", err)
+				}
+			}
+			items = append(items, responses.ResponseInputItemParamOfMessage(text, responses.EasyInputMessageRoleAssistant))
+
+		default:
+			return nil, llm.NewValidationError("role", "unknown role", string(msg.Role))
+		}
+	}
+	return items, nil
+}
+
+// ToResponseInputContent converts an artifact into an input_image or input_file content part
+func ToResponseInputContent(artifact *llm.ModelArtifact) responses.ResponseInputContentUnionParam {
+	if common.IsImageArtifact(artifact) {
+		return responses.ResponseInputContentUnionParam{
+			OfInputImage: &responses.ResponseInputImageParam{
+				Detail:   responses.ResponseInputImageDetailAuto,
+				ImageURL: openai.String(common.ArtifactURL(artifact)),
+			},
+		}
+	}
+
+	file := &responses.ResponseInputFileParam{
+		Filename: openai.String(artifact.Name),
+	}
+	if artifact.URL != "" {
+		file.FileURL = openai.String(artifact.URL)
+	} else {
+		file.FileData = openai.String(common.ArtifactDataURL(artifact))
+	}
+	return responses.ResponseInputContentUnionParam{OfInputFile: file}
+}
+
+// ToChatCompletionContentPart converts an artifact into an image, audio or file content part
+func ToChatCompletionContentPart(artifact *llm.ModelArtifact) openai.ChatCompletionContentPartUnionParam {
+	if common.IsImageArtifact(artifact) {
+		return openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+			URL: common.ArtifactURL(artifact),
+		})
+	}
+	// Chat completions accept inline wav and mp3 audio; other audio is sent as a file
+	if format := inputAudioFormat(artifact.ContentType); format != "" && len(artifact.Content) > 0 {
+		return openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{
+			Data:   base64.StdEncoding.EncodeToString(artifact.Content),
+			Format: format,
+		})
+	}
+	return openai.FileContentPart(openai.ChatCompletionContentPartFileFileParam{
+		FileData: openai.String(common.ArtifactDataURL(artifact)),
+		Filename: openai.String(artifact.Name),
+	})
+}
+
+// inputAudioFormat returns the chat completion input audio format for a content type, or "" if unsupported
+func inputAudioFormat(contentType string) string {
+	switch contentType {
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	}
+	return ""
+}
+
+// appendInstructions appends extra instructions to the system instructions
+func appendInstructions(instructions, extra string) string {
+	if instructions == "" {
+		return extra
+	}
+	return instructions + "\n\n" + extra
+}
+
+// toolCallText formats a tool call as the fenced JSON text sent to the model
+func toolCallText(prefix string, call *llm.ToolCall) (string, error) {
+	jsonBytes, err := json.Marshal(call)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	sb.WriteString("```")
+	sb.Write(jsonBytes)
+	sb.WriteString("```")
+	return sb.String(), nil
+}
+
+// taskPrefixedContents prefixes the contents with the task type for models that are
+// trained with task prefixes, such as nomic-embed-text served by OpenAI compatible
+// providers. Other models have no task types and get the contents unchanged.
+func taskPrefixedContents(model string, taskType llm.EmbeddingTaskType, contents []string) []string {
+	if !strings.Contains(model, "nomic-embed-text") {
+		return contents
+	}
+	switch taskType {
+	case llm.EmbeddingTaskSearchDocument, llm.EmbeddingTaskSearchQuery, llm.EmbeddingTaskClassification, llm.EmbeddingTaskClustering:
+	default:
+		return contents
+	}
+	prefixed := make([]string, len(contents))
+	for i, content := range contents {
+		prefixed[i] = string(taskType) + ": " + content
+	}
+	return prefixed
+}
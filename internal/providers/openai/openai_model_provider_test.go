@@ -4,7 +4,18 @@
 package openai
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/easyagent-dev/llm"
 	"github.com/openai/openai-go/v3/option"
@@ -201,6 +212,124 @@ func TestOpenAIModelProvider_NewImageModel(t *testing.T) {
 	}
 }
 
+// TestOpenAIImageModel_ResponseFormats tests that images are delivered in the requested format
+func TestOpenAIImageModel_ResponseFormats(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	var formats []any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		formats = append(formats, body["response_format"])
+		w.Header().Set("Content-Type", "application/json")
+		if body["response_format"] == "url" {
+			fmt.Fprint(w, `{"created":1,"data":[{"url":"https://images.example.com/1.png"}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"created":1,"data":[{"b64_json":"%s"}]}`, base64.StdEncoding.EncodeToString(png))
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "dall-e-3", Name: "DALL·E 3"}, {ID: "gpt-image-1", Name: "GPT Image 1"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	generate := func(model, format string) (*llm.ImageResponse, error) {
+		imageModel, err := provider.NewImageModel(model)
+		require.NoError(t, err)
+		return imageModel.GenerateImage(context.Background(), &llm.ImageRequest{
+			Instructions: "A lighthouse",
+			Config:       &llm.ImageModelConfig{ResponseFormat: format},
+		})
+	}
+
+	resp, err := generate("dall-e-3", llm.ImageResponseFormatURL)
+	require.NoError(t, err)
+	assert.Equal(t, "https://images.example.com/1.png", resp.URL)
+	assert.Nil(t, resp.Output)
+
+	resp, err = generate("dall-e-3", llm.ImageResponseFormatDataURI)
+	require.NoError(t, err)
+	assert.Equal(t, "data:image/png;base64,"+base64.StdEncoding.EncodeToString(png), resp.DataURI)
+	assert.Equal(t, "image/png", resp.ContentType)
+
+	resp, err = generate("gpt-image-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, png, resp.Output)
+	assert.Equal(t, []any{"url", "b64_json", nil}, formats)
+
+	_, err = generate("gpt-image-1", llm.ImageResponseFormatURL)
+	var capErr *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &capErr)
+	assert.Len(t, formats, 3)
+}
+
+// TestOpenAIModelProvider_Capabilities tests that models are only created for their declared capabilities
+func TestOpenAIModelProvider_Capabilities(t *testing.T) {
+	provider, err := NewOpenAIModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+
+	_, err = provider.NewEmbeddingModel("text-embedding-3-small")
+	assert.NoError(t, err, "Should create embedding model")
+
+	_, err = provider.NewImageModel("dall-e-3")
+	assert.NoError(t, err, "Should create dall-e-3 image model")
+
+	var capErr *llm.UnsupportedCapabilityError
+	_, err = provider.NewImageModel("gpt-4o")
+	assert.ErrorAs(t, err, &capErr, "Chat model should not be usable for image generation")
+
+	_, err = provider.NewCompletionModel("text-embedding-3-small")
+	assert.ErrorAs(t, err, &capErr, "Embedding model should not be usable for completions")
+
+	_, err = provider.NewEmbeddingModel("gpt-4o")
+	assert.ErrorAs(t, err, &capErr, "Chat model should not be usable for embeddings")
+
+	embeddingModels := provider.ModelsWithCapability(llm.ModelCapabilityEmbedding)
+	assert.Len(t, embeddingModels, 3)
+}
+
+// TestOpenAIModelProvider_PricingByRequestedModel tests that pricing follows the requested model
+func TestOpenAIModelProvider_PricingByRequestedModel(t *testing.T) {
+	provider, err := NewOpenAIModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+
+	// The provider wraps its models with cost tracking, so they are built like the provider does
+	embeddingModel, err := NewOpenAIEmbeddingModel("text-embedding-3-small", provider.GetModelInfo("text-embedding-3-small"), provider.client)
+	require.NoError(t, err)
+	embeddingModel.lookup = provider.GetModelInfo
+
+	assert.Equal(t, 0.02, embeddingModel.requestedModelInfo("text-embedding-3-small").Pricing.Prompt.Float64())
+	assert.Equal(t, 0.13, embeddingModel.requestedModelInfo("text-embedding-3-large").Pricing.Prompt.Float64())
+	assert.Nil(t, embeddingModel.requestedModelInfo("unknown-embedding-model"))
+
+	dalle2, err := NewOpenAIImageModel("dall-e-2", provider.GetModelInfo("dall-e-2"), provider.client)
+	require.NoError(t, err)
+	assert.Equal(t, "dall-e-2", dalle2.modelID())
+	assert.Equal(t, 0.02, dalle2.modelInfo.Pricing.Image.Float64())
+}
+
+// TestOpenAIModelProvider_NilRequest tests that nil requests fail validation instead of panicking
+func TestOpenAIModelProvider_NilRequest(t *testing.T) {
+	provider, err := NewOpenAIModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	var validationErr *llm.ValidationError
+
+	model, err := provider.NewCompletionModel("gpt-4o")
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), nil)
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = model.StreamComplete(context.Background(), nil)
+	assert.ErrorAs(t, err, &validationErr)
+
+	conversation, err := provider.NewConversationModel("gpt-4o")
+	require.NoError(t, err)
+	_, err = conversation.Response(context.Background(), nil)
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = conversation.StreamResponse(context.Background(), nil)
+	assert.ErrorAs(t, err, &validationErr)
+}
+
 // TestOpenAIModelProvider_NewConversationModel tests conversation model creation
 func TestOpenAIModelProvider_NewConversationModel(t *testing.T) {
 	provider, err := NewOpenAIModelProvider(llm.WithAPIKey("test-api-key"))
@@ -336,6 +465,32 @@ func TestToChatCompletionMessage(t *testing.T) {
 	}
 }
 
+// TestToChatCompletionMessage_ToolArtifacts tests that tool artifacts are forwarded as content parts
+func TestToChatCompletionMessage_ToolArtifacts(t *testing.T) {
+	msg := &llm.ModelMessage{
+		Role: llm.RoleTool,
+		ToolCall: &llm.ToolCall{
+			Name:   "chart",
+			Output: "chart generated",
+		},
+		Artifacts: []*llm.ModelArtifact{
+			{Name: "chart.png", ContentType: "image/png", Content: []byte{0x89, 0x50}},
+			{Name: "data.pdf", ContentType: "application/pdf", Content: []byte("%PDF")},
+		},
+	}
+
+	result, err := ToChatCompletionMessage(msg)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	body := string(data)
+	assert.Contains(t, body, `"type":"text"`)
+	assert.Contains(t, body, "call tool results: ")
+	assert.Contains(t, body, `"url":"data:image/png;base64,iVA="`)
+	assert.Contains(t, body, `"filename":"data.pdf"`)
+}
+
 // TestToChatCompletionParams tests parameter conversion
 func TestToChatCompletionParams(t *testing.T) {
 	tests := []struct {
@@ -413,6 +568,461 @@ func TestToChatCompletionParams(t *testing.T) {
 	}
 }
 
+// TestToResponseNewParams_Artifacts tests conversion of conversation messages with artifacts
+func TestToResponseNewParams_Artifacts(t *testing.T) {
+	req := &llm.ConversationRequest{
+		Messages: []*llm.ModelMessage{
+			{
+				Role:    llm.RoleUser,
+				Content: "Describe these files",
+				Artifacts: []*llm.ModelArtifact{
+					{Name: "chart.png", ContentType: "image/png", Content: []byte{0x89, 0x50}},
+					{Name: "remote.jpg", ContentType: "image/jpeg", URL: "https://example.com/remote.jpg"},
+					{Name: "report.pdf", ContentType: "application/pdf", Content: []byte("%PDF")},
+				},
+			},
+			{Role: llm.RoleAssistant, Content: "Sure."},
+		},
+		Input: "Go ahead",
+	}
+
+	params, err := ToResponseNewParams("gpt-4o", req, llm.ApplyResponseOptions(nil))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(params)
+	require.NoError(t, err)
+	body := string(data)
+
+	assert.Contains(t, body, `"type":"input_text"`)
+	assert.Contains(t, body, `"image_url":"data:image/png;base64,iVA="`)
+	assert.Contains(t, body, `"image_url":"https://example.com/remote.jpg"`)
+	assert.Contains(t, body, `"type":"input_file"`)
+	assert.Contains(t, body, `"filename":"report.pdf"`)
+	assert.Contains(t, body, `"file_data":"data:application/pdf;base64,JVBERg=="`)
+	assert.Contains(t, body, `"content":"Go ahead"`)
+}
+
+// TestToResponseNewParams_StringInput tests that plain input is sent as a string
+func TestToResponseNewParams_StringInput(t *testing.T) {
+	params, err := ToResponseNewParams("gpt-4o", &llm.ConversationRequest{Input: "Hello"}, llm.ApplyResponseOptions(nil))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(params)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"input":"Hello"`)
+}
+
+// TestOpenAIModelProvider_Shutdown tests that a shut down provider rejects new requests
+func TestOpenAIModelProvider_Shutdown(t *testing.T) {
+	provider, err := NewOpenAIModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+
+	model, err := provider.NewCompletionModel("gpt-4o-mini")
+	require.NoError(t, err)
+
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	req := &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hello"}},
+	}
+	_, err = model.Complete(context.Background(), req)
+	assert.ErrorIs(t, err, llm.ErrShuttingDown)
+
+	_, err = model.StreamComplete(context.Background(), req)
+	assert.ErrorIs(t, err, llm.ErrShuttingDown)
+}
+
+// TestOpenAIModelProvider_Retry tests that rate limited requests are retried after the Retry-After delay
+func TestOpenAIModelProvider_Retry(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.Header().Set("Retry-After-Ms", "10")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"rate limited","type":"rate_limit_error"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	models, err := getOpenAIModels()
+	require.NoError(t, err)
+	provider, err := NewBaseOpenAIModelProvider("openai", models, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+		option.WithMaxRetries(0),
+	})
+	require.NoError(t, err)
+	provider.SetRetryOptions(&llm.RetryOptions{MaxAttempts: 3, Backoff: func(int) time.Duration { return time.Hour }})
+
+	model, err := provider.NewCompletionModel("gpt-4o-mini", llm.WithUsage(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Output)
+	assert.Equal(t, llm.FinishReasonStop, resp.FinishReason)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, resp.Usage.TotalRequests)
+}
+
+// TestOpenAICompletionModel_Deterministic tests how deterministic requests are normalized per provider
+func TestOpenAICompletionModel_Deterministic(t *testing.T) {
+	tests := []struct {
+		provider        string
+		wantLevel       llm.DeterminismLevel
+		wantTemperature any
+	}{
+		{provider: "openai", wantLevel: llm.DeterminismSeeded, wantTemperature: 0.7},
+		{provider: "deepseek", wantLevel: llm.DeterminismPinned, wantTemperature: 0.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			var body map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","system_fingerprint":"fp_123","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`)
+			}))
+			defer server.Close()
+
+			provider, err := NewBaseOpenAIModelProvider(tt.provider, []*llm.ModelInfo{{ID: "m", Name: "M"}}, []option.RequestOption{
+				option.WithAPIKey("test-api-key"),
+				option.WithBaseURL(server.URL),
+			})
+			require.NoError(t, err)
+			model, err := provider.NewCompletionModel("m", llm.WithTemperature(0.7), llm.WithDeterministic(7))
+			require.NoError(t, err)
+
+			resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+				Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantTemperature, body["temperature"])
+			assert.Equal(t, 7.0, body["seed"])
+			require.NotNil(t, resp.Metadata)
+			assert.Equal(t, "fp_123", resp.Metadata.SystemFingerprint)
+			assert.Equal(t, tt.wantLevel, resp.Metadata.Determinism)
+		})
+	}
+}
+
+// TestOpenAICompletionModel_ContextOptions tests that options attached to the context override
+// those of the model
+func TestOpenAICompletionModel_ContextOptions(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "m", Name: "M"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("m", llm.WithTemperature(0.7), llm.WithMaxTokens(1000))
+	require.NoError(t, err)
+
+	ctx := llm.ContextWithOptions(context.Background(), llm.WithMaxTokens(16))
+	_, err = model.Complete(ctx, &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 0.7, body["temperature"])
+	assert.Equal(t, 16.0, body["max_tokens"])
+}
+
+// TestOpenAICompletionModel_AudioCost tests that audio tokens are billed at audio rates
+func TestOpenAICompletionModel_AudioCost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":1000,"completion_tokens":500,"total_tokens":1500,"prompt_tokens_details":{"audio_tokens":600},"completion_tokens_details":{"audio_tokens":400}}}`)
+	}))
+	defer server.Close()
+
+	info := &llm.ModelInfo{ID: "m", Name: "M", Pricing: llm.ModelPricing{Prompt: llm.NewPrice(2.5), Completion: llm.NewPrice(10), AudioInput: llm.NewPrice(40), AudioOutput: llm.NewPrice(80)}}
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{info}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("m", llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(600), resp.Usage.TotalAudioInputTokens)
+	assert.Equal(t, int64(400), resp.Usage.TotalAudioOutputTokens)
+	// 400 text and 600 audio input tokens, 100 text and 400 audio output tokens
+	want := (400*2.5 + 600*40 + 100*10 + 400*80) / 1e6
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, want, *resp.Cost, 1e-9)
+}
+
+func TestOpenAICompletionModel_ServiceTier(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","service_tier":"priority","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":1000000,"completion_tokens":1000000,"total_tokens":2000000}}`)
+	}))
+	defer server.Close()
+
+	info := &llm.ModelInfo{ID: "m", Name: "M", Pricing: llm.ModelPricing{Prompt: llm.NewPrice(2.5), Completion: llm.NewPrice(10)},
+		ServiceTierPricing: map[llm.ServiceTier]llm.ModelPricing{llm.ServiceTierPriority: {Prompt: llm.NewPrice(4.25), Completion: llm.NewPrice(17)}}}
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{info}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("m", llm.WithUsage(true), llm.WithCost(true), llm.WithServiceTier(llm.ServiceTierAuto))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "auto", body["service_tier"])
+	require.NotNil(t, resp.Metadata)
+	assert.Equal(t, llm.ServiceTierPriority, resp.Metadata.ServiceTier)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 4.25+17, *resp.Cost, 1e-9, "the request is priced at the tier that served it")
+
+	// Compatible backends may reject the field, so it is only sent to OpenAI
+	compatible, err := NewBaseOpenAIModelProvider("groq", []*llm.ModelInfo{info}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err = compatible.NewCompletionModel("m", llm.WithServiceTier(llm.ServiceTierAuto))
+	require.NoError(t, err)
+	body = nil
+	_, err = model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.NotContains(t, body, "service_tier")
+}
+
+// TestOpenAISpeechModel_GenerateSpeech tests speech parameters and character based cost
+func TestOpenAISpeechModel_GenerateSpeech(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/audio/speech", r.URL.Path)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Write([]byte("RIFF"))
+	}))
+	defer server.Close()
+
+	models, err := getOpenAIModels()
+	require.NoError(t, err)
+	provider, err := NewBaseOpenAIModelProvider("openai", models, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+
+	_, err = provider.NewSpeechModel("gpt-4o")
+	var capabilityErr *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &capabilityErr)
+
+	model, err := provider.NewSpeechModel("tts-1")
+	require.NoError(t, err)
+	resp, err := model.GenerateSpeech(context.Background(), &llm.SpeechRequest{
+		Text:   "Hello, world",
+		Config: &llm.SpeechModelConfig{Voice: "nova", Speed: 1.5, Format: llm.SpeechFormatWAV},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"input": "Hello, world", "model": "tts-1", "voice": "nova", "speed": 1.5, "response_format": "wav"}, body)
+	assert.Equal(t, []byte("RIFF"), resp.Output)
+	assert.Equal(t, "audio/wav", resp.ContentType)
+	assert.Equal(t, int64(12), resp.Usage.TotalCharacters)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 12*15/1e6, *resp.Cost, 1e-12)
+
+	// gpt-4o-mini-tts is billed by token but priced per character, as the speech endpoint
+	// reports no usage
+	model, err = provider.NewSpeechModel("gpt-4o-mini-tts")
+	require.NoError(t, err)
+	resp, err = model.GenerateSpeech(context.Background(), &llm.SpeechRequest{Text: "Hello, world"})
+	require.NoError(t, err)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 12*15/1e6, *resp.Cost, 1e-12)
+}
+
+// TestToChatCompletionParams_OutputLanguage tests that the output language is requested in the system message
+func TestToChatCompletionParams_OutputLanguage(t *testing.T) {
+	opts := llm.ApplyCompletionOptions([]llm.CompletionOption{llm.WithOutputLanguage("de")})
+	messages := []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hello"}}
+
+	params, err := ToChatCompletionParams("gpt-4o", "You are helpful.", messages, opts)
+	require.NoError(t, err)
+	require.Len(t, params.Messages, 2)
+
+	data, err := json.Marshal(params.Messages[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "You are helpful.")
+	assert.Contains(t, string(data), "German (de)")
+}
+
+// TestOpenAICompletionModel_UnsupportedLanguage tests that validating a language that cannot
+// be detected fails before a request is sent
+func TestOpenAICompletionModel_UnsupportedLanguage(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":"Hei"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	models, err := getOpenAIModels()
+	require.NoError(t, err)
+	provider, err := NewBaseOpenAIModelProvider("openai", models, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+
+	model, err := provider.NewCompletionModel("gpt-4o-mini", llm.WithOutputLanguage("fi", true))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), req)
+	assert.ErrorIs(t, err, llm.ErrUnsupportedLanguage)
+	assert.False(t, llm.IsRetryableError(err))
+	assert.Zero(t, calls)
+
+	// Without validation the language is only requested
+	model, err = provider.NewCompletionModel("gpt-4o-mini", llm.WithOutputLanguage("fi"))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+// TestToChatCompletionParams_PromptCacheKey tests that the prompt cache key is sent
+func TestToChatCompletionParams_PromptCacheKey(t *testing.T) {
+	opts := llm.ApplyCompletionOptions([]llm.CompletionOption{llm.WithPromptCacheKey("support-bot")})
+	messages := []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hello", CacheControl: &llm.CacheControl{}}}
+
+	params, err := ToChatCompletionParams("gpt-4o", "You are helpful.", messages, opts)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(params)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"prompt_cache_key":"support-bot"`)
+	assert.NotContains(t, string(data), "cache_control")
+}
+
+// TestToChatCompletionMessage_ContentParts tests that ordered content parts keep their order
+func TestToChatCompletionMessage_ContentParts(t *testing.T) {
+	msg := llm.NewMessage(llm.RoleUser,
+		llm.NewTextPart("Before"),
+		llm.NewArtifactPart(&llm.ModelArtifact{Name: "a.png", ContentType: "image/png", URL: "https://example.com/a.png"}),
+		llm.NewTextPart("between"),
+		llm.NewArtifactPart(&llm.ModelArtifact{Name: "a.wav", ContentType: "audio/wav", Content: []byte("RIFF")}),
+		llm.NewToolResultPart(&llm.ToolCall{Name: "lookup", Output: "found"}),
+	)
+
+	result, err := ToChatCompletionMessage(msg)
+	require.NoError(t, err)
+	require.NotNil(t, result.OfUser)
+
+	parts := result.OfUser.Content.OfArrayOfContentParts
+	require.Len(t, parts, 5)
+	assert.Equal(t, "Before", parts[0].OfText.Text)
+	assert.Equal(t, "https://example.com/a.png", parts[1].OfImageURL.ImageURL.URL)
+	assert.Equal(t, "between", parts[2].OfText.Text)
+	assert.Equal(t, "wav", parts[3].OfInputAudio.InputAudio.Format)
+	assert.Contains(t, parts[4].OfText.Text, "call tool results: ")
+
+	items, err := ToResponseInputItems([]*llm.ModelMessage{msg})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	content := items[0].OfMessage.Content.OfInputItemContentList
+	require.Len(t, content, 5)
+	assert.Equal(t, "Before", content[0].OfInputText.Text)
+	assert.NotNil(t, content[1].OfInputImage)
+	assert.Equal(t, "between", content[2].OfInputText.Text)
+	assert.NotNil(t, content[3].OfInputFile)
+}
+
+// TestOpenAICompletionModel_ImageInputValidation tests that image input is checked against the model's input media types
+func TestOpenAICompletionModel_ImageInputValidation(t *testing.T) {
+	provider, err := NewOpenAIModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		model    string
+		artifact *llm.ModelArtifact
+		wantErr  string
+	}{
+		{
+			name:     "text_only_model",
+			model:    "o3-mini",
+			artifact: &llm.ModelArtifact{Name: "a.png", ContentType: "image/png", Content: []byte{0x89}},
+			wantErr:  "does not accept image input",
+		},
+		{
+			name:     "unsupported_format",
+			model:    "gpt-4o",
+			artifact: &llm.ModelArtifact{Name: "a.tiff", ContentType: "image/tiff", Content: []byte{0x49}},
+			wantErr:  "PNG, JPEG, GIF or WebP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := provider.NewCompletionModel(tt.model)
+			require.NoError(t, err)
+
+			_, err = model.Complete(context.Background(), &llm.CompletionRequest{
+				Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Describe", Artifacts: []*llm.ModelArtifact{tt.artifact}}},
+			})
+			var validationErr *llm.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "messages[0].artifacts[0]", validationErr.Field)
+			assert.Contains(t, validationErr.Message, tt.wantErr)
+		})
+	}
+}
+
+// TestToChatCompletionMessage_Images tests that image bytes and URLs become image content parts
+func TestToChatCompletionMessage_Images(t *testing.T) {
+	msg := &llm.ModelMessage{Role: llm.RoleUser, Content: "Compare", Artifacts: []*llm.ModelArtifact{
+		{Name: "a.jpg", ContentType: "image/jpeg", Content: []byte{0xff, 0xd8}},
+		{Name: "b.png", ContentType: "image/png", URL: "https://example.com/b.png"},
+	}}
+
+	result, err := ToChatCompletionMessage(msg)
+	require.NoError(t, err)
+
+	parts := result.OfUser.Content.OfArrayOfContentParts
+	require.Len(t, parts, 3)
+	assert.Equal(t, "data:image/jpeg;base64,/9g=", parts[1].OfImageURL.ImageURL.URL)
+	assert.Equal(t, "https://example.com/b.png", parts[2].OfImageURL.ImageURL.URL)
+}
+
 // TestNewOpenAIModelProvider_MultipleInstances tests creating multiple instances
 func TestNewOpenAIModelProvider_MultipleInstances(t *testing.T) {
 	provider1, err1 := NewOpenAIModelProvider(llm.WithAPIKey("test-api-key-1"))
@@ -522,3 +1132,645 @@ func BenchmarkToChatCompletionParams(b *testing.B) {
 		}
 	}
 }
+
+// TestOpenAICompletionModel_StreamEvents tests that filtered chunk types are not sent
+func TestOpenAICompletionModel_StreamEvents(t *testing.T) {
+	events := []string{
+		`{"id":"1","object":"chat.completion.chunk","model":"sonar","choices":[{"index":0,"delta":{"reasoning_content":"Hmm."}}]}`,
+		`{"id":"1","object":"chat.completion.chunk","model":"sonar","citations":["https://a.example"],"choices":[{"index":0,"delta":{"content":"Paris[1]"}}]}`,
+		`{"id":"1","object":"chat.completion.chunk","model":"sonar","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("perplexity", []*llm.ModelInfo{{ID: "sonar", Name: "Sonar"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		opts   []llm.CompletionOption
+		expect []llm.StreamChunkType
+	}{
+		{
+			name:   "all events",
+			opts:   []llm.CompletionOption{llm.WithUsage(true)},
+			expect: []llm.StreamChunkType{llm.ReasoningChunkType, llm.TextChunkType, llm.CitationChunkType, llm.FinishChunkType, llm.UsageChunkType},
+		},
+		{
+			name:   "text only",
+			opts:   []llm.CompletionOption{llm.WithUsage(true), llm.WithStreamEvents(llm.TextChunkType)},
+			expect: []llm.StreamChunkType{llm.TextChunkType},
+		},
+		{
+			name:   "citations and usage",
+			opts:   []llm.CompletionOption{llm.WithUsage(true), llm.WithStreamEvents(llm.CitationChunkType, llm.UsageChunkType)},
+			expect: []llm.StreamChunkType{llm.CitationChunkType, llm.UsageChunkType},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := provider.NewCompletionModel("sonar", tt.opts...)
+			require.NoError(t, err)
+
+			stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+				Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Capital of France?"}},
+			})
+			require.NoError(t, err)
+
+			var types []llm.StreamChunkType
+			for chunk := range stream {
+				types = append(types, chunk.Type())
+			}
+			assert.Equal(t, tt.expect, types)
+		})
+	}
+}
+
+// TestOpenAICompletionModel_StreamCancel tests that canceling the context closes the
+// connection and ends the stream with the context error
+func TestOpenAICompletionModel_StreamCancel(t *testing.T) {
+	disconnected := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"1","object":"chat.completion.chunk","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":"Hello"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			close(disconnected)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "gpt-4o-mini", Name: "GPT-4o mini"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("gpt-4o-mini")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := model.StreamComplete(ctx, &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, llm.StreamTextChunk{Text: "Hello"}, <-stream)
+	cancel()
+
+	var chunks []llm.StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, 1)
+	assert.ErrorIs(t, chunks[0].(llm.StreamErrorChunk), context.Canceled)
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("the connection was not closed when the context was canceled")
+	}
+}
+
+// TestOpenAICompletionModel_StreamKeepAlive tests that keep-alive comments, empty events and
+// out-of-spec frames sent by OpenAI compatible gateways are skipped. The fixtures are written
+// by hand after the frames reported from LiteLLM and vLLM, see testdata/README.md.
+func TestOpenAICompletionModel_StreamKeepAlive(t *testing.T) {
+	for fixture, modelID := range map[string]string{
+		"litellm_keepalive.sse": "gpt-4o-mini",
+		"vllm_stream.sse":       "meta-llama/Llama-3.1-8B-Instruct",
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", fixture))
+			require.NoError(t, err)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write(data)
+			}))
+			defer server.Close()
+
+			provider, err := NewBaseOpenAIModelProvider("gateway", []*llm.ModelInfo{{ID: modelID}}, []option.RequestOption{
+				option.WithAPIKey("test-api-key"),
+				option.WithBaseURL(server.URL),
+			})
+			require.NoError(t, err)
+			model, err := provider.NewCompletionModel(modelID, llm.WithUsage(true))
+			require.NoError(t, err)
+
+			stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+				Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+			})
+			require.NoError(t, err)
+
+			var text string
+			var usage *llm.TokenUsage
+			for chunk := range stream {
+				switch c := chunk.(type) {
+				case llm.StreamTextChunk:
+					text += c.Text
+				case llm.StreamUsageChunk:
+					usage = c.Usage
+				case llm.StreamErrorChunk:
+					t.Fatal(c.Err)
+				}
+			}
+			assert.Equal(t, "Hello world", text)
+			require.NotNil(t, usage)
+			assert.Equal(t, int64(5), usage.TotalInputTokens)
+			assert.Equal(t, int64(2), usage.TotalOutputTokens)
+		})
+	}
+}
+
+// TestToChatCompletionParams_Logprobs tests that requesting top logprobs enables logprobs
+func TestToChatCompletionParams_Logprobs(t *testing.T) {
+	messages := []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hello"}}
+
+	params, err := ToChatCompletionParams("gpt-4o", "", messages, llm.ApplyCompletionOptions([]llm.CompletionOption{llm.WithTopLogprobs(0)}))
+	require.NoError(t, err)
+	assert.True(t, params.Logprobs.Value)
+	assert.False(t, params.TopLogprobs.Valid())
+
+	params, err = ToChatCompletionParams("gpt-4o", "", messages, llm.ApplyCompletionOptions([]llm.CompletionOption{llm.WithTopLogprobs(3)}))
+	require.NoError(t, err)
+	assert.True(t, params.Logprobs.Value)
+	assert.Equal(t, int64(3), params.TopLogprobs.Value)
+
+	params, err = ToChatCompletionParams("gpt-4o", "", messages, llm.ApplyCompletionOptions(nil))
+	require.NoError(t, err)
+	assert.False(t, params.Logprobs.Valid())
+}
+
+func TestOpenAIConversationModel_StreamResponseUsage(t *testing.T) {
+	events := []string{
+		`{"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"Hello","sequence_number":1}`,
+		`{"type":"response.output_text.done","item_id":"msg_1","output_index":0,"content_index":0,"text":"Hello","sequence_number":2}`,
+		`{"type":"response.completed","sequence_number":3,"response":{"id":"resp_1","object":"response","model":"gpt-4o","status":"completed","output":[],` +
+			`"usage":{"input_tokens":1000,"input_tokens_details":{"cached_tokens":200},"output_tokens":500,"output_tokens_details":{"reasoning_tokens":100},"total_tokens":1500}}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	models, err := getOpenAIModels()
+	require.NoError(t, err)
+	provider, err := NewBaseOpenAIModelProvider("openai", models, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		opts      []llm.CompletionOption
+		wantUsage bool
+		wantCost  bool
+	}{
+		{name: "usage and cost", opts: []llm.CompletionOption{llm.WithUsage(true), llm.WithCost(true)}, wantUsage: true, wantCost: true},
+		{name: "usage only", opts: []llm.CompletionOption{llm.WithUsage(true)}, wantUsage: true},
+		{name: "usage not requested"},
+		{name: "usage filtered", opts: []llm.CompletionOption{llm.WithUsage(true), llm.WithStreamEvents(llm.TextChunkType)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := provider.NewConversationModel("gpt-4o", llm.WithOptions(tt.opts...))
+			require.NoError(t, err)
+
+			stream, err := model.StreamResponse(context.Background(), &llm.ConversationRequest{Input: "Hi"})
+			require.NoError(t, err)
+
+			var text string
+			var usage *llm.StreamUsageChunk
+			for chunk := range stream {
+				switch c := chunk.(type) {
+				case llm.StreamTextChunk:
+					text += c.Text
+				case llm.StreamUsageChunk:
+					usage = &c
+				case llm.StreamErrorChunk:
+					t.Fatalf("unexpected stream error: %v", c)
+				}
+			}
+			assert.Equal(t, "Hello", text)
+
+			if !tt.wantUsage {
+				assert.Nil(t, usage)
+				return
+			}
+			require.NotNil(t, usage)
+			assert.Equal(t, &llm.TokenUsage{
+				TotalInputTokens:     1000,
+				TotalOutputTokens:    500,
+				TotalReasoningTokens: 100,
+				TotalRequests:        1,
+				TotalCacheReadTokens: 200,
+			}, usage.Usage)
+			if tt.wantCost {
+				require.NotNil(t, usage.Cost)
+				assert.Greater(t, *usage.Cost, 0.0)
+			} else {
+				assert.Nil(t, usage.Cost)
+			}
+		})
+	}
+}
+
+func TestOpenAICompletionModel_CountTokens(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{
+		ID:            "gpt-4o-mini",
+		Name:          "GPT-4o mini",
+		ContextWindow: 100,
+		Pricing:       llm.ModelPricing{Prompt: llm.NewPrice(1)},
+	}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	provider.SetRetryOptions(&llm.RetryOptions{MaxAttempts: 2})
+
+	model, err := provider.NewCompletionModel("gpt-4o-mini", llm.WithContextWindowCheck(true), llm.WithMaxOutputTokens(50))
+	require.NoError(t, err)
+
+	req := &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: strings.Repeat("word ", 50)}},
+	}
+	count, err := llm.CountTokens(model, req)
+	require.NoError(t, err)
+	assert.Equal(t, 100, count.ContextWindow)
+	assert.Greater(t, count.InputTokens, 50)
+	assert.True(t, count.Estimated, "no o200k_base ranks are registered")
+	require.NotNil(t, count.Cost)
+	assert.InDelta(t, float64(count.InputTokens)/1e6, *count.Cost, 1e-12)
+
+	// The request is rejected before it is sent
+	_, err = model.Complete(context.Background(), req)
+	var windowErr *llm.ContextWindowError
+	require.ErrorAs(t, err, &windowErr)
+	assert.ErrorIs(t, err, llm.ErrContextWindowExceeded)
+	assert.Equal(t, count.InputTokens+50, windowErr.Tokens)
+	_, err = model.StreamComplete(context.Background(), req)
+	assert.ErrorIs(t, err, llm.ErrContextWindowExceeded)
+	assert.Equal(t, 0, calls)
+}
+
+func TestFinishReason(t *testing.T) {
+	assert.Equal(t, llm.FinishReasonStop, finishReason("stop", ""))
+	assert.Equal(t, llm.FinishReasonLength, finishReason("length", ""))
+	assert.Equal(t, llm.FinishReasonContentFilter, finishReason("content_filter", ""))
+	assert.Equal(t, llm.FinishReasonToolCalls, finishReason("tool_calls", ""))
+	assert.Equal(t, llm.FinishReason(""), finishReason("unknown", ""))
+	assert.Equal(t, llm.FinishReasonContentFilter, finishReason("stop", "I can't help with that."))
+}
+
+func TestTaskPrefixedContents(t *testing.T) {
+	contents := []string{"a", "b"}
+	assert.Equal(t, []string{"search_query: a", "search_query: b"},
+		taskPrefixedContents("nomic-ai/nomic-embed-text-v1.5", llm.EmbeddingTaskSearchQuery, contents))
+	assert.Equal(t, contents, taskPrefixedContents("nomic-ai/nomic-embed-text-v1.5", llm.EmbeddingTaskSemanticSimilarity, contents))
+	assert.Equal(t, contents, taskPrefixedContents("nomic-ai/nomic-embed-text-v1.5", "", contents))
+	assert.Equal(t, contents, taskPrefixedContents("text-embedding-3-small", llm.EmbeddingTaskSearchQuery, contents))
+}
+
+// TestOpenAIModelProvider_Policy tests that the provider refuses models denied by its policy
+func TestOpenAIModelProvider_Policy(t *testing.T) {
+	provider, err := NewOpenAIModelProvider(
+		llm.WithAPIKey("test-api-key"),
+		llm.WithPolicy(llm.NewModelPolicy(llm.PolicyRule{Name: "mini-only", Allow: []string{"openai/*-mini"}}), nil),
+	)
+	require.NoError(t, err)
+
+	_, err = provider.NewCompletionModel("gpt-4o")
+	assert.ErrorIs(t, err, llm.ErrPolicyViolation)
+	_, err = provider.NewEmbeddingModel("text-embedding-3-small")
+	assert.ErrorIs(t, err, llm.ErrPolicyViolation)
+
+	model, err := provider.NewCompletionModel("gpt-4o-mini")
+	require.NoError(t, err)
+	assert.NotNil(t, model)
+}
+
+// TestOpenAICompletionModel_StreamUsage tests that streams report the usage details of the last
+// usage sent, and lines longer than the read buffer
+func TestOpenAICompletionModel_StreamUsage(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o-mini\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"%s\"}}]}\n\n", long)
+		fmt.Fprint(w, `data: {"id":"2","object":"chat.completion.chunk","model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":4,"total_tokens":14,"prompt_tokens_details":{"cached_tokens":6},"completion_tokens_details":{"reasoning_tokens":2}}}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "gpt-4o-mini", Name: "GPT-4o mini"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("gpt-4o-mini", llm.WithUsage(true))
+	require.NoError(t, err)
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+
+	var text string
+	var usage *llm.TokenUsage
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case llm.StreamTextChunk:
+			text += c.Text
+		case llm.StreamUsageChunk:
+			usage = c.Usage
+		case llm.StreamErrorChunk:
+			t.Fatal(c.Err)
+		}
+	}
+	assert.Equal(t, long, text)
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(10), usage.TotalInputTokens)
+	assert.Equal(t, int64(4), usage.TotalOutputTokens)
+	assert.Equal(t, int64(6), usage.TotalCacheReadTokens)
+	assert.Equal(t, int64(2), usage.TotalReasoningTokens)
+}
+
+// TestOpenAICompletionModel_Refusal tests that refusals are reported apart from the output,
+// with the response ID, by completions and streams
+func TestOpenAICompletionModel_Refusal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":null,"refusal":"I can't help with that."},"finish_reason":"stop"}]}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{`{"refusal":"I can't "}`, `{"refusal":"help with that."}`} {
+			fmt.Fprintf(w, "data: {\"id\":\"chatcmpl-2\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o-mini\",\"choices\":[{\"index\":0,\"delta\":%s}]}\n\n", delta)
+		}
+		fmt.Fprint(w, `data: {"id":"chatcmpl-2","object":"chat.completion.chunk","model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "gpt-4o-mini", Name: "GPT-4o mini"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("gpt-4o-mini")
+	require.NoError(t, err)
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+
+	resp, err := model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Output)
+	assert.Equal(t, "I can't help with that.", resp.Refusal)
+	assert.Equal(t, llm.FinishReasonContentFilter, resp.FinishReason)
+	assert.Equal(t, "chatcmpl-1", resp.ID)
+
+	stream, err := llm.StreamCompletion(context.Background(), model, req)
+	require.NoError(t, err)
+	var finish []llm.StreamFinishChunk
+	for stream.Next() {
+		if c, ok := stream.Current().(llm.StreamFinishChunk); ok {
+			finish = append(finish, c)
+		}
+	}
+	assert.Equal(t, []llm.StreamFinishChunk{{Reason: llm.FinishReasonContentFilter, Refusal: "I can't help with that.", ID: "chatcmpl-2"}}, finish)
+	final, err := stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "I can't help with that.", final.Refusal)
+	assert.Equal(t, "chatcmpl-2", final.ID)
+}
+
+// TestOpenAICompletionModel_N tests that multiple choices are requested and returned, with the
+// usage of all of them
+func TestOpenAICompletionModel_N(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		choices := `{"index":0,"message":{"role":"assistant","content":"Paris"},"finish_reason":"stop"}`
+		if body["n"] == float64(2) {
+			choices += `,{"index":1,"message":{"role":"assistant","content":"The capital of France is"},"finish_reason":"length"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o-mini","choices":[`+choices+`],`+
+			`"usage":{"prompt_tokens":10,"completion_tokens":7,"total_tokens":17}}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "gpt-4o-mini", Name: "GPT-4o mini", Pricing: llm.ModelPricing{Prompt: llm.NewPrice(1), Completion: llm.NewPrice(2)}}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Capital of France?"}}}
+
+	model, err := provider.NewCompletionModel("gpt-4o-mini", llm.WithN(2), llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+	resp, err := model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), body["n"])
+	assert.Equal(t, "Paris", resp.Output)
+	assert.Equal(t, llm.FinishReasonStop, resp.FinishReason)
+	assert.Equal(t, []llm.CompletionChoice{
+		{Index: 0, Text: "Paris", FinishReason: llm.FinishReasonStop},
+		{Index: 1, Text: "The capital of France is", FinishReason: llm.FinishReasonLength},
+	}, resp.Choices)
+	assert.Equal(t, int64(7), resp.Usage.TotalOutputTokens, "output tokens of all choices")
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, (10*1+7*2)/1e6, *resp.Cost, 1e-12)
+
+	_, err = model.StreamComplete(context.Background(), req)
+	var unsupported *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &unsupported)
+
+	model, err = provider.NewCompletionModel("gpt-4o-mini", llm.WithN(1))
+	require.NoError(t, err)
+	resp, err = model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.NotContains(t, body, "n")
+	assert.Nil(t, resp.Choices)
+
+	model, err = provider.NewCompletionModel("gpt-4o-mini", llm.WithN(0))
+	require.NoError(t, err)
+	_, err = model.Complete(context.Background(), req)
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+// TestOpenAICompletionModel_Logprobs tests that token logprobs with their top alternatives are
+// returned by completions and sent as logprob chunks by streams
+func TestOpenAICompletionModel_Logprobs(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["stream"] != true {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":"Paris"},"finish_reason":"stop",`+
+				`"logprobs":{"content":[{"token":"Paris","logprob":-0.01,"bytes":[80,97,114,105,115],"top_logprobs":[{"token":"Paris","logprob":-0.01},{"token":"Lyon","logprob":-4.6}]}]}}]}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, token := range []string{"Par", "is"} {
+			fmt.Fprintf(w, "data: {\"id\":\"chatcmpl-2\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o-mini\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q},\"logprobs\":{\"content\":[{\"token\":%q,\"logprob\":-0.5,\"top_logprobs\":[]}]}}]}\n\n", token, token)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "gpt-4o-mini", Name: "GPT-4o mini"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("gpt-4o-mini", llm.WithTopLogprobs(2))
+	require.NoError(t, err)
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Capital of France?"}}}
+
+	resp, err := model.Complete(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, true, body["logprobs"])
+	assert.Equal(t, float64(2), body["top_logprobs"])
+	assert.Equal(t, []llm.TokenLogprob{{
+		Token:       "Paris",
+		Logprob:     -0.01,
+		TopLogprobs: []llm.TokenLogprob{{Token: "Paris", Logprob: -0.01}, {Token: "Lyon", Logprob: -4.6}},
+	}}, resp.Logprobs)
+	assert.InDelta(t, 0.99, resp.Logprobs[0].Probability(), 0.001)
+
+	stream, err := llm.StreamCompletion(context.Background(), model, req)
+	require.NoError(t, err)
+	var chunks []llm.StreamLogprobChunk
+	for stream.Next() {
+		if c, ok := stream.Current().(llm.StreamLogprobChunk); ok {
+			chunks = append(chunks, c)
+		}
+	}
+	assert.Equal(t, []llm.StreamLogprobChunk{
+		{Logprobs: []llm.TokenLogprob{{Token: "Par", Logprob: -0.5}}},
+		{Logprobs: []llm.TokenLogprob{{Token: "is", Logprob: -0.5}}},
+	}, chunks)
+	final, err := stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "Paris", final.Output)
+	assert.Len(t, final.Logprobs, 2)
+
+	model, err = provider.NewCompletionModel("gpt-4o-mini", llm.WithTopLogprobs(2), llm.WithStreamEvents(llm.TextChunkType))
+	require.NoError(t, err)
+	stream, err = llm.StreamCompletion(context.Background(), model, req)
+	require.NoError(t, err)
+	final, err = stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "Paris", final.Output)
+	assert.Nil(t, final.Logprobs)
+}
+
+// BenchmarkOpenAICompletionModel_Stream measures the per chunk cost of decoding a chat
+// completion event stream, from the HTTP body to the chunk channel, with plain text chunks and
+// with pooled text chunks released by the consumer
+func BenchmarkOpenAICompletionModel_Stream(b *testing.B) {
+	const chunks = 256
+	var body strings.Builder
+	for i := range chunks {
+		fmt.Fprintf(&body, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"created\":1718000000,\"model\":\"gpt-4o-mini\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"token%d \"}}]}\n\n", i%100)
+	}
+	body.WriteString("data: [DONE]\n\n")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, body.String())
+	}))
+	defer server.Close()
+
+	provider, err := NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "gpt-4o-mini", Name: "GPT-4o mini"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(b, err)
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+
+	for _, pooled := range []bool{false, true} {
+		b.Run(fmt.Sprintf("pooled=%t", pooled), func(b *testing.B) {
+			model, err := provider.NewCompletionModel("gpt-4o-mini", llm.WithPooledTextChunks(pooled))
+			require.NoError(b, err)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				stream, err := model.StreamComplete(context.Background(), req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for chunk := range stream {
+					if c, ok := chunk.(*llm.StreamTextBytesChunk); ok {
+						c.Release()
+					}
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*chunks), "ns/chunk")
+		})
+	}
+}
+
+func TestOpenAIModelProvider_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[
+			{"id":"gpt-4o-mini","object":"model","created":1721172741,"owned_by":"system"},
+			{"id":"gpt-9","object":"model","created":1790000000,"owned_by":"system"},
+			{"id":"text-embedding-4","object":"model","created":1790000000,"owned_by":"system"},
+			{"id":"whisper-2","object":"model","created":1790000000,"owned_by":"system"}]}`)
+	}))
+	defer server.Close()
+
+	models, err := getOpenAIModels()
+	require.NoError(t, err)
+	provider, err := NewBaseOpenAIModelProvider("openai", models, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	provider.SetModelRefresh(&llm.ModelRefreshOptions{}, provider)
+	require.NoError(t, provider.ModelCatalog().Refresh(context.Background()))
+
+	// Embedded models keep their capabilities and pricing
+	mini := provider.GetModelInfo("gpt-4o-mini")
+	require.NotNil(t, mini)
+	assert.Equal(t, "GPT-4o Mini", mini.Name)
+	assert.NotZero(t, mini.Pricing.Prompt.Float64())
+
+	// New models are usable with the capabilities inferred from their IDs
+	_, err = provider.NewCompletionModel("gpt-9")
+	assert.NoError(t, err)
+	_, err = provider.NewEmbeddingModel("text-embedding-4")
+	assert.NoError(t, err)
+	assert.Nil(t, provider.GetModelInfo("whisper-2"))
+}
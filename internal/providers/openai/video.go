@@ -0,0 +1,198 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+// videoDurations are the video lengths in seconds accepted by the videos API
+var videoDurations = map[float64]bool{4: true, 8: true, 12: true}
+
+// video is a video generation job of the videos API
+type video struct {
+	ID       string  `json:"id"`
+	Status   string  `json:"status"`
+	Progress float64 `json:"progress"`
+	Seconds  string  `json:"seconds"`
+	Error    *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// OpenAIVideoModel implements VideoModel with the videos API of Sora
+type OpenAIVideoModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	client    openai.Client
+	inflight  *llm.InFlight
+}
+
+var _ llm.VideoModel = (*OpenAIVideoModel)(nil)
+
+func NewOpenAIVideoModel(name string, modelInfo *llm.ModelInfo, client openai.Client) *OpenAIVideoModel {
+	return &OpenAIVideoModel{
+		name:      name,
+		modelInfo: modelInfo,
+		client:    client,
+	}
+}
+
+// GenerateVideo creates a video job, polls it until it completes and downloads the MP4. The
+// duration must be 4, 8 or 12 seconds; the frame rate cannot be chosen and URLs are not
+// available, so FPS and the URL response format are ignored. An image artifact is used as
+// the first frame.
+func (p *OpenAIVideoModel) GenerateVideo(ctx context.Context, req *llm.VideoRequest) (*llm.VideoResponse, error) {
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	if req.Instructions == "" {
+		return nil, llm.ErrEmptyInstructions
+	}
+	body, contentType, err := p.videoForm(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var job video
+	if err := p.client.Post(ctx, "videos", nil, &job, option.WithRequestBody(contentType, body)); err != nil {
+		return nil, fmt.Errorf("failed to create video: %w", err)
+	}
+
+	ticker := time.NewTicker(llm.VideoPollInterval(req.Config))
+	defer ticker.Stop()
+	for {
+		if req.OnProgress != nil {
+			progress := job.Progress / 100
+			req.OnProgress(&llm.VideoProgress{ID: job.ID, Status: job.Status, Progress: &progress})
+		}
+		switch job.Status {
+		case "completed":
+			return p.download(ctx, &job)
+		case "failed":
+			message := "video generation failed"
+			if job.Error != nil && job.Error.Message != "" {
+				message = job.Error.Message
+			}
+			return nil, llm.NewResponseError("openai", message, nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		if err := p.client.Get(ctx, "videos/"+job.ID, nil, &job); err != nil {
+			return nil, fmt.Errorf("failed to get video: %w", err)
+		}
+	}
+}
+
+// videoForm builds the multipart body of a video job
+func (p *OpenAIVideoModel) videoForm(req *llm.VideoRequest) ([]byte, string, error) {
+	model := req.Model
+	if model == "" {
+		model = p.modelInfo.ID
+	}
+
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	fields := [][2]string{{"model", model}, {"prompt", req.Instructions}}
+	if req.Config != nil {
+		if req.Config.Duration != 0 {
+			if !videoDurations[req.Config.Duration] {
+				return nil, "", llm.NewValidationError("duration", "must be 4, 8 or 12 seconds", req.Config.Duration)
+			}
+			fields = append(fields, [2]string{"seconds", strconv.Itoa(int(req.Config.Duration))})
+		}
+		if req.Config.Size != "" {
+			fields = append(fields, [2]string{"size", req.Config.Size})
+		}
+	}
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if len(req.Artifacts) > 0 {
+		artifact := req.Artifacts[0]
+		if len(artifact.Content) == 0 {
+			return nil, "", llm.NewValidationError("artifacts", "reference image must have content", artifact.Name)
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="input_reference"; filename=%q`, artifact.Name))
+		header.Set("Content-Type", artifact.ContentType)
+		part, err := form.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(artifact.Content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), form.FormDataContentType(), nil
+}
+
+// download fetches the content of a completed video job
+func (p *OpenAIVideoModel) download(ctx context.Context, job *video) (*llm.VideoResponse, error) {
+	var resp *http.Response
+	if err := p.client.Get(ctx, "videos/"+job.ID+"/content", nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to download video: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, fmt.Errorf("failed to read video: %w", err)
+	}
+	if buf.Len() == 0 {
+		return nil, llm.ErrEmptyContent
+	}
+
+	// Videos are billed per second of output
+	seconds, err := strconv.ParseFloat(job.Seconds, 64)
+	if err != nil {
+		return nil, llm.NewResponseError("openai", fmt.Sprintf("invalid video length %q", job.Seconds), err)
+	}
+	usage := &llm.TokenUsage{
+		TotalVideoSeconds: seconds,
+		TotalRequests:     1,
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = "video/mp4"
+	}
+
+	cost, breakdown := common.CalculateCostBreakdown(p.modelInfo, usage)
+	return &llm.VideoResponse{
+		Output:        buf.Bytes(),
+		ContentType:   contentType,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}, nil
+}
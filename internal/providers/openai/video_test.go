@@ -0,0 +1,113 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIVideoModel_GenerateVideo(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/videos":
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			assert.Equal(t, "sora-2", r.FormValue("model"))
+			assert.Equal(t, "A cat surfing", r.FormValue("prompt"))
+			assert.Equal(t, "8", r.FormValue("seconds"))
+			assert.Equal(t, "1280x720", r.FormValue("size"))
+			file, header, err := r.FormFile("input_reference")
+			require.NoError(t, err)
+			content, _ := io.ReadAll(file)
+			assert.Equal(t, "frame.png", header.Filename)
+			assert.Equal(t, "png", string(content))
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"video_1","status":"queued","progress":0,"seconds":"8"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/videos/video_1":
+			polls++
+			w.Header().Set("Content-Type", "application/json")
+			if polls == 1 {
+				fmt.Fprint(w, `{"id":"video_1","status":"in_progress","progress":50,"seconds":"8"}`)
+				return
+			}
+			fmt.Fprint(w, `{"id":"video_1","status":"completed","progress":100,"seconds":"8"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/videos/video_1/content":
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Write([]byte("mp4"))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	models, err := getOpenAIModels()
+	require.NoError(t, err)
+	provider, err := NewBaseOpenAIModelProvider("openai", models, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewVideoModel("sora-2")
+	require.NoError(t, err)
+
+	var progress []float64
+	resp, err := model.GenerateVideo(context.Background(), &llm.VideoRequest{
+		Instructions: "A cat surfing",
+		Artifacts:    []*llm.ModelArtifact{{Name: "frame.png", ContentType: "image/png", Content: []byte("png")}},
+		Config:       &llm.VideoModelConfig{Duration: 8, Size: "1280x720", PollInterval: time.Millisecond},
+		OnProgress: func(p *llm.VideoProgress) {
+			assert.Equal(t, "video_1", p.ID)
+			progress = append(progress, *p.Progress)
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []float64{0, 0.5, 1}, progress)
+	assert.Equal(t, []byte("mp4"), resp.Output)
+	assert.Equal(t, "video/mp4", resp.ContentType)
+	assert.Equal(t, 8.0, resp.Usage.TotalVideoSeconds)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 0.8, *resp.Cost, 1e-9)
+	require.NotNil(t, resp.CostBreakdown)
+	assert.Equal(t, llm.MicroCentsFromUSD(0.8), resp.CostBreakdown.Video)
+}
+
+func TestOpenAIVideoModel_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"video_1","status":"failed","error":{"code":"moderation_blocked","message":"blocked by moderation"}}`)
+	}))
+	defer server.Close()
+
+	models, err := getOpenAIModels()
+	require.NoError(t, err)
+	provider, err := NewBaseOpenAIModelProvider("openai", models, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+	})
+	require.NoError(t, err)
+
+	_, err = provider.NewVideoModel("gpt-4o")
+	assert.Error(t, err)
+
+	model, err := provider.NewVideoModel("sora-2")
+	require.NoError(t, err)
+	_, err = model.GenerateVideo(context.Background(), &llm.VideoRequest{Instructions: "A cat", Config: &llm.VideoModelConfig{Duration: 5}})
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	_, err = model.GenerateVideo(context.Background(), &llm.VideoRequest{Instructions: "A cat"})
+	assert.ErrorContains(t, err, "blocked by moderation")
+}
@@ -4,12 +4,13 @@
 package openrouter
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
-	"strconv"
+	"log/slog"
+	"strings"
 
 	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
 	"github.com/easyagent-dev/llm/internal/providers/openai"
 	"github.com/openai/openai-go/v3/option"
 )
@@ -64,7 +65,7 @@ func NewOpenRouterModelProvider(opts ...llm.ModelOption) (*OpenRouterModelProvid
 	// Append any custom options
 	requestOpts = append(requestOpts, config.Options...)
 
-	models, err := loadModels(config.APIKey)
+	models, err := loadModels(context.Background(), requestOpts, config.Logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load models: %w", err)
 	}
@@ -73,6 +74,18 @@ func NewOpenRouterModelProvider(opts ...llm.ModelOption) (*OpenRouterModelProvid
 	if err != nil {
 		return nil, err
 	}
+	openAIModelProvider.SetRetryOptions(config.Retry)
+	openAIModelProvider.SetCostTracker(config.CostTracker)
+	openAIModelProvider.SetRateLimit(config.RateLimit)
+	openAIModelProvider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	openAIModelProvider.SetLogging(config.Logger, config.Logging)
+	openAIModelProvider.SetPolicy(config.Policy, config.PolicyAudit)
+	openAIModelProvider.SetRegion(config.Region)
+	openAIModelProvider.SetCache(config.Cache, config.CacheOptions)
+	openAIModelProvider.SetPricing(config.Pricing)
+	openAIModelProvider.SetModelRefresh(config.ModelRefresh, llm.ModelSourceFunc(func(ctx context.Context) ([]*llm.ModelInfo, error) {
+		return loadModels(ctx, requestOpts, config.Logger)
+	}))
 
 	provider := &OpenRouterModelProvider{
 		OpenAIModelProvider: openAIModelProvider,
@@ -82,45 +95,29 @@ func NewOpenRouterModelProvider(opts ...llm.ModelOption) (*OpenRouterModelProvid
 	return provider, nil
 }
 
-// loadModels fetches all available models from OpenRouter API
-func loadModels(apiKey string) ([]*llm.ModelInfo, error) {
-	req, err := http.NewRequest("GET", "https://openrouter.ai/api/v1/models", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status: %d", resp.StatusCode)
-	}
-
+// loadModels fetches all available models from the OpenRouter API. Models with malformed
+// prices are skipped with a warning on logger, or slog.Default() when nil.
+func loadModels(ctx context.Context, requestOpts []option.RequestOption, logger *slog.Logger) ([]*llm.ModelInfo, error) {
 	var modelsResponse OpenRouterModelsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&modelsResponse); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := common.ListModels(ctx, "openrouter", requestOpts, &modelsResponse); err != nil {
+		return nil, err
 	}
+	return modelInfos(modelsResponse.Data, logger), nil
+}
 
+// modelInfos converts the models listed by OpenRouter, skipping those with malformed prices
+// so one bad entry does not fail the whole catalog
+func modelInfos(listed []OpenRouterModelInfo, logger *slog.Logger) []*llm.ModelInfo {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	var models []*llm.ModelInfo
-
-	for _, model := range modelsResponse.Data {
-		// Parse pricing strings to float64
-		pricing := llm.ModelPricing{
-			Prompt:            parsePrice(model.Pricing.Prompt),
-			Completion:        parsePrice(model.Pricing.Completion),
-			Request:           parsePrice(model.Pricing.Request),
-			Image:             parsePrice(model.Pricing.Image),
-			WebSearch:         parsePrice(model.Pricing.WebSearch),
-			InternalReasoning: parsePrice(model.Pricing.InternalReasoning),
-			InputCacheRead:    parsePrice(model.Pricing.InputCacheRead),
-			InputCacheWrite:   parsePrice(model.Pricing.InputCacheWrite),
+	for _, model := range listed {
+		pricing, err := model.Pricing.modelPricing()
+		if err != nil {
+			logger.Warn("skipping model with malformed pricing",
+				slog.String("provider", "openrouter"), slog.String("model", model.ID), slog.Any("error", err))
+			continue
 		}
 
 		modelInfo := &llm.ModelInfo{
@@ -130,18 +127,54 @@ func loadModels(apiKey string) ([]*llm.ModelInfo, error) {
 		}
 		models = append(models, modelInfo)
 	}
+	return models
+}
+
+// modelPricing converts the prices listed by OpenRouter, token prices being per token rather
+// than per million tokens. Models listed with zero token prices, such as the :free models, are
+// marked Free.
+func (p OpenRouterModelPricing) modelPricing() (llm.ModelPricing, error) {
+	var pricing llm.ModelPricing
+	var err error
+	prices := []struct {
+		price   string
+		perUnit bool
+		dst     *llm.Price
+	}{
+		{p.Prompt, false, &pricing.Prompt},
+		{p.Completion, false, &pricing.Completion},
+		{p.Request, true, &pricing.Request},
+		{p.Image, true, &pricing.Image},
+		{p.WebSearch, true, &pricing.WebSearch},
+		{p.InternalReasoning, false, &pricing.InternalReasoning},
+		{p.InputCacheRead, false, &pricing.InputCacheRead},
+		{p.InputCacheWrite, false, &pricing.InputCacheWrite},
+	}
+	for _, price := range prices {
+		if *price.dst, err = parsePrice(price.price, price.perUnit); err != nil {
+			return llm.ModelPricing{}, err
+		}
+	}
+	pricing.Free = pricing.IsZero() && listedZero(p.Prompt) && listedZero(p.Completion)
+	return pricing, nil
+}
 
-	return models, nil
+// listedZero reports whether OpenRouter lists price as zero, rather than leaving it out or
+// listing the negative price of a router
+func listedZero(price string) bool {
+	p, err := llm.ParsePrice(price)
+	return err == nil && p.IsZero()
 }
 
-// parsePrice converts a price string to float64, returning 0 if empty or invalid
-func parsePrice(price string) float64 {
-	if price == "" {
-		return 0.0
+// parsePrice parses a price listed by OpenRouter. Empty prices are zero, as are the negative
+// prices of routers such as openrouter/auto whose price depends on the model they pick.
+func parsePrice(price string, perUnit bool) (llm.Price, error) {
+	if price == "" || strings.HasPrefix(price, "-") {
+		return llm.Price{}, nil
 	}
-	val, err := strconv.ParseFloat(price, 64)
-	if err != nil {
-		return 0.0
+	if !perUnit {
+		// Shift the decimal point exactly to price a million tokens
+		price += "e6"
 	}
-	return val
+	return llm.ParsePrice(price)
 }
@@ -4,7 +4,9 @@
 package openrouter
 
 import (
+	"bytes"
 	"github.com/easyagent-dev/llm"
+	"log/slog"
 	"testing"
 
 	"github.com/openai/openai-go/v3/option"
@@ -159,3 +161,47 @@ func BenchmarkOpenRouterModel_SupportedModels(b *testing.B) {
 		_ = model.SupportedModels()
 	}
 }
+
+func TestOpenRouterModelPricing(t *testing.T) {
+	pricing, err := OpenRouterModelPricing{
+		Prompt:         "0.00000015",
+		Completion:     "0.0000006",
+		InputCacheRead: "0.000000075",
+		WebSearch:      "0.004",
+		Request:        "-1",
+	}.modelPricing()
+	require.NoError(t, err)
+	// Token prices are listed per token and converted to per million tokens
+	assert.Equal(t, llm.ModelPricing{
+		Prompt:         llm.NewPrice(0.15),
+		Completion:     llm.NewPrice(0.6),
+		InputCacheRead: llm.NewPrice(0.075),
+		WebSearch:      llm.NewPrice(0.004),
+	}, pricing)
+
+	// Zero prices are deliberate for :free models, but not for routers priced by the model
+	// they pick
+	pricing, err = OpenRouterModelPricing{Prompt: "0", Completion: "0"}.modelPricing()
+	require.NoError(t, err)
+	assert.True(t, pricing.Free)
+	assert.False(t, pricing.IsZero())
+	pricing, err = OpenRouterModelPricing{Prompt: "-1", Completion: "-1"}.modelPricing()
+	require.NoError(t, err)
+	assert.True(t, pricing.IsZero())
+
+	_, err = OpenRouterModelPricing{Prompt: "free"}.modelPricing()
+	assert.ErrorIs(t, err, llm.ErrInvalidPrice)
+}
+
+func TestModelInfos_SkipsMalformedPricing(t *testing.T) {
+	var buf bytes.Buffer
+	models := modelInfos([]OpenRouterModelInfo{
+		{ID: "acme/good", Name: "Good", Pricing: OpenRouterModelPricing{Prompt: "0.000001", Completion: "0.000002"}},
+		{ID: "acme/bad", Name: "Bad", Pricing: OpenRouterModelPricing{Prompt: "free"}},
+	}, slog.New(slog.NewTextHandler(&buf, nil)))
+
+	require.Len(t, models, 1)
+	assert.Equal(t, "acme/good", models[0].ID)
+	assert.Equal(t, llm.NewPrice(1), models[0].Pricing.Prompt)
+	assert.Contains(t, buf.String(), "model=acme/bad")
+}
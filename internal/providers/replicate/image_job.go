@@ -0,0 +1,105 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package replicate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/replicate/replicate-go"
+)
+
+var _ llm.ImageJobModel = (*ReplicateImageModel)(nil)
+
+// StartImage creates the prediction of req and returns it as a job, whose ID is the prediction
+// ID. The webhook of the model is not notified of jobs, which are polled.
+func (m *ReplicateImageModel) StartImage(ctx context.Context, req *llm.ImageRequest) (*llm.ImageJob, error) {
+	format, err := llm.ImageResponseFormat(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	input, err := imageInput(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, done, err := m.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	prediction, err := m.create(ctx, req.Model, input, req.Config, nil)
+	if err != nil {
+		return nil, err
+	}
+	return llm.NewImageJob(prediction.ID, &replicateImageJob{model: m, format: format}), nil
+}
+
+// ResumeImage returns the job of prediction id
+func (m *ReplicateImageModel) ResumeImage(ctx context.Context, id string, config *llm.ImageModelConfig) (*llm.ImageJob, error) {
+	if id == "" {
+		return nil, llm.NewValidationError("id", "cannot be empty", id)
+	}
+	format, err := llm.ImageResponseFormat(config)
+	if err != nil {
+		return nil, err
+	}
+	return llm.NewImageJob(id, &replicateImageJob{model: m, format: format}), nil
+}
+
+// replicateImageJob polls and cancels the predictions of image jobs
+type replicateImageJob struct {
+	model  *ReplicateImageModel
+	format string
+}
+
+func (j *replicateImageJob) PollImageJob(ctx context.Context, id string) (*llm.JobProgress, *llm.ImageResponse, error) {
+	prediction, err := j.model.client.GetPrediction(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get prediction: %w", err)
+	}
+	progress := &llm.JobProgress{ID: id, Status: jobStatus(prediction.Status)}
+	if p := prediction.Progress(); p != nil {
+		percent := p.Percentage * 100
+		progress.Percent = &percent
+	}
+	if prediction.Logs != nil {
+		progress.Logs = *prediction.Logs
+	}
+	if prediction.Error != nil {
+		progress.Error = fmt.Sprint(prediction.Error)
+	}
+	if progress.Status != llm.JobStatusSucceeded {
+		return progress, nil, nil
+	}
+	resp, err := j.model.response(ctx, prediction, j.format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return progress, resp, nil
+}
+
+func (j *replicateImageJob) CancelImageJob(ctx context.Context, id string) error {
+	if _, err := j.model.client.CancelPrediction(ctx, id); err != nil {
+		return fmt.Errorf("failed to cancel prediction: %w", err)
+	}
+	return nil
+}
+
+// jobStatus normalizes the status of a prediction
+func jobStatus(status replicate.Status) llm.JobStatus {
+	switch status {
+	case replicate.Starting:
+		return llm.JobStatusQueued
+	case replicate.Processing:
+		return llm.JobStatusRunning
+	case replicate.Succeeded:
+		return llm.JobStatusSucceeded
+	case replicate.Canceled:
+		return llm.JobStatusCanceled
+	}
+	return llm.JobStatusFailed
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/replicate/replicate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicateImageModel_Jobs(t *testing.T) {
+	var server *httptest.Server
+	polls := 0
+	canceled := false
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/predictions":
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Nil(t, body["webhook"])
+			fmt.Fprint(w, `{"id":"p1","status":"starting"}`)
+		case "/predictions/p1":
+			polls++
+			switch {
+			case canceled:
+				fmt.Fprint(w, `{"id":"p1","status":"canceled"}`)
+			case polls == 1:
+				fmt.Fprint(w, `{"id":"p1","status":"processing","logs":"Using seed: 7\n 40%|████      | 20/50 [00:04<00:06]\n"}`)
+			default:
+				fmt.Fprintf(w, `{"id":"p1","status":"succeeded","logs":"Using seed: 7\n","output":["%s/files/out.png"]}`, server.URL)
+			}
+		case "/predictions/p1/cancel":
+			canceled = true
+			fmt.Fprint(w, `{"id":"p1","status":"canceled"}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := NewReplicateImageModel("acme/image", &llm.ModelInfo{ID: "acme/image"}, client)
+	require.NoError(t, err)
+	config := &llm.ImageModelConfig{ResponseFormat: llm.ImageResponseFormatURL}
+
+	job, err := model.StartImage(context.Background(), &llm.ImageRequest{Model: "acme/image", Instructions: "A lighthouse", Config: config})
+	require.NoError(t, err)
+	assert.Equal(t, "p1", job.ID())
+	assert.Zero(t, polls, "the prediction is not waited for")
+
+	progress, resp, err := job.Poll(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+	assert.Equal(t, llm.JobStatusRunning, progress.Status)
+	require.NotNil(t, progress.Percent)
+	assert.InDelta(t, 40, *progress.Percent, 1e-9)
+	assert.Contains(t, progress.Logs, "20/50")
+
+	// After a restart, the job is resumed from its ID
+	resumed, err := model.ResumeImage(context.Background(), job.ID(), config)
+	require.NoError(t, err)
+	resp, err = resumed.Wait(context.Background(), time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/files/out.png", resp.Images[0].URL)
+	assert.Equal(t, int64(7), *resp.Images[0].Seed)
+
+	require.NoError(t, job.Cancel(context.Background()))
+	_, err = job.Wait(context.Background(), time.Millisecond)
+	assert.ErrorIs(t, err, llm.ErrJobCanceled)
+
+	_, err = model.ResumeImage(context.Background(), "", config)
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
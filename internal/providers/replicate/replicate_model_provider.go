@@ -5,19 +5,36 @@ package replicate
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
 	"github.com/replicate/replicate-go"
 	"io"
+	"maps"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// ReplicateModelProvider implements ImageModel interface for Replicate
+// webhookPollInterval is how often predictions are polled while waiting for their webhook
+// delivery, in case it is missed
+const webhookPollInterval = 15 * time.Second
+
+// ReplicateModelProvider provides image and video models hosted on Replicate
 type ReplicateModelProvider struct {
 	*llm.DefaultModelProvider
-	apiKey string
-	client *replicate.Client
+	apiKey  string
+	client  *replicate.Client
+	webhook *llm.Webhook
+	// httpClient sends the API requests and downloads the outputs
+	httpClient *http.Client
 }
 
 var _ llm.ModelProvider = (*ReplicateModelProvider)(nil)
@@ -30,7 +47,12 @@ func NewReplicateModelProvider(opts ...llm.ModelOption) (*ReplicateModelProvider
 		return nil, llm.ErrAPIKeyEmpty
 	}
 	// Create Replicate client
-	r8, err := replicate.NewClient(replicate.WithToken(apiKey))
+	httpClient := http.DefaultClient
+	clientOpts := []replicate.ClientOption{replicate.WithToken(apiKey), replicate.WithHTTPClient(httpClient)}
+	if config.BaseURL != "" {
+		clientOpts = append(clientOpts, replicate.WithBaseURL(config.BaseURL))
+	}
+	r8, err := replicate.NewClient(clientOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create replicate client: %w", err)
 	}
@@ -38,11 +60,23 @@ func NewReplicateModelProvider(opts ...llm.ModelOption) (*ReplicateModelProvider
 	models := loadModels(r8)
 
 	provider := llm.NewDefaultModelProvider("replicate", models)
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, nil)
 
 	return &ReplicateModelProvider{
 		DefaultModelProvider: provider,
 		apiKey:               apiKey,
 		client:               r8,
+		webhook:              config.Webhook,
+		httpClient:           httpClient,
 	}, nil
 }
 
@@ -69,10 +103,15 @@ func loadModels(client *replicate.Client) []*llm.ModelInfo {
 		}
 
 		modelInfo := &llm.ModelInfo{
-			ID:     modelID,
-			Name:   model.Name,
-			Input:  []llm.ModelMediaType{llm.ModelMediaTypeText},
-			Output: []llm.ModelMediaType{llm.ModelMediaTypeImage},
+			ID:           modelID,
+			Name:         model.Name,
+			Input:        []llm.ModelMediaType{llm.ModelMediaTypeText},
+			Output:       []llm.ModelMediaType{llm.ModelMediaTypeImage},
+			Capabilities: []llm.ModelCapability{llm.ModelCapabilityImage},
+		}
+		if model.LatestVersion != nil && hasInput(model.LatestVersion.OpenAPISchema, "image") {
+			modelInfo.Input = append(modelInfo.Input, llm.ModelMediaTypeImage)
+			modelInfo.Capabilities = append(modelInfo.Capabilities, llm.ModelCapabilityImageEdit)
 		}
 
 		models = append(models, modelInfo)
@@ -81,21 +120,124 @@ func loadModels(client *replicate.Client) []*llm.ModelInfo {
 	return models
 }
 
+// hasInput reports whether the OpenAPI schema of a model version has the input field name
+func hasInput(schema any, name string) bool {
+	keys := []string{"components", "schemas", "Input", "properties"}
+	for _, key := range keys {
+		m, ok := schema.(map[string]any)
+		if !ok {
+			return false
+		}
+		schema = m[key]
+	}
+	properties, ok := schema.(map[string]any)
+	if !ok {
+		return false
+	}
+	_, ok = properties[name]
+	return ok
+}
+
 func (p *ReplicateModelProvider) NewImageModel(model string) (llm.ImageModel, error) {
 	info := p.GetModelInfo(model)
 	if info == nil {
 		return nil, errors.New("model not found")
 	}
-	return NewReplicateImageModel(model, info, p.client)
+	if !info.HasCapability(llm.ModelCapabilityImage) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "image generation")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	imageModel, err := NewReplicateImageModel(model, info, p.client)
+	if err != nil {
+		return nil, err
+	}
+	imageModel.inflight = p.InFlight()
+	imageModel.webhook = p.webhook
+	imageModel.httpClient = p.httpClient
+	return p.EnforceImagePolicy(p.WrapImageModel(imageModel), info), nil
+}
+
+// NewImageEditModel creates an image model that also edits images and creates variations,
+// for inpainting models and models that take an image input
+func (p *ReplicateModelProvider) NewImageEditModel(model string) (llm.ImageEditModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, errors.New("model not found")
+	}
+	if !info.HasCapability(llm.ModelCapabilityImageEdit) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "image editing")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	imageModel, err := NewReplicateImageModel(model, info, p.client)
+	if err != nil {
+		return nil, err
+	}
+	imageModel.inflight = p.InFlight()
+	imageModel.webhook = p.webhook
+	imageModel.httpClient = p.httpClient
+	return p.EnforceImageEditPolicy(p.WrapImageEditModel(imageModel), info), nil
+}
+
+// NewImageJobModel creates an image model that also runs predictions as resumable jobs, for
+// models taking minutes
+func (p *ReplicateModelProvider) NewImageJobModel(model string) (llm.ImageJobModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, errors.New("model not found")
+	}
+	if !info.HasCapability(llm.ModelCapabilityImage) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "image generation")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	imageModel, err := NewReplicateImageModel(model, info, p.client)
+	if err != nil {
+		return nil, err
+	}
+	imageModel.inflight = p.InFlight()
+	imageModel.webhook = p.webhook
+	imageModel.httpClient = p.httpClient
+	return p.EnforceImageJobPolicy(p.WrapImageJobModel(imageModel), info), nil
+}
+
+func (p *ReplicateModelProvider) NewVideoModel(model string) (llm.VideoModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, errors.New("model not found")
+	}
+	if !info.HasCapability(llm.ModelCapabilityVideo) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "video generation")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	videoModel := NewReplicateVideoModel(model, info, p.client)
+	videoModel.inflight = p.InFlight()
+	videoModel.httpClient = p.httpClient
+	return p.EnforceVideoPolicy(p.WrapVideoModel(videoModel), info), nil
 }
 
-// ReplicateImageModel implements ImageModel interface
+// ReplicateImageModel implements the ImageModel and ImageEditModel interfaces
 type ReplicateImageModel struct {
 	name      string
 	modelInfo *llm.ModelInfo
 	client    *replicate.Client
+	inflight  *llm.InFlight
+	// webhook receives the completion of predictions when set, predictions then being polled
+	// every pollInterval in case a delivery is missed
+	webhook      *llm.Webhook
+	pollInterval time.Duration
+	// httpClient downloads the output images, http.DefaultClient when nil
+	httpClient *http.Client
 }
 
+var _ llm.ImageEditModel = (*ReplicateImageModel)(nil)
+
 func NewReplicateImageModel(name string, modelInfo *llm.ModelInfo, client *replicate.Client) (*ReplicateImageModel, error) {
 	return &ReplicateImageModel{
 		name:      name,
@@ -106,6 +248,15 @@ func NewReplicateImageModel(name string, modelInfo *llm.ModelInfo, client *repli
 
 // GenerateImage generates an image from a text prompt
 func (m *ReplicateImageModel) GenerateImage(ctx context.Context, req *llm.ImageRequest) (*llm.ImageResponse, error) {
+	input, err := imageInput(req)
+	if err != nil {
+		return nil, err
+	}
+	return m.predict(ctx, req.Model, input, req.Config)
+}
+
+// imageInput returns the prediction input of an image generation request
+func imageInput(req *llm.ImageRequest) (replicate.PredictionInput, error) {
 	if req.Instructions == "" {
 		return nil, llm.ErrEmptyInstructions
 	}
@@ -127,25 +278,106 @@ func (m *ReplicateImageModel) GenerateImage(ctx context.Context, req *llm.ImageR
 			input["style"] = req.Config.Style
 		}
 	}
+	maps.Copy(input, req.Inputs)
+	return input, nil
+}
 
-	// Get model from request
-	model := req.Model
-	if model == "" {
-		return nil, fmt.Errorf("model must be specified in request")
+// EditImage edits an image with an inpainting model, which receives the image, mask and
+// prompt as its image, mask and prompt inputs
+func (m *ReplicateImageModel) EditImage(ctx context.Context, req *llm.ImageEditRequest) (*llm.ImageResponse, error) {
+	if req.Instructions == "" {
+		return nil, llm.ErrEmptyInstructions
+	}
+	if err := common.ValidateImageInput("image", req.Image); err != nil {
+		return nil, err
 	}
+	input := replicate.PredictionInput{
+		"prompt": req.Instructions,
+		"image":  common.ArtifactURL(req.Image),
+	}
+	if req.Mask != nil {
+		if err := common.ValidateImageInput("mask", req.Mask); err != nil {
+			return nil, err
+		}
+		input["mask"] = common.ArtifactURL(req.Mask)
+	}
+	if req.Config != nil && req.Config.Size != "" {
+		input["size"] = req.Config.Size
+	}
+	maps.Copy(input, req.Inputs)
+	return m.predict(ctx, req.Model, input, req.Config)
+}
 
-	// Create prediction
-	prediction, err := m.client.CreatePrediction(ctx, model, input, nil, false)
+// CreateVariation creates variations of an image with a model that takes an image input
+// without a prompt
+func (m *ReplicateImageModel) CreateVariation(ctx context.Context, req *llm.ImageVariationRequest) (*llm.ImageResponse, error) {
+	if err := common.ValidateImageInput("image", req.Image); err != nil {
+		return nil, err
+	}
+	input := replicate.PredictionInput{
+		"image": common.ArtifactURL(req.Image),
+	}
+	if req.Config != nil && req.Config.Size != "" {
+		input["size"] = req.Config.Size
+	}
+	maps.Copy(input, req.Inputs)
+	return m.predict(ctx, req.Model, input, req.Config)
+}
+
+// predict runs model with input and delivers the output images in the format of config
+func (m *ReplicateImageModel) predict(ctx context.Context, model string, input replicate.PredictionInput, config *llm.ImageModelConfig) (*llm.ImageResponse, error) {
+	format, err := llm.ImageResponseFormat(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create prediction: %w", err)
+		return nil, err
+	}
+
+	ctx, done, err := m.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var webhook *replicate.Webhook
+	if m.webhook != nil {
+		webhook = &replicate.Webhook{URL: m.webhook.URL, Events: []replicate.WebhookEventType{replicate.WebhookEventCompleted}}
+	}
+	prediction, err := m.create(ctx, model, input, config, webhook)
+	if err != nil {
+		return nil, err
 	}
 
 	// Wait for completion
-	err = m.client.Wait(ctx, prediction)
+	if err := m.wait(ctx, prediction); err != nil {
+		return nil, err
+	}
+	return m.response(ctx, prediction, format)
+}
+
+// create creates a prediction of model with input, asking for the number of images of config
+// as num_outputs
+func (m *ReplicateImageModel) create(ctx context.Context, model string, input replicate.PredictionInput, config *llm.ImageModelConfig, webhook *replicate.Webhook) (*replicate.Prediction, error) {
+	n, err := llm.ImageCount(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to wait for prediction: %w", err)
+		return nil, err
+	}
+	if n > 1 {
+		input["num_outputs"] = n
 	}
 
+	// Get model from request
+	if model == "" {
+		return nil, fmt.Errorf("model must be specified in request")
+	}
+
+	prediction, err := m.client.CreatePrediction(ctx, model, input, webhook, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %w", err)
+	}
+	return prediction, nil
+}
+
+// response delivers the output images of a completed prediction in format
+func (m *ReplicateImageModel) response(ctx context.Context, prediction *replicate.Prediction, format string) (*llm.ImageResponse, error) {
 	// Check for errors in the prediction
 	if prediction.Error != nil {
 		return nil, fmt.Errorf("prediction failed: %v", prediction.Error)
@@ -156,49 +388,172 @@ func (m *ReplicateImageModel) GenerateImage(ctx context.Context, req *llm.ImageR
 		return nil, llm.ErrEmptyContent
 	}
 
-	// Extract URL from output
-	var url string
+	// Extract URLs from output
+	var urls []string
 	switch output := prediction.Output.(type) {
 	case string:
-		url = output
+		urls = []string{output}
 	case []interface{}:
 		if len(output) == 0 {
 			return nil, fmt.Errorf("empty output array")
 		}
-		var ok bool
-		url, ok = output[0].(string)
-		if !ok {
-			return nil, fmt.Errorf("unexpected output format: expected string in array")
+		for _, item := range output {
+			url, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected output format: expected string in array")
+			}
+			urls = append(urls, url)
 		}
 	default:
 		return nil, fmt.Errorf("unexpected output format: %T", output)
 	}
 
-	// Download the image
-	imageData, err := downloadURL(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to download image: %w", err)
-	}
-
 	// Create usage information
 	usage := &llm.TokenUsage{
-		TotalImages:   1,
+		TotalImages:   len(urls),
 		TotalRequests: 1,
 	}
 
-	// Note: Cost calculation would require model-specific pricing
-	// This can be implemented based on the model pricing in SupportedModels
+	var cost *float64
+	var breakdown *llm.CostBreakdown
+	if m.modelInfo != nil && !m.modelInfo.Pricing.Image.IsZero() {
+		cost, breakdown = common.CalculateCostBreakdown(m.modelInfo, usage)
+	}
 
-	return &llm.ImageResponse{
-		Output: imageData,
-		Usage:  usage,
-		Cost:   nil,
-	}, nil
+	seeds := predictionSeeds(prediction)
+	images := make([]llm.GeneratedImage, len(urls))
+	for i, url := range urls {
+		image := &images[i]
+		image.MimeType = imageContentType(url)
+		if i < len(seeds) {
+			image.Seed = &seeds[i]
+		}
+		if format == llm.ImageResponseFormatURL {
+			image.URL = url
+		} else {
+			// Download the image
+			var err error
+			if image.Data, err = downloadURL(ctx, m.httpClient, url); err != nil {
+				return nil, fmt.Errorf("failed to download image: %w", err)
+			}
+			if err := llm.DeliverImage(image, format); err != nil {
+				return nil, err
+			}
+		}
+	}
+	response := llm.NewImageResponse(images, usage, cost)
+	response.CostBreakdown = breakdown
+	return response, nil
+}
+
+// wait waits for prediction to complete, notified by the webhook when one is set and polling
+// otherwise. With a webhook, the prediction is still polled every pollInterval, so a missed
+// delivery does not block until ctx ends. The prediction is canceled when ctx ends first, so it
+// is no longer billed.
+func (m *ReplicateImageModel) wait(ctx context.Context, prediction *replicate.Prediction) error {
+	if prediction.Status.Terminated() {
+		return nil
+	}
+	var err error
+	if m.webhook != nil {
+		err = m.waitWebhook(ctx, prediction)
+	} else {
+		err = m.client.Wait(ctx, prediction)
+	}
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		_, _ = m.client.CancelPrediction(context.WithoutCancel(ctx), prediction.ID)
+		return ctx.Err()
+	}
+	return fmt.Errorf("failed to wait for prediction: %w", err)
+}
+
+// waitWebhook waits for the webhook delivery of prediction, polling it every pollInterval
+// until the delivery arrives or the prediction completes. Failed polls are retried at the next
+// interval.
+func (m *ReplicateImageModel) waitWebhook(ctx context.Context, prediction *replicate.Prediction) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type delivery struct {
+		payload []byte
+		err     error
+	}
+	delivered := make(chan delivery, 1)
+	go func() {
+		payload, err := m.webhook.Wait(ctx, prediction.ID)
+		delivered <- delivery{payload, err}
+	}()
+
+	interval := m.pollInterval
+	if interval <= 0 {
+		interval = webhookPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case d := <-delivered:
+			if d.err != nil {
+				return d.err
+			}
+			if err := json.Unmarshal(d.payload, prediction); err != nil {
+				return fmt.Errorf("failed to decode webhook prediction: %w", err)
+			}
+			return nil
+		case <-ticker.C:
+			polled, err := m.client.GetPrediction(ctx, prediction.ID)
+			if err == nil && polled.Status.Terminated() {
+				*prediction = *polled
+				return nil
+			}
+		}
+	}
+}
+
+// seedPattern matches a log line reporting a seed, e.g. "Using seed: 1234" or "seed=1234".
+// Only whole lines match, so a prompt echoed in the logs is not mistaken for a seed.
+var seedPattern = regexp.MustCompile(`(?im)^[ \t]*(?:using[ \t]+(?:random[ \t]+)?)?seed(?:[ \t]*[:=][ \t]*|[ \t]+)(\d+)[ \t]*\r?$`)
+
+// predictionSeeds returns the seeds reported in the logs of prediction, in output order.
+// Replicate has no seed field, but most image models log the seed of every output.
+func predictionSeeds(prediction *replicate.Prediction) []int64 {
+	if prediction.Logs == nil {
+		return nil
+	}
+	var seeds []int64
+	for _, match := range seedPattern.FindAllStringSubmatch(*prediction.Logs, -1) {
+		if seed, err := strconv.ParseInt(match[1], 10, 64); err == nil {
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds
 }
 
-// downloadURL downloads content from a URL and returns it as bytes
-func downloadURL(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+// imageContentType guesses the MIME type of an image from the extension of its URL, or
+// returns "" to detect it from the downloaded image
+func imageContentType(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if contentType := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(contentType, "image/") {
+			return contentType
+		}
+	}
+	return ""
+}
+
+// downloadURL downloads content from a URL with client, or http.DefaultClient when nil, and
+// returns it as bytes
+func downloadURL(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get URL: %w", err)
 	}
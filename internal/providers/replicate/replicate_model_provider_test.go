@@ -0,0 +1,341 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package replicate
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/replicate/replicate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestReplicateImageModel_ResponseFormats(t *testing.T) {
+	var server *httptest.Server
+	downloads := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predictions", "/predictions/p1":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id":"p1","status":"succeeded","output":["%s/files/out.png"]}`, server.URL)
+		case "/files/out.png":
+			downloads++
+			w.Write(pngImage)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := NewReplicateImageModel("acme/image", &llm.ModelInfo{ID: "acme/image"}, client)
+	require.NoError(t, err)
+	generate := func(format string) *llm.ImageResponse {
+		resp, err := model.GenerateImage(context.Background(), &llm.ImageRequest{
+			Model:        "acme/image",
+			Instructions: "A lighthouse",
+			Config:       &llm.ImageModelConfig{ResponseFormat: format},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", resp.ContentType)
+		return resp
+	}
+
+	t.Run("url", func(t *testing.T) {
+		resp := generate(llm.ImageResponseFormatURL)
+		assert.Equal(t, server.URL+"/files/out.png", resp.URL)
+		assert.Nil(t, resp.Output)
+		assert.Zero(t, downloads)
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		resp := generate("")
+		assert.Equal(t, pngImage, resp.Output)
+		// As with OpenAI, the URL is only reported when requested
+		assert.Empty(t, resp.URL)
+	})
+
+	t.Run("b64_json", func(t *testing.T) {
+		resp := generate("b64_json")
+		assert.Equal(t, pngImage, resp.Output)
+	})
+
+	t.Run("data uri", func(t *testing.T) {
+		resp := generate(llm.ImageResponseFormatDataURI)
+		assert.Nil(t, resp.Output)
+		assert.Equal(t, "data:image/png;base64,"+base64.StdEncoding.EncodeToString(pngImage), resp.DataURI)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		resp := generate(llm.ImageResponseFormatFile)
+		defer os.Remove(resp.Path)
+		assert.Nil(t, resp.Output)
+		assert.FileExists(t, resp.Path)
+		assert.Regexp(t, `\.png$`, resp.Path)
+		data, err := os.ReadFile(resp.Path)
+		require.NoError(t, err)
+		assert.Equal(t, pngImage, data)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := model.GenerateImage(context.Background(), &llm.ImageRequest{
+			Model:        "acme/image",
+			Instructions: "A lighthouse",
+			Config:       &llm.ImageModelConfig{ResponseFormat: "tiff"},
+		})
+		var validationErr *llm.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
+
+func TestReplicateImageModel_EditAndVariation(t *testing.T) {
+	var server *httptest.Server
+	var inputs []map[string]any
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/predictions":
+			var body struct {
+				Input map[string]any `json:"input"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			inputs = append(inputs, body.Input)
+			fallthrough
+		case "/predictions/p1":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id":"p1","status":"succeeded","logs":"Using seed: 1234\nUsing seed: 1235\n","output":["%[1]s/files/1.png","%[1]s/files/2.png"]}`, server.URL)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := NewReplicateImageModel("acme/inpaint", &llm.ModelInfo{ID: "acme/inpaint", Pricing: llm.ModelPricing{Image: llm.NewPrice(0.003)}}, client)
+	require.NoError(t, err)
+	image := &llm.ModelArtifact{Name: "photo.png", ContentType: "image/png", Content: pngImage}
+	config := &llm.ImageModelConfig{ResponseFormat: llm.ImageResponseFormatURL, Count: 2}
+
+	resp, err := model.EditImage(context.Background(), &llm.ImageEditRequest{
+		Model:        "acme/inpaint",
+		Instructions: "Add a hat",
+		Image:        image,
+		Mask:         &llm.ModelArtifact{ContentType: "image/png", URL: "https://example.com/mask.png"},
+		Config:       config,
+		Inputs:       map[string]any{"strength": 0.8},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/files/1.png", resp.URL)
+	require.Len(t, resp.Images, 2)
+	assert.Equal(t, server.URL+"/files/2.png", resp.Images[1].URL)
+	require.NotNil(t, resp.Images[1].Seed)
+	assert.Equal(t, int64(1235), *resp.Images[1].Seed)
+	assert.Equal(t, 2, resp.Usage.TotalImages)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 0.006, *resp.Cost, 1e-9)
+	assert.Equal(t, map[string]any{
+		"prompt":      "Add a hat",
+		"image":       "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngImage),
+		"mask":        "https://example.com/mask.png",
+		"strength":    0.8,
+		"num_outputs": float64(2),
+	}, inputs[0])
+
+	_, err = model.CreateVariation(context.Background(), &llm.ImageVariationRequest{
+		Model:  "acme/inpaint",
+		Image:  image,
+		Config: config,
+		Inputs: map[string]any{"seed": float64(7)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"image": inputs[0]["image"], "seed": float64(7), "num_outputs": float64(2)}, inputs[1])
+
+	_, err = model.EditImage(context.Background(), &llm.ImageEditRequest{Model: "acme/inpaint", Instructions: "Add a hat"})
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, inputs, 2)
+}
+
+func TestReplicateImageModel_WebhookAndInputs(t *testing.T) {
+	webhook, err := llm.NewWebhook("https://example.com/hooks/replicate", "")
+	require.NoError(t, err)
+	var server *httptest.Server
+	var body struct {
+		Input               map[string]any `json:"input"`
+		Webhook             string         `json:"webhook"`
+		WebhookEventsFilter []string       `json:"webhook_events_filter"`
+	}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/predictions" {
+			t.Errorf("unexpected request %s, predictions are not polled", r.URL.Path)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"p1","status":"starting"}`)
+
+		// Replicate notifies the webhook once the prediction completes
+		go func() {
+			payload := fmt.Sprintf(`{"id":"p1","status":"succeeded","output":["%s/files/out.png"]}`, server.URL)
+			webhook.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, webhook.URL, strings.NewReader(payload)))
+		}()
+	}))
+	defer server.Close()
+
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := NewReplicateImageModel("acme/image", &llm.ModelInfo{ID: "acme/image"}, client)
+	require.NoError(t, err)
+	model.webhook = webhook
+
+	resp, err := model.GenerateImage(context.Background(), &llm.ImageRequest{
+		Model:        "acme/image",
+		Instructions: "A lighthouse",
+		Config:       &llm.ImageModelConfig{Size: "1024x1024", ResponseFormat: llm.ImageResponseFormatURL},
+		Inputs:       map[string]any{"guidance_scale": 7.5, "negative_prompt": "fog", "size": "512x512"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/files/out.png", resp.Images[0].URL)
+	assert.Equal(t, map[string]any{
+		"prompt":          "A lighthouse",
+		"size":            "512x512",
+		"guidance_scale":  7.5,
+		"negative_prompt": "fog",
+	}, body.Input)
+	assert.Equal(t, webhook.URL, body.Webhook)
+	assert.Equal(t, []string{"completed"}, body.WebhookEventsFilter)
+}
+
+func TestReplicateImageModel_WebhookMissed(t *testing.T) {
+	webhook, err := llm.NewWebhook("https://example.com/hooks/replicate", "")
+	require.NoError(t, err)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/predictions":
+			fmt.Fprint(w, `{"id":"p1","status":"starting"}`)
+		case "/predictions/p1":
+			// The webhook is never notified, so the prediction is found complete by polling
+			fmt.Fprintf(w, `{"id":"p1","status":"succeeded","output":["%s/files/out.png"]}`, server.URL)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := NewReplicateImageModel("acme/image", &llm.ModelInfo{ID: "acme/image"}, client)
+	require.NoError(t, err)
+	model.webhook, model.pollInterval = webhook, 10*time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := model.GenerateImage(ctx, &llm.ImageRequest{
+		Model:        "acme/image",
+		Instructions: "A lighthouse",
+		Config:       &llm.ImageModelConfig{ResponseFormat: llm.ImageResponseFormatURL},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/files/out.png", resp.Images[0].URL)
+}
+
+func TestReplicateImageModel_CancelOnContextDone(t *testing.T) {
+	canceled := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/predictions", "/predictions/p1":
+			fmt.Fprint(w, `{"id":"p1","status":"processing"}`)
+		case "/predictions/p1/cancel":
+			canceled <- r.Method
+			fmt.Fprint(w, `{"id":"p1","status":"canceled"}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := NewReplicateImageModel("acme/image", &llm.ModelInfo{ID: "acme/image"}, client)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = model.GenerateImage(ctx, &llm.ImageRequest{Model: "acme/image", Instructions: "A lighthouse"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	select {
+	case method := <-canceled:
+		assert.Equal(t, http.MethodPost, method)
+	default:
+		t.Fatal("prediction was not canceled")
+	}
+}
+
+func TestPredictionSeeds(t *testing.T) {
+	tests := []struct {
+		logs string
+		want []int64
+	}{
+		{"Using seed: 1234\nUsing seed: 1235\n", []int64{1234, 1235}},
+		{"Using random seed 7\r\nseed=8\n", []int64{7, 8}},
+		{"prompt: a seed 5 growing into a tree\nSeed: 9 images left\n", nil},
+		{"Generating 2 images\n  seed: 42  \n", []int64{42}},
+	}
+	for _, tt := range tests {
+		logs := tt.logs
+		assert.Equal(t, tt.want, predictionSeeds(&replicate.Prediction{Logs: &logs}), tt.logs)
+	}
+	assert.Nil(t, predictionSeeds(&replicate.Prediction{}))
+}
+
+func TestLoadModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models", r.URL.Path)
+		fmt.Fprint(w, `{"results": [
+			{"owner": "acme", "name": "draw", "latest_version": {"id": "v1", "openapi_schema": {"components": {"schemas": {"Input": {"properties": {"prompt": {}}}}}}}},
+			{"owner": "acme", "name": "inpaint", "latest_version": {"id": "v2", "openapi_schema": {"components": {"schemas": {"Input": {"properties": {"prompt": {}, "image": {}, "mask": {}}}}}}}}
+		]}`)
+	}))
+	defer server.Close()
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+
+	models := loadModels(client)
+	require.Len(t, models, 2)
+	assert.Equal(t, []llm.ModelCapability{llm.ModelCapabilityImage}, models[0].Capabilities)
+	assert.Equal(t, []llm.ModelCapability{llm.ModelCapabilityImage, llm.ModelCapabilityImageEdit}, models[1].Capabilities)
+	assert.Equal(t, []llm.ModelMediaType{llm.ModelMediaTypeText, llm.ModelMediaTypeImage}, models[1].Input)
+}
+
+func TestReplicateModelProvider_NewImageEditModel(t *testing.T) {
+	provider := &ReplicateModelProvider{DefaultModelProvider: llm.NewDefaultModelProvider("replicate", []*llm.ModelInfo{
+		{ID: "acme/draw", Capabilities: []llm.ModelCapability{llm.ModelCapabilityImage}},
+		{ID: "acme/inpaint", Capabilities: []llm.ModelCapability{llm.ModelCapabilityImage, llm.ModelCapabilityImageEdit}},
+	})}
+
+	_, err := provider.NewImageEditModel("acme/draw")
+	var capabilityErr *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &capabilityErr)
+
+	_, err = provider.NewImageEditModel("acme/inpaint")
+	assert.NoError(t, err)
+	_, err = provider.NewImageModel("acme/inpaint")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,172 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package replicate
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/replicate/replicate-go"
+)
+
+// ReplicateVideoModel implements VideoModel interface
+type ReplicateVideoModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	client    *replicate.Client
+	inflight  *llm.InFlight
+	// httpClient downloads the output videos, http.DefaultClient when nil
+	httpClient *http.Client
+}
+
+var _ llm.VideoModel = (*ReplicateVideoModel)(nil)
+
+func NewReplicateVideoModel(name string, modelInfo *llm.ModelInfo, client *replicate.Client) *ReplicateVideoModel {
+	return &ReplicateVideoModel{
+		name:      name,
+		modelInfo: modelInfo,
+		client:    client,
+	}
+}
+
+// GenerateVideo runs a prediction of a video model and polls it until it finishes. The model
+// is a version ID or an owner/name pair. The config is passed as the duration, fps and size
+// inputs, and an image artifact as the image input. The prediction is canceled when ctx
+// ends first. Replicate bills by compute time, so the usage counts the requested duration
+// and no cost is reported.
+func (m *ReplicateVideoModel) GenerateVideo(ctx context.Context, req *llm.VideoRequest) (*llm.VideoResponse, error) {
+	if req == nil {
+		return nil, llm.NewValidationError("request", "cannot be nil", nil)
+	}
+	if req.Instructions == "" {
+		return nil, llm.ErrEmptyInstructions
+	}
+
+	input := replicate.PredictionInput{
+		"prompt": req.Instructions,
+	}
+	if req.Config != nil {
+		if req.Config.Duration != 0 {
+			input["duration"] = req.Config.Duration
+		}
+		if req.Config.FPS != 0 {
+			input["fps"] = req.Config.FPS
+		}
+		if req.Config.Size != "" {
+			input["size"] = req.Config.Size
+		}
+	}
+	if len(req.Artifacts) > 0 {
+		artifact := req.Artifacts[0]
+		if artifact.URL != "" {
+			input["image"] = artifact.URL
+		} else {
+			input["image"] = fmt.Sprintf("data:%s;base64,%s", artifact.ContentType, base64.StdEncoding.EncodeToString(artifact.Content))
+		}
+	}
+
+	model := req.Model
+	if model == "" {
+		model = m.name
+	}
+
+	ctx, done, err := m.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var prediction *replicate.Prediction
+	if owner, name, ok := strings.Cut(model, "/"); ok {
+		prediction, err = m.client.CreatePredictionWithModel(ctx, owner, name, input, nil, false)
+	} else {
+		prediction, err = m.client.CreatePrediction(ctx, model, input, nil, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %w", err)
+	}
+
+	ticker := time.NewTicker(llm.VideoPollInterval(req.Config))
+	defer ticker.Stop()
+	for {
+		if req.OnProgress != nil {
+			req.OnProgress(&llm.VideoProgress{ID: prediction.ID, Status: prediction.Status.String()})
+		}
+		if prediction.Status.Terminated() {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			// Stop the prediction so it is no longer billed
+			_, _ = m.client.CancelPrediction(context.WithoutCancel(ctx), prediction.ID)
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		if prediction, err = m.client.GetPrediction(ctx, prediction.ID); err != nil {
+			return nil, fmt.Errorf("failed to get prediction: %w", err)
+		}
+	}
+
+	if prediction.Status != replicate.Succeeded {
+		return nil, llm.NewResponseError("replicate", fmt.Sprintf("prediction %s: %v", prediction.Status, prediction.Error), nil)
+	}
+	videoURL, err := outputURL(prediction.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &llm.TokenUsage{TotalRequests: 1}
+	if req.Config != nil {
+		usage.TotalVideoSeconds = req.Config.Duration
+	}
+	resp := &llm.VideoResponse{
+		URL:         videoURL,
+		ContentType: videoContentType(videoURL),
+		Usage:       usage,
+	}
+	if req.Config != nil && req.Config.ResponseFormat == llm.VideoResponseFormatURL {
+		return resp, nil
+	}
+	if resp.Output, err = downloadURL(ctx, m.httpClient, videoURL); err != nil {
+		return nil, fmt.Errorf("failed to download video: %w", err)
+	}
+	return resp, nil
+}
+
+// outputURL returns the URL of a file output, the first one if the model returns several
+func outputURL(output replicate.PredictionOutput) (string, error) {
+	switch output := output.(type) {
+	case nil:
+		return "", llm.ErrEmptyContent
+	case string:
+		return output, nil
+	case []interface{}:
+		if len(output) == 0 {
+			return "", llm.ErrEmptyContent
+		}
+		if u, ok := output[0].(string); ok {
+			return u, nil
+		}
+	}
+	return "", fmt.Errorf("unexpected output format: %T", output)
+}
+
+// videoContentType guesses the MIME type of a video from the extension of its URL
+func videoContentType(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if contentType := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(contentType, "video/") {
+			return contentType
+		}
+	}
+	return "video/mp4"
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/replicate/replicate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicateVideoModel_GenerateVideo(t *testing.T) {
+	var server *httptest.Server
+	var input map[string]any
+	polls := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/models/acme/video/predictions":
+			var body struct {
+				Input map[string]any `json:"input"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			input = body.Input
+			fmt.Fprint(w, `{"id":"p1","status":"starting"}`)
+		case "/predictions/p1":
+			polls++
+			if polls == 1 {
+				fmt.Fprint(w, `{"id":"p1","status":"processing"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"id":"p1","status":"succeeded","output":"%s/files/out.webm"}`, server.URL)
+		case "/files/out.webm":
+			w.Write([]byte("webm"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model := NewReplicateVideoModel("acme/video", &llm.ModelInfo{ID: "acme/video"}, client)
+
+	var statuses []string
+	resp, err := model.GenerateVideo(context.Background(), &llm.VideoRequest{
+		Instructions: "A cat surfing",
+		Artifacts:    []*llm.ModelArtifact{{URL: "https://example.com/frame.png"}},
+		Config:       &llm.VideoModelConfig{Duration: 5, FPS: 24, PollInterval: time.Millisecond},
+		OnProgress: func(p *llm.VideoProgress) {
+			statuses = append(statuses, p.Status)
+			assert.Nil(t, p.Progress)
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"prompt": "A cat surfing", "duration": 5.0, "fps": 24.0, "image": "https://example.com/frame.png"}, input)
+	assert.Equal(t, []string{"starting", "processing", "succeeded"}, statuses)
+	assert.Equal(t, []byte("webm"), resp.Output)
+	assert.Equal(t, server.URL+"/files/out.webm", resp.URL)
+	assert.Equal(t, "video/webm", resp.ContentType)
+	assert.Equal(t, 5.0, resp.Usage.TotalVideoSeconds)
+}
+
+func TestReplicateVideoModel_Failed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"p1","status":"failed","error":"out of memory"}`)
+	}))
+	defer server.Close()
+
+	client, err := replicate.NewClient(replicate.WithToken("test-token"), replicate.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model := NewReplicateVideoModel("version-id", &llm.ModelInfo{ID: "version-id"}, client)
+
+	_, err = model.GenerateVideo(context.Background(), &llm.VideoRequest{Instructions: "A cat"})
+	assert.ErrorContains(t, err, "out of memory")
+
+	_, err = model.GenerateVideo(context.Background(), &llm.VideoRequest{})
+	assert.ErrorIs(t, err, llm.ErrEmptyInstructions)
+}
+
+func TestReplicateModelProvider_NewVideoModel(t *testing.T) {
+	provider := &ReplicateModelProvider{DefaultModelProvider: llm.NewDefaultModelProvider("replicate", []*llm.ModelInfo{
+		{ID: "acme/image", Capabilities: []llm.ModelCapability{llm.ModelCapabilityImage}},
+		{ID: "acme/video", Capabilities: []llm.ModelCapability{llm.ModelCapabilityVideo}},
+	})}
+
+	_, err := provider.NewVideoModel("acme/image")
+	var capabilityErr *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &capabilityErr)
+
+	_, err = provider.NewVideoModel("acme/video")
+	assert.NoError(t, err)
+}
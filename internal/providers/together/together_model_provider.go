@@ -0,0 +1,127 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package together
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+	"github.com/easyagent-dev/llm/internal/providers/openai"
+	"github.com/openai/openai-go/v3/option"
+)
+
+const defaultBaseURL = "https://api.together.xyz/v1/"
+
+// TogetherModelInfo is a model listed by the Together AI models endpoint
+type TogetherModelInfo struct {
+	ID            string               `json:"id"`
+	Type          string               `json:"type"`
+	DisplayName   string               `json:"display_name"`
+	ContextLength int                  `json:"context_length"`
+	Pricing       TogetherModelPricing `json:"pricing"`
+}
+
+// TogetherModelPricing is the price of a model in USD per million tokens
+type TogetherModelPricing struct {
+	Input  llm.Price `json:"input"`
+	Output llm.Price `json:"output"`
+}
+
+// TogetherModelProvider provides the open-weight chat and embedding models of Together AI
+// through its OpenAI compatible API. The models and their prices are discovered from the
+// models endpoint when the provider is created.
+type TogetherModelProvider struct {
+	*openai.OpenAIModelProvider
+}
+
+var _ llm.ModelProvider = (*TogetherModelProvider)(nil)
+
+func NewTogetherModelProvider(opts ...llm.ModelOption) (*TogetherModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+
+	// Build request options list
+	requestOpts := []option.RequestOption{}
+	requestOpts = append(requestOpts, option.WithAPIKey(config.APIKey))
+
+	// Set base URL (use default if not provided)
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	requestOpts = append(requestOpts, option.WithBaseURL(baseURL))
+
+	// Append any custom options
+	requestOpts = append(requestOpts, config.Options...)
+
+	models, err := loadModels(context.Background(), requestOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load models: %w", err)
+	}
+
+	// Create the completion model with Together's API endpoint
+	provider, err := openai.NewBaseOpenAIModelProvider("together", models, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, llm.ModelSourceFunc(func(ctx context.Context) ([]*llm.ModelInfo, error) {
+		return loadModels(ctx, requestOpts)
+	}))
+	provider.SetCompletionFields(config.CompletionFields)
+
+	return &TogetherModelProvider{
+		OpenAIModelProvider: provider,
+	}, nil
+}
+
+// loadModels fetches the chat and embedding models, with their prices, from the Together API
+// with the request options of the provider. Models of other types, such as image or rerank
+// models, are skipped.
+func loadModels(ctx context.Context, requestOpts []option.RequestOption) ([]*llm.ModelInfo, error) {
+	var listed []TogetherModelInfo
+	if err := common.ListModels(ctx, "together", requestOpts, &listed); err != nil {
+		return nil, err
+	}
+
+	models := make([]*llm.ModelInfo, 0, len(listed))
+	for _, model := range listed {
+		info := &llm.ModelInfo{
+			ID:            model.ID,
+			Name:          model.DisplayName,
+			ContextWindow: model.ContextLength,
+			Pricing: llm.ModelPricing{
+				Prompt:     model.Pricing.Input,
+				Completion: model.Pricing.Output,
+			},
+		}
+		if info.Name == "" {
+			info.Name = model.ID
+		}
+		switch model.Type {
+		case "chat":
+			info.Capabilities = []llm.ModelCapability{llm.ModelCapabilityCompletion}
+		case "embedding":
+			info.Capabilities = []llm.ModelCapability{llm.ModelCapabilityEmbedding}
+			info.Embedding = true
+		default:
+			continue
+		}
+		models = append(models, info)
+	}
+	return models, nil
+}
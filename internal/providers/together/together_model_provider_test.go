@@ -0,0 +1,96 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package together
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const modelsResponse = `[
+	{"id":"meta-llama/Llama-3.3-70B-Instruct-Turbo","object":"model","type":"chat","display_name":"Meta Llama 3.3 70B Instruct Turbo","context_length":131072,"pricing":{"hourly":0,"input":0.88,"output":0.88,"base":0,"finetune":0}},
+	{"id":"BAAI/bge-large-en-v1.5","object":"model","type":"embedding","display_name":"BAAI-Bge-Large-1p5","context_length":512,"pricing":{"input":0.02,"output":0.02}},
+	{"id":"black-forest-labs/FLUX.1-schnell","object":"model","type":"image","display_name":"FLUX.1 Schnell","pricing":{"base":0.0027}}
+]`
+
+func newServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-api-key", r.Header.Get("Authorization"))
+		if r.URL.Path == "/models" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, modelsResponse)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewTogetherModelProvider(t *testing.T) {
+	_, err := NewTogetherModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	server := newServer(t, http.NotFound)
+	provider, err := NewTogetherModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, "together", provider.Name())
+	require.Len(t, provider.SupportedModels(), 2, "image models are skipped")
+
+	info := provider.GetModelInfo("meta-llama/Llama-3.3-70B-Instruct-Turbo")
+	require.NotNil(t, info)
+	assert.Equal(t, "Meta Llama 3.3 70B Instruct Turbo", info.Name)
+	assert.Equal(t, 131072, info.ContextWindow)
+	assert.Equal(t, 0.88, info.Pricing.Prompt.Float64())
+	assert.Equal(t, 0.88, info.Pricing.Completion.Float64())
+
+	_, err = provider.NewCompletionModel("meta-llama/Llama-3.3-70B-Instruct-Turbo")
+	assert.NoError(t, err)
+	_, err = provider.NewEmbeddingModel("BAAI/bge-large-en-v1.5")
+	assert.NoError(t, err)
+	_, err = provider.NewEmbeddingModel("meta-llama/Llama-3.3-70B-Instruct-Turbo")
+	var capErr *llm.UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &capErr)
+}
+
+func TestNewTogetherModelProvider_DiscoveryFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid api key"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := NewTogetherModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	var reqErr *llm.RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusUnauthorized, reqErr.StatusCode)
+}
+
+func TestTogetherCompletionModel_Cost(t *testing.T) {
+	server := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"meta-llama/Llama-3.3-70B-Instruct-Turbo","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],`+
+			`"usage":{"prompt_tokens":1000000,"completion_tokens":500000,"total_tokens":1500000}}`)
+	})
+
+	provider, err := NewTogetherModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("meta-llama/Llama-3.3-70B-Instruct-Turbo", llm.WithUsage(true), llm.WithCost(true))
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Output)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 1.32, *resp.Cost, 1e-9)
+}
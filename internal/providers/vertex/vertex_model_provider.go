@@ -0,0 +1,334 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package vertex
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+	"github.com/easyagent-dev/llm/internal/providers/openai"
+	"github.com/openai/openai-go/v3/option"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+//go:embed vertex.json
+var vertexModels []byte
+
+const (
+	// defaultLocation is used when no location is configured
+	defaultLocation = "us-central1"
+	// cloudPlatformScope is the OAuth scope required by Vertex AI
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	// publisherPrefix is the publisher of Gemini models on the OpenAI compatible endpoint
+	publisherPrefix = "google/"
+)
+
+// VertexModelProvider provides Gemini models through Google Vertex AI. Completions use the
+// OpenAI compatible endpoint and embeddings the native predict endpoint, both authenticated
+// with Application Default Credentials or a service account key.
+type VertexModelProvider struct {
+	*openai.OpenAIModelProvider
+	httpClient *http.Client
+	baseURL    string
+}
+
+var _ llm.ModelProvider = (*VertexModelProvider)(nil)
+
+// NewVertexModelProvider creates a new Vertex AI model provider. Credentials set with
+// WithCredentialsJSON must be a service account key; otherwise Application Default
+// Credentials are used. The project defaults to the project of the credentials and the
+// location to us-central1.
+func NewVertexModelProvider(opts ...llm.ModelOption) (*VertexModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+	ctx := context.Background()
+
+	var tokenSource oauth2.TokenSource
+	project := config.Project
+	if len(config.CredentialsJSON) > 0 {
+		jwtConfig, err := google.JWTConfigFromJSON(config.CredentialsJSON, cloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account key: %w", err)
+		}
+		tokenSource = jwtConfig.TokenSource(ctx)
+		if project == "" {
+			var key struct {
+				ProjectID string `json:"project_id"`
+			}
+			if err := json.Unmarshal(config.CredentialsJSON, &key); err == nil {
+				project = key.ProjectID
+			}
+		}
+	} else {
+		creds, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find default credentials: %w", err)
+		}
+		tokenSource = creds.TokenSource
+		if project == "" {
+			project = creds.ProjectID
+		}
+	}
+
+	return newVertexModelProvider(config, project, tokenSource)
+}
+
+func newVertexModelProvider(config *llm.ModelOptions, project string, tokenSource oauth2.TokenSource) (*VertexModelProvider, error) {
+	if project == "" {
+		return nil, llm.ErrProjectEmpty
+	}
+	location := config.Location
+	if location == "" {
+		location = defaultLocation
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		host := "aiplatform.googleapis.com"
+		if location != "global" {
+			host = location + "-" + host
+		}
+		baseURL = fmt.Sprintf("https://%s/v1/projects/%s/locations/%s", host, project, location)
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	// The OAuth transport replaces the Authorization header on every request
+	httpClient := oauth2.NewClient(context.Background(), tokenSource)
+
+	requestOpts := []option.RequestOption{
+		option.WithBaseURL(baseURL + "/endpoints/openapi/"),
+		option.WithHTTPClient(httpClient),
+	}
+
+	// Append any custom options
+	requestOpts = append(requestOpts, config.Options...)
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(vertexModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	provider, err := openai.NewBaseOpenAIModelProvider("vertex", models, requestOpts)
+	if err != nil {
+		return nil, err
+	}
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	// Models are served from the configured location unless another region is set
+	provider.SetRegion(cmp.Or(config.Region, location))
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, nil)
+
+	return &VertexModelProvider{
+		OpenAIModelProvider: provider,
+		httpClient:          httpClient,
+		baseURL:             baseURL,
+	}, nil
+}
+
+// NewCompletionModel creates a Gemini completion model. Model IDs may omit the "google/"
+// publisher prefix.
+func (p *VertexModelProvider) NewCompletionModel(model string, opts ...llm.CompletionOption) (llm.CompletionModel, error) {
+	if p.GetModelInfo(model) == nil && p.GetModelInfo(publisherPrefix+model) != nil {
+		model = publisherPrefix + model
+	}
+	return p.OpenAIModelProvider.NewCompletionModel(model, opts...)
+}
+
+// NewEmbeddingModel creates an embedding model that uses the Vertex AI predict endpoint
+func (p *VertexModelProvider) NewEmbeddingModel(model string) (llm.EmbeddingModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityEmbedding) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "embeddings")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.EnforceEmbeddingPolicy(p.WrapEmbeddingModel(&VertexEmbeddingModel{
+		name:       model,
+		modelInfo:  info,
+		httpClient: p.httpClient,
+		baseURL:    p.baseURL,
+		lookup:     p.GetModelInfo,
+		inflight:   p.InFlight(),
+	}), info), nil
+}
+
+// VertexEmbeddingModel implements EmbeddingModel with the Vertex AI text embeddings API
+type VertexEmbeddingModel struct {
+	name       string
+	modelInfo  *llm.ModelInfo
+	httpClient *http.Client
+	baseURL    string
+	lookup     func(model string) *llm.ModelInfo
+	inflight   *llm.InFlight
+}
+
+var _ llm.EmbeddingModel = (*VertexEmbeddingModel)(nil)
+
+type predictRequest struct {
+	Instances  []predictInstance  `json:"instances"`
+	Parameters *predictParameters `json:"parameters,omitempty"`
+}
+
+type predictInstance struct {
+	Content  string `json:"content"`
+	TaskType string `json:"task_type,omitempty"`
+}
+
+type predictParameters struct {
+	OutputDimensionality int `json:"outputDimensionality,omitempty"`
+}
+
+type predictResponse struct {
+	Predictions []struct {
+		Embeddings struct {
+			Values     []float64 `json:"values"`
+			Statistics struct {
+				TokenCount int64 `json:"token_count"`
+			} `json:"statistics"`
+		} `json:"embeddings"`
+	} `json:"predictions"`
+}
+
+func (p *VertexEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	// Default to the model this instance was created for
+	req = common.DefaultEmbeddingModel(req, p.modelInfo.ID)
+
+	if err := common.ValidateEmbeddingRequest(req); err != nil {
+		return nil, err
+	}
+	if req.Config != nil && req.Config.EncodingFormat == llm.EmbeddingEncodingFormatBase64 {
+		return nil, llm.NewValidationError("encoding_format", "base64 is not supported by Vertex AI", req.Config.EncodingFormat)
+	}
+
+	body := predictRequest{Instances: make([]predictInstance, len(req.Contents))}
+	var taskType string
+	if req.Config != nil && req.Config.TaskType != "" {
+		if taskType = vertexTaskType(req.Config.TaskType); taskType == "" {
+			return nil, llm.NewValidationError("task_type", "is not supported by Vertex AI", req.Config.TaskType)
+		}
+	}
+	for i, content := range req.Contents {
+		body.Instances[i] = predictInstance{Content: content, TaskType: taskType}
+	}
+	if req.Config != nil && req.Config.Dimensions > 0 {
+		body.Parameters = &predictParameters{OutputDimensionality: req.Config.Dimensions}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	url := fmt.Sprintf("%s/publishers/google/models/%s:predict", p.baseURL, req.Model)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, &llm.RequestError{
+			Provider:   "vertex",
+			StatusCode: resp.StatusCode,
+			Message:    "failed to generate embeddings",
+			Err:        errors.New(strings.TrimSpace(string(respBody))),
+			RetryAfter: llm.ParseRetryAfter(resp.Header),
+		}
+	}
+
+	var predictions predictResponse
+	if err := json.Unmarshal(respBody, &predictions); err != nil {
+		return nil, llm.NewResponseError("vertex", "failed to parse embedding response", err)
+	}
+
+	embeddings := make([]llm.Embedding, len(predictions.Predictions))
+	usage := &llm.TokenUsage{TotalRequests: 1}
+	for i, prediction := range predictions.Predictions {
+		embeddings[i] = llm.Embedding{
+			Index:     i,
+			Embedding: prediction.Embeddings.Values,
+			Object:    "embedding",
+		}
+		usage.TotalInputTokens += prediction.Embeddings.Statistics.TokenCount
+	}
+
+	// Calculate cost using the pricing of the model actually requested
+	var cost *float64
+	var breakdown *llm.CostBreakdown
+	if modelInfo := p.requestedModelInfo(req.Model); modelInfo != nil {
+		cost, breakdown = common.CalculateCostBreakdown(modelInfo, usage)
+	}
+
+	return &llm.EmbeddingResponse{
+		Embeddings:    embeddings,
+		Usage:         usage,
+		Cost:          cost,
+		CostBreakdown: breakdown,
+	}, nil
+}
+
+// vertexTaskType maps a task type to its Vertex AI name. Without one Vertex AI embeds
+// texts as search queries.
+func vertexTaskType(taskType llm.EmbeddingTaskType) string {
+	switch taskType {
+	case llm.EmbeddingTaskSearchDocument:
+		return "RETRIEVAL_DOCUMENT"
+	case llm.EmbeddingTaskSearchQuery:
+		return "RETRIEVAL_QUERY"
+	case llm.EmbeddingTaskClassification:
+		return "CLASSIFICATION"
+	case llm.EmbeddingTaskClustering:
+		return "CLUSTERING"
+	case llm.EmbeddingTaskSemanticSimilarity:
+		return "SEMANTIC_SIMILARITY"
+	}
+	return ""
+}
+
+// requestedModelInfo returns the model info for the requested model, which may
+// differ from the model this instance was created for
+func (p *VertexEmbeddingModel) requestedModelInfo(model string) *llm.ModelInfo {
+	if model == p.name || model == p.modelInfo.ID {
+		return p.modelInfo
+	}
+	if p.lookup != nil {
+		return p.lookup(model)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package vertex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *VertexModelProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config := llm.ApplyOptions([]llm.ModelOption{llm.WithBaseURL(server.URL + "/v1/projects/p/locations/l")})
+	provider, err := newVertexModelProvider(config, "p", oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"}))
+	require.NoError(t, err)
+	return provider
+}
+
+func TestNewVertexModelProvider(t *testing.T) {
+	_, err := newVertexModelProvider(llm.ApplyOptions(nil), "", oauth2.StaticTokenSource(&oauth2.Token{}))
+	assert.ErrorIs(t, err, llm.ErrProjectEmpty)
+
+	_, err = NewVertexModelProvider(llm.WithCredentialsJSON([]byte(`{"type":"authorized_user"}`)))
+	assert.Error(t, err)
+
+	tests := []struct {
+		location string
+		wantURL  string
+	}{
+		{location: "", wantURL: "https://us-central1-aiplatform.googleapis.com/v1/projects/p/locations/us-central1"},
+		{location: "europe-west4", wantURL: "https://europe-west4-aiplatform.googleapis.com/v1/projects/p/locations/europe-west4"},
+		{location: "global", wantURL: "https://aiplatform.googleapis.com/v1/projects/p/locations/global"},
+	}
+	for _, tt := range tests {
+		provider, err := newVertexModelProvider(llm.ApplyOptions([]llm.ModelOption{llm.WithLocation(tt.location)}), "p",
+			oauth2.StaticTokenSource(&oauth2.Token{}))
+		require.NoError(t, err)
+		assert.Equal(t, "vertex", provider.Name())
+		assert.Equal(t, tt.wantURL, provider.baseURL)
+	}
+}
+
+func TestVertexCompletionModel_Complete(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/p/locations/l/endpoints/openapi/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		var params map[string]any
+		require.NoError(t, json.Unmarshal(body, &params))
+		assert.Equal(t, "google/gemini-2.5-flash", params["model"])
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"google/gemini-2.5-flash",
+			"choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`)
+	})
+
+	model, err := provider.NewCompletionModel("gemini-2.5-flash")
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Output)
+
+	_, err = provider.NewCompletionModel("text-embedding-004")
+	assert.Error(t, err)
+}
+
+func TestVertexEmbeddingModel_GenerateEmbeddings(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/projects/p/locations/l/publishers/google/models/text-embedding-004:predict", r.URL.Path)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		var body predictRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, []predictInstance{{Content: "a", TaskType: "RETRIEVAL_DOCUMENT"}, {Content: "b", TaskType: "RETRIEVAL_DOCUMENT"}}, body.Instances)
+		require.NotNil(t, body.Parameters)
+		assert.Equal(t, 2, body.Parameters.OutputDimensionality)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"predictions":[
+			{"embeddings":{"values":[0.1,0.2],"statistics":{"token_count":1,"truncated":false}}},
+			{"embeddings":{"values":[0.3,0.4],"statistics":{"token_count":2,"truncated":false}}}
+		]}`)
+	})
+
+	model, err := provider.NewEmbeddingModel("text-embedding-004")
+	require.NoError(t, err)
+
+	resp, err := model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"a", "b"},
+		Config:   &llm.EmbeddingModelConfig{Dimensions: 2, TaskType: llm.EmbeddingTaskSearchDocument},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Embeddings, 2)
+	assert.Equal(t, []float64{0.3, 0.4}, resp.Embeddings[1].Embedding)
+	assert.Equal(t, 1, resp.Embeddings[1].Index)
+	assert.Equal(t, int64(3), resp.Usage.TotalInputTokens)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 3*0.1/1e6, *resp.Cost, 1e-12)
+
+	_, err = model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"a"},
+		Config:   &llm.EmbeddingModelConfig{TaskType: "summarization"},
+	})
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	_, err = provider.NewEmbeddingModel("gemini-2.5-flash")
+	assert.ErrorIs(t, err, llm.ErrInvalidModel)
+}
+
+func TestVertexEmbeddingModel_RequestError(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"code":429,"message":"Quota exceeded"}}`)
+	})
+
+	model, err := provider.NewEmbeddingModel("text-embedding-004")
+	require.NoError(t, err)
+
+	_, err = model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{Contents: []string{"a"}})
+	var reqErr *llm.RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusTooManyRequests, reqErr.StatusCode)
+	assert.Equal(t, "vertex", reqErr.Provider)
+	assert.Equal(t, "3s", reqErr.RetryAfter.String())
+	assert.ErrorContains(t, err, "Quota exceeded")
+}
@@ -0,0 +1,227 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package voyage
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/common"
+)
+
+//go:embed voyage.json
+var voyageModels []byte
+
+const defaultBaseURL = "https://api.voyageai.com"
+
+// VoyageModelProvider provides Voyage AI embedding and rerank models
+type VoyageModelProvider struct {
+	*llm.DefaultModelProvider
+	client *common.APIClient
+}
+
+var _ llm.ModelProvider = (*VoyageModelProvider)(nil)
+
+// NewVoyageModelProvider creates a new Voyage AI model provider
+func NewVoyageModelProvider(opts ...llm.ModelOption) (*VoyageModelProvider, error) {
+	config := llm.ApplyOptions(opts)
+	if config.APIKey == "" {
+		return nil, llm.ErrAPIKeyEmpty
+	}
+
+	var models []*llm.ModelInfo
+	if err := json.Unmarshal(voyageModels, &models); err != nil {
+		return nil, errors.New("failed to read model info")
+	}
+
+	provider := llm.NewDefaultModelProvider("voyage", models)
+	provider.SetRetryOptions(config.Retry)
+	provider.SetCostTracker(config.CostTracker)
+	provider.SetRateLimit(config.RateLimit)
+	provider.SetDeprecationPolicy(config.Logger, config.StrictDeprecation)
+	provider.SetLogging(config.Logger, config.Logging)
+	provider.SetPolicy(config.Policy, config.PolicyAudit)
+	provider.SetRegion(config.Region)
+	provider.SetCache(config.Cache, config.CacheOptions)
+	provider.SetPricing(config.Pricing)
+	provider.SetModelRefresh(config.ModelRefresh, nil)
+
+	return &VoyageModelProvider{
+		DefaultModelProvider: provider,
+		client:               common.NewAPIClient("voyage", config.BaseURL, defaultBaseURL, config.APIKey),
+	}, nil
+}
+
+func (p *VoyageModelProvider) NewEmbeddingModel(model string) (llm.EmbeddingModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityEmbedding) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "embeddings")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.EnforceEmbeddingPolicy(p.WrapEmbeddingModel(&VoyageEmbeddingModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		inflight:  p.InFlight(),
+	}), info), nil
+}
+
+func (p *VoyageModelProvider) NewRerankModel(model string) (llm.RerankModel, error) {
+	info := p.GetModelInfo(model)
+	if info == nil {
+		return nil, llm.ErrInvalidModel
+	}
+	if !info.HasCapability(llm.ModelCapabilityRerank) {
+		return nil, llm.NewUnsupportedCapabilityError(p.Name(), "reranking")
+	}
+	if err := p.CheckModel(info); err != nil {
+		return nil, err
+	}
+	return p.EnforceRerankPolicy(p.WrapRerankModel(&VoyageRerankModel{
+		name:      model,
+		modelInfo: info,
+		provider:  p,
+		inflight:  p.InFlight(),
+	}), info), nil
+}
+
+// VoyageEmbeddingModel implements EmbeddingModel with the Voyage Embeddings API
+type VoyageEmbeddingModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *VoyageModelProvider
+	inflight  *llm.InFlight
+}
+
+var _ llm.EmbeddingModel = (*VoyageEmbeddingModel)(nil)
+
+type embedRequest struct {
+	Model           string   `json:"model"`
+	Input           []string `json:"input"`
+	InputType       string   `json:"input_type,omitempty"`
+	OutputDimension int      `json:"output_dimension,omitempty"`
+	OutputDtype     string   `json:"output_dtype"`
+}
+
+// GenerateEmbeddings embeds req.Contents. Search task types are sent as the query or document
+// input type; other task types use the general purpose embeddings.
+func (p *VoyageEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	// Default to the model this instance was created for
+	req = common.DefaultEmbeddingModel(req, p.modelInfo.ID)
+
+	if err := common.ValidateEmbeddingRequest(req); err != nil {
+		return nil, err
+	}
+	body := embedRequest{
+		Model:       req.Model,
+		Input:       req.Contents,
+		OutputDtype: "float",
+	}
+	if req.Config != nil {
+		if req.Config.EncodingFormat == llm.EmbeddingEncodingFormatBase64 {
+			return nil, llm.NewValidationError("encoding_format", "base64 is not supported by Voyage", req.Config.EncodingFormat)
+		}
+		var ok bool
+		if body.InputType, ok = inputType(req.Config.TaskType); !ok {
+			return nil, llm.NewValidationError("task_type", "is not supported by Voyage", req.Config.TaskType)
+		}
+		body.OutputDimension = req.Config.Dimensions
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var embed common.EmbeddingsResponse
+	if err := p.provider.client.PostJSON(ctx, "/v1/embeddings", body, &embed, "failed to generate embeddings"); err != nil {
+		return nil, err
+	}
+	return embed.EmbeddingResponse(p.provider.GetModelInfo(req.Model)), nil
+}
+
+// inputType maps a task type to a Voyage input type, which is empty for tasks other than
+// search. It reports false for unknown task types.
+func inputType(taskType llm.EmbeddingTaskType) (string, bool) {
+	switch taskType {
+	case llm.EmbeddingTaskSearchDocument:
+		return "document", true
+	case llm.EmbeddingTaskSearchQuery:
+		return "query", true
+	case "", llm.EmbeddingTaskClassification, llm.EmbeddingTaskClustering, llm.EmbeddingTaskSemanticSimilarity:
+		return "", true
+	}
+	return "", false
+}
+
+// VoyageRerankModel implements RerankModel with the Voyage Rerank API
+type VoyageRerankModel struct {
+	name      string
+	modelInfo *llm.ModelInfo
+	provider  *VoyageModelProvider
+	inflight  *llm.InFlight
+}
+
+var _ llm.RerankModel = (*VoyageRerankModel)(nil)
+
+type rerankRequest struct {
+	Model           string   `json:"model"`
+	Query           string   `json:"query"`
+	Documents       []string `json:"documents"`
+	TopK            int      `json:"top_k,omitempty"`
+	ReturnDocuments bool     `json:"return_documents"`
+}
+
+type rerankResponse struct {
+	Data  []common.ScoredDocument `json:"data"`
+	Usage common.TotalTokens      `json:"usage"`
+}
+
+// Rerank orders req.Documents by relevance to req.Query. Voyage bills the tokens of the query and
+// documents at the model's prompt price.
+func (p *VoyageRerankModel) Rerank(ctx context.Context, req *llm.RerankRequest) (*llm.RerankResponse, error) {
+	if err := common.ValidateRerankRequest(req); err != nil {
+		return nil, err
+	}
+	model := req.Model
+	if model == "" {
+		model = p.modelInfo.ID
+	}
+
+	ctx, done, err := p.inflight.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var rerank rerankResponse
+	if err := p.provider.client.PostJSON(ctx, "/v1/rerank", rerankRequest{
+		Model:     model,
+		Query:     req.Query,
+		Documents: req.Documents,
+		TopK:      req.TopN,
+	}, &rerank, "failed to rerank documents"); err != nil {
+		return nil, err
+	}
+	results, err := common.RerankResults("voyage", req, rerank.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := rerank.Usage.Usage()
+	return &llm.RerankResponse{
+		Results: results,
+		Usage:   usage,
+		Cost:    common.CalculateCost(p.provider.GetModelInfo(model), usage),
+	}, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package voyage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *VoyageModelProvider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	provider, err := NewVoyageModelProvider(llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	return provider
+}
+
+func TestNewVoyageModelProvider(t *testing.T) {
+	_, err := NewVoyageModelProvider()
+	assert.ErrorIs(t, err, llm.ErrAPIKeyEmpty)
+
+	provider, err := NewVoyageModelProvider(llm.WithAPIKey("test-api-key"))
+	require.NoError(t, err)
+	assert.Equal(t, "voyage", provider.Name())
+
+	_, err = provider.NewRerankModel("unknown-model")
+	assert.ErrorIs(t, err, llm.ErrInvalidModel)
+	var capErr *llm.UnsupportedCapabilityError
+	_, err = provider.NewRerankModel("voyage-3.5")
+	assert.ErrorAs(t, err, &capErr)
+}
+
+func TestVoyageEmbeddingModel_GenerateEmbeddings(t *testing.T) {
+	var inputTypes []string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer test-api-key", r.Header.Get("Authorization"))
+		var body embedRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "voyage-3.5", body.Model)
+		assert.Equal(t, "float", body.OutputDtype)
+		assert.Equal(t, 256, body.OutputDimension)
+		inputTypes = append(inputTypes, body.InputType)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"object":"embedding","embedding":[0.1,0.2],"index":0}],"model":"voyage-3.5","usage":{"total_tokens":3}}`)
+	})
+
+	model, err := provider.NewEmbeddingModel("voyage-3.5")
+	require.NoError(t, err)
+
+	for _, taskType := range []llm.EmbeddingTaskType{llm.EmbeddingTaskSearchDocument, llm.EmbeddingTaskSearchQuery, llm.EmbeddingTaskClustering} {
+		req := &llm.EmbeddingRequest{
+			Contents: []string{"a"},
+			Config:   &llm.EmbeddingModelConfig{TaskType: taskType, Dimensions: 256},
+		}
+		resp, err := model.GenerateEmbeddings(context.Background(), req)
+		require.NoError(t, err)
+		assert.Empty(t, req.Model, "the request of the caller is not modified")
+		assert.Equal(t, []float64{0.1, 0.2}, resp.Embeddings[0].Embedding)
+		require.NotNil(t, resp.Cost)
+		assert.InDelta(t, 3*0.06/1e6, *resp.Cost, 1e-12)
+		require.NotNil(t, resp.CostBreakdown)
+		assert.Equal(t, llm.MicroCentsFromUSD(*resp.Cost), resp.CostBreakdown.Input)
+	}
+	assert.Equal(t, []string{"document", "query", ""}, inputTypes)
+
+	_, err = model.GenerateEmbeddings(context.Background(), &llm.EmbeddingRequest{
+		Contents: []string{"a"},
+		Config:   &llm.EmbeddingModelConfig{TaskType: "summarization"},
+	})
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestVoyageRerankModel_Rerank(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/rerank", r.URL.Path)
+		var body rerankRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, rerankRequest{Model: "rerank-2.5", Query: "capital of France", Documents: []string{"Berlin", "Paris"}, TopK: 1}, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"relevance_score":0.87,"index":1}],"model":"rerank-2.5","usage":{"total_tokens":12}}`)
+	})
+
+	model, err := provider.NewRerankModel("rerank-2.5")
+	require.NoError(t, err)
+
+	resp, err := model.Rerank(context.Background(), &llm.RerankRequest{
+		Query:     "capital of France",
+		Documents: []string{"Berlin", "Paris"},
+		TopN:      1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []llm.RerankResult{{Index: 1, Document: "Paris", RelevanceScore: 0.87}}, resp.Results)
+	require.NotNil(t, resp.Cost)
+	assert.InDelta(t, 12*0.05/1e6, *resp.Cost, 1e-12)
+}
+
+func TestVoyageRerankModel_OutOfRange(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[{"relevance_score":0.5,"index":3}],"usage":{"total_tokens":1}}`)
+	})
+
+	model, err := provider.NewRerankModel("rerank-2.5-lite")
+	require.NoError(t, err)
+	_, err = model.Rerank(context.Background(), &llm.RerankRequest{Query: "q", Documents: []string{"d"}})
+	var respErr *llm.ResponseError
+	assert.ErrorAs(t, err, &respErr)
+}
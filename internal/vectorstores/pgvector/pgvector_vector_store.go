@@ -0,0 +1,183 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package pgvector
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+)
+
+// PgVectorStore implements VectorStore with a PostgreSQL table using the pgvector extension.
+// The table is created with:
+//
+//	CREATE EXTENSION IF NOT EXISTS vector;
+//	CREATE TABLE documents (
+//		id text PRIMARY KEY,
+//		content text NOT NULL,
+//		metadata jsonb NOT NULL DEFAULT '{}',
+//		embedding vector(1536) NOT NULL
+//	);
+//	CREATE INDEX ON documents USING hnsw (embedding vector_cosine_ops);
+//
+// Records are compared by cosine distance, and filters match metadata by JSON containment.
+type PgVectorStore struct {
+	db    *sql.DB
+	table string
+}
+
+var _ llm.VectorStore = (*PgVectorStore)(nil)
+
+// NewPgVectorStore creates a store for table, which may be qualified with its schema, in db.
+// db is opened by the caller with a PostgreSQL driver such as pgx or lib/pq.
+func NewPgVectorStore(db *sql.DB, table string) (*PgVectorStore, error) {
+	if db == nil {
+		return nil, llm.NewValidationError("db", "cannot be nil", nil)
+	}
+	if table == "" {
+		return nil, llm.NewValidationError("table", "cannot be empty", nil)
+	}
+	return &PgVectorStore{db: db, table: quoteIdentifier(table)}, nil
+}
+
+func (s *PgVectorStore) Upsert(ctx context.Context, records []*llm.VectorRecord) error {
+	if err := llm.ValidateVectorRecords(records); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO `+s.table+` (id, content, metadata, embedding)
+VALUES ($1, $2, $3::jsonb, $4::vector)
+ON CONFLICT (id) DO UPDATE SET content = EXCLUDED.content, metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare upsert: %w", err)
+	}
+	defer stmt.Close()
+	for _, record := range records {
+		metadata, err := marshalMetadata(record.Metadata)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, record.ID, record.Content, metadata, vectorLiteral(record.Vector)); err != nil {
+			return fmt.Errorf("failed to upsert record %s: %w", record.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit upsert: %w", err)
+	}
+	return nil
+}
+
+func (s *PgVectorStore) Query(ctx context.Context, query *llm.VectorQuery) ([]*llm.VectorMatch, error) {
+	if err := llm.ValidateVectorQuery(query); err != nil {
+		return nil, err
+	}
+	filter, err := marshalMetadata(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT id, content, metadata, 1 - (embedding <=> $1::vector) AS score
+FROM `+s.table+`
+WHERE metadata @> $2::jsonb
+ORDER BY embedding <=> $1::vector
+LIMIT $3`, vectorLiteral(query.Vector), filter, query.Limit())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query records: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []*llm.VectorMatch
+	for rows.Next() {
+		record := &llm.VectorRecord{}
+		var metadata []byte
+		var score float64
+		if err := rows.Scan(&record.ID, &record.Content, &metadata, &score); err != nil {
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &record.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to parse metadata of record %s: %w", record.ID, err)
+			}
+			if len(record.Metadata) == 0 {
+				record.Metadata = nil
+			}
+		}
+		matches = append(matches, &llm.VectorMatch{Record: record, Score: score})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read records: %w", err)
+	}
+	return matches, nil
+}
+
+func (s *PgVectorStore) Delete(ctx context.Context, ids []string, filter llm.VectorFilter) error {
+	if err := llm.ValidateVectorDelete(ids, filter); err != nil {
+		return err
+	}
+	metadata, err := marshalMetadata(filter)
+	if err != nil {
+		return err
+	}
+	query := `DELETE FROM ` + s.table + ` WHERE metadata @> $1::jsonb`
+	args := []any{metadata}
+	if ids != nil {
+		placeholders := make([]string, len(ids))
+		for i, id := range ids {
+			args = append(args, id)
+			placeholders[i] = "$" + strconv.Itoa(len(args))
+		}
+		query += ` AND id IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete records: %w", err)
+	}
+	return nil
+}
+
+// marshalMetadata encodes metadata as a JSON object, "{}" when empty
+func marshalMetadata(metadata map[string]any) (string, error) {
+	if len(metadata) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// vectorLiteral formats vector in the text representation of pgvector, e.g. [0.1,0.2]
+func vectorLiteral(vector []float64) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(v, 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// quoteIdentifier quotes every part of a possibly schema qualified table name
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,196 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package pgvector
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver records the statements it runs and answers queries with rows
+type fakeDriver struct {
+	mu        sync.Mutex
+	execs     []fakeStatement
+	rows      [][]driver.Value
+	committed bool
+	execErr   error
+}
+
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{driver: c.driver, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{driver: c.driver}, nil
+}
+
+type fakeTx struct {
+	driver *fakeDriver
+}
+
+func (t *fakeTx) Commit() error {
+	t.driver.mu.Lock()
+	defer t.driver.mu.Unlock()
+	t.driver.committed = true
+	return nil
+}
+
+func (t *fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	driver *fakeDriver
+	query  string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.mu.Lock()
+	defer s.driver.mu.Unlock()
+	if s.driver.execErr != nil {
+		return nil, s.driver.execErr
+	}
+	s.driver.execs = append(s.driver.execs, fakeStatement{query: s.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.mu.Lock()
+	defer s.driver.mu.Unlock()
+	s.driver.execs = append(s.driver.execs, fakeStatement{query: s.query, args: args})
+	return &fakeRows{rows: s.driver.rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id", "content", "metadata", "score"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func newTestStore(t *testing.T, table string) (*PgVectorStore, *fakeDriver) {
+	fake := &fakeDriver{}
+	db := sql.OpenDB(connector{fake})
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewPgVectorStore(db, table)
+	require.NoError(t, err)
+	return store, fake
+}
+
+type connector struct {
+	driver *fakeDriver
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open("") }
+func (c connector) Driver() driver.Driver                        { return c.driver }
+
+// normalize collapses the whitespace of a query
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func TestNewPgVectorStore(t *testing.T) {
+	var validationErr *llm.ValidationError
+	_, err := NewPgVectorStore(nil, "documents")
+	assert.ErrorAs(t, err, &validationErr)
+
+	store, _ := newTestStore(t, `app.my"docs`)
+	assert.Equal(t, `"app"."my""docs"`, store.table)
+}
+
+func TestPgVectorStore_Upsert(t *testing.T) {
+	store, fake := newTestStore(t, "documents")
+	err := store.Upsert(context.Background(), []*llm.VectorRecord{
+		{ID: "doc-1", Vector: []float64{0.1, -2, 3e-5}, Content: "hello", Metadata: map[string]any{"lang": "en"}},
+		{ID: "doc-2", Vector: []float64{1}, Content: "hi"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, fake.execs, 2)
+	assert.Equal(t, `INSERT INTO "documents" (id, content, metadata, embedding) VALUES ($1, $2, $3::jsonb, $4::vector) ON CONFLICT (id) DO UPDATE SET content = EXCLUDED.content, metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding`, normalize(fake.execs[0].query))
+	assert.Equal(t, []driver.Value{"doc-1", "hello", `{"lang":"en"}`, "[0.1,-2,3e-05]"}, fake.execs[0].args)
+	assert.Equal(t, []driver.Value{"doc-2", "hi", "{}", "[1]"}, fake.execs[1].args)
+	assert.True(t, fake.committed)
+
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, store.Upsert(context.Background(), []*llm.VectorRecord{{ID: "doc-3"}}), &validationErr)
+}
+
+func TestPgVectorStore_UpsertError(t *testing.T) {
+	store, fake := newTestStore(t, "documents")
+	fake.execErr = errors.New("relation does not exist")
+	err := store.Upsert(context.Background(), []*llm.VectorRecord{{ID: "doc-1", Vector: []float64{1}}})
+	assert.ErrorContains(t, err, "failed to upsert record doc-1: relation does not exist")
+	assert.False(t, fake.committed)
+}
+
+func TestPgVectorStore_Query(t *testing.T) {
+	store, fake := newTestStore(t, "documents")
+	fake.rows = [][]driver.Value{
+		{"doc-1", "hello", []byte(`{"lang": "en"}`), 0.93},
+		{"doc-2", "hi", []byte(`{}`), 0.71},
+	}
+
+	matches, err := store.Query(context.Background(), &llm.VectorQuery{Vector: []float64{0.5, 0.25}, TopK: 2, Filter: llm.VectorFilter{"lang": "en"}})
+	require.NoError(t, err)
+
+	require.Len(t, fake.execs, 1)
+	assert.Equal(t, `SELECT id, content, metadata, 1 - (embedding <=> $1::vector) AS score FROM "documents" WHERE metadata @> $2::jsonb ORDER BY embedding <=> $1::vector LIMIT $3`, normalize(fake.execs[0].query))
+	assert.Equal(t, []driver.Value{"[0.5,0.25]", `{"lang":"en"}`, int64(2)}, fake.execs[0].args)
+	require.Len(t, matches, 2)
+	assert.Equal(t, &llm.VectorRecord{ID: "doc-1", Content: "hello", Metadata: map[string]any{"lang": "en"}}, matches[0].Record)
+	assert.Equal(t, 0.93, matches[0].Score)
+	assert.Nil(t, matches[1].Record.Metadata)
+}
+
+func TestPgVectorStore_Delete(t *testing.T) {
+	store, fake := newTestStore(t, "documents")
+	require.NoError(t, store.Delete(context.Background(), []string{"doc-1", "doc-2"}, nil))
+	require.NoError(t, store.Delete(context.Background(), nil, llm.VectorFilter{"lang": "de"}))
+
+	require.Len(t, fake.execs, 2)
+	assert.Equal(t, `DELETE FROM "documents" WHERE metadata @> $1::jsonb AND id IN ($2, $3)`, fake.execs[0].query)
+	assert.Equal(t, []driver.Value{"{}", "doc-1", "doc-2"}, fake.execs[0].args)
+	assert.Equal(t, `DELETE FROM "documents" WHERE metadata @> $1::jsonb`, fake.execs[1].query)
+	assert.Equal(t, []driver.Value{`{"lang":"de"}`}, fake.execs[1].args)
+
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, store.Delete(context.Background(), nil, nil), &validationErr)
+}
@@ -0,0 +1,235 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package qdrant
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/easyagent-dev/llm"
+)
+
+const defaultBaseURL = "http://localhost:6333"
+
+// QdrantVectorStore implements VectorStore with a collection of the Qdrant REST API. The
+// collection must exist and use the cosine distance. Qdrant point IDs must be integers or
+// UUIDs, so records are stored under a UUID derived from their ID, with the ID, content and
+// metadata in the payload.
+type QdrantVectorStore struct {
+	collection string
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+var _ llm.VectorStore = (*QdrantVectorStore)(nil)
+
+// NewQdrantVectorStore creates a store for collection. WithBaseURL sets the Qdrant URL,
+// http://localhost:6333 by default, and WithAPIKey the API key of Qdrant Cloud.
+func NewQdrantVectorStore(collection string, opts ...llm.ModelOption) (*QdrantVectorStore, error) {
+	if collection == "" {
+		return nil, llm.NewValidationError("collection", "cannot be empty", nil)
+	}
+	config := llm.ApplyOptions(opts)
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &QdrantVectorStore{
+		collection: collection,
+		apiKey:     config.APIKey,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}, nil
+}
+
+type point struct {
+	ID      string    `json:"id"`
+	Vector  []float64 `json:"vector"`
+	Payload payload   `json:"payload"`
+}
+
+type payload struct {
+	ID       string         `json:"id"`
+	Content  string         `json:"content"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// filter is a Qdrant filter whose conditions must all match
+type filter struct {
+	Must []condition `json:"must"`
+}
+
+type condition struct {
+	Key   string      `json:"key,omitempty"`
+	Match *match      `json:"match,omitempty"`
+	HasID []string    `json:"has_id,omitempty"`
+	Must  []condition `json:"must,omitempty"`
+}
+
+type match struct {
+	Value any `json:"value"`
+}
+
+func (s *QdrantVectorStore) Upsert(ctx context.Context, records []*llm.VectorRecord) error {
+	if err := llm.ValidateVectorRecords(records); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	points := make([]point, len(records))
+	for i, record := range records {
+		points[i] = point{
+			ID:      pointID(record.ID),
+			Vector:  record.Vector,
+			Payload: payload{ID: record.ID, Content: record.Content, Metadata: record.Metadata},
+		}
+	}
+	body := map[string]any{"points": points}
+	return s.do(ctx, http.MethodPut, "/points?wait=true", body, nil, "failed to upsert points")
+}
+
+type searchResponse struct {
+	Result []struct {
+		Score   float64 `json:"score"`
+		Payload payload `json:"payload"`
+	} `json:"result"`
+}
+
+func (s *QdrantVectorStore) Query(ctx context.Context, query *llm.VectorQuery) ([]*llm.VectorMatch, error) {
+	if err := llm.ValidateVectorQuery(query); err != nil {
+		return nil, err
+	}
+	body := map[string]any{
+		"vector":       query.Vector,
+		"limit":        query.Limit(),
+		"with_payload": true,
+	}
+	if f := metadataFilter(nil, query.Filter); f != nil {
+		body["filter"] = f
+	}
+	var resp searchResponse
+	if err := s.do(ctx, http.MethodPost, "/points/search", body, &resp, "failed to search points"); err != nil {
+		return nil, err
+	}
+	matches := make([]*llm.VectorMatch, len(resp.Result))
+	for i, result := range resp.Result {
+		matches[i] = &llm.VectorMatch{
+			Record: &llm.VectorRecord{
+				ID:       result.Payload.ID,
+				Content:  result.Payload.Content,
+				Metadata: result.Payload.Metadata,
+			},
+			Score: result.Score,
+		}
+	}
+	return matches, nil
+}
+
+func (s *QdrantVectorStore) Delete(ctx context.Context, ids []string, f llm.VectorFilter) error {
+	if err := llm.ValidateVectorDelete(ids, f); err != nil {
+		return err
+	}
+	body := map[string]any{"filter": metadataFilter(ids, f)}
+	return s.do(ctx, http.MethodPost, "/points/delete?wait=true", body, nil, "failed to delete points")
+}
+
+// metadataFilter returns the Qdrant filter matching the points of ids with the metadata of f,
+// or nil when both are empty
+func metadataFilter(ids []string, f llm.VectorFilter) *filter {
+	var must []condition
+	if ids != nil {
+		pointIDs := make([]string, len(ids))
+		for i, id := range ids {
+			pointIDs[i] = pointID(id)
+		}
+		must = append(must, condition{HasID: pointIDs})
+	}
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		must = append(must, condition{Key: "metadata." + key, Match: &match{Value: f[key]}})
+	}
+	if len(must) == 0 {
+		return nil
+	}
+	return &filter{Must: must}
+}
+
+// pointID returns the UUID under which the record id is stored, derived from a SHA-1 hash of
+// the id like a version 5 UUID
+func pointID(id string) string {
+	sum := sha1.Sum([]byte(id))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// do sends body to the collection path and decodes the response into out, if not nil. Error
+// statuses are returned as a RequestError.
+func (s *QdrantVectorStore) do(ctx context.Context, method, path string, body any, out any, message string) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	endpoint := s.baseURL + "/collections/" + url.PathEscape(s.collection) + path
+	httpReq, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	if s.apiKey != "" {
+		httpReq.Header.Set("api-key", s.apiKey)
+	}
+
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%s: %w", message, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		respBody, _ := io.ReadAll(resp.Body)
+		return &llm.RequestError{
+			Provider:   "qdrant",
+			StatusCode: resp.StatusCode,
+			Message:    message,
+			Err:        errors.New(errorMessage(respBody)),
+			RetryAfter: llm.ParseRetryAfter(resp.Header),
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return llm.NewResponseError("qdrant", "failed to parse response", err)
+	}
+	return nil
+}
+
+// errorMessage returns the error Qdrant reports in the status of a response body
+func errorMessage(body []byte) string {
+	var resp struct {
+		Status struct {
+			Error string `json:"error"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Status.Error != "" {
+		return resp.Status.Error
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,144 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package qdrant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, handler http.HandlerFunc) *QdrantVectorStore {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	store, err := NewQdrantVectorStore("docs", llm.WithAPIKey("test-api-key"), llm.WithBaseURL(server.URL))
+	require.NoError(t, err)
+	return store
+}
+
+func TestNewQdrantVectorStore(t *testing.T) {
+	_, err := NewQdrantVectorStore("")
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	store, err := NewQdrantVectorStore("docs")
+	require.NoError(t, err)
+	assert.Equal(t, defaultBaseURL, store.baseURL)
+}
+
+func TestPointID(t *testing.T) {
+	assert.Equal(t, pointID("doc-1"), pointID("doc-1"))
+	assert.NotEqual(t, pointID("doc-1"), pointID("doc-2"))
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, pointID("doc-1"))
+}
+
+func TestQdrantVectorStore_Upsert(t *testing.T) {
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/collections/docs/points", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("wait"))
+		assert.Equal(t, "test-api-key", r.Header.Get("api-key"))
+		var body struct {
+			Points []point `json:"points"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Len(t, body.Points, 1)
+		assert.Equal(t, pointID("doc-1"), body.Points[0].ID)
+		assert.Equal(t, []float64{0.1, 0.2}, body.Points[0].Vector)
+		assert.Equal(t, payload{ID: "doc-1", Content: "hello", Metadata: map[string]any{"lang": "en"}}, body.Points[0].Payload)
+
+		fmt.Fprint(w, `{"result":{"operation_id":1,"status":"completed"},"status":"ok","time":0.001}`)
+	})
+
+	err := store.Upsert(context.Background(), []*llm.VectorRecord{
+		{ID: "doc-1", Vector: []float64{0.1, 0.2}, Content: "hello", Metadata: map[string]any{"lang": "en"}},
+	})
+	require.NoError(t, err)
+
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, store.Upsert(context.Background(), []*llm.VectorRecord{{ID: "doc-2"}}), &validationErr)
+}
+
+func TestQdrantVectorStore_Query(t *testing.T) {
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/collections/docs/points/search", r.URL.Path)
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, []any{0.1, 0.2}, body["vector"])
+		assert.Equal(t, float64(3), body["limit"])
+		assert.Equal(t, true, body["with_payload"])
+		assert.Equal(t, map[string]any{"must": []any{
+			map[string]any{"key": "metadata.lang", "match": map[string]any{"value": "en"}},
+			map[string]any{"key": "metadata.year", "match": map[string]any{"value": float64(2024)}},
+		}}, body["filter"])
+
+		fmt.Fprint(w, `{"result":[
+			{"id":"`+pointID("doc-1")+`","version":1,"score":0.93,"payload":{"id":"doc-1","content":"hello","metadata":{"lang":"en","year":2024}}},
+			{"id":"`+pointID("doc-2")+`","version":1,"score":0.71,"payload":{"id":"doc-2","content":"hi"}}
+		],"status":"ok","time":0.002}`)
+	})
+
+	matches, err := store.Query(context.Background(), &llm.VectorQuery{
+		Vector: []float64{0.1, 0.2},
+		TopK:   3,
+		Filter: llm.VectorFilter{"year": 2024, "lang": "en"},
+	})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, &llm.VectorRecord{ID: "doc-1", Content: "hello", Metadata: map[string]any{"lang": "en", "year": float64(2024)}}, matches[0].Record)
+	assert.Equal(t, 0.93, matches[0].Score)
+	assert.Equal(t, "doc-2", matches[1].Record.ID)
+}
+
+func TestQdrantVectorStore_Delete(t *testing.T) {
+	var filters []any
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/collections/docs/points/delete", r.URL.Path)
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		filters = append(filters, body["filter"])
+		fmt.Fprint(w, `{"result":{"operation_id":2,"status":"completed"},"status":"ok","time":0.001}`)
+	})
+
+	require.NoError(t, store.Delete(context.Background(), []string{"doc-1"}, llm.VectorFilter{"lang": "en"}))
+	require.NoError(t, store.Delete(context.Background(), nil, llm.VectorFilter{"lang": "de"}))
+	assert.Equal(t, []any{
+		map[string]any{"must": []any{
+			map[string]any{"has_id": []any{pointID("doc-1")}},
+			map[string]any{"key": "metadata.lang", "match": map[string]any{"value": "en"}},
+		}},
+		map[string]any{"must": []any{
+			map[string]any{"key": "metadata.lang", "match": map[string]any{"value": "de"}},
+		}},
+	}, filters)
+
+	var validationErr *llm.ValidationError
+	assert.ErrorAs(t, store.Delete(context.Background(), nil, nil), &validationErr)
+}
+
+func TestQdrantVectorStore_Error(t *testing.T) {
+	store := newTestStore(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"status":{"error":"Not found: Collection `+"`docs`"+` doesn't exist!"},"time":0.0}`)
+	})
+
+	_, err := store.Query(context.Background(), &llm.VectorQuery{Vector: []float64{0.1}})
+	var reqErr *llm.RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, "qdrant", reqErr.Provider)
+	assert.Equal(t, http.StatusNotFound, reqErr.StatusCode)
+	assert.Equal(t, 2*time.Second, reqErr.RetryAfter)
+	assert.ErrorContains(t, err, "Collection `docs` doesn't exist!")
+}
@@ -0,0 +1,306 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PromptVariation is an alternate way of issuing a request, tried by a JitterCompletionModel
+// after the previous attempt failed or was refused
+type PromptVariation struct {
+	// Name identifies the variation in ResponseMetadata.Variation
+	Name string
+	// Options are applied to the model after the base options
+	Options []CompletionOption
+	// Rewrite adjusts a copy of the request, e.g. to amend the instructions
+	Rewrite func(req *CompletionRequest)
+	// Sampling marks variations that only change sampling parameters, which are skipped for
+	// reasoning models since they reject them
+	Sampling bool
+}
+
+// VariationTemperatureBump raises the temperature by delta, starting from 0.7 when the base
+// options leave it unset. The result is capped at 1, which every provider accepts. Reasoning
+// models do not take a temperature, so the variation is skipped for them.
+func VariationTemperatureBump(delta float64) PromptVariation {
+	return PromptVariation{
+		Name:     "temperature_bump",
+		Sampling: true,
+		Options: []CompletionOption{func(o *CompletionOptions) {
+			temperature := 0.7
+			if o.Temperature != nil {
+				temperature = *o.Temperature
+			}
+			temperature = min(temperature+delta, 1)
+			o.Temperature = &temperature
+		}},
+	}
+}
+
+// jsonOnlyInstructions asks for a bare JSON response
+const jsonOnlyInstructions = `Respond in JSON only, with no other text.`
+
+// VariationJSONOnly asks the model to respond in JSON only and requests JSON output unless
+// the base options already set a response format
+func VariationJSONOnly() PromptVariation {
+	return PromptVariation{
+		Name: "json_only",
+		Options: []CompletionOption{func(o *CompletionOptions) {
+			if o.ResponseFormat == nil {
+				format := ResponseFormatJson
+				o.ResponseFormat = &format
+			}
+		}},
+		Rewrite: func(req *CompletionRequest) {
+			req.Instructions = joinInstructions(req.Instructions, jsonOnlyInstructions)
+		},
+	}
+}
+
+// DefaultPromptVariations returns the variations tried by default: a temperature bump and a
+// JSON-only instruction
+func DefaultPromptVariations() []PromptVariation {
+	return []PromptVariation{VariationTemperatureBump(0.3), VariationJSONOnly()}
+}
+
+func joinInstructions(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "\n\n")
+}
+
+// refusalPrefixes are the usual openings of a model declining to answer
+var refusalPrefixes = []string{
+	"i'm sorry, but i can",
+	"i am sorry, but i can",
+	"sorry, but i can",
+	"sorry, i can't",
+	"sorry, i cannot",
+	"i can't help",
+	"i can't assist",
+	"i can't provide",
+	"i can't comply",
+	"i cannot help",
+	"i cannot assist",
+	"i cannot provide",
+	"i cannot comply",
+	"i'm unable to",
+	"i am unable to",
+	"i won't be able to",
+	"as an ai",
+}
+
+// IsRefusal reports whether output opens like a model declining to answer, e.g. "I'm sorry,
+// but I can't help with that". Curly apostrophes are treated as straight ones.
+func IsRefusal(output string) bool {
+	output = strings.ToLower(strings.TrimSpace(output))
+	output = strings.ReplaceAll(output, "’", "'")
+	for _, prefix := range refusalPrefixes {
+		if strings.HasPrefix(output, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// JitterOption is a functional option for configuring a JitterCompletionModel
+type JitterOption func(*JitterOptions)
+
+// JitterOptions contains configuration options for a JitterCompletionModel
+type JitterOptions struct {
+	// Variations are tried in order after the original request, cycling when MaxAttempts
+	// exceeds their number
+	Variations []PromptVariation
+	// MaxAttempts is the total number of attempts, including the original request. It
+	// defaults to one attempt per variation after the original request.
+	MaxAttempts int
+	// Check rejects a response, making the next variation be tried. It defaults to
+	// rejecting refusals with ErrRefusal.
+	Check func(resp *CompletionResponse) error
+}
+
+// WithVariations sets the prompt variations to try after the original request
+func WithVariations(variations ...PromptVariation) JitterOption {
+	return func(o *JitterOptions) {
+		o.Variations = variations
+	}
+}
+
+// WithJitterAttempts sets the total number of attempts, including the original request
+func WithJitterAttempts(maxAttempts int) JitterOption {
+	return func(o *JitterOptions) {
+		o.MaxAttempts = maxAttempts
+	}
+}
+
+// WithResponseCheck sets the check a response must pass, e.g. that it parses as JSON. A
+// check replaces the default refusal check; call IsRefusal from it to keep both.
+func WithResponseCheck(check func(resp *CompletionResponse) error) JitterOption {
+	return func(o *JitterOptions) {
+		o.Check = check
+	}
+}
+
+// ApplyJitterOptions applies all options to create a JitterOptions struct
+func ApplyJitterOptions(opts []JitterOption) *JitterOptions {
+	options := &JitterOptions{
+		Variations: DefaultPromptVariations(),
+		Check:      checkRefusal,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// attempts returns the total number of attempts
+func (o *JitterOptions) attempts() int {
+	if o.MaxAttempts <= 0 {
+		return len(o.Variations) + 1
+	}
+	return o.MaxAttempts
+}
+
+func checkRefusal(resp *CompletionResponse) error {
+	if IsRefusal(resp.Output) {
+		return ErrRefusal
+	}
+	return nil
+}
+
+// JitterCompletionModel retries failed or refused completions with systematic prompt
+// variations, for pipelines that must get an answer despite flaky refusals. Each variation
+// runs on its own model of the provider, created with the base options followed by the
+// variation's options. The variation that succeeded and the number of attempts are recorded
+// in ResponseMetadata.
+type JitterCompletionModel struct {
+	models  []CompletionModel
+	options *JitterOptions
+}
+
+var _ CompletionModel = (*JitterCompletionModel)(nil)
+
+// NewJitterCompletionModel creates a completion model for model of provider that retries with
+// prompt variations. baseOpts are the options of the original request.
+func NewJitterCompletionModel(provider ModelProvider, model string, baseOpts []CompletionOption, opts ...JitterOption) (*JitterCompletionModel, error) {
+	if provider == nil {
+		return nil, NewValidationError("provider", "cannot be nil", nil)
+	}
+	options := ApplyJitterOptions(opts)
+	if info := findModelInfo(provider, model); info != nil && info.Reasoning {
+		var variations []PromptVariation
+		for _, variation := range options.Variations {
+			if !variation.Sampling {
+				variations = append(variations, variation)
+			}
+		}
+		options.Variations = variations
+	}
+
+	// The original request is models[0], variation i runs on models[i+1]
+	models := make([]CompletionModel, 0, len(options.Variations)+1)
+	base, err := provider.NewCompletionModel(model, baseOpts...)
+	if err != nil {
+		return nil, err
+	}
+	models = append(models, base)
+	for _, variation := range options.Variations {
+		if len(variation.Options) == 0 {
+			models = append(models, base)
+			continue
+		}
+		variant, err := provider.NewCompletionModel(model, append(baseOpts[:len(baseOpts):len(baseOpts)], variation.Options...)...)
+		if err != nil {
+			return nil, fmt.Errorf("variation %s: %w", variation.Name, err)
+		}
+		models = append(models, variant)
+	}
+	return &JitterCompletionModel{models: models, options: options}, nil
+}
+
+// attempt returns the model, request and variation name of the given attempt, counting
+// from zero for the original request
+func (m *JitterCompletionModel) attempt(n int, req *CompletionRequest) (CompletionModel, *CompletionRequest, string) {
+	if n == 0 || len(m.options.Variations) == 0 {
+		return m.models[0], req, ""
+	}
+	i := (n - 1) % len(m.options.Variations)
+	variation := m.options.Variations[i]
+	if variation.Rewrite == nil {
+		return m.models[i+1], req, variation.Name
+	}
+	rewritten := *req
+	rewritten.Messages = append([]*ModelMessage(nil), req.Messages...)
+	variation.Rewrite(&rewritten)
+	return m.models[i+1], &rewritten, variation.Name
+}
+
+// Complete issues the request and then each variation in turn until a response passes the
+// check. Validation errors and context cancellation are returned immediately; other errors
+// are joined if every attempt fails.
+func (m *JitterCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if req == nil {
+		return nil, NewValidationError("request", "cannot be nil", nil)
+	}
+
+	// Rejected responses are billed too, so usage and cost add up across attempts
+	var errs []error
+	var total usageTotal
+	for n := 0; n < m.options.attempts(); n++ {
+		model, attemptReq, name := m.attempt(n, req)
+		resp, err := model.Complete(ctx, attemptReq)
+		if err == nil {
+			total.addResponse(resp)
+			if m.options.Check != nil {
+				err = m.options.Check(resp)
+			}
+			if err == nil {
+				resp.Usage, resp.Cost, resp.CostBreakdown = total.usage, total.cost, total.breakdown
+				if resp.Metadata == nil {
+					resp.Metadata = &ResponseMetadata{}
+				}
+				resp.Metadata.Variation = name
+				resp.Metadata.Attempts = n + 1
+				return resp, nil
+			}
+		} else if !shouldFailover(ctx, err) {
+			return nil, err
+		}
+		if name == "" {
+			name = "original"
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// StreamComplete opens a stream with the request and then each variation in turn until one
+// opens. Refusals cannot be detected before a stream is consumed, so they are not retried.
+func (m *JitterCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	if req == nil {
+		return nil, NewValidationError("request", "cannot be nil", nil)
+	}
+
+	var errs []error
+	for n := 0; n < m.options.attempts(); n++ {
+		model, attemptReq, _ := m.attempt(n, req)
+		stream, err := model.StreamComplete(ctx, attemptReq)
+		if err == nil {
+			return stream, nil
+		}
+		if !shouldFailover(ctx, err) {
+			return nil, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
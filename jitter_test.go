@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jitterTestProvider returns models that answer with scripted outputs in turn, shared by all
+// its models, and records the options and request of each call
+type jitterTestProvider struct {
+	*DefaultModelProvider
+	replies  []any // string outputs or errors
+	options  []*CompletionOptions
+	requests []*CompletionRequest
+}
+
+type jitterTestModel struct {
+	provider *jitterTestProvider
+	options  *CompletionOptions
+}
+
+func (p *jitterTestProvider) NewCompletionModel(model string, opts ...CompletionOption) (CompletionModel, error) {
+	return &jitterTestModel{provider: p, options: ApplyCompletionOptions(opts)}, nil
+}
+
+func (m *jitterTestModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	p := m.provider
+	p.options = append(p.options, m.options)
+	p.requests = append(p.requests, req)
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	if err, ok := reply.(error); ok {
+		return nil, err
+	}
+	cost := 0.01
+	return &CompletionResponse{Output: reply.(string), Usage: &TokenUsage{TotalOutputTokens: 10}, Cost: &cost}, nil
+}
+
+func (m *jitterTestModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	return nil, ErrInvalidRequest
+}
+
+func TestIsRefusal(t *testing.T) {
+	assert.True(t, IsRefusal("I'm sorry, but I can't help with that."))
+	assert.True(t, IsRefusal("  I can’t assist with this request"))
+	assert.True(t, IsRefusal("As an AI, I cannot"))
+	assert.False(t, IsRefusal(`{"name": "Ada"}`))
+	assert.False(t, IsRefusal("Sorry for the wait, here is the summary."))
+}
+
+func TestJitterCompletionModel(t *testing.T) {
+	provider := &jitterTestProvider{
+		DefaultModelProvider: NewDefaultModelProvider("test", nil),
+		replies: []any{
+			"I'm sorry, but I can't help with that.",
+			NewRequestError("test", 500, "server error", nil),
+			`{"ok": true}`,
+		},
+	}
+	model, err := NewJitterCompletionModel(provider, "m", []CompletionOption{WithTemperature(0.2)})
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &CompletionRequest{
+		Instructions: "Summarize the text.",
+		Messages:     []*ModelMessage{{Role: RoleUser, Content: "text"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"ok": true}`, resp.Output)
+	assert.Equal(t, "json_only", resp.Metadata.Variation)
+	assert.Equal(t, 3, resp.Metadata.Attempts)
+	// The refusal is billed too
+	assert.Equal(t, int64(20), resp.Usage.TotalOutputTokens)
+	assert.InDelta(t, 0.02, *resp.Cost, 1e-9)
+
+	require.Len(t, provider.requests, 3)
+	assert.Equal(t, 0.2, *provider.options[0].Temperature)
+	assert.Nil(t, provider.options[0].ResponseFormat)
+	assert.InDelta(t, 0.5, *provider.options[1].Temperature, 1e-9)
+	assert.Equal(t, "Summarize the text.", provider.requests[1].Instructions)
+	assert.Equal(t, "Summarize the text.\n\nRespond in JSON only, with no other text.", provider.requests[2].Instructions)
+	assert.Equal(t, ResponseFormatJson, *provider.options[2].ResponseFormat)
+	assert.Equal(t, 0.2, *provider.options[2].Temperature)
+}
+
+func TestJitterCompletionModel_Reasoning(t *testing.T) {
+	provider := &jitterTestProvider{
+		DefaultModelProvider: NewDefaultModelProvider("test", []*ModelInfo{{ID: "r", Reasoning: true}}),
+		replies:              []any{"I'm unable to do that.", `{"ok": true}`},
+	}
+	model, err := NewJitterCompletionModel(provider, "r", nil)
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	// The temperature bump is skipped, reasoning models reject a temperature
+	assert.Equal(t, "json_only", resp.Metadata.Variation)
+	assert.Equal(t, 2, resp.Metadata.Attempts)
+	for _, options := range provider.options {
+		assert.Nil(t, options.Temperature)
+	}
+}
+
+func TestJitterCompletionModel_Check(t *testing.T) {
+	provider := &jitterTestProvider{
+		DefaultModelProvider: NewDefaultModelProvider("test", nil),
+		replies:              []any{"not json", "still not json", `{"a": 1}`},
+	}
+	model, err := NewJitterCompletionModel(provider, "m", nil,
+		WithVariations(VariationJSONOnly()),
+		WithJitterAttempts(3),
+		WithResponseCheck(func(resp *CompletionResponse) error {
+			var v map[string]any
+			return json.Unmarshal([]byte(resp.Output), &v)
+		}),
+	)
+	require.NoError(t, err)
+
+	resp, err := model.Complete(context.Background(), &CompletionRequest{Messages: []*ModelMessage{{Role: RoleUser, Content: "hi"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "json_only", resp.Metadata.Variation)
+	assert.Equal(t, 3, resp.Metadata.Attempts)
+	assert.Equal(t, "Respond in JSON only, with no other text.", provider.requests[2].Instructions)
+}
+
+func TestJitterCompletionModel_Errors(t *testing.T) {
+	t.Run("every attempt refused", func(t *testing.T) {
+		provider := &jitterTestProvider{
+			DefaultModelProvider: NewDefaultModelProvider("test", nil),
+			replies:              []any{"I'm unable to do that.", "I'm unable to do that."},
+		}
+		model, err := NewJitterCompletionModel(provider, "m", nil, WithJitterAttempts(2))
+		require.NoError(t, err)
+
+		_, err = model.Complete(context.Background(), &CompletionRequest{})
+		assert.ErrorIs(t, err, ErrRefusal)
+		assert.Contains(t, err.Error(), "original: ")
+		assert.Contains(t, err.Error(), "temperature_bump: ")
+	})
+
+	t.Run("validation error is not retried", func(t *testing.T) {
+		provider := &jitterTestProvider{
+			DefaultModelProvider: NewDefaultModelProvider("test", nil),
+			replies:              []any{NewValidationError("messages", "cannot be empty", nil), "ok"},
+		}
+		model, err := NewJitterCompletionModel(provider, "m", nil)
+		require.NoError(t, err)
+
+		_, err = model.Complete(context.Background(), &CompletionRequest{})
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Len(t, provider.requests, 1)
+	})
+}
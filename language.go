@@ -0,0 +1,209 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// languageNames maps ISO 639-1 codes to English language names used in instructions
+var languageNames = map[string]string{
+	"ar": "Arabic",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"sv": "Swedish",
+	"th": "Thai",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"zh": "Chinese",
+}
+
+// LanguageName returns the English name of an ISO 639-1 language code, or the code itself if unknown
+func LanguageName(code string) string {
+	if name, ok := languageNames[normalizeLanguageCode(code)]; ok {
+		return name
+	}
+	return code
+}
+
+// OutputLanguageInstruction returns the instruction asking the model to respond in the given language.
+// Strict instructions are used when retrying after a language mismatch.
+func OutputLanguageInstruction(code string, strict bool) string {
+	name := LanguageName(code)
+	if strict {
+		return fmt.Sprintf("IMPORTANT: Your previous answer was not in %s. You MUST write your entire response in %s (%s) only, "+
+			"regardless of the language of the instructions or the user's messages. Do not use any other language.", name, name, code)
+	}
+	return fmt.Sprintf("Always respond in %s (%s), regardless of the language of the user's messages.", name, code)
+}
+
+// stopwords holds frequent function words for Latin-script languages the detector distinguishes
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "with", "for", "this", "you", "was", "on"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "mit", "sich", "auf", "für", "ich", "sie", "es", "den", "dem"},
+	"fr": {"le", "la", "les", "et", "est", "un", "une", "des", "du", "que", "pour", "dans", "pas", "ce", "il", "avec", "sur"},
+	"es": {"el", "la", "los", "las", "y", "es", "un", "una", "que", "de", "en", "por", "para", "con", "no", "se", "del"},
+	"it": {"il", "lo", "la", "gli", "le", "e", "è", "un", "una", "che", "di", "per", "non", "con", "del", "della", "sono"},
+	"pt": {"o", "a", "os", "as", "e", "é", "um", "uma", "que", "de", "em", "para", "com", "não", "do", "da", "são"},
+	"nl": {"de", "het", "een", "en", "is", "van", "dat", "niet", "op", "te", "met", "zijn", "voor", "ik", "je"},
+}
+
+// DetectLanguage returns the ISO 639-1 code of the most likely language of text, or "" when
+// the text is too short or ambiguous. It is a lightweight local heuristic based on script
+// and stopword frequency, suitable for catching responses in the wrong language.
+func DetectLanguage(text string) string {
+	var letters, latin, cyrillic, greek, arabic, hebrew, devanagari, thai, han, kana, hangul int
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Greek, r):
+			greek++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Hebrew, r):
+			hebrew++
+		case unicode.Is(unicode.Devanagari, r):
+			devanagari++
+		case unicode.Is(unicode.Thai, r):
+			thai++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// Non-Latin scripts identify the language (or language family) directly
+	half := letters / 2
+	switch {
+	case kana > 0 && kana+han > half:
+		return "ja"
+	case hangul > half:
+		return "ko"
+	case han > half:
+		return "zh"
+	case cyrillic > half:
+		if strings.ContainsAny(text, "іїєґІЇЄҐ") {
+			return "uk"
+		}
+		return "ru"
+	case greek > half:
+		return "el"
+	case arabic > half:
+		return "ar"
+	case hebrew > half:
+		return "he"
+	case devanagari > half:
+		return "hi"
+	case thai > half:
+		return "th"
+	case latin <= half:
+		return ""
+	}
+
+	// Latin scripts are distinguished by stopword frequency
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) < 3 {
+		return ""
+	}
+	counts := make(map[string]int, len(stopwords))
+	for _, word := range words {
+		for lang, list := range stopwords {
+			for _, stopword := range list {
+				if word == stopword {
+					counts[lang]++
+					break
+				}
+			}
+		}
+	}
+
+	best, bestCount, secondCount := "", 0, 0
+	for lang, count := range counts {
+		if count > bestCount || (count == bestCount && lang < best) {
+			best, bestCount, secondCount = lang, count, bestCount
+		} else if count > secondCount {
+			secondCount = count
+		}
+	}
+	if bestCount == 0 || bestCount == secondCount {
+		return ""
+	}
+	return best
+}
+
+// scriptLanguages are the languages DetectLanguage identifies by their script
+var scriptLanguages = []string{"ja", "ko", "zh", "ru", "uk", "el", "ar", "he", "hi", "th"}
+
+// IsDetectableLanguage reports whether DetectLanguage can identify the language of an
+// ISO 639-1 code
+func IsDetectableLanguage(code string) bool {
+	code = normalizeLanguageCode(code)
+	if _, ok := stopwords[code]; ok {
+		return true
+	}
+	return slices.Contains(scriptLanguages, code)
+}
+
+// CheckOutputLanguage returns an error matching ErrUnsupportedLanguage when opts validate an
+// output language that DetectLanguage cannot identify. Responses in such a language could be
+// mistaken for another one, and the paid retry would fail the same way, so the request is
+// refused before it is sent.
+func CheckOutputLanguage(opts *CompletionOptions) error {
+	if opts == nil || opts.OutputLanguage == nil || opts.ValidateOutputLanguage == nil || !*opts.ValidateOutputLanguage {
+		return nil
+	}
+	if !IsDetectableLanguage(*opts.OutputLanguage) {
+		return fmt.Errorf("%w: %q cannot be validated, use WithOutputLanguage without validation", ErrUnsupportedLanguage, *opts.OutputLanguage)
+	}
+	return nil
+}
+
+// IsOutputLanguageMismatch reports whether text was confidently detected as a language
+// other than the expected one. Undetectable text is not treated as a mismatch.
+func IsOutputLanguageMismatch(expected, text string) bool {
+	detected := DetectLanguage(text)
+	if detected == "" {
+		return false
+	}
+	return detected != normalizeLanguageCode(expected)
+}
+
+// normalizeLanguageCode reduces a language tag such as "de-DE" to its lowercase primary subtag
+func normalizeLanguageCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	return code
+}
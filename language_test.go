@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "english", text: "The weather is nice and it is warm in the city today.", want: "en"},
+		{name: "german", text: "Das Wetter ist heute schön und es ist warm in der Stadt, nicht wahr?", want: "de"},
+		{name: "french", text: "Le temps est beau et il fait chaud dans la ville pour les enfants.", want: "fr"},
+		{name: "dutch", text: "Het weer is mooi en het is warm in de stad, dat is niet slecht voor je.", want: "nl"},
+		{name: "russian", text: "Сегодня хорошая погода и в городе тепло.", want: "ru"},
+		{name: "ukrainian", text: "Сьогодні гарна погода і в місті тепло, їжа смачна.", want: "uk"},
+		{name: "japanese", text: "今日はいい天気ですね。", want: "ja"},
+		{name: "chinese", text: "今天天气很好，城市里很暖和。", want: "zh"},
+		{name: "korean", text: "오늘 날씨가 좋습니다.", want: "ko"},
+		{name: "too_short", text: "OK", want: ""},
+		{name: "no_letters", text: "12345 !!!", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectLanguage(tt.text))
+		})
+	}
+}
+
+func TestIsOutputLanguageMismatch(t *testing.T) {
+	german := "Das Wetter ist heute schön und es ist warm in der Stadt."
+	english := "The weather is nice and it is warm in the city today."
+
+	assert.False(t, IsOutputLanguageMismatch("de", german))
+	assert.False(t, IsOutputLanguageMismatch("de-DE", german))
+	assert.True(t, IsOutputLanguageMismatch("de", english))
+	assert.False(t, IsOutputLanguageMismatch("de", "42"), "undetectable output is not a mismatch")
+}
+
+func TestOutputLanguageInstruction(t *testing.T) {
+	assert.Contains(t, OutputLanguageInstruction("de", false), "German (de)")
+	assert.Contains(t, OutputLanguageInstruction("de", true), "MUST")
+	assert.Contains(t, OutputLanguageInstruction("xx", false), "xx")
+}
+
+func TestCheckOutputLanguage(t *testing.T) {
+	check := func(opts ...CompletionOption) error {
+		return CheckOutputLanguage(ApplyCompletionOptions(opts))
+	}
+	assert.NoError(t, check())
+	assert.NoError(t, check(WithOutputLanguage("de-DE", true)))
+	assert.NoError(t, check(WithOutputLanguage("ja", true)))
+	assert.NoError(t, check(WithOutputLanguage("fi")), "not validated")
+	assert.ErrorIs(t, check(WithOutputLanguage("fi", true)), ErrUnsupportedLanguage)
+	assert.ErrorIs(t, check(WithOutputLanguage("pl", true)), ErrUnsupportedLanguage)
+}
@@ -0,0 +1,598 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LexiconAction is what a lexicon filter does with the terms of a lexicon found in output
+type LexiconAction string
+
+const (
+	// LexiconActionMask replaces every character of the term with the mask of the lexicon
+	LexiconActionMask LexiconAction = "mask"
+	// LexiconActionHalt ends the output before the term, and FinishReason is then
+	// FinishReasonContentFilter. The upstream request of a halted stream is canceled, and the
+	// usage reported so far or, when usage is requested, an estimate follows the finish chunk.
+	LexiconActionHalt LexiconAction = "halt"
+)
+
+// Lexicon is a list of terms, such as profanity or competitor brand names, that a
+// LexiconFilter masks or halts generation on. Terms are matched regardless of case, with
+// whitespace in a term matching any run of whitespace, e.g. a line break.
+type Lexicon struct {
+	// Name identifies the lexicon in audit events
+	Name  string
+	Terms []string
+	// Action is what is done when a term is found; empty masks it
+	Action LexiconAction
+	// Mask replaces each character of masked terms; empty uses "*"
+	Mask string
+	// Substrings also matches terms inside words; by default terms only match whole words,
+	// so "class" is not masked for the term "ass"
+	Substrings bool
+	// When limits the lexicon to requests whose context labels have all these values, see
+	// ContextWithPolicyLabels, e.g. to use stricter lexicons for some deployments
+	When map[string]string
+}
+
+// LexiconFilter masks or halts the output of models when the terms of its lexicons appear,
+// see WithLexiconFilter
+type LexiconFilter struct {
+	Lexicons []*Lexicon
+	// Audit receives an event for each term found
+	Audit LexiconAuditHook `json:"-"`
+}
+
+// LexiconEvent is the audit record of a lexicon term found in output
+type LexiconEvent struct {
+	Time     time.Time `json:"time"`
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Lexicon  string    `json:"lexicon"`
+	// Term is the term of the lexicon that was found
+	Term   string            `json:"term"`
+	Action LexiconAction     `json:"action"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// LexiconAuditHook receives the audit events of a lexicon filter
+type LexiconAuditHook func(ctx context.Context, event *LexiconEvent)
+
+// WithLexiconFilter filters the output of models created by providers with the lexicons of
+// filter that apply to the labels of the request context. Streams hold back text that may
+// start a term until the next chunk completes or rules it out, so terms split across chunks
+// are found.
+func WithLexiconFilter(filter *LexiconFilter) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.LexiconFilter = filter
+	}
+}
+
+// LexiconCompletionModel applies the lexicon filter set with WithLexiconFilter to the output
+// of a model, both to complete responses and to streams. Everything carrying output text is
+// filtered: the text, its logprobs, whose masked tokens lose their alternatives, and JSON
+// chunks. Reasoning and tool calls are passed through.
+type LexiconCompletionModel struct {
+	model    CompletionModel
+	options  []CompletionOption
+	provider string
+	name     string
+}
+
+var _ CompletionModel = (*LexiconCompletionModel)(nil)
+var _ TokenCounter = (*LexiconCompletionModel)(nil)
+
+// NewLexiconCompletionModel wraps model, created with opts, with the lexicon filter of opts and
+// of the option overrides of request contexts
+func NewLexiconCompletionModel(model CompletionModel, opts []CompletionOption) *LexiconCompletionModel {
+	return &LexiconCompletionModel{model: model, options: opts}
+}
+
+func (m *LexiconCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, err := m.model.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	filter := ApplyContextCompletionOptions(ctx, m.options).LexiconFilter
+	matcher := filter.matcher(PolicyLabelsFromContext(ctx))
+	if matcher == nil {
+		return resp, nil
+	}
+
+	filtered := *resp
+	matches, _ := matcher.scan(resp.Output, 0, true)
+	output, halted := m.apply(ctx, filter, resp.Output, matches, true)
+	filtered.Output = output
+	filtered.Logprobs = matcher.filterLogprobs(resp.Logprobs)
+	if halted {
+		filtered.FinishReason = FinishReasonContentFilter
+	}
+	if len(resp.Choices) > 0 {
+		filtered.Choices = make([]CompletionChoice, len(resp.Choices))
+		for j, choice := range resp.Choices {
+			// The first choice is the output, which was audited
+			matches, _ := matcher.scan(choice.Text, 0, true)
+			choice.Logprobs = matcher.filterLogprobs(choice.Logprobs)
+			if text, halted := m.apply(ctx, filter, choice.Text, matches, j > 0); halted {
+				choice.Text, choice.FinishReason = text, FinishReasonContentFilter
+			} else {
+				choice.Text = text
+			}
+			filtered.Choices[j] = choice
+		}
+	}
+	return &filtered, nil
+}
+
+func (m *LexiconCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	options := ApplyContextCompletionOptions(ctx, m.options)
+	matcher := options.LexiconFilter.matcher(PolicyLabelsFromContext(ctx))
+	if matcher == nil {
+		return m.model.StreamComplete(ctx, req)
+	}
+	upstream, cancel := context.WithCancel(ctx)
+	stream, err := m.model.StreamComplete(upstream, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	cut := &cutStream{ctx: ctx, out: out, model: m.model, req: req, stream: stream, cancel: cancel}
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		send := cut.send
+		sendText := func(text string) bool {
+			return text == "" || send(options.TextChunk(text))
+		}
+		// pending is the text held back because it may start a term, and prev the last
+		// character sent, which decides whether a term starts a word
+		var pending string
+		var prev rune
+		// release sends the filtered text that can no longer be part of a new term, or all of
+		// it when final, and reports whether the stream goes on
+		release := func(final bool) bool {
+			matches, safe := matcher.scan(pending, prev, final)
+			text, halted := m.apply(ctx, options.LexiconFilter, pending[:safe], matches, true)
+			if halted {
+				if !sendText(text) {
+					return false
+				}
+				if options.StreamsEvent(FinishChunkType) && !send(StreamFinishChunk{Reason: FinishReasonContentFilter}) {
+					return false
+				}
+				cut.end(options)
+				return false
+			}
+			if safe > 0 {
+				prev, _ = utf8.DecodeLastRuneInString(pending[:safe])
+			}
+			pending = pending[safe:]
+			return sendText(text)
+		}
+		// The logprobs are held back and filtered like the text they spell
+		logprobs := &lexiconLogprobs{matcher: matcher}
+		releaseLogprobs := func(final bool) bool {
+			tokens := logprobs.release(final)
+			return len(tokens) == 0 || send(StreamLogprobChunk{Logprobs: tokens})
+		}
+		jsonHalted := false
+
+		for chunk := range stream {
+			chunk = ownText(chunk)
+			cut.observe(chunk)
+			switch c := chunk.(type) {
+			case StreamTextChunk:
+				pending += c.Text
+				if !release(false) {
+					return
+				}
+				continue
+			case StreamLogprobChunk:
+				logprobs.pending = append(logprobs.pending, c.Logprobs...)
+				if !releaseLogprobs(false) {
+					return
+				}
+				continue
+			case StreamJSONChunk:
+				filtered, halted := matcher.filterJSONChunk(c)
+				jsonHalted = jsonHalted || halted
+				if jsonHalted {
+					continue
+				}
+				chunk = filtered
+			case StreamFinishChunk, StreamUsageChunk, StreamErrorChunk:
+				// The stream ends, so the held back text is output
+				if !release(true) || !releaseLogprobs(true) {
+					return
+				}
+			}
+			if !send(chunk) {
+				return
+			}
+		}
+		if release(true) {
+			releaseLogprobs(true)
+		}
+	}()
+	return out, nil
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *LexiconCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
+
+// apply masks the matches in text, auditing them when audit is set, and returns the text up
+// to the first match of a halting lexicon, reporting whether there was one
+func (m *LexiconCompletionModel) apply(ctx context.Context, filter *LexiconFilter, text string, matches []lexiconMatch, audit bool) (string, bool) {
+	if len(matches) == 0 {
+		return text, false
+	}
+	for _, match := range matches {
+		lexicon := match.lexicon
+		if audit && filter.Audit != nil {
+			filter.Audit(ctx, &LexiconEvent{
+				Time:     time.Now(),
+				Provider: m.provider,
+				Model:    m.name,
+				Lexicon:  lexicon.Name,
+				Term:     match.term,
+				Action:   lexicon.action(),
+				Labels:   maps.Clone(PolicyLabelsFromContext(ctx)),
+			})
+		}
+		if lexicon.action() == LexiconActionHalt {
+			break
+		}
+	}
+	return maskMatches(text, matches)
+}
+
+// maskMatches masks the matches in text and returns the text up to the first match of a
+// halting lexicon, reporting whether there was one
+func maskMatches(text string, matches []lexiconMatch) (string, bool) {
+	var sb strings.Builder
+	last := 0
+	for _, match := range matches {
+		sb.WriteString(text[last:match.start])
+		if match.lexicon.action() == LexiconActionHalt {
+			return sb.String(), true
+		}
+		sb.WriteString(strings.Repeat(match.lexicon.mask(), utf8.RuneCountInString(text[match.start:match.end])))
+		last = match.end
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), false
+}
+
+// filterText masks the terms in text, which is not audited, and returns the text up to the
+// first term of a halting lexicon, reporting whether there was one
+func (m *lexiconMatcher) filterText(text string) (string, bool) {
+	matches, _ := m.scan(text, 0, true)
+	if len(matches) == 0 {
+		return text, false
+	}
+	return maskMatches(text, matches)
+}
+
+// filterValue filters the strings of a decoded JSON value, returning a copy when they change
+func (m *lexiconMatcher) filterValue(value any) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		return m.filterText(v)
+	case map[string]any:
+		filtered, halted := make(map[string]any, len(v)), false
+		for key, element := range v {
+			var h bool
+			filtered[key], h = m.filterValue(element)
+			halted = halted || h
+		}
+		return filtered, halted
+	case []any:
+		filtered, halted := make([]any, len(v)), false
+		for i, element := range v {
+			var h bool
+			filtered[i], h = m.filterValue(element)
+			halted = halted || h
+		}
+		return filtered, halted
+	}
+	return value, false
+}
+
+// filterJSONChunk filters the strings of a JSON chunk, each taken as a whole, reporting
+// whether one has a term of a halting lexicon
+func (m *lexiconMatcher) filterJSONChunk(c StreamJSONChunk) (StreamJSONChunk, bool) {
+	delta, halted := m.filterText(c.Delta)
+	c.Delta = delta
+	value, h := m.filterValue(c.Value)
+	c.Value, halted = value, halted || h
+	if len(c.Partial) > 0 {
+		var partial any
+		if err := json.Unmarshal(c.Partial, &partial); err == nil {
+			partial, h = m.filterValue(partial)
+			halted = halted || h
+			if data, err := json.Marshal(partial); err == nil {
+				c.Partial = data
+			}
+		}
+	}
+	return c, halted
+}
+
+// filterLogprobs masks the terms in the text spelled by tokens, see maskLogprobs
+func (m *lexiconMatcher) filterLogprobs(tokens []TokenLogprob) []TokenLogprob {
+	if len(tokens) == 0 {
+		return tokens
+	}
+	text := joinTokens(tokens)
+	matches, _ := m.scan(text, 0, true)
+	masked, _ := maskLogprobs(tokens, text, matches)
+	return masked
+}
+
+// lexiconLogprobs holds back the streamed logprobs whose tokens may start a term
+type lexiconLogprobs struct {
+	matcher *lexiconMatcher
+	pending []TokenLogprob
+	// prev is the last character of the released tokens
+	prev   rune
+	halted bool
+}
+
+// release returns the filtered tokens that can no longer be part of a new term, or all of
+// them when final. Tokens are released whole, so the text of a term split across tokens is
+// masked in each of them.
+func (l *lexiconLogprobs) release(final bool) []TokenLogprob {
+	if l.halted || len(l.pending) == 0 {
+		return nil
+	}
+	text := joinTokens(l.pending)
+	matches, safe := l.matcher.scan(text, l.prev, final)
+	n, end := 0, 0
+	for n < len(l.pending) && end+len(l.pending[n].Token) <= safe {
+		end += len(l.pending[n].Token)
+		n++
+	}
+	// A term continuing into a held back token is matched again once that token is released
+	for len(matches) > 0 && matches[len(matches)-1].end > end {
+		last := matches[len(matches)-1]
+		for n > 0 && end > last.start {
+			n--
+			end -= len(l.pending[n].Token)
+		}
+		matches = matches[:len(matches)-1]
+	}
+	if n == 0 {
+		return nil
+	}
+	released, halted := maskLogprobs(l.pending[:n], text[:end], matches)
+	l.prev, _ = utf8.DecodeLastRuneInString(text[:end])
+	l.pending, l.halted = l.pending[n:], halted
+	return released
+}
+
+// maskLogprobs masks the matches in text, which tokens spell, in the tokens they overlap.
+// Masked tokens lose their alternatives, which may spell the term, and the tokens from the
+// first match of a halting lexicon on are dropped, reporting whether there was one.
+func maskLogprobs(tokens []TokenLogprob, text string, matches []lexiconMatch) ([]TokenLogprob, bool) {
+	if len(matches) == 0 {
+		return tokens, false
+	}
+	masked := make([]TokenLogprob, 0, len(tokens))
+	start := 0
+	for _, token := range tokens {
+		end := start + len(token.Token)
+		var sb strings.Builder
+		pos, halted := start, false
+		for _, match := range matches {
+			if match.end <= pos || match.start >= end {
+				continue
+			}
+			from, to := max(match.start, pos), min(match.end, end)
+			sb.WriteString(text[pos:from])
+			if match.lexicon.action() == LexiconActionHalt {
+				halted = true
+				break
+			}
+			sb.WriteString(strings.Repeat(match.lexicon.mask(), utf8.RuneCountInString(text[from:to])))
+			pos = to
+		}
+		if halted {
+			if sb.Len() > 0 {
+				masked = append(masked, TokenLogprob{Token: sb.String(), Logprob: token.Logprob})
+			}
+			return masked, true
+		}
+		if pos != start {
+			sb.WriteString(text[pos:end])
+			token = TokenLogprob{Token: sb.String(), Logprob: token.Logprob}
+		}
+		masked = append(masked, token)
+		start = end
+	}
+	return masked, false
+}
+
+// joinTokens returns the text spelled by tokens
+func joinTokens(tokens []TokenLogprob) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString(token.Token)
+	}
+	return sb.String()
+}
+
+func (l *Lexicon) action() LexiconAction {
+	if l.Action == "" {
+		return LexiconActionMask
+	}
+	return l.Action
+}
+
+// mask returns the replacement of each character of the terms masked by the lexicon
+func (l *Lexicon) mask() string {
+	if l.Mask == "" {
+		return "*"
+	}
+	return l.Mask
+}
+
+func (l *Lexicon) applies(labels map[string]string) bool {
+	for key, value := range l.When {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// lexiconMatcher finds the terms of the lexicons that apply to a request
+type lexiconMatcher struct {
+	// terms are sorted longest first, so the longest term found at a position is matched
+	terms []lexiconTerm
+}
+
+type lexiconTerm struct {
+	term    string
+	lexicon *Lexicon
+}
+
+// lexiconMatch is a term found in text at [start, end)
+type lexiconMatch struct {
+	lexiconTerm
+	start, end int
+}
+
+// matcher returns the matcher of the lexicons applying to labels, or nil if none does
+func (f *LexiconFilter) matcher(labels map[string]string) *lexiconMatcher {
+	if f == nil {
+		return nil
+	}
+	var terms []lexiconTerm
+	for _, lexicon := range f.Lexicons {
+		if lexicon == nil || !lexicon.applies(labels) {
+			continue
+		}
+		for _, term := range lexicon.Terms {
+			if term = strings.TrimSpace(term); term != "" {
+				terms = append(terms, lexiconTerm{term: term, lexicon: lexicon})
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(terms, func(a, b lexiconTerm) int {
+		return utf8.RuneCountInString(b.term) - utf8.RuneCountInString(a.term)
+	})
+	return &lexiconMatcher{terms: terms}
+}
+
+// scan returns the terms found in text, which follows the character prev, or 0 at the start
+// of the output. Unless final, scanning stops where a term may continue into the next chunk,
+// or a whole word term may be followed by more of its word, and safe is the length of the
+// text before it, which no later text can change the matches of. When final, safe is the
+// length of text.
+func (m *lexiconMatcher) scan(text string, prev rune, final bool) (matches []lexiconMatch, safe int) {
+	for i := 0; i < len(text); {
+		before := prev
+		if i > 0 {
+			before, _ = utf8.DecodeLastRuneInString(text[:i])
+		}
+		n := 0
+		for _, t := range m.terms {
+			if !t.lexicon.Substrings && isWordRune(before) {
+				continue
+			}
+			length, partial := foldPrefix(text[i:], t.term)
+			if partial && !final {
+				return matches, i
+			}
+			if length == 0 {
+				continue
+			}
+			if !t.lexicon.Substrings {
+				if i+length == len(text) && !final {
+					return matches, i
+				}
+				if next, _ := utf8.DecodeRuneInString(text[i+length:]); isWordRune(next) {
+					continue
+				}
+			}
+			matches = append(matches, lexiconMatch{lexiconTerm: t, start: i, end: i + length})
+			n = length
+			break
+		}
+		if n == 0 {
+			_, n = utf8.DecodeRuneInString(text[i:])
+		}
+		i += n
+	}
+	return matches, len(text)
+}
+
+// foldPrefix returns the length of the prefix of text matching term regardless of case, or 0
+// if text does not start with term. partial reports that text is a proper prefix of term.
+func foldPrefix(text, term string) (n int, partial bool) {
+	for i := 0; i < len(term); {
+		want, wantSize := utf8.DecodeRuneInString(term[i:])
+		if n == len(text) {
+			return 0, true
+		}
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if !unicode.IsSpace(want) {
+			if !equalFoldRune(r, want) {
+				return 0, false
+			}
+			i, n = i+wantSize, n+size
+			continue
+		}
+		// A run of whitespace matches any run of whitespace
+		if !unicode.IsSpace(r) {
+			return 0, false
+		}
+		i += wantSize + spaceLen(term[i+wantSize:])
+		n += size + spaceLen(text[n+size:])
+	}
+	return n, false
+}
+
+// spaceLen returns the length of the whitespace at the start of text
+func spaceLen(text string) int {
+	if i := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsSpace(r) }); i >= 0 {
+		return i
+	}
+	return len(text)
+}
+
+// equalFoldRune reports whether a and b are equal under simple Unicode case folding
+func equalFoldRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+// isWordRune reports whether r is part of a word, for whole word matching
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,256 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lexiconTestFilter(events *[]*LexiconEvent) *LexiconFilter {
+	return &LexiconFilter{
+		Lexicons: []*Lexicon{
+			{Name: "profanity", Terms: []string{"darn", "heck"}},
+			{Name: "competitors", Terms: []string{"Acme Corp"}, Action: LexiconActionHalt},
+			{Name: "kids", Terms: []string{"scary"}, Mask: "#", When: map[string]string{"deployment": "kids"}},
+		},
+		Audit: func(ctx context.Context, event *LexiconEvent) {
+			*events = append(*events, event)
+		},
+	}
+}
+
+func TestLexiconCompletionModel_Stream(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+		finish FinishReason
+	}{
+		{name: "within chunk", chunks: []string{"Oh darn it", " works"}, want: "Oh **** it works", finish: FinishReasonStop},
+		{name: "spanning chunks", chunks: []string{"Oh da", "rn", " it"}, want: "Oh **** it", finish: FinishReasonStop},
+		{name: "case insensitive", chunks: []string{"HECK", "!"}, want: "****!", finish: FinishReasonStop},
+		{name: "inside words", chunks: []string{"darn", "ed heckle"}, want: "darned heckle", finish: FinishReasonStop},
+		{name: "at end", chunks: []string{"well, dar", "n"}, want: "well, ****", finish: FinishReasonStop},
+		{name: "halt", chunks: []string{"Try ACME", "  corp's tools", " instead"}, want: "Try ", finish: FinishReasonContentFilter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := make([]StreamChunk, 0, len(tt.chunks)+1)
+			for _, text := range tt.chunks {
+				chunks = append(chunks, StreamTextChunk{Text: text})
+			}
+			chunks = append(chunks, StreamFinishChunk{Reason: FinishReasonStop})
+
+			var events []*LexiconEvent
+			model := NewLexiconCompletionModel(&chunkModel{chunks: chunks}, []CompletionOption{WithLexiconFilter(lexiconTestFilter(&events))})
+			stream, err := StreamCompletion(context.Background(), model, &CompletionRequest{})
+			require.NoError(t, err)
+			resp, err := stream.Final()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, resp.Output)
+			assert.Equal(t, tt.finish, resp.FinishReason)
+			if tt.finish == FinishReasonContentFilter {
+				require.Len(t, events, 1)
+				assert.Equal(t, "competitors", events[0].Lexicon)
+				assert.Equal(t, "Acme Corp", events[0].Term)
+				assert.Equal(t, LexiconActionHalt, events[0].Action)
+			}
+		})
+	}
+}
+
+func TestLexiconCompletionModel_StreamHaltReportsUsage(t *testing.T) {
+	upstream := &chunkModel{chunks: []StreamChunk{
+		StreamTextChunk{Text: "Buy from Ac"},
+		StreamTextChunk{Text: "me Corp"},
+		StreamTextChunk{Text: " today"},
+		StreamFinishChunk{Reason: FinishReasonStop},
+		StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 5}},
+	}}
+	var events []*LexiconEvent
+	model := NewLexiconCompletionModel(upstream, []CompletionOption{WithLexiconFilter(lexiconTestFilter(&events)), WithUsage(true)})
+	stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+
+	var text strings.Builder
+	var rest []StreamChunk
+	for chunk := range stream {
+		if c, ok := chunk.(StreamTextChunk); ok {
+			text.WriteString(c.Text)
+			continue
+		}
+		rest = append(rest, chunk)
+	}
+	assert.Equal(t, "Buy from ", text.String())
+	// The upstream request is canceled, so the usage is estimated from the text received
+	assert.Equal(t, []StreamChunk{
+		StreamFinishChunk{Reason: FinishReasonContentFilter},
+		StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: int64(HeuristicTokenizer{}.CountTokens("Buy from Acme Corp today")), TotalRequests: 1}},
+	}, rest)
+}
+
+func TestLexiconCompletionModel_StreamHaltCancelsUpstream(t *testing.T) {
+	upstream := &endlessModel{canceled: make(chan error, 1)}
+	filter := &LexiconFilter{Lexicons: []*Lexicon{{Name: "repeats", Terms: []string{"more more"}, Action: LexiconActionHalt}}}
+	model := NewLexiconCompletionModel(upstream, []CompletionOption{WithLexiconFilter(filter)})
+	stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+
+	var chunks []StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	select {
+	case err := <-upstream.canceled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("the upstream request was not canceled")
+	}
+	assert.Equal(t, []StreamChunk{StreamFinishChunk{Reason: FinishReasonContentFilter}}, chunks)
+}
+
+func TestLexiconCompletionModel_StreamLogprobsAndJSON(t *testing.T) {
+	logprob := func(tokens ...string) StreamLogprobChunk {
+		c := StreamLogprobChunk{}
+		for _, token := range tokens {
+			c.Logprobs = append(c.Logprobs, TokenLogprob{Token: token, Logprob: -0.1, TopLogprobs: []TokenLogprob{{Token: token, Logprob: -0.1}}})
+		}
+		return c
+	}
+	upstream := &chunkModel{chunks: []StreamChunk{
+		StreamTextChunk{Text: "oh da"},
+		logprob("oh", " da"),
+		StreamTextChunk{Text: "rn it"},
+		logprob("rn", " it"),
+		StreamJSONChunk{Path: "/a", Delta: "heck yes"},
+		StreamJSONChunk{Path: "/b", Value: map[string]any{"c": "darn"}, Partial: []byte(`{"a":"heck"}`)},
+		StreamFinishChunk{Reason: FinishReasonStop},
+	}}
+	var events []*LexiconEvent
+	model := NewLexiconCompletionModel(upstream, []CompletionOption{WithLexiconFilter(lexiconTestFilter(&events))})
+	stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+
+	var tokens []TokenLogprob
+	var jsonChunks []StreamJSONChunk
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case StreamLogprobChunk:
+			tokens = append(tokens, c.Logprobs...)
+		case StreamJSONChunk:
+			jsonChunks = append(jsonChunks, c)
+		}
+	}
+	// The term is split across tokens, which are masked and lose their alternatives
+	require.Len(t, tokens, 4)
+	assert.Equal(t, []string{"oh", " **", "**", " it"}, []string{tokens[0].Token, tokens[1].Token, tokens[2].Token, tokens[3].Token})
+	assert.NotEmpty(t, tokens[0].TopLogprobs)
+	assert.Empty(t, tokens[1].TopLogprobs)
+	assert.Empty(t, tokens[2].TopLogprobs)
+
+	require.Len(t, jsonChunks, 2)
+	assert.Equal(t, "**** yes", jsonChunks[0].Delta)
+	assert.Equal(t, map[string]any{"c": "****"}, jsonChunks[1].Value)
+	assert.JSONEq(t, `{"a":"****"}`, string(jsonChunks[1].Partial))
+	// Only the text is audited
+	assert.Len(t, events, 1)
+}
+
+func TestLexiconCompletionModel_CompleteLogprobs(t *testing.T) {
+	upstream := &stubCompletionModel{
+		output:   "ask Acme Corp",
+		logprobs: []TokenLogprob{{Token: "ask"}, {Token: " Ac"}, {Token: "me"}, {Token: " Corp"}},
+	}
+	model := NewLexiconCompletionModel(upstream, []CompletionOption{WithLexiconFilter(lexiconTestFilter(new([]*LexiconEvent)))})
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "ask ", resp.Output)
+	assert.Equal(t, []TokenLogprob{{Token: "ask"}, {Token: " "}}, resp.Logprobs)
+}
+
+func TestLexiconCompletionModel_Labels(t *testing.T) {
+	var events []*LexiconEvent
+	model := NewLexiconCompletionModel(&stubCompletionModel{output: "a scary heck"}, []CompletionOption{WithLexiconFilter(lexiconTestFilter(&events))})
+
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "a scary ****", resp.Output)
+
+	// Lexicons limited to a deployment apply to the requests labeled with it
+	ctx := ContextWithPolicyLabels(context.Background(), map[string]string{"deployment": "kids"})
+	resp, err = model.Complete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "a ##### ****", resp.Output)
+	require.Len(t, events, 3)
+	assert.Equal(t, "kids", events[1].Lexicon)
+	assert.Equal(t, map[string]string{"deployment": "kids"}, events[1].Labels)
+}
+
+func TestLexiconCompletionModel_Complete(t *testing.T) {
+	upstream := &stubCompletionModel{
+		output: "Heck, ask Acme Corp.",
+		choices: []CompletionChoice{
+			{Index: 0, Text: "Heck, ask Acme Corp.", FinishReason: FinishReasonStop},
+			{Index: 1, Text: "darn", FinishReason: FinishReasonStop},
+		},
+	}
+	var events []*LexiconEvent
+	model := NewLexiconCompletionModel(upstream, []CompletionOption{WithLexiconFilter(lexiconTestFilter(&events))})
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "****, ask ", resp.Output)
+	assert.Equal(t, FinishReasonContentFilter, resp.FinishReason)
+	assert.Equal(t, []CompletionChoice{
+		{Index: 0, Text: "****, ask ", FinishReason: FinishReasonContentFilter},
+		{Index: 1, Text: "****", FinishReason: FinishReasonStop},
+	}, resp.Choices)
+	// The first choice is the output, which is audited once
+	assert.Len(t, events, 3)
+}
+
+func TestLexiconMatcher_Substrings(t *testing.T) {
+	filter := &LexiconFilter{Lexicons: []*Lexicon{{Terms: []string{"ass"}, Substrings: true}, {Terms: []string{"   "}}}}
+	matches, safe := filter.matcher(nil).scan("class b", 0, false)
+	require.Len(t, matches, 1)
+	assert.Equal(t, 2, matches[0].start)
+	assert.Equal(t, 7, safe)
+
+	// Text that may start a term is held back
+	_, safe = filter.matcher(nil).scan("a cla", 0, false)
+	assert.Equal(t, 4, safe)
+	assert.Nil(t, (&LexiconFilter{}).matcher(nil))
+}
+
+func TestDefaultModelProvider_LexiconFilter(t *testing.T) {
+	info := &ModelInfo{ID: "gpt-4o"}
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{info})
+	var events []*LexiconEvent
+	model := provider.DecorateCompletionModel(&stubCompletionModel{output: "oh heck"}, info, []CompletionOption{WithLexiconFilter(lexiconTestFilter(&events))})
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "oh ****", resp.Output)
+	require.Len(t, events, 1)
+	assert.Equal(t, "openai", events[0].Provider)
+	assert.Equal(t, "gpt-4o", events[0].Model)
+
+	// A filter set per request applies to models created without one
+	model = provider.DecorateCompletionModel(&stubCompletionModel{output: "oh heck"}, info, nil)
+	ctx := ContextWithOptions(context.Background(), WithLexiconFilter(lexiconTestFilter(&events)))
+	resp, err = model.Complete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "oh ****", resp.Output)
+}
+
+func TestCacheKey_LexiconFilter(t *testing.T) {
+	var events []*LexiconEvent
+	options := ApplyCompletionOptions([]CompletionOption{WithLexiconFilter(lexiconTestFilter(&events))})
+	key, err := CacheKey("openai/gpt-4o", &CompletionRequest{}, options)
+	require.NoError(t, err)
+	unfiltered, err := CacheKey("openai/gpt-4o", &CompletionRequest{}, &CompletionOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, unfiltered, key)
+}
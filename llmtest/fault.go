@@ -0,0 +1,297 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llmtest
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+)
+
+type faultKind int
+
+const (
+	faultCut faultKind = iota
+	faultDelay
+	faultDuplicate
+	faultCorrupt
+)
+
+// Fault is a failure injected at a chunk of a stream, or at a frame of a server-sent events
+// response, counted from 0
+type Fault struct {
+	kind  faultKind
+	index int
+	delay time.Duration
+	err   error
+}
+
+// CutStream ends the stream in the middle of the chunk or frame at index: half of its text is
+// sent, followed by err. A nil err closes the stream without an error, like a dropped
+// connection.
+func CutStream(index int, err error) Fault {
+	return Fault{kind: faultCut, index: index, err: err}
+}
+
+// DelayChunk waits d before sending the chunk or frame at index
+func DelayChunk(index int, d time.Duration) Fault {
+	return Fault{kind: faultDelay, index: index, delay: d}
+}
+
+// DuplicateChunk sends the chunk or frame at index twice
+func DuplicateChunk(index int) Fault {
+	return Fault{kind: faultDuplicate, index: index}
+}
+
+// CorruptFrame truncates the data of the server-sent events frame at index, so its JSON
+// payload no longer parses. It only applies to a FaultyTransport.
+func CorruptFrame(index int) Fault {
+	return Fault{kind: faultCorrupt, index: index}
+}
+
+// faultsAt returns the faults injected at index
+func faultsAt(faults []Fault, index int) (delay time.Duration, cut *Fault, duplicate, corrupt bool) {
+	for i := range faults {
+		f := &faults[i]
+		if f.index != index {
+			continue
+		}
+		switch f.kind {
+		case faultCut:
+			cut = f
+		case faultDelay:
+			delay += f.delay
+		case faultDuplicate:
+			duplicate = true
+		case faultCorrupt:
+			corrupt = true
+		}
+	}
+	return delay, cut, duplicate, corrupt
+}
+
+// sleep waits d, returning false when ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// half returns the first half of s, cut between runes
+func half(s string) string {
+	runes := []rune(s)
+	return string(runes[:len(runes)/2])
+}
+
+// InjectFaults returns a stream that sends the chunks of stream with faults injected, to test
+// how consumers and middleware handle broken streams. A text chunk is cut in half by CutStream;
+// other chunks are dropped. The rest of stream is drained once the faulty stream ends.
+func InjectFaults(ctx context.Context, stream llm.StreamCompletionResponse, faults ...Fault) llm.StreamCompletionResponse {
+	ch := make(chan llm.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer func() {
+			go func() {
+				for range stream {
+				}
+			}()
+		}()
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		index := 0
+		for chunk := range stream {
+			delay, cut, duplicate, _ := faultsAt(faults, index)
+			if !sleep(ctx, delay) {
+				return
+			}
+			if cut != nil {
+				if text, ok := llm.StreamChunkText(chunk); ok && half(text) != "" {
+					if !send(llm.StreamTextChunk{Text: half(text)}) {
+						return
+					}
+				}
+				if cut.err != nil {
+					send(llm.StreamErrorChunk{Provider: "llmtest", Err: cut.err})
+				}
+				return
+			}
+			// A pooled chunk is released by its consumer, so the duplicate is a copy
+			if text, ok := chunk.(*llm.StreamTextBytesChunk); ok && duplicate {
+				duplicate = false
+				if !send(llm.NewStreamTextBytesChunk(string(text.Text))) {
+					return
+				}
+			}
+			if !send(chunk) || (duplicate && !send(chunk)) {
+				return
+			}
+			index++
+		}
+	}()
+	return ch
+}
+
+// FaultyCompletionModel is a CompletionModel whose streams have faults injected, see
+// InjectFaults. Complete is passed through.
+type FaultyCompletionModel struct {
+	model  llm.CompletionModel
+	faults []Fault
+}
+
+var _ llm.CompletionModel = (*FaultyCompletionModel)(nil)
+
+// NewFaultyCompletionModel wraps model, such as a MockCompletionModel or a provider model, to
+// inject faults into every stream it returns
+func NewFaultyCompletionModel(model llm.CompletionModel, faults ...Fault) *FaultyCompletionModel {
+	return &FaultyCompletionModel{model: model, faults: faults}
+}
+
+func (m *FaultyCompletionModel) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	return m.model.Complete(ctx, req)
+}
+
+func (m *FaultyCompletionModel) StreamComplete(ctx context.Context, req *llm.CompletionRequest) (llm.StreamCompletionResponse, error) {
+	stream, err := m.model.StreamComplete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return InjectFaults(ctx, stream, m.faults...), nil
+}
+
+// FaultyTransport is an http.RoundTripper that injects faults into the frames of server-sent
+// events responses, to test the stream parsing of providers against an httptest server or a
+// recorded stream. Other responses are passed through. Use it with
+// llm.WithRequestOption(option.WithHTTPClient(&http.Client{Transport: transport})).
+type FaultyTransport struct {
+	base   http.RoundTripper
+	faults []Fault
+}
+
+var _ http.RoundTripper = (*FaultyTransport)(nil)
+
+// NewFaultyTransport wraps base, or http.DefaultTransport when nil, to inject faults into every
+// event stream it receives
+func NewFaultyTransport(base http.RoundTripper, faults ...Fault) *FaultyTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &FaultyTransport{base: base, faults: faults}
+}
+
+func (t *FaultyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, err
+	}
+	resp.Body = &faultyBody{
+		ctx:    req.Context(),
+		body:   resp.Body,
+		reader: bufio.NewReader(resp.Body),
+		faults: t.faults,
+	}
+	return resp, nil
+}
+
+// faultyBody reads an event stream frame by frame, injecting the faults of each frame
+type faultyBody struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	reader  *bufio.Reader
+	faults  []Fault
+	index   int
+	pending []byte
+	err     error // returned once pending is read
+}
+
+func (b *faultyBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		b.nextFrame()
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// nextFrame reads the next frame into pending and applies its faults
+func (b *faultyBody) nextFrame() {
+	frame, err := b.readFrame()
+	if err != nil {
+		b.pending, b.err = []byte(frame), err
+		return
+	}
+	delay, cut, duplicate, corrupt := faultsAt(b.faults, b.index)
+	b.index++
+	if !sleep(b.ctx, delay) {
+		b.err = b.ctx.Err()
+		return
+	}
+	if corrupt {
+		frame = corruptFrame(frame)
+	}
+	switch {
+	case cut != nil:
+		b.pending, b.err = []byte(half(frame)), cut.err
+		if b.err == nil {
+			b.err = io.EOF
+		}
+	case duplicate:
+		b.pending = []byte(frame + frame)
+	default:
+		b.pending = []byte(frame)
+	}
+}
+
+// readFrame reads the lines of a frame up to and including the blank line that ends it
+func (b *faultyBody) readFrame() (string, error) {
+	var frame strings.Builder
+	for {
+		line, err := b.reader.ReadString('\n')
+		frame.WriteString(line)
+		if err != nil {
+			return frame.String(), err
+		}
+		if line == "\n" || line == "\r\n" {
+			return frame.String(), nil
+		}
+	}
+}
+
+// corruptFrame truncates the value of every data line of frame
+func corruptFrame(frame string) string {
+	lines := strings.SplitAfter(frame, "\n")
+	for i, line := range lines {
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			data = strings.TrimRight(data, "\r\n")
+			lines[i] = "data:" + half(data) + "\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func (b *faultyBody) Close() error {
+	return b.body.Close()
+}
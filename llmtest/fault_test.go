@@ -0,0 +1,150 @@
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/openai"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func faultyStream(t *testing.T, faults ...Fault) []llm.StreamChunk {
+	t.Helper()
+	provider := NewMockModelProvider().AddStream(
+		llm.StreamTextChunk{Text: "Hello"},
+		llm.StreamTextChunk{Text: " world"},
+		llm.StreamUsageChunk{Usage: &llm.TokenUsage{TotalOutputTokens: 2}},
+	)
+	mock, err := provider.NewCompletionModel("any")
+	require.NoError(t, err)
+	stream, err := NewFaultyCompletionModel(mock, faults...).StreamComplete(context.Background(), &llm.CompletionRequest{})
+	require.NoError(t, err)
+	return collect(t, stream)
+}
+
+func TestFaultyCompletionModel(t *testing.T) {
+	errReset := errors.New("connection reset")
+	assert.Equal(t, []llm.StreamChunk{
+		llm.StreamTextChunk{Text: "Hello"},
+		llm.StreamTextChunk{Text: " wo"},
+		llm.StreamErrorChunk{Provider: "llmtest", Err: errReset},
+	}, faultyStream(t, CutStream(1, errReset)))
+
+	assert.Equal(t, []llm.StreamChunk{
+		llm.StreamTextChunk{Text: "Hello"},
+		llm.StreamTextChunk{Text: " world"},
+	}, faultyStream(t, CutStream(2, nil)), "a stream cut at a usage chunk closes without it")
+
+	assert.Equal(t, []llm.StreamChunk{
+		llm.StreamTextChunk{Text: "Hello"},
+		llm.StreamTextChunk{Text: "Hello"},
+		llm.StreamTextChunk{Text: " world"},
+		llm.StreamUsageChunk{Usage: &llm.TokenUsage{TotalOutputTokens: 2}},
+	}, faultyStream(t, DuplicateChunk(0)))
+
+	// Duplicates of pooled chunks are copies, so each can be released
+	provider := NewMockModelProvider().AddStream(llm.StreamTextChunk{Text: "Hello"})
+	mock, err := provider.NewCompletionModel("any", llm.WithPooledTextChunks(true))
+	require.NoError(t, err)
+	stream, err := NewFaultyCompletionModel(mock, DuplicateChunk(0)).StreamComplete(context.Background(), &llm.CompletionRequest{})
+	require.NoError(t, err)
+	pooled := collect(t, stream)
+	require.Len(t, pooled, 2)
+	assert.NotSame(t, pooled[0], pooled[1])
+	assert.Equal(t, pooled[0], pooled[1])
+
+	start := time.Now()
+	chunks := faultyStream(t, DelayChunk(1, 50*time.Millisecond))
+	assert.Len(t, chunks, 3)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestInjectFaults_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := make(chan llm.StreamChunk, 1)
+	source <- llm.StreamTextChunk{Text: "slow"}
+	close(source)
+
+	stream := InjectFaults(ctx, source, DelayChunk(0, time.Minute))
+	cancel()
+	assert.Empty(t, collect(t, stream))
+}
+
+const events = "data: {\"n\":1}\n\ndata: {\"n\":2}\n\ndata: [DONE]\n\n"
+
+func readEvents(t *testing.T, faults ...Fault) (string, error) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, events)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewFaultyTransport(nil, faults...)}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestFaultyTransport(t *testing.T) {
+	body, err := readEvents(t)
+	require.NoError(t, err)
+	assert.Equal(t, events, body)
+
+	body, err = readEvents(t, CorruptFrame(1))
+	require.NoError(t, err)
+	assert.Equal(t, "data: {\"n\":1}\n\ndata: {\"n\n\ndata: [DONE]\n\n", body)
+
+	body, err = readEvents(t, DuplicateChunk(0))
+	require.NoError(t, err)
+	assert.Equal(t, "data: {\"n\":1}\n\ndata: {\"n\":1}\n\ndata: {\"n\":2}\n\ndata: [DONE]\n\n", body)
+
+	errReset := errors.New("connection reset")
+	body, err = readEvents(t, CutStream(1, errReset))
+	assert.ErrorIs(t, err, errReset)
+	assert.Equal(t, "data: {\"n\":1}\n\ndata: {", body)
+
+	body, err = readEvents(t, CutStream(0, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "data: {", body)
+}
+
+func TestFaultyTransport_ProviderStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, text := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", text)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := openai.NewBaseOpenAIModelProvider("openai", []*llm.ModelInfo{{ID: "m"}}, []option.RequestOption{
+		option.WithAPIKey("test-api-key"),
+		option.WithBaseURL(server.URL),
+		option.WithHTTPClient(&http.Client{Transport: NewFaultyTransport(nil, CorruptFrame(1))}),
+	})
+	require.NoError(t, err)
+	model, err := provider.NewCompletionModel("m")
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{
+		Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	chunks := collect(t, stream)
+	require.NotEmpty(t, chunks)
+	assert.Equal(t, llm.StreamTextChunk{Text: "Hel"}, chunks[0])
+	assert.Equal(t, llm.ErrorChunkType, chunks[len(chunks)-1].Type(), "the corrupt frame ends the stream with an error")
+}
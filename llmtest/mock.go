@@ -0,0 +1,665 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package llmtest provides a scripted model provider for testing code that uses llm without
+// network access or API keys.
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/easyagent-dev/llm"
+)
+
+// ErrNoReply is returned by mock models when no scripted reply is left
+var ErrNoReply = errors.New("llmtest: no scripted reply")
+
+// Reply is a scripted answer to one completion or conversation request. Exactly one of
+// Response, Chunks and Err is expected to be set.
+type Reply struct {
+	// Response is returned by Complete, or streamed as a text and a usage chunk
+	Response *llm.CompletionResponse
+	// Chunks are sent by StreamComplete, or joined into the output of Complete
+	Chunks []llm.StreamChunk
+	// Err is returned instead of a response
+	Err error
+}
+
+// CompletionCall is a request received by a mock completion or conversation model
+type CompletionCall struct {
+	Model   string
+	Options *llm.CompletionOptions
+	Request *llm.CompletionRequest
+	// Conversation is the request when the call was made through a conversation model
+	Conversation *llm.ConversationRequest
+	Stream       bool
+}
+
+// EmbeddingCall is a request received by a mock embedding model
+type EmbeddingCall struct {
+	Model   string
+	Request *llm.EmbeddingRequest
+}
+
+// ImageCall is a request received by a mock image model. Exactly one of Request, Edit,
+// Variation and JobID is set; Request is also set for jobs started with StartImage.
+type ImageCall struct {
+	Model     string
+	Request   *llm.ImageRequest
+	Edit      *llm.ImageEditRequest
+	Variation *llm.ImageVariationRequest
+	// JobID is the job resumed with ResumeImage
+	JobID string
+}
+
+// SpeechCall is a request received by a mock speech model
+type SpeechCall struct {
+	Model   string
+	Request *llm.SpeechRequest
+}
+
+// VideoCall is a request received by a mock video model
+type VideoCall struct {
+	Model   string
+	Request *llm.VideoRequest
+}
+
+type embeddingReply struct {
+	response *llm.EmbeddingResponse
+	err      error
+}
+
+type imageReply struct {
+	response *llm.ImageResponse
+	err      error
+}
+
+type speechReply struct {
+	response *llm.SpeechResponse
+	err      error
+}
+
+type videoReply struct {
+	response *llm.VideoResponse
+	err      error
+}
+
+// MockModelProvider is a ModelProvider whose models answer with scripted replies in the order
+// they were added and record every request. Replies are shared by all models of the provider.
+// It is safe for concurrent use.
+type MockModelProvider struct {
+	*llm.DefaultModelProvider
+
+	mu              sync.Mutex
+	replies         []*Reply
+	embeddings      []*embeddingReply
+	images          []*imageReply
+	speeches        []*speechReply
+	videos          []*videoReply
+	completionCalls []*CompletionCall
+	embeddingCalls  []*EmbeddingCall
+	imageCalls      []*ImageCall
+	speechCalls     []*SpeechCall
+	videoCalls      []*VideoCall
+}
+
+var _ llm.ModelProvider = (*MockModelProvider)(nil)
+
+// NewMockModelProvider creates a mock provider named "mock". Without models any model name is
+// accepted; otherwise unknown models fail with llm.ErrInvalidModel like a real provider.
+func NewMockModelProvider(models ...*llm.ModelInfo) *MockModelProvider {
+	return &MockModelProvider{
+		DefaultModelProvider: llm.NewDefaultModelProvider("mock", models),
+	}
+}
+
+// AddReply queues replies for completion and conversation requests
+func (p *MockModelProvider) AddReply(replies ...*Reply) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replies = append(p.replies, replies...)
+	return p
+}
+
+// AddText queues a response with the given output
+func (p *MockModelProvider) AddText(output string) *MockModelProvider {
+	return p.AddReply(&Reply{Response: &llm.CompletionResponse{Output: output}})
+}
+
+// AddResponse queues a completion response
+func (p *MockModelProvider) AddResponse(resp *llm.CompletionResponse) *MockModelProvider {
+	return p.AddReply(&Reply{Response: resp})
+}
+
+// AddStream queues stream chunks. End them with an llm.StreamErrorChunk to simulate a
+// stream that fails after it was opened.
+func (p *MockModelProvider) AddStream(chunks ...llm.StreamChunk) *MockModelProvider {
+	return p.AddReply(&Reply{Chunks: chunks})
+}
+
+// AddError queues an error for the next completion or conversation request
+func (p *MockModelProvider) AddError(err error) *MockModelProvider {
+	return p.AddReply(&Reply{Err: err})
+}
+
+// AddEmbeddings queues an embedding response
+func (p *MockModelProvider) AddEmbeddings(resp *llm.EmbeddingResponse) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.embeddings = append(p.embeddings, &embeddingReply{response: resp})
+	return p
+}
+
+// AddEmbeddingError queues an error for the next embedding request
+func (p *MockModelProvider) AddEmbeddingError(err error) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.embeddings = append(p.embeddings, &embeddingReply{err: err})
+	return p
+}
+
+// AddImage queues an image response, for generation, edit and variation requests alike
+func (p *MockModelProvider) AddImage(resp *llm.ImageResponse) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.images = append(p.images, &imageReply{response: resp})
+	return p
+}
+
+// AddImageError queues an error for the next image request
+func (p *MockModelProvider) AddImageError(err error) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.images = append(p.images, &imageReply{err: err})
+	return p
+}
+
+// AddSpeech queues a speech response
+func (p *MockModelProvider) AddSpeech(resp *llm.SpeechResponse) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.speeches = append(p.speeches, &speechReply{response: resp})
+	return p
+}
+
+// AddSpeechError queues an error for the next speech request
+func (p *MockModelProvider) AddSpeechError(err error) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.speeches = append(p.speeches, &speechReply{err: err})
+	return p
+}
+
+// AddVideo queues a video response
+func (p *MockModelProvider) AddVideo(resp *llm.VideoResponse) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.videos = append(p.videos, &videoReply{response: resp})
+	return p
+}
+
+// AddVideoError queues an error for the next video request
+func (p *MockModelProvider) AddVideoError(err error) *MockModelProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.videos = append(p.videos, &videoReply{err: err})
+	return p
+}
+
+// CompletionCalls returns the completion and conversation requests received so far
+func (p *MockModelProvider) CompletionCalls() []*CompletionCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*CompletionCall(nil), p.completionCalls...)
+}
+
+// LastCompletionCall returns the most recent completion or conversation request, or nil
+func (p *MockModelProvider) LastCompletionCall() *CompletionCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.completionCalls) == 0 {
+		return nil
+	}
+	return p.completionCalls[len(p.completionCalls)-1]
+}
+
+// EmbeddingCalls returns the embedding requests received so far
+func (p *MockModelProvider) EmbeddingCalls() []*EmbeddingCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*EmbeddingCall(nil), p.embeddingCalls...)
+}
+
+// ImageCalls returns the image requests received so far
+func (p *MockModelProvider) ImageCalls() []*ImageCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*ImageCall(nil), p.imageCalls...)
+}
+
+// SpeechCalls returns the speech requests received so far
+func (p *MockModelProvider) SpeechCalls() []*SpeechCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*SpeechCall(nil), p.speechCalls...)
+}
+
+// VideoCalls returns the video requests received so far
+func (p *MockModelProvider) VideoCalls() []*VideoCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*VideoCall(nil), p.videoCalls...)
+}
+
+// Pending returns the number of completion and conversation replies not consumed yet
+func (p *MockModelProvider) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.replies)
+}
+
+// Reset drops all queued replies and recorded calls
+func (p *MockModelProvider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replies = nil
+	p.embeddings = nil
+	p.images = nil
+	p.speeches = nil
+	p.videos = nil
+	p.completionCalls = nil
+	p.embeddingCalls = nil
+	p.imageCalls = nil
+	p.speechCalls = nil
+	p.videoCalls = nil
+}
+
+func (p *MockModelProvider) checkModel(model string) error {
+	if len(p.SupportedModels()) > 0 && p.GetModelInfo(model) == nil {
+		return llm.ErrInvalidModel
+	}
+	return nil
+}
+
+func (p *MockModelProvider) NewCompletionModel(model string, opts ...llm.CompletionOption) (llm.CompletionModel, error) {
+	if err := p.checkModel(model); err != nil {
+		return nil, err
+	}
+	return &MockCompletionModel{
+		provider: p,
+		model:    model,
+		options:  opts,
+	}, nil
+}
+
+func (p *MockModelProvider) NewConversationModel(model string, opts ...llm.ResponseOption) (llm.ConversationModel, error) {
+	if err := p.checkModel(model); err != nil {
+		return nil, err
+	}
+	return &MockConversationModel{
+		provider: p,
+		model:    model,
+		options:  opts,
+	}, nil
+}
+
+func (p *MockModelProvider) NewEmbeddingModel(model string) (llm.EmbeddingModel, error) {
+	if err := p.checkModel(model); err != nil {
+		return nil, err
+	}
+	return &MockEmbeddingModel{provider: p, model: model}, nil
+}
+
+func (p *MockModelProvider) NewImageModel(model string) (llm.ImageModel, error) {
+	if err := p.checkModel(model); err != nil {
+		return nil, err
+	}
+	return &MockImageModel{provider: p, model: model}, nil
+}
+
+func (p *MockModelProvider) NewImageEditModel(model string) (llm.ImageEditModel, error) {
+	if err := p.checkModel(model); err != nil {
+		return nil, err
+	}
+	return &MockImageModel{provider: p, model: model}, nil
+}
+
+func (p *MockModelProvider) NewImageJobModel(model string) (llm.ImageJobModel, error) {
+	if err := p.checkModel(model); err != nil {
+		return nil, err
+	}
+	return &MockImageModel{provider: p, model: model}, nil
+}
+
+func (p *MockModelProvider) NewSpeechModel(model string) (llm.SpeechModel, error) {
+	if err := p.checkModel(model); err != nil {
+		return nil, err
+	}
+	return &MockSpeechModel{provider: p, model: model}, nil
+}
+
+func (p *MockModelProvider) NewVideoModel(model string) (llm.VideoModel, error) {
+	if err := p.checkModel(model); err != nil {
+		return nil, err
+	}
+	return &MockVideoModel{provider: p, model: model}, nil
+}
+
+// nextReply records call and returns the next scripted reply
+func (p *MockModelProvider) nextReply(call *CompletionCall) *Reply {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completionCalls = append(p.completionCalls, call)
+	if len(p.replies) == 0 {
+		return &Reply{Err: ErrNoReply}
+	}
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	return reply
+}
+
+// MockCompletionModel is a CompletionModel that answers with the replies of its provider
+type MockCompletionModel struct {
+	provider *MockModelProvider
+	model    string
+	options  []llm.CompletionOption
+}
+
+var _ llm.CompletionModel = (*MockCompletionModel)(nil)
+
+func (m *MockCompletionModel) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	options := llm.ApplyContextCompletionOptions(ctx, m.options)
+	reply := m.provider.nextReply(&CompletionCall{Model: m.model, Options: options, Request: req})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return reply.response()
+}
+
+func (m *MockCompletionModel) StreamComplete(ctx context.Context, req *llm.CompletionRequest) (llm.StreamCompletionResponse, error) {
+	options := llm.ApplyContextCompletionOptions(ctx, m.options)
+	reply := m.provider.nextReply(&CompletionCall{Model: m.model, Options: options, Request: req, Stream: true})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+	return stream(ctx, reply.chunks(), options), nil
+}
+
+// MockConversationModel is a ConversationModel that answers with the replies of its provider
+type MockConversationModel struct {
+	provider *MockModelProvider
+	model    string
+	options  []llm.ResponseOption
+}
+
+var _ llm.ConversationModel = (*MockConversationModel)(nil)
+
+func (m *MockConversationModel) Response(ctx context.Context, req *llm.ConversationRequest) (*llm.ConversationResponse, error) {
+	options := llm.ApplyContextResponseOptions(ctx, m.options)
+	reply := m.provider.nextReply(&CompletionCall{Model: m.model, Options: options.CompletionOptions, Conversation: req})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp, err := reply.response()
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ConversationResponse{Output: resp.Output, Usage: resp.Usage, Cost: resp.Cost}, nil
+}
+
+func (m *MockConversationModel) StreamResponse(ctx context.Context, req *llm.ConversationRequest) (llm.StreamConversationResponse, error) {
+	options := llm.ApplyContextResponseOptions(ctx, m.options)
+	reply := m.provider.nextReply(&CompletionCall{Model: m.model, Options: options.CompletionOptions, Conversation: req, Stream: true})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+	return llm.StreamConversationResponse(stream(ctx, reply.chunks(), options.CompletionOptions)), nil
+}
+
+// response returns the reply as a complete response, joining the text of scripted chunks.
+// An error chunk in the script is returned as the error.
+func (r *Reply) response() (*llm.CompletionResponse, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if r.Response != nil {
+		return r.Response, nil
+	}
+	resp := &llm.CompletionResponse{}
+	var output strings.Builder
+	for _, chunk := range r.Chunks {
+		switch c := chunk.(type) {
+		case llm.StreamTextChunk:
+			output.WriteString(c.Text)
+		case *llm.StreamTextBytesChunk:
+			output.Write(c.Text)
+		case llm.StreamUsageChunk:
+			resp.Usage = c.Usage
+			resp.Cost = c.Cost
+		case llm.StreamFinishChunk:
+			resp.FinishReason, resp.Refusal, resp.ID = c.Reason, c.Refusal, c.ID
+		case llm.StreamLogprobChunk:
+			resp.Logprobs = append(resp.Logprobs, c.Logprobs...)
+		case llm.StreamErrorChunk:
+			return nil, c
+		}
+	}
+	resp.Output = output.String()
+	return resp, nil
+}
+
+// chunks returns the reply as stream chunks, splitting a scripted response into its text,
+// finish reason and usage
+func (r *Reply) chunks() []llm.StreamChunk {
+	if r.Response == nil {
+		return r.Chunks
+	}
+	var chunks []llm.StreamChunk
+	if r.Response.Output != "" {
+		chunks = append(chunks, llm.StreamTextChunk{Text: r.Response.Output})
+	}
+	if r.Response.FinishReason != "" || r.Response.Refusal != "" || r.Response.ID != "" {
+		chunks = append(chunks, llm.StreamFinishChunk{Reason: r.Response.FinishReason, Refusal: r.Response.Refusal, ID: r.Response.ID})
+	}
+	if r.Response.Usage != nil || r.Response.Cost != nil {
+		chunks = append(chunks, llm.StreamUsageChunk{Usage: r.Response.Usage, Cost: r.Response.Cost})
+	}
+	return chunks
+}
+
+// stream sends chunks on a new channel, skipping the types options exclude, until ctx is done.
+// Text chunks are sent pooled when requested with WithPooledTextChunks.
+func stream(ctx context.Context, chunks []llm.StreamChunk, options *llm.CompletionOptions) llm.StreamCompletionResponse {
+	ch := make(chan llm.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer llm.SendStreamCanceled(ctx, ch, "mock")
+		for _, chunk := range chunks {
+			if !options.StreamsEvent(chunk.Type()) {
+				continue
+			}
+			if text, ok := chunk.(llm.StreamTextChunk); ok {
+				chunk = options.TextChunk(text.Text)
+			}
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// MockEmbeddingModel is an EmbeddingModel that answers with the embedding replies of its provider
+type MockEmbeddingModel struct {
+	provider *MockModelProvider
+	model    string
+}
+
+var _ llm.EmbeddingModel = (*MockEmbeddingModel)(nil)
+
+func (m *MockEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	p := m.provider
+	p.mu.Lock()
+	p.embeddingCalls = append(p.embeddingCalls, &EmbeddingCall{Model: m.model, Request: req})
+	reply := &embeddingReply{err: ErrNoReply}
+	if len(p.embeddings) > 0 {
+		reply = p.embeddings[0]
+		p.embeddings = p.embeddings[1:]
+	}
+	p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return reply.response, reply.err
+}
+
+// MockImageModel is an ImageEditModel that answers with the image replies of its provider
+type MockImageModel struct {
+	provider *MockModelProvider
+	model    string
+}
+
+var (
+	_ llm.ImageEditModel = (*MockImageModel)(nil)
+	_ llm.ImageJobModel  = (*MockImageModel)(nil)
+)
+
+func (m *MockImageModel) GenerateImage(ctx context.Context, req *llm.ImageRequest) (*llm.ImageResponse, error) {
+	return m.reply(ctx, &ImageCall{Model: m.model, Request: req})
+}
+
+func (m *MockImageModel) EditImage(ctx context.Context, req *llm.ImageEditRequest) (*llm.ImageResponse, error) {
+	return m.reply(ctx, &ImageCall{Model: m.model, Edit: req})
+}
+
+func (m *MockImageModel) CreateVariation(ctx context.Context, req *llm.ImageVariationRequest) (*llm.ImageResponse, error) {
+	return m.reply(ctx, &ImageCall{Model: m.model, Variation: req})
+}
+
+// StartImage returns a job that succeeds with the next image reply when it is first polled
+func (m *MockImageModel) StartImage(ctx context.Context, req *llm.ImageRequest) (*llm.ImageJob, error) {
+	return m.job(ctx, &ImageCall{Model: m.model, Request: req})
+}
+
+// ResumeImage returns a job that succeeds with the next image reply when it is first polled
+func (m *MockImageModel) ResumeImage(ctx context.Context, id string, config *llm.ImageModelConfig) (*llm.ImageJob, error) {
+	return m.job(ctx, &ImageCall{Model: m.model, JobID: id})
+}
+
+// job records call and returns a job answering with the next image reply
+func (m *MockImageModel) job(ctx context.Context, call *ImageCall) (*llm.ImageJob, error) {
+	resp, err := m.reply(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	id := call.JobID
+	if id == "" {
+		id = fmt.Sprintf("mock-job-%d", len(m.provider.ImageCalls()))
+	}
+	return llm.NewImageJob(id, &mockImageJob{response: resp}), nil
+}
+
+// mockImageJob is the backend of the jobs of a MockImageModel
+type mockImageJob struct {
+	mu       sync.Mutex
+	response *llm.ImageResponse
+	canceled bool
+}
+
+func (j *mockImageJob) PollImageJob(ctx context.Context, id string) (*llm.JobProgress, *llm.ImageResponse, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.canceled {
+		return &llm.JobProgress{ID: id, Status: llm.JobStatusCanceled}, nil, nil
+	}
+	percent := 100.0
+	return &llm.JobProgress{ID: id, Status: llm.JobStatusSucceeded, Percent: &percent}, j.response, nil
+}
+
+func (j *mockImageJob) CancelImageJob(ctx context.Context, id string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.canceled = true
+	return nil
+}
+
+// reply records call and returns the next image reply
+func (m *MockImageModel) reply(ctx context.Context, call *ImageCall) (*llm.ImageResponse, error) {
+	p := m.provider
+	p.mu.Lock()
+	p.imageCalls = append(p.imageCalls, call)
+	reply := &imageReply{err: ErrNoReply}
+	if len(p.images) > 0 {
+		reply = p.images[0]
+		p.images = p.images[1:]
+	}
+	p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return reply.response, reply.err
+}
+
+// MockSpeechModel is a SpeechModel that answers with the speech replies of its provider
+type MockSpeechModel struct {
+	provider *MockModelProvider
+	model    string
+}
+
+var _ llm.SpeechModel = (*MockSpeechModel)(nil)
+
+func (m *MockSpeechModel) GenerateSpeech(ctx context.Context, req *llm.SpeechRequest) (*llm.SpeechResponse, error) {
+	p := m.provider
+	p.mu.Lock()
+	p.speechCalls = append(p.speechCalls, &SpeechCall{Model: m.model, Request: req})
+	reply := &speechReply{err: ErrNoReply}
+	if len(p.speeches) > 0 {
+		reply = p.speeches[0]
+		p.speeches = p.speeches[1:]
+	}
+	p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return reply.response, reply.err
+}
+
+// MockVideoModel is a VideoModel that answers with the video replies of its provider
+type MockVideoModel struct {
+	provider *MockModelProvider
+	model    string
+}
+
+var _ llm.VideoModel = (*MockVideoModel)(nil)
+
+func (m *MockVideoModel) GenerateVideo(ctx context.Context, req *llm.VideoRequest) (*llm.VideoResponse, error) {
+	p := m.provider
+	p.mu.Lock()
+	p.videoCalls = append(p.videoCalls, &VideoCall{Model: m.model, Request: req})
+	reply := &videoReply{err: ErrNoReply}
+	if len(p.videos) > 0 {
+		reply = p.videos[0]
+		p.videos = p.videos[1:]
+	}
+	p.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return reply.response, reply.err
+}
@@ -0,0 +1,200 @@
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(t *testing.T, stream llm.StreamCompletionResponse) []llm.StreamChunk {
+	t.Helper()
+	var chunks []llm.StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestMockModelProvider_Complete(t *testing.T) {
+	ctx := context.Background()
+	errRateLimited := errors.New("rate limited")
+	provider := NewMockModelProvider().
+		AddText("hello").
+		AddError(errRateLimited).
+		AddStream(llm.StreamTextChunk{Text: "a"}, llm.StreamTextChunk{Text: "b"})
+
+	model, err := provider.NewCompletionModel("any", llm.WithTemperature(0.5))
+	require.NoError(t, err)
+
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "hi"}}}
+	resp, err := model.Complete(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Output)
+
+	_, err = model.Complete(ctx, req)
+	assert.ErrorIs(t, err, errRateLimited)
+
+	// A scripted stream is joined for Complete
+	resp, err = model.Complete(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "ab", resp.Output)
+
+	_, err = model.Complete(ctx, req)
+	assert.ErrorIs(t, err, ErrNoReply)
+
+	calls := provider.CompletionCalls()
+	require.Len(t, calls, 4)
+	assert.Equal(t, "any", calls[0].Model)
+	assert.Same(t, req, calls[0].Request)
+	assert.Equal(t, 0.5, *calls[0].Options.Temperature)
+	assert.Equal(t, 0, provider.Pending())
+}
+
+func TestMockModelProvider_ContextOptions(t *testing.T) {
+	provider := NewMockModelProvider().AddText("hello")
+	model, err := provider.NewCompletionModel("any", llm.WithTemperature(0.5))
+	require.NoError(t, err)
+
+	ctx := llm.ContextWithOptions(context.Background(), llm.WithTemperature(0), llm.WithMaxTokens(10))
+	_, err = model.Complete(ctx, &llm.CompletionRequest{})
+	require.NoError(t, err)
+
+	calls := provider.CompletionCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, 0.0, *calls[0].Options.Temperature)
+	assert.Equal(t, 10, *calls[0].Options.MaxTokens)
+}
+
+func TestMockModelProvider_StreamComplete(t *testing.T) {
+	ctx := context.Background()
+	streamErr := llm.StreamErrorChunk{Provider: "mock", Err: errors.New("connection reset")}
+	provider := NewMockModelProvider().
+		AddResponse(&llm.CompletionResponse{Output: "hello", Usage: &llm.TokenUsage{TotalOutputTokens: 1}}).
+		AddStream(llm.StreamTextChunk{Text: "partial"}, streamErr).
+		AddStream(llm.StreamReasoningChunk{Reasoning: "thinking"}, llm.StreamTextChunk{Text: "text"})
+
+	model, err := provider.NewCompletionModel("any")
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(ctx, &llm.CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []llm.StreamChunk{
+		llm.StreamTextChunk{Text: "hello"},
+		llm.StreamUsageChunk{Usage: &llm.TokenUsage{TotalOutputTokens: 1}},
+	}, collect(t, stream))
+
+	stream, err = model.StreamComplete(ctx, &llm.CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []llm.StreamChunk{llm.StreamTextChunk{Text: "partial"}, streamErr}, collect(t, stream))
+
+	textOnly, err := provider.NewCompletionModel("any", llm.WithStreamEvents(llm.TextChunkType))
+	require.NoError(t, err)
+	stream, err = textOnly.StreamComplete(ctx, &llm.CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, []llm.StreamChunk{llm.StreamTextChunk{Text: "text"}}, collect(t, stream))
+
+	assert.True(t, provider.LastCompletionCall().Stream)
+}
+
+func TestMockModelProvider_PooledTextChunks(t *testing.T) {
+	provider := NewMockModelProvider().AddStream(llm.StreamTextChunk{Text: "Hel"}, llm.StreamTextChunk{Text: "lo"})
+	model, err := provider.NewCompletionModel("any", llm.WithPooledTextChunks(true))
+	require.NoError(t, err)
+
+	stream, err := model.StreamComplete(context.Background(), &llm.CompletionRequest{})
+	require.NoError(t, err)
+	var text string
+	for chunk := range stream {
+		c, ok := chunk.(*llm.StreamTextBytesChunk)
+		require.True(t, ok)
+		text += string(c.Text)
+		c.Release()
+	}
+	assert.Equal(t, "Hello", text)
+}
+
+func TestMockModelProvider_Conversation(t *testing.T) {
+	provider := NewMockModelProvider().AddText("hi there")
+
+	model, err := provider.NewConversationModel("any")
+	require.NoError(t, err)
+
+	req := &llm.ConversationRequest{Input: "hello"}
+	resp, err := model.Response(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "hi there", resp.Output)
+	assert.Same(t, req, provider.LastCompletionCall().Conversation)
+}
+
+func TestMockModelProvider_EmbeddingsImagesSpeechAndVideo(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMockModelProvider().
+		AddEmbeddings(&llm.EmbeddingResponse{Embeddings: []llm.Embedding{{Embedding: []float64{1, 0}}}}).
+		AddEmbeddingError(llm.ErrInvalidRequest).
+		AddImage(&llm.ImageResponse{Output: []byte("png")}).
+		AddSpeech(&llm.SpeechResponse{Output: []byte("mp3"), ContentType: "audio/mpeg"}).
+		AddVideo(&llm.VideoResponse{URL: "https://example.com/v.mp4", ContentType: "video/mp4"})
+
+	embeddingModel, err := provider.NewEmbeddingModel("embed")
+	require.NoError(t, err)
+	req := &llm.EmbeddingRequest{Contents: []string{"a"}}
+	resp, err := embeddingModel.GenerateEmbeddings(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 0}, resp.Embeddings[0].Embedding)
+	_, err = embeddingModel.GenerateEmbeddings(ctx, req)
+	assert.ErrorIs(t, err, llm.ErrInvalidRequest)
+	assert.Len(t, provider.EmbeddingCalls(), 2)
+
+	imageModel, err := provider.NewImageModel("image")
+	require.NoError(t, err)
+	image, err := imageModel.GenerateImage(ctx, &llm.ImageRequest{Instructions: "a cat"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("png"), image.Output)
+	assert.Equal(t, "a cat", provider.ImageCalls()[0].Request.Instructions)
+	_, err = imageModel.GenerateImage(ctx, &llm.ImageRequest{})
+	assert.ErrorIs(t, err, ErrNoReply)
+
+	editModel, err := provider.NewImageEditModel("image")
+	require.NoError(t, err)
+	provider.AddImage(&llm.ImageResponse{Output: []byte("edited")})
+	edited, err := editModel.EditImage(ctx, &llm.ImageEditRequest{Instructions: "add a hat"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("edited"), edited.Output)
+	assert.Equal(t, "add a hat", provider.ImageCalls()[2].Edit.Instructions)
+	_, err = editModel.CreateVariation(ctx, &llm.ImageVariationRequest{})
+	assert.ErrorIs(t, err, ErrNoReply)
+	assert.NotNil(t, provider.ImageCalls()[3].Variation)
+
+	speechModel, err := provider.NewSpeechModel("speech")
+	require.NoError(t, err)
+	speech, err := speechModel.GenerateSpeech(ctx, &llm.SpeechRequest{Text: "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("mp3"), speech.Output)
+	assert.Equal(t, "hello", provider.SpeechCalls()[0].Request.Text)
+
+	videoModel, err := provider.NewVideoModel("video")
+	require.NoError(t, err)
+	video, err := videoModel.GenerateVideo(ctx, &llm.VideoRequest{Instructions: "a cat surfing"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/v.mp4", video.URL)
+	assert.Equal(t, "a cat surfing", provider.VideoCalls()[0].Request.Instructions)
+
+	provider.Reset()
+	assert.Empty(t, provider.EmbeddingCalls())
+	assert.Empty(t, provider.ImageCalls())
+	assert.Empty(t, provider.SpeechCalls())
+	assert.Empty(t, provider.VideoCalls())
+}
+
+func TestMockModelProvider_Models(t *testing.T) {
+	provider := NewMockModelProvider(&llm.ModelInfo{ID: "mock-1", Name: "Mock 1"})
+
+	_, err := provider.NewCompletionModel("mock-1")
+	assert.NoError(t, err)
+	_, err = provider.NewCompletionModel("other")
+	assert.ErrorIs(t, err, llm.ErrInvalidModel)
+}
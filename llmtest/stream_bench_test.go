@@ -0,0 +1,208 @@
+package llmtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/easyagent-dev/llm"
+)
+
+// benchChunks returns a stream script of n text chunks followed by a usage chunk
+func benchChunks(n int) []llm.StreamChunk {
+	chunks := make([]llm.StreamChunk, 0, n+1)
+	for i := range n {
+		chunks = append(chunks, llm.StreamTextChunk{Text: fmt.Sprintf("token%d ", i%100)})
+	}
+	return append(chunks, llm.StreamUsageChunk{Usage: &llm.TokenUsage{TotalOutputTokens: int64(n)}})
+}
+
+// streamLayers are the streaming middleware measured on top of the mock model
+var streamLayers = []struct {
+	name string
+	wrap func(llm.CompletionModel) llm.CompletionModel
+}{
+	{"mock", func(m llm.CompletionModel) llm.CompletionModel { return m }},
+	{"cost_tracking", func(m llm.CompletionModel) llm.CompletionModel {
+		return llm.NewCostTrackingCompletionModel(m, llm.NewCostTracker())
+	}},
+	{"logging", func(m llm.CompletionModel) llm.CompletionModel {
+		return llm.NewLoggingCompletionModel("bench", m, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}},
+	{"caching", func(m llm.CompletionModel) llm.CompletionModel {
+		return llm.NewCachingCompletionModel("bench", m, discardCache{})
+	}},
+}
+
+// discardCache is a CacheStore that never hits, so every stream reaches the model
+type discardCache struct{}
+
+func (discardCache) Get(ctx context.Context, key string) (*llm.CompletionResponse, bool, error) {
+	return nil, false, nil
+}
+
+func (discardCache) Set(ctx context.Context, key string, resp *llm.CompletionResponse, ttl time.Duration) error {
+	return nil
+}
+
+// reportPerChunk reports the time and allocations per chunk of b.N streams of n chunks,
+// measured from the memory statistics taken before the benchmark loop
+func reportPerChunk(b *testing.B, before *runtime.MemStats, n int) {
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	chunks := float64(b.N * n)
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/chunks, "ns/chunk")
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/chunks, "allocs/chunk")
+}
+
+// BenchmarkStream measures the chunk throughput and allocations of the channel based streaming
+// layer: the mock model alone, and with each middleware on top
+func BenchmarkStream(b *testing.B) {
+	for _, layer := range streamLayers {
+		for _, n := range []int{16, 256} {
+			b.Run(fmt.Sprintf("%s/chunks=%d", layer.name, n), func(b *testing.B) {
+				chunks := benchChunks(n)
+				provider := NewMockModelProvider()
+				model, _ := provider.NewCompletionModel("mock")
+				model = layer.wrap(model)
+				req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+				ctx := context.Background()
+
+				var before runtime.MemStats
+				runtime.ReadMemStats(&before)
+				b.ResetTimer()
+				for range b.N {
+					provider.AddStream(chunks...)
+					stream, err := model.StreamComplete(ctx, req)
+					if err != nil {
+						b.Fatal(err)
+					}
+					for range stream {
+					}
+				}
+				b.StopTimer()
+				reportPerChunk(b, &before, n+1)
+			})
+		}
+	}
+}
+
+// BenchmarkStream_FirstChunk measures the latency from starting a stream to receiving its
+// first chunk, the overhead added to the time to first token of a provider
+func BenchmarkStream_FirstChunk(b *testing.B) {
+	for _, layer := range streamLayers {
+		b.Run(layer.name, func(b *testing.B) {
+			chunks := benchChunks(4)
+			provider := NewMockModelProvider()
+			model, _ := provider.NewCompletionModel("mock")
+			model = layer.wrap(model)
+			req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+			ctx := context.Background()
+
+			var first time.Duration
+			b.ResetTimer()
+			for range b.N {
+				provider.AddStream(chunks...)
+				start := time.Now()
+				stream, err := model.StreamComplete(ctx, req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				<-stream
+				first += time.Since(start)
+				for range stream {
+				}
+			}
+			b.ReportMetric(float64(first.Nanoseconds())/float64(b.N), "ns/first-chunk")
+		})
+	}
+}
+
+// BenchmarkCompletionStream measures the pull based CompletionStream on top of the mock model
+func BenchmarkCompletionStream(b *testing.B) {
+	const n = 256
+	chunks := benchChunks(n)
+	provider := NewMockModelProvider()
+	model, _ := provider.NewCompletionModel("mock")
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for range b.N {
+		provider.AddStream(chunks...)
+		stream, err := llm.StreamCompletion(context.Background(), model, req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for stream.Next() {
+		}
+		if _, err := stream.Final(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	reportPerChunk(b, &before, n+1)
+}
+
+// relayModel streams its texts as chunks created while sending, like a provider decoding an
+// event stream, with the text chunk type requested by its options
+type relayModel struct {
+	texts   []string
+	options *llm.CompletionOptions
+}
+
+func (m *relayModel) Complete(ctx context.Context, req *llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	return nil, llm.ErrEmptyContent
+}
+
+func (m *relayModel) StreamComplete(ctx context.Context, req *llm.CompletionRequest) (llm.StreamCompletionResponse, error) {
+	ch := make(chan llm.StreamChunk, 1)
+	go func() {
+		defer close(ch)
+		for _, text := range m.texts {
+			ch <- m.options.TextChunk(text)
+		}
+	}()
+	return ch, nil
+}
+
+// BenchmarkStream_TextChunks compares plain and pooled text chunks relayed to a consumer that
+// releases the pooled chunks after writing them out
+func BenchmarkStream_TextChunks(b *testing.B) {
+	const n = 256
+	texts := make([]string, n)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("token%d ", i%100)
+	}
+	req := &llm.CompletionRequest{Messages: []*llm.ModelMessage{{Role: llm.RoleUser, Content: "Hi"}}}
+
+	for _, pooled := range []bool{false, true} {
+		b.Run(fmt.Sprintf("pooled=%t", pooled), func(b *testing.B) {
+			model := &relayModel{texts: texts, options: llm.ApplyCompletionOptions([]llm.CompletionOption{llm.WithPooledTextChunks(pooled)})}
+			ctx := context.Background()
+
+			var before runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+			for range b.N {
+				stream, _ := model.StreamComplete(ctx, req)
+				for chunk := range stream {
+					switch c := chunk.(type) {
+					case llm.StreamTextChunk:
+						io.WriteString(io.Discard, c.Text)
+					case *llm.StreamTextBytesChunk:
+						io.Discard.Write(c.Text)
+						c.Release()
+					}
+				}
+			}
+			b.StopTimer()
+			reportPerChunk(b, &before, n)
+		})
+	}
+}
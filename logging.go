@@ -0,0 +1,520 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedactedText replaces redacted secrets and, with WithContentRedaction, message content
+const RedactedText = "[REDACTED]"
+
+// secretPatterns match API keys and credentials by their format: bearer tokens, credential
+// fields and query parameters, and the prefixed keys issued by providers. Only credential
+// names are matched, so fields such as max_tokens are left alone.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|(?:access|refresh|auth)[_-]?token|client[_-]?secret|secret[_-]?key|password)["']?\s*[:=]\s*["']?)[^\s"'&,;]+`),
+	regexp.MustCompile(`(?i)([?&](?:key|token)=)[^\s"'&,;]+`),
+	regexp.MustCompile(`\b(?:sk|pk|rk|gsk|xai|pplx|fw|r8)[-_][A-Za-z0-9_-]{12,}`),
+}
+
+// PayloadSampleReason describes why a request/response payload was persisted
+type PayloadSampleReason string
+
+const (
+	PayloadSampled  PayloadSampleReason = "sampled"
+	PayloadError    PayloadSampleReason = "error"
+	PayloadLowScore PayloadSampleReason = "low_score"
+)
+
+// PayloadRecord is a full request/response payload selected for persistence
+type PayloadRecord struct {
+	Time     time.Time           `json:"time"`
+	Model    string              `json:"model"`
+	Reason   PayloadSampleReason `json:"reason"`
+	Request  *CompletionRequest  `json:"request"`
+	Response *CompletionResponse `json:"response,omitempty"`
+	Error    string              `json:"error,omitempty"`
+	Score    *float64            `json:"score,omitempty"`
+	Latency  time.Duration       `json:"latency"`
+}
+
+// PayloadSink persists sampled payloads, e.g. to object storage or a database
+type PayloadSink interface {
+	StorePayload(ctx context.Context, record *PayloadRecord) error
+}
+
+// PayloadSinkFunc adapts a function to the PayloadSink interface
+type PayloadSinkFunc func(ctx context.Context, record *PayloadRecord) error
+
+// StorePayload calls f(ctx, record)
+func (f PayloadSinkFunc) StorePayload(ctx context.Context, record *PayloadRecord) error {
+	return f(ctx, record)
+}
+
+// PayloadJudge scores a response between 0 and 1; scores below the threshold are persisted.
+// Responses are judged in the background after they were returned, so a slow judge, e.g. a
+// model, does not delay them.
+type PayloadJudge func(ctx context.Context, req *CompletionRequest, resp *CompletionResponse) float64
+
+// LoggingOption is a functional option for configuring the logging middleware
+type LoggingOption func(*LoggingOptions)
+
+// LoggingOptions contains configuration options for the logging middleware
+type LoggingOptions struct {
+	// PayloadSampleRate is the fraction of requests, between 0 and 1, whose full payload is persisted
+	PayloadSampleRate float64
+	// PayloadOnError persists the payload of every failed request
+	PayloadOnError bool
+	// PayloadJudge scores responses; payloads scoring below PayloadScoreThreshold are persisted
+	PayloadJudge          PayloadJudge
+	PayloadScoreThreshold float64
+	// PayloadJudgeRate is the fraction of responses, between 0 and 1, scored by PayloadJudge;
+	// all of them when zero
+	PayloadJudgeRate float64
+	// PayloadSink stores persisted payloads. When nil they are logged at debug level.
+	PayloadSink PayloadSink
+	// RequestLevel, ResponseLevel and ErrorLevel are the levels requests, responses and
+	// failures are logged at; they default to debug, info and error
+	RequestLevel  slog.Level
+	ResponseLevel slog.Level
+	ErrorLevel    slog.Level
+	// RedactContent replaces the instructions, messages and output of persisted payloads with
+	// RedactedText
+	RedactContent bool
+	// Secrets are redacted from logged errors and persisted payloads, in addition to the
+	// credentials recognized by their format
+	Secrets []string
+}
+
+// WithPayloadSampling persists the full payload of the given fraction of requests
+func WithPayloadSampling(rate float64) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.PayloadSampleRate = rate
+	}
+}
+
+// WithPayloadOnError persists the full payload of every failed request
+func WithPayloadOnError(enabled bool) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.PayloadOnError = enabled
+	}
+}
+
+// WithPayloadJudge persists the full payload of responses the judge scores below threshold
+func WithPayloadJudge(judge PayloadJudge, threshold float64) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.PayloadJudge = judge
+		o.PayloadScoreThreshold = threshold
+	}
+}
+
+// WithPayloadJudgeSampling scores only the given fraction of responses with the judge of
+// WithPayloadJudge, e.g. to bound the cost of a judge model
+func WithPayloadJudgeSampling(rate float64) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.PayloadJudgeRate = rate
+	}
+}
+
+// WithPayloadSink sets where persisted payloads are stored
+func WithPayloadSink(sink PayloadSink) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.PayloadSink = sink
+	}
+}
+
+// WithLogLevels sets the levels requests, responses and failures are logged at
+func WithLogLevels(request, response, failure slog.Level) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.RequestLevel = request
+		o.ResponseLevel = response
+		o.ErrorLevel = failure
+	}
+}
+
+// WithContentRedaction replaces the instructions, messages and output of persisted payloads
+// with RedactedText, so no prompt or completion text is stored
+func WithContentRedaction(enabled bool) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.RedactContent = enabled
+	}
+}
+
+// WithRedactedSecrets redacts secrets from logged errors and persisted payloads. API keys and
+// bearer tokens are redacted by their format without being listed.
+func WithRedactedSecrets(secrets ...string) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.Secrets = append(o.Secrets, secrets...)
+	}
+}
+
+// ApplyLoggingOptions applies all options to create a LoggingOptions struct
+func ApplyLoggingOptions(opts []LoggingOption) *LoggingOptions {
+	options := &LoggingOptions{
+		RequestLevel:  slog.LevelDebug,
+		ResponseLevel: slog.LevelInfo,
+		ErrorLevel:    slog.LevelError,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// LoggingCompletionModel is a middleware that logs every completion request and response and
+// persists full payloads for a sample of requests, failed requests and low scoring responses
+type LoggingCompletionModel struct {
+	name     string
+	model    CompletionModel
+	logger   *slog.Logger
+	options  *LoggingOptions
+	sample   func() float64
+	provider string
+	// completionOptions are logged with every request
+	completionOptions []any
+	// judging counts the responses being judged in the background
+	judging sync.WaitGroup
+}
+
+var _ CompletionModel = (*LoggingCompletionModel)(nil)
+
+// NewLoggingCompletionModel wraps model with request logging. A nil logger uses slog.Default().
+func NewLoggingCompletionModel(name string, model CompletionModel, logger *slog.Logger, opts ...LoggingOption) *LoggingCompletionModel {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LoggingCompletionModel{
+		name:    name,
+		model:   model,
+		logger:  logger,
+		options: ApplyLoggingOptions(opts),
+		sample:  rand.Float64,
+	}
+}
+
+// SetCompletionOptions logs the provider and the completion options the model was created with
+// along with every request
+func (m *LoggingCompletionModel) SetCompletionOptions(provider string, opts []CompletionOption) {
+	m.provider = provider
+	m.completionOptions = completionOptionAttrs(ApplyCompletionOptions(opts))
+}
+
+// Wait waits for the responses being judged by the PayloadJudge and for their payloads to be
+// stored, e.g. before the process exits
+func (m *LoggingCompletionModel) Wait() {
+	m.judging.Wait()
+}
+
+func (m *LoggingCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	m.logRequest(ctx, req)
+	start := time.Now()
+	resp, err := m.model.Complete(ctx, req)
+	m.finish(ctx, req, resp, err, time.Since(start))
+	return resp, err
+}
+
+func (m *LoggingCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	m.logRequest(ctx, req)
+	start := time.Now()
+	stream, err := m.model.StreamComplete(ctx, req)
+	if err != nil {
+		m.finish(ctx, req, nil, err, time.Since(start))
+		return nil, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer close(out)
+
+		var text strings.Builder
+		resp := &CompletionResponse{}
+		var streamErr error
+		for chunk := range stream {
+			switch c := chunk.(type) {
+			case StreamTextChunk:
+				text.WriteString(c.Text)
+			case *StreamTextBytesChunk:
+				text.Write(c.Text)
+			case StreamUsageChunk:
+				resp.Usage = c.Usage
+				resp.Cost = c.Cost
+			case StreamFinishChunk:
+				c.apply(resp)
+			case StreamLogprobChunk:
+				resp.Logprobs = append(resp.Logprobs, c.Logprobs...)
+			case StreamErrorChunk:
+				streamErr = c
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				m.finish(ctx, req, nil, ctx.Err(), time.Since(start))
+				return
+			}
+		}
+		if streamErr != nil {
+			m.finish(ctx, req, nil, streamErr, time.Since(start))
+			return
+		}
+		resp.Output = text.String()
+		m.finish(ctx, req, resp, nil, time.Since(start))
+	}()
+	return out, nil
+}
+
+// attrs returns the attributes identifying the model in every log record
+func (m *LoggingCompletionModel) attrs(req *CompletionRequest) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 8)
+	if m.provider != "" {
+		attrs = append(attrs, slog.String("provider", m.provider))
+	}
+	attrs = append(attrs, slog.String("model", m.name))
+	if req != nil {
+		attrs = append(attrs, slog.Int("messages", len(req.Messages)))
+	}
+	return attrs
+}
+
+// logRequest logs a request with the options of the model
+func (m *LoggingCompletionModel) logRequest(ctx context.Context, req *CompletionRequest) {
+	if !m.logger.Enabled(ctx, m.options.RequestLevel) {
+		return
+	}
+	attrs := m.attrs(req)
+	if len(m.completionOptions) > 0 {
+		attrs = append(attrs, slog.Group("options", m.completionOptions...))
+	}
+	m.logger.LogAttrs(ctx, m.options.RequestLevel, "llm request", attrs...)
+}
+
+// finish logs the request outcome and persists its payload if selected
+func (m *LoggingCompletionModel) finish(ctx context.Context, req *CompletionRequest, resp *CompletionResponse, err error, latency time.Duration) {
+	attrs := append(m.attrs(req), slog.Duration("latency", latency))
+	if resp != nil && resp.Usage != nil {
+		attrs = append(attrs,
+			slog.Int64("input_tokens", resp.Usage.TotalInputTokens),
+			slog.Int64("output_tokens", resp.Usage.TotalOutputTokens))
+	}
+	if resp != nil && resp.Cost != nil {
+		attrs = append(attrs, slog.Float64("cost", *resp.Cost))
+	}
+	if resp != nil && resp.FinishReason != "" {
+		attrs = append(attrs, slog.String("finish_reason", string(resp.FinishReason)))
+	}
+	if resp != nil && resp.ID != "" {
+		attrs = append(attrs, slog.String("response_id", resp.ID))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", m.redactSecrets(err.Error())))
+		m.logger.LogAttrs(ctx, m.options.ErrorLevel, "llm completion failed", attrs...)
+	} else {
+		m.logger.LogAttrs(ctx, m.options.ResponseLevel, "llm completion", attrs...)
+	}
+
+	if m.judge(resp, err) {
+		// The request and response are copied, since the caller may change them once returned
+		if req != nil {
+			copied := *req
+			copied.Messages = append([]*ModelMessage(nil), req.Messages...)
+			req = &copied
+		}
+		copied := *resp
+		resp = &copied
+		sampled := m.sampled()
+		m.judging.Add(1)
+		go func() {
+			defer m.judging.Done()
+			ctx := context.WithoutCancel(ctx)
+			score := m.options.PayloadJudge(ctx, req, resp)
+			record := &PayloadRecord{Request: req, Response: resp, Score: &score}
+			switch {
+			case score < m.options.PayloadScoreThreshold:
+				record.Reason = PayloadLowScore
+			case sampled:
+				record.Reason = PayloadSampled
+			default:
+				return
+			}
+			m.storePayload(ctx, record, latency)
+		}()
+		return
+	}
+	if record := m.selectPayload(req, resp, err); record != nil {
+		m.storePayload(ctx, record, latency)
+	}
+}
+
+// judge reports whether a response is scored by the PayloadJudge, as sampled by
+// PayloadJudgeRate
+func (m *LoggingCompletionModel) judge(resp *CompletionResponse, err error) bool {
+	o := m.options
+	if o.PayloadJudge == nil || resp == nil || err != nil {
+		return false
+	}
+	return o.PayloadJudgeRate <= 0 || o.PayloadJudgeRate >= 1 || m.sample() < o.PayloadJudgeRate
+}
+
+// sampled reports whether a payload is persisted by random sampling
+func (m *LoggingCompletionModel) sampled() bool {
+	return m.options.PayloadSampleRate > 0 && m.sample() < m.options.PayloadSampleRate
+}
+
+// storePayload redacts a selected payload and persists it to the sink, or logs it without one
+func (m *LoggingCompletionModel) storePayload(ctx context.Context, record *PayloadRecord, latency time.Duration) {
+	record.Time = time.Now()
+	record.Model = m.name
+	record.Latency = latency
+	m.redactPayload(record)
+
+	if m.options.PayloadSink == nil {
+		payload, err := json.Marshal(record)
+		if err != nil {
+			return
+		}
+		m.logger.LogAttrs(ctx, slog.LevelDebug, "llm payload", slog.String("payload", string(payload)))
+		return
+	}
+	if err := m.options.PayloadSink.StorePayload(ctx, record); err != nil {
+		m.logger.LogAttrs(ctx, slog.LevelWarn, "failed to store llm payload",
+			slog.String("model", m.name), slog.String("error", m.redactSecrets(err.Error())))
+	}
+}
+
+// selectPayload decides whether the payload of a response that is not judged is persisted:
+// failed requests take precedence over random sampling
+func (m *LoggingCompletionModel) selectPayload(req *CompletionRequest, resp *CompletionResponse, err error) *PayloadRecord {
+	record := &PayloadRecord{Request: req, Response: resp}
+	if err != nil {
+		if !m.options.PayloadOnError && !m.sampled() {
+			return nil
+		}
+		record.Reason = PayloadError
+		record.Error = err.Error()
+		return record
+	}
+	if m.sampled() {
+		record.Reason = PayloadSampled
+		return record
+	}
+	return nil
+}
+
+// redactSecrets replaces the configured secrets and the credentials recognized by their format
+func (m *LoggingCompletionModel) redactSecrets(text string) string {
+	for _, secret := range m.options.Secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, RedactedText)
+		}
+	}
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			// Keep the parameter name or scheme captured before the secret
+			if sub := pattern.FindStringSubmatch(match); len(sub) > 1 {
+				return sub[1] + RedactedText
+			}
+			return RedactedText
+		})
+	}
+	return text
+}
+
+// redactPayload replaces the secrets of a payload, and its content with RedactContent, on copies
+// of the request and response so the caller's values are not modified
+func (m *LoggingCompletionModel) redactPayload(record *PayloadRecord) {
+	redact := m.redactSecrets
+	if m.options.RedactContent {
+		redact = func(text string) string {
+			if text == "" {
+				return ""
+			}
+			return RedactedText
+		}
+	}
+	record.Error = m.redactSecrets(record.Error)
+
+	if req := record.Request; req != nil {
+		copied := &CompletionRequest{Instructions: redact(req.Instructions)}
+		for _, msg := range req.Messages {
+			copied.Messages = append(copied.Messages, redactMessage(msg, redact, m.options.RedactContent))
+		}
+		record.Request = copied
+	}
+	if resp := record.Response; resp != nil {
+		copied := *resp
+		copied.Output = redact(resp.Output)
+		copied.Refusal = redact(resp.Refusal)
+		if m.options.RedactContent {
+			copied.Logprobs = nil
+		}
+		if len(resp.Choices) > 0 {
+			copied.Choices = make([]CompletionChoice, len(resp.Choices))
+			for i, choice := range resp.Choices {
+				choice.Text = redact(choice.Text)
+				choice.Refusal = redact(choice.Refusal)
+				if m.options.RedactContent {
+					choice.Logprobs = nil
+				}
+				copied.Choices[i] = choice
+			}
+		}
+		record.Response = &copied
+	}
+}
+
+// redactMessage copies msg with its text redacted. Without content, artifacts and tool inputs
+// and outputs are dropped as well.
+func redactMessage(msg *ModelMessage, redact func(string) string, withoutContent bool) *ModelMessage {
+	if msg == nil {
+		return nil
+	}
+	copied := *msg
+	copied.Content = redact(msg.Content)
+	copied.Reasoning = redact(msg.Reasoning)
+	copied.Parts = nil
+	for _, part := range msg.Parts {
+		if part == nil {
+			continue
+		}
+		redacted := *part
+		redacted.Text = redact(part.Text)
+		if withoutContent {
+			redacted.Artifact, redacted.ToolCall = nil, nil
+		}
+		copied.Parts = append(copied.Parts, &redacted)
+	}
+	if withoutContent {
+		copied.Artifacts = nil
+		if msg.ToolCall != nil {
+			copied.ToolCall = &ToolCall{ID: msg.ToolCall.ID, Name: msg.ToolCall.Name}
+		}
+	}
+	return &copied
+}
+
+// completionOptionAttrs returns an attribute for every completion option that is set
+func completionOptionAttrs(options *CompletionOptions) []any {
+	var attrs []any
+	value := reflect.ValueOf(options).Elem()
+	for i := range value.NumField() {
+		field := value.Field(i)
+		if field.IsZero() {
+			continue
+		}
+		if field.Kind() == reflect.Pointer {
+			field = field.Elem()
+		}
+		attrs = append(attrs, slog.Any(value.Type().Field(i).Name, field.Interface()))
+	}
+	return attrs
+}
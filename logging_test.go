@@ -0,0 +1,241 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubCompletionModel struct {
+	output   string
+	choices  []CompletionChoice
+	logprobs []TokenLogprob
+	err      error
+}
+
+func (m *stubCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &CompletionResponse{Output: m.output, Usage: &TokenUsage{TotalInputTokens: 3, TotalOutputTokens: 2}, Choices: m.choices, Logprobs: m.logprobs}, nil
+}
+
+func (m *stubCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	ch := make(chan StreamChunk, 3)
+	ch <- StreamTextChunk{Text: m.output[:2]}
+	ch <- StreamTextChunk{Text: m.output[2:]}
+	ch <- StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 2}}
+	close(ch)
+	return ch, nil
+}
+
+func TestLoggingCompletionModel_PayloadSampling(t *testing.T) {
+	lowScore := func(ctx context.Context, req *CompletionRequest, resp *CompletionResponse) float64 { return 0.2 }
+	highScore := func(ctx context.Context, req *CompletionRequest, resp *CompletionResponse) float64 { return 0.9 }
+
+	tests := []struct {
+		name       string
+		modelErr   error
+		opts       []LoggingOption
+		sample     float64
+		wantReason PayloadSampleReason
+	}{
+		{name: "not_sampled", opts: []LoggingOption{WithPayloadSampling(0.1)}, sample: 0.5},
+		{name: "sampled", opts: []LoggingOption{WithPayloadSampling(0.1)}, sample: 0.05, wantReason: PayloadSampled},
+		{name: "error", modelErr: errors.New("boom"), opts: []LoggingOption{WithPayloadOnError(true)}, sample: 0.9, wantReason: PayloadError},
+		{name: "error_not_kept", modelErr: errors.New("boom"), sample: 0.9},
+		{name: "low_score", opts: []LoggingOption{WithPayloadJudge(lowScore, 0.5)}, sample: 0.9, wantReason: PayloadLowScore},
+		{name: "high_score", opts: []LoggingOption{WithPayloadJudge(highScore, 0.5)}, sample: 0.9},
+		{name: "high_score_sampled", opts: []LoggingOption{WithPayloadJudge(highScore, 0.5), WithPayloadSampling(0.1)}, sample: 0.05, wantReason: PayloadSampled},
+		{name: "judge_sampled", opts: []LoggingOption{WithPayloadJudge(lowScore, 0.5), WithPayloadJudgeSampling(0.1)}, sample: 0.05, wantReason: PayloadLowScore},
+		{name: "judge_not_sampled", opts: []LoggingOption{WithPayloadJudge(lowScore, 0.5), WithPayloadJudgeSampling(0.1)}, sample: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records []*PayloadRecord
+			sink := PayloadSinkFunc(func(ctx context.Context, record *PayloadRecord) error {
+				records = append(records, record)
+				return nil
+			})
+			logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+			model := NewLoggingCompletionModel("gpt-test", &stubCompletionModel{output: "hello", err: tt.modelErr}, logger,
+				append(tt.opts, WithPayloadSink(sink))...)
+			model.sample = func() float64 { return tt.sample }
+
+			_, _ = model.Complete(context.Background(), &CompletionRequest{Messages: []*ModelMessage{{Role: RoleUser, Content: "hi"}}})
+			model.Wait()
+
+			if tt.wantReason == "" {
+				assert.Empty(t, records)
+				return
+			}
+			require.Len(t, records, 1)
+			assert.Equal(t, tt.wantReason, records[0].Reason)
+			assert.Equal(t, "gpt-test", records[0].Model)
+			assert.Equal(t, "hi", records[0].Request.Messages[0].Content)
+		})
+	}
+}
+
+func TestLoggingCompletionModel_JudgeInBackground(t *testing.T) {
+	release := make(chan struct{})
+	judge := func(ctx context.Context, req *CompletionRequest, resp *CompletionResponse) float64 {
+		<-release
+		return 0.2
+	}
+	var records []*PayloadRecord
+	sink := PayloadSinkFunc(func(ctx context.Context, record *PayloadRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	model := NewLoggingCompletionModel("gpt-test", &stubCompletionModel{output: "hello"}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		WithPayloadJudge(judge, 0.5), WithPayloadSink(sink))
+
+	// The response is returned while the judge is still scoring it
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.Output)
+	close(release)
+	model.Wait()
+	require.Len(t, records, 1)
+	assert.Equal(t, PayloadLowScore, records[0].Reason)
+	assert.Equal(t, 0.2, *records[0].Score)
+}
+
+func TestLoggingCompletionModel_Stream(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	model := NewLoggingCompletionModel("gpt-test", &stubCompletionModel{output: "hello"}, logger, WithPayloadSampling(1))
+
+	stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	var text string
+	for chunk := range stream {
+		if c, ok := chunk.(StreamTextChunk); ok {
+			text += c.Text
+		}
+	}
+
+	assert.Equal(t, "hello", text)
+	assert.Contains(t, buf.String(), "msg=\"llm completion\"")
+	assert.Contains(t, buf.String(), "output_tokens=2")
+	assert.Contains(t, buf.String(), "msg=\"llm payload\"")
+	assert.Contains(t, buf.String(), `\"output\":\"hello\"`)
+}
+
+func TestLoggingCompletionModel_RequestAndResponse(t *testing.T) {
+	var buf bytes.Buffer
+	provider := NewDefaultModelProvider("openai", nil)
+	options := ApplyOptions([]ModelOption{
+		WithAPIKey("test-key-1234"),
+		WithLogger(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		WithRequestLogging(),
+	})
+	provider.SetLogging(options.Logger, options.Logging)
+	model := provider.LogCompletionModel(&stubCompletionModel{output: "hello"}, "gpt-test", []CompletionOption{WithTemperature(0.2), WithMaxTokens(100)})
+
+	_, err := model.Complete(context.Background(), &CompletionRequest{Messages: []*ModelMessage{{Role: RoleUser, Content: "hi"}}})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `level=DEBUG msg="llm request" provider=openai model=gpt-test messages=1 options.Temperature=0.2 options.MaxTokens=100`)
+	assert.Contains(t, lines[1], `level=INFO msg="llm completion" provider=openai model=gpt-test messages=1`)
+	assert.Contains(t, lines[1], "input_tokens=3 output_tokens=2")
+	assert.Equal(t, []string{"test-key-1234"}, options.Logging.Secrets)
+
+	assert.Same(t, model, NewDefaultModelProvider("openai", nil).LogCompletionModel(model, "gpt-test", nil), "logging is off by default")
+	options = ApplyOptions([]ModelOption{WithLogger(slog.NewTextHandler(&buf, nil))})
+	assert.Nil(t, options.Logging, "WithLogger does not turn on request logging")
+}
+
+func TestDefaultModelProvider_SeparateLoggers(t *testing.T) {
+	var warnings, requests bytes.Buffer
+	provider := NewDefaultModelProvider("openai", nil)
+	provider.SetLogging(slog.New(slog.NewTextHandler(&requests, nil)), &LoggingOptions{})
+	provider.SetDeprecationPolicy(slog.New(slog.NewTextHandler(&warnings, nil)), false)
+	model := provider.LogCompletionModel(&stubCompletionModel{output: "hello"}, "gpt-test", nil)
+
+	_, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Contains(t, requests.String(), `msg="llm completion"`)
+	assert.Empty(t, warnings.String())
+}
+
+func TestLoggingCompletionModel_Levels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	model := NewLoggingCompletionModel("gpt-test", &stubCompletionModel{err: errors.New("boom")}, logger,
+		WithLogLevels(slog.LevelInfo, slog.LevelDebug, slog.LevelWarn))
+
+	_, _ = model.Complete(context.Background(), &CompletionRequest{})
+	assert.Contains(t, buf.String(), `level=INFO msg="llm request"`)
+	assert.Contains(t, buf.String(), `level=WARN msg="llm completion failed"`)
+
+	buf.Reset()
+	model.model = &stubCompletionModel{output: "hello"}
+	_, _ = model.Complete(context.Background(), &CompletionRequest{})
+	assert.NotContains(t, buf.String(), `msg="llm completion"`, "responses are logged below the handler level")
+}
+
+func TestLoggingCompletionModel_RedactSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	modelErr := errors.New(`401: Incorrect API key provided: sk-proj-abcdef0123456789; sent Authorization: Bearer tok.en-42 to /v1?api_key=secret42&x=1 and /v1beta?key=secret43 with my-gateway-secret, max_tokens=100 cache_key=abc`)
+	model := NewLoggingCompletionModel("gpt-test", &stubCompletionModel{err: modelErr}, logger,
+		WithRedactedSecrets("my-gateway-secret"))
+
+	_, _ = model.Complete(context.Background(), &CompletionRequest{})
+	for _, secret := range []string{"sk-proj-abcdef0123456789", "tok.en-42", "secret42", "secret43", "my-gateway-secret"} {
+		assert.NotContains(t, buf.String(), secret)
+	}
+	assert.Contains(t, buf.String(), "Bearer [REDACTED]")
+	assert.Contains(t, buf.String(), "api_key=[REDACTED]&x=1")
+	assert.Contains(t, buf.String(), "max_tokens=100 cache_key=abc", "ordinary fields are not redacted")
+}
+
+func TestLoggingCompletionModel_ContentRedaction(t *testing.T) {
+	var records []*PayloadRecord
+	sink := PayloadSinkFunc(func(ctx context.Context, record *PayloadRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	req := &CompletionRequest{
+		Instructions: "be brief",
+		Messages: []*ModelMessage{
+			{Role: RoleUser, Parts: []*ContentPart{NewTextPart("my card is 4111"), {Type: ContentPartImage, Artifact: &ModelArtifact{Content: []byte("img")}}}},
+			{Role: RoleUser, Content: "hi", ToolCall: &ToolCall{ID: "c1", Name: "lookup", Input: map[string]any{"q": "secret"}}},
+		},
+	}
+
+	choices := []CompletionChoice{{Text: "hello"}, {Index: 1, Text: "hi", Logprobs: []TokenLogprob{{Token: "hi"}}}}
+	model := NewLoggingCompletionModel("gpt-test", &stubCompletionModel{output: "hello", choices: choices}, logger,
+		WithPayloadSampling(1), WithPayloadSink(sink), WithContentRedaction(true))
+	_, err := model.Complete(context.Background(), req)
+	require.NoError(t, err)
+
+	require.Len(t, records, 1)
+	record := records[0]
+	assert.Equal(t, RedactedText, record.Request.Instructions)
+	assert.Equal(t, []*ContentPart{{Type: ContentPartText, Text: RedactedText}, {Type: ContentPartImage}}, record.Request.Messages[0].Parts)
+	assert.Equal(t, RedactedText, record.Request.Messages[1].Content)
+	assert.Equal(t, &ToolCall{ID: "c1", Name: "lookup"}, record.Request.Messages[1].ToolCall)
+	assert.Equal(t, RedactedText, record.Response.Output)
+	assert.Equal(t, []CompletionChoice{{Text: RedactedText}, {Index: 1, Text: RedactedText}}, record.Response.Choices)
+
+	assert.Equal(t, "be brief", req.Instructions, "the request is not modified")
+	assert.Equal(t, "my card is 4111", req.Messages[0].Parts[0].Text)
+	assert.Equal(t, "secret", req.Messages[1].ToolCall.Input["q"])
+	assert.Equal(t, "hi", choices[1].Text, "the response is not modified")
+}
@@ -0,0 +1,248 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PromptTemplate is a prompt of a PromptMatrix. Instructions and Prompt are Go templates
+// rendered with GetPrompts for every variable set.
+type PromptTemplate struct {
+	Name         string `json:"name"`
+	Instructions string `json:"instructions,omitempty"`
+	// Prompt is the user message
+	Prompt string `json:"prompt"`
+}
+
+// MatrixModel is a model of a PromptMatrix, named in its results
+type MatrixModel struct {
+	Name  string
+	Model CompletionModel
+}
+
+// PromptMatrix is a set of prompt experiments: every template is rendered with every variable
+// set and sent to every model
+type PromptMatrix struct {
+	Templates []PromptTemplate
+	// Variables are the variable sets the templates are rendered with; templates are rendered
+	// once without variables when empty
+	Variables []map[string]any
+	Models    []MatrixModel
+}
+
+// MatrixResult is the outcome of one combination of a PromptMatrix
+type MatrixResult struct {
+	Template     string         `json:"template"`
+	Model        string         `json:"model"`
+	Variables    map[string]any `json:"variables,omitempty"`
+	Instructions string         `json:"instructions,omitempty"`
+	Prompt       string         `json:"prompt"`
+	Output       string         `json:"output,omitempty"`
+	FinishReason FinishReason   `json:"finishReason,omitempty"`
+	Usage        *TokenUsage    `json:"usage,omitempty"`
+	Cost         *float64       `json:"cost,omitempty"`
+	// Latency is the duration of the last attempt
+	Latency  time.Duration `json:"latency"`
+	Attempts int           `json:"attempts"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// MatrixWriter receives the results of a prompt matrix as they complete, one call at a time
+type MatrixWriter interface {
+	WriteResult(result *MatrixResult) error
+}
+
+// JSONLMatrixWriter writes results as JSON lines
+type JSONLMatrixWriter struct {
+	enc *json.Encoder
+}
+
+var _ MatrixWriter = (*JSONLMatrixWriter)(nil)
+
+// NewJSONLMatrixWriter creates a writer of JSON lines to w
+func NewJSONLMatrixWriter(w io.Writer) *JSONLMatrixWriter {
+	return &JSONLMatrixWriter{enc: json.NewEncoder(w)}
+}
+
+func (w *JSONLMatrixWriter) WriteResult(result *MatrixResult) error {
+	return w.enc.Encode(result)
+}
+
+// matrixCSVHeader are the columns written by CSVMatrixWriter
+var matrixCSVHeader = []string{
+	"template", "model", "variables", "instructions", "prompt", "output", "finish_reason",
+	"input_tokens", "output_tokens", "cost", "latency_ms", "attempts", "error",
+}
+
+// CSVMatrixWriter writes results as CSV rows after a header row. Variables are encoded as a
+// JSON object.
+type CSVMatrixWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+var _ MatrixWriter = (*CSVMatrixWriter)(nil)
+
+// NewCSVMatrixWriter creates a writer of CSV rows to w
+func NewCSVMatrixWriter(w io.Writer) *CSVMatrixWriter {
+	return &CSVMatrixWriter{w: csv.NewWriter(w)}
+}
+
+func (w *CSVMatrixWriter) WriteResult(result *MatrixResult) error {
+	if !w.header {
+		if err := w.w.Write(matrixCSVHeader); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	var variables []byte
+	if len(result.Variables) > 0 {
+		var err error
+		if variables, err = json.Marshal(result.Variables); err != nil {
+			return fmt.Errorf("failed to encode variables: %w", err)
+		}
+	}
+	var inputTokens, outputTokens, cost string
+	if result.Usage != nil {
+		inputTokens = strconv.FormatInt(result.Usage.TotalInputTokens, 10)
+		outputTokens = strconv.FormatInt(result.Usage.TotalOutputTokens, 10)
+	}
+	if result.Cost != nil {
+		cost = strconv.FormatFloat(*result.Cost, 'f', -1, 64)
+	}
+	if err := w.w.Write([]string{
+		result.Template,
+		result.Model,
+		string(variables),
+		result.Instructions,
+		result.Prompt,
+		result.Output,
+		string(result.FinishReason),
+		inputTokens,
+		outputTokens,
+		cost,
+		strconv.FormatInt(result.Latency.Milliseconds(), 10),
+		strconv.Itoa(result.Attempts),
+		result.Error,
+	}); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// RunPromptMatrix runs every combination of matrix, each model with its own Scheduler
+// configured with opts, so requests are retried and run concurrently. Results are passed to
+// writer, which may be nil, as they complete, and returned in matrix order: by template, then
+// variable set, then model. Failed requests are reported by the Error of their result; the
+// returned error is a template, writer or context error. When ctx is done, the results of the
+// combinations that did not complete are nil.
+func RunPromptMatrix(ctx context.Context, matrix *PromptMatrix, writer MatrixWriter, opts ...SchedulerOption) ([]*MatrixResult, error) {
+	if matrix == nil {
+		return nil, NewValidationError("matrix", "cannot be nil", nil)
+	}
+	for i, model := range matrix.Models {
+		if model.Model == nil {
+			return nil, NewValidationError(fmt.Sprintf("models[%d].model", i), "cannot be nil", nil)
+		}
+	}
+	variables := matrix.Variables
+	if len(variables) == 0 {
+		variables = []map[string]any{nil}
+	}
+
+	// Render all prompts first, so a broken template fails before any request is sent
+	var cells []*MatrixResult
+	for _, tmpl := range matrix.Templates {
+		for _, vars := range variables {
+			instructions, err := GetPrompts(tmpl.Instructions, vars)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render instructions of template %s: %w", tmpl.Name, err)
+			}
+			prompt, err := GetPrompts(tmpl.Prompt, vars)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render prompt of template %s: %w", tmpl.Name, err)
+			}
+			for _, model := range matrix.Models {
+				cells = append(cells, &MatrixResult{
+					Template:     tmpl.Name,
+					Model:        model.Name,
+					Variables:    vars,
+					Instructions: instructions,
+					Prompt:       prompt,
+				})
+			}
+		}
+	}
+	results := make([]*MatrixResult, len(cells))
+	if len(cells) == 0 {
+		return results, nil
+	}
+
+	schedulers := make([]*Scheduler, len(matrix.Models))
+	for i, model := range matrix.Models {
+		scheduler, err := NewScheduler(model.Model, opts...)
+		if err != nil {
+			return nil, err
+		}
+		scheduler.Start(ctx)
+		schedulers[i] = scheduler
+	}
+	defer func() {
+		for _, scheduler := range schedulers {
+			scheduler.Shutdown(context.Background())
+		}
+	}()
+
+	var mu sync.Mutex
+	var writeErr error
+	completed := make(chan struct{}, len(cells))
+	for i, cell := range cells {
+		callback := func(ctx context.Context, job *JobResult) {
+			defer func() { completed <- struct{}{} }()
+			cell.Attempts, cell.Latency, cell.Error = job.Attempts, job.Latency, job.Error
+			if resp := job.Response; resp != nil {
+				cell.Output, cell.FinishReason, cell.Usage, cell.Cost = resp.Output, resp.FinishReason, resp.Usage, resp.Cost
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			results[i] = cell
+			if writer != nil && writeErr == nil {
+				if err := writer.WriteResult(cell); err != nil {
+					writeErr = fmt.Errorf("failed to write result: %w", err)
+				}
+			}
+		}
+		req := &CompletionRequest{
+			Instructions: cell.Instructions,
+			Messages:     []*ModelMessage{{Role: RoleUser, Content: cell.Prompt}},
+		}
+		if _, err := schedulers[i%len(matrix.Models)].SubmitAsync(ctx, req, callback); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	for range cells {
+		select {
+		case <-completed:
+			continue
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		break
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return results, errors.Join(writeErr, err)
+}
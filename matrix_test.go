@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoModel answers with its name, the instructions and the prompt
+type echoModel struct {
+	name string
+}
+
+func (m *echoModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	cost := 0.5
+	return &CompletionResponse{
+		Output:       m.name + ": " + req.Instructions + "|" + req.Messages[0].Content,
+		FinishReason: FinishReasonStop,
+		Usage:        &TokenUsage{TotalInputTokens: 3, TotalOutputTokens: 2},
+		Cost:         &cost,
+	}, nil
+}
+
+func (m *echoModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	return nil, NewUnsupportedCapabilityError("echo", "streaming")
+}
+
+func TestRunPromptMatrix(t *testing.T) {
+	matrix := &PromptMatrix{
+		Templates: []PromptTemplate{
+			{Name: "terse", Instructions: "Be terse.", Prompt: "Capital of {{.country}}?"},
+			{Name: "polite", Prompt: "Please name the capital of {{.country}}."},
+		},
+		Variables: []map[string]any{{"country": "France"}, {"country": "Japan"}},
+		Models: []MatrixModel{
+			{Name: "a", Model: &echoModel{name: "a"}},
+			{Name: "b", Model: &echoModel{name: "b"}},
+		},
+	}
+	var jsonl bytes.Buffer
+	results, err := RunPromptMatrix(context.Background(), matrix, NewJSONLMatrixWriter(&jsonl))
+	require.NoError(t, err)
+	require.Len(t, results, 8)
+
+	var outputs []string
+	for _, result := range results {
+		outputs = append(outputs, result.Output)
+		assert.Equal(t, 1, result.Attempts)
+		assert.Equal(t, int64(2), result.Usage.TotalOutputTokens)
+	}
+	assert.Equal(t, []string{
+		"a: Be terse.|Capital of France?",
+		"b: Be terse.|Capital of France?",
+		"a: Be terse.|Capital of Japan?",
+		"b: Be terse.|Capital of Japan?",
+		"a: |Please name the capital of France.",
+		"b: |Please name the capital of France.",
+		"a: |Please name the capital of Japan.",
+		"b: |Please name the capital of Japan.",
+	}, outputs)
+	assert.Equal(t, "polite", results[7].Template)
+	assert.Equal(t, "b", results[7].Model)
+	assert.Equal(t, map[string]any{"country": "Japan"}, results[7].Variables)
+
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	require.Len(t, lines, 8)
+	var line MatrixResult
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.NotEmpty(t, line.Output)
+	assert.Equal(t, FinishReasonStop, line.FinishReason)
+}
+
+func TestRunPromptMatrix_Errors(t *testing.T) {
+	matrix := &PromptMatrix{
+		Templates: []PromptTemplate{{Name: "greeting", Prompt: "Hello {{.name}}"}},
+		Variables: []map[string]any{{"name": "Ada"}},
+		Models: []MatrixModel{
+			{Name: "echo", Model: &echoModel{name: "echo"}},
+			{Name: "broken", Model: &failingModel{err: errors.New("boom")}},
+		},
+	}
+	var out bytes.Buffer
+	results, err := RunPromptMatrix(context.Background(), matrix, NewCSVMatrixWriter(&out), WithJobRetries(1, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "echo: |Hello Ada", results[0].Output)
+	assert.Equal(t, "boom", results[1].Error)
+	assert.Empty(t, results[1].Output)
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, matrixCSVHeader, rows[0])
+	for _, row := range rows[1:] {
+		assert.Equal(t, `{"name":"Ada"}`, row[2])
+		if row[1] == "echo" {
+			assert.Equal(t, []string{"echo: |Hello Ada", "stop", "3", "2", "0.5"}, row[5:10])
+		} else {
+			assert.Equal(t, "boom", row[12])
+		}
+	}
+
+	matrix.Templates = []PromptTemplate{{Name: "broken", Prompt: "Hello {{.name"}}
+	_, err = RunPromptMatrix(context.Background(), matrix, nil)
+	assert.ErrorContains(t, err, "template broken")
+
+	matrix.Models = []MatrixModel{{Name: "missing"}}
+	_, err = RunPromptMatrix(context.Background(), matrix, nil)
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"time"
+)
+
+// ResponseMetadata contains additional information collected while serving a response
+type ResponseMetadata struct {
+	ToolCalls      int           `json:"toolCalls"`      // Number of tool calls executed
+	ToolErrors     int           `json:"toolErrors"`     // Number of tool calls that failed
+	ToolDenials    int           `json:"toolDenials"`    // Number of tool calls denied approval
+	ToolLatency    time.Duration `json:"toolLatency"`    // Total time spent executing tools
+	MaxToolLatency time.Duration `json:"maxToolLatency"` // Slowest single tool call
+	// SystemFingerprint identifies the provider's backend configuration. Responses to the same
+	// deterministic request can only be expected to match while it stays the same.
+	SystemFingerprint string `json:"systemFingerprint,omitempty"`
+	// Determinism reports how a deterministic request was honored
+	Determinism DeterminismLevel `json:"determinism,omitempty"`
+	// Switches are the fallbacks a FallbackCompletionModel made before the model that
+	// produced the response
+	Switches []*ModelSwitch `json:"switches,omitempty"`
+	// ServedBy is the name of the model of a FallbackCompletionModel chain that produced the
+	// response, set when the chain is named with WithModelNames
+	ServedBy string `json:"servedBy,omitempty"`
+	// Variation is the name of the prompt variation of a JitterCompletionModel that produced
+	// the response, empty for the original prompt
+	Variation string `json:"variation,omitempty"`
+	// Attempts is the number of attempts a JitterCompletionModel made, including the last
+	Attempts int `json:"attempts,omitempty"`
+	// ServerTiming is how the provider reports the request was served, set by providers that
+	// report it such as Groq
+	ServerTiming *ServerTiming `json:"serverTiming,omitempty"`
+	// ServiceTier is the tier the provider served the request with, which determines its price
+	ServiceTier ServiceTier `json:"serviceTier,omitempty"`
+	// Cache is set when a CachingCompletionModel served the response from its cache
+	Cache CacheHit `json:"cache,omitempty"`
+}
+
+// ServerTiming is the time a provider reports spending on a request and where it was served
+type ServerTiming struct {
+	// Queue is the time the request waited before it was processed
+	Queue time.Duration `json:"queue"`
+	// Prompt and Completion are the time spent reading the prompt and generating the output
+	Prompt     time.Duration `json:"prompt"`
+	Completion time.Duration `json:"completion"`
+	// Total is the total processing time
+	Total time.Duration `json:"total"`
+	// Region is the region that served the request
+	Region string `json:"region,omitempty"`
+	// RequestID is the provider's ID of the request, for support tickets
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// SwitchReason describes why a request was reissued to a fallback model
+type SwitchReason string
+
+const (
+	// SwitchError means the previous model failed
+	SwitchError SwitchReason = "error"
+	// SwitchFirstTokenDeadline means the previous model sent no chunk before the deadline
+	SwitchFirstTokenDeadline SwitchReason = "first_token_deadline"
+	// SwitchTimeout means the previous model did not answer within its timeout
+	SwitchTimeout SwitchReason = "timeout"
+)
+
+// ModelSwitch records a request reissued from one model of a fallback chain to the next
+type ModelSwitch struct {
+	// From and To are the positions of the models in the chain
+	From   int          `json:"from"`
+	To     int          `json:"to"`
+	Reason SwitchReason `json:"reason"`
+	// Error is the error of the previous model, if it failed
+	Error string `json:"error,omitempty"`
+	// Elapsed is how long the previous model was waited for
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// AddToolCall records the timing and outcome of a finished tool call
+func (m *ResponseMetadata) AddToolCall(call *ToolCall) {
+	if m == nil || call == nil {
+		return
+	}
+	m.ToolCalls++
+	if call.Failed() {
+		m.ToolErrors++
+	}
+	d := call.Duration()
+	m.ToolLatency += d
+	if d > m.MaxToolLatency {
+		m.MaxToolLatency = d
+	}
+}
@@ -16,31 +16,114 @@ const (
 	ModelMediaTypeVideo ModelMediaType = "video"
 )
 
+// ModelCapability describes which kind of model interface a model can be used with
+type ModelCapability string
+
+const (
+	ModelCapabilityCompletion   ModelCapability = "completion"
+	ModelCapabilityConversation ModelCapability = "conversation"
+	ModelCapabilityEmbedding    ModelCapability = "embedding"
+	ModelCapabilityImage        ModelCapability = "image"
+	ModelCapabilityImageEdit    ModelCapability = "image_edit"
+	ModelCapabilitySpeech       ModelCapability = "speech"
+	ModelCapabilityVideo        ModelCapability = "video"
+	ModelCapabilityRerank       ModelCapability = "rerank"
+)
+
 // ModelInfo contains metadata about a specific model including its ID, name, and pricing information
 type ModelInfo struct {
-	ID              string           `json:"id"`              // Unique identifier for the model
-	Name            string           `json:"name"`            // Human-readable name for the model
-	Pricing         ModelPricing     `json:"pricing"`         // Pricing information for different operations
-	Reasoning       bool             `json:"reasoning"`       // Whether the model supports reasoning
-	Embedding       bool             `json:"embedding"`       // Whether the model supports embeddings
-	Input           []ModelMediaType `json:"input"`           // Input type (e.g., "text", "image")
-	Output          []ModelMediaType `json:"output"`          // Output type (e.g., "text", "image")
-	ContextWindow   int              `json:"contextWindow"`   // Maximum context window size in tokens
-	MaxOutputTokens int              `json:"maxOutputTokens"` // Maximum output tokens
-	UpdatedAt       time.Time        `json:"updatedAt"`       // Last updated time
+	ID              string            `json:"id"`                     // Unique identifier for the model
+	Name            string            `json:"name"`                   // Human-readable name for the model
+	Capabilities    []ModelCapability `json:"capabilities"`           // Model interfaces supported by the model
+	Pricing         ModelPricing      `json:"pricing"`                // Pricing information for different operations
+	Reasoning       bool              `json:"reasoning"`              // Whether the model supports reasoning
+	Embedding       bool              `json:"embedding"`              // Whether the model supports embeddings
+	Input           []ModelMediaType  `json:"input"`                  // Input type (e.g., "text", "image")
+	Output          []ModelMediaType  `json:"output"`                 // Output type (e.g., "text", "image")
+	ContextWindow   int               `json:"contextWindow"`          // Maximum context window size in tokens
+	MaxOutputTokens int               `json:"maxOutputTokens"`        // Maximum output tokens
+	UpdatedAt       time.Time         `json:"updatedAt"`              // Last updated time
+	DeprecatesAt    *time.Time        `json:"deprecatesAt,omitempty"` // When the provider deprecated the model
+	SunsetsAt       *time.Time        `json:"sunsetsAt,omitempty"`    // When the provider stops serving the model
+	Replacement     string            `json:"replacement,omitempty"`  // Model recommended instead of a deprecated one
+	// ServiceTierPricing replaces Pricing for requests served with a tier priced differently
+	ServiceTierPricing map[ServiceTier]ModelPricing `json:"serviceTierPricing,omitempty"`
+	// ExtendedContextWindow is the context window with WithExtendedContext, or zero when the
+	// model has no extended window
+	ExtendedContextWindow int `json:"extendedContextWindow,omitempty"`
+	// LongContextPricing replaces Pricing for requests with long inputs
+	LongContextPricing *LongContextPricing `json:"longContextPricing,omitempty"`
+}
+
+// HasCapability reports whether the model supports the given capability.
+// Models without declared capabilities are assumed to support everything.
+func (m *ModelInfo) HasCapability(capability ModelCapability) bool {
+	if len(m.Capabilities) == 0 {
+		return true
+	}
+	for _, c := range m.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDeprecated reports whether the model is past its deprecation or sunset date at now
+func (m *ModelInfo) IsDeprecated(now time.Time) bool {
+	return (m.DeprecatesAt != nil && !now.Before(*m.DeprecatesAt)) || m.IsSunset(now)
+}
+
+// IsSunset reports whether the provider no longer serves the model at now
+func (m *ModelInfo) IsSunset(now time.Time) bool {
+	return m.SunsetsAt != nil && !now.Before(*m.SunsetsAt)
+}
+
+// AcceptsInput reports whether the model accepts the given input media type.
+// A model with no declared input types is assumed to accept every media type.
+func (m *ModelInfo) AcceptsInput(mediaType ModelMediaType) bool {
+	if len(m.Input) == 0 {
+		return true
+	}
+	for _, t := range m.Input {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
 }
 
 // ModelPricing contains pricing information for various model operations
-// All prices are in USD
+// Prices are in USD unless Currency is set. Token prices are per million tokens; for embedding
+// models Prompt is the price per million input tokens, and for image models
+// Image is the price per generated image.
 type ModelPricing struct {
-	Prompt            float64 `json:"prompt"`            // Price per million input tokens
-	Completion        float64 `json:"completion"`        // Price per million output tokens
-	Request           float64 `json:"request"`           // Price per request (if applicable)
-	Image             float64 `json:"image"`             // Price per image generation
-	WebSearch         float64 `json:"webSearch"`         // Price per web search operation
-	InternalReasoning float64 `json:"internalReasoning"` // Price per million reasoning tokens
-	InputCacheRead    float64 `json:"inputCacheRead"`    // Price per million cached input tokens read
-	InputCacheWrite   float64 `json:"inputCacheWrite"`   // Price per million cached input tokens written
+	Prompt            Price `json:"prompt"`            // Price per million input tokens
+	Completion        Price `json:"completion"`        // Price per million output tokens
+	Request           Price `json:"request"`           // Price per request (if applicable)
+	Image             Price `json:"image"`             // Price per image generation
+	WebSearch         Price `json:"webSearch"`         // Price per web search operation
+	InternalReasoning Price `json:"internalReasoning"` // Price per million reasoning tokens
+	InputCacheRead    Price `json:"inputCacheRead"`    // Price per million cached input tokens read
+	InputCacheWrite   Price `json:"inputCacheWrite"`   // Price per million cached input tokens written
+	AudioInput        Price `json:"audioInput"`        // Price per million audio input tokens
+	AudioOutput       Price `json:"audioOutput"`       // Price per million audio output tokens
+	Characters        Price `json:"characters"`        // Price per million input characters of speech models
+	VideoSecond       Price `json:"videoSecond"`       // Price per second of generated video
+	// Currency is the ISO 4217 code of the prices, USD when empty. The costs of the model are
+	// reported in it; a CostTracker keeps costs in other currencies than its own apart, and
+	// spending caps reject requests to models priced in another currency.
+	Currency string `json:"currency,omitempty"`
+	// Free marks zero prices as deliberate, e.g. for local models or OpenRouter :free models,
+	// so the model is not reported as missing pricing
+	Free bool `json:"free,omitempty"`
+}
+
+// IsZero reports whether the model has no pricing: all prices are zero, whatever the
+// currency, and it is not marked Free
+func (p ModelPricing) IsZero() bool {
+	p.Currency = ""
+	return p == ModelPricing{}
 }
 
 type Role string
@@ -58,12 +141,30 @@ const (
 	EmbeddingEncodingFormatBase64 EmbeddingEncodingFormat = "base64"
 )
 
+// EmbeddingTaskType hints what embeddings are used for. Providers that distinguish task
+// types optimize the embeddings for it; others ignore it.
+type EmbeddingTaskType string
+
+const (
+	// EmbeddingTaskSearchDocument embeds documents to be retrieved by search queries
+	EmbeddingTaskSearchDocument EmbeddingTaskType = "search_document"
+	// EmbeddingTaskSearchQuery embeds search queries to retrieve documents
+	EmbeddingTaskSearchQuery EmbeddingTaskType = "search_query"
+	// EmbeddingTaskClassification embeds texts to be classified
+	EmbeddingTaskClassification EmbeddingTaskType = "classification"
+	// EmbeddingTaskClustering embeds texts to be clustered
+	EmbeddingTaskClustering EmbeddingTaskType = "clustering"
+	// EmbeddingTaskSemanticSimilarity embeds texts to be compared with each other
+	EmbeddingTaskSemanticSimilarity EmbeddingTaskType = "semantic_similarity"
+)
+
 type ModelArtifact struct {
 	ID          string            `json:"id"`
 	Name        string            `json:"name" binding:"required"`
 	ContentType string            `json:"contentType" binding:"required"`
 	Description string            `json:"description"`
 	Content     []byte            `json:"content"`
+	URL         string            `json:"url,omitempty"` // Remote location used instead of Content when set
 	Metadata    map[string]string `json:"metadata"`
 }
 
@@ -75,6 +176,8 @@ type ToolCall struct {
 	ErrorMessage *string        `json:"errorMessage"`
 	StartAt      time.Time      `json:"startAt"`
 	EndAt        time.Time      `json:"endAt"`
+	// Artifacts produced by the tool; sent as message content rather than in the call JSON
+	Artifacts []*ModelArtifact `json:"-"`
 }
 
 type ModelMessage struct {
@@ -82,6 +185,12 @@ type ModelMessage struct {
 	Content   string           `json:"content"`
 	Artifacts []*ModelArtifact `json:"artifacts"`
 	ToolCall  *ToolCall        `json:"toolCall"`
+	Reasoning string           `json:"reasoning,omitempty"` // Reasoning summary of assistant messages; not sent to providers
+	// Parts holds ordered multimodal content. When set it takes precedence over Content and
+	// Artifacts; use ContentParts to read either form.
+	Parts []*ContentPart `json:"parts,omitempty"`
+	// CacheControl marks the message as the end of a cached prompt prefix, see WithCacheControl
+	CacheControl *CacheControl `json:"cacheControl,omitempty"`
 }
 
 type TokenUsage struct {
@@ -93,6 +202,14 @@ type TokenUsage struct {
 	TotalRequests         int   `json:"totalRequests"`
 	TotalCacheReadTokens  int64 `json:"totalCacheReadTokens"`
 	TotalCacheWriteTokens int64 `json:"totalCacheWriteTokens"`
+	// TotalAudioInputTokens and TotalAudioOutputTokens are the audio share of the input and
+	// output tokens, which audio models bill at their own rates
+	TotalAudioInputTokens  int64 `json:"totalAudioInputTokens,omitempty"`
+	TotalAudioOutputTokens int64 `json:"totalAudioOutputTokens,omitempty"`
+	// TotalCharacters is the number of characters synthesized by speech models
+	TotalCharacters int64 `json:"totalCharacters,omitempty"`
+	// TotalVideoSeconds is the length of the videos generated by video models
+	TotalVideoSeconds float64 `json:"totalVideoSeconds,omitempty"`
 }
 
 func (s *TokenUsage) Append(usage *TokenUsage) {
@@ -104,4 +221,8 @@ func (s *TokenUsage) Append(usage *TokenUsage) {
 	s.TotalRequests += usage.TotalRequests
 	s.TotalCacheReadTokens += usage.TotalCacheReadTokens
 	s.TotalCacheWriteTokens += usage.TotalCacheWriteTokens
+	s.TotalAudioInputTokens += usage.TotalAudioInputTokens
+	s.TotalAudioOutputTokens += usage.TotalAudioOutputTokens
+	s.TotalCharacters += usage.TotalCharacters
+	s.TotalVideoSeconds += usage.TotalVideoSeconds
 }
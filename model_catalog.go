@@ -0,0 +1,307 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// modelRefreshTimeout bounds the refreshes a catalog starts in the background
+const modelRefreshTimeout = 30 * time.Second
+
+// ModelSource lists the current models of a provider, e.g. from its models endpoint or a
+// hosted pricing feed. Sources may describe models partially, see ModelCatalog.
+type ModelSource interface {
+	ListModels(ctx context.Context) ([]*ModelInfo, error)
+}
+
+// ModelSourceFunc adapts a function to a ModelSource
+type ModelSourceFunc func(ctx context.Context) ([]*ModelInfo, error)
+
+func (f ModelSourceFunc) ListModels(ctx context.Context) ([]*ModelInfo, error) {
+	return f(ctx)
+}
+
+// ModelRefreshOptions configures the refresh of the supported models of a provider
+type ModelRefreshOptions struct {
+	// Source lists the models; nil uses the models endpoint of the provider, where it has one
+	Source ModelSource
+	// TTL is how long refreshed models are used before they are refreshed again; zero only
+	// refreshes them with ModelCatalog.Refresh
+	TTL time.Duration
+}
+
+// ModelCatalogProvider is implemented by providers whose supported models are held in a
+// ModelCatalog, such as the built-in ones. Assert it on the ModelProvider to refresh them.
+type ModelCatalogProvider interface {
+	ModelCatalog() *ModelCatalog
+}
+
+var _ ModelCatalogProvider = (*DefaultModelProvider)(nil)
+
+// ModelCatalog holds the supported models of a provider. It starts with the models embedded
+// in the provider and, with a source, merges the models of the source into them when it is
+// refreshed, so new models and price changes are picked up without upgrading the module.
+//
+// Models of the source that are not embedded are added. For embedded models, the name,
+// capabilities and media types of the embedded data are kept, since models endpoints only
+// report some of them, while the pricing, limits and lifecycle dates the source sets replace
+// the embedded ones. Embedded models the source does not list are kept. When a refresh
+// fails, the catalog keeps its models and tries again after the TTL. It is safe for
+// concurrent use.
+type ModelCatalog struct {
+	mu       sync.RWMutex
+	embedded []*ModelInfo
+	// merged are the models before the pricing options are applied
+	merged  []*ModelInfo
+	pricing *PricingOptions
+	models  []*ModelInfo
+	byID    map[string]*ModelInfo
+	byName  map[string]*ModelInfo
+
+	source      ModelSource
+	ttl         time.Duration
+	refreshedAt time.Time
+	err         error
+	refreshing  bool
+	now         func() time.Time
+}
+
+// NewModelCatalog creates a catalog of the embedded models, without a source
+func NewModelCatalog(models []*ModelInfo) *ModelCatalog {
+	c := &ModelCatalog{embedded: models, now: time.Now}
+	c.set(models)
+	return c
+}
+
+// SetSource sets the source the catalog is refreshed from, in the background once ttl has
+// passed since the last refresh, or only with Refresh for a zero ttl. With a ttl, the first
+// refresh starts on the first use of the catalog; until it completes, the embedded models are
+// used.
+func (c *ModelCatalog) SetSource(source ModelSource, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.source, c.ttl = source, ttl
+	c.refreshedAt = time.Time{}
+}
+
+// SetPricing applies the pricing overrides and default pricing of options to the models of the
+// catalog, see PricingOptions
+func (c *ModelCatalog) SetPricing(options *PricingOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pricing = options
+	c.set(c.merged)
+}
+
+// Models returns the models of the catalog, starting a background refresh when they are
+// older than the TTL
+func (c *ModelCatalog) Models() []*ModelInfo {
+	c.refreshIfStale()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.models
+}
+
+// Lookup returns the model with the given ID or name, or nil, starting a background refresh
+// when the models are older than the TTL
+func (c *ModelCatalog) Lookup(model string) *ModelInfo {
+	c.refreshIfStale()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if info, ok := c.byID[model]; ok {
+		return info
+	}
+	return c.byName[model]
+}
+
+// Refresh lists the models of the source and merges them into the embedded models. On error
+// the current models are kept.
+func (c *ModelCatalog) Refresh(ctx context.Context) error {
+	c.mu.RLock()
+	source := c.source
+	c.mu.RUnlock()
+	if source == nil {
+		return errors.New("model catalog has no source")
+	}
+
+	listed, err := source.ListModels(ctx)
+	if err == nil && len(listed) == 0 {
+		err = errors.New("model source listed no models")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshedAt, c.err = c.now(), err
+	if err != nil {
+		c.err = fmt.Errorf("failed to refresh models: %w", err)
+		return c.err
+	}
+	c.set(mergeModels(c.embedded, listed))
+	return nil
+}
+
+// RefreshedAt returns when the catalog was last refreshed, successfully or not, or the zero
+// time if it was not
+func (c *ModelCatalog) RefreshedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.refreshedAt
+}
+
+// Err returns the error of the last refresh, or nil if it succeeded
+func (c *ModelCatalog) Err() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.err
+}
+
+// refreshIfStale starts a background refresh when the TTL has passed and none is running
+func (c *ModelCatalog) refreshIfStale() {
+	c.mu.RLock()
+	stale := c.stale()
+	c.mu.RUnlock()
+	if !stale {
+		return
+	}
+	c.mu.Lock()
+	if !c.stale() {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing = true
+	c.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), modelRefreshTimeout)
+		defer cancel()
+		c.Refresh(ctx)
+		c.mu.Lock()
+		c.refreshing = false
+		c.mu.Unlock()
+	}()
+}
+
+// stale reports whether a background refresh is due; c.mu must be held
+func (c *ModelCatalog) stale() bool {
+	if c.source == nil || c.ttl <= 0 || c.refreshing {
+		return false
+	}
+	return c.refreshedAt.IsZero() || c.now().Sub(c.refreshedAt) >= c.ttl
+}
+
+// set replaces the models, applying the pricing options, and their indexes; c.mu must be held
+func (c *ModelCatalog) set(models []*ModelInfo) {
+	c.merged = models
+	models = c.pricing.apply(models)
+	c.models = models
+	c.byID = make(map[string]*ModelInfo, len(models))
+	c.byName = make(map[string]*ModelInfo, len(models))
+	for _, model := range models {
+		c.byID[model.ID] = model
+		c.byName[model.Name] = model
+	}
+}
+
+// mergeModels merges the listed models into the embedded ones, see ModelCatalog
+func mergeModels(embedded, listed []*ModelInfo) []*ModelInfo {
+	models := make([]*ModelInfo, 0, len(embedded)+len(listed))
+	index := make(map[string]int, len(embedded))
+	for _, model := range embedded {
+		index[model.ID] = len(models)
+		models = append(models, model)
+	}
+
+	for _, model := range listed {
+		if model == nil || model.ID == "" {
+			continue
+		}
+		i, ok := index[model.ID]
+		if !ok {
+			added := *model
+			if added.Name == "" {
+				added.Name = added.ID
+			}
+			index[model.ID] = len(models)
+			models = append(models, &added)
+			continue
+		}
+
+		merged := *models[i]
+		if !model.Pricing.IsZero() {
+			merged.Pricing = model.Pricing
+		}
+		if model.ServiceTierPricing != nil {
+			merged.ServiceTierPricing = model.ServiceTierPricing
+		}
+		if model.LongContextPricing != nil {
+			merged.LongContextPricing = model.LongContextPricing
+		}
+		if model.ContextWindow > 0 {
+			merged.ContextWindow = model.ContextWindow
+		}
+		if model.MaxOutputTokens > 0 {
+			merged.MaxOutputTokens = model.MaxOutputTokens
+		}
+		if model.ExtendedContextWindow > 0 {
+			merged.ExtendedContextWindow = model.ExtendedContextWindow
+		}
+		if model.DeprecatesAt != nil {
+			merged.DeprecatesAt = model.DeprecatesAt
+		}
+		if model.SunsetsAt != nil {
+			merged.SunsetsAt = model.SunsetsAt
+		}
+		if model.Replacement != "" {
+			merged.Replacement = model.Replacement
+		}
+		if model.UpdatedAt.After(merged.UpdatedAt) {
+			merged.UpdatedAt = model.UpdatedAt
+		}
+		models[i] = &merged
+	}
+	return models
+}
+
+// NewModelFeed returns a source reading the models of a provider from a JSON feed at url, a
+// list of models in the format of the model lists embedded in the providers, e.g. a copy of
+// openai.json kept up to date by the operators of a service. The feed is fetched with client,
+// or http.DefaultClient when nil.
+func NewModelFeed(url string, client *http.Client) ModelSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch model feed: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			return nil, NewRequestError("model_feed", resp.StatusCode, "failed to fetch model feed",
+				errors.New(strings.TrimSpace(string(body))))
+		}
+
+		var models []*ModelInfo
+		if err := json.NewDecoder(resp.Body).Decode(&models); err != nil {
+			return nil, NewResponseError("model_feed", "failed to decode model feed", err)
+		}
+		return models, nil
+	})
+}
@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func catalogTestModels() []*ModelInfo {
+	return []*ModelInfo{
+		{
+			ID:            "chat-1",
+			Name:          "Chat 1",
+			Capabilities:  []ModelCapability{ModelCapabilityCompletion},
+			Input:         []ModelMediaType{ModelMediaTypeText, ModelMediaTypeImage},
+			Pricing:       ModelPricing{Prompt: NewPrice(1), Completion: NewPrice(2)},
+			ContextWindow: 128_000,
+		},
+		{
+			ID:           "chat-0",
+			Name:         "Chat 0",
+			Capabilities: []ModelCapability{ModelCapabilityCompletion},
+			Pricing:      ModelPricing{Prompt: NewPrice(0.5), Completion: NewPrice(1)},
+		},
+	}
+}
+
+func TestMergeModels(t *testing.T) {
+	sunset := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	models := mergeModels(catalogTestModels(), []*ModelInfo{
+		{ID: "chat-1", Name: "chat-1", Pricing: ModelPricing{Prompt: NewPrice(0.8), Completion: NewPrice(1.6)}, SunsetsAt: &sunset, Replacement: "chat-2"},
+		{ID: "chat-2", Capabilities: []ModelCapability{ModelCapabilityCompletion}, ContextWindow: 200_000},
+		{ID: ""},
+		nil,
+	})
+	require.Len(t, models, 3)
+
+	// Embedded descriptions are kept, listed pricing and lifecycle dates replace theirs
+	chat1 := models[0]
+	assert.Equal(t, "Chat 1", chat1.Name)
+	assert.Equal(t, []ModelMediaType{ModelMediaTypeText, ModelMediaTypeImage}, chat1.Input)
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(0.8), Completion: NewPrice(1.6)}, chat1.Pricing)
+	assert.Equal(t, 128_000, chat1.ContextWindow)
+	assert.Equal(t, &sunset, chat1.SunsetsAt)
+	assert.Equal(t, "chat-2", chat1.Replacement)
+
+	// Embedded models missing from the listing are kept
+	assert.Equal(t, "chat-0", models[1].ID)
+
+	// New models are added, named by their ID
+	assert.Equal(t, "chat-2", models[2].Name)
+	assert.Equal(t, 200_000, models[2].ContextWindow)
+}
+
+func TestModelCatalog_Refresh(t *testing.T) {
+	embedded := catalogTestModels()
+	catalog := NewModelCatalog(embedded)
+	assert.Error(t, catalog.Refresh(context.Background()))
+
+	listed := []*ModelInfo{{ID: "chat-2", Capabilities: []ModelCapability{ModelCapabilityCompletion}}}
+	catalog.SetSource(ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		return listed, nil
+	}), 0)
+	require.NoError(t, catalog.Refresh(context.Background()))
+	assert.Len(t, catalog.Models(), 3)
+	assert.NotNil(t, catalog.Lookup("chat-2"))
+	assert.NotNil(t, catalog.Lookup("Chat 1"))
+	assert.False(t, catalog.RefreshedAt().IsZero())
+	assert.NoError(t, catalog.Err())
+	// The embedded models are not modified
+	assert.Len(t, embedded, 2)
+
+	// A failed refresh keeps the refreshed models
+	catalog.SetSource(ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		return nil, errors.New("unavailable")
+	}), 0)
+	err := catalog.Refresh(context.Background())
+	assert.ErrorContains(t, err, "unavailable")
+	assert.Equal(t, err, catalog.Err())
+	assert.NotNil(t, catalog.Lookup("chat-2"))
+
+	// An empty listing is an error rather than a catalog without models
+	catalog.SetSource(ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		return nil, nil
+	}), 0)
+	assert.Error(t, catalog.Refresh(context.Background()))
+	assert.Len(t, catalog.Models(), 3)
+}
+
+func TestModelCatalog_BackgroundRefresh(t *testing.T) {
+	var calls atomic.Int32
+	refreshed := make(chan struct{}, 4)
+	catalog := NewModelCatalog(catalogTestModels())
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	catalog.now = func() time.Time { return now }
+	catalog.SetSource(ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		defer func() { refreshed <- struct{}{} }()
+		calls.Add(1)
+		return []*ModelInfo{{ID: "chat-2"}}, nil
+	}), time.Hour)
+
+	waitRefresh := func() {
+		select {
+		case <-refreshed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("catalog was not refreshed")
+		}
+		require.Eventually(t, func() bool {
+			catalog.mu.RLock()
+			defer catalog.mu.RUnlock()
+			return !catalog.refreshing
+		}, 5*time.Second, time.Millisecond)
+	}
+
+	// The first use starts a refresh
+	catalog.Lookup("chat-1")
+	waitRefresh()
+	assert.NotNil(t, catalog.Lookup("chat-2"))
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Within the TTL the refreshed models are used
+	now = now.Add(30 * time.Minute)
+	catalog.Models()
+	assert.Equal(t, int32(1), calls.Load())
+
+	now = now.Add(30 * time.Minute)
+	catalog.Models()
+	waitRefresh()
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestModelCatalog_ManualRefreshOnly(t *testing.T) {
+	var calls atomic.Int32
+	catalog := NewModelCatalog(catalogTestModels())
+	catalog.SetSource(ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		calls.Add(1)
+		return []*ModelInfo{{ID: "chat-2"}}, nil
+	}), 0)
+
+	// Using the catalog does not start a first refresh
+	catalog.Models()
+	time.Sleep(10 * time.Millisecond)
+	assert.Zero(t, calls.Load())
+
+	require.NoError(t, catalog.Refresh(context.Background()))
+
+	catalog.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	catalog.Models()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestNewModelFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models.json" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":"chat-1","pricing":{"prompt":0.9,"completion":1.8}}]`))
+	}))
+	defer server.Close()
+
+	models, err := NewModelFeed(server.URL+"/models.json", nil).ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, 0.9, models[0].Pricing.Prompt.Float64())
+
+	// The feed is fetched with the given client
+	var fetched bool
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		fetched = true
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	_, err = NewModelFeed(server.URL+"/models.json", client).ListModels(context.Background())
+	require.NoError(t, err)
+	assert.True(t, fetched)
+
+	_, err = NewModelFeed(server.URL+"/missing.json", nil).ListModels(context.Background())
+	var reqErr *RequestError
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, http.StatusNotFound, reqErr.StatusCode)
+}
+
+func TestDefaultModelProvider_SetModelRefresh(t *testing.T) {
+	endpoint := ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		return []*ModelInfo{{ID: "endpoint-model"}}, nil
+	})
+	feed := ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		return []*ModelInfo{{ID: "chat-1", Pricing: ModelPricing{Prompt: NewPrice(0.9), Completion: NewPrice(1.8)}}}, nil
+	})
+
+	// Without options the embedded models are kept
+	provider := NewDefaultModelProvider("test", catalogTestModels())
+	provider.SetModelRefresh(nil, endpoint)
+	assert.Error(t, provider.ModelCatalog().Refresh(context.Background()))
+
+	// The models endpoint is used when the options have no source
+	provider.SetModelRefresh(&ModelRefreshOptions{}, endpoint)
+	require.NoError(t, provider.ModelCatalog().Refresh(context.Background()))
+	assert.NotNil(t, provider.GetModelInfo("endpoint-model"))
+
+	// A source replaces the models endpoint
+	provider = NewDefaultModelProvider("test", catalogTestModels())
+	provider.SetModelRefresh(ApplyOptions([]ModelOption{WithModelRefresh(0, feed)}).ModelRefresh, endpoint)
+	require.NoError(t, provider.ModelCatalog().Refresh(context.Background()))
+	assert.Nil(t, provider.GetModelInfo("endpoint-model"))
+	assert.Equal(t, 0.9, provider.GetModelInfo("chat-1").Pricing.Prompt.Float64())
+	assert.Len(t, provider.ModelsWithCapability(ModelCapabilityCompletion), 2)
+}
+
+// roundTripperFunc adapts a function to an http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,200 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+)
+
+// overrideCompletionModel is a middleware that sends requests to the completion model of the
+// ModelOverrides of their context, if any
+type overrideCompletionModel struct {
+	model CompletionModel
+}
+
+var _ CompletionModel = (*overrideCompletionModel)(nil)
+
+func (m *overrideCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	model, ctx := m.target(ctx)
+	return model.Complete(ctx, req)
+}
+
+func (m *overrideCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	model, ctx := m.target(ctx)
+	return model.StreamComplete(ctx, req)
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *overrideCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
+
+// target returns the model a request made with ctx is sent to. The override is usually a
+// model decorated by its own provider, so it is called without the completion override to
+// not send the request back to itself.
+func (m *overrideCompletionModel) target(ctx context.Context) (CompletionModel, context.Context) {
+	overrides := ModelsFromContext(ctx)
+	if overrides.Completion == nil {
+		return m.model, ctx
+	}
+	model := overrides.Completion
+	overrides.Completion = nil
+	return model, context.WithValue(ctx, modelsKey{}, overrides)
+}
+
+// overrideCheck is the policy check of a request whose model is overridden by ID
+type overrideCheck func(ctx context.Context, model string) error
+
+// overrideRequest returns a copy of req with the model that field points to replaced by
+// override, after checking it with check, if not nil. Requests without an override are
+// returned as they are, so the request of the caller is never modified.
+func overrideRequest[R any](ctx context.Context, check overrideCheck, req *R, field func(*R) *string, override string) (*R, error) {
+	if req == nil || override == "" || override == *field(req) {
+		return req, nil
+	}
+	if check != nil {
+		if err := check(ctx, override); err != nil {
+			return nil, err
+		}
+	}
+	overridden := *req
+	*field(&overridden) = override
+	return &overridden, nil
+}
+
+// overrideEmbeddingModel is a middleware that replaces the model of embedding requests with
+// the embedding model of the ModelOverrides of their context, if any
+type overrideEmbeddingModel struct {
+	model EmbeddingModel
+	check overrideCheck
+}
+
+var _ EmbeddingModel = (*overrideEmbeddingModel)(nil)
+
+func (m *overrideEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	req, err := overrideRequest(ctx, m.check, req, func(r *EmbeddingRequest) *string { return &r.Model }, ModelsFromContext(ctx).Embedding)
+	if err != nil {
+		return nil, err
+	}
+	return m.model.GenerateEmbeddings(ctx, req)
+}
+
+// overrideImageModel is a middleware that replaces the model of image requests with the image
+// model of the ModelOverrides of their context, if any
+type overrideImageModel struct {
+	model ImageModel
+	check overrideCheck
+}
+
+var _ ImageModel = (*overrideImageModel)(nil)
+
+func (m *overrideImageModel) GenerateImage(ctx context.Context, req *ImageRequest) (*ImageResponse, error) {
+	req, err := m.override(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return m.model.GenerateImage(ctx, req)
+}
+
+func (m *overrideImageModel) override(ctx context.Context, req *ImageRequest) (*ImageRequest, error) {
+	return overrideRequest(ctx, m.check, req, func(r *ImageRequest) *string { return &r.Model }, ModelsFromContext(ctx).Image)
+}
+
+// overrideImageEditModel is a middleware that replaces the model of image, edit and variation
+// requests with the image model of the ModelOverrides of their context, if any
+type overrideImageEditModel struct {
+	*overrideImageModel
+	model ImageEditModel
+}
+
+var _ ImageEditModel = (*overrideImageEditModel)(nil)
+
+func (m *overrideImageEditModel) EditImage(ctx context.Context, req *ImageEditRequest) (*ImageResponse, error) {
+	req, err := overrideRequest(ctx, m.check, req, func(r *ImageEditRequest) *string { return &r.Model }, ModelsFromContext(ctx).Image)
+	if err != nil {
+		return nil, err
+	}
+	return m.model.EditImage(ctx, req)
+}
+
+func (m *overrideImageEditModel) CreateVariation(ctx context.Context, req *ImageVariationRequest) (*ImageResponse, error) {
+	req, err := overrideRequest(ctx, m.check, req, func(r *ImageVariationRequest) *string { return &r.Model }, ModelsFromContext(ctx).Image)
+	if err != nil {
+		return nil, err
+	}
+	return m.model.CreateVariation(ctx, req)
+}
+
+// overrideImageJobModel is a middleware that replaces the model of image and job requests
+// with the image model of the ModelOverrides of their context, if any. Resumed jobs keep the
+// model they were started with.
+type overrideImageJobModel struct {
+	*overrideImageModel
+	model ImageJobModel
+}
+
+var _ ImageJobModel = (*overrideImageJobModel)(nil)
+
+func (m *overrideImageJobModel) StartImage(ctx context.Context, req *ImageRequest) (*ImageJob, error) {
+	req, err := m.override(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return m.model.StartImage(ctx, req)
+}
+
+func (m *overrideImageJobModel) ResumeImage(ctx context.Context, id string, config *ImageModelConfig) (*ImageJob, error) {
+	return m.model.ResumeImage(ctx, id, config)
+}
+
+// overrideSpeechModel is a middleware that replaces the model of speech requests with the
+// speech model of the ModelOverrides of their context, if any
+type overrideSpeechModel struct {
+	model SpeechModel
+	check overrideCheck
+}
+
+var _ SpeechModel = (*overrideSpeechModel)(nil)
+
+func (m *overrideSpeechModel) GenerateSpeech(ctx context.Context, req *SpeechRequest) (*SpeechResponse, error) {
+	req, err := overrideRequest(ctx, m.check, req, func(r *SpeechRequest) *string { return &r.Model }, ModelsFromContext(ctx).Speech)
+	if err != nil {
+		return nil, err
+	}
+	return m.model.GenerateSpeech(ctx, req)
+}
+
+// overrideVideoModel is a middleware that replaces the model of video requests with the video
+// model of the ModelOverrides of their context, if any
+type overrideVideoModel struct {
+	model VideoModel
+	check overrideCheck
+}
+
+var _ VideoModel = (*overrideVideoModel)(nil)
+
+func (m *overrideVideoModel) GenerateVideo(ctx context.Context, req *VideoRequest) (*VideoResponse, error) {
+	req, err := overrideRequest(ctx, m.check, req, func(r *VideoRequest) *string { return &r.Model }, ModelsFromContext(ctx).Video)
+	if err != nil {
+		return nil, err
+	}
+	return m.model.GenerateVideo(ctx, req)
+}
+
+// overrideRerankModel is a middleware that replaces the model of rerank requests with the
+// rerank model of the ModelOverrides of their context, if any
+type overrideRerankModel struct {
+	model RerankModel
+	check overrideCheck
+}
+
+var _ RerankModel = (*overrideRerankModel)(nil)
+
+func (m *overrideRerankModel) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	req, err := overrideRequest(ctx, m.check, req, func(r *RerankRequest) *string { return &r.Model }, ModelsFromContext(ctx).Rerank)
+	if err != nil {
+		return nil, err
+	}
+	return m.model.Rerank(ctx, req)
+}
@@ -1,5 +1,12 @@
 package llm
 
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
 // ModelProvider defines the base interface that all model providers must implement
 type ModelProvider interface {
 	// Name returns the provider name (e.g., "openai", "claude", "gemini")
@@ -15,32 +22,54 @@ type ModelProvider interface {
 
 	NewImageModel(model string) (ImageModel, error)
 
+	// NewImageEditModel creates an image model that also edits images and creates variations
+	NewImageEditModel(model string) (ImageEditModel, error)
+
+	// NewImageJobModel creates an image model that also runs generations as resumable jobs
+	NewImageJobModel(model string) (ImageJobModel, error)
+
+	NewSpeechModel(model string) (SpeechModel, error)
+
+	NewVideoModel(model string) (VideoModel, error)
+
+	NewRerankModel(model string) (RerankModel, error)
+
 	NewConversationModel(model string, opts ...ResponseOption) (ConversationModel, error)
+
+	// Shutdown stops accepting new requests and waits for in-flight requests and streams
+	// to finish, canceling the remaining ones when ctx expires
+	Shutdown(ctx context.Context) error
 }
 
 type DefaultModelProvider struct {
-	name        string
-	models      []*ModelInfo
-	modelByID   map[string]*ModelInfo
-	modelByName map[string]*ModelInfo
+	name      string
+	catalog   *ModelCatalog
+	inflight  *InFlight
+	retry     *RetryOptions
+	tracker   *CostTracker
+	limiter   *RateLimiter
+	logger    *slog.Logger
+	strict    bool
+	logging   *LoggingOptions
+	requests  *slog.Logger
+	policy    Policy
+	audit     PolicyAuditHook
+	region    string
+	cache     CacheStore
+	cacheOpts *CacheOptions
+	pricing   *PricingOptions
+	warned    sync.Map // IDs of the deprecated and unpriced models a warning was logged for
+	now       func() time.Time
 }
 
 var _ ModelProvider = (*DefaultModelProvider)(nil)
 
 func NewDefaultModelProvider(name string, models []*ModelInfo) *DefaultModelProvider {
-	modelByID := make(map[string]*ModelInfo, len(models))
-	modelByName := make(map[string]*ModelInfo, len(models))
-
-	for _, model := range models {
-		modelByID[model.ID] = model
-		modelByName[model.Name] = model
-	}
-
 	return &DefaultModelProvider{
-		name:        name,
-		models:      models,
-		modelByID:   modelByID,
-		modelByName: modelByName,
+		name:     name,
+		catalog:  NewModelCatalog(models),
+		inflight: NewInFlight(),
+		now:      time.Now,
 	}
 }
 
@@ -49,21 +78,444 @@ func (p *DefaultModelProvider) Name() string {
 }
 
 func (p *DefaultModelProvider) SupportedModels() []*ModelInfo {
-	return p.models
+	return p.catalog.Models()
 }
 
-func (p *DefaultModelProvider) GetModelInfo(modelID string) *ModelInfo {
-	// O(1) lookup by ID
-	if model, exists := p.modelByID[modelID]; exists {
+// ModelCatalog returns the catalog of the supported models, e.g. to refresh it
+func (p *DefaultModelProvider) ModelCatalog() *ModelCatalog {
+	return p.catalog
+}
+
+// SetModelRefresh refreshes the supported models from the source of options, or from
+// endpoint, the models endpoint of the provider, when it has none. A nil options, or a nil
+// source without an endpoint, keeps the embedded models.
+func (p *DefaultModelProvider) SetModelRefresh(options *ModelRefreshOptions, endpoint ModelSource) {
+	if options == nil {
+		return
+	}
+	source := options.Source
+	if source == nil {
+		source = endpoint
+	}
+	if source != nil {
+		p.catalog.SetSource(source, options.TTL)
+	}
+}
+
+// InFlight returns the tracker of in-flight requests for models created by this provider
+func (p *DefaultModelProvider) InFlight() *InFlight {
+	return p.inflight
+}
+
+// SetRetryOptions enables automatic retries for models created by this provider
+func (p *DefaultModelProvider) SetRetryOptions(retry *RetryOptions) {
+	if retry != nil && retry.MaxAttempts > 1 {
+		p.retry = retry
+	} else {
+		p.retry = nil
+	}
+}
+
+// SetCostTracker records the usage and cost of requests of models created by this provider
+// on tracker and enforces its limit. The budgets of contexts are enforced without a tracker.
+func (p *DefaultModelProvider) SetCostTracker(tracker *CostTracker) {
+	p.tracker = tracker
+}
+
+// CostTracker returns the cost tracker of the provider, or nil
+func (p *DefaultModelProvider) CostTracker() *CostTracker {
+	return p.tracker
+}
+
+// SetRateLimit paces the completions and embeddings of models created by this provider. A nil
+// options disables rate limiting.
+func (p *DefaultModelProvider) SetRateLimit(options *RateLimitOptions) {
+	if options != nil && (options.RequestsPerMinute > 0 || options.TokensPerMinute > 0) {
+		p.limiter = NewRateLimiter(*options)
+	} else {
+		p.limiter = nil
+	}
+}
+
+// RateLimiter returns the rate limiter of the provider, or nil
+func (p *DefaultModelProvider) RateLimiter() *RateLimiter {
+	return p.limiter
+}
+
+// SetDeprecationPolicy sets how models past their deprecation or sunset date are handled: a
+// warning is logged on logger, or slog.Default() when nil, the first time each of them is
+// created, or CheckDeprecation fails when strict is set.
+func (p *DefaultModelProvider) SetDeprecationPolicy(logger *slog.Logger, strict bool) {
+	p.logger = logger
+	p.strict = strict
+}
+
+// SetLogging logs every completion request and response of models created by this provider on
+// logger, or slog.Default() when nil. A nil options disables request logging.
+func (p *DefaultModelProvider) SetLogging(logger *slog.Logger, options *LoggingOptions) {
+	p.requests = logger
+	p.logging = options
+}
+
+// SetPolicy enforces policy when models are created and before every request, reporting
+// every decision to audit. A nil policy disables enforcement.
+func (p *DefaultModelProvider) SetPolicy(policy Policy, audit PolicyAuditHook) {
+	p.policy = policy
+	p.audit = audit
+}
+
+// SetRegion sets the region the provider serves its models from, checked by policies
+func (p *DefaultModelProvider) SetRegion(region string) {
+	p.region = region
+}
+
+// CheckPolicy returns a PolicyViolation when the policy refuses to create the model. Providers
+// call it through CheckModel when creating models.
+func (p *DefaultModelProvider) CheckPolicy(info *ModelInfo) error {
+	if p.policy == nil {
+		return nil
+	}
+	return enforcePolicy(context.Background(), p.policy, p.audit, p.policyTarget(PolicyStageModel, info, nil))
+}
+
+// CheckModel runs the checks providers make when creating a model: CheckDeprecation,
+// CheckPricing, then CheckPolicy
+func (p *DefaultModelProvider) CheckModel(info *ModelInfo) error {
+	if err := p.CheckDeprecation(info); err != nil {
+		return err
+	}
+	if err := p.CheckPricing(info); err != nil {
+		return err
+	}
+	return p.CheckPolicy(info)
+}
+
+// EnforcePolicy checks the policy before every request of a completion model, with the policy
+// labels of the request context
+func (p *DefaultModelProvider) EnforcePolicy(model CompletionModel, info *ModelInfo) CompletionModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicyCompletionModel{model: model, check: p.requestCheck(info)}
+}
+
+// EnforceConversationPolicy checks the policy before every request of a conversation model
+func (p *DefaultModelProvider) EnforceConversationPolicy(model ConversationModel, info *ModelInfo) ConversationModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicyConversationModel{model: model, check: p.requestCheck(info)}
+}
+
+// EnforceEmbeddingPolicy checks the policy before every request of an embedding model
+func (p *DefaultModelProvider) EnforceEmbeddingPolicy(model EmbeddingModel, info *ModelInfo) EmbeddingModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicyEmbeddingModel{model: model, check: p.requestCheck(info)}
+}
+
+// EnforceImagePolicy checks the policy before every request of an image model
+func (p *DefaultModelProvider) EnforceImagePolicy(model ImageModel, info *ModelInfo) ImageModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicyImageModel{model: model, check: p.requestCheck(info)}
+}
+
+// EnforceImageEditPolicy checks the policy before every request of an image edit model
+func (p *DefaultModelProvider) EnforceImageEditPolicy(model ImageEditModel, info *ModelInfo) ImageEditModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicyImageEditModel{
+		PolicyImageModel: &PolicyImageModel{model: model, check: p.requestCheck(info)},
+		model:            model,
+	}
+}
+
+// EnforceImageJobPolicy checks the policy before every request of an image job model
+func (p *DefaultModelProvider) EnforceImageJobPolicy(model ImageJobModel, info *ModelInfo) ImageJobModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicyImageJobModel{
+		PolicyImageModel: &PolicyImageModel{model: model, check: p.requestCheck(info)},
+		model:            model,
+	}
+}
+
+// EnforceSpeechPolicy checks the policy before every request of a speech model
+func (p *DefaultModelProvider) EnforceSpeechPolicy(model SpeechModel, info *ModelInfo) SpeechModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicySpeechModel{model: model, check: p.requestCheck(info)}
+}
+
+// EnforceVideoPolicy checks the policy before every request of a video model
+func (p *DefaultModelProvider) EnforceVideoPolicy(model VideoModel, info *ModelInfo) VideoModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicyVideoModel{model: model, check: p.requestCheck(info)}
+}
+
+// EnforceRerankPolicy checks the policy before every request of a rerank model
+func (p *DefaultModelProvider) EnforceRerankPolicy(model RerankModel, info *ModelInfo) RerankModel {
+	if p.policy == nil {
+		return model
+	}
+	return &PolicyRerankModel{model: model, check: p.requestCheck(info)}
+}
+
+// requestCheck returns the policy check of the requests of the model described by info, with
+// the policy labels of the request context
+func (p *DefaultModelProvider) requestCheck(info *ModelInfo) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		target := p.policyTarget(PolicyStageRequest, info, PolicyLabelsFromContext(ctx))
+		return enforcePolicy(ctx, p.policy, p.audit, target)
+	}
+}
+
+// overrideCheck returns the policy check of the requests whose model is overridden by ID with
+// ModelOverrides, or nil without a policy
+func (p *DefaultModelProvider) overrideCheck() overrideCheck {
+	if p.policy == nil {
+		return nil
+	}
+	return func(ctx context.Context, model string) error {
+		info := p.GetModelInfo(model)
+		if info == nil {
+			info = &ModelInfo{ID: model}
+		}
+		return p.requestCheck(info)(ctx)
+	}
+}
+
+func (p *DefaultModelProvider) policyTarget(stage PolicyStage, info *ModelInfo, labels map[string]string) *PolicyTarget {
+	return &PolicyTarget{
+		Stage:    stage,
+		Provider: p.name,
+		Model:    info.ID,
+		Info:     info,
+		Region:   p.region,
+		Labels:   labels,
+	}
+}
+
+// SetCache caches the completion responses of models created by this provider in store and,
+// with a semantic cache in options, for similar prompts. A nil options disables caching.
+func (p *DefaultModelProvider) SetCache(store CacheStore, options *CacheOptions) {
+	p.cache = store
+	p.cacheOpts = options
+}
+
+// CheckDeprecation warns about, or with strict deprecation rejects, a model past its deprecation
+// or sunset date. Providers call it through CheckModel when creating models.
+func (p *DefaultModelProvider) CheckDeprecation(info *ModelInfo) error {
+	now := p.now()
+	if !info.IsDeprecated(now) {
+		return nil
+	}
+	if p.strict {
+		return &ModelDeprecatedError{
+			Provider:     p.name,
+			Model:        info.ID,
+			DeprecatesAt: info.DeprecatesAt,
+			SunsetsAt:    info.SunsetsAt,
+			Replacement:  info.Replacement,
+			Sunset:       info.IsSunset(now),
+		}
+	}
+	if _, warned := p.warned.LoadOrStore(info.ID, true); warned {
+		return nil
+	}
+	logger := p.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	msg := "model is deprecated"
+	if info.IsSunset(now) {
+		msg = "model is past its sunset date"
+	}
+	logger.Warn(msg, deprecationAttrs(p.name, info)...)
+	return nil
+}
+
+// WrapCompletionModel adds the provider's rate limiting, cost tracking and retry behavior to a
+// completion model. Every attempt is rate limited and checked against the budgets. Costs are
+// tracked without a CostTracker too, for the budgets of contexts.
+func (p *DefaultModelProvider) WrapCompletionModel(model CompletionModel) CompletionModel {
+	if p.limiter != nil {
+		model = NewRateLimitedCompletionModel(model, p.limiter)
+	}
+	model = NewCostTrackingCompletionModel(model, p.tracker)
+	if p.retry == nil {
 		return model
 	}
-	// O(1) lookup by Name
-	if model, exists := p.modelByName[modelID]; exists {
+	return NewRetryCompletionModel(model, p.retry)
+}
+
+// DecorateCompletionModel adds all the provider's middleware to a completion model created for
+// info with opts: the fingerprint hook, the client-side enforcement of stop sequences, the
+// lexicon filter and the output checks, the rate limiting, cost tracking and retries of
+// WrapCompletionModel, then the response cache, the policy and the request logging. The
+// fingerprint hook, stop sequences, lexicon filter and output checks can also be set per
+// request with ContextWithOptions, so their middleware is always added and passes requests
+// without them through. Requests are sent to the completion model of the ModelOverrides of
+// their context instead, if any.
+func (p *DefaultModelProvider) DecorateCompletionModel(model CompletionModel, info *ModelInfo, opts []CompletionOption) CompletionModel {
+	fingerprint := NewFingerprintCompletionModel(model, opts)
+	fingerprint.provider, fingerprint.name = p.name, info.ID
+	model = NewStopSequenceCompletionModel(fingerprint, opts)
+	lexicon := NewLexiconCompletionModel(model, opts)
+	lexicon.provider, lexicon.name = p.name, info.ID
+	model = NewOutputCheckCompletionModel(lexicon, opts)
+	model = p.CacheCompletionModel(p.WrapCompletionModel(model), info.ID, opts)
+	model = p.LogCompletionModel(p.EnforcePolicy(model, info), info.ID, opts)
+	return &overrideCompletionModel{model: model}
+}
+
+// CacheCompletionModel adds the provider's response cache to a completion model created for
+// modelID with opts
+func (p *DefaultModelProvider) CacheCompletionModel(model CompletionModel, modelID string, opts []CompletionOption) CompletionModel {
+	if p.cacheOpts == nil || (p.cache == nil && p.cacheOpts.Semantic == nil) {
 		return model
 	}
+	caching := NewCachingCompletionModel(p.name+"/"+modelID, model, p.cache)
+	caching.options = p.cacheOpts
+	caching.SetCompletionOptions(opts)
+	return caching
+}
+
+// LogCompletionModel adds the provider's request logging to a completion model created for
+// modelID with opts. It wraps the model returned by WrapCompletionModel, so a request is
+// logged once however many attempts it takes.
+func (p *DefaultModelProvider) LogCompletionModel(model CompletionModel, modelID string, opts []CompletionOption) CompletionModel {
+	if p.logging == nil {
+		return model
+	}
+	logging := NewLoggingCompletionModel(modelID, model, p.requests)
+	logging.options = p.logging
+	logging.SetCompletionOptions(p.name, opts)
+	return logging
+}
+
+// WrapEmbeddingModel adds the provider's model overrides, rate limiting, cost tracking and retry
+// behavior to an embedding model
+func (p *DefaultModelProvider) WrapEmbeddingModel(model EmbeddingModel) EmbeddingModel {
+	model = &overrideEmbeddingModel{model: model, check: p.overrideCheck()}
+	if p.limiter != nil {
+		model = NewRateLimitedEmbeddingModel(model, p.limiter)
+	}
+	model = NewCostTrackingEmbeddingModel(model, p.tracker)
+	if p.retry == nil {
+		return model
+	}
+	return NewRetryEmbeddingModel(model, p.retry)
+}
+
+// WrapImageModel adds the provider's model overrides, cost tracking and retry behavior to
+// an image model
+func (p *DefaultModelProvider) WrapImageModel(model ImageModel) ImageModel {
+	model = &overrideImageModel{model: model, check: p.overrideCheck()}
+	model = NewCostTrackingImageModel(model, p.tracker)
+	if p.retry == nil {
+		return model
+	}
+	return NewRetryImageModel(model, p.retry)
+}
+
+// WrapImageEditModel adds the provider's model overrides, cost tracking and retry behavior to
+// an image edit model
+func (p *DefaultModelProvider) WrapImageEditModel(model ImageEditModel) ImageEditModel {
+	model = &overrideImageEditModel{overrideImageModel: &overrideImageModel{model: model, check: p.overrideCheck()}, model: model}
+	model = NewCostTrackingImageEditModel(model, p.tracker)
+	if p.retry == nil {
+		return model
+	}
+	return NewRetryImageEditModel(model, p.retry)
+}
+
+// WrapImageJobModel adds the provider's model overrides, cost tracking and retry behavior to
+// an image job model
+func (p *DefaultModelProvider) WrapImageJobModel(model ImageJobModel) ImageJobModel {
+	model = &overrideImageJobModel{overrideImageModel: &overrideImageModel{model: model, check: p.overrideCheck()}, model: model}
+	model = NewCostTrackingImageJobModel(model, p.tracker)
+	if p.retry == nil {
+		return model
+	}
+	return NewRetryImageJobModel(model, p.retry)
+}
+
+// WrapSpeechModel adds the provider's model overrides, cost tracking and retry behavior to
+// a speech model
+func (p *DefaultModelProvider) WrapSpeechModel(model SpeechModel) SpeechModel {
+	model = &overrideSpeechModel{model: model, check: p.overrideCheck()}
+	model = NewCostTrackingSpeechModel(model, p.tracker)
+	if p.retry == nil {
+		return model
+	}
+	return NewRetrySpeechModel(model, p.retry)
+}
+
+// WrapVideoModel adds the provider's model overrides, cost tracking and retry behavior to
+// a video model
+func (p *DefaultModelProvider) WrapVideoModel(model VideoModel) VideoModel {
+	model = &overrideVideoModel{model: model, check: p.overrideCheck()}
+	model = NewCostTrackingVideoModel(model, p.tracker)
+	if p.retry == nil {
+		return model
+	}
+	return NewRetryVideoModel(model, p.retry)
+}
+
+// WrapRerankModel adds the provider's model overrides, cost tracking and retry behavior to
+// a rerank model
+func (p *DefaultModelProvider) WrapRerankModel(model RerankModel) RerankModel {
+	model = &overrideRerankModel{model: model, check: p.overrideCheck()}
+	model = NewCostTrackingRerankModel(model, p.tracker)
+	if p.retry == nil {
+		return model
+	}
+	return NewRetryRerankModel(model, p.retry)
+}
+
+func (p *DefaultModelProvider) Shutdown(ctx context.Context) error {
+	return p.inflight.Shutdown(ctx)
+}
+
+func (p *DefaultModelProvider) GetModelInfo(modelID string) *ModelInfo {
+	return p.catalog.Lookup(modelID)
+}
+
+// findModelInfo returns the supported model of provider with the given ID, or nil when the
+// provider does not list it
+func findModelInfo(provider ModelProvider, model string) *ModelInfo {
+	if p, ok := provider.(interface{ GetModelInfo(string) *ModelInfo }); ok {
+		return p.GetModelInfo(model)
+	}
+	for _, info := range provider.SupportedModels() {
+		if info.ID == model {
+			return info
+		}
+	}
 	return nil
 }
 
+// ModelsWithCapability returns the supported models that declare the given capability
+func (p *DefaultModelProvider) ModelsWithCapability(capability ModelCapability) []*ModelInfo {
+	supported := p.SupportedModels()
+	models := make([]*ModelInfo, 0, len(supported))
+	for _, model := range supported {
+		if model.HasCapability(capability) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
 func (p *DefaultModelProvider) NewCompletionModel(model string, opts ...CompletionOption) (CompletionModel, error) {
 	return nil, ErrInvalidModel
 }
@@ -76,6 +528,26 @@ func (p *DefaultModelProvider) NewImageModel(model string) (ImageModel, error) {
 	return nil, ErrInvalidModel
 }
 
+func (p *DefaultModelProvider) NewImageEditModel(model string) (ImageEditModel, error) {
+	return nil, ErrInvalidModel
+}
+
+func (p *DefaultModelProvider) NewImageJobModel(model string) (ImageJobModel, error) {
+	return nil, ErrInvalidModel
+}
+
+func (p *DefaultModelProvider) NewSpeechModel(model string) (SpeechModel, error) {
+	return nil, ErrInvalidModel
+}
+
+func (p *DefaultModelProvider) NewVideoModel(model string) (VideoModel, error) {
+	return nil, ErrInvalidModel
+}
+
+func (p *DefaultModelProvider) NewRerankModel(model string) (RerankModel, error) {
+	return nil, ErrInvalidModel
+}
+
 func (p *DefaultModelProvider) NewConversationModel(model string, opts ...ResponseOption) (ConversationModel, error) {
 	return nil, ErrInvalidModel
 }
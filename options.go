@@ -4,7 +4,11 @@
 package llm
 
 import (
+	"log/slog"
+	"time"
+
 	"github.com/openai/openai-go/v3/option"
+	"golang.org/x/oauth2"
 )
 
 // ModelOption is a functional option for configuring models
@@ -16,8 +20,74 @@ type ModelOptions struct {
 	BaseURL    string
 	APIVersion string // For Azure OpenAI
 	Options    []option.RequestOption
+	// TokenSource issues the bearer tokens that authenticate requests instead of an API key
+	// (for Azure OpenAI)
+	TokenSource oauth2.TokenSource
+	// DeploymentMap maps model IDs to the names of their deployments (for Azure OpenAI)
+	DeploymentMap map[string]string
+	Retry         *RetryOptions
+	// Project, Location and CredentialsJSON configure Google Vertex AI
+	Project         string
+	Location        string
+	CredentialsJSON []byte
+	// CompletionFields are extra body fields sent with every completion request (for OpenAI
+	// compatible providers)
+	CompletionFields map[string]any
+	// CostTracker records the usage and cost of every request of the provider
+	CostTracker *CostTracker
+	// RateLimit paces the completions and embeddings of the provider
+	RateLimit *RateLimitOptions
+	// AuthStyle is where an OpenAI compatible gateway expects the API key; nil sends it as a
+	// bearer token
+	AuthStyle *AuthStyle
+	// Models replaces the model list of the OpenAI compatible provider
+	Models []*ModelInfo
+	// ModelRefresh refreshes the supported models of the provider at runtime, see
+	// WithModelRefresh
+	ModelRefresh *ModelRefreshOptions
+	// Logger receives the warnings of the provider, such as deprecated models; nil uses
+	// slog.Default()
+	Logger *slog.Logger
+	// Logging logs every completion request and response on Logger when set, see
+	// WithRequestLogging
+	Logging *LoggingOptions
+	// StrictDeprecation fails the creation of deprecated models instead of logging a warning
+	StrictDeprecation bool
+	// Pricing adjusts the pricing of the supported models, see WithPricingOverride
+	Pricing *PricingOptions
+	// Policy is checked when models are created and before every completion request
+	Policy Policy
+	// PolicyAudit receives the audit event of every policy decision
+	PolicyAudit PolicyAuditHook
+	// Region is where the provider serves its models, checked by policy rules
+	Region string
+	// Cache stores the completion responses of the provider, see WithCache
+	Cache CacheStore
+	// CacheOptions configures the response cache when it is enabled
+	CacheOptions *CacheOptions
+	// Webhook receives the completion of asynchronous jobs instead of polling (for Replicate)
+	Webhook *Webhook
+}
+
+// AuthStyle describes where a gateway expects the API key
+type AuthStyle struct {
+	// Query sends the key as a query parameter instead of a header
+	Query bool
+	// Name is the header or query parameter name
+	Name string
+	// Prefix is prepended to the key, e.g. "Bearer "
+	Prefix string
 }
 
+var (
+	// AuthStyleBearer sends the key as an Authorization bearer token, as OpenAI does
+	AuthStyleBearer = AuthStyle{Name: "Authorization", Prefix: "Bearer "}
+	// AuthStyleAPIKeyHeader sends the key in an api-key header, as Azure API Management does
+	AuthStyleAPIKeyHeader = AuthStyle{Name: "api-key"}
+	// AuthStyleQueryParam sends the key in the api_key query parameter
+	AuthStyleQueryParam = AuthStyle{Query: true, Name: "api_key"}
+)
+
 // WithAPIKey sets the API key
 func WithAPIKey(apiKey string) ModelOption {
 	return func(o *ModelOptions) {
@@ -39,6 +109,181 @@ func WithAPIVersion(version string) ModelOption {
 	}
 }
 
+// WithTokenSource authenticates requests with bearer tokens from source instead of an API key,
+// e.g. Microsoft Entra ID or managed identity tokens (for Azure OpenAI). Tokens are reused
+// until they expire.
+func WithTokenSource(source oauth2.TokenSource) ModelOption {
+	return func(o *ModelOptions) {
+		o.TokenSource = source
+	}
+}
+
+// WithDeploymentMap routes the requests for each model ID to the deployment named by
+// deployments, and the requests for other models to a deployment named after the model (for
+// Azure OpenAI)
+func WithDeploymentMap(deployments map[string]string) ModelOption {
+	return func(o *ModelOptions) {
+		o.DeploymentMap = deployments
+	}
+}
+
+// WithProject sets the Google Cloud project (for Vertex AI)
+func WithProject(project string) ModelOption {
+	return func(o *ModelOptions) {
+		o.Project = project
+	}
+}
+
+// WithLocation sets the Google Cloud region, e.g. "us-central1" (for Vertex AI)
+func WithLocation(location string) ModelOption {
+	return func(o *ModelOptions) {
+		o.Location = location
+	}
+}
+
+// WithCredentialsJSON sets the service account key used instead of Application Default
+// Credentials (for Vertex AI)
+func WithCredentialsJSON(credentials []byte) ModelOption {
+	return func(o *ModelOptions) {
+		o.CredentialsJSON = credentials
+	}
+}
+
+// WithAuthStyle sets where the OpenAI compatible provider sends the API key, e.g.
+// AuthStyleAPIKeyHeader, or AuthStyle{Name: "x-api-key"} for a custom header
+func WithAuthStyle(style AuthStyle) ModelOption {
+	return func(o *ModelOptions) {
+		o.AuthStyle = &style
+	}
+}
+
+// WithModels replaces the model list of the OpenAI compatible provider with the models served
+// by the gateway, so they can be created and priced. Models of local gateways that cost nothing
+// set Free in their pricing.
+func WithModels(models ...*ModelInfo) ModelOption {
+	return func(o *ModelOptions) {
+		o.Models = models
+	}
+}
+
+// WithModelRefresh refreshes the supported models of the provider from source in the
+// background, every ttl, merging them into the embedded models, see ModelCatalog. A nil
+// source uses the models endpoint of the provider, where it has one. A zero ttl only
+// refreshes them with ModelCatalog.Refresh.
+func WithModelRefresh(ttl time.Duration, source ModelSource) ModelOption {
+	return func(o *ModelOptions) {
+		o.ModelRefresh = &ModelRefreshOptions{Source: source, TTL: ttl}
+	}
+}
+
+// WithCompletionField sends an extra body field with every completion request, for parameters
+// specific to an OpenAI compatible provider such as Fireworks' prompt_cache_max_len
+func WithCompletionField(name string, value any) ModelOption {
+	return func(o *ModelOptions) {
+		if o.CompletionFields == nil {
+			o.CompletionFields = map[string]any{}
+		}
+		o.CompletionFields[name] = value
+	}
+}
+
+// WithRateLimit paces the completions, streams and embeddings of the provider to stay below
+// requestsPerMinute and tokensPerMinute, 0 for no limit, so they are not rejected with rate
+// limit errors. Requests wait for capacity until their context is done. All models created by
+// the provider share the limits.
+func WithRateLimit(requestsPerMinute, tokensPerMinute int) ModelOption {
+	return func(o *ModelOptions) {
+		if o.RateLimit == nil {
+			o.RateLimit = &RateLimitOptions{}
+		}
+		o.RateLimit.RequestsPerMinute = requestsPerMinute
+		o.RateLimit.TokensPerMinute = tokensPerMinute
+	}
+}
+
+// WithRateLimitBurst sets how many requests and tokens may be sent at once after a quiet
+// period, see WithRateLimit. The bursts default to the per minute rates.
+func WithRateLimitBurst(requests, tokens int) ModelOption {
+	return func(o *ModelOptions) {
+		if o.RateLimit == nil {
+			o.RateLimit = &RateLimitOptions{}
+		}
+		o.RateLimit.RequestBurst = requests
+		o.RateLimit.TokenBurst = tokens
+	}
+}
+
+// WithCostTracker records the usage and cost of every request of the provider on tracker and
+// enforces its limit, see CostTracker.SetLimit. Several providers may share a tracker.
+func WithCostTracker(tracker *CostTracker) ModelOption {
+	return func(o *ModelOptions) {
+		o.CostTracker = tracker
+	}
+}
+
+// WithLogger logs the warnings of the provider, such as the use of a deprecated model, and the
+// requests logged with WithRequestLogging, on handler
+func WithLogger(handler slog.Handler) ModelOption {
+	return func(o *ModelOptions) {
+		o.Logger = slog.New(handler)
+	}
+}
+
+// WithRequestLogging logs every completion request with its model, message count and options,
+// and every response with its latency, tokens, cost and finish reason, on the logger of
+// WithLogger or slog.Default(). The levels, content redaction and payload persistence are set
+// with opts; the API key of the provider is always redacted.
+func WithRequestLogging(opts ...LoggingOption) ModelOption {
+	return func(o *ModelOptions) {
+		o.Logging = ApplyLoggingOptions(opts)
+	}
+}
+
+// WithStrictDeprecation makes the provider return a ModelDeprecatedError for models past their
+// deprecation or sunset date instead of logging a warning
+func WithStrictDeprecation(strict bool) ModelOption {
+	return func(o *ModelOptions) {
+		o.StrictDeprecation = strict
+	}
+}
+
+// WithPolicy enforces policy when the provider creates models and before every request of
+// them, which fail with a PolicyViolation when it refuses the model. Every decision is
+// reported to audit when it is not nil.
+func WithPolicy(policy Policy, audit PolicyAuditHook) ModelOption {
+	return func(o *ModelOptions) {
+		o.Policy = policy
+		o.PolicyAudit = audit
+	}
+}
+
+// WithRegion sets the region the provider serves its models from, e.g. "eu-west-1", for
+// policy rules restricting regions
+func WithRegion(region string) ModelOption {
+	return func(o *ModelOptions) {
+		o.Region = region
+	}
+}
+
+// WithCache returns responses cached in store for repeated completion requests, keyed by the
+// canonical hash of the model, instructions, messages and options, see CacheKey. Responses
+// for similar prompts are also returned with WithSemanticCache, in which case store may be
+// nil. Cached responses have a zero cost and are not rate limited.
+func WithCache(store CacheStore, opts ...CacheOption) ModelOption {
+	return func(o *ModelOptions) {
+		o.Cache = store
+		o.CacheOptions = ApplyCacheOptions(opts)
+	}
+}
+
+// WithWebhook has the provider notify webhook when its asynchronous jobs complete, such as
+// Replicate predictions, instead of polling them. The webhook must be served at its URL.
+func WithWebhook(webhook *Webhook) ModelOption {
+	return func(o *ModelOptions) {
+		o.Webhook = webhook
+	}
+}
+
 // WithRequestOption adds a custom request option from the OpenAI SDK
 func WithRequestOption(opt option.RequestOption) ModelOption {
 	return func(o *ModelOptions) {
@@ -53,6 +298,17 @@ func WithRequestOptions(opts ...option.RequestOption) ModelOption {
 	}
 }
 
+// WithRetry retries completions, streams, embeddings and image generation that fail with
+// rate limit or server errors, up to maxAttempts attempts in total. The provider's
+// Retry-After delay takes precedence over backoff; a nil backoff uses DefaultRetryBackoff.
+// The built-in retries of the provider SDKs are disabled so attempts are not multiplied.
+func WithRetry(maxAttempts int, backoff Backoff) ModelOption {
+	return func(o *ModelOptions) {
+		o.Retry = &RetryOptions{MaxAttempts: maxAttempts, Backoff: backoff}
+		o.Options = append(o.Options, option.WithMaxRetries(0))
+	}
+}
+
 // ApplyOptions applies all options to create a ModelOptions struct
 func ApplyOptions(opts []ModelOption) *ModelOptions {
 	options := &ModelOptions{
@@ -61,5 +317,8 @@ func ApplyOptions(opts []ModelOption) *ModelOptions {
 	for _, opt := range opts {
 		opt(options)
 	}
+	if options.Logging != nil && options.APIKey != "" {
+		options.Logging.Secrets = append(options.Logging.Secrets, options.APIKey)
+	}
 	return options
 }
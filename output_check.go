@@ -0,0 +1,285 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputChecks are sanity checks of model output. Output failing them is requested once more
+// before an EmptyOutputError is returned.
+type OutputChecks struct {
+	// MinLength is the minimum number of characters of the output, not counting surrounding
+	// whitespace and code fence lines
+	MinLength int
+	// NonWhitespace rejects output made only of whitespace and code fence lines
+	NonWhitespace bool
+	// BalancedFences rejects output with a code fence that is never closed
+	BalancedFences bool
+}
+
+// DefaultOutputChecks rejects blank output, including a lone code fence, and unclosed fences
+func DefaultOutputChecks() OutputChecks {
+	return OutputChecks{NonWhitespace: true, BalancedFences: true}
+}
+
+// WithOutputChecks checks the output of models created by providers, retrying a request once
+// when its output fails them. Refusals are not checked.
+func WithOutputChecks(checks OutputChecks) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.OutputChecks = &checks
+	}
+}
+
+// EmptyOutputError is returned when the output of a model still fails its OutputChecks after a
+// retry. It matches ErrEmptyOutput, and ErrEmptyContent for callers checking empty responses.
+type EmptyOutputError struct {
+	// Output is the output of the last attempt
+	Output string
+	// Reason is the check the output failed
+	Reason string
+}
+
+func (e *EmptyOutputError) Error() string {
+	return "empty model output: " + e.Reason
+}
+
+func (e *EmptyOutputError) Is(target error) bool {
+	return target == ErrEmptyOutput || target == ErrEmptyContent
+}
+
+// Check returns why output fails the checks, or an empty string if it passes
+func (c OutputChecks) Check(output string) string {
+	content, fences := outputContent(output)
+	switch {
+	case c.NonWhitespace && content == "":
+		if fences > 0 {
+			return "output is only a code fence"
+		}
+		return "output is only whitespace"
+	case c.MinLength > 0 && utf8.RuneCountInString(content) < c.MinLength:
+		return fmt.Sprintf("output has %d characters, fewer than %d", utf8.RuneCountInString(content), c.MinLength)
+	case c.BalancedFences && fences%2 != 0:
+		return "output has an unclosed code fence"
+	}
+	return ""
+}
+
+// outputContent returns output without its code fence lines and surrounding whitespace, and
+// the number of fence lines
+func outputContent(output string) (string, int) {
+	var sb strings.Builder
+	fences := 0
+	for _, line := range strings.SplitAfter(output, "\n") {
+		if isFenceLine(line) {
+			fences++
+			continue
+		}
+		sb.WriteString(line)
+	}
+	return strings.TrimSpace(sb.String()), fences
+}
+
+// isFenceLine reports whether line opens or closes a code block, e.g. ```json
+func isFenceLine(line string) bool {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "```") {
+		return false
+	}
+	info := strings.TrimLeft(line, "`")
+	return !strings.ContainsAny(info, "` \t")
+}
+
+// completeLines returns text without its last line when that line may be the start of a code
+// fence still being streamed
+func completeLines(text string) string {
+	i := strings.LastIndexByte(text, '\n') + 1
+	line := strings.TrimSpace(text[i:])
+	if line != "" && (strings.Trim(line, "`") == "" || isFenceLine(line)) {
+		return text[:i]
+	}
+	return text
+}
+
+// OutputCheckCompletionModel checks the output of a model with the OutputChecks set with
+// WithOutputChecks, so empty or blank output is handled the same by every provider. A request
+// whose output fails the checks, or for which the model returns ErrEmptyContent, is sent once
+// more, and an EmptyOutputError is returned if the retry fails too. Usage and cost cover both
+// attempts.
+//
+// Streams hold back their chunks until the output passes the checks, so a stream can be
+// retried without its consumer seeing the failed attempt. An unclosed fence is only known once
+// the output ends, so with BalancedFences streams are held back until they end.
+type OutputCheckCompletionModel struct {
+	model   CompletionModel
+	options []CompletionOption
+}
+
+var _ CompletionModel = (*OutputCheckCompletionModel)(nil)
+var _ TokenCounter = (*OutputCheckCompletionModel)(nil)
+
+// NewOutputCheckCompletionModel wraps model, created with opts, with the output checks of opts
+// and of the option overrides of request contexts
+func NewOutputCheckCompletionModel(model CompletionModel, opts []CompletionOption) *OutputCheckCompletionModel {
+	return &OutputCheckCompletionModel{model: model, options: opts}
+}
+
+func (m *OutputCheckCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	checks := ApplyContextCompletionOptions(ctx, m.options).OutputChecks
+	if checks == nil {
+		return m.model.Complete(ctx, req)
+	}
+
+	var total usageTotal
+	var reason, output string
+	for attempt := 0; attempt < 2; attempt++ {
+		resp, err := m.model.Complete(ctx, req)
+		if errors.Is(err, ErrEmptyContent) {
+			reason, output = "output is empty", ""
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		total.addResponse(resp)
+		if resp.Refusal != "" || resp.FinishReason == FinishReasonContentFilter {
+			return resp, nil
+		}
+		if reason = checks.Check(resp.Output); reason == "" {
+			if attempt > 0 {
+				checked := *resp
+				checked.Usage, checked.Cost, checked.CostBreakdown = total.usage, total.cost, total.breakdown
+				return &checked, nil
+			}
+			return resp, nil
+		}
+		output = resp.Output
+	}
+	return nil, &EmptyOutputError{Output: output, Reason: reason}
+}
+
+func (m *OutputCheckCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	options := ApplyContextCompletionOptions(ctx, m.options)
+	if options.OutputChecks == nil {
+		return m.model.StreamComplete(ctx, req)
+	}
+	checks := *options.OutputChecks
+
+	stream, err := m.model.StreamComplete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer close(out)
+
+		send := func(chunk StreamChunk) bool {
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		sendAll := func(chunks []StreamChunk) bool {
+			for _, chunk := range chunks {
+				if t, ok := chunk.(StreamTextChunk); ok {
+					chunk = options.TextChunk(t.Text)
+				}
+				if !send(chunk) {
+					return false
+				}
+			}
+			return true
+		}
+		// usage and cost are those of a failed attempt, added to the usage of the next one
+		// spent is the usage and cost of the attempts so far
+		var spent usageTotal
+
+		for attempt := 0; ; attempt++ {
+			var text strings.Builder
+			var held []StreamChunk
+			passed, refused := false, false
+
+			for chunk := range stream {
+				chunk = ownText(chunk)
+				switch c := chunk.(type) {
+				case StreamTextChunk:
+					text.WriteString(c.Text)
+				case StreamUsageChunk:
+					if attempt > 0 {
+						total := spent.clone()
+						total.add(c.Usage, c.Cost, c.CostBreakdown)
+						c.Usage, c.Cost, c.CostBreakdown = total.usage, total.cost, total.breakdown
+						chunk = c
+					}
+				case StreamFinishChunk:
+					refused = c.Refusal != "" || c.Reason == FinishReasonContentFilter
+				case StreamErrorChunk:
+					// Errors are not output to check
+					refused = true
+				}
+				if !passed {
+					held = append(held, chunk)
+					// The length and whitespace checks only ever go from failing to passing as
+					// text arrives, while a fence may be opened by any later chunk
+					if !refused && (checks.BalancedFences || checks.Check(completeLines(text.String())) != "") {
+						continue
+					}
+					passed = true
+					if !sendAll(held) {
+						return
+					}
+					held = nil
+					continue
+				}
+				if !sendAll([]StreamChunk{chunk}) {
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			output := text.String()
+			reason := checks.Check(output)
+			if passed || reason == "" {
+				sendAll(held)
+				return
+			}
+
+			for _, h := range held {
+				if c, ok := h.(StreamUsageChunk); ok {
+					spent = usageTotal{}
+					spent.add(c.Usage, c.Cost, c.CostBreakdown)
+				}
+			}
+			if attempt > 0 {
+				if spent.usage != nil || spent.cost != nil {
+					if !send(StreamUsageChunk{Usage: spent.usage, Cost: spent.cost, CostBreakdown: spent.breakdown}) {
+						return
+					}
+				}
+				send(StreamErrorChunk{Err: &EmptyOutputError{Output: output, Reason: reason}})
+				return
+			}
+			var err error
+			if stream, err = m.model.StreamComplete(ctx, req); err != nil {
+				send(StreamErrorChunk{Err: err})
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *OutputCheckCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// attemptsModel answers each request with its next output, or ErrEmptyContent for "<empty>"
+type attemptsModel struct {
+	outputs []string
+	calls   int
+}
+
+func (m *attemptsModel) next() string {
+	output := m.outputs[min(m.calls, len(m.outputs)-1)]
+	m.calls++
+	return output
+}
+
+func (m *attemptsModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	output := m.next()
+	if output == "<empty>" {
+		return nil, ErrEmptyContent
+	}
+	cost := 0.001
+	return &CompletionResponse{Output: output, Usage: &TokenUsage{TotalOutputTokens: 10, TotalRequests: 1}, Cost: &cost}, nil
+}
+
+func (m *attemptsModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	output := m.next()
+	cost := 0.001
+	ch := make(chan StreamChunk, len(output)+2)
+	for _, r := range output {
+		ch <- StreamTextChunk{Text: string(r)}
+	}
+	ch <- StreamFinishChunk{Reason: FinishReasonStop}
+	ch <- StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 10, TotalRequests: 1}, Cost: &cost}
+	close(ch)
+	return ch, nil
+}
+
+func TestOutputChecks_Check(t *testing.T) {
+	checks := DefaultOutputChecks()
+	assert.Equal(t, "output is only whitespace", checks.Check(" \n\t"))
+	assert.Equal(t, "output is only a code fence", checks.Check("```"))
+	assert.Equal(t, "output is only a code fence", checks.Check("```json\n\n```\n"))
+	assert.Equal(t, "output has an unclosed code fence", checks.Check("Here:\n```go\nfunc main() {}"))
+	assert.Empty(t, checks.Check("```go\nfunc main() {}\n```"))
+	assert.Empty(t, checks.Check("Use `go test` or ``` inline"))
+
+	checks.MinLength = 5
+	assert.Equal(t, "output has 2 characters, fewer than 5", checks.Check("  ok  "))
+	assert.Empty(t, checks.Check("héllo"))
+	assert.Empty(t, OutputChecks{}.Check(""))
+}
+
+func TestOutputCheckCompletionModel_Complete(t *testing.T) {
+	upstream := &attemptsModel{outputs: []string{"  \n", "Hello"}}
+	model := NewOutputCheckCompletionModel(upstream, []CompletionOption{WithOutputChecks(DefaultOutputChecks())})
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Output)
+	assert.Equal(t, 2, upstream.calls)
+	// Both attempts are accounted for
+	assert.Equal(t, int64(20), resp.Usage.TotalOutputTokens)
+	assert.InDelta(t, 0.002, *resp.Cost, 1e-9)
+
+	upstream = &attemptsModel{outputs: []string{"<empty>", "```"}}
+	model = NewOutputCheckCompletionModel(upstream, []CompletionOption{WithOutputChecks(DefaultOutputChecks())})
+	_, err = model.Complete(context.Background(), &CompletionRequest{})
+	var emptyErr *EmptyOutputError
+	require.ErrorAs(t, err, &emptyErr)
+	assert.Equal(t, "output is only a code fence", emptyErr.Reason)
+	assert.Equal(t, "```", emptyErr.Output)
+	assert.ErrorIs(t, err, ErrEmptyOutput)
+	assert.ErrorIs(t, err, ErrEmptyContent)
+	assert.Equal(t, 2, upstream.calls)
+
+	// Without checks, and with checks disabled by the context, output passes through
+	upstream = &attemptsModel{outputs: []string{" "}}
+	resp, err = NewOutputCheckCompletionModel(upstream, nil).Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, " ", resp.Output)
+	ctx := ContextWithOptions(context.Background(), func(o *CompletionOptions) { o.OutputChecks = nil })
+	model = NewOutputCheckCompletionModel(upstream, []CompletionOption{WithOutputChecks(DefaultOutputChecks())})
+	_, err = model.Complete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, upstream.calls)
+}
+
+func TestOutputCheckCompletionModel_StreamComplete(t *testing.T) {
+	collect := func(t *testing.T, model CompletionModel) (string, *StreamUsageChunk, error) {
+		stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+		require.NoError(t, err)
+		var text string
+		var usage *StreamUsageChunk
+		var streamErr error
+		for chunk := range stream {
+			switch c := chunk.(type) {
+			case StreamTextChunk:
+				text += c.Text
+			case StreamUsageChunk:
+				usage = &c
+			case StreamErrorChunk:
+				streamErr = c.Err
+			}
+		}
+		return text, usage, streamErr
+	}
+
+	// The blank attempt is never seen by the consumer
+	upstream := &attemptsModel{outputs: []string{" \n", "Hi there"}}
+	text, usage, err := collect(t, NewOutputCheckCompletionModel(upstream, []CompletionOption{WithOutputChecks(DefaultOutputChecks())}))
+	require.NoError(t, err)
+	assert.Equal(t, "Hi there", text)
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(20), usage.Usage.TotalOutputTokens)
+	assert.InDelta(t, 0.002, *usage.Cost, 1e-9)
+
+	upstream = &attemptsModel{outputs: []string{"```"}}
+	text, usage, err = collect(t, NewOutputCheckCompletionModel(upstream, []CompletionOption{WithOutputChecks(DefaultOutputChecks())}))
+	assert.Empty(t, text)
+	assert.ErrorIs(t, err, ErrEmptyOutput)
+	require.NotNil(t, usage)
+	assert.Equal(t, int64(20), usage.Usage.TotalOutputTokens)
+	assert.Equal(t, 2, upstream.calls)
+
+	// Unclosed fences are retried like blank output
+	upstream = &attemptsModel{outputs: []string{"```go\nx := 1", "```go\nx := 2\n```"}}
+	text, usage, err = collect(t, NewOutputCheckCompletionModel(upstream, []CompletionOption{WithOutputChecks(DefaultOutputChecks())}))
+	require.NoError(t, err)
+	assert.Equal(t, "```go\nx := 2\n```", text)
+	assert.Equal(t, int64(20), usage.Usage.TotalOutputTokens)
+
+	upstream = &attemptsModel{outputs: []string{"```go\nx := 1"}}
+	text, _, err = collect(t, NewOutputCheckCompletionModel(upstream, []CompletionOption{WithOutputChecks(DefaultOutputChecks())}))
+	assert.Empty(t, text)
+	var emptyErr *EmptyOutputError
+	require.True(t, errors.As(err, &emptyErr))
+	assert.Equal(t, "output has an unclosed code fence", emptyErr.Reason)
+	assert.Equal(t, 2, upstream.calls)
+
+	// Checks set per request apply to models created without them
+	upstream = &attemptsModel{outputs: []string{" ", "ok"}}
+	ctx := ContextWithOptions(context.Background(), WithOutputChecks(OutputChecks{NonWhitespace: true}))
+	stream, err := StreamCompletion(ctx, NewOutputCheckCompletionModel(upstream, nil), &CompletionRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Output)
+}
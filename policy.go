@@ -0,0 +1,416 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+)
+
+// PolicyStage is when a policy is checked
+type PolicyStage string
+
+const (
+	// PolicyStageModel is the check when a provider creates a model
+	PolicyStageModel PolicyStage = "model"
+	// PolicyStageRequest is the check before every request of a model
+	PolicyStageRequest PolicyStage = "request"
+)
+
+// PolicyTarget is the model a policy decides on
+type PolicyTarget struct {
+	Stage    PolicyStage
+	Provider string
+	Model    string
+	Info     *ModelInfo
+	// Region is where the provider serves the model, set with WithRegion; empty when unknown
+	Region string
+	// Labels are the labels of the request context, see ContextWithPolicyLabels; nil when a
+	// model is created
+	Labels map[string]string
+}
+
+// Policy decides whether a model may be created and called. Check returns a PolicyViolation,
+// or another error that is reported as one, to refuse the model.
+type Policy interface {
+	Check(ctx context.Context, target *PolicyTarget) error
+}
+
+// PolicyFunc adapts a function to the Policy interface
+type PolicyFunc func(ctx context.Context, target *PolicyTarget) error
+
+// Check calls f(ctx, target)
+func (f PolicyFunc) Check(ctx context.Context, target *PolicyTarget) error {
+	return f(ctx, target)
+}
+
+// PolicyViolation is returned when a policy refuses a model
+type PolicyViolation struct {
+	Stage    PolicyStage
+	Provider string
+	Model    string
+	Region   string
+	// Rule is the name of the rule that refused the model
+	Rule   string
+	Reason string
+}
+
+func (e *PolicyViolation) Error() string {
+	msg := fmt.Sprintf("%s model %s is not allowed", e.Provider, e.Model)
+	if e.Rule != "" {
+		msg += " by policy rule " + e.Rule
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *PolicyViolation) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// PolicyEvent is the audit record of a policy decision
+type PolicyEvent struct {
+	Time     time.Time         `json:"time"`
+	Stage    PolicyStage       `json:"stage"`
+	Provider string            `json:"provider"`
+	Model    string            `json:"model"`
+	Region   string            `json:"region,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Allowed  bool              `json:"allowed"`
+	// Violation is why the model was refused
+	Violation *PolicyViolation `json:"violation,omitempty"`
+}
+
+// PolicyAuditHook receives the audit event of every policy decision, allowed or refused
+type PolicyAuditHook func(ctx context.Context, event *PolicyEvent)
+
+// PolicyRule allows or refuses models, optionally only for requests with given labels.
+// Patterns match "provider/model" and regions, with * matching any text.
+type PolicyRule struct {
+	// Name identifies the rule in violations and audit events
+	Name string
+	// When limits the rule to requests whose context labels have all these values. Rules
+	// without When also apply when models are created.
+	When map[string]string
+	// Allow lists the permitted models, such as "azure/*" or "openai/gpt-4o*"; empty permits
+	// every model that is not denied
+	Allow []string
+	// Deny lists the refused models and takes precedence over Allow
+	Deny []string
+	// Regions lists the permitted regions, such as "eu-*"; when set, providers without a region
+	// are refused
+	Regions []string
+}
+
+// ModelPolicy enforces allow and deny lists of models; a model must satisfy every rule that
+// applies to it
+type ModelPolicy struct {
+	Rules []PolicyRule
+}
+
+var _ Policy = (*ModelPolicy)(nil)
+
+// NewModelPolicy creates a policy enforcing rules
+func NewModelPolicy(rules ...PolicyRule) *ModelPolicy {
+	return &ModelPolicy{Rules: rules}
+}
+
+// Check returns a PolicyViolation for the first rule that refuses target
+func (p *ModelPolicy) Check(ctx context.Context, target *PolicyTarget) error {
+	name := target.Provider + "/" + target.Model
+	for _, rule := range p.Rules {
+		if !rule.applies(target.Labels) {
+			continue
+		}
+		violation := &PolicyViolation{Rule: rule.Name}
+		switch {
+		case matchAnyPattern(rule.Deny, name):
+			violation.Reason = "model is denied"
+		case len(rule.Allow) > 0 && !matchAnyPattern(rule.Allow, name):
+			violation.Reason = "model is not in the allowlist"
+		case len(rule.Regions) > 0 && target.Region == "":
+			violation.Reason = "provider region is unknown"
+		case len(rule.Regions) > 0 && !matchAnyPattern(rule.Regions, target.Region):
+			violation.Reason = fmt.Sprintf("region %s is not allowed", target.Region)
+		default:
+			continue
+		}
+		return violation
+	}
+	return nil
+}
+
+// applies reports whether the rule applies to a request with labels
+func (r *PolicyRule) applies(labels map[string]string) bool {
+	for key, value := range r.When {
+		if actual, ok := labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAnyPattern reports whether s matches one of patterns
+func matchAnyPattern(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches s against pattern, where * matches any text, including slashes
+func matchPattern(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+type policyLabelsKey struct{}
+
+// ContextWithPolicyLabels returns a context carrying labels that policy rules select requests
+// by, e.g. {"data": "pii"} or {"tenant_region": "eu"}. Labels added by nested calls replace
+// those of the same key.
+func ContextWithPolicyLabels(ctx context.Context, labels map[string]string) context.Context {
+	if len(labels) == 0 {
+		return ctx
+	}
+	merged := maps.Clone(PolicyLabelsFromContext(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(labels))
+	}
+	maps.Copy(merged, labels)
+	return context.WithValue(ctx, policyLabelsKey{}, merged)
+}
+
+// PolicyLabelsFromContext returns the policy labels of ctx
+func PolicyLabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(policyLabelsKey{}).(map[string]string)
+	return labels
+}
+
+// PolicyCompletionModel is a middleware that checks a policy before every request, with the
+// policy labels of the request context
+type PolicyCompletionModel struct {
+	model CompletionModel
+	check func(ctx context.Context) error
+}
+
+var _ CompletionModel = (*PolicyCompletionModel)(nil)
+
+func (m *PolicyCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.Complete(ctx, req)
+}
+
+func (m *PolicyCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.StreamComplete(ctx, req)
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *PolicyCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
+
+// PolicyConversationModel is a middleware that checks a policy before every request of a
+// conversation model
+type PolicyConversationModel struct {
+	model ConversationModel
+	check func(ctx context.Context) error
+}
+
+var _ ConversationModel = (*PolicyConversationModel)(nil)
+
+func (m *PolicyConversationModel) StreamResponse(ctx context.Context, req *ConversationRequest) (StreamConversationResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.StreamResponse(ctx, req)
+}
+
+func (m *PolicyConversationModel) Response(ctx context.Context, req *ConversationRequest) (*ConversationResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.Response(ctx, req)
+}
+
+// PolicyEmbeddingModel is a middleware that checks a policy before every embedding request
+type PolicyEmbeddingModel struct {
+	model EmbeddingModel
+	check func(ctx context.Context) error
+}
+
+var _ EmbeddingModel = (*PolicyEmbeddingModel)(nil)
+
+func (m *PolicyEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.GenerateEmbeddings(ctx, req)
+}
+
+// PolicyImageModel is a middleware that checks a policy before every image request
+type PolicyImageModel struct {
+	model ImageModel
+	check func(ctx context.Context) error
+}
+
+var _ ImageModel = (*PolicyImageModel)(nil)
+
+func (m *PolicyImageModel) GenerateImage(ctx context.Context, req *ImageRequest) (*ImageResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.GenerateImage(ctx, req)
+}
+
+// PolicyImageEditModel is a middleware that checks a policy before every image, edit and
+// variation request
+type PolicyImageEditModel struct {
+	*PolicyImageModel
+	model ImageEditModel
+}
+
+var _ ImageEditModel = (*PolicyImageEditModel)(nil)
+
+func (m *PolicyImageEditModel) EditImage(ctx context.Context, req *ImageEditRequest) (*ImageResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.EditImage(ctx, req)
+}
+
+func (m *PolicyImageEditModel) CreateVariation(ctx context.Context, req *ImageVariationRequest) (*ImageResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.CreateVariation(ctx, req)
+}
+
+// PolicyImageJobModel is a middleware that checks a policy before every image job is started
+// or resumed
+type PolicyImageJobModel struct {
+	*PolicyImageModel
+	model ImageJobModel
+}
+
+var _ ImageJobModel = (*PolicyImageJobModel)(nil)
+
+func (m *PolicyImageJobModel) StartImage(ctx context.Context, req *ImageRequest) (*ImageJob, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.StartImage(ctx, req)
+}
+
+func (m *PolicyImageJobModel) ResumeImage(ctx context.Context, id string, config *ImageModelConfig) (*ImageJob, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.ResumeImage(ctx, id, config)
+}
+
+// PolicySpeechModel is a middleware that checks a policy before every speech request
+type PolicySpeechModel struct {
+	model SpeechModel
+	check func(ctx context.Context) error
+}
+
+var _ SpeechModel = (*PolicySpeechModel)(nil)
+
+func (m *PolicySpeechModel) GenerateSpeech(ctx context.Context, req *SpeechRequest) (*SpeechResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.GenerateSpeech(ctx, req)
+}
+
+// PolicyVideoModel is a middleware that checks a policy before every video request
+type PolicyVideoModel struct {
+	model VideoModel
+	check func(ctx context.Context) error
+}
+
+var _ VideoModel = (*PolicyVideoModel)(nil)
+
+func (m *PolicyVideoModel) GenerateVideo(ctx context.Context, req *VideoRequest) (*VideoResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.GenerateVideo(ctx, req)
+}
+
+// PolicyRerankModel is a middleware that checks a policy before every rerank request
+type PolicyRerankModel struct {
+	model RerankModel
+	check func(ctx context.Context) error
+}
+
+var _ RerankModel = (*PolicyRerankModel)(nil)
+
+func (m *PolicyRerankModel) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	if err := m.check(ctx); err != nil {
+		return nil, err
+	}
+	return m.model.Rerank(ctx, req)
+}
+
+// enforcePolicy checks policy for the model described by target and reports the decision to
+// audit. Errors other than a PolicyViolation are reported as one.
+func enforcePolicy(ctx context.Context, policy Policy, audit PolicyAuditHook, target *PolicyTarget) error {
+	err := policy.Check(ctx, target)
+	var violation *PolicyViolation
+	if err != nil && !errors.As(err, &violation) {
+		violation = &PolicyViolation{Reason: err.Error()}
+	}
+	if violation != nil {
+		violation.Stage = target.Stage
+		violation.Provider = target.Provider
+		violation.Model = target.Model
+		violation.Region = target.Region
+	}
+
+	if audit != nil {
+		audit(ctx, &PolicyEvent{
+			Time:      time.Now(),
+			Stage:     target.Stage,
+			Provider:  target.Provider,
+			Model:     target.Model,
+			Region:    target.Region,
+			Labels:    target.Labels,
+			Allowed:   violation == nil,
+			Violation: violation,
+		})
+	}
+	if violation != nil {
+		return violation
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"*", "openai/gpt-4o", true},
+		{"openai/*", "openai/gpt-4o", true},
+		{"openai/*", "azure/gpt-4o", false},
+		{"openai/gpt-4o*", "openai/gpt-4o-mini", true},
+		{"openai/gpt-4o", "openai/gpt-4o-mini", false},
+		{"fireworks/*/llama*", "fireworks/accounts/fireworks/models/llama-v3", true},
+		{"*-mini", "openai/gpt-4o-mini", true},
+		{"eu-*", "eu-west-1", true},
+		{"eu-*", "us-east-1", false},
+		{"a*a", "a", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchPattern(tt.pattern, tt.s), "%s %s", tt.pattern, tt.s)
+	}
+}
+
+func TestModelPolicy_Check(t *testing.T) {
+	policy := NewModelPolicy(
+		PolicyRule{Name: "no-preview", Deny: []string{"*-preview"}},
+		PolicyRule{Name: "pii-internal", When: map[string]string{"data": "pii"}, Allow: []string{"azure/*", "ollama/*"}},
+		PolicyRule{Name: "eu-tenants", When: map[string]string{"tenant_region": "eu"}, Regions: []string{"eu-*", "europe-*"}},
+	)
+
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		region   string
+		labels   map[string]string
+		rule     string
+	}{
+		{name: "allowed", provider: "openai", model: "gpt-4o"},
+		{name: "denied", provider: "openai", model: "gpt-4o-audio-preview", rule: "no-preview"},
+		{name: "pii external", provider: "openai", model: "gpt-4o", labels: map[string]string{"data": "pii"}, rule: "pii-internal"},
+		{name: "pii internal", provider: "azure", model: "gpt-4o", labels: map[string]string{"data": "pii"}},
+		{name: "eu region", provider: "vertex", model: "gemini-2.5-flash", region: "europe-west4", labels: map[string]string{"tenant_region": "eu"}},
+		{name: "us region", provider: "vertex", model: "gemini-2.5-flash", region: "us-central1", labels: map[string]string{"tenant_region": "eu"}, rule: "eu-tenants"},
+		{name: "unknown region", provider: "openai", model: "gpt-4o", labels: map[string]string{"tenant_region": "eu"}, rule: "eu-tenants"},
+		{name: "us tenant", provider: "vertex", model: "gemini-2.5-flash", region: "us-central1", labels: map[string]string{"tenant_region": "us"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(context.Background(), &PolicyTarget{Provider: tt.provider, Model: tt.model, Region: tt.region, Labels: tt.labels})
+			if tt.rule == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var violation *PolicyViolation
+			require.ErrorAs(t, err, &violation)
+			assert.Equal(t, tt.rule, violation.Rule)
+			assert.ErrorIs(t, err, ErrPolicyViolation)
+		})
+	}
+}
+
+func TestContextWithPolicyLabels(t *testing.T) {
+	ctx := ContextWithPolicyLabels(context.Background(), map[string]string{"data": "pii", "tenant": "a"})
+	nested := ContextWithPolicyLabels(ctx, map[string]string{"tenant": "b"})
+	assert.Equal(t, map[string]string{"data": "pii", "tenant": "b"}, PolicyLabelsFromContext(nested))
+	assert.Equal(t, map[string]string{"data": "pii", "tenant": "a"}, PolicyLabelsFromContext(ctx))
+	assert.Nil(t, PolicyLabelsFromContext(context.Background()))
+}
+
+func TestDefaultModelProvider_Policy(t *testing.T) {
+	info := &ModelInfo{ID: "gpt-4o"}
+	var events []*PolicyEvent
+	audit := func(ctx context.Context, event *PolicyEvent) {
+		events = append(events, event)
+	}
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{info})
+	provider.SetRegion("us-east-1")
+	provider.SetPolicy(NewModelPolicy(
+		PolicyRule{Name: "pii-internal", When: map[string]string{"data": "pii"}, Allow: []string{"azure/*"}},
+	), audit)
+
+	require.NoError(t, provider.CheckPolicy(info))
+	require.Len(t, events, 1)
+	assert.Equal(t, PolicyStageModel, events[0].Stage)
+	assert.True(t, events[0].Allowed)
+
+	model := provider.EnforcePolicy(&stubCompletionModel{output: "hello"}, info)
+	_, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+
+	ctx := ContextWithPolicyLabels(context.Background(), map[string]string{"data": "pii"})
+	_, err = model.Complete(ctx, &CompletionRequest{})
+	var violation *PolicyViolation
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, &PolicyViolation{
+		Stage:    PolicyStageRequest,
+		Provider: "openai",
+		Model:    "gpt-4o",
+		Region:   "us-east-1",
+		Rule:     "pii-internal",
+		Reason:   "model is not in the allowlist",
+	}, violation)
+	assert.EqualError(t, err, "openai model gpt-4o is not allowed by policy rule pii-internal: model is not in the allowlist")
+	_, err = model.StreamComplete(ctx, &CompletionRequest{})
+	assert.ErrorIs(t, err, ErrPolicyViolation)
+
+	require.Len(t, events, 4)
+	assert.False(t, events[2].Allowed)
+	assert.Equal(t, map[string]string{"data": "pii"}, events[2].Labels)
+	assert.Same(t, violation, events[2].Violation)
+}
+
+func TestDefaultModelProvider_PolicyFunc(t *testing.T) {
+	info := &ModelInfo{ID: "gpt-4o"}
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{info})
+	provider.SetPolicy(PolicyFunc(func(ctx context.Context, target *PolicyTarget) error {
+		return errors.New("external providers are disabled")
+	}), nil)
+
+	err := provider.CheckPolicy(info)
+	var violation *PolicyViolation
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, PolicyStageModel, violation.Stage)
+	assert.Equal(t, "external providers are disabled", violation.Reason)
+
+	provider.SetPolicy(nil, nil)
+	assert.NoError(t, provider.CheckPolicy(info))
+	model := &stubCompletionModel{}
+	assert.Same(t, CompletionModel(model), provider.EnforcePolicy(model, info))
+}
+
+func TestDefaultModelProvider_EnforceEmbeddingPolicy(t *testing.T) {
+	info := &ModelInfo{ID: "text-embedding-3-small"}
+	var events []*PolicyEvent
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{info})
+	provider.SetPolicy(NewModelPolicy(
+		PolicyRule{Name: "pii-internal", When: map[string]string{"data": "pii"}, Allow: []string{"azure/*"}},
+	), func(ctx context.Context, event *PolicyEvent) {
+		events = append(events, event)
+	})
+
+	inner := &batchEmbeddingModel{}
+	model := provider.EnforceEmbeddingPolicy(inner, info)
+	_, err := model.GenerateEmbeddings(context.Background(), &EmbeddingRequest{Contents: []string{"a"}})
+	require.NoError(t, err)
+
+	ctx := ContextWithPolicyLabels(context.Background(), map[string]string{"data": "pii"})
+	_, err = model.GenerateEmbeddings(ctx, &EmbeddingRequest{Contents: []string{"a"}})
+	var violation *PolicyViolation
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, PolicyStageRequest, violation.Stage)
+	assert.Equal(t, "text-embedding-3-small", violation.Model)
+	assert.Equal(t, int32(1), inner.calls)
+	require.Len(t, events, 2)
+
+	provider.SetPolicy(nil, nil)
+	assert.Same(t, EmbeddingModel(inner), provider.EnforceEmbeddingPolicy(inner, info))
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Price is an exact decimal price in the currency of its ModelPricing, with a resolution of
+// one MicroCent (1e-8). Prices are parsed once when model lists are loaded, from JSON numbers
+// or decimal strings, and costs are computed from them in integer arithmetic.
+type Price struct {
+	amount MicroCents
+}
+
+// NewPrice returns the price of amount, rounded to the nearest MicroCent. Prices cannot be
+// negative, so NewPrice panics when amount is negative or not finite; use ParsePrice for
+// prices that are not constants.
+func NewPrice(amount float64) Price {
+	if !(amount >= 0) || math.IsInf(amount, 1) {
+		panic(fmt.Sprintf("llm: invalid price %v", amount))
+	}
+	return Price{amount: MicroCentsFromUSD(amount)}
+}
+
+// ParsePrice parses a non-negative decimal price such as "0.15", "2" or "3e-7" exactly,
+// rounding digits beyond the resolution of a MicroCent half up. Malformed, negative and out
+// of range prices return an error matching ErrInvalidPrice.
+func ParsePrice(s string) (Price, error) {
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || e < -100 || e > 100 {
+			return Price{}, fmt.Errorf("%w: %q", ErrInvalidPrice, s)
+		}
+		mantissa, exp = s[:i], e
+	}
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	digits := whole + frac
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Price{}, fmt.Errorf("%w: %q", ErrInvalidPrice, s)
+	}
+
+	n, _ := new(big.Int).SetString(digits, 10)
+	// The amount in MicroCents is n * 10^scale
+	scale := big.NewInt(int64(exp - len(frac) + 8))
+	if scale.Sign() >= 0 {
+		n.Mul(n, new(big.Int).Exp(big.NewInt(10), scale, nil))
+	} else {
+		d := new(big.Int).Exp(big.NewInt(10), scale.Neg(scale), nil)
+		var r big.Int
+		n.QuoRem(n, d, &r)
+		if r.Lsh(&r, 1).Cmp(d) >= 0 {
+			n.Add(n, big.NewInt(1))
+		}
+	}
+	if !n.IsInt64() {
+		return Price{}, fmt.Errorf("%w: %q is out of range", ErrInvalidPrice, s)
+	}
+	return Price{amount: MicroCents(n.Int64())}, nil
+}
+
+// Float64 returns the price as a float, e.g. for display
+func (p Price) Float64() float64 {
+	return p.amount.USD()
+}
+
+// IsZero reports whether the price is zero
+func (p Price) IsZero() bool {
+	return p.amount == 0
+}
+
+// Cost returns the exact cost of quantity units billed at the price, e.g. images or seconds
+// of video
+func (p Price) Cost(quantity float64) MicroCents {
+	if quantity == 0 || p.amount == 0 {
+		return 0
+	}
+	return MicroCents(math.Round(quantity * float64(p.amount)))
+}
+
+// mulDiv returns the price multiplied by num/den, rounded half up to a MicroCent. den must not
+// be zero.
+func (p Price) mulDiv(num, den Price) Price {
+	n := new(big.Int).Mul(big.NewInt(int64(p.amount)), big.NewInt(int64(num.amount)))
+	d := big.NewInt(int64(den.amount))
+	n.Add(n, new(big.Int).Rsh(d, 1)).Quo(n, d)
+	if !n.IsInt64() {
+		return Price{amount: math.MaxInt64}
+	}
+	return Price{amount: MicroCents(n.Int64())}
+}
+
+// String formats the price as a decimal without trailing zeros, e.g. "0.075"
+func (p Price) String() string {
+	whole, frac := int64(p.amount)/int64(MicroCentsPerUSD), int64(p.amount)%int64(MicroCentsPerUSD)
+	if frac == 0 {
+		return strconv.FormatInt(whole, 10)
+	}
+	return fmt.Sprintf("%d.%s", whole, strings.TrimRight(fmt.Sprintf("%08d", frac), "0"))
+}
+
+// MarshalJSON encodes the price as a JSON number
+func (p Price) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON decodes a price from a JSON number or decimal string, see ParsePrice. Null
+// decodes to a zero price.
+func (p *Price) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*p = Price{}
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	price, err := ParsePrice(s)
+	if err != nil {
+		return err
+	}
+	*p = price
+	return nil
+}
+
+// TokenCost returns the exact cost of tokens billed at pricePerMillion per million tokens,
+// rounded half up to a MicroCent
+func TokenCost(tokens int64, pricePerMillion Price) MicroCents {
+	if tokens <= 0 || pricePerMillion.amount <= 0 {
+		return 0
+	}
+	// tokens * amount can overflow 64 bits, so it is computed in 128
+	hi, lo := bits.Mul64(uint64(tokens), uint64(pricePerMillion.amount))
+	lo, carry := bits.Add64(lo, 500_000, 0)
+	hi += carry
+	if hi >= 1_000_000 {
+		return math.MaxInt64
+	}
+	q, _ := bits.Div64(hi, lo, 1_000_000)
+	if q > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return MicroCents(q)
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		price string
+		want  MicroCents
+	}{
+		{price: "0", want: 0},
+		{price: "2", want: 200_000_000},
+		{price: "0.075", want: 7_500_000},
+		{price: ".5", want: 50_000_000},
+		{price: "1.25e2", want: 12_500_000_000},
+		{price: "3E-7", want: 30},
+		{price: "0.000000075e6", want: 7_500_000},
+		{price: "0.000000005", want: 1},
+		{price: "0.000000004", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.price, func(t *testing.T) {
+			price, err := ParsePrice(tt.price)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, price.amount)
+		})
+	}
+
+	for _, invalid := range []string{"", ".", "-1", "+1", "1.2.3", "abc", "1e", "1e1000", "1e12"} {
+		_, err := ParsePrice(invalid)
+		assert.ErrorIs(t, err, ErrInvalidPrice, invalid)
+	}
+}
+
+func TestNewPrice(t *testing.T) {
+	assert.Equal(t, "0.075", NewPrice(0.075).String())
+	assert.True(t, NewPrice(0).IsZero())
+	assert.Panics(t, func() { NewPrice(-0.5) })
+	assert.Panics(t, func() { NewPrice(math.NaN()) })
+	assert.Panics(t, func() { NewPrice(math.Inf(1)) })
+}
+
+func TestPrice_JSON(t *testing.T) {
+	var pricing ModelPricing
+	require.NoError(t, json.Unmarshal([]byte(`{"prompt": 0.15, "completion": "0.6", "image": null, "currency": "EUR"}`), &pricing))
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(0.15), Completion: NewPrice(0.6), Currency: "EUR"}, pricing)
+
+	data, err := json.Marshal(ModelPricing{Prompt: NewPrice(0.075), Completion: NewPrice(3)})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"prompt": 0.075, "completion": 3, "request": 0, "image": 0, "webSearch": 0,
+		"internalReasoning": 0, "inputCacheRead": 0, "inputCacheWrite": 0, "audioInput": 0,
+		"audioOutput": 0, "characters": 0, "videoSecond": 0}`, string(data))
+
+	// Malformed prices fail to load instead of pricing the model at zero
+	err = json.Unmarshal([]byte(`{"prompt": "n/a"}`), &pricing)
+	assert.ErrorIs(t, err, ErrInvalidPrice)
+}
+
+func TestPrice_Cost(t *testing.T) {
+	assert.Equal(t, MicroCents(12_000_000), NewPrice(0.04).Cost(3))
+	assert.Equal(t, MicroCents(5_000_000), NewPrice(0.1).Cost(0.5))
+	assert.Zero(t, Price{}.Cost(10))
+	assert.Equal(t, "0.04", NewPrice(0.04).String())
+	assert.Equal(t, "12", NewPrice(12).String())
+
+	// Large token counts don't overflow
+	assert.Equal(t, MicroCents(1_000_000_000_000_000_000), TokenCost(10_000_000_000, NewPrice(1_000_000)))
+	assert.Equal(t, MicroCents(math.MaxInt64), TokenCost(math.MaxInt64, NewPrice(1_000_000)))
+}
+
+func TestModelPricing_Currency(t *testing.T) {
+	pricing := ModelPricing{Currency: "EUR"}
+	assert.True(t, pricing.IsZero())
+	pricing.Prompt = NewPrice(1)
+	assert.False(t, pricing.IsZero())
+
+	breakdown := (&ModelInfo{Pricing: pricing}).CostBreakdown(&TokenUsage{TotalInputTokens: 1_000_000})
+	assert.Equal(t, "EUR", breakdown.Currency)
+	assert.Equal(t, MicroCentsPerUSD, breakdown.Total())
+}
@@ -0,0 +1,164 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// PricingRule prices the models without pricing whose ID matches Pattern, with * matching any
+// text, e.g. "ft:gpt-4o-mini*" or "*"
+type PricingRule struct {
+	Pattern string
+	Pricing ModelPricing
+}
+
+// PricingOptions adjusts the pricing of the supported models of a provider, e.g. for
+// negotiated rates, fine-tuned models or custom deployments missing from the model catalog
+type PricingOptions struct {
+	// Overrides replace the pricing of models by ID, see WithPricingOverride
+	Overrides map[string]ModelPricing
+	// Defaults price the models without pricing, the first matching rule applying, see
+	// WithDefaultPricing
+	Defaults []PricingRule
+	// Strict fails the creation of models without pricing instead of logging a warning
+	Strict bool
+}
+
+// WithPricingOverride replaces the pricing of model, including its service tier and long
+// context prices. A model missing from the catalog of the provider is added as a text
+// completion model, so fine-tuned models and custom deployments can be created and priced.
+func WithPricingOverride(model string, pricing ModelPricing) ModelOption {
+	return func(o *ModelOptions) {
+		if o.Pricing == nil {
+			o.Pricing = &PricingOptions{}
+		}
+		if o.Pricing.Overrides == nil {
+			o.Pricing.Overrides = make(map[string]ModelPricing)
+		}
+		o.Pricing.Overrides[model] = pricing
+	}
+}
+
+// WithDefaultPricing adds a rule to the default pricing table of the provider, which prices
+// the models whose catalog entry has no pricing, such as models listed by a models endpoint,
+// so their cost is estimated instead of reported as zero. Rules are tried in the order they
+// were added.
+func WithDefaultPricing(pattern string, pricing ModelPricing) ModelOption {
+	return func(o *ModelOptions) {
+		if o.Pricing == nil {
+			o.Pricing = &PricingOptions{}
+		}
+		o.Pricing.Defaults = append(o.Pricing.Defaults, PricingRule{Pattern: pattern, Pricing: pricing})
+	}
+}
+
+// WithStrictPricing makes the provider return a MissingPricingWarning for models without
+// pricing instead of logging it
+func WithStrictPricing(strict bool) ModelOption {
+	return func(o *ModelOptions) {
+		if o.Pricing == nil {
+			o.Pricing = &PricingOptions{}
+		}
+		o.Pricing.Strict = strict
+	}
+}
+
+// MissingPricingWarning reports that a model has no pricing, so its cost is reported as zero.
+// It is logged once per model when the model is created, or returned with WithStrictPricing.
+type MissingPricingWarning struct {
+	Provider string
+	Model    string
+}
+
+func (w *MissingPricingWarning) Error() string {
+	return fmt.Sprintf("%s model %s has no pricing, its cost is reported as zero", w.Provider, w.Model)
+}
+
+func (w *MissingPricingWarning) Is(target error) bool {
+	return target == ErrPricingMissing
+}
+
+// apply returns models with the overrides and default pricing applied. Models that change are
+// copied, and overridden models missing from models are appended in the order of their IDs.
+func (o *PricingOptions) apply(models []*ModelInfo) []*ModelInfo {
+	if o == nil || (len(o.Overrides) == 0 && len(o.Defaults) == 0) {
+		return models
+	}
+	priced := make([]*ModelInfo, 0, len(models)+len(o.Overrides))
+	seen := make(map[string]bool, len(models))
+	for _, model := range models {
+		seen[model.ID] = true
+		if pricing, ok := o.Overrides[model.ID]; ok {
+			overridden := *model
+			overridden.Pricing = pricing
+			overridden.ServiceTierPricing, overridden.LongContextPricing = nil, nil
+			model = &overridden
+		} else if model.Pricing.IsZero() {
+			if pricing, ok := o.defaultPricing(model.ID); ok {
+				estimated := *model
+				estimated.Pricing = pricing
+				model = &estimated
+			}
+		}
+		priced = append(priced, model)
+	}
+	for _, id := range slices.Sorted(maps.Keys(o.Overrides)) {
+		if seen[id] {
+			continue
+		}
+		priced = append(priced, &ModelInfo{
+			ID:           id,
+			Name:         id,
+			Capabilities: []ModelCapability{ModelCapabilityCompletion},
+			Input:        []ModelMediaType{ModelMediaTypeText},
+			Output:       []ModelMediaType{ModelMediaTypeText},
+			Pricing:      o.Overrides[id],
+		})
+	}
+	return priced
+}
+
+// defaultPricing returns the pricing of the first default rule matching model
+func (o *PricingOptions) defaultPricing(model string) (ModelPricing, bool) {
+	for _, rule := range o.Defaults {
+		if matchPattern(rule.Pattern, model) {
+			return rule.Pricing, true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+// SetPricing applies the pricing overrides and default pricing of options to the supported
+// models, including those of later refreshes. A nil options keeps the pricing of the catalog.
+func (p *DefaultModelProvider) SetPricing(options *PricingOptions) {
+	p.pricing = options
+	p.catalog.SetPricing(options)
+}
+
+// CheckPricing logs a MissingPricingWarning once per model for models without pricing, or
+// returns it with strict pricing. Models whose pricing is marked Free have pricing. Providers
+// call it through CheckModel when creating models.
+func (p *DefaultModelProvider) CheckPricing(info *ModelInfo) error {
+	if !info.Pricing.IsZero() {
+		return nil
+	}
+	warning := &MissingPricingWarning{Provider: p.name, Model: info.ID}
+	if p.pricing != nil && p.pricing.Strict {
+		return warning
+	}
+	if _, warned := p.warned.LoadOrStore("pricing/"+info.ID, true); warned {
+		return nil
+	}
+	logger := p.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Warn("model has no pricing, its cost is reported as zero",
+		slog.String("provider", p.name), slog.String("model", info.ID))
+	return nil
+}
@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricingOptions_Apply(t *testing.T) {
+	models := []*ModelInfo{
+		{
+			ID:                 "gpt-4o",
+			Pricing:            ModelPricing{Prompt: NewPrice(2.5), Completion: NewPrice(10)},
+			ServiceTierPricing: map[ServiceTier]ModelPricing{ServiceTierFlex: {Prompt: NewPrice(1.25), Completion: NewPrice(5)}},
+		},
+		{ID: "llama-3-8b"},
+		{ID: "mistral-7b"},
+	}
+	var options ModelOptions
+	for _, opt := range []ModelOption{
+		WithPricingOverride("gpt-4o", ModelPricing{Prompt: NewPrice(2), Completion: NewPrice(8)}),
+		WithPricingOverride("ft:gpt-4o-mini:acme", ModelPricing{Prompt: NewPrice(0.3), Completion: NewPrice(1.2)}),
+		WithDefaultPricing("llama-*", ModelPricing{Prompt: NewPrice(0.1), Completion: NewPrice(0.1)}),
+		WithDefaultPricing("*", ModelPricing{Prompt: NewPrice(1), Completion: NewPrice(1)}),
+	} {
+		opt(&options)
+	}
+
+	priced := options.Pricing.apply(models)
+	require.Len(t, priced, 4)
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(2), Completion: NewPrice(8)}, priced[0].Pricing)
+	assert.Nil(t, priced[0].ServiceTierPricing, "overrides replace the service tier prices")
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(0.1), Completion: NewPrice(0.1)}, priced[1].Pricing, "the first matching rule applies")
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(1), Completion: NewPrice(1)}, priced[2].Pricing)
+
+	// Overridden models missing from the catalog are added as completion models
+	assert.Equal(t, "ft:gpt-4o-mini:acme", priced[3].ID)
+	assert.True(t, priced[3].HasCapability(ModelCapabilityCompletion))
+
+	// The models are copied
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(2.5), Completion: NewPrice(10)}, models[0].Pricing)
+	assert.Equal(t, ModelPricing{}, models[1].Pricing)
+	assert.Equal(t, models, (*PricingOptions)(nil).apply(models))
+}
+
+func TestDefaultModelProvider_SetPricing(t *testing.T) {
+	provider := NewDefaultModelProvider("test", []*ModelInfo{{ID: "chat-1", Capabilities: []ModelCapability{ModelCapabilityCompletion}}})
+	provider.SetPricing(&PricingOptions{
+		Overrides: map[string]ModelPricing{"ft:chat-1:acme": {Prompt: NewPrice(3), Completion: NewPrice(6)}},
+		Defaults:  []PricingRule{{Pattern: "*", Pricing: ModelPricing{Prompt: NewPrice(1), Completion: NewPrice(2)}}},
+	})
+
+	info := provider.GetModelInfo("ft:chat-1:acme")
+	require.NotNil(t, info)
+	breakdown := info.CostBreakdown(&TokenUsage{TotalInputTokens: 1_000_000, TotalOutputTokens: 500_000})
+	assert.Equal(t, 6*MicroCentsPerUSD, breakdown.Total())
+
+	// Refreshed models are priced too
+	provider.ModelCatalog().SetSource(ModelSourceFunc(func(ctx context.Context) ([]*ModelInfo, error) {
+		return []*ModelInfo{{ID: "chat-2", Capabilities: []ModelCapability{ModelCapabilityCompletion}}}, nil
+	}), 0)
+	require.NoError(t, provider.ModelCatalog().Refresh(context.Background()))
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(1), Completion: NewPrice(2)}, provider.GetModelInfo("chat-2").Pricing)
+	assert.Equal(t, ModelPricing{Prompt: NewPrice(3), Completion: NewPrice(6)}, provider.GetModelInfo("ft:chat-1:acme").Pricing)
+
+	provider.SetPricing(nil)
+	assert.Equal(t, ModelPricing{}, provider.GetModelInfo("chat-2").Pricing)
+	assert.Nil(t, provider.GetModelInfo("ft:chat-1:acme"))
+}
+
+func TestCheckPricing(t *testing.T) {
+	priced := &ModelInfo{ID: "priced", Pricing: ModelPricing{Prompt: NewPrice(1)}}
+	free := &ModelInfo{ID: "local", Pricing: ModelPricing{Free: true}}
+	unpriced := &ModelInfo{ID: "custom"}
+
+	var buf bytes.Buffer
+	provider := NewDefaultModelProvider("test", []*ModelInfo{priced, free, unpriced})
+	provider.SetDeprecationPolicy(slog.New(slog.NewTextHandler(&buf, nil)), false)
+
+	require.NoError(t, provider.CheckPricing(priced))
+	require.NoError(t, provider.CheckModel(free))
+	assert.Empty(t, buf.String())
+
+	require.NoError(t, provider.CheckPricing(unpriced))
+	require.NoError(t, provider.CheckPricing(unpriced))
+	assert.Equal(t, 1, strings.Count(buf.String(), "level=WARN"), "the warning is logged once per model")
+	assert.Contains(t, buf.String(), "model=custom")
+
+	provider.SetPricing(&PricingOptions{Strict: true})
+	err := provider.CheckPricing(unpriced)
+	assert.ErrorIs(t, err, ErrPricingMissing)
+	var warning *MissingPricingWarning
+	require.True(t, errors.As(err, &warning))
+	assert.Equal(t, "test", warning.Provider)
+	assert.Equal(t, "custom", warning.Model)
+	assert.ErrorIs(t, provider.CheckModel(unpriced), ErrPricingMissing)
+	assert.NoError(t, provider.CheckModel(free), "explicitly free models have pricing")
+}
@@ -0,0 +1,14 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/anthropic"
+)
+
+// NewAnthropicModelProvider creates a new Anthropic model provider that uses the native Messages API
+func NewAnthropicModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return anthropic.NewAnthropicModelProvider(opts...)
+}
@@ -5,9 +5,22 @@ package providers
 import (
 	"github.com/easyagent-dev/llm"
 	"github.com/easyagent-dev/llm/internal/providers/azure"
+	"golang.org/x/oauth2"
 )
 
 // NewAzureOpenAIModelProvider creates a new Azure OpenAI model that supports llm, embedding, and image generation
 func NewAzureOpenAIModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
 	return azure.NewAzureOpenAIModelProvider(opts...)
 }
+
+// NewAzureManagedIdentityTokenSource returns a source of managed identity tokens for Azure
+// OpenAI, for use with llm.WithTokenSource. An empty clientID uses the system-assigned identity.
+func NewAzureManagedIdentityTokenSource(clientID string) oauth2.TokenSource {
+	return azure.NewManagedIdentityTokenSource(clientID)
+}
+
+// NewAzureClientSecretTokenSource returns a source of Microsoft Entra ID tokens for Azure
+// OpenAI issued to an application with a client secret, for use with llm.WithTokenSource
+func NewAzureClientSecretTokenSource(tenantID, clientID, clientSecret string) oauth2.TokenSource {
+	return azure.NewClientSecretTokenSource(tenantID, clientID, clientSecret)
+}
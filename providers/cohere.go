@@ -0,0 +1,15 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/cohere"
+)
+
+// NewCohereModelProvider creates a new Cohere model provider for Command chat, Embed and
+// Rerank models
+func NewCohereModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return cohere.NewCohereModelProvider(opts...)
+}
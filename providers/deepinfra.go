@@ -0,0 +1,29 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/deepinfra"
+)
+
+// NewDeepInfraModelProvider creates a new DeepInfra model provider that supports llm
+func NewDeepInfraModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return deepinfra.NewDeepInfraModelProvider(opts...)
+}
+
+// WithDeepInfraTopK samples DeepInfra completions only from the k most likely tokens
+func WithDeepInfraTopK(k int) llm.ModelOption {
+	return deepinfra.WithTopK(k)
+}
+
+// WithDeepInfraMinP drops tokens less likely than p times the most likely token
+func WithDeepInfraMinP(p float64) llm.ModelOption {
+	return deepinfra.WithMinP(p)
+}
+
+// WithDeepInfraRepetitionPenalty penalizes repeated tokens in DeepInfra completions
+func WithDeepInfraRepetitionPenalty(penalty float64) llm.ModelOption {
+	return deepinfra.WithRepetitionPenalty(penalty)
+}
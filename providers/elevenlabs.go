@@ -0,0 +1,14 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/elevenlabs"
+)
+
+// NewElevenLabsModelProvider creates a new ElevenLabs text-to-speech model provider
+func NewElevenLabsModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return elevenlabs.NewElevenLabsModelProvider(opts...)
+}
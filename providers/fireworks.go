@@ -0,0 +1,31 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/fireworks"
+)
+
+// NewFireworksModelProvider creates a new Fireworks AI model provider that supports llm
+func NewFireworksModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return fireworks.NewFireworksModelProvider(opts...)
+}
+
+// WithFireworksPromptCacheMaxLen limits how many prompt tokens Fireworks caches; zero
+// disables prompt caching
+func WithFireworksPromptCacheMaxLen(tokens int) llm.ModelOption {
+	return fireworks.WithPromptCacheMaxLen(tokens)
+}
+
+// WithFireworksSessionAffinity routes requests with the same session ID to the same Fireworks
+// replica to raise prompt cache hit rates
+func WithFireworksSessionAffinity(sessionID string) llm.ModelOption {
+	return fireworks.WithSessionAffinity(sessionID)
+}
+
+// WithFireworksSpeculation sets the text Fireworks uses to speculate the start of every response
+func WithFireworksSpeculation(text string) llm.ModelOption {
+	return fireworks.WithSpeculation(text)
+}
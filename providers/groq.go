@@ -0,0 +1,16 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/groq"
+)
+
+// NewGroqModelProvider creates a new Groq model provider for low-latency Llama, Mixtral and
+// Gemma models. Completions report Groq's server timing in Metadata.ServerTiming, and streams
+// in the Metadata of their usage chunk.
+func NewGroqModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return groq.NewGroqModelProvider(opts...)
+}
@@ -0,0 +1,14 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/jina"
+)
+
+// NewJinaModelProvider creates a new Jina model provider for Jina AI embedding and reranker models
+func NewJinaModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return jina.NewJinaModelProvider(opts...)
+}
@@ -0,0 +1,20 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/mistral"
+)
+
+// NewMistralModelProvider creates a new Mistral AI model provider for Mistral and Mixtral chat
+// models and mistral-embed embeddings
+func NewMistralModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return mistral.NewMistralModelProvider(opts...)
+}
+
+// WithMistralSafePrompt prepends Mistral's safety system prompt to every conversation
+func WithMistralSafePrompt(enabled bool) llm.ModelOption {
+	return mistral.WithSafePrompt(enabled)
+}
@@ -0,0 +1,15 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/onnx"
+)
+
+// NewONNXModelProvider creates a provider of local embedding models run with ONNX Runtime.
+// Models are read from <modelDir>/<model id>/ and the provider requires the onnx build tag.
+func NewONNXModelProvider(modelDir, libraryPath string) (llm.ModelProvider, error) {
+	return onnx.NewONNXModelProvider(modelDir, libraryPath)
+}
@@ -12,3 +12,10 @@ import (
 func NewOpenAIModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
 	return openai.NewOpenAIModelProvider(opts...)
 }
+
+// NewOpenAICompatibleModelProvider creates a provider for an OpenAI compatible gateway at the
+// base URL given with llm.WithBaseURL. llm.WithAuthStyle sets where the gateway expects the API
+// key and llm.WithModels the models it serves, which default to the OpenAI models.
+func NewOpenAICompatibleModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return openai.NewOpenAICompatibleModelProvider(opts...)
+}
@@ -0,0 +1,55 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+package providers
+
+import (
+	"os"
+
+	"github.com/easyagent-dev/llm"
+)
+
+// Importing this package registers its providers in llm.DefaultRegistry, so their models can
+// be opened with llm.Open, with the API key of each provider read from the environment
+func init() {
+	register := func(name string, factory llm.ProviderFactory, apiKeyEnv ...string) {
+		llm.Register(name, llm.ProviderRegistration{New: factory, APIKeyEnv: apiKeyEnv})
+	}
+	register("openai", NewOpenAIModelProvider, "OPENAI_API_KEY")
+	register("anthropic", NewAnthropicModelProvider, "ANTHROPIC_API_KEY", "CLAUDE_API_KEY")
+	register("gemini", NewGeminiModelProvider, "GEMINI_API_KEY", "GOOGLE_API_KEY")
+	register("deepseek", NewDeepSeekModelProvider, "DEEPSEEK_API_KEY")
+	register("openrouter", NewOpenRouterModel, "OPENROUTER_API_KEY")
+	register("fireworks", NewFireworksModelProvider, "FIREWORKS_API_KEY")
+	register("together", NewTogetherModelProvider, "TOGETHER_API_KEY")
+	register("deepinfra", NewDeepInfraModelProvider, "DEEPINFRA_API_KEY", "DEEPINFRA_TOKEN")
+	register("mistral", NewMistralModelProvider, "MISTRAL_API_KEY")
+	register("groq", NewGroqModelProvider, "GROQ_API_KEY")
+	register("cohere", NewCohereModelProvider, "COHERE_API_KEY", "CO_API_KEY")
+	register("jina", NewJinaModelProvider, "JINA_API_KEY")
+	register("voyage", NewVoyageModelProvider, "VOYAGE_API_KEY")
+	register("replicate", NewReplicateModelProvider, "REPLICATE_API_TOKEN")
+	register("elevenlabs", NewElevenLabsModelProvider, "ELEVENLABS_API_KEY")
+
+	llm.Register("azure", llm.ProviderRegistration{
+		New: func(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+			if version := os.Getenv("OPENAI_API_VERSION"); version != "" {
+				opts = append([]llm.ModelOption{llm.WithAPIVersion(version)}, opts...)
+			}
+			return NewAzureOpenAIModelProvider(opts...)
+		},
+		APIKeyEnv:  []string{"AZURE_OPENAI_API_KEY"},
+		BaseURLEnv: "AZURE_OPENAI_ENDPOINT",
+	})
+	llm.Register("vertex", llm.ProviderRegistration{
+		New: func(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+			var env []llm.ModelOption
+			if project := os.Getenv("GOOGLE_CLOUD_PROJECT"); project != "" {
+				env = append(env, llm.WithProject(project))
+			}
+			if location := os.Getenv("GOOGLE_CLOUD_LOCATION"); location != "" {
+				env = append(env, llm.WithLocation(location))
+			}
+			return NewVertexModelProvider(append(env, opts...)...)
+		},
+	})
+}
@@ -0,0 +1,15 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/together"
+)
+
+// NewTogetherModelProvider creates a new Together AI model provider. Its chat and embedding
+// models, with their prices, are discovered from the Together API.
+func NewTogetherModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return together.NewTogetherModelProvider(opts...)
+}
@@ -0,0 +1,15 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/vertex"
+)
+
+// NewVertexModelProvider creates a new Google Vertex AI model provider that authenticates with
+// Application Default Credentials or a service account key
+func NewVertexModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return vertex.NewVertexModelProvider(opts...)
+}
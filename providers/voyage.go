@@ -0,0 +1,14 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/providers/voyage"
+)
+
+// NewVoyageModelProvider creates a new Voyage model provider for Voyage AI embedding and rerank models
+func NewVoyageModelProvider(opts ...llm.ModelOption) (llm.ModelProvider, error) {
+	return voyage.NewVoyageModelProvider(opts...)
+}
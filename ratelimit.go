@@ -0,0 +1,244 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions configures client side rate limiting
+type RateLimitOptions struct {
+	// RequestsPerMinute and TokensPerMinute are the sustained rates, 0 for no limit
+	RequestsPerMinute int
+	TokensPerMinute   int
+	// RequestBurst and TokenBurst are how many requests and tokens may be sent at once after
+	// a quiet period. They default to the per minute rates, like the windows of providers.
+	RequestBurst int
+	TokenBurst   int
+}
+
+// RateLimiter paces requests to stay below a provider's requests and tokens per minute. It
+// refills both budgets continuously and is safe for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	requests *tokenBucket
+	tokens   *tokenBucket
+}
+
+// NewRateLimiter creates a rate limiter that starts with full bursts
+func NewRateLimiter(options RateLimitOptions) *RateLimiter {
+	now := time.Now()
+	return &RateLimiter{
+		requests: newTokenBucket(options.RequestsPerMinute, options.RequestBurst, now),
+		tokens:   newTokenBucket(options.TokensPerMinute, options.TokenBurst, now),
+	}
+}
+
+// Wait blocks until a request with an estimated number of tokens may be sent and reserves
+// them. It fails without waiting when ctx would expire first. Estimates larger than the token
+// burst wait for a full burst.
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		delay := max(l.requests.delay(1, now), l.tokens.delay(float64(tokens), now))
+		if delay == 0 {
+			l.requests.take(1)
+			l.tokens.take(float64(tokens))
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(delay)) {
+			return fmt.Errorf("rate limit delay of %s exceeds the context deadline: %w", delay, context.DeadlineExceeded)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Adjust corrects the tokens reserved by Wait once the actual usage of the request is known.
+// Requests that used more tokens than estimated delay the following ones.
+func (l *RateLimiter) Adjust(estimated, actual int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens.refill(time.Now())
+	l.tokens.take(float64(actual - estimated))
+}
+
+// tokenBucket is a token bucket that refills at rate per second up to capacity. The level may
+// go negative when usage exceeds the reservation. A nil bucket is unlimited.
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	level    float64
+	last     time.Time
+}
+
+func newTokenBucket(perMinute, burst int, now time.Time) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &tokenBucket{
+		rate:     float64(perMinute) / 60,
+		capacity: float64(burst),
+		level:    float64(burst),
+		last:     now,
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b == nil {
+		return
+	}
+	b.level = min(b.level+now.Sub(b.last).Seconds()*b.rate, b.capacity)
+	b.last = now
+}
+
+// delay returns how long until n can be taken
+func (b *tokenBucket) delay(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.refill(now)
+	n = min(n, b.capacity)
+	if b.level >= n {
+		return 0
+	}
+	return time.Duration((n - b.level) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b == nil {
+		return
+	}
+	b.level = min(b.level-min(n, b.capacity), b.capacity)
+}
+
+// RateLimitedCompletionModel waits on a RateLimiter before each completion. Input tokens are
+// estimated with the wrapped model's TokenCounter, or heuristically, and corrected with the
+// reported usage.
+type RateLimitedCompletionModel struct {
+	model   CompletionModel
+	limiter *RateLimiter
+}
+
+var _ CompletionModel = (*RateLimitedCompletionModel)(nil)
+var _ TokenCounter = (*RateLimitedCompletionModel)(nil)
+
+// NewRateLimitedCompletionModel wraps model with rate limiting. Models sharing a limiter share
+// its rates.
+func NewRateLimitedCompletionModel(model CompletionModel, limiter *RateLimiter) *RateLimitedCompletionModel {
+	return &RateLimitedCompletionModel{model: model, limiter: limiter}
+}
+
+func (m *RateLimitedCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	estimate := m.estimate(req)
+	if err := m.limiter.Wait(ctx, estimate); err != nil {
+		return nil, err
+	}
+	resp, err := m.model.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Usage != nil {
+		m.limiter.Adjust(estimate, usedTokens(resp.Usage))
+	}
+	return resp, nil
+}
+
+func (m *RateLimitedCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	estimate := m.estimate(req)
+	if err := m.limiter.Wait(ctx, estimate); err != nil {
+		return nil, err
+	}
+	stream, err := m.model.StreamComplete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer close(out)
+		for chunk := range stream {
+			if c, ok := chunk.(StreamUsageChunk); ok && c.Usage != nil {
+				m.limiter.Adjust(estimate, usedTokens(c.Usage))
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// The consumer is gone; let the upstream stream finish on its own
+				go func() {
+					for range stream {
+					}
+				}()
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *RateLimitedCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
+
+func (m *RateLimitedCompletionModel) estimate(req *CompletionRequest) int {
+	if count, err := CountTokens(m.model, req); err == nil {
+		return count.InputTokens
+	}
+	return CountRequestTokens(HeuristicTokenizer{}, req)
+}
+
+// RateLimitedEmbeddingModel waits on a RateLimiter before each embedding request, with the
+// input tokens estimated heuristically and corrected with the reported usage
+type RateLimitedEmbeddingModel struct {
+	model   EmbeddingModel
+	limiter *RateLimiter
+}
+
+var _ EmbeddingModel = (*RateLimitedEmbeddingModel)(nil)
+
+// NewRateLimitedEmbeddingModel wraps model with rate limiting
+func NewRateLimitedEmbeddingModel(model EmbeddingModel, limiter *RateLimiter) *RateLimitedEmbeddingModel {
+	return &RateLimitedEmbeddingModel{model: model, limiter: limiter}
+}
+
+func (m *RateLimitedEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	var estimate int
+	if req != nil {
+		for _, content := range req.Contents {
+			estimate += HeuristicTokenizer{}.CountTokens(content)
+		}
+	}
+	if err := m.limiter.Wait(ctx, estimate); err != nil {
+		return nil, err
+	}
+	resp, err := m.model.GenerateEmbeddings(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Usage != nil {
+		m.limiter.Adjust(estimate, usedTokens(resp.Usage))
+	}
+	return resp, nil
+}
+
+// usedTokens returns the tokens of usage that count against a tokens per minute limit
+func usedTokens(usage *TokenUsage) int {
+	return int(usage.TotalInputTokens + usage.TotalOutputTokens)
+}
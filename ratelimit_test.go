@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_Requests(t *testing.T) {
+	// 100 requests per second with bursts of 2
+	limiter := NewRateLimiter(RateLimitOptions{RequestsPerMinute: 6000, RequestBurst: 2})
+	ctx := context.Background()
+
+	start := time.Now()
+	require.NoError(t, limiter.Wait(ctx, 0))
+	require.NoError(t, limiter.Wait(ctx, 0))
+	assert.Less(t, time.Since(start), 5*time.Millisecond)
+	require.NoError(t, limiter.Wait(ctx, 0))
+	assert.GreaterOrEqual(t, time.Since(start), 5*time.Millisecond)
+}
+
+func TestRateLimiter_Context(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitOptions{RequestsPerMinute: 60, RequestBurst: 1})
+	require.NoError(t, limiter.Wait(context.Background(), 0))
+
+	// The next request is a second away, past the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, limiter.Wait(ctx, 0), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	assert.ErrorIs(t, limiter.Wait(ctx, 0), context.Canceled)
+}
+
+func TestRateLimiter_Adjust(t *testing.T) {
+	// 100 tokens per second with bursts of 100
+	limiter := NewRateLimiter(RateLimitOptions{TokensPerMinute: 6000, TokenBurst: 100})
+	require.NoError(t, limiter.Wait(context.Background(), 10))
+
+	// Using 200 tokens instead of 10 leaves a debt of 100 tokens
+	limiter.Adjust(10, 200)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, limiter.Wait(ctx, 1), context.DeadlineExceeded)
+
+	// Estimates larger than the burst wait for a full burst
+	limiter = NewRateLimiter(RateLimitOptions{TokensPerMinute: 6000, TokenBurst: 100})
+	require.NoError(t, limiter.Wait(context.Background(), 1000))
+}
+
+func TestRateLimitedCompletionModel(t *testing.T) {
+	// 1000 tokens per second with bursts of 100, the estimate of pricedModel
+	limiter := NewRateLimiter(RateLimitOptions{TokensPerMinute: 60000, TokenBurst: 100})
+	inner := &pricedModel{}
+	model := NewRateLimitedCompletionModel(inner, limiter)
+
+	_, err := model.Complete(context.Background(), &CompletionRequest{Instructions: "hi"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = model.Complete(ctx, &CompletionRequest{Instructions: "hi"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = model.StreamComplete(ctx, &CompletionRequest{Instructions: "hi"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, inner.calls)
+
+	count, err := model.CountTokens(&CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, count.InputTokens)
+}
+
+// unstoppableModel streams chunks until it is done, whatever its context
+type unstoppableModel struct {
+	chunkModel
+	chunks int
+	done   chan struct{}
+}
+
+func (m *unstoppableModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	ch := make(chan StreamChunk)
+	go func() {
+		defer close(m.done)
+		defer close(ch)
+		for range m.chunks {
+			ch <- StreamTextChunk{Text: "ok"}
+		}
+	}()
+	return ch, nil
+}
+
+func TestRateLimitedCompletionModel_StreamCanceled(t *testing.T) {
+	upstream := &unstoppableModel{chunks: 10, done: make(chan struct{})}
+	model := NewRateLimitedCompletionModel(upstream, NewRateLimiter(RateLimitOptions{}))
+
+	// The stream is abandoned without being read, and the upstream is still drained
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := model.StreamComplete(ctx, &CompletionRequest{})
+	require.NoError(t, err)
+	cancel()
+	select {
+	case <-upstream.done:
+	case <-time.After(time.Second):
+		t.Fatal("the upstream stream was not drained")
+	}
+}
+
+func TestRateLimitedEmbeddingModel(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitOptions{RequestsPerMinute: 60, RequestBurst: 1})
+	inner := &batchEmbeddingModel{}
+	model := NewRateLimitedEmbeddingModel(inner, limiter)
+
+	_, err := model.GenerateEmbeddings(context.Background(), &EmbeddingRequest{Contents: []string{"a"}})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = model.GenerateEmbeddings(ctx, &EmbeddingRequest{Contents: []string{"a"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), inner.calls)
+}
+
+func TestDefaultModelProvider_RateLimit(t *testing.T) {
+	options := ApplyOptions([]ModelOption{WithRateLimitBurst(5, 1000), WithRateLimit(60, 10000)})
+	assert.Equal(t, &RateLimitOptions{RequestsPerMinute: 60, TokensPerMinute: 10000, RequestBurst: 5, TokenBurst: 1000}, options.RateLimit)
+
+	provider := NewDefaultModelProvider("test", nil)
+	provider.SetRateLimit(options.RateLimit)
+	require.NotNil(t, provider.RateLimiter())
+	// Cost tracking wraps the rate limiting, so budgets are checked on every attempt
+	assert.IsType(t, &RateLimitedCompletionModel{}, provider.WrapCompletionModel(&pricedModel{}).(*CostTrackingCompletionModel).model)
+	assert.IsType(t, &RateLimitedEmbeddingModel{}, provider.WrapEmbeddingModel(&batchEmbeddingModel{}).(*CostTrackingEmbeddingModel).model)
+
+	provider.SetRateLimit(nil)
+	assert.Nil(t, provider.RateLimiter())
+}
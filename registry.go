@@ -0,0 +1,191 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrUnknownProvider is returned when opening a model of a provider that is not registered
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// ProviderFactory creates a provider with the given options
+type ProviderFactory func(opts ...ModelOption) (ModelProvider, error)
+
+// ProviderRegistration describes how a Registry creates a provider
+type ProviderRegistration struct {
+	// New creates the provider
+	New ProviderFactory
+	// APIKeyEnv are the environment variables holding the API key; the first one set is used
+	APIKeyEnv []string
+	// BaseURLEnv is the environment variable holding the base URL, if any
+	BaseURLEnv string
+}
+
+// Registry creates providers by name and models by URI, e.g. "openai/gpt-4o-mini". Providers
+// are created once, on first use, with their credentials from the environment followed by the
+// options set with Configure, and shared by the models opened from them. Providers are created
+// without holding the lock of the registry, so a provider discovering its models over a slow
+// network does not block the others, and concurrent first uses of a provider create it once.
+type Registry struct {
+	mu            sync.Mutex
+	registrations map[string]ProviderRegistration
+	options       map[string][]ModelOption
+	providers     map[string]ModelProvider
+	// generations counts the calls to Configure of each provider, so providers created with
+	// options replaced in the meantime are not kept
+	generations map[string]int
+	creating    singleflight.Group
+	getenv      func(string) string
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		registrations: make(map[string]ProviderRegistration),
+		options:       make(map[string][]ModelOption),
+		providers:     make(map[string]ModelProvider),
+		generations:   make(map[string]int),
+		getenv:        os.Getenv,
+	}
+}
+
+// DefaultRegistry is the registry used by Register, Configure and Open. The providers of the
+// providers package register themselves in it when that package is imported.
+var DefaultRegistry = NewRegistry()
+
+// Register adds a provider under name. It panics if name is already registered or has a
+// slash, like database/sql.Register, since registrations happen in init functions.
+func (r *Registry) Register(name string, registration ProviderRegistration) {
+	if name == "" || strings.Contains(name, "/") || registration.New == nil {
+		panic("llm: invalid provider registration " + name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.registrations[name]; ok {
+		panic("llm: provider " + name + " registered twice")
+	}
+	r.registrations[name] = registration
+}
+
+// Configure sets the options the provider name is created with, applied after the credentials
+// from the environment, e.g. an API key read from a config file. A provider already created
+// is created again with the new options on next use.
+func (r *Registry) Configure(name string, opts ...ModelOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.options[name] = opts
+	r.generations[name]++
+	delete(r.providers, name)
+}
+
+// Providers returns the names of the registered providers, sorted
+func (r *Registry) Providers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.registrations))
+	for name := range r.registrations {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Provider returns the provider registered under name, creating it on first use. Concurrent
+// callers wait for the same creation.
+func (r *Registry) Provider(name string) (ModelProvider, error) {
+	r.mu.Lock()
+	provider, ok := r.providers[name]
+	opts, generation := r.options[name], r.generations[name]
+	r.mu.Unlock()
+	if ok {
+		return provider, nil
+	}
+
+	created, err, _ := r.creating.Do(name+"#"+strconv.Itoa(generation), func() (any, error) {
+		provider, err := r.newProvider(name, opts)
+		if err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.generations[name] == generation {
+			r.providers[name] = provider
+		}
+		return provider, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created.(ModelProvider), nil
+}
+
+// newProvider creates a provider of registration name with its credentials from the
+// environment followed by opts. r.mu must not be held, as providers may discover their models
+// over the network while they are created.
+func (r *Registry) newProvider(name string, opts []ModelOption) (ModelProvider, error) {
+	r.mu.Lock()
+	registration, ok := r.registrations[name]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+
+	var env []ModelOption
+	for _, key := range registration.APIKeyEnv {
+		if apiKey := r.getenv(key); apiKey != "" {
+			env = append(env, WithAPIKey(apiKey))
+			break
+		}
+	}
+	if registration.BaseURLEnv != "" {
+		if baseURL := r.getenv(registration.BaseURLEnv); baseURL != "" {
+			env = append(env, WithBaseURL(baseURL))
+		}
+	}
+	provider, err := registration.New(append(env, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider %s: %w", name, err)
+	}
+	return provider, nil
+}
+
+// Open returns a completion model created with opts from a URI naming its provider and model,
+// e.g. "openai/gpt-4o-mini" or "openrouter/anthropic/claude-3.5-sonnet". Everything after the
+// first slash is the model ID.
+func (r *Registry) Open(uri string, opts ...CompletionOption) (CompletionModel, error) {
+	name, model, ok := strings.Cut(uri, "/")
+	if !ok || name == "" || model == "" {
+		return nil, NewValidationError("uri", "must be <provider>/<model>", uri)
+	}
+	provider, err := r.Provider(name)
+	if err != nil {
+		return nil, err
+	}
+	return provider.NewCompletionModel(model, opts...)
+}
+
+// Register adds a provider to DefaultRegistry, see Registry.Register
+func Register(name string, registration ProviderRegistration) {
+	DefaultRegistry.Register(name, registration)
+}
+
+// Configure sets the options of a provider of DefaultRegistry, see Registry.Configure
+func Configure(name string, opts ...ModelOption) {
+	DefaultRegistry.Configure(name, opts...)
+}
+
+// Open returns a completion model of DefaultRegistry from a URI such as "openai/gpt-4o-mini",
+// see Registry.Open. The built-in providers are registered by importing the providers package.
+func Open(uri string, opts ...CompletionOption) (CompletionModel, error) {
+	return DefaultRegistry.Open(uri, opts...)
+}
@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registryTestProvider answers every model ID with a stub model named after it, and keeps the
+// options it was created with
+type registryTestProvider struct {
+	*DefaultModelProvider
+	options *ModelOptions
+	models  []string
+}
+
+func (p *registryTestProvider) NewCompletionModel(model string, opts ...CompletionOption) (CompletionModel, error) {
+	p.models = append(p.models, model)
+	return &stubCompletionModel{output: model}, nil
+}
+
+func newRegistryTestRegistry(env map[string]string) (*Registry, *int) {
+	created := 0
+	registry := NewRegistry()
+	registry.getenv = func(name string) string { return env[name] }
+	registry.Register("test", ProviderRegistration{
+		New: func(opts ...ModelOption) (ModelProvider, error) {
+			created++
+			options := ApplyOptions(opts)
+			if options.APIKey == "" {
+				return nil, errors.New("api key is required")
+			}
+			models := []*ModelInfo{
+				{ID: "vendor/model-a", Capabilities: []ModelCapability{ModelCapabilityCompletion}},
+				{ID: "vendor/embedder", Capabilities: []ModelCapability{ModelCapabilityEmbedding}},
+			}
+			return &registryTestProvider{DefaultModelProvider: NewDefaultModelProvider("test", models), options: options}, nil
+		},
+		APIKeyEnv:  []string{"TEST_API_KEY", "TEST_TOKEN"},
+		BaseURLEnv: "TEST_BASE_URL",
+	})
+	return registry, &created
+}
+
+func TestRegistry_Open(t *testing.T) {
+	registry, created := newRegistryTestRegistry(map[string]string{
+		"TEST_TOKEN":    "env-key",
+		"TEST_BASE_URL": "https://env.example.com",
+	})
+
+	model, err := registry.Open("test/vendor/model-a")
+	require.NoError(t, err)
+	assert.Equal(t, "vendor/model-a", model.(*stubCompletionModel).output)
+
+	provider, err := registry.Provider("test")
+	require.NoError(t, err)
+	p := provider.(*registryTestProvider)
+	assert.Equal(t, "env-key", p.options.APIKey)
+	assert.Equal(t, "https://env.example.com", p.options.BaseURL)
+
+	// The provider is created once and shared by the models opened from it
+	_, err = registry.Open("test/model-b")
+	require.NoError(t, err)
+	assert.Equal(t, 1, *created)
+	assert.Equal(t, []string{"vendor/model-a", "model-b"}, p.models)
+
+	// Configured options override the environment and recreate the provider
+	registry.Configure("test", WithAPIKey("configured-key"))
+	provider, err = registry.Provider("test")
+	require.NoError(t, err)
+	assert.Equal(t, 2, *created)
+	assert.Equal(t, "configured-key", provider.(*registryTestProvider).options.APIKey)
+	assert.Equal(t, "https://env.example.com", provider.(*registryTestProvider).options.BaseURL)
+}
+
+func TestRegistry_OpenErrors(t *testing.T) {
+	registry, created := newRegistryTestRegistry(nil)
+
+	for _, uri := range []string{"test", "test/", "/model", ""} {
+		_, err := registry.Open(uri)
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr, uri)
+	}
+
+	_, err := registry.Open("unknown/model")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+
+	// Failed creations are not cached
+	_, err = registry.Open("test/model")
+	assert.ErrorContains(t, err, "api key is required")
+	registry.Configure("test", WithAPIKey("key"))
+	_, err = registry.Open("test/model")
+	require.NoError(t, err)
+	assert.Equal(t, 2, *created)
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry, _ := newRegistryTestRegistry(nil)
+	registry.Register("other", ProviderRegistration{New: func(opts ...ModelOption) (ModelProvider, error) {
+		return NewDefaultModelProvider("other", nil), nil
+	}})
+	assert.Equal(t, []string{"other", "test"}, registry.Providers())
+
+	assert.Panics(t, func() {
+		registry.Register("test", ProviderRegistration{New: func(opts ...ModelOption) (ModelProvider, error) { return nil, nil }})
+	})
+	assert.Panics(t, func() {
+		registry.Register("a/b", ProviderRegistration{New: func(opts ...ModelOption) (ModelProvider, error) { return nil, nil }})
+	})
+	assert.Panics(t, func() { registry.Register("empty", ProviderRegistration{}) })
+}
+
+func TestRegistry_SlowProvider(t *testing.T) {
+	registry, _ := newRegistryTestRegistry(map[string]string{"TEST_API_KEY": "key"})
+	release := make(chan struct{})
+	var created atomic.Int32
+	registry.Register("slow", ProviderRegistration{New: func(opts ...ModelOption) (ModelProvider, error) {
+		created.Add(1)
+		<-release
+		return NewDefaultModelProvider("slow", nil), nil
+	}})
+
+	var wg sync.WaitGroup
+	providers := make([]ModelProvider, 3)
+	for i := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			providers[i], _ = registry.Provider("slow")
+		}()
+	}
+
+	// Other providers are created while the slow one discovers its models
+	require.Eventually(t, func() bool { return created.Load() == 1 }, time.Second, time.Millisecond)
+	_, err := registry.Open("test/gpt-test")
+	require.NoError(t, err)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), created.Load())
+	assert.NotNil(t, providers[0])
+	assert.Same(t, providers[0], providers[1])
+	assert.Same(t, providers[0], providers[2])
+}
@@ -0,0 +1,308 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReplayJudge scores how well a replayed answer matches the recorded one, between 0 and 1
+type ReplayJudge func(ctx context.Context, prompt, original, replayed string) (float64, error)
+
+// ReplayOption is a functional option for configuring session replays
+type ReplayOption func(*ReplayOptions)
+
+// ReplayOptions contains configuration options for session replays
+type ReplayOptions struct {
+	// Model is the name of the replay model shown in reports
+	Model string
+	// Judge scores each replayed turn against the recorded answer
+	Judge ReplayJudge
+}
+
+// WithReplayModelName sets the replay model name shown in reports
+func WithReplayModelName(name string) ReplayOption {
+	return func(o *ReplayOptions) {
+		o.Model = name
+	}
+}
+
+// WithReplayJudge sets the judge used to score replayed turns
+func WithReplayJudge(judge ReplayJudge) ReplayOption {
+	return func(o *ReplayOptions) {
+		o.Judge = judge
+	}
+}
+
+// ApplyReplayOptions applies all options to create a ReplayOptions struct
+func ApplyReplayOptions(opts []ReplayOption) *ReplayOptions {
+	options := &ReplayOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// ReplayTurn compares one recorded assistant answer with the replay model's answer
+type ReplayTurn struct {
+	// Index is the position of the recorded assistant message in the transcript
+	Index    int         `json:"index"`
+	Prompt   string      `json:"prompt"`
+	Original string      `json:"original"`
+	Replayed string      `json:"replayed"`
+	Diff     []DiffLine  `json:"diff"`
+	Score    *float64    `json:"score,omitempty"`
+	Usage    *TokenUsage `json:"usage,omitempty"`
+	Cost     *float64    `json:"cost,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// ReplayReport is the side-by-side result of replaying a recorded session against another model
+type ReplayReport struct {
+	OriginalModel string        `json:"originalModel"`
+	ReplayModel   string        `json:"replayModel"`
+	Turns         []*ReplayTurn `json:"turns"`
+	OriginalCost  *float64      `json:"originalCost,omitempty"`
+	ReplayCost    *float64      `json:"replayCost,omitempty"`
+	ReplayUsage   *TokenUsage   `json:"replayUsage,omitempty"`
+	// ReplayCostBreakdown splits ReplayCost by what was billed
+	ReplayCostBreakdown *CostBreakdown `json:"replayCostBreakdown,omitempty"`
+}
+
+// CostDelta returns the replay cost minus the recorded cost, or nil if either is unknown
+func (r *ReplayReport) CostDelta() *float64 {
+	if r.OriginalCost == nil || r.ReplayCost == nil {
+		return nil
+	}
+	delta := (MicroCentsFromUSD(*r.ReplayCost) - MicroCentsFromUSD(*r.OriginalCost)).USD()
+	return &delta
+}
+
+// MeanScore returns the mean judge score over scored turns, or nil if no turn was scored
+func (r *ReplayReport) MeanScore() *float64 {
+	var sum float64
+	var n int
+	for _, turn := range r.Turns {
+		if turn.Score != nil {
+			sum += *turn.Score
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	mean := sum / float64(n)
+	return &mean
+}
+
+// Replay replays a recorded session turn by turn against model. For every recorded assistant
+// answer, the model receives the recorded conversation up to that point, so turns are compared
+// on identical context. Tool call turns are kept as context but not replayed. Errors of
+// individual turns are recorded on the turn; Replay only fails if ctx is canceled.
+func Replay(ctx context.Context, transcript *Transcript, model CompletionModel, opts ...ReplayOption) (*ReplayReport, error) {
+	if transcript == nil {
+		return nil, NewValidationError("transcript", "cannot be nil", nil)
+	}
+	if model == nil {
+		return nil, NewValidationError("model", "cannot be nil", nil)
+	}
+	o := ApplyReplayOptions(opts)
+
+	report := &ReplayReport{
+		OriginalModel: transcript.Model,
+		ReplayModel:   o.Model,
+		OriginalCost:  transcript.Cost,
+	}
+
+	var total usageTotal
+	for i, msg := range transcript.Messages {
+		if msg == nil || msg.Role != RoleAssistant || msg.ToolCall != nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		turn := &ReplayTurn{
+			Index:    i,
+			Prompt:   lastUserText(transcript.Messages[:i]),
+			Original: msg.Text(),
+		}
+		report.Turns = append(report.Turns, turn)
+
+		resp, err := model.Complete(ctx, &CompletionRequest{
+			Instructions: transcript.Instructions,
+			Messages:     transcript.Messages[:i],
+		})
+		if err != nil {
+			turn.Error = err.Error()
+			continue
+		}
+		turn.Replayed = resp.Output
+		turn.Usage = resp.Usage
+		turn.Cost = resp.Cost
+		turn.Diff = DiffLines(turn.Original, turn.Replayed)
+		total.addResponse(resp)
+		report.ReplayUsage, report.ReplayCost, report.ReplayCostBreakdown = total.usage, total.cost, total.breakdown
+
+		if o.Judge != nil {
+			score, err := o.Judge(ctx, turn.Prompt, turn.Original, turn.Replayed)
+			if err != nil {
+				turn.Error = fmt.Sprintf("judge: %v", err)
+				continue
+			}
+			turn.Score = &score
+		}
+	}
+
+	return report, nil
+}
+
+func lastUserText(messages []*ModelMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i] != nil && messages[i].Role == RoleUser {
+			return messages[i].Text()
+		}
+	}
+	return ""
+}
+
+// DiffOp is the kind of a diff line
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = " "
+	DiffDelete DiffOp = "-"
+	DiffInsert DiffOp = "+"
+)
+
+// DiffLine is one line of a line-based text diff
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffLines computes a line-based diff turning a into b using the longest common subsequence
+func DiffLines(a, b string) []DiffLine {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := make([]DiffLine, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			diff = append(diff, DiffLine{Op: DiffEqual, Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Op: DiffDelete, Text: x[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: DiffInsert, Text: y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		diff = append(diff, DiffLine{Op: DiffDelete, Text: x[i]})
+	}
+	for ; j < len(y); j++ {
+		diff = append(diff, DiffLine{Op: DiffInsert, Text: y[j]})
+	}
+	return diff
+}
+
+// RenderReplayReport writes the replay report as Markdown, with a summary table of scores and
+// costs followed by the recorded and replayed answers and their diff for every turn
+func RenderReplayReport(w io.Writer, report *ReplayReport) error {
+	if report == nil {
+		return NewValidationError("report", "cannot be nil", nil)
+	}
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# Replay: %s → %s\n\n", orDash(report.OriginalModel), orDash(report.ReplayModel))
+	fmt.Fprintf(bw, "- **Turns:** %d\n", len(report.Turns))
+	if mean := report.MeanScore(); mean != nil {
+		fmt.Fprintf(bw, "- **Mean score:** %.2f\n", *mean)
+	}
+	if report.OriginalCost != nil {
+		fmt.Fprintf(bw, "- **Recorded cost:** %s\n", MicroCentsFromUSD(*report.OriginalCost))
+	}
+	if report.ReplayCost != nil {
+		fmt.Fprintf(bw, "- **Replay cost:** %s\n", MicroCentsFromUSD(*report.ReplayCost))
+	}
+	if delta := report.CostDelta(); delta != nil {
+		fmt.Fprintf(bw, "- **Cost delta:** %+.6f\n", *delta)
+	}
+
+	bw.WriteString("\n| Turn | Score | Replay cost | Changed lines |\n|---:|---:|---:|---:|\n")
+	for _, turn := range report.Turns {
+		score, cost := "-", "-"
+		if turn.Score != nil {
+			score = fmt.Sprintf("%.2f", *turn.Score)
+		}
+		if turn.Cost != nil {
+			cost = MicroCentsFromUSD(*turn.Cost).String()
+		}
+		changed := 0
+		for _, line := range turn.Diff {
+			if line.Op != DiffEqual {
+				changed++
+			}
+		}
+		fmt.Fprintf(bw, "| %d | %s | %s | %d |\n", turn.Index, score, cost, changed)
+	}
+
+	for _, turn := range report.Turns {
+		fmt.Fprintf(bw, "\n## Turn %d\n\n", turn.Index)
+		if turn.Prompt != "" {
+			writeMarkdownQuote(bw, turn.Prompt)
+			bw.WriteString("\n")
+		}
+		if turn.Error != "" {
+			fmt.Fprintf(bw, "**Error:** %s\n\n", turn.Error)
+		}
+		bw.WriteString("<table><tr><th>Recorded</th><th>Replayed</th></tr><tr><td>\n\n")
+		bw.WriteString(turn.Original)
+		bw.WriteString("\n\n</td><td>\n\n")
+		bw.WriteString(turn.Replayed)
+		bw.WriteString("\n\n</td></tr></table>\n\n")
+		if len(turn.Diff) > 0 {
+			bw.WriteString("```diff\n")
+			for _, line := range turn.Diff {
+				bw.WriteString(string(line.Op))
+				bw.WriteString(line.Text)
+				bw.WriteString("\n")
+			}
+			bw.WriteString("```\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLines(t *testing.T) {
+	diff := DiffLines("a\nb\nc", "a\nx\nc\nd")
+
+	assert.Equal(t, []DiffLine{
+		{Op: DiffEqual, Text: "a"},
+		{Op: DiffDelete, Text: "b"},
+		{Op: DiffInsert, Text: "x"},
+		{Op: DiffEqual, Text: "c"},
+		{Op: DiffInsert, Text: "d"},
+	}, diff)
+}
+
+func TestReplay(t *testing.T) {
+	originalCost := 0.003
+	transcript := &Transcript{
+		Model:        "gpt-4o",
+		Instructions: "Be brief.",
+		Cost:         &originalCost,
+		Messages: []*ModelMessage{
+			{Role: RoleUser, Content: "Capital of France?"},
+			{Role: RoleAssistant, Content: "Paris."},
+			{Role: RoleUser, Content: "Weather there?"},
+			{Role: RoleAssistant, ToolCall: &ToolCall{Name: "weather"}},
+			{Role: RoleTool, ToolCall: &ToolCall{Name: "weather", Output: "sunny"}},
+			{Role: RoleAssistant, Content: "It is sunny."},
+		},
+	}
+	model := &scriptedModel{outputs: []string{"Paris.", "Sunny today."}}
+	judge := func(ctx context.Context, prompt, original, replayed string) (float64, error) {
+		if original == replayed {
+			return 1, nil
+		}
+		return 0.5, nil
+	}
+
+	report, err := Replay(context.Background(), transcript, model, WithReplayModelName("claude-sonnet-4-5"), WithReplayJudge(judge))
+	require.NoError(t, err)
+
+	require.Len(t, report.Turns, 2)
+	assert.Equal(t, 1, report.Turns[0].Index)
+	assert.Equal(t, "Capital of France?", report.Turns[0].Prompt)
+	assert.Equal(t, 5, report.Turns[1].Index)
+	assert.Equal(t, "Sunny today.", report.Turns[1].Replayed)
+	assert.Equal(t, 0.5, *report.Turns[1].Score)
+
+	// The replay model sees the recorded context up to each turn
+	require.Len(t, model.requests, 2)
+	assert.Len(t, model.requests[0].Messages, 1)
+	assert.Len(t, model.requests[1].Messages, 5)
+	assert.Equal(t, "Be brief.", model.requests[1].Instructions)
+
+	assert.InDelta(t, 0.75, *report.MeanScore(), 1e-9)
+	assert.InDelta(t, -0.001, *report.CostDelta(), 1e-12)
+
+	var buf bytes.Buffer
+	require.NoError(t, RenderReplayReport(&buf, report))
+	out := buf.String()
+	assert.Contains(t, out, "# Replay: gpt-4o → claude-sonnet-4-5")
+	assert.Contains(t, out, "- **Mean score:** 0.75")
+	assert.Contains(t, out, "-It is sunny.\n+Sunny today.\n")
+}
@@ -0,0 +1,38 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+)
+
+// RerankModel defines the interface for reranking operations, which order documents by their
+// relevance to a query, e.g. to pick the best passages retrieved for a RAG prompt
+type RerankModel interface {
+	// Rerank scores req.Documents against req.Query and returns them most relevant first
+	Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error)
+}
+
+type RerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	// TopN limits the results to the most relevant documents; 0 returns all of them
+	TopN int `json:"topN,omitempty"`
+}
+
+type RerankResult struct {
+	// Index is the position of the document in the request
+	Index    int    `json:"index"`
+	Document string `json:"document"`
+	// RelevanceScore is the relevance of the document to the query, higher is more relevant
+	RelevanceScore float64 `json:"relevanceScore"`
+}
+
+type RerankResponse struct {
+	// Results are ordered by descending relevance
+	Results []RerankResult `json:"results"`
+	Usage   *TokenUsage    `json:"usage,omitempty"`
+	Cost    *float64       `json:"cost,omitempty"`
+}
@@ -0,0 +1,613 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheCapacity is the number of responses a MemoryCacheStore keeps by default
+const DefaultCacheCapacity = 1000
+
+// CacheHit is how a response was served from a response cache
+type CacheHit string
+
+const (
+	// CacheHitExact is a response cached for the same request
+	CacheHitExact CacheHit = "exact"
+	// CacheHitSemantic is a response cached for a similar prompt, see SemanticCache
+	CacheHitSemantic CacheHit = "semantic"
+)
+
+// CacheStore stores completion responses by the canonical hash of their request, see CacheKey
+type CacheStore interface {
+	// Get returns the response cached under key, or false when there is none or it expired
+	Get(ctx context.Context, key string) (*CompletionResponse, bool, error)
+	// Set caches resp under key for ttl; a zero ttl keeps it until it is evicted
+	Set(ctx context.Context, key string, resp *CompletionResponse, ttl time.Duration) error
+}
+
+// CacheKey returns the canonical hash of a completion of model with req and options. Requests
+// with the same model, instructions, messages and options that change the response have the
+// same key; options such as hooks, prompt cache keys or predicted outputs are ignored.
+func CacheKey(model string, req *CompletionRequest, options *CompletionOptions) (string, error) {
+	data, err := json.Marshal(struct {
+		Model        string           `json:"model"`
+		Instructions string           `json:"instructions"`
+		Messages     []*ModelMessage  `json:"messages"`
+		Options      *cacheKeyOptions `json:"options"`
+	}{model, req.Instructions, req.Messages, newCacheKeyOptions(options)})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cacheKeyOptions are the completion options that change the response of a model, and so the
+// cache key of its requests. They are listed explicitly, so options added to CompletionOptions,
+// such as hooks that cannot be marshaled, neither break caching nor change keys until they
+// are added here.
+type cacheKeyOptions struct {
+	Temperature            *float64          `json:"temperature,omitempty"`
+	TopP                   *float64          `json:"top_p,omitempty"`
+	MaxTokens              *int              `json:"max_tokens,omitempty"`
+	MaxOutputTokens        *int              `json:"max_output_tokens,omitempty"`
+	PresencePenalty        *float64          `json:"presence_penalty,omitempty"`
+	FrequencyPenalty       *float64          `json:"frequency_penalty,omitempty"`
+	Seed                   *int64            `json:"seed,omitempty"`
+	ReasoningEffort        *ReasoningEffort  `json:"reasoning_effort,omitempty"`
+	Stop                   []string          `json:"stop,omitempty"`
+	ResponseFormat         *ResponseFormat   `json:"response_format,omitempty"`
+	JSONSchema             any               `json:"json_schema,omitempty"`
+	WithCost               *bool             `json:"with_cost,omitempty"`
+	WithUsage              *bool             `json:"with_usage,omitempty"`
+	ParallelToolCalls      *bool             `json:"parallel_tool_calls,omitempty"`
+	TopLogprobs            *int              `json:"top_logprobs,omitempty"`
+	N                      *int              `json:"n,omitempty"`
+	OutputLanguage         *string           `json:"output_language,omitempty"`
+	ValidateOutputLanguage *bool             `json:"validate_output_language,omitempty"`
+	Deterministic          *bool             `json:"deterministic,omitempty"`
+	WebSearch              *WebSearchOptions `json:"web_search,omitempty"`
+	StreamEvents           []StreamChunkType `json:"stream_events,omitempty"`
+	ExtendedContext        *bool             `json:"extended_context,omitempty"`
+	ServiceTier            *ServiceTier      `json:"service_tier,omitempty"`
+	SafetySettings         []SafetySetting   `json:"safety_settings,omitempty"`
+	Grounding              *GroundingOptions `json:"grounding,omitempty"`
+	CachedContent          *string           `json:"cached_content,omitempty"`
+	OutputChecks           *OutputChecks     `json:"output_checks,omitempty"`
+	Lexicons               []*Lexicon        `json:"lexicons,omitempty"`
+}
+
+func newCacheKeyOptions(o *CompletionOptions) *cacheKeyOptions {
+	if o == nil {
+		return nil
+	}
+	options := &cacheKeyOptions{
+		Temperature:            o.Temperature,
+		TopP:                   o.TopP,
+		MaxTokens:              o.MaxTokens,
+		MaxOutputTokens:        o.MaxOutputTokens,
+		PresencePenalty:        o.PresencePenalty,
+		FrequencyPenalty:       o.FrequencyPenalty,
+		Seed:                   o.Seed,
+		ReasoningEffort:        o.ReasoningEffort,
+		Stop:                   o.Stop,
+		ResponseFormat:         o.ResponseFormat,
+		JSONSchema:             o.JSONSchema,
+		WithCost:               o.WithCost,
+		WithUsage:              o.WithUsage,
+		ParallelToolCalls:      o.ParallelToolCalls,
+		TopLogprobs:            o.TopLogprobs,
+		N:                      o.N,
+		OutputLanguage:         o.OutputLanguage,
+		ValidateOutputLanguage: o.ValidateOutputLanguage,
+		Deterministic:          o.Deterministic,
+		WebSearch:              o.WebSearch,
+		StreamEvents:           o.StreamEvents,
+		ExtendedContext:        o.ExtendedContext,
+		ServiceTier:            o.ServiceTier,
+		SafetySettings:         o.SafetySettings,
+		Grounding:              o.Grounding,
+		CachedContent:          o.CachedContent,
+		OutputChecks:           o.OutputChecks,
+	}
+	if o.LexiconFilter != nil {
+		options.Lexicons = o.LexiconFilter.Lexicons
+	}
+	return options
+}
+
+// MemoryCacheStore is a CacheStore that keeps the most recently used responses in memory
+type MemoryCacheStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // entries from the most to the least recently used
+	now      func() time.Time
+}
+
+type memoryCacheEntry struct {
+	key       string
+	resp      CompletionResponse
+	expiresAt time.Time
+}
+
+var _ CacheStore = (*MemoryCacheStore)(nil)
+
+// NewMemoryCacheStore creates a MemoryCacheStore evicting the least recently used responses
+// beyond capacity, DefaultCacheCapacity when not positive
+func NewMemoryCacheStore(capacity int) *MemoryCacheStore {
+	if capacity <= 0 {
+		capacity = DefaultCacheCapacity
+	}
+	return &MemoryCacheStore{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) (*CompletionResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	if !entry.expiresAt.IsZero() && !s.now().Before(entry.expiresAt) {
+		s.order.Remove(element)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(element)
+	resp := entry.resp
+	return &resp, true, nil
+}
+
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, resp *CompletionResponse, ttl time.Duration) error {
+	entry := &memoryCacheEntry{key: key, resp: *resp}
+	if ttl > 0 {
+		entry.expiresAt = s.now().Add(ttl)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.entries[key]; ok {
+		element.Value = entry
+		s.order.MoveToFront(element)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(entry)
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of cached responses, including expired ones not yet removed
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// SemanticCache returns the responses cached for prompts similar to a new one, so rephrased
+// questions are answered without calling the model. The last user message of a request is
+// the prompt; the model, instructions, earlier messages and options must match exactly.
+type SemanticCache struct {
+	embedder  EmbeddingModel
+	model     string
+	vectors   VectorStore
+	threshold float64
+	now       func() time.Time
+}
+
+// NewSemanticCache creates a SemanticCache embedding prompts with model of embedder and
+// keeping them in store, a MemoryVectorStore when nil. Cached responses are returned for
+// prompts whose cosine similarity to the new one is at least threshold, e.g. 0.95.
+func NewSemanticCache(embedder EmbeddingModel, model string, threshold float64, store VectorStore) *SemanticCache {
+	if store == nil {
+		store = NewMemoryVectorStore()
+	}
+	return &SemanticCache{
+		embedder:  embedder,
+		model:     model,
+		vectors:   store,
+		threshold: threshold,
+		now:       time.Now,
+	}
+}
+
+// semanticMatches is how many similar prompts are compared, to skip expired ones
+const semanticMatches = 4
+
+// lookup returns the response cached for the most similar prompt of scope, with the
+// embedding of prompt to store the response under on a miss
+func (c *SemanticCache) lookup(ctx context.Context, scope, prompt string) (*CompletionResponse, []float64, error) {
+	resp, err := c.embedder.GenerateEmbeddings(ctx, &EmbeddingRequest{
+		Model:    c.model,
+		Contents: []string{prompt},
+		Config:   &EmbeddingModelConfig{TaskType: EmbeddingTaskSemanticSimilarity},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to embed: %w", err)
+	}
+	vectors, err := orderedEmbeddings(resp, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matches, err := c.vectors.Query(ctx, &VectorQuery{Vector: vectors[0], TopK: semanticMatches, Filter: VectorFilter{"scope": scope}})
+	if err != nil {
+		return nil, nil, err
+	}
+	now := c.now()
+	var expired []string
+	defer func() {
+		if len(expired) > 0 {
+			_ = c.vectors.Delete(ctx, expired, nil)
+		}
+	}()
+	for _, match := range matches {
+		if match.Score < c.threshold {
+			break
+		}
+		if expiresAt, ok := match.Record.Metadata["expires_at"].(float64); ok && expiresAt > 0 && !now.Before(time.Unix(int64(expiresAt), 0)) {
+			expired = append(expired, match.Record.ID)
+			continue
+		}
+		data, _ := match.Record.Metadata["response"].(string)
+		var cached CompletionResponse
+		if err := json.Unmarshal([]byte(data), &cached); err != nil {
+			continue
+		}
+		return &cached, vectors[0], nil
+	}
+	return nil, vectors[0], nil
+}
+
+// add caches resp for prompt of scope for ttl
+func (c *SemanticCache) add(ctx context.Context, scope, prompt string, vector []float64, resp *CompletionResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	var expiresAt float64
+	if ttl > 0 {
+		expiresAt = float64(c.now().Add(ttl).Unix())
+	}
+	sum := sha256.Sum256([]byte(scope + "\x00" + prompt))
+	return c.vectors.Upsert(ctx, []*VectorRecord{{
+		ID:       hex.EncodeToString(sum[:]),
+		Vector:   vector,
+		Content:  prompt,
+		Metadata: map[string]any{"scope": scope, "response": string(data), "expires_at": expiresAt},
+	}})
+}
+
+// semanticPrompt splits req into the text of its last user message and the request before it
+func semanticPrompt(req *CompletionRequest) (string, *CompletionRequest, bool) {
+	if len(req.Messages) == 0 {
+		return "", nil, false
+	}
+	last := req.Messages[len(req.Messages)-1]
+	if last == nil || last.Role != RoleUser || last.ToolCall != nil {
+		return "", nil, false
+	}
+	var text strings.Builder
+	for _, part := range last.ContentParts() {
+		if part.Type != ContentPartText {
+			// Media is not compared by the embeddings of the text
+			return "", nil, false
+		}
+		text.WriteString(part.Text)
+	}
+	if text.Len() == 0 {
+		return "", nil, false
+	}
+	return text.String(), &CompletionRequest{Instructions: req.Instructions, Messages: req.Messages[:len(req.Messages)-1]}, true
+}
+
+// CacheOption is a functional option for configuring response caching
+type CacheOption func(*CacheOptions)
+
+// CacheOptions contains configuration options for response caching
+type CacheOptions struct {
+	// TTL is how long responses are cached; zero keeps them until they are evicted
+	TTL time.Duration
+	// Semantic returns responses cached for similar prompts when the exact request missed
+	Semantic *SemanticCache
+}
+
+// WithCacheTTL sets how long responses are cached
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(o *CacheOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithSemanticCache returns the responses cached for similar prompts by cache when no response
+// is cached for the exact request
+func WithSemanticCache(cache *SemanticCache) CacheOption {
+	return func(o *CacheOptions) {
+		o.Semantic = cache
+	}
+}
+
+// ApplyCacheOptions applies all options to create a CacheOptions struct
+func ApplyCacheOptions(opts []CacheOption) *CacheOptions {
+	options := &CacheOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// CachingCompletionModel is a middleware that returns cached responses for repeated requests
+// and caches the responses of the model. Responses served from the cache have a zero cost and
+// their Metadata.Cache set. Cache failures are not returned; the model is called instead.
+type CachingCompletionModel struct {
+	name              string
+	model             CompletionModel
+	store             CacheStore
+	options           *CacheOptions
+	completionOptions []CompletionOption
+}
+
+var _ CompletionModel = (*CachingCompletionModel)(nil)
+
+// NewCachingCompletionModel wraps model, named name in cache keys, with response caching in
+// store. A nil store only uses the semantic cache set with WithSemanticCache.
+func NewCachingCompletionModel(name string, model CompletionModel, store CacheStore, opts ...CacheOption) *CachingCompletionModel {
+	return &CachingCompletionModel{
+		name:    name,
+		model:   model,
+		store:   store,
+		options: ApplyCacheOptions(opts),
+	}
+}
+
+// SetCompletionOptions sets the options model was created with, which are part of cache keys
+// along with the option overrides of request contexts
+func (m *CachingCompletionModel) SetCompletionOptions(opts []CompletionOption) {
+	m.completionOptions = opts
+}
+
+// cacheLookup is a request's cache keys and the response cached for them
+type cacheLookup struct {
+	options *CompletionOptions
+	key     string
+	scope   string
+	prompt  string
+	vector  []float64
+	resp    *CompletionResponse
+}
+
+// lookup returns the cached response for req, or the keys to cache its response under
+func (m *CachingCompletionModel) lookup(ctx context.Context, req *CompletionRequest) *cacheLookup {
+	options := ApplyContextCompletionOptions(ctx, m.completionOptions)
+	l := &cacheLookup{options: options}
+	if m.store != nil {
+		if key, err := CacheKey(m.name, req, options); err == nil {
+			l.key = key
+			if resp, ok, err := m.store.Get(ctx, key); err == nil && ok {
+				l.resp = cachedResponse(resp, CacheHitExact)
+				return l
+			}
+		}
+	}
+
+	semantic := m.options.Semantic
+	if semantic == nil {
+		return l
+	}
+	prompt, prefix, ok := semanticPrompt(req)
+	if !ok {
+		return l
+	}
+	scope, err := CacheKey(m.name, prefix, options)
+	if err != nil {
+		return l
+	}
+	resp, vector, err := semantic.lookup(ctx, scope, prompt)
+	if err != nil {
+		return l
+	}
+	l.scope, l.prompt, l.vector = scope, prompt, vector
+	if resp != nil {
+		l.resp = cachedResponse(resp, CacheHitSemantic)
+	}
+	return l
+}
+
+// save caches resp under the keys of l
+func (m *CachingCompletionModel) save(ctx context.Context, l *cacheLookup, resp *CompletionResponse) {
+	if l.key != "" {
+		_ = m.store.Set(ctx, l.key, resp, m.options.TTL)
+	}
+	if l.vector != nil {
+		_ = m.options.Semantic.add(ctx, l.scope, l.prompt, l.vector, resp, m.options.TTL)
+	}
+}
+
+// cachedResponse copies a cached response, marked as served from the cache at no cost
+func cachedResponse(resp *CompletionResponse, hit CacheHit) *CompletionResponse {
+	cached := *resp
+	metadata := ResponseMetadata{}
+	if resp.Metadata != nil {
+		metadata = *resp.Metadata
+	}
+	metadata.Cache = hit
+	cached.Metadata = &metadata
+	cost := 0.0
+	cached.Cost = &cost
+	return &cached
+}
+
+func (m *CachingCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	l := m.lookup(ctx, req)
+	if l.resp != nil {
+		l.resp.Stream = nil
+		return l.resp, nil
+	}
+	resp, err := m.model.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	m.save(ctx, l, resp)
+	return resp, nil
+}
+
+func (m *CachingCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	l := m.lookup(ctx, req)
+	if l.resp != nil {
+		chunks := cachedChunks(l.resp, l.options)
+		out := make(chan StreamChunk, len(chunks))
+		for _, chunk := range chunks {
+			out <- chunk
+		}
+		close(out)
+		return out, nil
+	}
+
+	stream, err := m.model.StreamComplete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer close(out)
+
+		var text, reasoning strings.Builder
+		var chunks []StreamChunk
+		resp := &CompletionResponse{}
+		failed := false
+		for chunk := range stream {
+			switch c := chunk.(type) {
+			case StreamTextChunk:
+				text.WriteString(c.Text)
+				chunks = appendTextChunk(chunks, c.Text)
+			case *StreamTextBytesChunk:
+				text.Write(c.Text)
+				chunks = appendTextChunk(chunks, string(c.Text))
+			case StreamReasoningChunk:
+				reasoning.WriteString(c.Reasoning)
+				chunks = append(chunks, c)
+			case StreamWebSearchChunk:
+				resp.WebSearches = append(resp.WebSearches, c.Search)
+				chunks = append(chunks, c)
+			case StreamUsageChunk:
+				resp.Usage = c.Usage
+				resp.Cost = c.Cost
+				chunks = append(chunks, c)
+			case StreamFinishChunk:
+				c.apply(resp)
+				chunks = append(chunks, c)
+			case StreamLogprobChunk:
+				resp.Logprobs = append(resp.Logprobs, c.Logprobs...)
+				chunks = append(chunks, c)
+			case StreamErrorChunk:
+				failed = true
+			default:
+				chunks = append(chunks, c)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if failed || ctx.Err() != nil {
+			return
+		}
+		resp.Output = text.String()
+		resp.Reasoning = reasoning.String()
+		resp.Stream = streamEvents(chunks)
+		m.save(ctx, l, resp)
+	}()
+	return out, nil
+}
+
+// appendTextChunk appends text to chunks, merged with a text chunk that ends chunks, so cached
+// streams keep one chunk per run of text
+func appendTextChunk(chunks []StreamChunk, text string) []StreamChunk {
+	if n := len(chunks); n > 0 {
+		if last, ok := chunks[n-1].(StreamTextChunk); ok {
+			chunks[n-1] = StreamTextChunk{Text: last.Text + text}
+			return chunks
+		}
+	}
+	return append(chunks, StreamTextChunk{Text: text})
+}
+
+// streamEvents encodes the chunks of a stream to cache, or returns nil if one cannot be
+// encoded, so the stream is replayed from the fields of its response
+func streamEvents(chunks []StreamChunk) []StreamEvent {
+	events := make([]StreamEvent, 0, len(chunks))
+	for i, chunk := range chunks {
+		event, err := NewStreamEvent(int64(i+1), chunk)
+		if err != nil {
+			return nil
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// cachedChunks returns the chunks a cached response is streamed as: the chunks of the stream
+// it was collected from, or for responses of Complete its reasoning, web searches, output,
+// logprobs, finish and usage. Usage chunks report the cost of the cached response.
+func cachedChunks(resp *CompletionResponse, options *CompletionOptions) []StreamChunk {
+	usage := StreamUsageChunk{Usage: resp.Usage, Cost: resp.Cost}
+	if len(resp.Stream) > 0 {
+		chunks := make([]StreamChunk, 0, len(resp.Stream))
+		for _, event := range resp.Stream {
+			chunk, err := event.Chunk()
+			if err != nil {
+				chunks = nil
+				break
+			}
+			if _, ok := chunk.(StreamUsageChunk); ok {
+				chunk = usage
+			}
+			chunks = append(chunks, chunk)
+		}
+		if chunks != nil {
+			return chunks
+		}
+	}
+
+	var chunks []StreamChunk
+	if resp.Reasoning != "" && options.StreamsEvent(ReasoningChunkType) {
+		chunks = append(chunks, StreamReasoningChunk{Reasoning: resp.Reasoning})
+	}
+	if options.StreamsEvent(WebSearchChunkType) {
+		for _, search := range resp.WebSearches {
+			chunks = append(chunks, StreamWebSearchChunk{Search: search})
+		}
+	}
+	chunks = append(chunks, StreamTextChunk{Text: resp.Output})
+	if len(resp.Logprobs) > 0 && options.StreamsEvent(LogprobChunkType) {
+		chunks = append(chunks, StreamLogprobChunk{Logprobs: resp.Logprobs})
+	}
+	if resp.FinishReason != "" || resp.Refusal != "" || resp.ID != "" {
+		chunks = append(chunks, StreamFinishChunk{Reason: resp.FinishReason, Refusal: resp.Refusal, ID: resp.ID})
+	}
+	if resp.Usage != nil {
+		chunks = append(chunks, usage)
+	}
+	return chunks
+}
@@ -0,0 +1,272 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingModel answers every request with the number of calls made so far
+type countingModel struct {
+	calls atomic.Int32
+}
+
+func (m *countingModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	n := m.calls.Add(1)
+	cost := 0.01
+	return &CompletionResponse{Output: fmt.Sprintf("answer %d", n), Usage: &TokenUsage{TotalOutputTokens: 2}, Cost: &cost, FinishReason: FinishReasonStop}, nil
+}
+
+func (m *countingModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	resp, _ := m.Complete(ctx, req)
+	ch := make(chan StreamChunk, 4)
+	ch <- StreamTextChunk{Text: resp.Output[:3]}
+	ch <- StreamTextChunk{Text: resp.Output[3:]}
+	ch <- StreamFinishChunk{Reason: resp.FinishReason}
+	ch <- StreamUsageChunk{Usage: resp.Usage, Cost: resp.Cost}
+	close(ch)
+	return ch, nil
+}
+
+func userRequest(texts ...string) *CompletionRequest {
+	req := &CompletionRequest{Instructions: "be brief"}
+	for _, text := range texts {
+		req.Messages = append(req.Messages, &ModelMessage{Role: RoleUser, Content: text})
+	}
+	return req
+}
+
+func TestCacheKey(t *testing.T) {
+	options := ApplyCompletionOptions([]CompletionOption{WithTemperature(0)})
+	key, err := CacheKey("openai/gpt-4o", userRequest("hi"), options)
+	require.NoError(t, err)
+	assert.Len(t, key, 64)
+
+	same, err := CacheKey("openai/gpt-4o", userRequest("hi"), ApplyCompletionOptions([]CompletionOption{WithTemperature(0)}))
+	require.NoError(t, err)
+	assert.Equal(t, key, same)
+
+	// Options that do not change the response are not part of the key
+	same, err = CacheKey("openai/gpt-4o", userRequest("hi"), ApplyCompletionOptions([]CompletionOption{WithTemperature(0), WithPromptCacheKey("tenant-1"), WithPrediction("hi")}))
+	require.NoError(t, err)
+	assert.Equal(t, key, same)
+
+	for _, other := range []struct {
+		model   string
+		req     *CompletionRequest
+		options *CompletionOptions
+	}{
+		{"openai/gpt-4o-mini", userRequest("hi"), options},
+		{"openai/gpt-4o", userRequest("hello"), options},
+		{"openai/gpt-4o", userRequest("hi"), ApplyCompletionOptions([]CompletionOption{WithTemperature(1)})},
+	} {
+		otherKey, err := CacheKey(other.model, other.req, other.options)
+		require.NoError(t, err)
+		assert.NotEqual(t, key, otherKey)
+	}
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryCacheStore(2)
+	store.now = func() time.Time { return now }
+
+	require.NoError(t, store.Set(ctx, "a", &CompletionResponse{Output: "A"}, 0))
+	require.NoError(t, store.Set(ctx, "b", &CompletionResponse{Output: "B"}, time.Minute))
+	resp, ok, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "A", resp.Output)
+
+	// b is the least recently used
+	require.NoError(t, store.Set(ctx, "c", &CompletionResponse{Output: "C"}, 0))
+	assert.Equal(t, 2, store.Len())
+	_, ok, _ = store.Get(ctx, "b")
+	assert.False(t, ok)
+
+	require.NoError(t, store.Set(ctx, "d", &CompletionResponse{Output: "D"}, time.Minute))
+	now = now.Add(time.Minute)
+	_, ok, _ = store.Get(ctx, "d")
+	assert.False(t, ok, "expired")
+	_, ok, _ = store.Get(ctx, "c")
+	assert.True(t, ok, "never expires")
+}
+
+func TestCachingCompletionModel_Exact(t *testing.T) {
+	ctx := context.Background()
+	model := &countingModel{}
+	caching := NewCachingCompletionModel("openai/gpt-4o", model, NewMemoryCacheStore(10), WithCacheTTL(time.Hour))
+
+	first, err := caching.Complete(ctx, userRequest("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer 1", first.Output)
+	assert.Nil(t, first.Metadata)
+
+	second, err := caching.Complete(ctx, userRequest("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer 1", second.Output)
+	assert.Equal(t, CacheHitExact, second.Metadata.Cache)
+	assert.Zero(t, *second.Cost)
+	assert.Equal(t, 0.01, *first.Cost, "the cached response is not modified")
+
+	_, err = caching.Complete(ContextWithOptions(ctx, WithMaxTokens(10)), userRequest("hi"))
+	require.NoError(t, err)
+	_, err = caching.Complete(ctx, userRequest("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), model.calls.Load(), "other options and messages miss")
+}
+
+func TestCachingCompletionModel_Stream(t *testing.T) {
+	ctx := context.Background()
+	model := &countingModel{}
+	caching := NewCachingCompletionModel("openai/gpt-4o", model, NewMemoryCacheStore(10))
+
+	collect := func() (string, []StreamChunk) {
+		stream, err := caching.StreamComplete(ctx, userRequest("hi"))
+		require.NoError(t, err)
+		var text string
+		var chunks []StreamChunk
+		for chunk := range stream {
+			if c, ok := chunk.(StreamTextChunk); ok {
+				text += c.Text
+			}
+			chunks = append(chunks, chunk)
+		}
+		return text, chunks
+	}
+
+	text, chunks := collect()
+	assert.Equal(t, "answer 1", text)
+	assert.Len(t, chunks, 4)
+
+	text, chunks = collect()
+	assert.Equal(t, "answer 1", text)
+	require.Len(t, chunks, 3)
+	assert.Equal(t, StreamFinishChunk{Reason: FinishReasonStop}, chunks[1])
+	assert.Zero(t, *chunks[2].(StreamUsageChunk).Cost)
+	assert.Equal(t, int32(1), model.calls.Load())
+
+	resp, err := caching.Complete(ctx, userRequest("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer 1", resp.Output, "streams and completions share the cache")
+}
+
+// richStreamModel streams reasoning, a web search, a citation and logprobs around its text
+type richStreamModel struct {
+	countingModel
+}
+
+func (m *richStreamModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	m.calls.Add(1)
+	cost := 0.01
+	ch := make(chan StreamChunk, 8)
+	ch <- StreamReasoningChunk{Reasoning: "thinking"}
+	ch <- StreamWebSearchChunk{Search: &WebSearch{Query: "weather"}}
+	ch <- StreamTextChunk{Text: "sunny "}
+	ch <- StreamTextChunk{Text: "today"}
+	ch <- StreamCitationChunk{Index: 1, URL: "https://example.com", Offset: 11}
+	ch <- StreamLogprobChunk{Logprobs: []TokenLogprob{{Token: "today", Logprob: -0.5}}}
+	ch <- StreamFinishChunk{Reason: FinishReasonStop}
+	ch <- StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 2}, Cost: &cost}
+	close(ch)
+	return ch, nil
+}
+
+func TestCachingCompletionModel_StreamReplay(t *testing.T) {
+	ctx := context.Background()
+	model := &richStreamModel{}
+	caching := NewCachingCompletionModel("openai/gpt-4o", model, NewMemoryCacheStore(10))
+
+	stream, err := caching.StreamComplete(ctx, userRequest("hi"))
+	require.NoError(t, err)
+	for range stream {
+	}
+
+	stream, err = caching.StreamComplete(ctx, userRequest("hi"))
+	require.NoError(t, err)
+	var chunks []StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, int32(1), model.calls.Load())
+	require.Len(t, chunks, 7)
+	assert.Equal(t, StreamReasoningChunk{Reasoning: "thinking"}, chunks[0])
+	assert.Equal(t, "weather", chunks[1].(StreamWebSearchChunk).Search.Query)
+	assert.Equal(t, StreamTextChunk{Text: "sunny today"}, chunks[2])
+	assert.Equal(t, StreamCitationChunk{Index: 1, URL: "https://example.com", Offset: 11}, chunks[3])
+	assert.IsType(t, StreamLogprobChunk{}, chunks[4])
+	assert.Equal(t, StreamFinishChunk{Reason: FinishReasonStop}, chunks[5])
+	assert.Zero(t, *chunks[6].(StreamUsageChunk).Cost)
+
+	resp, err := caching.Complete(ctx, userRequest("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "sunny today", resp.Output)
+	assert.Equal(t, "thinking", resp.Reasoning)
+	assert.Len(t, resp.WebSearches, 1)
+	assert.Empty(t, resp.Stream)
+}
+
+func TestCachingCompletionModel_Semantic(t *testing.T) {
+	ctx := context.Background()
+	embedder := &vectorEmbeddingModel{vectors: map[string][]float64{
+		"What is the capital of France?":   {1, 0.1, 0},
+		"what's the capital of France":     {1, 0.12, 0},
+		"What is the capital of Germany?":  {0.2, 1, 0},
+		"And what is its population?":      {0, 0.1, 1},
+		"And what is its population then?": {0, 0.11, 1},
+	}}
+	semantic := NewSemanticCache(embedder, "embed", 0.99, nil)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	semantic.now = func() time.Time { return now }
+	model := &countingModel{}
+	caching := NewCachingCompletionModel("openai/gpt-4o", model, nil, WithSemanticCache(semantic), WithCacheTTL(time.Hour))
+
+	first, err := caching.Complete(ctx, userRequest("What is the capital of France?"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer 1", first.Output)
+
+	similar, err := caching.Complete(ctx, userRequest("what's the capital of France"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer 1", similar.Output)
+	assert.Equal(t, CacheHitSemantic, similar.Metadata.Cache)
+
+	other, err := caching.Complete(ctx, userRequest("What is the capital of Germany?"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer 2", other.Output)
+
+	// The earlier messages must match exactly
+	_, err = caching.Complete(ctx, userRequest("What is the capital of France?", "And what is its population?"))
+	require.NoError(t, err)
+	followUp, err := caching.Complete(ctx, userRequest("What is the capital of Germany?", "And what is its population then?"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer 4", followUp.Output)
+
+	now = now.Add(time.Hour)
+	expired, err := caching.Complete(ctx, userRequest("what's the capital of France"))
+	require.NoError(t, err)
+	assert.Equal(t, "answer 5", expired.Output)
+	assert.Equal(t, int32(5), model.calls.Load())
+}
+
+func TestDefaultModelProvider_Cache(t *testing.T) {
+	info := &ModelInfo{ID: "gpt-4o"}
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{info})
+	model := &countingModel{}
+	assert.IsNotType(t, &CachingCompletionModel{}, provider.DecorateCompletionModel(model, info, nil))
+
+	options := ApplyOptions([]ModelOption{WithCache(NewMemoryCacheStore(10))})
+	provider.SetCache(options.Cache, options.CacheOptions)
+	cached := provider.DecorateCompletionModel(model, info, []CompletionOption{WithTemperature(0)})
+	for range 3 {
+		resp, err := cached.Complete(context.Background(), userRequest("hi"))
+		require.NoError(t, err)
+		assert.Equal(t, "answer 1", resp.Output)
+	}
+	assert.Equal(t, int32(1), model.calls.Load())
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Retriever embeds texts with an EmbeddingModel and stores them in a VectorStore, to find the
+// texts relevant to a query for a RAG prompt
+type Retriever struct {
+	embedder EmbeddingModel
+	model    string
+	store    VectorStore
+}
+
+// NewRetriever creates a Retriever embedding with model of embedder. Texts are embedded as
+// search documents and queries, for models that distinguish them.
+func NewRetriever(embedder EmbeddingModel, model string, store VectorStore) *Retriever {
+	return &Retriever{embedder: embedder, model: model, store: store}
+}
+
+// Add embeds the Content of records without a Vector and upserts them into the store
+func (r *Retriever) Add(ctx context.Context, records ...*VectorRecord) error {
+	if err := validateRetrieverRecords(records); err != nil {
+		return err
+	}
+	var contents []string
+	var pending []*VectorRecord
+	for _, record := range records {
+		if len(record.Vector) == 0 {
+			contents = append(contents, record.Content)
+			pending = append(pending, record)
+		}
+	}
+	if len(pending) > 0 {
+		vectors, err := r.embed(ctx, contents, EmbeddingTaskSearchDocument)
+		if err != nil {
+			return err
+		}
+		for i, record := range pending {
+			record.Vector = vectors[i]
+		}
+	}
+	return r.store.Upsert(ctx, records)
+}
+
+// Retrieve returns the topK records most relevant to query that match filter
+func (r *Retriever) Retrieve(ctx context.Context, query string, topK int, filter VectorFilter) ([]*VectorMatch, error) {
+	if query == "" {
+		return nil, NewValidationError("query", "cannot be empty", nil)
+	}
+	vectors, err := r.embed(ctx, []string{query}, EmbeddingTaskSearchQuery)
+	if err != nil {
+		return nil, err
+	}
+	return r.store.Query(ctx, &VectorQuery{Vector: vectors[0], TopK: topK, Filter: filter})
+}
+
+// Delete removes records from the store, see VectorStore.Delete
+func (r *Retriever) Delete(ctx context.Context, ids []string, filter VectorFilter) error {
+	return r.store.Delete(ctx, ids, filter)
+}
+
+func (r *Retriever) embed(ctx context.Context, contents []string, task EmbeddingTaskType) ([][]float64, error) {
+	resp, err := r.embedder.GenerateEmbeddings(ctx, &EmbeddingRequest{
+		Model:    r.model,
+		Contents: contents,
+		Config:   &EmbeddingModelConfig{TaskType: task},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed: %w", err)
+	}
+	return orderedEmbeddings(resp, len(contents))
+}
+
+func validateRetrieverRecords(records []*VectorRecord) error {
+	for i, record := range records {
+		if record == nil {
+			return NewValidationError("records", "cannot contain nil", i)
+		}
+		if record.ID == "" {
+			return NewValidationError("id", "cannot be empty", i)
+		}
+		if len(record.Vector) == 0 && record.Content == "" {
+			return NewValidationError("content", "cannot be empty", record.ID)
+		}
+	}
+	return nil
+}
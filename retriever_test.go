@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// taskEmbeddingModel embeds with fixed vectors and records the task types of the requests
+type taskEmbeddingModel struct {
+	vectorEmbeddingModel
+	tasks []EmbeddingTaskType
+}
+
+func (m *taskEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	m.tasks = append(m.tasks, req.Config.TaskType)
+	return m.vectorEmbeddingModel.GenerateEmbeddings(ctx, req)
+}
+
+func TestRetriever(t *testing.T) {
+	ctx := context.Background()
+	embedder := &taskEmbeddingModel{vectorEmbeddingModel: vectorEmbeddingModel{vectors: map[string][]float64{
+		"Cats purr.":        {1, 0.1},
+		"Trucks haul cargo": {0.1, 1},
+		"what do cats do?":  {1, 0},
+	}}}
+	retriever := NewRetriever(embedder, "embed", NewMemoryVectorStore())
+
+	require.NoError(t, retriever.Add(ctx,
+		&VectorRecord{ID: "1", Content: "Cats purr.", Metadata: map[string]any{"topic": "pets"}},
+		&VectorRecord{ID: "2", Content: "Trucks haul cargo"},
+		&VectorRecord{ID: "3", Content: "precomputed", Vector: []float64{0.9, 0.1}},
+	))
+	assert.Equal(t, []EmbeddingTaskType{EmbeddingTaskSearchDocument}, embedder.tasks)
+
+	matches, err := retriever.Retrieve(ctx, "what do cats do?", 2, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "3"}, matchIDs(matches))
+	assert.Equal(t, EmbeddingTaskSearchQuery, embedder.tasks[1])
+
+	matches, err = retriever.Retrieve(ctx, "what do cats do?", 5, VectorFilter{"topic": "pets"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, matchIDs(matches))
+
+	require.NoError(t, retriever.Delete(ctx, []string{"1"}, nil))
+	matches, err = retriever.Retrieve(ctx, "what do cats do?", 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3"}, matchIDs(matches))
+}
+
+func TestRetrieverValidation(t *testing.T) {
+	retriever := NewRetriever(&vectorEmbeddingModel{}, "embed", NewMemoryVectorStore())
+	var validationErr *ValidationError
+	assert.ErrorAs(t, retriever.Add(context.Background(), &VectorRecord{ID: "1"}), &validationErr)
+	_, err := retriever.Retrieve(context.Background(), "", 1, nil)
+	assert.ErrorAs(t, err, &validationErr)
+
+	// Missing embeddings are reported instead of storing empty vectors
+	err = retriever.Add(context.Background(), &VectorRecord{ID: "1", Content: "unknown"})
+	assert.ErrorContains(t, err, "missing embedding")
+}
@@ -0,0 +1,405 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// Backoff returns the delay before the given retry attempt, starting at 1
+type Backoff func(attempt int) time.Duration
+
+// ExponentialBackoff doubles the delay after every attempt, starting at initial and capped at
+// maxDelay. Each delay is jittered between half and the full value to spread out retries.
+func ExponentialBackoff(initial, maxDelay time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		delay := initial
+		for i := 1; i < attempt && delay < maxDelay; i++ {
+			delay *= 2
+		}
+		delay = min(delay, maxDelay)
+		if delay <= 0 {
+			return 0
+		}
+		return delay/2 + rand.N(delay/2+1)
+	}
+}
+
+// DefaultRetryBackoff is used when WithRetry is given no backoff
+var DefaultRetryBackoff = ExponentialBackoff(500*time.Millisecond, 30*time.Second)
+
+// RetryOptions configures automatic retries of provider calls
+type RetryOptions struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// Backoff is the delay between attempts when the error carries no Retry-After
+	Backoff Backoff
+}
+
+// IsRetryableError reports whether a failed request may succeed when retried: rate limits,
+// timeouts and server errors are retryable, other client errors are not
+func IsRetryableError(err error) bool {
+	statusCode, _, ok := requestErrorStatus(err)
+	if !ok {
+		return false
+	}
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+		return false
+	}
+	return statusCode >= http.StatusInternalServerError
+}
+
+// RetryAfter returns the delay requested by the provider through the Retry-After header
+func RetryAfter(err error) (time.Duration, bool) {
+	_, retryAfter, ok := requestErrorStatus(err)
+	return retryAfter, ok && retryAfter > 0
+}
+
+// requestErrorStatus returns the status code and Retry-After delay of a failed API request
+func requestErrorStatus(err error) (int, time.Duration, bool) {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.StatusCode, reqErr.RetryAfter, true
+	}
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		var retryAfter time.Duration
+		if apiErr.Response != nil {
+			retryAfter = ParseRetryAfter(apiErr.Response.Header)
+		}
+		return apiErr.StatusCode, retryAfter, true
+	}
+	return 0, 0, false
+}
+
+// ParseRetryAfter reads the delay from the retry-after-ms or Retry-After response header.
+// Retry-After may be a number of seconds or an HTTP date.
+func ParseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// retry calls fn until it succeeds, fails with a non-retryable error or runs out of attempts,
+// and returns the number of attempts made
+func retry[T any](ctx context.Context, o *RetryOptions, fn func() (T, error)) (T, int, error) {
+	return retryWhen(ctx, o, IsRetryableError, fn)
+}
+
+// retryWhen is retry with the errors that are retried chosen by retryable
+func retryWhen[T any](ctx context.Context, o *RetryOptions, retryable func(error) bool, fn func() (T, error)) (T, int, error) {
+	backoff := o.Backoff
+	if backoff == nil {
+		backoff = DefaultRetryBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || attempt >= o.MaxAttempts || !retryable(err) {
+			return result, attempt, err
+		}
+
+		delay, ok := RetryAfter(err)
+		if !ok {
+			delay = backoff(attempt)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, attempt, err
+		}
+	}
+}
+
+// countRetries adds the failed attempts to the request count of usage
+func countRetries(usage *TokenUsage, attempts int) {
+	if usage != nil {
+		usage.TotalRequests += attempts - 1
+	}
+}
+
+// RetryCompletionModel retries completions that fail with retryable errors. Streams are
+// retried only while opening them, before any chunk is delivered.
+type RetryCompletionModel struct {
+	model   CompletionModel
+	options *RetryOptions
+}
+
+var _ CompletionModel = (*RetryCompletionModel)(nil)
+
+// NewRetryCompletionModel wraps model with automatic retries
+func NewRetryCompletionModel(model CompletionModel, options *RetryOptions) *RetryCompletionModel {
+	return &RetryCompletionModel{model: model, options: options}
+}
+
+func (m *RetryCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, attempts, err := retry(ctx, m.options, func() (*CompletionResponse, error) {
+		return m.model.Complete(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	countRetries(resp.Usage, attempts)
+	return resp, nil
+}
+
+func (m *RetryCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	stream, attempts, err := retry(ctx, m.options, func() (StreamCompletionResponse, error) {
+		return m.model.StreamComplete(ctx, req)
+	})
+	if err != nil || attempts == 1 {
+		return stream, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	go func() {
+		defer close(out)
+		for chunk := range stream {
+			if c, ok := chunk.(StreamUsageChunk); ok && c.Usage != nil {
+				usage := *c.Usage
+				countRetries(&usage, attempts)
+				c.Usage = &usage
+				chunk = c
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *RetryCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
+
+// RetryEmbeddingModel retries embedding requests that fail with retryable errors
+type RetryEmbeddingModel struct {
+	model   EmbeddingModel
+	options *RetryOptions
+}
+
+var _ EmbeddingModel = (*RetryEmbeddingModel)(nil)
+
+// NewRetryEmbeddingModel wraps model with automatic retries
+func NewRetryEmbeddingModel(model EmbeddingModel, options *RetryOptions) *RetryEmbeddingModel {
+	return &RetryEmbeddingModel{model: model, options: options}
+}
+
+func (m *RetryEmbeddingModel) GenerateEmbeddings(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	resp, attempts, err := retry(ctx, m.options, func() (*EmbeddingResponse, error) {
+		return m.model.GenerateEmbeddings(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	countRetries(resp.Usage, attempts)
+	return resp, nil
+}
+
+// RetryImageModel retries image generation requests that fail with retryable errors
+type RetryImageModel struct {
+	model   ImageModel
+	options *RetryOptions
+}
+
+var _ ImageModel = (*RetryImageModel)(nil)
+
+// NewRetryImageModel wraps model with automatic retries
+func NewRetryImageModel(model ImageModel, options *RetryOptions) *RetryImageModel {
+	return &RetryImageModel{model: model, options: options}
+}
+
+func (m *RetryImageModel) GenerateImage(ctx context.Context, req *ImageRequest) (*ImageResponse, error) {
+	resp, attempts, err := retry(ctx, m.options, func() (*ImageResponse, error) {
+		return m.model.GenerateImage(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	countRetries(resp.Usage, attempts)
+	return resp, nil
+}
+
+// RetryImageEditModel retries image generation, edit and variation requests that fail with
+// retryable errors
+type RetryImageEditModel struct {
+	*RetryImageModel
+	model ImageEditModel
+}
+
+var _ ImageEditModel = (*RetryImageEditModel)(nil)
+
+// NewRetryImageEditModel wraps model with automatic retries
+func NewRetryImageEditModel(model ImageEditModel, options *RetryOptions) *RetryImageEditModel {
+	return &RetryImageEditModel{RetryImageModel: NewRetryImageModel(model, options), model: model}
+}
+
+func (m *RetryImageEditModel) EditImage(ctx context.Context, req *ImageEditRequest) (*ImageResponse, error) {
+	resp, attempts, err := retry(ctx, m.options, func() (*ImageResponse, error) {
+		return m.model.EditImage(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	countRetries(resp.Usage, attempts)
+	return resp, nil
+}
+
+func (m *RetryImageEditModel) CreateVariation(ctx context.Context, req *ImageVariationRequest) (*ImageResponse, error) {
+	resp, attempts, err := retry(ctx, m.options, func() (*ImageResponse, error) {
+		return m.model.CreateVariation(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	countRetries(resp.Usage, attempts)
+	return resp, nil
+}
+
+// RetryImageJobModel retries image generation requests, and the start and resumption of image
+// jobs, that fail with retryable errors. Starting a job is only retried after a rate limit
+// error, the only retryable error proving that no job was created, so a job whose creation
+// timed out or failed with a server error is not billed twice. Polls of jobs are not retried.
+type RetryImageJobModel struct {
+	*RetryImageModel
+	model ImageJobModel
+}
+
+var _ ImageJobModel = (*RetryImageJobModel)(nil)
+
+// NewRetryImageJobModel wraps model with automatic retries
+func NewRetryImageJobModel(model ImageJobModel, options *RetryOptions) *RetryImageJobModel {
+	return &RetryImageJobModel{RetryImageModel: NewRetryImageModel(model, options), model: model}
+}
+
+func (m *RetryImageJobModel) StartImage(ctx context.Context, req *ImageRequest) (*ImageJob, error) {
+	job, _, err := retryWhen(ctx, m.options, IsRateLimitError, func() (*ImageJob, error) {
+		return m.model.StartImage(ctx, req)
+	})
+	return job, err
+}
+
+func (m *RetryImageJobModel) ResumeImage(ctx context.Context, id string, config *ImageModelConfig) (*ImageJob, error) {
+	job, _, err := retry(ctx, m.options, func() (*ImageJob, error) {
+		return m.model.ResumeImage(ctx, id, config)
+	})
+	return job, err
+}
+
+// RetrySpeechModel retries speech generation requests that fail with retryable errors
+type RetrySpeechModel struct {
+	model   SpeechModel
+	options *RetryOptions
+}
+
+var _ SpeechModel = (*RetrySpeechModel)(nil)
+
+// NewRetrySpeechModel wraps model with automatic retries
+func NewRetrySpeechModel(model SpeechModel, options *RetryOptions) *RetrySpeechModel {
+	return &RetrySpeechModel{model: model, options: options}
+}
+
+func (m *RetrySpeechModel) GenerateSpeech(ctx context.Context, req *SpeechRequest) (*SpeechResponse, error) {
+	resp, attempts, err := retry(ctx, m.options, func() (*SpeechResponse, error) {
+		return m.model.GenerateSpeech(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	countRetries(resp.Usage, attempts)
+	return resp, nil
+}
+
+// RetryVideoModel retries video generation requests that fail before a video job is created.
+// As with RetryImageJobModel, only rate limit errors are retried, and only until the model
+// reports the progress of a job: a job whose creation timed out or failed with a server error,
+// or that failed once running, may already be billed and is not created again.
+type RetryVideoModel struct {
+	model   VideoModel
+	options *RetryOptions
+}
+
+var _ VideoModel = (*RetryVideoModel)(nil)
+
+// NewRetryVideoModel wraps model with automatic retries
+func NewRetryVideoModel(model VideoModel, options *RetryOptions) *RetryVideoModel {
+	return &RetryVideoModel{model: model, options: options}
+}
+
+func (m *RetryVideoModel) GenerateVideo(ctx context.Context, req *VideoRequest) (*VideoResponse, error) {
+	var started atomic.Bool
+	if req != nil {
+		tracked, onProgress := *req, req.OnProgress
+		tracked.OnProgress = func(progress *VideoProgress) {
+			started.Store(true)
+			if onProgress != nil {
+				onProgress(progress)
+			}
+		}
+		req = &tracked
+	}
+	retryable := func(err error) bool {
+		return !started.Load() && IsRateLimitError(err)
+	}
+	resp, attempts, err := retryWhen(ctx, m.options, retryable, func() (*VideoResponse, error) {
+		return m.model.GenerateVideo(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	countRetries(resp.Usage, attempts)
+	return resp, nil
+}
+
+// RetryRerankModel retries rerank requests that fail with retryable errors
+type RetryRerankModel struct {
+	model   RerankModel
+	options *RetryOptions
+}
+
+var _ RerankModel = (*RetryRerankModel)(nil)
+
+// NewRetryRerankModel wraps model with automatic retries
+func NewRetryRerankModel(model RerankModel, options *RetryOptions) *RetryRerankModel {
+	return &RetryRerankModel{model: model, options: options}
+}
+
+func (m *RetryRerankModel) Rerank(ctx context.Context, req *RerankRequest) (*RerankResponse, error) {
+	resp, attempts, err := retry(ctx, m.options, func() (*RerankResponse, error) {
+		return m.model.Rerank(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	countRetries(resp.Usage, attempts)
+	return resp, nil
+}
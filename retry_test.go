@@ -0,0 +1,203 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "rate limit", err: NewRequestError("openai", http.StatusTooManyRequests, "slow down", nil), want: true},
+		{name: "server error", err: NewRequestError("openai", http.StatusBadGateway, "bad gateway", nil), want: true},
+		{name: "timeout", err: NewRequestError("openai", http.StatusRequestTimeout, "timeout", nil), want: true},
+		{name: "wrapped", err: errors.Join(errors.New("ctx"), NewRequestError("openai", http.StatusServiceUnavailable, "", nil)), want: true},
+		{name: "bad request", err: NewRequestError("openai", http.StatusBadRequest, "invalid", nil), want: false},
+		{name: "unauthorized", err: NewRequestError("openai", http.StatusUnauthorized, "invalid key", nil), want: false},
+		{name: "not implemented", err: NewRequestError("openai", http.StatusNotImplemented, "", nil), want: false},
+		{name: "plain error", err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryableError(tt.err))
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 2*time.Second, ParseRetryAfter(http.Header{"Retry-After": {"2"}}))
+	assert.Equal(t, 1500*time.Millisecond, ParseRetryAfter(http.Header{"Retry-After-Ms": {"1500"}, "Retry-After": {"2"}}))
+	assert.Zero(t, ParseRetryAfter(http.Header{"Retry-After": {"soon"}}))
+	assert.Zero(t, ParseRetryAfter(nil))
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	delay := ParseRetryAfter(http.Header{"Retry-After": {date}})
+	assert.Greater(t, delay, 50*time.Second)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(100*time.Millisecond, time.Second)
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		delay := backoff(attempt)
+		assert.GreaterOrEqual(t, delay, want/2)
+		assert.LessOrEqual(t, delay, want)
+	}
+}
+
+// flakyModel fails with the given errors before succeeding
+type flakyModel struct {
+	errs  []error
+	calls int
+}
+
+func (m *flakyModel) next() error {
+	m.calls++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func (m *flakyModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if err := m.next(); err != nil {
+		return nil, err
+	}
+	return &CompletionResponse{Output: "ok", Usage: &TokenUsage{TotalRequests: 1}}, nil
+}
+
+func (m *flakyModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	if err := m.next(); err != nil {
+		return nil, err
+	}
+	ch := make(chan StreamChunk, 2)
+	ch <- StreamTextChunk{Text: "ok"}
+	ch <- StreamUsageChunk{Usage: &TokenUsage{TotalRequests: 1}}
+	close(ch)
+	return ch, nil
+}
+
+func TestRetryCompletionModel(t *testing.T) {
+	noDelay := func(int) time.Duration { return 0 }
+	rateLimited := &RequestError{Provider: "openai", StatusCode: http.StatusTooManyRequests, RetryAfter: 10 * time.Millisecond}
+	unavailable := NewRequestError("openai", http.StatusServiceUnavailable, "unavailable", nil)
+	invalid := NewRequestError("openai", http.StatusBadRequest, "invalid", nil)
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantErr      error
+		wantCalls    int
+		wantRequests int
+	}{
+		{name: "succeeds first time", wantCalls: 1, wantRequests: 1},
+		{name: "retries transient errors", errs: []error{rateLimited, unavailable}, wantCalls: 3, wantRequests: 3},
+		{name: "gives up after max attempts", errs: []error{unavailable, unavailable, unavailable}, wantErr: unavailable, wantCalls: 3},
+		{name: "does not retry client errors", errs: []error{invalid}, wantErr: invalid, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &flakyModel{errs: tt.errs}
+			model := NewRetryCompletionModel(inner, &RetryOptions{MaxAttempts: 3, Backoff: noDelay})
+
+			resp, err := model.Complete(context.Background(), &CompletionRequest{})
+			assert.Equal(t, tt.wantCalls, inner.calls)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRequests, resp.Usage.TotalRequests)
+		})
+	}
+}
+
+func TestRetryCompletionModel_StreamComplete(t *testing.T) {
+	inner := &flakyModel{errs: []error{NewRequestError("openai", http.StatusInternalServerError, "", nil)}}
+	model := NewRetryCompletionModel(inner, &RetryOptions{MaxAttempts: 2, Backoff: func(int) time.Duration { return 0 }})
+
+	stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+
+	var usage *TokenUsage
+	for chunk := range stream {
+		if c, ok := chunk.(StreamUsageChunk); ok {
+			usage = c.Usage
+		}
+	}
+	assert.Equal(t, 2, inner.calls)
+	require.NotNil(t, usage)
+	assert.Equal(t, 2, usage.TotalRequests)
+}
+
+func TestRetryCompletionModel_ContextCanceled(t *testing.T) {
+	inner := &flakyModel{errs: []error{NewRequestError("openai", http.StatusTooManyRequests, "", nil)}}
+	model := NewRetryCompletionModel(inner, &RetryOptions{MaxAttempts: 3, Backoff: func(int) time.Duration { return time.Hour }})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := model.Complete(ctx, &CompletionRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, inner.calls)
+}
+
+// flakyVideoModel fails with the next error of errs, after reporting the progress of a job
+// when started is set
+type flakyVideoModel struct {
+	errs    []error
+	started bool
+	calls   int
+}
+
+func (m *flakyVideoModel) GenerateVideo(ctx context.Context, req *VideoRequest) (*VideoResponse, error) {
+	m.calls++
+	if m.started {
+		req.OnProgress(&VideoProgress{ID: "video", Status: "in_progress"})
+	}
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return nil, err
+	}
+	return &VideoResponse{Usage: &TokenUsage{TotalRequests: 1}}, nil
+}
+
+func TestRetryVideoModel(t *testing.T) {
+	options := &RetryOptions{MaxAttempts: 3, Backoff: func(int) time.Duration { return 0 }}
+	rateLimited := &RequestError{StatusCode: http.StatusTooManyRequests}
+
+	// A rate limited creation created nothing, so it is retried
+	inner := &flakyVideoModel{errs: []error{rateLimited}}
+	resp, err := NewRetryVideoModel(inner, options).GenerateVideo(context.Background(), &VideoRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.calls)
+	assert.Equal(t, 2, resp.Usage.TotalRequests)
+
+	// A server error may follow the creation of a billed job, so it is not retried
+	inner = &flakyVideoModel{errs: []error{&RequestError{StatusCode: http.StatusBadGateway}}}
+	_, err = NewRetryVideoModel(inner, options).GenerateVideo(context.Background(), &VideoRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, inner.calls)
+
+	// Nor is a job that started, and its progress still reaches the caller
+	var progress []string
+	inner = &flakyVideoModel{errs: []error{rateLimited}, started: true}
+	_, err = NewRetryVideoModel(inner, options).GenerateVideo(context.Background(), &VideoRequest{
+		OnProgress: func(p *VideoProgress) { progress = append(progress, p.ID) },
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, inner.calls)
+	assert.Equal(t, []string{"video"}, progress)
+}
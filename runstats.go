@@ -0,0 +1,154 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// JobOutcome classifies how a job ended
+type JobOutcome string
+
+const (
+	// JobSucceeded means the model finished its answer
+	JobSucceeded JobOutcome = "success"
+	// JobFailed means the job failed with an error
+	JobFailed JobOutcome = "error"
+	// JobContentFiltered means the output was withheld or refused for safety reasons
+	JobContentFiltered JobOutcome = "content_filter"
+	// JobTruncated means the output was cut off at the maximum number of tokens
+	JobTruncated JobOutcome = "length"
+)
+
+// Outcome classifies the result by its error and the finish reason of its response
+func (r *JobResult) Outcome() JobOutcome {
+	switch {
+	case r.Err != nil || r.Error != "" || r.Response == nil:
+		return JobFailed
+	case r.Response.FinishReason == FinishReasonContentFilter:
+		return JobContentFiltered
+	case r.Response.FinishReason == FinishReasonLength:
+		return JobTruncated
+	}
+	return JobSucceeded
+}
+
+// LatencyStats summarizes the latencies of the jobs of a run
+type LatencyStats struct {
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// RunStats are the aggregate statistics of the jobs of a run, e.g. of a Scheduler between
+// Start and Shutdown
+type RunStats struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Jobs       int       `json:"jobs"`
+	// Outcomes counts the jobs by how they ended
+	Outcomes map[JobOutcome]int `json:"outcomes"`
+	// Attempts is the number of attempts of all jobs, including retries
+	Attempts int        `json:"attempts"`
+	Usage    TokenUsage `json:"usage"`
+	// Cost is the total cost in USD of the jobs whose responses report one
+	Cost float64 `json:"cost"`
+	// Latency summarizes the duration of the last attempt of each job
+	Latency LatencyStats `json:"latency"`
+}
+
+// WriteReport writes the statistics as an indented JSON file
+func (s *RunStats) WriteReport(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write run report: %w", err)
+	}
+	return nil
+}
+
+// RunStatsCollector aggregates job results into RunStats. It is safe for concurrent use.
+type RunStatsCollector struct {
+	mu        sync.Mutex
+	stats     RunStats
+	cost      MicroCents
+	latencies []time.Duration
+}
+
+// NewRunStatsCollector starts collecting the statistics of a run
+func NewRunStatsCollector() *RunStatsCollector {
+	return &RunStatsCollector{stats: RunStats{
+		StartedAt: time.Now(),
+		Outcomes:  make(map[JobOutcome]int),
+	}}
+}
+
+// Record adds the result of a finished job
+func (c *RunStatsCollector) Record(result *JobResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Jobs++
+	c.stats.Outcomes[result.Outcome()]++
+	c.stats.Attempts += result.Attempts
+	if result.CompletedAt.After(c.stats.FinishedAt) {
+		c.stats.FinishedAt = result.CompletedAt
+	}
+	if resp := result.Response; resp != nil {
+		if resp.Usage != nil {
+			c.stats.Usage.Append(resp.Usage)
+		}
+		if resp.Cost != nil {
+			c.cost += MicroCentsFromUSD(*resp.Cost)
+		}
+	}
+	c.latencies = append(c.latencies, result.Latency)
+}
+
+// Stats returns the statistics of the results recorded so far
+func (c *RunStatsCollector) Stats() *RunStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Outcomes = make(map[JobOutcome]int, len(c.stats.Outcomes))
+	for outcome, n := range c.stats.Outcomes {
+		stats.Outcomes[outcome] = n
+	}
+	stats.Cost = c.cost.USD()
+	stats.Latency = latencyStats(c.latencies)
+	return &stats
+}
+
+func latencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	var sum time.Duration
+	for _, latency := range sorted {
+		sum += latency
+	}
+	percentile := func(p float64) time.Duration {
+		return sorted[int(math.Round(p*float64(len(sorted)-1)))]
+	}
+	return LatencyStats{
+		Mean: sum / time.Duration(len(sorted)),
+		P50:  percentile(0.5),
+		P90:  percentile(0.9),
+		P99:  percentile(0.99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
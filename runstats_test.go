@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobResult_Outcome(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *JobResult
+		want   JobOutcome
+	}{
+		{name: "stop", result: &JobResult{Response: &CompletionResponse{FinishReason: FinishReasonStop}}, want: JobSucceeded},
+		{name: "unknown_reason", result: &JobResult{Response: &CompletionResponse{}}, want: JobSucceeded},
+		{name: "length", result: &JobResult{Response: &CompletionResponse{FinishReason: FinishReasonLength}}, want: JobTruncated},
+		{name: "content_filter", result: &JobResult{Response: &CompletionResponse{FinishReason: FinishReasonContentFilter}}, want: JobContentFiltered},
+		{name: "error", result: &JobResult{Err: errors.New("boom")}, want: JobFailed},
+		{name: "stored_error", result: &JobResult{Error: "boom"}, want: JobFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.result.Outcome())
+		})
+	}
+}
+
+func TestRunStatsCollector(t *testing.T) {
+	collector := NewRunStatsCollector()
+	cost := 0.25
+	for i := 1; i <= 10; i++ {
+		collector.Record(&JobResult{
+			Response: &CompletionResponse{
+				FinishReason: FinishReasonStop,
+				Usage:        &TokenUsage{TotalInputTokens: 10, TotalOutputTokens: 5, TotalRequests: 1},
+				Cost:         &cost,
+			},
+			Attempts: 1,
+			Latency:  time.Duration(i) * time.Second,
+		})
+	}
+	collector.Record(&JobResult{Response: &CompletionResponse{FinishReason: FinishReasonLength}, Attempts: 2, Latency: 20 * time.Second})
+	collector.Record(&JobResult{Err: errors.New("boom"), Attempts: 3, Latency: time.Second})
+
+	stats := collector.Stats()
+	assert.Equal(t, 12, stats.Jobs)
+	assert.Equal(t, map[JobOutcome]int{JobSucceeded: 10, JobTruncated: 1, JobFailed: 1}, stats.Outcomes)
+	assert.Equal(t, 15, stats.Attempts)
+	assert.Equal(t, int64(100), stats.Usage.TotalInputTokens)
+	assert.Equal(t, 10, stats.Usage.TotalRequests)
+	assert.InDelta(t, 2.50, stats.Cost, 1e-9)
+	assert.Equal(t, LatencyStats{
+		Mean: 76 * time.Second / 12,
+		P50:  6 * time.Second,
+		P90:  10 * time.Second,
+		P99:  20 * time.Second,
+		Max:  20 * time.Second,
+	}, stats.Latency)
+}
+
+func TestScheduler_RunReport(t *testing.T) {
+	model := &flakyCompletionModel{stubCompletionModel: stubCompletionModel{
+		output: "draft",
+		err:    NewRequestError("openai", http.StatusBadRequest, "bad request", nil),
+	}}
+	model.failures.Store(1)
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	scheduler, err := NewScheduler(model, WithWorkers(1), WithRunReport(path))
+	require.NoError(t, err)
+	assert.Nil(t, scheduler.Stats())
+	scheduler.Start(context.Background())
+
+	done := make(chan struct{}, 2)
+	for range 2 {
+		_, err := scheduler.SubmitAsync(context.Background(), &CompletionRequest{}, func(ctx context.Context, result *JobResult) {
+			done <- struct{}{}
+		})
+		require.NoError(t, err)
+	}
+	<-done
+	<-done
+	require.NoError(t, scheduler.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var report RunStats
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, 2, report.Jobs)
+	assert.Equal(t, map[JobOutcome]int{JobSucceeded: 1, JobFailed: 1}, report.Outcomes)
+	assert.Equal(t, scheduler.Stats().Jobs, report.Jobs)
+}
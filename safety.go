@@ -0,0 +1,46 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+// HarmCategory is a category of harmful content rated by the provider's safety filters
+type HarmCategory string
+
+const (
+	HarmCategoryHarassment       HarmCategory = "HARM_CATEGORY_HARASSMENT"
+	HarmCategoryHateSpeech       HarmCategory = "HARM_CATEGORY_HATE_SPEECH"
+	HarmCategorySexuallyExplicit HarmCategory = "HARM_CATEGORY_SEXUALLY_EXPLICIT"
+	HarmCategoryDangerousContent HarmCategory = "HARM_CATEGORY_DANGEROUS_CONTENT"
+	HarmCategoryCivicIntegrity   HarmCategory = "HARM_CATEGORY_CIVIC_INTEGRITY"
+)
+
+// HarmBlockThreshold is the probability of harm from which content of a category is blocked
+type HarmBlockThreshold string
+
+const (
+	// HarmBlockLowAndAbove blocks content with a low or higher probability of harm
+	HarmBlockLowAndAbove HarmBlockThreshold = "BLOCK_LOW_AND_ABOVE"
+	// HarmBlockMediumAndAbove blocks content with a medium or higher probability of harm
+	HarmBlockMediumAndAbove HarmBlockThreshold = "BLOCK_MEDIUM_AND_ABOVE"
+	// HarmBlockOnlyHigh blocks only content with a high probability of harm
+	HarmBlockOnlyHigh HarmBlockThreshold = "BLOCK_ONLY_HIGH"
+	// HarmBlockNone blocks nothing but still rates the content
+	HarmBlockNone HarmBlockThreshold = "BLOCK_NONE"
+	// HarmBlockOff turns the filter of the category off
+	HarmBlockOff HarmBlockThreshold = "OFF"
+)
+
+// SafetySetting sets the blocking threshold of a harm category
+type SafetySetting struct {
+	Category  HarmCategory       `json:"category"`
+	Threshold HarmBlockThreshold `json:"threshold"`
+}
+
+// WithSafetySettings overrides the provider's default safety thresholds. Blocked responses
+// finish with FinishReasonContentFilter. It is supported by the Gemini provider and ignored by
+// other providers.
+func WithSafetySettings(settings ...SafetySetting) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.SafetySettings = settings
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// InferSchema infers the JSON schema of a structured output from a few example outputs, so
+// prompts that ask for JSON in their instructions can move to WithJSONSchema without a Go type
+// describing their output. Each example is JSON text, as a string, []byte or json.RawMessage,
+// or any other value, which is encoded to JSON first, and must be an object, as structured
+// outputs require at the top level.
+//
+// Properties present in every example are required, and unknown properties are not allowed,
+// as in GenerateSchema. A property with values of several types, e.g. null in some examples,
+// is given all of them, and numbers are integers unless an example has a fraction. The schema
+// is a candidate to review: the examples may not show every optional property or value type.
+func InferSchema(examples ...any) (map[string]any, error) {
+	if len(examples) == 0 {
+		return nil, NewValidationError("examples", "at least one example is required", nil)
+	}
+
+	root := &schemaNode{}
+	for i, example := range examples {
+		value, err := decodeExample(example)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode example %d: %w", i, err)
+		}
+		if _, ok := value.(map[string]any); !ok {
+			return nil, NewValidationError(fmt.Sprintf("examples[%d]", i), "must be a JSON object", example)
+		}
+		root.add(value)
+	}
+	return root.schema(), nil
+}
+
+// decodeExample decodes example into the values of encoding/json, keeping numbers as
+// json.Number to tell integers from fractions
+func decodeExample(example any) (any, error) {
+	var data []byte
+	switch e := example.(type) {
+	case string:
+		data = []byte(e)
+	case []byte:
+		data = e
+	case json.RawMessage:
+		data = e
+	default:
+		var err error
+		if data, err = json.Marshal(example); err != nil {
+			return nil, err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// schemaNode accumulates the values seen at one location of the examples
+type schemaNode struct {
+	types map[string]bool
+	// objects is the number of objects seen, and present the number of them having each property
+	objects    int
+	present    map[string]int
+	properties map[string]*schemaNode
+	items      *schemaNode
+}
+
+func (n *schemaNode) add(value any) {
+	if n.types == nil {
+		n.types = make(map[string]bool)
+	}
+	switch v := value.(type) {
+	case nil:
+		n.types["null"] = true
+	case bool:
+		n.types["boolean"] = true
+	case string:
+		n.types["string"] = true
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			n.types["number"] = true
+		} else {
+			n.types["integer"] = true
+		}
+	case []any:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = &schemaNode{}
+		}
+		for _, item := range v {
+			n.items.add(item)
+		}
+	case map[string]any:
+		n.types["object"] = true
+		if n.properties == nil {
+			n.properties = make(map[string]*schemaNode)
+			n.present = make(map[string]int)
+		}
+		n.objects++
+		for name, property := range v {
+			if n.properties[name] == nil {
+				n.properties[name] = &schemaNode{}
+			}
+			n.properties[name].add(property)
+			n.present[name]++
+		}
+	}
+}
+
+func (n *schemaNode) schema() map[string]any {
+	// Integers are numbers, so a location with both is a number
+	if n.types["number"] {
+		delete(n.types, "integer")
+	}
+	types := make([]string, 0, len(n.types))
+	for t := range n.types {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+
+	schema := make(map[string]any)
+	switch len(types) {
+	case 0:
+		// Only empty arrays were seen, so their items can be anything
+		return schema
+	case 1:
+		schema["type"] = types[0]
+	default:
+		schema["type"] = types
+	}
+
+	if n.types["object"] {
+		properties := make(map[string]any, len(n.properties))
+		required := []string{}
+		for name, property := range n.properties {
+			properties[name] = property.schema()
+			if n.present[name] == n.objects {
+				required = append(required, name)
+			}
+		}
+		slices.Sort(required)
+		schema["properties"] = properties
+		schema["required"] = required
+		schema["additionalProperties"] = false
+	}
+	if n.types["array"] {
+		schema["items"] = n.items.schema()
+	}
+	return schema
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferSchema(t *testing.T) {
+	schema, err := InferSchema(
+		`{"name": "Ada", "age": 36, "score": 9, "tags": ["math"], "address": {"city": "London"}, "manager": null}`,
+		[]byte(`{"name": "Alan", "age": 41, "score": 7.5, "tags": [], "address": {"city": "Wilmslow", "zip": "SK9"}, "manager": "Ada"}`),
+		map[string]any{"name": "Grace", "age": 85, "score": 8, "tags": []string{"navy", "cobol"}, "address": map[string]any{"city": "Arlington"}, "email": "grace@example.com"},
+	)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"age": {"type": "integer"},
+			"score": {"type": "number"},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"address": {
+				"type": "object",
+				"properties": {"city": {"type": "string"}, "zip": {"type": "string"}},
+				"required": ["city"],
+				"additionalProperties": false
+			},
+			"manager": {"type": ["null", "string"]},
+			"email": {"type": "string"}
+		},
+		"required": ["address", "age", "name", "score", "tags"],
+		"additionalProperties": false
+	}`, string(data))
+}
+
+func TestInferSchema_EmptyArrays(t *testing.T) {
+	schema, err := InferSchema(json.RawMessage(`{"items": [], "rows": [[1, 2], []]}`))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"items": {"type": "array", "items": {}},
+			"rows": {"type": "array", "items": {"type": "array", "items": {"type": "integer"}}}
+		},
+		"required": ["items", "rows"],
+		"additionalProperties": false
+	}`, string(data))
+}
+
+func TestInferSchema_Invalid(t *testing.T) {
+	_, err := InferSchema()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	_, err = InferSchema(`{"name": "Ada"}`, `[1, 2]`)
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "examples[1]", validationErr.Field)
+
+	_, err = InferSchema(`{"name": `)
+	assert.ErrorContains(t, err, "failed to decode example 0")
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+// ServiceTier is the capacity tier a provider serves a request with, which trades latency and
+// availability against price
+type ServiceTier string
+
+const (
+	// ServiceTierAuto lets the provider choose, using priority capacity where the account has it
+	ServiceTierAuto ServiceTier = "auto"
+	// ServiceTierDefault is the standard capacity at the standard price
+	ServiceTierDefault ServiceTier = "default"
+	// ServiceTierFlex is cheaper, slower capacity that may be unavailable (OpenAI)
+	ServiceTierFlex ServiceTier = "flex"
+	// ServiceTierPriority is faster, more reliable capacity at a higher price
+	ServiceTierPriority ServiceTier = "priority"
+)
+
+// WithServiceTier selects the service tier of requests. OpenAI accepts every tier; Anthropic
+// uses its priority tier for ServiceTierAuto and ServiceTierPriority and only standard capacity
+// for ServiceTierDefault. Other providers, including OpenAI compatible ones, are not sent it.
+func WithServiceTier(tier ServiceTier) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.ServiceTier = &tier
+	}
+}
+
+// ForServiceTier returns the model priced for the tier that served a request, or the model
+// itself when the tier has no pricing of its own. The long context prices of the model are
+// scaled like its prices, so long requests served by the tier are billed at both premiums.
+func (m *ModelInfo) ForServiceTier(tier ServiceTier) *ModelInfo {
+	pricing, ok := m.ServiceTierPricing[tier]
+	if !ok {
+		return m
+	}
+	priced := *m
+	priced.Pricing = pricing
+	if long := m.LongContextPricing; long != nil {
+		priced.LongContextPricing = &LongContextPricing{
+			InputTokens: long.InputTokens,
+			Pricing:     long.Pricing.scale(m.Pricing, pricing),
+		}
+	}
+	return &priced
+}
+
+// scale returns the pricing with each price multiplied by the ratio of its price in to and
+// from, or replaced by its price in to when it has none in from
+func (p ModelPricing) scale(from, to ModelPricing) ModelPricing {
+	prices := func(m *ModelPricing) []*Price {
+		return []*Price{
+			&m.Prompt, &m.Completion, &m.Request, &m.Image, &m.WebSearch, &m.InternalReasoning,
+			&m.InputCacheRead, &m.InputCacheWrite, &m.AudioInput, &m.AudioOutput, &m.Characters,
+			&m.VideoSecond,
+		}
+	}
+	scaled := p
+	fromPrices, toPrices := prices(&from), prices(&to)
+	for i, price := range prices(&scaled) {
+		if fromPrices[i].IsZero() {
+			*price = *toPrices[i]
+			continue
+		}
+		*price = price.mulDiv(*toPrices[i], *fromPrices[i])
+	}
+	return scaled
+}
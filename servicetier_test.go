@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelInfo_ForServiceTier(t *testing.T) {
+	info := &ModelInfo{
+		ID:      "m",
+		Pricing: ModelPricing{Prompt: NewPrice(2), Completion: NewPrice(8)},
+		ServiceTierPricing: map[ServiceTier]ModelPricing{
+			ServiceTierFlex: {Prompt: NewPrice(1), Completion: NewPrice(4)},
+		},
+	}
+
+	flex := info.ForServiceTier(ServiceTierFlex)
+	assert.Equal(t, 1.0, flex.Pricing.Prompt.Float64())
+	assert.Equal(t, "m", flex.ID)
+	assert.Equal(t, 2.0, info.Pricing.Prompt.Float64(), "the catalog model is not changed")
+
+	assert.Same(t, info, info.ForServiceTier(ServiceTierPriority))
+	assert.Same(t, info, info.ForServiceTier(""))
+}
+
+func TestWithServiceTier(t *testing.T) {
+	opts := ApplyCompletionOptions([]CompletionOption{WithServiceTier(ServiceTierFlex)})
+	assert.Equal(t, ServiceTierFlex, *opts.ServiceTier)
+}
+
+func TestModelInfo_ForServiceTier_LongContext(t *testing.T) {
+	info := &ModelInfo{
+		Pricing: ModelPricing{Prompt: NewPrice(3), Completion: NewPrice(15), InputCacheRead: NewPrice(0.3)},
+		ServiceTierPricing: map[ServiceTier]ModelPricing{
+			ServiceTierPriority: {Prompt: NewPrice(6), Completion: NewPrice(30), InputCacheRead: NewPrice(0.6)},
+		},
+		LongContextPricing: &LongContextPricing{
+			InputTokens: 200000,
+			Pricing:     ModelPricing{Prompt: NewPrice(6), Completion: NewPrice(22.5), InputCacheRead: NewPrice(0.6)},
+		},
+	}
+
+	priority := info.ForServiceTier(ServiceTierPriority)
+	assert.Equal(t, 6.0, priority.ForInputTokens(200000).Pricing.Prompt.Float64())
+	long := priority.ForInputTokens(200001).Pricing
+	assert.Equal(t, 12.0, long.Prompt.Float64())
+	assert.Equal(t, 45.0, long.Completion.Float64())
+	assert.Equal(t, 1.2, long.InputCacheRead.Float64())
+	assert.Equal(t, 6.0, info.LongContextPricing.Pricing.Prompt.Float64(), "the catalog model is not changed")
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// InFlight tracks in-flight requests and streams so a provider can shut down gracefully.
+// A nil *InFlight is valid and tracks nothing.
+type InFlight struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closing bool
+	nextID  uint64
+	active  map[uint64]context.CancelCauseFunc
+}
+
+// NewInFlight creates a new in-flight request tracker
+func NewInFlight() *InFlight {
+	return &InFlight{
+		active: make(map[uint64]context.CancelCauseFunc),
+	}
+}
+
+// Begin registers a new request. It returns a context that is canceled with ErrShuttingDown
+// if the request is still running when the shutdown deadline passes, and a done function
+// that must be called when the request (or stream) finishes. Begin returns ErrShuttingDown
+// once Shutdown has been called.
+func (g *InFlight) Begin(ctx context.Context) (context.Context, func(), error) {
+	if g == nil {
+		return ctx, func() {}, nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closing {
+		return nil, nil, ErrShuttingDown
+	}
+
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	id := g.nextID
+	g.nextID++
+	g.active[id] = cancel
+	g.wg.Add(1)
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			g.mu.Lock()
+			delete(g.active, id)
+			g.mu.Unlock()
+			cancel(nil)
+			g.wg.Done()
+		})
+	}
+	return reqCtx, done, nil
+}
+
+// Active returns the number of in-flight requests
+func (g *InFlight) Active() int {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.active)
+}
+
+// Shutdown stops accepting new requests and waits for in-flight requests to finish.
+// If ctx expires first, the remaining requests are canceled with ErrShuttingDown and
+// Shutdown returns ctx.Err() once they have stopped.
+func (g *InFlight) Shutdown(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	g.closing = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	for _, cancel := range g.active {
+		cancel(ErrShuttingDown)
+	}
+	g.mu.Unlock()
+
+	<-done
+	return ctx.Err()
+}
+
+// IsShuttingDown reports whether ctx was canceled by a provider shutdown
+func IsShuttingDown(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrShuttingDown)
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInFlight_ShutdownWaitsForRequests(t *testing.T) {
+	g := NewInFlight()
+
+	_, done, err := g.Begin(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, g.Active())
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		done()
+	}()
+
+	require.NoError(t, g.Shutdown(context.Background()))
+	assert.Equal(t, 0, g.Active())
+
+	_, _, err = g.Begin(context.Background())
+	assert.ErrorIs(t, err, ErrShuttingDown)
+}
+
+func TestInFlight_ShutdownCancelsAfterDeadline(t *testing.T) {
+	g := NewInFlight()
+
+	reqCtx, done, err := g.Begin(context.Background())
+	require.NoError(t, err)
+
+	stopped := make(chan bool, 1)
+	go func() {
+		<-reqCtx.Done()
+		stopped <- IsShuttingDown(reqCtx)
+		done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = g.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, <-stopped, "request should be canceled with ErrShuttingDown")
+	assert.Equal(t, 0, g.Active())
+}
+
+func TestInFlight_DoneIsIdempotent(t *testing.T) {
+	g := NewInFlight()
+
+	reqCtx, done, err := g.Begin(context.Background())
+	require.NoError(t, err)
+	done()
+	done()
+
+	assert.ErrorIs(t, reqCtx.Err(), context.Canceled)
+	assert.False(t, IsShuttingDown(reqCtx))
+	require.NoError(t, g.Shutdown(context.Background()))
+}
+
+func TestInFlight_Nil(t *testing.T) {
+	var g *InFlight
+
+	ctx := context.Background()
+	reqCtx, done, err := g.Begin(ctx)
+	require.NoError(t, err)
+	done()
+	assert.Equal(t, ctx, reqCtx)
+	assert.Equal(t, 0, g.Active())
+	assert.NoError(t, g.Shutdown(ctx))
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import "context"
+
+// SpeechModel defines the interface for text-to-speech operations
+type SpeechModel interface {
+	// GenerateSpeech synthesizes audio from text
+	GenerateSpeech(ctx context.Context, req *SpeechRequest) (*SpeechResponse, error)
+}
+
+// Audio formats of generated speech
+const (
+	SpeechFormatMP3  = "mp3"
+	SpeechFormatOpus = "opus"
+	SpeechFormatAAC  = "aac"
+	SpeechFormatFLAC = "flac"
+	SpeechFormatWAV  = "wav"
+	SpeechFormatPCM  = "pcm"
+)
+
+type SpeechRequest struct {
+	Model string `json:"model"`
+	// Text is the text to speak
+	Text string `json:"text"`
+	// Instructions steer the tone and delivery on models that support them, e.g. gpt-4o-mini-tts
+	Instructions string             `json:"instructions,omitempty"`
+	Config       *SpeechModelConfig `json:"config,omitempty"`
+}
+
+type SpeechModelConfig struct {
+	// Voice is the provider's voice name or ID
+	Voice string `json:"voice,omitempty"`
+	// Speed multiplies the speaking rate; zero uses the provider default of 1
+	Speed float64 `json:"speed,omitempty"`
+	// Format is the audio format, one of the SpeechFormat constants; MP3 by default
+	Format string `json:"format,omitempty"`
+}
+
+type SpeechResponse struct {
+	Output []byte `json:"output"`
+	// ContentType is the MIME type of Output, e.g. audio/mpeg
+	ContentType string      `json:"contentType"`
+	Usage       *TokenUsage `json:"usage,omitempty"`
+	Cost        *float64    `json:"cost,omitempty"`
+	// CostBreakdown splits Cost by what was billed, see CompletionResponse.CostBreakdown
+	CostBreakdown *CostBreakdown `json:"costBreakdown,omitempty"`
+}
+
+// SpeechContentType returns the MIME type of a speech format
+func SpeechContentType(format string) string {
+	switch format {
+	case SpeechFormatOpus:
+		return "audio/ogg"
+	case SpeechFormatAAC:
+		return "audio/aac"
+	case SpeechFormatFLAC:
+		return "audio/flac"
+	case SpeechFormatWAV:
+		return "audio/wav"
+	case SpeechFormatPCM:
+		return "audio/pcm"
+	}
+	return "audio/mpeg"
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// StopSequenceCompletionModel enforces the stop sequences set with WithStop on the client, for
+// gateways that ignore them. Output is truncated before the first stop sequence, which is not
+// included, and FinishReason is then FinishReasonStop. Streams are scanned as they arrive,
+// including sequences spanning text chunks. Once a stop sequence is found, the upstream request
+// is canceled, so the provider stops generating, and the finish chunk is followed by the usage
+// the provider reported so far or, when usage is requested, an estimate from the prompt and the
+// text received. Providers that honor stop sequences are unaffected, apart from streams holding
+// back text that may start one.
+type StopSequenceCompletionModel struct {
+	model   CompletionModel
+	options []CompletionOption
+}
+
+var _ CompletionModel = (*StopSequenceCompletionModel)(nil)
+var _ TokenCounter = (*StopSequenceCompletionModel)(nil)
+
+// NewStopSequenceCompletionModel wraps model, created with opts, with client-side enforcement
+// of the stop sequences of opts and of the option overrides of request contexts
+func NewStopSequenceCompletionModel(model CompletionModel, opts []CompletionOption) *StopSequenceCompletionModel {
+	return &StopSequenceCompletionModel{model: model, options: opts}
+}
+
+func (m *StopSequenceCompletionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	resp, err := m.model.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	stop := ApplyContextCompletionOptions(ctx, m.options).Stop
+	if len(stop) == 0 {
+		return resp, nil
+	}
+	truncated := *resp
+	if i := indexStop(resp.Output, stop); i >= 0 {
+		truncated.Output, truncated.FinishReason = resp.Output[:i], FinishReasonStop
+	}
+	if len(resp.Choices) > 0 {
+		truncated.Choices = make([]CompletionChoice, len(resp.Choices))
+		for j, choice := range resp.Choices {
+			if i := indexStop(choice.Text, stop); i >= 0 {
+				choice.Text, choice.FinishReason = choice.Text[:i], FinishReasonStop
+			}
+			truncated.Choices[j] = choice
+		}
+	}
+	return &truncated, nil
+}
+
+func (m *StopSequenceCompletionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	options := ApplyContextCompletionOptions(ctx, m.options)
+	if len(options.Stop) == 0 {
+		return m.model.StreamComplete(ctx, req)
+	}
+	upstream, cancel := context.WithCancel(ctx)
+	stream, err := m.model.StreamComplete(upstream, req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	out := make(chan StreamChunk, 1)
+	cut := &cutStream{ctx: ctx, out: out, model: m.model, req: req, stream: stream, cancel: cancel}
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		send := cut.send
+		sendText := func(text string) bool {
+			return text == "" || send(options.TextChunk(text))
+		}
+		// pending is the text held back because it may start a stop sequence
+		var pending string
+
+		for chunk := range stream {
+			chunk = ownText(chunk)
+			cut.observe(chunk)
+			text, isText := chunk.(StreamTextChunk)
+			if !isText {
+				switch chunk.(type) {
+				case StreamFinishChunk, StreamUsageChunk, StreamErrorChunk:
+					// The stream ends, so the held back text is output
+					if !sendText(pending) {
+						return
+					}
+					pending = ""
+				}
+				if !send(chunk) {
+					return
+				}
+				continue
+			}
+
+			buffered := pending + text.Text
+			if i := indexStop(buffered, options.Stop); i >= 0 {
+				if !sendText(buffered[:i]) {
+					return
+				}
+				if options.StreamsEvent(FinishChunkType) && !send(StreamFinishChunk{Reason: FinishReasonStop}) {
+					return
+				}
+				cut.end(options)
+				return
+			}
+			hold := len(buffered) - stopPrefixLen(buffered, options.Stop)
+			pending = buffered[hold:]
+			if !sendText(buffered[:hold]) {
+				return
+			}
+		}
+		sendText(pending)
+	}()
+	return out, nil
+}
+
+// CountTokens counts the tokens of req with the wrapped model
+func (m *StopSequenceCompletionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return CountTokens(m.model, req)
+}
+
+// cutStream delivers an upstream stream whose output a middleware may cut short, keeping
+// what is needed to end it
+type cutStream struct {
+	ctx    context.Context
+	out    chan<- StreamChunk
+	model  CompletionModel
+	req    *CompletionRequest
+	stream StreamCompletionResponse
+	// cancel cancels the upstream request
+	cancel context.CancelFunc
+	// output is the text received, usage the last usage chunk received and reported whether
+	// a usage chunk was delivered
+	output   strings.Builder
+	usage    *StreamUsageChunk
+	reported bool
+}
+
+// send delivers chunk and reports whether the consumer is still reading
+func (s *cutStream) send(chunk StreamChunk) bool {
+	select {
+	case s.out <- chunk:
+		if _, ok := chunk.(StreamUsageChunk); ok {
+			s.reported = true
+		}
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// observe records an upstream chunk, whose text must be owned
+func (s *cutStream) observe(chunk StreamChunk) {
+	switch c := chunk.(type) {
+	case StreamTextChunk:
+		s.output.WriteString(c.Text)
+	case StreamUsageChunk:
+		s.usage = &c
+	}
+}
+
+// end cuts the stream short after its last chunk was delivered. The upstream request is
+// canceled, so the provider stops generating, and the rest of the stream is drained in the
+// background. Unless a usage chunk was delivered, the last one received is sent or, when
+// options request usage, one estimated from the prompt and the text received.
+func (s *cutStream) end(options *CompletionOptions) {
+	s.cancel()
+	go func() {
+		for chunk := range s.stream {
+			if c, ok := chunk.(*StreamTextBytesChunk); ok {
+				c.Release()
+			}
+		}
+	}()
+	switch {
+	case s.reported:
+	case s.usage != nil:
+		s.send(*s.usage)
+	case options.WithUsage != nil && *options.WithUsage && options.StreamsEvent(UsageChunkType):
+		usage := &TokenUsage{
+			TotalOutputTokens: int64(HeuristicTokenizer{}.CountTokens(s.output.String())),
+			TotalRequests:     1,
+		}
+		if count, err := CountTokens(s.model, s.req); err == nil {
+			usage.TotalInputTokens = int64(count.InputTokens)
+		}
+		s.send(StreamUsageChunk{Usage: usage})
+	}
+}
+
+// indexStop returns the index of the first stop sequence in text, or -1 if there is none
+func indexStop(text string, stop []string) int {
+	first := -1
+	for _, s := range stop {
+		if s == "" {
+			continue
+		}
+		if i := strings.Index(text, s); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	return first
+}
+
+// stopPrefixLen returns the length of the longest suffix of text that is a proper prefix of a
+// stop sequence, i.e. the text that may start a stop sequence completed by the next chunk
+func stopPrefixLen(text string, stop []string) int {
+	longest := 0
+	for _, s := range stop {
+		for n := min(len(s)-1, len(text)); n > longest; n-- {
+			if strings.HasSuffix(text, s[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
@@ -0,0 +1,191 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkModel streams the given chunks
+type chunkModel struct {
+	chunks []StreamChunk
+}
+
+func (m *chunkModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	ch := make(chan StreamChunk, len(m.chunks))
+	for _, chunk := range m.chunks {
+		ch <- chunk
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (m *chunkModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	return nil, NewUnsupportedCapabilityError("streaming", "completion")
+}
+
+func TestStopSequenceCompletionModel_Stream(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		stop   []string
+		want   []string
+	}{
+		{name: "within chunk", chunks: []string{"Hello", " world\nUser: hi", "more"}, stop: []string{"\nUser:"}, want: []string{"Hello", " world"}},
+		{name: "spanning chunks", chunks: []string{"Hello wor", "ld\nUs", "er: hi"}, stop: []string{"\nUser:"}, want: []string{"Hello wor", "ld"}},
+		{name: "false start", chunks: []string{"a\nU", "nix\nUser:"}, stop: []string{"\nUser:"}, want: []string{"a", "\nUnix"}},
+		{name: "first of several", chunks: []string{"one two", " three"}, stop: []string{"three", "two"}, want: []string{"one "}},
+		{name: "at start", chunks: []string{"END"}, stop: []string{"END"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The gateway ignores the stop sequences and generates until its own end
+			var chunks []StreamChunk
+			for _, text := range tt.chunks {
+				chunks = append(chunks, StreamTextChunk{Text: text})
+			}
+			chunks = append(chunks,
+				StreamFinishChunk{Reason: FinishReasonLength},
+				StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 9}},
+			)
+			model := NewStopSequenceCompletionModel(&chunkModel{chunks: chunks}, []CompletionOption{WithStop(tt.stop), WithUsage(true)})
+			stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+			require.NoError(t, err)
+
+			var texts []string
+			var rest []StreamChunk
+			for chunk := range stream {
+				if c, ok := chunk.(StreamTextChunk); ok {
+					require.Empty(t, rest, "text after the finish chunk")
+					texts = append(texts, c.Text)
+					continue
+				}
+				rest = append(rest, chunk)
+			}
+			assert.Equal(t, tt.want, texts)
+			// The upstream request is canceled, so the usage is estimated from the text received
+			var received string
+			for _, text := range tt.chunks {
+				if received += text; indexStop(received, tt.stop) >= 0 {
+					break
+				}
+			}
+			assert.Equal(t, []StreamChunk{
+				StreamFinishChunk{Reason: FinishReasonStop},
+				StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: int64(HeuristicTokenizer{}.CountTokens(received)), TotalRequests: 1}},
+			}, rest)
+		})
+	}
+}
+
+// endlessModel streams text until its context is done, which it sends on canceled
+type endlessModel struct {
+	chunkModel
+	canceled chan error
+}
+
+func (m *endlessModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	ch := make(chan StreamChunk)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case ch <- StreamTextChunk{Text: "more "}:
+			case <-ctx.Done():
+				m.canceled <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestStopSequenceCompletionModel_StreamCancelsUpstream(t *testing.T) {
+	upstream := &endlessModel{canceled: make(chan error, 1)}
+	model := NewStopSequenceCompletionModel(upstream, []CompletionOption{WithStop([]string{"more more"}), WithUsage(true)})
+	stream, err := model.StreamComplete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+
+	var chunks []StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	select {
+	case err := <-upstream.canceled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("the upstream request was not canceled")
+	}
+	assert.Equal(t, []StreamChunk{
+		StreamFinishChunk{Reason: FinishReasonStop},
+		StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: int64(HeuristicTokenizer{}.CountTokens("more more ")), TotalRequests: 1}},
+	}, chunks)
+}
+
+func TestStopSequenceCompletionModel_StreamWithoutStop(t *testing.T) {
+	model := NewStopSequenceCompletionModel(&chunkModel{
+		chunks: []StreamChunk{
+			StreamTextChunk{Text: "say \"do"},
+			StreamTextChunk{Text: "ne\" when"},
+			StreamFinishChunk{Reason: FinishReasonStop},
+			StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 4}},
+		},
+	}, []CompletionOption{WithStop([]string{"\"done\"."})})
+	stream, err := StreamCompletion(context.Background(), model, &CompletionRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "say \"done\" when", resp.Output)
+	assert.Equal(t, int64(4), resp.Usage.TotalOutputTokens)
+
+	// Context overrides are enforced too, and a model without stop sequences passes through
+	model = NewStopSequenceCompletionModel(&chunkModel{chunks: []StreamChunk{StreamTextChunk{Text: "a;b"}}}, nil)
+	stream, err = StreamCompletion(ContextWithOptions(context.Background(), WithStop([]string{";"})), model, &CompletionRequest{})
+	require.NoError(t, err)
+	resp, err = stream.Final()
+	require.NoError(t, err)
+	assert.Equal(t, "a", resp.Output)
+	assert.Equal(t, FinishReasonStop, resp.FinishReason)
+}
+
+func TestStopSequenceCompletionModel_Complete(t *testing.T) {
+	upstream := &stubCompletionModel{
+		output: "Paris.\nQ: next",
+		choices: []CompletionChoice{
+			{Index: 0, Text: "Paris.\nQ: next", FinishReason: FinishReasonLength},
+			{Index: 1, Text: "Lyon", FinishReason: FinishReasonLength},
+		},
+	}
+	model := NewStopSequenceCompletionModel(upstream, []CompletionOption{WithStop([]string{"\nQ:"})})
+	resp, err := model.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "Paris.", resp.Output)
+	assert.Equal(t, FinishReasonStop, resp.FinishReason)
+	assert.Equal(t, []CompletionChoice{
+		{Index: 0, Text: "Paris.", FinishReason: FinishReasonStop},
+		{Index: 1, Text: "Lyon", FinishReason: FinishReasonLength},
+	}, resp.Choices)
+	assert.Equal(t, "Paris.\nQ: next", upstream.choices[0].Text, "upstream response is not modified")
+}
+
+func TestDefaultModelProvider_StopSequences(t *testing.T) {
+	info := &ModelInfo{ID: "gpt-4o"}
+	provider := NewDefaultModelProvider("openai", []*ModelInfo{info})
+	model := &stubCompletionModel{output: "a;b"}
+
+	resp, err := provider.DecorateCompletionModel(model, info, []CompletionOption{WithStop([]string{";"})}).Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "a", resp.Output)
+
+	// A model created without stop sequences enforces those of the request context
+	decorated := provider.DecorateCompletionModel(model, info, []CompletionOption{WithTemperature(0)})
+	resp, err = decorated.Complete(ContextWithOptions(context.Background(), WithStop([]string{";"})), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "a", resp.Output)
+	resp, err = decorated.Complete(context.Background(), &CompletionRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "a;b", resp.Output)
+}
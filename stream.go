@@ -1,8 +1,11 @@
 package llm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"slices"
 )
 
 // StreamChunkType defines the type of chunk in the API stream
@@ -12,8 +15,31 @@ const (
 	TextChunkType      StreamChunkType = "text"
 	ReasoningChunkType StreamChunkType = "reasoning"
 	UsageChunkType     StreamChunkType = "usage"
+	CitationChunkType  StreamChunkType = "citation"
+	ErrorChunkType     StreamChunkType = "error"
+	WebSearchChunkType StreamChunkType = "web_search"
+	JSONChunkType      StreamChunkType = "json"
+	FinishChunkType    StreamChunkType = "finish"
+	LogprobChunkType   StreamChunkType = "logprob"
 )
 
+// WithStreamEvents limits a stream to chunks of the given types, e.g. only TextChunkType for
+// consumers that just render text. Providers skip the other chunks instead of sending them.
+// Error chunks are always sent, and usage chunks still require WithUsage.
+func WithStreamEvents(types ...StreamChunkType) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.StreamEvents = types
+	}
+}
+
+// StreamsEvent reports whether chunks of the given type should be sent on a stream
+func (o *CompletionOptions) StreamsEvent(t StreamChunkType) bool {
+	if o == nil || o.StreamEvents == nil || t == ErrorChunkType {
+		return true
+	}
+	return slices.Contains(o.StreamEvents, t)
+}
+
 // StreamChunk is the interface for all types of chunks in the API stream
 type StreamChunk interface {
 	Type() StreamChunkType
@@ -50,10 +76,131 @@ func (c StreamReasoningChunk) String() string {
 	return c.Reasoning
 }
 
+// StreamCitationChunk represents a source citation in the API stream. It is sent as soon as
+// the provider reports the citation, so UIs can render footnote markers while text streams.
+type StreamCitationChunk struct {
+	// Index is the footnote number of the source, stable across repeated citations of the same URL
+	Index int `json:"index"`
+	// URL of the cited source
+	URL string `json:"url"`
+	// Title of the cited source, if known
+	Title string `json:"title,omitempty"`
+	// Offset is the character offset in the streamed text where the citation marker belongs
+	Offset int `json:"offset"`
+}
+
+// Type returns the type of the chunk
+func (c StreamCitationChunk) Type() StreamChunkType {
+	return CitationChunkType
+}
+
+func (c StreamCitationChunk) String() string {
+	return fmt.Sprintf("[%d] %s", c.Index, c.URL)
+}
+
+// StreamErrorChunk reports an error that ended a stream after it was opened. It is always
+// the last chunk of the stream, so consumers can tell a failed stream from model output.
+type StreamErrorChunk struct {
+	// Provider is the name of the provider that reported the error
+	Provider string `json:"provider"`
+	// Err is the underlying error
+	Err error `json:"-"`
+}
+
+// Type returns the type of the chunk
+func (c StreamErrorChunk) Type() StreamChunkType {
+	return ErrorChunkType
+}
+
+func (c StreamErrorChunk) String() string {
+	return c.Error()
+}
+
+// Error returns the error message, so the chunk can be returned as an error
+func (c StreamErrorChunk) Error() string {
+	return fmt.Sprintf("%s stream error: %v", c.Provider, c.Err)
+}
+
+func (c StreamErrorChunk) Unwrap() error {
+	return c.Err
+}
+
+// SendStreamCanceled ends a stream of provider whose ctx is done with a StreamErrorChunk
+// holding ctx.Err(), so errors.Is(chunk, context.Canceled) tells a canceled stream from a
+// finished one. A consumer that canceled ctx may have stopped reading, so the chunk is dropped
+// instead of blocking when ch is full. It does nothing while ctx is not done, so providers
+// defer it before closing ch.
+func SendStreamCanceled(ctx context.Context, ch chan<- StreamChunk, provider string) {
+	if ctx.Err() == nil {
+		return
+	}
+	select {
+	case ch <- StreamErrorChunk{Provider: provider, Err: ctx.Err()}:
+	default:
+	}
+}
+
+// CloseOnDone closes c, such as the body of a provider's event stream, as soon as ctx is
+// done, so a read blocked on the connection returns at once instead of consuming the rest of
+// the response. The returned function closes c if ctx is not done yet; providers defer it
+// when the stream ends.
+func CloseOnDone(ctx context.Context, c io.Closer) func() {
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	return func() {
+		if stop() {
+			c.Close()
+		}
+	}
+}
+
+// StreamLogprobChunk carries the log probabilities of the tokens of the text chunk sent before
+// it, when requested with WithTopLogprobs
+type StreamLogprobChunk struct {
+	Logprobs []TokenLogprob `json:"logprobs"`
+}
+
+// Type returns the type of the chunk
+func (c StreamLogprobChunk) Type() StreamChunkType {
+	return LogprobChunkType
+}
+
+func (c StreamLogprobChunk) String() string {
+	return fmt.Sprintf("logprobs: %d tokens", len(c.Logprobs))
+}
+
+// StreamFinishChunk reports why a stream ended. Providers send it once the model stops
+// generating, before the usage chunk.
+type StreamFinishChunk struct {
+	// Reason is why the model stopped generating; empty when the provider does not report it
+	Reason FinishReason `json:"reason,omitempty"`
+	// Refusal is the text of a refusal the provider sent apart from the output
+	Refusal string `json:"refusal,omitempty"`
+	// ID is the provider's identifier of the response
+	ID string `json:"id,omitempty"`
+}
+
+// Type returns the type of the chunk
+func (c StreamFinishChunk) Type() StreamChunkType {
+	return FinishChunkType
+}
+
+func (c StreamFinishChunk) String() string {
+	return fmt.Sprintf("finish: %s", c.Reason)
+}
+
+// apply sets the finish reason, refusal and ID of resp
+func (c StreamFinishChunk) apply(resp *CompletionResponse) {
+	resp.FinishReason, resp.Refusal, resp.ID = c.Reason, c.Refusal, c.ID
+}
+
 // StreamUsageChunk represents a outputExample information chunk in the API stream
 type StreamUsageChunk struct {
 	Usage *TokenUsage
 	Cost  *float64
+	// CostBreakdown splits Cost by what was billed, see CompletionResponse.CostBreakdown
+	CostBreakdown *CostBreakdown
+	// Metadata is set by middlewares that collect response metadata, e.g. FallbackCompletionModel
+	Metadata *ResponseMetadata
 }
 
 // Type returns the type of the chunk
@@ -0,0 +1,256 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+)
+
+// ArraySchema returns the JSON schema of an object whose field holds an array of T. Structured
+// outputs require an object at the top level, so long lists are requested with
+// WithJSONSchema(ArraySchema[T]("items")) and read with StreamArray.
+func ArraySchema[T any](field string) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			field: map[string]any{"type": "array", "items": GenerateSchema[T]()},
+		},
+		"required":             []string{field},
+		"additionalProperties": false,
+	}
+}
+
+// ArrayStream reads the elements of a JSON array from a stream, see StreamArray
+type ArrayStream[T any] struct {
+	// Usage and Cost are set once the stream reports them, which is after the last element
+	Usage *TokenUsage
+	Cost  *float64
+
+	ctx    context.Context
+	stream StreamCompletionResponse
+}
+
+// StreamArray reads the elements of the first JSON array in stream, such as a response
+// requested with ArraySchema, as soon as each of them is complete. Text before the array, such
+// as a Markdown fence or the opening of the enclosing object, is skipped.
+func StreamArray[T any](ctx context.Context, stream StreamCompletionResponse) *ArrayStream[T] {
+	return &ArrayStream[T]{ctx: ctx, stream: stream}
+}
+
+// Items yields every element of the array decoded into T, so downstream processing can start
+// before the response ends. Elements that do not match the schema of T, with unknown or missing
+// required fields, are yielded with a ValidationError and the iteration continues. Stream
+// errors, a canceled context, or a stream that ends before the array does, are yielded as the
+// last error.
+func (s *ArrayStream[T]) Items() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		scanner := &arrayScanner{elemStart: -1}
+		index := 0
+		for {
+			select {
+			case chunk, ok := <-s.stream:
+				if !ok {
+					if !scanner.done {
+						yield(zero, fmt.Errorf("stream ended before the JSON array was complete"))
+					}
+					return
+				}
+				switch c := ownText(chunk).(type) {
+				case StreamTextChunk:
+					if scanner.done {
+						continue
+					}
+					for _, element := range scanner.write(c.Text) {
+						item, err := decodeArrayItem[T](element, index)
+						index++
+						if !yield(item, err) {
+							return
+						}
+					}
+				case StreamUsageChunk:
+					s.Usage, s.Cost = c.Usage, c.Cost
+				case StreamErrorChunk:
+					yield(zero, c)
+					return
+				}
+			case <-s.ctx.Done():
+				yield(zero, s.ctx.Err())
+				return
+			}
+		}
+	}
+}
+
+// arrayScanner splits streamed JSON text into the elements of its first array
+type arrayScanner struct {
+	buf        []byte
+	depth      int // open containers
+	arrayDepth int // depth inside the streamed array, 0 until it starts
+	inString   bool
+	escape     bool
+	elemStart  int // start of the element being read in buf, -1 between elements
+	done       bool
+}
+
+// write scans text and returns the elements it completed. Scanned text that no longer belongs
+// to an element is dropped, so memory stays bounded by the largest element.
+func (s *arrayScanner) write(text string) [][]byte {
+	var elements [][]byte
+	pos := len(s.buf)
+	s.buf = append(s.buf, text...)
+	emit := func(end int) {
+		elements = append(elements, bytes.TrimSpace(bytes.Clone(s.buf[s.elemStart:end])))
+		s.elemStart = -1
+	}
+
+	for ; pos < len(s.buf) && !s.done; pos++ {
+		c := s.buf[pos]
+		if s.inString {
+			switch {
+			case s.escape:
+				s.escape = false
+			case c == '\\':
+				s.escape = true
+			case c == '"':
+				s.inString = false
+				if s.depth == s.arrayDepth {
+					emit(pos + 1)
+				}
+			}
+			continue
+		}
+
+		if s.arrayDepth == 0 {
+			switch c {
+			case '{':
+				s.depth++
+			case '}':
+				s.depth--
+			case '[':
+				s.depth++
+				s.arrayDepth = s.depth
+			case '"':
+				// Strings are only skipped inside the JSON, not in text before it
+				s.inString = s.depth > 0
+			}
+			continue
+		}
+
+		if s.depth > s.arrayDepth {
+			switch c {
+			case '{', '[':
+				s.depth++
+			case '}', ']':
+				s.depth--
+				if s.depth == s.arrayDepth {
+					emit(pos + 1)
+				}
+			case '"':
+				s.inString = true
+			}
+			continue
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		case ',':
+			if s.elemStart >= 0 {
+				emit(pos)
+			}
+		case ']':
+			if s.elemStart >= 0 {
+				emit(pos)
+			}
+			s.done = true
+		case '{', '[':
+			s.elemStart = pos
+			s.depth++
+		case '"':
+			s.elemStart = pos
+			s.inString = true
+		default:
+			if s.elemStart < 0 {
+				s.elemStart = pos
+			}
+		}
+	}
+
+	// Keep only the element being read
+	if s.elemStart >= 0 {
+		s.buf = append(s.buf[:0], s.buf[s.elemStart:]...)
+		s.elemStart = 0
+	} else {
+		s.buf = s.buf[:0]
+	}
+	return elements
+}
+
+// decodeArrayItem decodes the element at index into T, rejecting the fields the schema of T
+// does not allow and reporting the required ones that are missing
+func decodeArrayItem[T any](data []byte, index int) (T, error) {
+	var item T
+	field := fmt.Sprintf("items[%d]", index)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&item); err != nil {
+		return item, NewValidationError(field, err.Error(), string(data))
+	}
+
+	t := reflect.TypeOf(item)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return item, nil
+	}
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(data, &present); err != nil {
+		return item, NewValidationError(field, err.Error(), string(data))
+	}
+	for _, name := range requiredFields(t) {
+		if _, ok := present[name]; !ok {
+			return item, NewValidationError(field+"."+name, "is required", nil)
+		}
+	}
+	return item, nil
+}
+
+// requiredFields returns the JSON names of the fields of struct type t that GenerateSchema
+// marks as required: the exported fields without omitempty, including those of embedded structs
+func requiredFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				names = append(names, requiredFields(embedded)...)
+			}
+			continue
+		}
+		if strings.Contains(","+options+",", ",omitempty,") || strings.Contains(","+options+",", ",omitzero,") {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
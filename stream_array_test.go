@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type arrayRecord struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	Note  string `json:"note,omitempty"`
+}
+
+// textStream streams text in chunks of size, followed by the extra chunks
+func textStream(text string, size int, extra ...StreamChunk) StreamCompletionResponse {
+	stream := make(chan StreamChunk, len(text)/size+len(extra)+1)
+	for i := 0; i < len(text); i += size {
+		stream <- StreamTextChunk{Text: text[i:min(i+size, len(text))]}
+	}
+	for _, chunk := range extra {
+		stream <- chunk
+	}
+	close(stream)
+	return stream
+}
+
+func TestArrayScanner(t *testing.T) {
+	scanner := &arrayScanner{elemStart: -1}
+	var elements []string
+	text := "```json\n{\"title\": \"a [b]\", \"items\": [1, \"x,\\\"]\", {\"a\": [1, {\"b\": \"}\"}]}, [2, 3], true ] }"
+	for i := 0; i < len(text); i += 3 {
+		for _, element := range scanner.write(text[i:min(i+3, len(text))]) {
+			elements = append(elements, string(element))
+		}
+	}
+	assert.Equal(t, []string{`1`, `"x,\"]"`, `{"a": [1, {"b": "}"}]}`, `[2, 3]`, `true`}, elements)
+	assert.True(t, scanner.done)
+	assert.Empty(t, scanner.buf)
+}
+
+func TestStreamArray(t *testing.T) {
+	text := `{"items": [{"name": "a", "score": 1}, {"name": "b", "score": 2, "note": "ok"}, {"name": "c"}, {"name": "d", "score": 4, "extra": 1}, {"name": "e", "score": 5}]}`
+	cost := 0.01
+	stream := textStream(text, 5, StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 60}, Cost: &cost})
+
+	records := StreamArray[arrayRecord](context.Background(), stream)
+	var names []string
+	var errs []error
+	for record, err := range records.Items() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		names = append(names, record.Name)
+	}
+	assert.Equal(t, []string{"a", "b", "e"}, names)
+	require.Len(t, errs, 2)
+	var validationErr *ValidationError
+	require.ErrorAs(t, errs[0], &validationErr)
+	assert.Equal(t, "items[2].score", validationErr.Field)
+	require.ErrorAs(t, errs[1], &validationErr)
+	assert.Equal(t, "items[3]", validationErr.Field)
+	require.NotNil(t, records.Usage)
+	assert.Equal(t, 0.01, *records.Cost)
+}
+
+func TestStreamArray_Errors(t *testing.T) {
+	t.Run("incomplete", func(t *testing.T) {
+		var errs []error
+		for _, err := range StreamArray[arrayRecord](context.Background(), textStream(`[{"name": "a", "score": 1}, {"na`, 4)).Items() {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 2)
+		assert.NoError(t, errs[0])
+		assert.ErrorContains(t, errs[1], "stream ended before the JSON array was complete")
+	})
+
+	t.Run("stream error", func(t *testing.T) {
+		stream := textStream(`[{"name": "a", "score": 1}, `, 4, StreamErrorChunk{Provider: "test", Err: errors.New("connection reset")})
+		var last error
+		for _, err := range StreamArray[arrayRecord](context.Background(), stream).Items() {
+			last = err
+		}
+		assert.ErrorContains(t, last, "connection reset")
+	})
+
+	t.Run("break", func(t *testing.T) {
+		count := 0
+		for range StreamArray[int](context.Background(), textStream(`[1, 2, 3]`, 1)).Items() {
+			count++
+			break
+		}
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var last error
+		for _, err := range StreamArray[int](ctx, make(chan StreamChunk)).Items() {
+			last = err
+		}
+		assert.ErrorIs(t, last, context.Canceled)
+	})
+}
+
+func TestArraySchema(t *testing.T) {
+	schema := ArraySchema[arrayRecord]("records")
+	assert.Equal(t, []string{"records"}, schema["required"])
+	properties := schema["properties"].(map[string]any)
+	assert.Equal(t, "array", properties["records"].(map[string]any)["type"])
+	assert.Equal(t, []string{"name", "score"}, requiredFields(reflect.TypeOf(arrayRecord{})))
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FlushSentences regroups the text chunks of a stream into whole sentences for speech
+// synthesis, while guaranteeing that no text waits longer than maxDelay. When the model
+// stalls mid-sentence, the buffered text is flushed up to its last word boundary, or
+// entirely if it holds a single word, so audio synthesis never starves. Other chunks are
+// passed through in order, after the text buffered before them. The returned stream closes
+// when the input stream closes or ctx is done.
+func FlushSentences(ctx context.Context, stream StreamCompletionResponse, maxDelay time.Duration) StreamCompletionResponse {
+	out := make(chan StreamChunk, 1)
+
+	go func() {
+		defer close(out)
+
+		var buf strings.Builder
+		timer := time.NewTimer(maxDelay)
+		timer.Stop()
+		defer timer.Stop()
+
+		send := func(chunk StreamChunk) bool {
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		// flush sends the first n bytes of the buffer and restarts the deadline for the rest
+		flush := func(n int) bool {
+			if n == 0 {
+				return true
+			}
+			text := buf.String()
+			buf.Reset()
+			buf.WriteString(text[n:])
+			timer.Stop()
+			if buf.Len() > 0 {
+				timer.Reset(maxDelay)
+			}
+			return send(StreamTextChunk{Text: text[:n]})
+		}
+
+		for {
+			select {
+			case chunk, ok := <-stream:
+				if !ok {
+					flush(buf.Len())
+					return
+				}
+				text, isText := ownText(chunk).(StreamTextChunk)
+				if !isText {
+					if !flush(buf.Len()) || !send(chunk) {
+						return
+					}
+					continue
+				}
+				if buf.Len() == 0 && text.Text != "" {
+					timer.Reset(maxDelay)
+				}
+				buf.WriteString(text.Text)
+				if !flush(sentenceEnd(buf.String())) {
+					return
+				}
+			case <-timer.C:
+				if !flush(partialEnd(buf.String())) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// sentenceEnd returns the length of the complete sentences at the start of text, including
+// the whitespace that follows them. A terminator only ends a sentence once the next character
+// is known, so "3.14" is not split.
+func sentenceEnd(text string) int {
+	end := 0
+	for i, r := range text {
+		switch {
+		case r == '\n':
+			end = i + 1
+		case r == '。' || r == '！' || r == '？':
+			end = i + utf8.RuneLen(r)
+		case unicode.IsSpace(r) && i > 0:
+			prev, _ := utf8.DecodeLastRuneInString(text[:i])
+			if prev == '.' || prev == '!' || prev == '?' || prev == '…' {
+				end = i + utf8.RuneLen(r)
+			}
+		}
+	}
+	return end
+}
+
+// partialEnd returns the length of text up to and including its last whitespace, or the
+// whole text if it has none
+func partialEnd(text string) int {
+	if i := strings.LastIndexFunc(text, unicode.IsSpace); i >= 0 {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		return i + size
+	}
+	return len(text)
+}
@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collectText(t *testing.T, stream StreamCompletionResponse) []string {
+	t.Helper()
+	var texts []string
+	for chunk := range stream {
+		texts = append(texts, chunk.String())
+	}
+	return texts
+}
+
+func TestFlushSentences_GroupsSentences(t *testing.T) {
+	in := make(chan StreamChunk, 8)
+	for _, text := range []string{"Hello", " there. How", " are you? Pi is 3.", "14 today.", " Bye"} {
+		in <- StreamTextChunk{Text: text}
+	}
+	close(in)
+
+	out := FlushSentences(context.Background(), in, time.Minute)
+	assert.Equal(t, []string{"Hello there. ", "How are you? ", "Pi is 3.14 today. ", "Bye"}, collectText(t, out))
+}
+
+func TestFlushSentences_FlushesPartialOnStall(t *testing.T) {
+	in := make(chan StreamChunk)
+	out := FlushSentences(context.Background(), in, 20*time.Millisecond)
+
+	in <- StreamTextChunk{Text: "The quick brown fo"}
+	select {
+	case chunk := <-out:
+		assert.Equal(t, "The quick brown ", chunk.String())
+	case <-time.After(time.Second):
+		t.Fatal("partial sentence was not flushed")
+	}
+
+	select {
+	case chunk := <-out:
+		assert.Equal(t, "fo", chunk.String())
+	case <-time.After(time.Second):
+		t.Fatal("single word was not flushed")
+	}
+
+	in <- StreamTextChunk{Text: "x."}
+	close(in)
+	assert.Equal(t, []string{"x."}, collectText(t, out))
+}
+
+func TestFlushSentences_PassesThroughOtherChunks(t *testing.T) {
+	in := make(chan StreamChunk, 4)
+	in <- StreamTextChunk{Text: "Partial"}
+	in <- StreamUsageChunk{Usage: &TokenUsage{}}
+	in <- StreamTextChunk{Text: " rest"}
+	close(in)
+
+	var chunks []StreamChunk
+	for chunk := range FlushSentences(context.Background(), in, time.Minute) {
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, 3)
+	assert.Equal(t, StreamTextChunk{Text: "Partial"}, chunks[0])
+	assert.Equal(t, UsageChunkType, chunks[1].Type())
+	assert.Equal(t, StreamTextChunk{Text: " rest"}, chunks[2])
+}
+
+func TestFlushSentences_StopsOnContextCancel(t *testing.T) {
+	in := make(chan StreamChunk)
+	ctx, cancel := context.WithCancel(context.Background())
+	out := FlushSentences(ctx, in, time.Minute)
+	cancel()
+
+	select {
+	case _, ok := <-out:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("stream was not closed on cancel")
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StreamJSONChunk is a change to the JSON value being streamed, see StreamJSON. Every value
+// starts with a chunk holding its initial Value: an empty object, array or string, or the
+// whole number, boolean or null. Strings then grow by their Delta, and objects, arrays and
+// strings end with a chunk marked Complete.
+type StreamJSONChunk struct {
+	// Path is the JSON Pointer (RFC 6901) of the value, "" for the root
+	Path string `json:"path"`
+	// Value is the initial value when the value starts
+	Value any `json:"value,omitempty"`
+	// Delta is text appended to the string at Path
+	Delta string `json:"delta,omitempty"`
+	// Complete reports that the value at Path is fully received
+	Complete bool `json:"complete,omitempty"`
+	// Partial is the object received so far, repaired into valid JSON, without the number or
+	// literal being streamed. It is set on the last JSON chunk of every text chunk when the
+	// root is an object.
+	Partial json.RawMessage `json:"partial,omitempty"`
+}
+
+// Type returns the type of the chunk
+func (c StreamJSONChunk) Type() StreamChunkType {
+	return JSONChunkType
+}
+
+func (c StreamJSONChunk) String() string {
+	if c.Delta != "" {
+		return c.Path + " += " + strconv.Quote(c.Delta)
+	}
+	value, _ := json.Marshal(c.Value)
+	return c.Path + " = " + string(value)
+}
+
+// StreamJSON parses the text of stream, e.g. a completion requested with WithJSONSchema, as it
+// arrives and sends the StreamJSONChunk changes of every text chunk after it, so UIs can render
+// structured output without buffering the whole response. Text before the root object or
+// array, such as a Markdown fence, and after it is ignored. Invalid JSON ends the stream with
+// a StreamErrorChunk. Other chunks are passed through.
+func StreamJSON(ctx context.Context, stream StreamCompletionResponse) StreamCompletionResponse {
+	out := make(chan StreamChunk, 1)
+
+	go func() {
+		defer close(out)
+
+		send := func(chunk StreamChunk) bool {
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		parser := &jsonDeltaParser{}
+		for {
+			select {
+			case chunk, ok := <-stream:
+				if !ok {
+					return
+				}
+				// The text is read before the chunk is sent on, since the consumer may release it
+				text, isText := StreamChunkText(chunk)
+				if !send(chunk) {
+					return
+				}
+				if !isText {
+					continue
+				}
+				deltas, err := parser.write(text)
+				for _, delta := range deltas {
+					if !send(delta) {
+						return
+					}
+				}
+				if err != nil {
+					send(StreamErrorChunk{Provider: "json", Err: err})
+					// The error chunk ends the stream; drain the rest so the model can finish
+					go func() {
+						for range stream {
+						}
+					}()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// jsonFrame is an object or array being parsed
+type jsonFrame struct {
+	object    bool
+	key       string // key of the current member of an object
+	index     int    // index of the current element of an array
+	expectKey bool
+}
+
+// jsonDeltaParser incrementally parses a JSON document into StreamJSONChunk changes
+type jsonDeltaParser struct {
+	text     strings.Builder // the document from its root, for repairing partial objects
+	started  bool
+	done     bool
+	root     byte
+	stack    []jsonFrame
+	inString bool
+	isKey    bool
+	escape   bool
+	raw      []byte // string content not sent yet, or the key being read
+	scalar   []byte // number or literal being read
+	offset   int
+	deltas   []StreamJSONChunk
+}
+
+// write parses text and returns the changes it completed
+func (p *jsonDeltaParser) write(text string) ([]StreamJSONChunk, error) {
+	p.deltas = nil
+	for i := 0; i < len(text) && !p.done; i++ {
+		if p.started {
+			p.text.WriteByte(text[i])
+		}
+		if err := p.next(text[i]); err != nil {
+			return p.deltas, err
+		}
+		p.offset++
+	}
+	if p.inString && !p.isKey {
+		if err := p.flushString(false); err != nil {
+			return p.deltas, err
+		}
+	}
+	if len(p.deltas) > 0 && p.root == '{' {
+		if repaired, _, _ := repairPartialJSON(p.text.String()); repaired != "" {
+			p.deltas[len(p.deltas)-1].Partial = json.RawMessage(repaired)
+		}
+	}
+	return p.deltas, nil
+}
+
+// next parses byte c of the document
+func (p *jsonDeltaParser) next(c byte) error {
+	if !p.started {
+		if c == '{' || c == '[' {
+			p.started, p.root = true, c
+			p.text.WriteByte(c)
+			p.open(c)
+		}
+		return nil
+	}
+	if p.inString {
+		switch {
+		case p.escape:
+			p.escape = false
+		case c == '\\':
+			p.escape = true
+		case c == '"':
+			p.inString = false
+			return p.closeString()
+		}
+		p.raw = append(p.raw, c)
+		return nil
+	}
+	if len(p.scalar) > 0 {
+		if !strings.ContainsRune(",}] \t\r\n", rune(c)) {
+			p.scalar = append(p.scalar, c)
+			return nil
+		}
+		if err := p.closeScalar(); err != nil {
+			return err
+		}
+	}
+
+	top := &p.stack[len(p.stack)-1]
+	switch c {
+	case ' ', '\t', '\r', '\n':
+	case ':':
+		if !top.object || top.expectKey {
+			return p.syntaxError(c)
+		}
+	case ',':
+		if top.object {
+			top.expectKey = true
+		}
+	case '}', ']':
+		if top.object != (c == '}') {
+			return p.syntaxError(c)
+		}
+		p.stack = p.stack[:len(p.stack)-1]
+		p.emit(StreamJSONChunk{Path: p.path(), Complete: true})
+		p.done = len(p.stack) == 0
+	case '"':
+		p.inString, p.raw = true, p.raw[:0]
+		p.isKey = top.object && top.expectKey
+		if p.isKey {
+			top.expectKey = false
+			return nil
+		}
+		p.beginValue()
+		p.emit(StreamJSONChunk{Path: p.path(), Value: ""})
+	case '{', '[':
+		if top.object && top.expectKey {
+			return p.syntaxError(c)
+		}
+		p.beginValue()
+		p.open(c)
+	default:
+		if !strings.ContainsRune("-0123456789tfn", rune(c)) || (top.object && top.expectKey) {
+			return p.syntaxError(c)
+		}
+		p.beginValue()
+		p.scalar = append(p.scalar[:0], c)
+	}
+	return nil
+}
+
+// open starts an object or array at the current path
+func (p *jsonDeltaParser) open(c byte) {
+	if c == '{' {
+		p.emit(StreamJSONChunk{Path: p.path(), Value: map[string]any{}})
+	} else {
+		p.emit(StreamJSONChunk{Path: p.path(), Value: []any{}})
+	}
+	p.stack = append(p.stack, jsonFrame{object: c == '{', index: -1, expectKey: c == '{'})
+}
+
+// beginValue moves an array to its next element
+func (p *jsonDeltaParser) beginValue() {
+	if top := &p.stack[len(p.stack)-1]; !top.object {
+		top.index++
+	}
+}
+
+// closeString ends the key or string value being read
+func (p *jsonDeltaParser) closeString() error {
+	if !p.isKey {
+		return p.flushString(true)
+	}
+	var key string
+	if err := json.Unmarshal(append(append([]byte{'"'}, p.raw...), '"'), &key); err != nil {
+		return fmt.Errorf("invalid JSON key at offset %d: %w", p.offset, err)
+	}
+	p.stack[len(p.stack)-1].key = key
+	return nil
+}
+
+// flushString sends the decodable text of the string being read as a delta
+func (p *jsonDeltaParser) flushString(complete bool) error {
+	n := len(p.raw)
+	if !complete {
+		n = decodableLength(p.raw)
+	}
+	var delta string
+	if n > 0 {
+		if err := json.Unmarshal(append(append([]byte{'"'}, p.raw[:n]...), '"'), &delta); err != nil {
+			return fmt.Errorf("invalid JSON string at offset %d: %w", p.offset, err)
+		}
+	}
+	p.raw = append(p.raw[:0], p.raw[n:]...)
+	if delta != "" {
+		p.emit(StreamJSONChunk{Path: p.path(), Delta: delta})
+	}
+	if complete {
+		p.emit(StreamJSONChunk{Path: p.path(), Complete: true})
+	}
+	return nil
+}
+
+// closeScalar ends the number or literal being read
+func (p *jsonDeltaParser) closeScalar() error {
+	var value any
+	if err := json.Unmarshal(p.scalar, &value); err != nil {
+		return fmt.Errorf("invalid JSON value %q at offset %d", p.scalar, p.offset)
+	}
+	p.scalar = p.scalar[:0]
+	p.emit(StreamJSONChunk{Path: p.path(), Value: value, Complete: true})
+	return nil
+}
+
+func (p *jsonDeltaParser) emit(chunk StreamJSONChunk) {
+	p.deltas = append(p.deltas, chunk)
+}
+
+func (p *jsonDeltaParser) syntaxError(c byte) error {
+	return fmt.Errorf("invalid JSON: unexpected %q at offset %d", c, p.offset)
+}
+
+// path returns the JSON Pointer of the current value
+func (p *jsonDeltaParser) path() string {
+	var b strings.Builder
+	for _, frame := range p.stack {
+		b.WriteByte('/')
+		if frame.object {
+			b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(frame.key))
+		} else {
+			b.WriteString(strconv.Itoa(frame.index))
+		}
+	}
+	return b.String()
+}
+
+// decodableLength returns the length of the longest prefix of the raw content of a JSON
+// string that does not end within an escape sequence, a surrogate pair or a UTF-8 character
+func decodableLength(raw []byte) int {
+	safe := 0
+	for i := 0; i < len(raw); {
+		switch c := raw[i]; {
+		case c == '\\':
+			if i+1 >= len(raw) {
+				return safe
+			}
+			if raw[i+1] != 'u' {
+				i += 2
+				break
+			}
+			if i+6 > len(raw) {
+				return safe
+			}
+			i += 6
+			// A high surrogate is decoded together with the low surrogate escape after it
+			if r, err := strconv.ParseUint(string(raw[i-4:i]), 16, 16); err == nil && r >= 0xD800 && r < 0xDC00 {
+				if i+2 > len(raw) {
+					return safe
+				}
+				if raw[i] == '\\' && raw[i+1] == 'u' {
+					if i+6 > len(raw) {
+						return safe
+					}
+					i += 6
+				}
+			}
+		case c < utf8.RuneSelf:
+			i++
+		default:
+			if !utf8.FullRune(raw[i:]) {
+				return safe
+			}
+			_, size := utf8.DecodeRune(raw[i:])
+			i += size
+		}
+		safe = i
+	}
+	return safe
+}
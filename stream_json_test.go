@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectJSON returns the JSON chunks of stream as strings, the last Partial and the error
+func collectJSON(stream StreamCompletionResponse) (changes []string, partial string, err error) {
+	for chunk := range stream {
+		switch c := chunk.(type) {
+		case StreamJSONChunk:
+			change := c.String()
+			if c.Complete {
+				change += " done"
+			}
+			changes = append(changes, change)
+			if c.Partial != nil {
+				partial = string(c.Partial)
+			}
+		case StreamErrorChunk:
+			err = c.Err
+		}
+	}
+	return changes, partial, err
+}
+
+func TestStreamJSON(t *testing.T) {
+	text := "```json\n{\"name\": \"Ada \\\"L\\\" \\u00e9\\ud83d\\ude00\", \"age\": -36.5, \"tags\": [\"a\", true, null], \"a/b~\": {}}\n```"
+	for _, size := range []int{1, 3, 7, len(text)} {
+		changes, partial, err := collectJSON(StreamJSON(context.Background(), textStream(text, size)))
+		require.NoError(t, err)
+
+		// The name is split into deltas depending on the chunk size; join them
+		var name string
+		var rest []string
+		for _, change := range changes {
+			if delta, ok := strings.CutPrefix(change, "/name += "); ok {
+				unquoted, err := strconv.Unquote(delta)
+				require.NoError(t, err)
+				name += unquoted
+				continue
+			}
+			rest = append(rest, change)
+		}
+		assert.Equal(t, `Ada "L" é😀`, name, "size %d", size)
+		assert.Equal(t, []string{
+			` = {}`,
+			`/name = ""`,
+			`/name = null done`,
+			`/age = -36.5 done`,
+			`/tags = []`,
+			`/tags/0 = ""`,
+			`/tags/0 += "a"`,
+			`/tags/0 = null done`,
+			`/tags/1 = true done`,
+			`/tags/2 = null done`,
+			`/tags = null done`,
+			`/a~1b~0 = {}`,
+			`/a~1b~0 = null done`,
+			` = null done`,
+		}, rest, "size %d", size)
+		assert.JSONEq(t, `{"name": "Ada \"L\" é😀", "age": -36.5, "tags": ["a", true, null], "a/b~": {}}`, partial)
+	}
+}
+
+func TestStreamJSONDeltas(t *testing.T) {
+	// Deltas never split an escape sequence, a surrogate pair or a UTF-8 character
+	text := `{"s": "xé😀é\n"}`
+	changes, _, err := collectJSON(StreamJSON(context.Background(), textStream(text, 1)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		` = {}`,
+		`/s = ""`,
+		`/s += "x"`,
+		`/s += "é"`,
+		`/s += "😀"`,
+		`/s += "é"`,
+		`/s += "\n"`,
+		`/s = null done`,
+		` = null done`,
+	}, changes)
+}
+
+func TestStreamJSONPartial(t *testing.T) {
+	stream := make(chan StreamChunk, 2)
+	stream <- StreamTextChunk{Text: `{"title": "Hel`}
+	stream <- StreamTextChunk{Text: `lo", "items": [1, 2`}
+	close(stream)
+
+	var partials []string
+	for chunk := range StreamJSON(context.Background(), stream) {
+		if c, ok := chunk.(StreamJSONChunk); ok && c.Partial != nil {
+			partials = append(partials, string(c.Partial))
+		}
+	}
+	require.Len(t, partials, 2)
+	assert.JSONEq(t, `{"title": "Hel"}`, partials[0])
+	// The number being streamed is left out until it ends
+	assert.JSONEq(t, `{"title": "Hello", "items": [1]}`, partials[1])
+}
+
+func TestStreamJSONArrayRoot(t *testing.T) {
+	changes, partial, err := collectJSON(StreamJSON(context.Background(), textStream(`[{"id": 1}, [2]]`, 4)))
+	require.NoError(t, err)
+	assert.Empty(t, partial)
+	assert.Equal(t, []string{
+		` = []`,
+		`/0 = {}`,
+		`/0/id = 1 done`,
+		`/0 = null done`,
+		`/1 = []`,
+		`/1/0 = 2 done`,
+		`/1 = null done`,
+		` = null done`,
+	}, changes)
+}
+
+func TestStreamJSONPassThrough(t *testing.T) {
+	stream := textStream(`{"a": 1}`, 8, StreamUsageChunk{Usage: &TokenUsage{TotalOutputTokens: 3}})
+	var types []StreamChunkType
+	for chunk := range StreamJSON(context.Background(), stream) {
+		types = append(types, chunk.Type())
+	}
+	assert.Equal(t, []StreamChunkType{TextChunkType, JSONChunkType, JSONChunkType, JSONChunkType, UsageChunkType}, types)
+}
+
+func TestStreamJSONInvalid(t *testing.T) {
+	for _, text := range []string{`{"a": 1]`, `{"a" 1, 2}`, `{"a": tru}`, `[1, {"b": x}]`} {
+		_, _, err := collectJSON(StreamJSON(context.Background(), textStream(text, 2)))
+		assert.ErrorContains(t, err, "invalid JSON", text)
+	}
+}
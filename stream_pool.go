@@ -0,0 +1,99 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"sync"
+)
+
+// StreamTextBytesChunk is a text chunk whose buffer is reused across chunks, sent instead of
+// StreamTextChunk by models created with WithPooledTextChunks. Sending a StreamTextChunk
+// allocates for the chunk itself; these chunks come from a pool, so a consumer that calls
+// Release after reading each chunk streams text without allocating per chunk.
+//
+// The consumer that receives the chunk owns it. Text must not be used after Release, and
+// middleware that forwards the chunk must not read it after sending it on. Chunks that are
+// never released are garbage collected.
+type StreamTextBytesChunk struct {
+	// Text contains the text content, valid until Release
+	Text []byte
+}
+
+// maxPooledTextSize is the capacity above which buffers are not returned to the pool, so a
+// single large chunk does not keep its memory alive
+const maxPooledTextSize = 64 << 10
+
+var textChunkPool = sync.Pool{
+	New: func() any {
+		return &StreamTextBytesChunk{Text: make([]byte, 0, 64)}
+	},
+}
+
+// NewStreamTextBytesChunk returns a pooled chunk holding a copy of text
+func NewStreamTextBytesChunk(text string) *StreamTextBytesChunk {
+	c := textChunkPool.Get().(*StreamTextBytesChunk)
+	c.Text = append(c.Text[:0], text...)
+	return c
+}
+
+// Type returns the type of the chunk
+func (c *StreamTextBytesChunk) Type() StreamChunkType {
+	return TextChunkType
+}
+
+func (c *StreamTextBytesChunk) String() string {
+	return string(c.Text)
+}
+
+// Release returns the chunk to the pool. The chunk must not be used afterwards.
+func (c *StreamTextBytesChunk) Release() {
+	if cap(c.Text) > maxPooledTextSize {
+		return
+	}
+	c.Text = c.Text[:0]
+	textChunkPool.Put(c)
+}
+
+// WithPooledTextChunks makes streams send text as *StreamTextBytesChunk instead of
+// StreamTextChunk, for relay servers where the allocation per chunk dominates. Consumers must
+// handle both chunk types, since middleware such as response caching may still send
+// StreamTextChunk. Supported by the OpenAI compatible, Anthropic and Cohere providers and the
+// mock provider; others keep sending StreamTextChunk.
+func WithPooledTextChunks(enabled bool) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.PooledTextChunks = &enabled
+	}
+}
+
+// TextChunk returns a chunk holding text: a pooled *StreamTextBytesChunk when requested with
+// WithPooledTextChunks, a StreamTextChunk otherwise
+func (o *CompletionOptions) TextChunk(text string) StreamChunk {
+	if o != nil && o.PooledTextChunks != nil && *o.PooledTextChunks {
+		return NewStreamTextBytesChunk(text)
+	}
+	return StreamTextChunk{Text: text}
+}
+
+// StreamChunkText returns the text of a StreamTextChunk or *StreamTextBytesChunk, copying the
+// text of the latter so it stays valid after the chunk is released
+func StreamChunkText(chunk StreamChunk) (string, bool) {
+	switch c := chunk.(type) {
+	case StreamTextChunk:
+		return c.Text, true
+	case *StreamTextBytesChunk:
+		return string(c.Text), true
+	}
+	return "", false
+}
+
+// ownText converts a *StreamTextBytesChunk that the caller consumes into a StreamTextChunk,
+// releasing it; other chunks are returned as is
+func ownText(chunk StreamChunk) StreamChunk {
+	if c, ok := chunk.(*StreamTextBytesChunk); ok {
+		text := StreamTextChunk{Text: string(c.Text)}
+		c.Release()
+		return text
+	}
+	return chunk
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamTextBytesChunk(t *testing.T) {
+	text := []byte("Hello")
+	c := NewStreamTextBytesChunk(string(text))
+	text[0] = 'J'
+	assert.Equal(t, "Hello", string(c.Text))
+	assert.Equal(t, TextChunkType, c.Type())
+	assert.Equal(t, "Hello", c.String())
+	c.Release()
+
+	reused := NewStreamTextBytesChunk("Hi")
+	assert.Equal(t, "Hi", string(reused.Text))
+	reused.Release()
+}
+
+func TestCompletionOptions_TextChunk(t *testing.T) {
+	var options *CompletionOptions
+	assert.Equal(t, StreamTextChunk{Text: "a"}, options.TextChunk("a"))
+	options = ApplyCompletionOptions([]CompletionOption{WithPooledTextChunks(false)})
+	assert.Equal(t, StreamTextChunk{Text: "a"}, options.TextChunk("a"))
+
+	options = ApplyCompletionOptions([]CompletionOption{WithPooledTextChunks(true)})
+	chunk := options.TextChunk("a")
+	require.IsType(t, &StreamTextBytesChunk{}, chunk)
+	text, ok := StreamChunkText(chunk)
+	assert.True(t, ok)
+	assert.Equal(t, "a", text)
+	_, ok = StreamChunkText(StreamUsageChunk{})
+	assert.False(t, ok)
+}
+
+// pooledStream sends texts as pooled chunks followed by extra
+func pooledStream(texts []string, extra ...StreamChunk) StreamCompletionResponse {
+	stream := make(chan StreamChunk, len(texts)+len(extra))
+	for _, text := range texts {
+		stream <- NewStreamTextBytesChunk(text)
+	}
+	for _, chunk := range extra {
+		stream <- chunk
+	}
+	close(stream)
+	return stream
+}
+
+func TestPooledTextChunks_Consumers(t *testing.T) {
+	ctx := context.Background()
+	resp, err := NewCompletionStream(ctx, pooledStream([]string{"Hel", "lo"}, StreamFinishChunk{Reason: FinishReasonStop})).Final()
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Output)
+
+	partial, err := BindStream[map[string]string](ctx, pooledStream([]string{`{"a":`, `"b"}`}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "b"}, partial.Value)
+
+	var items []int
+	for item, err := range StreamArray[int](ctx, pooledStream([]string{"[1,", "2]"})).Items() {
+		require.NoError(t, err)
+		items = append(items, item)
+	}
+	assert.Equal(t, []int{1, 2}, items)
+}
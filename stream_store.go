@@ -0,0 +1,347 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrStreamNotFound is returned when reading a stream that was not created or has expired
+	ErrStreamNotFound = errors.New("stream not found")
+
+	// ErrStreamExists is returned when creating a stream under an ID that is already used
+	ErrStreamExists = errors.New("stream already exists")
+)
+
+// StreamEvent is a chunk of a persisted stream
+type StreamEvent struct {
+	// Cursor is the position of the event in its stream, from 1. Clients resume a stream after
+	// the cursor of the last event they received.
+	Cursor int64           `json:"cursor"`
+	Type   StreamChunkType `json:"type"`
+	// Data is the chunk encoded as JSON
+	Data json.RawMessage `json:"data"`
+}
+
+// StreamStore persists the chunks of streams by request ID, so clients that reconnect, e.g.
+// after a page refresh, can read the rest of a stream that is still being generated. Stores
+// backed by an external system, e.g. Redis streams, let any server instance serve the client.
+type StreamStore interface {
+	// Create starts an empty stream under id, or returns ErrStreamExists
+	Create(ctx context.Context, id string) error
+	// Append adds events, in cursor order, to the end of stream id
+	Append(ctx context.Context, id string, events ...StreamEvent) error
+	// Close marks stream id complete after its last event
+	Close(ctx context.Context, id string) error
+	// Read returns the events of stream id after cursor, and whether the stream is complete.
+	// It blocks until there is an event after cursor, the stream is complete or ctx is done,
+	// and returns ErrStreamNotFound for unknown streams.
+	Read(ctx context.Context, id string, cursor int64) ([]StreamEvent, bool, error)
+}
+
+// NewStreamEvent encodes chunk as the event at cursor. The error of a StreamErrorChunk is
+// encoded as its message.
+func NewStreamEvent(cursor int64, chunk StreamChunk) (StreamEvent, error) {
+	chunk = ownText(chunk)
+	var value any = chunk
+	if c, ok := chunk.(StreamErrorChunk); ok {
+		value = streamErrorData{Provider: c.Provider, Error: fmt.Sprint(c.Err)}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return StreamEvent{}, fmt.Errorf("failed to encode %s chunk: %w", chunk.Type(), err)
+	}
+	return StreamEvent{Cursor: cursor, Type: chunk.Type(), Data: data}, nil
+}
+
+type streamErrorData struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error"`
+}
+
+// Chunk decodes the chunk of the event
+func (e StreamEvent) Chunk() (StreamChunk, error) {
+	var chunk StreamChunk
+	var err error
+	switch e.Type {
+	case TextChunkType:
+		chunk, err = decodeChunk[StreamTextChunk](e.Data)
+	case ReasoningChunkType:
+		chunk, err = decodeChunk[StreamReasoningChunk](e.Data)
+	case UsageChunkType:
+		chunk, err = decodeChunk[StreamUsageChunk](e.Data)
+	case CitationChunkType:
+		chunk, err = decodeChunk[StreamCitationChunk](e.Data)
+	case WebSearchChunkType:
+		chunk, err = decodeChunk[StreamWebSearchChunk](e.Data)
+	case JSONChunkType:
+		chunk, err = decodeChunk[StreamJSONChunk](e.Data)
+	case FinishChunkType:
+		chunk, err = decodeChunk[StreamFinishChunk](e.Data)
+	case LogprobChunkType:
+		chunk, err = decodeChunk[StreamLogprobChunk](e.Data)
+	case ErrorChunkType:
+		var data streamErrorData
+		if err = json.Unmarshal(e.Data, &data); err == nil {
+			chunk = StreamErrorChunk{Provider: data.Provider, Err: errors.New(data.Error)}
+		}
+	default:
+		return nil, fmt.Errorf("unknown stream chunk type %q", e.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s chunk: %w", e.Type, err)
+	}
+	return chunk, nil
+}
+
+func decodeChunk[T StreamChunk](data []byte) (StreamChunk, error) {
+	var chunk T
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+// PersistStream creates stream id in store and writes the chunks of stream to it as they
+// arrive, until stream ends. It returns once the stream is created, so clients can read it at
+// once with ResumeStream or ServeStream, and writes in the background: generation continues
+// when the client that started it disconnects, as long as the context of the request that
+// produced stream is not canceled, e.g. with context.WithoutCancel. The returned channel
+// receives nil once the stream is written and closed, or the store error that stopped it.
+func PersistStream(ctx context.Context, store StreamStore, id string, stream StreamCompletionResponse) (<-chan error, error) {
+	if err := store.Create(ctx, id); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		var cursor int64
+		for chunk := range stream {
+			cursor++
+			event, err := NewStreamEvent(cursor, chunk)
+			if err == nil {
+				err = store.Append(ctx, id, event)
+			}
+			if err != nil {
+				// Drain the stream so its producer does not block
+				for range stream {
+				}
+				store.Close(ctx, id)
+				done <- err
+				return
+			}
+		}
+		done <- store.Close(ctx, id)
+	}()
+	return done, nil
+}
+
+// ResumeStream returns the events of stream id after cursor, 0 for the whole stream, followed
+// by the rest of the events as they are persisted, until the stream is complete or ctx is
+// done. A store error ends the channel with an error event, whose Cursor is 0.
+func ResumeStream(ctx context.Context, store StreamStore, id string, cursor int64) <-chan StreamEvent {
+	out := make(chan StreamEvent, 1)
+	go func() {
+		defer close(out)
+		for {
+			events, complete, err := store.Read(ctx, id, cursor)
+			if err != nil {
+				if ctx.Err() == nil {
+					event, _ := NewStreamEvent(0, StreamErrorChunk{Provider: "stream_store", Err: err})
+					select {
+					case out <- event:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			for _, event := range events {
+				select {
+				case out <- event:
+					cursor = event.Cursor
+				case <-ctx.Done():
+					return
+				}
+			}
+			if complete {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// ServeStream writes stream id of store as server-sent events, one per chunk with its cursor
+// as event ID and its type as event name, until the stream is complete or the client
+// disconnects. Clients resume after the cursor of the Last-Event-ID header, which EventSource
+// sends when it reconnects, or of the cursor query parameter, e.g. after a page refresh.
+// Unknown streams are answered with 404 Not Found.
+func ServeStream(w http.ResponseWriter, r *http.Request, store StreamStore, id string) {
+	var cursor int64
+	if last := cmp.Or(r.Header.Get("Last-Event-ID"), r.URL.Query().Get("cursor")); last != "" {
+		var err error
+		if cursor, err = strconv.ParseInt(last, 10, 64); err != nil || cursor < 0 {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, _ := w.(http.Flusher)
+	started := false
+	for {
+		events, complete, err := store.Read(r.Context(), id, cursor)
+		if err != nil {
+			if !started {
+				if errors.Is(err, ErrStreamNotFound) {
+					http.Error(w, "stream not found", http.StatusNotFound)
+				} else if r.Context().Err() == nil {
+					http.Error(w, "failed to read stream", http.StatusInternalServerError)
+				}
+			}
+			return
+		}
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+		}
+		for _, event := range events {
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Cursor, event.Type, event.Data); err != nil {
+				return
+			}
+			cursor = event.Cursor
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if complete {
+			return
+		}
+	}
+}
+
+// MemoryStreamStore is an in-process StreamStore. Complete streams expire after a TTL.
+type MemoryStreamStore struct {
+	mu      sync.Mutex
+	streams map[string]*memoryStream
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+type memoryStream struct {
+	events   []StreamEvent
+	complete bool
+	closedAt time.Time
+	// changed is closed, and replaced, when events are appended or the stream is closed
+	changed chan struct{}
+}
+
+var _ StreamStore = (*MemoryStreamStore)(nil)
+
+// NewMemoryStreamStore creates an empty in-memory stream store keeping complete streams for
+// ttl, or until the process exits for a zero ttl
+func NewMemoryStreamStore(ttl time.Duration) *MemoryStreamStore {
+	return &MemoryStreamStore{streams: make(map[string]*memoryStream), ttl: ttl, now: time.Now}
+}
+
+func (s *MemoryStreamStore) Create(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evict()
+	if _, ok := s.streams[id]; ok {
+		return ErrStreamExists
+	}
+	s.streams[id] = &memoryStream{changed: make(chan struct{})}
+	return nil
+}
+
+func (s *MemoryStreamStore) Append(ctx context.Context, id string, events ...StreamEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.streams[id]
+	if !ok {
+		return ErrStreamNotFound
+	}
+	if stream.complete {
+		return fmt.Errorf("stream %s is complete", id)
+	}
+	stream.events = append(stream.events, events...)
+	stream.notify()
+	return nil
+}
+
+func (s *MemoryStreamStore) Close(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.streams[id]
+	if !ok {
+		return ErrStreamNotFound
+	}
+	if !stream.complete {
+		stream.complete, stream.closedAt = true, s.now()
+		stream.notify()
+	}
+	return nil
+}
+
+func (s *MemoryStreamStore) Read(ctx context.Context, id string, cursor int64) ([]StreamEvent, bool, error) {
+	for {
+		s.mu.Lock()
+		s.evict()
+		stream, ok := s.streams[id]
+		if !ok {
+			s.mu.Unlock()
+			return nil, false, ErrStreamNotFound
+		}
+		// Events are in cursor order, so the first after cursor is found by binary search
+		i, _ := slices.BinarySearchFunc(stream.events, cursor+1, func(e StreamEvent, target int64) int {
+			return cmp.Compare(e.Cursor, target)
+		})
+		if i < len(stream.events) || stream.complete {
+			events := append([]StreamEvent(nil), stream.events[i:]...)
+			complete := stream.complete
+			s.mu.Unlock()
+			return events, complete, nil
+		}
+		changed := stream.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// evict removes the complete streams past their TTL; s.mu must be held
+func (s *MemoryStreamStore) evict() {
+	if s.ttl <= 0 {
+		return
+	}
+	now := s.now()
+	for id, stream := range s.streams {
+		if stream.complete && now.Sub(stream.closedAt) >= s.ttl {
+			delete(s.streams, id)
+		}
+	}
+}
+
+// notify wakes the readers waiting for the stream to change
+func (m *memoryStream) notify() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
@@ -0,0 +1,179 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamEvent_Chunk(t *testing.T) {
+	cost := 0.5
+	for _, chunk := range []StreamChunk{
+		StreamTextChunk{Text: "Hello"},
+		StreamReasoningChunk{Reasoning: "thinking"},
+		StreamCitationChunk{Index: 1, URL: "https://example.com", Offset: 5},
+		StreamFinishChunk{Reason: FinishReasonStop, ID: "r1"},
+		StreamUsageChunk{Usage: &TokenUsage{TotalInputTokens: 10, TotalOutputTokens: 5}, Cost: &cost},
+		StreamLogprobChunk{Logprobs: []TokenLogprob{{Token: "Hello", Logprob: -0.1}}},
+	} {
+		event, err := NewStreamEvent(3, chunk)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), event.Cursor)
+		assert.Equal(t, chunk.Type(), event.Type)
+		decoded, err := event.Chunk()
+		require.NoError(t, err)
+		assert.Equal(t, chunk, decoded)
+	}
+
+	event, err := NewStreamEvent(1, StreamErrorChunk{Provider: "openai", Err: errors.New("overloaded")})
+	require.NoError(t, err)
+	decoded, err := event.Chunk()
+	require.NoError(t, err)
+	assert.EqualError(t, decoded.(StreamErrorChunk), "openai stream error: overloaded")
+
+	// Pooled text is copied before it is released
+	event, err = NewStreamEvent(1, NewStreamTextBytesChunk("pooled"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"text":"pooled"}`, string(event.Data))
+
+	_, err = StreamEvent{Type: "unknown"}.Chunk()
+	assert.Error(t, err)
+}
+
+func TestPersistStream_Resume(t *testing.T) {
+	store := NewMemoryStreamStore(time.Minute)
+	upstream := make(chan StreamChunk)
+	done, err := PersistStream(context.Background(), store, "req-1", upstream)
+	require.NoError(t, err)
+
+	_, err = PersistStream(context.Background(), store, "req-1", make(chan StreamChunk))
+	assert.ErrorIs(t, err, ErrStreamExists)
+
+	upstream <- StreamTextChunk{Text: "Hello"}
+	upstream <- StreamTextChunk{Text: " wor"}
+
+	// A client reads the first chunk, then disconnects while generation continues
+	ctx, cancel := context.WithCancel(context.Background())
+	first := <-ResumeStream(ctx, store, "req-1", 0)
+	cancel()
+	assert.Equal(t, int64(1), first.Cursor)
+
+	upstream <- StreamTextChunk{Text: "ld"}
+	upstream <- StreamFinishChunk{Reason: FinishReasonStop}
+	close(upstream)
+	require.NoError(t, <-done)
+
+	// The reconnecting client resumes after its last cursor
+	var text string
+	var cursors []int64
+	for event := range ResumeStream(context.Background(), store, "req-1", first.Cursor) {
+		cursors = append(cursors, event.Cursor)
+		chunk, err := event.Chunk()
+		require.NoError(t, err)
+		if c, ok := chunk.(StreamTextChunk); ok {
+			text += c.Text
+		}
+	}
+	assert.Equal(t, " world", text)
+	assert.Equal(t, []int64{2, 3, 4}, cursors)
+
+	var errEvent StreamEvent
+	for event := range ResumeStream(context.Background(), store, "missing", 0) {
+		errEvent = event
+	}
+	assert.Equal(t, ErrorChunkType, errEvent.Type)
+	assert.Equal(t, int64(0), errEvent.Cursor)
+}
+
+func TestMemoryStreamStore_Read(t *testing.T) {
+	store := NewMemoryStreamStore(time.Minute)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+	require.NoError(t, store.Create(ctx, "s"))
+
+	// Reads block until an event is appended
+	read := make(chan []StreamEvent)
+	go func() {
+		events, _, _ := store.Read(ctx, "s", 0)
+		read <- events
+	}()
+	event, _ := NewStreamEvent(1, StreamTextChunk{Text: "a"})
+	require.NoError(t, store.Append(ctx, "s", event))
+	assert.Len(t, <-read, 1)
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, _, err := store.Read(timeout, "s", 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, store.Close(ctx, "s"))
+	events, complete, err := store.Read(ctx, "s", 1)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	assert.True(t, complete)
+	assert.Error(t, store.Append(ctx, "s", event))
+
+	// Complete streams expire after the TTL
+	now = now.Add(time.Minute)
+	_, _, err = store.Read(ctx, "s", 0)
+	assert.ErrorIs(t, err, ErrStreamNotFound)
+}
+
+func TestServeStream(t *testing.T) {
+	store := NewMemoryStreamStore(0)
+	upstream := make(chan StreamChunk, 3)
+	upstream <- StreamTextChunk{Text: "Hi"}
+	upstream <- StreamTextChunk{Text: " there"}
+	upstream <- StreamFinishChunk{Reason: FinishReasonStop}
+	close(upstream)
+	done, err := PersistStream(context.Background(), store, "req-1", upstream)
+	require.NoError(t, err)
+	require.NoError(t, <-done)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeStream(w, r, store, strings.TrimPrefix(r.URL.Path, "/streams/"))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/streams/req-1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Equal(t, []string{
+		"id: 2", "event: text", `data: {"text":" there"}`, "",
+		"id: 3", "event: finish", `data: {"reason":"stop"}`, "",
+	}, lines)
+
+	resp, err = http.Get(server.URL + "/streams/req-1?cursor=3")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/streams/missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/streams/req-1?cursor=abc")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
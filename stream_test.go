@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingCloser struct {
+	closed atomic.Int32
+}
+
+func (c *countingCloser) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestSendStreamCanceled(t *testing.T) {
+	ch := make(chan StreamChunk, 1)
+	SendStreamCanceled(context.Background(), ch, "openai")
+	assert.Empty(t, ch, "nothing is sent while the context is not done")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	SendStreamCanceled(ctx, ch, "openai")
+	require.Len(t, ch, 1)
+	chunk := (<-ch).(StreamErrorChunk)
+	assert.Equal(t, "openai", chunk.Provider)
+	assert.ErrorIs(t, chunk, context.DeadlineExceeded)
+
+	// A full channel does not block
+	ch <- StreamTextChunk{Text: "unread"}
+	SendStreamCanceled(ctx, ch, "openai")
+	assert.Equal(t, StreamTextChunk{Text: "unread"}, <-ch)
+}
+
+func TestCloseOnDone(t *testing.T) {
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		closer := &countingCloser{}
+		closeNow := CloseOnDone(ctx, closer)
+		cancel()
+		assert.Eventually(t, func() bool { return closer.closed.Load() == 1 }, time.Second, time.Millisecond)
+		closeNow()
+		assert.Equal(t, int32(1), closer.closed.Load())
+	})
+
+	t.Run("finished", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		closer := &countingCloser{}
+		closeNow := CloseOnDone(ctx, closer)
+		closeNow()
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, int32(1), closer.closed.Load())
+	})
+}
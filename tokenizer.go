@@ -0,0 +1,345 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encodings of OpenAI models, used as tokenizer names with RegisterTokenizer
+const (
+	EncodingO200K  = "o200k_base"
+	EncodingCL100K = "cl100k_base"
+	// EncodingClaude names the tokenizer used for Anthropic models
+	EncodingClaude = "claude"
+	// EncodingCohere names the tokenizer used for Cohere models
+	EncodingCohere = "cohere"
+)
+
+const (
+	// messageTokenOverhead is the number of tokens each message adds for its role and delimiters
+	messageTokenOverhead = 3
+	// replyTokenOverhead is the number of tokens that prime the assistant reply
+	replyTokenOverhead = 3
+	// imageTokenEstimate is the token cost assumed for an image, that of a 1024x1024 image at
+	// high detail on OpenAI models
+	imageTokenEstimate = 765
+)
+
+// Tokenizer counts the tokens of text
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenCounter is implemented by completion models that can count the tokens of a request
+// before sending it
+type TokenCounter interface {
+	CountTokens(req *CompletionRequest) (*TokenCount, error)
+}
+
+// TokenCount is the estimated size of a request
+type TokenCount struct {
+	InputTokens int `json:"inputTokens"`
+	// Estimated reports that InputTokens was estimated from character counts by a
+	// HeuristicTokenizer, because no tokenizer is registered for the encoding of the model
+	Estimated bool `json:"estimated,omitempty"`
+	// ContextWindow is the context window of the model, or 0 if unknown
+	ContextWindow int `json:"contextWindow"`
+	// Cost is the estimated input cost, if the model has pricing
+	Cost *float64 `json:"cost,omitempty"`
+	// Currency is the currency of Cost, USD when empty
+	Currency string `json:"currency,omitempty"`
+}
+
+// CountTokens counts the tokens of req with model, which must implement TokenCounter
+func CountTokens(model CompletionModel, req *CompletionRequest) (*TokenCount, error) {
+	counter, ok := model.(TokenCounter)
+	if !ok {
+		return nil, NewUnsupportedCapabilityError(fmt.Sprintf("%T", model), "token counts")
+	}
+	return counter.CountTokens(req)
+}
+
+// WithContextWindowCheck rejects requests whose estimated input tokens, plus the requested
+// maximum output tokens, exceed the model's context window with a ContextWindowError before
+// they are sent
+func WithContextWindowCheck(enabled bool) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.ContextWindowCheck = &enabled
+	}
+}
+
+// ChecksContextWindow reports whether requests are checked against the context window
+func (o *CompletionOptions) ChecksContextWindow() bool {
+	return o != nil && o.ContextWindowCheck != nil && *o.ContextWindowCheck
+}
+
+var tokenizers sync.Map
+
+// RegisterTokenizer sets the tokenizer used for an encoding, e.g. a BPETokenizer loaded from
+// the o200k_base rank file for EncodingO200K. No rank files are bundled with this module, so
+// encodings without a registered tokenizer, including the tiktoken encodings, are estimated
+// with HeuristicTokenizer.
+func RegisterTokenizer(encoding string, tokenizer Tokenizer) {
+	tokenizers.Store(encoding, tokenizer)
+}
+
+// GetTokenizer returns the tokenizer registered for encoding, or a HeuristicTokenizer
+func GetTokenizer(encoding string) Tokenizer {
+	if tokenizer, ok := tokenizers.Load(encoding); ok {
+		return tokenizer.(Tokenizer)
+	}
+	return HeuristicTokenizer{}
+}
+
+// OpenAIEncoding returns the tiktoken encoding of an OpenAI model
+func OpenAIEncoding(model string) string {
+	model = model[strings.LastIndex(model, "/")+1:]
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4", "chatgpt-4o"} {
+		if strings.HasPrefix(model, prefix) {
+			return EncodingO200K
+		}
+	}
+	return EncodingCL100K
+}
+
+// CountRequestTokens estimates the input tokens of req with tokenizer, including the tokens
+// each message adds for its role. Images are counted at a fixed estimate.
+func CountRequestTokens(tokenizer Tokenizer, req *CompletionRequest) int {
+	if req == nil {
+		return 0
+	}
+	tokens := replyTokenOverhead
+	if req.Instructions != "" {
+		tokens += messageTokenOverhead + tokenizer.CountTokens(req.Instructions)
+	}
+	for _, msg := range req.Messages {
+		if msg == nil {
+			continue
+		}
+		tokens += messageTokenOverhead
+		for _, part := range msg.ContentParts() {
+			switch {
+			case part.Text != "":
+				tokens += tokenizer.CountTokens(part.Text)
+			case part.ToolCall != nil:
+				tokens += countToolCallTokens(tokenizer, part.ToolCall)
+			case part.Artifact != nil:
+				tokens += countArtifactTokens(tokenizer, part.Artifact)
+			}
+		}
+		// Assistant tool calls are sent as the call name and JSON input
+		if msg.Role == RoleAssistant && msg.ToolCall != nil && len(msg.Parts) == 0 {
+			tokens += countToolCallTokens(tokenizer, msg.ToolCall)
+		}
+	}
+	return tokens
+}
+
+func countToolCallTokens(tokenizer Tokenizer, call *ToolCall) int {
+	tokens := tokenizer.CountTokens(call.Name)
+	if input, err := json.Marshal(call.Input); err == nil {
+		tokens += tokenizer.CountTokens(string(input))
+	}
+	if call.Output != nil {
+		if output, err := json.Marshal(call.Output); err == nil {
+			tokens += tokenizer.CountTokens(string(output))
+		}
+	}
+	return tokens
+}
+
+func countArtifactTokens(tokenizer Tokenizer, artifact *ModelArtifact) int {
+	switch {
+	case strings.HasPrefix(artifact.ContentType, "image/"):
+		return countImageTokens(artifact)
+	case strings.HasPrefix(artifact.ContentType, "text/") || artifact.ContentType == "application/json":
+		return tokenizer.CountTokens(string(artifact.Content))
+	}
+	return 0
+}
+
+// countImageTokens estimates the tokens of an image from its size like OpenAI models at high
+// detail: the image is scaled to fit 2048x2048 with its shortest side at most 768 pixels and
+// costs 170 tokens per 512 pixel tile plus 85. Images whose size is unknown are estimated
+// at imageTokenEstimate.
+func countImageTokens(artifact *ModelArtifact) int {
+	config, _, err := image.DecodeConfig(bytes.NewReader(artifact.Content))
+	if err != nil || config.Width == 0 || config.Height == 0 {
+		return imageTokenEstimate
+	}
+	width, height := float64(config.Width), float64(config.Height)
+	if scale := 2048 / max(width, height); scale < 1 {
+		width, height = width*scale, height*scale
+	}
+	if scale := 768 / min(width, height); scale < 1 {
+		width, height = width*scale, height*scale
+	}
+	tiles := int(math.Ceil(width/512) * math.Ceil(height/512))
+	return 85 + 170*tiles
+}
+
+// ContextWindowError is returned when a request does not fit the model's context window. It
+// matches ErrContextWindowExceeded with errors.Is.
+type ContextWindowError struct {
+	Model string
+	// Tokens is the estimated input tokens plus the requested maximum output tokens
+	Tokens        int
+	ContextWindow int
+}
+
+func (e *ContextWindowError) Error() string {
+	return fmt.Sprintf("request for %s needs about %d tokens, exceeding the context window of %d", e.Model, e.Tokens, e.ContextWindow)
+}
+
+func (e *ContextWindowError) Is(target error) bool {
+	return target == ErrContextWindowExceeded
+}
+
+// HeuristicTokenizer estimates tokens from character classes: about four characters per
+// token for ASCII text, one token per CJK character and two characters per token otherwise.
+// It is used for models without a registered tokenizer.
+type HeuristicTokenizer struct{}
+
+func (HeuristicTokenizer) CountTokens(text string) int {
+	var ascii, cjk, other int
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		default:
+			other++
+		}
+	}
+	return int(math.Ceil(float64(ascii)/4)) + cjk + int(math.Ceil(float64(other)/2))
+}
+
+// bpePattern splits text into the pieces BPE merges within. It follows the tiktoken
+// cl100k_base and o200k_base patterns; Go regexps lack the lookahead that keeps the last
+// space of a whitespace run with the next word, which bpeSplit restores.
+var bpePattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// BPETokenizer is a byte pair encoding tokenizer compatible with tiktoken rank files
+type BPETokenizer struct {
+	ranks map[string]int
+}
+
+// NewBPETokenizer reads a tiktoken rank file, e.g. o200k_base.tiktoken, with one base64
+// encoded token and its rank per line
+func NewBPETokenizer(ranks io.Reader) (*BPETokenizer, error) {
+	t := &BPETokenizer{ranks: make(map[string]int)}
+	scanner := bufio.NewScanner(ranks)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid rank file line %d", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid token on rank file line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank on rank file line %d: %w", line, err)
+		}
+		t.ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *BPETokenizer) CountTokens(text string) int {
+	return len(t.Encode(text))
+}
+
+// Encode returns the token ranks of text
+func (t *BPETokenizer) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range bpeSplit(text) {
+		if rank, ok := t.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		tokens = append(tokens, t.merge(piece)...)
+	}
+	return tokens
+}
+
+// merge applies byte pair merges to piece, always merging the adjacent pair with the lowest
+// rank, and returns the ranks of the resulting parts
+func (t *BPETokenizer) merge(piece string) []int {
+	parts := make([]string, len(piece))
+	for i := range len(piece) {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := t.ranks[parts[i]+parts[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+
+	ranks := make([]int, 0, len(parts))
+	for _, part := range parts {
+		if rank, ok := t.ranks[part]; ok {
+			ranks = append(ranks, rank)
+		} else {
+			// Bytes missing from the rank file are counted as one token each
+			for range len(part) {
+				ranks = append(ranks, -1)
+			}
+		}
+	}
+	return ranks
+}
+
+// bpeSplit splits text with bpePattern. A whitespace run followed by a word gives up its last
+// space to the word, as tiktoken's \s+(?!\S) does.
+func bpeSplit(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		loc := bpePattern.FindStringIndex(text)
+		if loc == nil {
+			pieces = append(pieces, text)
+			break
+		}
+		end := loc[1]
+		piece := text[:end]
+		if strings.TrimSpace(piece) == "" && end < len(text) && !strings.ContainsAny(piece, "\r\n") {
+			if _, size := utf8.DecodeLastRuneInString(piece); size < len(piece) {
+				end -= size
+			}
+		}
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRanks builds a tiktoken rank file with all single bytes followed by merges
+func testRanks(merges ...string) string {
+	var b strings.Builder
+	for i := range 256 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, merge := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(merge)), 256+i)
+	}
+	return b.String()
+}
+
+func TestBPETokenizer(t *testing.T) {
+	tokenizer, err := NewBPETokenizer(strings.NewReader(testRanks("he", "ll", "hell", "hello", " w", " wo", " wor")))
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{256 + 3}, tokenizer.Encode("hello"))
+	// " world" merges up to " wor", leaving "l" and "d"
+	assert.Equal(t, []int{256 + 3, 256 + 6, 'l', 'd'}, tokenizer.Encode("hello world"))
+	assert.Equal(t, 0, tokenizer.CountTokens(""))
+
+	_, err = NewBPETokenizer(strings.NewReader("not-base64! 1\n"))
+	assert.Error(t, err)
+}
+
+func TestBPESplit(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "Hello world", want: []string{"Hello", " world"}},
+		{text: "a  b", want: []string{"a", " ", " b"}},
+		{text: "it's 12345!", want: []string{"it", "'s", " ", "123", "45", "!"}},
+		{text: "line\n\nnext", want: []string{"line", "\n\n", "next"}},
+		{text: "end  ", want: []string{"end", "  "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.want, bpeSplit(tt.text))
+		})
+	}
+}
+
+func TestHeuristicTokenizer(t *testing.T) {
+	tokenizer := HeuristicTokenizer{}
+	assert.Equal(t, 3, tokenizer.CountTokens("Hello world!"))
+	assert.Equal(t, 4, tokenizer.CountTokens("你好世界"))
+	assert.Equal(t, 0, tokenizer.CountTokens(""))
+}
+
+func TestCountRequestTokens(t *testing.T) {
+	tokenizer := HeuristicTokenizer{}
+	req := &CompletionRequest{
+		Instructions: "Be brief.",
+		Messages: []*ModelMessage{
+			{Role: RoleUser, Content: "Hello world!", Artifacts: []*ModelArtifact{{Name: "a.png", ContentType: "image/png"}}},
+			{Role: RoleAssistant, Content: "Hi"},
+		},
+	}
+	// reply priming + instructions (3 + 3) + user (3 + 3 + image) + assistant (3 + 1)
+	assert.Equal(t, 3+6+6+imageTokenEstimate+4, CountRequestTokens(tokenizer, req))
+}
+
+func TestGetTokenizer(t *testing.T) {
+	assert.Equal(t, HeuristicTokenizer{}, GetTokenizer("test_encoding"))
+
+	tokenizer, err := NewBPETokenizer(strings.NewReader(testRanks()))
+	require.NoError(t, err)
+	RegisterTokenizer("test_encoding", tokenizer)
+	defer tokenizers.Delete("test_encoding")
+	assert.Same(t, tokenizer, GetTokenizer("test_encoding"))
+
+	assert.Equal(t, EncodingO200K, OpenAIEncoding("gpt-4o-mini"))
+	assert.Equal(t, EncodingO200K, OpenAIEncoding("openai/o3"))
+	assert.Equal(t, EncodingCL100K, OpenAIEncoding("gpt-3.5-turbo"))
+}
+
+func TestContextWindowError(t *testing.T) {
+	var err error = &ContextWindowError{Model: "m", Tokens: 200, ContextWindow: 100}
+	assert.True(t, errors.Is(err, ErrContextWindowExceeded))
+	assert.Contains(t, err.Error(), "200")
+
+	_, err = CountTokens(&stubCompletionModel{}, &CompletionRequest{})
+	var unsupported *UnsupportedCapabilityError
+	assert.ErrorAs(t, err, &unsupported)
+}
@@ -0,0 +1,184 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ModelTool defines a tool that can be invoked by the model
+type ModelTool interface {
+	// Name returns the tool name the model uses to call it
+	Name() string
+	// Description returns a human-readable description of the tool
+	Description() string
+	// InputSchema returns the JSON schema of the tool input
+	InputSchema() any
+	// Run executes the tool with the given input
+	Run(ctx context.Context, input map[string]any) (any, error)
+}
+
+// ToolResult can be returned from ModelTool.Run to attach artifacts (e.g. a generated chart)
+// to the tool output. The artifacts are forwarded to the model with the tool result message.
+type ToolResult struct {
+	Output    any
+	Artifacts []*ModelArtifact
+}
+
+// Standard tool error codes reported back to the model
+const (
+	ToolErrorCodeFailed   = "tool_failed"
+	ToolErrorCodeNotFound = "tool_not_found"
+	ToolErrorCodeCanceled = "tool_canceled"
+	ToolErrorCodeTimeout  = "tool_timeout"
+	ToolErrorCodePanic    = "tool_panic"
+)
+
+// ToolError represents a structured error returned by a tool
+type ToolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Err     error  `json:"-"`
+}
+
+func (e *ToolError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+// NewToolError creates a new tool error
+func NewToolError(code, message string, err error) error {
+	return &ToolError{
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// ToolErrorEnvelope is the standard output sent to the model when a tool call fails
+type ToolErrorEnvelope struct {
+	Error *ToolError `json:"error"`
+}
+
+// Duration returns how long the tool call took, or zero if it has not finished
+func (c *ToolCall) Duration() time.Duration {
+	if c.StartAt.IsZero() || c.EndAt.IsZero() {
+		return 0
+	}
+	return c.EndAt.Sub(c.StartAt)
+}
+
+// Failed reports whether the tool call finished with an error
+func (c *ToolCall) Failed() bool {
+	return c.ErrorMessage != nil
+}
+
+// ExecuteToolCall runs the tool for the given call, filling timing, output and error fields.
+// Tool failures are captured on the call as a ToolErrorEnvelope so they can be sent back to
+// the model; the returned error is the same structured ToolError.
+func ExecuteToolCall(ctx context.Context, tool ModelTool, call *ToolCall) (err error) {
+	if call == nil {
+		return NewValidationError("toolCall", "cannot be nil", nil)
+	}
+
+	call.StartAt = time.Now()
+	call.EndAt = time.Time{}
+	call.Output = nil
+	call.Artifacts = nil
+	call.ErrorMessage = nil
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = NewToolError(ToolErrorCodePanic, fmt.Sprintf("tool %q panicked: %v", call.Name, r), nil)
+		}
+		call.EndAt = time.Now()
+		if err != nil {
+			call.SetError(err)
+		}
+	}()
+
+	if tool == nil {
+		return NewToolError(ToolErrorCodeNotFound, fmt.Sprintf("tool %q not found", call.Name), nil)
+	}
+
+	output, err := tool.Run(ctx, call.Input)
+	if err != nil {
+		return toToolError(ctx, err)
+	}
+	if result, ok := output.(*ToolResult); ok {
+		call.Output = result.Output
+		call.Artifacts = result.Artifacts
+		return nil
+	}
+	call.Output = output
+	return nil
+}
+
+// NewToolResultMessage creates the tool result message for a finished call, carrying
+// any artifacts produced by the tool
+func NewToolResultMessage(call *ToolCall) *ModelMessage {
+	return &ModelMessage{
+		Role:      RoleTool,
+		ToolCall:  call,
+		Artifacts: call.Artifacts,
+	}
+}
+
+// SetError records err on the call using the standard tool error envelope
+func (c *ToolCall) SetError(err error) {
+	var toolErr *ToolError
+	if !errors.As(err, &toolErr) {
+		toolErr = &ToolError{Code: ToolErrorCodeFailed, Message: err.Error(), Err: err}
+	}
+	message := toolErr.Message
+	c.ErrorMessage = &message
+	c.Output = &ToolErrorEnvelope{Error: toolErr}
+}
+
+// toToolError converts err into a ToolError with a standard code
+func toToolError(ctx context.Context, err error) *ToolError {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return &ToolError{Code: ToolErrorCodeTimeout, Message: err.Error(), Err: err}
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return &ToolError{Code: ToolErrorCodeCanceled, Message: err.Error(), Err: err}
+	default:
+		return &ToolError{Code: ToolErrorCodeFailed, Message: err.Error(), Err: err}
+	}
+}
+
+// FindTool returns the tool with the given name, or nil if none matches
+func FindTool(tools []ModelTool, name string) ModelTool {
+	for _, tool := range tools {
+		if tool != nil && tool.Name() == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// ExecuteToolCalls runs each call against the matching tool in order and records the
+// tool latency on metadata when provided. Tool failures are recorded on the calls.
+func ExecuteToolCalls(ctx context.Context, tools []ModelTool, calls []*ToolCall, metadata *ResponseMetadata) {
+	for _, call := range calls {
+		if call == nil {
+			continue
+		}
+		_ = ExecuteToolCall(ctx, FindTool(tools, call.Name), call)
+		metadata.AddToolCall(call)
+	}
+}
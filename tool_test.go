@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTool struct {
+	name string
+	run  func(ctx context.Context, input map[string]any) (any, error)
+}
+
+func (t *testTool) Name() string        { return t.name }
+func (t *testTool) Description() string { return "test tool" }
+func (t *testTool) InputSchema() any    { return nil }
+func (t *testTool) Run(ctx context.Context, input map[string]any) (any, error) {
+	return t.run(ctx, input)
+}
+
+func TestExecuteToolCall_Success(t *testing.T) {
+	tool := &testTool{name: "echo", run: func(ctx context.Context, input map[string]any) (any, error) {
+		time.Sleep(time.Millisecond)
+		return input["text"], nil
+	}}
+	call := &ToolCall{ID: "1", Name: "echo", Input: map[string]any{"text": "hello"}}
+
+	err := ExecuteToolCall(context.Background(), tool, call)
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello", call.Output)
+	assert.Nil(t, call.ErrorMessage)
+	assert.False(t, call.StartAt.IsZero())
+	assert.False(t, call.EndAt.IsZero())
+	assert.Greater(t, call.Duration(), time.Duration(0))
+}
+
+func TestExecuteToolCall_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     ModelTool
+		ctx      func() context.Context
+		wantCode string
+	}{
+		{
+			name: "plain_error",
+			tool: &testTool{name: "t", run: func(ctx context.Context, input map[string]any) (any, error) {
+				return nil, errors.New("boom")
+			}},
+			wantCode: ToolErrorCodeFailed,
+		},
+		{
+			name: "structured_error",
+			tool: &testTool{name: "t", run: func(ctx context.Context, input map[string]any) (any, error) {
+				return nil, NewToolError("invalid_input", "missing field", nil)
+			}},
+			wantCode: "invalid_input",
+		},
+		{
+			name: "canceled",
+			tool: &testTool{name: "t", run: func(ctx context.Context, input map[string]any) (any, error) {
+				return nil, ctx.Err()
+			}},
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			wantCode: ToolErrorCodeCanceled,
+		},
+		{
+			name: "panic",
+			tool: &testTool{name: "t", run: func(ctx context.Context, input map[string]any) (any, error) {
+				panic("bad tool")
+			}},
+			wantCode: ToolErrorCodePanic,
+		},
+		{
+			name:     "not_found",
+			tool:     nil,
+			wantCode: ToolErrorCodeNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+			call := &ToolCall{Name: "t"}
+
+			err := ExecuteToolCall(ctx, tt.tool, call)
+
+			var toolErr *ToolError
+			require.ErrorAs(t, err, &toolErr)
+			assert.Equal(t, tt.wantCode, toolErr.Code)
+			require.NotNil(t, call.ErrorMessage)
+			assert.False(t, call.EndAt.IsZero())
+
+			envelope, ok := call.Output.(*ToolErrorEnvelope)
+			require.True(t, ok, "output should be a tool error envelope")
+			assert.Equal(t, tt.wantCode, envelope.Error.Code)
+
+			data, err := json.Marshal(call)
+			require.NoError(t, err)
+			assert.Contains(t, string(data), `"error":{"code":"`+tt.wantCode+`"`)
+		})
+	}
+}
+
+func TestExecuteToolCalls_Metadata(t *testing.T) {
+	tools := []ModelTool{
+		&testTool{name: "ok", run: func(ctx context.Context, input map[string]any) (any, error) {
+			time.Sleep(time.Millisecond)
+			return "done", nil
+		}},
+	}
+	calls := []*ToolCall{{Name: "ok"}, {Name: "missing"}}
+	metadata := &ResponseMetadata{}
+
+	ExecuteToolCalls(context.Background(), tools, calls, metadata)
+
+	assert.Equal(t, 2, metadata.ToolCalls)
+	assert.Equal(t, 1, metadata.ToolErrors)
+	assert.GreaterOrEqual(t, metadata.ToolLatency, calls[0].Duration())
+	assert.Equal(t, calls[0].Duration(), metadata.MaxToolLatency)
+}
+
+func TestExecuteToolCall_Artifacts(t *testing.T) {
+	chart := &ModelArtifact{Name: "chart.png", ContentType: "image/png", Content: []byte{0x89, 0x50}}
+	tool := &testTool{name: "chart", run: func(ctx context.Context, input map[string]any) (any, error) {
+		return &ToolResult{Output: "chart generated", Artifacts: []*ModelArtifact{chart}}, nil
+	}}
+	call := &ToolCall{Name: "chart"}
+
+	require.NoError(t, ExecuteToolCall(context.Background(), tool, call))
+	assert.Equal(t, "chart generated", call.Output)
+	require.Len(t, call.Artifacts, 1)
+
+	msg := NewToolResultMessage(call)
+	assert.Equal(t, RoleTool, msg.Role)
+	assert.Same(t, call, msg.ToolCall)
+	assert.Equal(t, []*ModelArtifact{chart}, msg.Artifacts)
+
+	data, err := json.Marshal(call)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "chart.png", "artifacts should not be embedded in the call JSON")
+}
@@ -0,0 +1,372 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// Transcript is a recorded conversation that can be exported for sharing and debugging
+type Transcript struct {
+	Title        string          `json:"title"`
+	Model        string          `json:"model"`
+	Instructions string          `json:"instructions"`
+	Messages     []*ModelMessage `json:"messages"`
+	Usage        *TokenUsage     `json:"usage,omitempty"`
+	Cost         *float64        `json:"cost,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+// RenderOption is a functional option for configuring transcript rendering
+type RenderOption func(*RenderOptions)
+
+// RenderOptions contains configuration options for transcript rendering
+type RenderOptions struct {
+	// Redact is applied to every piece of text (content, tool inputs/outputs, reasoning) before rendering
+	Redact func(text string) string
+	// IncludeReasoning renders reasoning summaries of assistant messages
+	IncludeReasoning bool
+	// IncludeToolCalls renders tool call inputs and outputs
+	IncludeToolCalls bool
+	// EmbedImages inlines image artifacts as data URI thumbnails; otherwise only their names are listed
+	EmbedImages bool
+	// ThumbnailWidth is the maximum width in pixels of embedded image thumbnails in HTML
+	ThumbnailWidth int
+}
+
+// WithRedaction sets the redaction hook applied to all rendered text
+func WithRedaction(redact func(text string) string) RenderOption {
+	return func(o *RenderOptions) {
+		o.Redact = redact
+	}
+}
+
+// WithReasoning sets whether reasoning summaries are rendered
+func WithReasoning(enabled bool) RenderOption {
+	return func(o *RenderOptions) {
+		o.IncludeReasoning = enabled
+	}
+}
+
+// WithToolCalls sets whether tool calls are rendered
+func WithToolCalls(enabled bool) RenderOption {
+	return func(o *RenderOptions) {
+		o.IncludeToolCalls = enabled
+	}
+}
+
+// WithEmbeddedImages sets whether image artifacts are inlined as thumbnails
+func WithEmbeddedImages(enabled bool) RenderOption {
+	return func(o *RenderOptions) {
+		o.EmbedImages = enabled
+	}
+}
+
+// WithThumbnailWidth sets the maximum width of embedded image thumbnails in HTML
+func WithThumbnailWidth(width int) RenderOption {
+	return func(o *RenderOptions) {
+		o.ThumbnailWidth = width
+	}
+}
+
+// ApplyRenderOptions applies all options to create a RenderOptions struct
+func ApplyRenderOptions(opts []RenderOption) *RenderOptions {
+	options := &RenderOptions{
+		IncludeReasoning: true,
+		IncludeToolCalls: true,
+		EmbedImages:      true,
+		ThumbnailWidth:   240,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+func (o *RenderOptions) redact(text string) string {
+	if o.Redact == nil {
+		return text
+	}
+	return o.Redact(text)
+}
+
+// RenderMarkdown writes the transcript as Markdown
+func RenderMarkdown(w io.Writer, t *Transcript, opts ...RenderOption) error {
+	if t == nil {
+		return NewValidationError("transcript", "cannot be nil", nil)
+	}
+	o := ApplyRenderOptions(opts)
+	bw := bufio.NewWriter(w)
+
+	title := t.Title
+	if title == "" {
+		title = "Conversation"
+	}
+	fmt.Fprintf(bw, "# %s\n\n", o.redact(title))
+	if t.Model != "" {
+		fmt.Fprintf(bw, "- **Model:** %s\n", t.Model)
+	}
+	if !t.CreatedAt.IsZero() {
+		fmt.Fprintf(bw, "- **Created:** %s\n", t.CreatedAt.Format(time.RFC3339))
+	}
+	writeMarkdownUsage(bw, t)
+	bw.WriteString("\n")
+
+	if t.Instructions != "" {
+		bw.WriteString("## Instructions\n\n")
+		writeMarkdownQuote(bw, o.redact(t.Instructions))
+		bw.WriteString("\n")
+	}
+
+	for _, msg := range t.Messages {
+		if msg == nil {
+			continue
+		}
+		fmt.Fprintf(bw, "### %s\n\n", roleTitle(msg.Role))
+
+		if o.IncludeReasoning && msg.Reasoning != "" {
+			bw.WriteString("<details><summary>Reasoning</summary>\n\n")
+			bw.WriteString(o.redact(msg.Reasoning))
+			bw.WriteString("\n\n</details>\n\n")
+		}
+		for _, part := range msg.ContentParts() {
+			switch {
+			case part == nil:
+			case part.Type == ContentPartText:
+				bw.WriteString(o.redact(part.Text))
+				bw.WriteString("\n\n")
+			case part.Type == ContentPartToolResult:
+				if part.ToolCall != nil && o.IncludeToolCalls {
+					writeMarkdownToolCall(bw, RoleTool, part.ToolCall, o)
+				}
+			default:
+				writeMarkdownArtifact(bw, part.Artifact, o)
+			}
+		}
+		if msg.Role != RoleTool && msg.ToolCall != nil && o.IncludeToolCalls {
+			writeMarkdownToolCall(bw, msg.Role, msg.ToolCall, o)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeMarkdownUsage(w *bufio.Writer, t *Transcript) {
+	if t.Usage != nil {
+		fmt.Fprintf(w, "- **Tokens:** %d input, %d output", t.Usage.TotalInputTokens, t.Usage.TotalOutputTokens)
+		if t.Usage.TotalReasoningTokens > 0 {
+			fmt.Fprintf(w, ", %d reasoning", t.Usage.TotalReasoningTokens)
+		}
+		w.WriteString("\n")
+	}
+	if t.Cost != nil {
+		fmt.Fprintf(w, "- **Cost:** %s\n", MicroCentsFromUSD(*t.Cost))
+	}
+}
+
+func writeMarkdownQuote(w *bufio.Writer, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		w.WriteString("> ")
+		w.WriteString(line)
+		w.WriteString("\n")
+	}
+}
+
+func writeMarkdownToolCall(w *bufio.Writer, role Role, call *ToolCall, o *RenderOptions) {
+	if role == RoleTool {
+		fmt.Fprintf(w, "**Tool result** `%s`", call.Name)
+	} else {
+		fmt.Fprintf(w, "**Tool call** `%s`", call.Name)
+	}
+	if d := call.Duration(); d > 0 {
+		fmt.Fprintf(w, " (%s)", d.Round(time.Millisecond))
+	}
+	w.WriteString("\n\n")
+
+	if role != RoleTool && call.Input != nil {
+		w.WriteString("```json\n")
+		w.WriteString(o.redact(toIndentedJSON(call.Input)))
+		w.WriteString("\n```\n\n")
+	}
+	if call.ErrorMessage != nil {
+		fmt.Fprintf(w, "Error: %s\n\n", o.redact(*call.ErrorMessage))
+	} else if role == RoleTool && call.Output != nil {
+		w.WriteString("```json\n")
+		w.WriteString(o.redact(toIndentedJSON(call.Output)))
+		w.WriteString("\n```\n\n")
+	}
+}
+
+func writeMarkdownArtifact(w *bufio.Writer, artifact *ModelArtifact, o *RenderOptions) {
+	if artifact == nil {
+		return
+	}
+	name := o.redact(artifact.Name)
+	if strings.HasPrefix(artifact.ContentType, "image/") {
+		if artifact.URL != "" {
+			fmt.Fprintf(w, "![%s](%s)\n\n", name, artifact.URL)
+			return
+		}
+		if o.EmbedImages && len(artifact.Content) > 0 {
+			fmt.Fprintf(w, "![%s](data:%s;base64,%s)\n\n", name, artifact.ContentType, base64.StdEncoding.EncodeToString(artifact.Content))
+			return
+		}
+	}
+	fmt.Fprintf(w, "📎 %s (%s)\n\n", name, artifact.ContentType)
+}
+
+// RenderHTML writes the transcript as a standalone HTML document
+func RenderHTML(w io.Writer, t *Transcript, opts ...RenderOption) error {
+	if t == nil {
+		return NewValidationError("transcript", "cannot be nil", nil)
+	}
+	o := ApplyRenderOptions(opts)
+	bw := bufio.NewWriter(w)
+	esc := func(text string) string {
+		return html.EscapeString(o.redact(text))
+	}
+
+	title := t.Title
+	if title == "" {
+		title = "Conversation"
+	}
+
+	bw.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(bw, "<title>%s</title>\n", esc(title))
+	bw.WriteString(`<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Helvetica,Arial,sans-serif;max-width:860px;margin:2em auto;padding:0 1em;color:#1f2328;line-height:1.5}
+.meta{color:#59636e;font-size:.9em}
+.msg{border:1px solid #d1d9e0;border-radius:8px;padding:.75em 1em;margin:1em 0}
+.role{font-weight:600;font-size:.85em;text-transform:uppercase;color:#59636e}
+.user{background:#f6f8fa}.tool{background:#fff8e6}
+.content{white-space:pre-wrap}
+pre{background:#f6f8fa;padding:.5em;overflow-x:auto;border-radius:6px}
+.error{color:#d1242f}
+details{color:#59636e;margin:.5em 0}
+.artifact{display:inline-block;margin:.25em .5em .25em 0}
+</style>
+</head>
+<body>
+`)
+	fmt.Fprintf(bw, "<h1>%s</h1>\n<div class=\"meta\">\n", esc(title))
+	if t.Model != "" {
+		fmt.Fprintf(bw, "<div>Model: %s</div>\n", html.EscapeString(t.Model))
+	}
+	if !t.CreatedAt.IsZero() {
+		fmt.Fprintf(bw, "<div>Created: %s</div>\n", t.CreatedAt.Format(time.RFC3339))
+	}
+	if t.Usage != nil {
+		fmt.Fprintf(bw, "<div>Tokens: %d input, %d output</div>\n", t.Usage.TotalInputTokens, t.Usage.TotalOutputTokens)
+	}
+	if t.Cost != nil {
+		fmt.Fprintf(bw, "<div>Cost: %s</div>\n", MicroCentsFromUSD(*t.Cost))
+	}
+	bw.WriteString("</div>\n")
+
+	if t.Instructions != "" {
+		fmt.Fprintf(bw, "<div class=\"msg system\"><div class=\"role\">Instructions</div><div class=\"content\">%s</div></div>\n", esc(t.Instructions))
+	}
+
+	for _, msg := range t.Messages {
+		if msg == nil {
+			continue
+		}
+		fmt.Fprintf(bw, "<div class=\"msg %s\">\n<div class=\"role\">%s</div>\n", html.EscapeString(string(msg.Role)), html.EscapeString(roleTitle(msg.Role)))
+		if o.IncludeReasoning && msg.Reasoning != "" {
+			fmt.Fprintf(bw, "<details><summary>Reasoning</summary><div class=\"content\">%s</div></details>\n", esc(msg.Reasoning))
+		}
+		for _, part := range msg.ContentParts() {
+			switch {
+			case part == nil:
+			case part.Type == ContentPartText:
+				fmt.Fprintf(bw, "<div class=\"content\">%s</div>\n", esc(part.Text))
+			case part.Type == ContentPartToolResult:
+				if part.ToolCall != nil && o.IncludeToolCalls {
+					writeHTMLToolCall(bw, RoleTool, part.ToolCall, esc)
+				}
+			default:
+				writeHTMLArtifact(bw, part.Artifact, o)
+			}
+		}
+		if msg.Role != RoleTool && msg.ToolCall != nil && o.IncludeToolCalls {
+			writeHTMLToolCall(bw, msg.Role, msg.ToolCall, esc)
+		}
+		bw.WriteString("</div>\n")
+	}
+
+	bw.WriteString("</body>\n</html>\n")
+	return bw.Flush()
+}
+
+func writeHTMLToolCall(w *bufio.Writer, role Role, call *ToolCall, esc func(string) string) {
+	label := "Tool call"
+	if role == RoleTool {
+		label = "Tool result"
+	}
+	fmt.Fprintf(w, "<div><strong>%s</strong> <code>%s</code>", label, html.EscapeString(call.Name))
+	if d := call.Duration(); d > 0 {
+		fmt.Fprintf(w, " <span class=\"meta\">(%s)</span>", d.Round(time.Millisecond))
+	}
+	w.WriteString("</div>\n")
+	if role != RoleTool && call.Input != nil {
+		fmt.Fprintf(w, "<pre>%s</pre>\n", esc(toIndentedJSON(call.Input)))
+	}
+	if call.ErrorMessage != nil {
+		fmt.Fprintf(w, "<div class=\"error\">Error: %s</div>\n", esc(*call.ErrorMessage))
+	} else if role == RoleTool && call.Output != nil {
+		fmt.Fprintf(w, "<pre>%s</pre>\n", esc(toIndentedJSON(call.Output)))
+	}
+}
+
+func writeHTMLArtifact(w *bufio.Writer, artifact *ModelArtifact, o *RenderOptions) {
+	if artifact == nil {
+		return
+	}
+	name := html.EscapeString(o.redact(artifact.Name))
+	if strings.HasPrefix(artifact.ContentType, "image/") {
+		src := ""
+		if artifact.URL != "" {
+			src = artifact.URL
+		} else if o.EmbedImages && len(artifact.Content) > 0 {
+			src = "data:" + artifact.ContentType + ";base64," + base64.StdEncoding.EncodeToString(artifact.Content)
+		}
+		if src != "" {
+			fmt.Fprintf(w, "<figure class=\"artifact\"><img src=\"%s\" alt=\"%s\" style=\"max-width:%dpx\"><figcaption class=\"meta\">%s</figcaption></figure>\n",
+				html.EscapeString(src), name, o.ThumbnailWidth, name)
+			return
+		}
+	}
+	fmt.Fprintf(w, "<div class=\"artifact meta\">📎 %s (%s)</div>\n", name, html.EscapeString(artifact.ContentType))
+}
+
+func roleTitle(role Role) string {
+	switch role {
+	case RoleUser:
+		return "User"
+	case RoleAssistant:
+		return "Assistant"
+	case RoleTool:
+		return "Tool"
+	default:
+		return string(role)
+	}
+}
+
+func toIndentedJSON(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
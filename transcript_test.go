@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTranscript() *Transcript {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	errMsg := "rate limited"
+	cost := 0.0123
+	return &Transcript{
+		Title:        "Support chat",
+		Model:        "gpt-4o-mini",
+		Instructions: "You are a helpful assistant.",
+		CreatedAt:    start,
+		Usage:        &TokenUsage{TotalInputTokens: 120, TotalOutputTokens: 45},
+		Cost:         &cost,
+		Messages: []*ModelMessage{
+			{Role: RoleUser, Content: "My email is jane@example.com, plot <sales>"},
+			{
+				Role:      RoleAssistant,
+				Reasoning: "Need to call the chart tool",
+				ToolCall:  &ToolCall{Name: "chart", Input: map[string]any{"series": "sales"}, StartAt: start, EndAt: start.Add(1500 * time.Millisecond)},
+			},
+			{
+				Role:      RoleTool,
+				ToolCall:  &ToolCall{Name: "chart", Output: map[string]any{"ok": true}},
+				Artifacts: []*ModelArtifact{{Name: "chart.png", ContentType: "image/png", Content: []byte{0x89, 0x50}}},
+			},
+			{Role: RoleTool, ToolCall: &ToolCall{Name: "lookup", ErrorMessage: &errMsg}},
+			{Role: RoleAssistant, Content: "Here is your chart."},
+		},
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	redact := func(text string) string {
+		return strings.ReplaceAll(text, "jane@example.com", "[REDACTED]")
+	}
+
+	err := RenderMarkdown(&buf, newTestTranscript(), WithRedaction(redact))
+	require.NoError(t, err)
+	out := buf.String()
+
+	assert.Contains(t, out, "# Support chat")
+	assert.Contains(t, out, "- **Model:** gpt-4o-mini")
+	assert.Contains(t, out, "- **Cost:** $0.012300")
+	assert.Contains(t, out, "> You are a helpful assistant.")
+	assert.Contains(t, out, "### User")
+	assert.Contains(t, out, "[REDACTED]")
+	assert.NotContains(t, out, "jane@example.com")
+	assert.Contains(t, out, "<summary>Reasoning</summary>")
+	assert.Contains(t, out, "**Tool call** `chart` (1.5s)")
+	assert.Contains(t, out, `"series": "sales"`)
+	assert.Contains(t, out, "![chart.png](data:image/png;base64,iVA=)")
+	assert.Contains(t, out, "Error: rate limited")
+}
+
+func TestRenderMarkdown_Options(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := RenderMarkdown(&buf, newTestTranscript(), WithReasoning(false), WithToolCalls(false), WithEmbeddedImages(false))
+	require.NoError(t, err)
+	out := buf.String()
+
+	assert.NotContains(t, out, "Reasoning")
+	assert.NotContains(t, out, "Tool call")
+	assert.Contains(t, out, "📎 chart.png (image/png)")
+}
+
+func TestRenderHTML(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := RenderHTML(&buf, newTestTranscript(), WithThumbnailWidth(120))
+	require.NoError(t, err)
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out, "<!DOCTYPE html>"))
+	assert.Contains(t, out, "<title>Support chat</title>")
+	assert.Contains(t, out, "plot &lt;sales&gt;", "content should be escaped")
+	assert.Contains(t, out, `<img src="data:image/png;base64,iVA=" alt="chart.png" style="max-width:120px">`)
+	assert.Contains(t, out, `<div class="error">Error: rate limited</div>`)
+	assert.True(t, strings.HasSuffix(out, "</html>\n"))
+}
+
+func TestRenderHTML_EscapesRole(t *testing.T) {
+	var buf bytes.Buffer
+	transcript := &Transcript{Messages: []*ModelMessage{{Role: Role("<script>alert(1)</script>"), Content: "hi"}}}
+	require.NoError(t, RenderHTML(&buf, transcript))
+	assert.NotContains(t, buf.String(), "<script>")
+	assert.Contains(t, buf.String(), `<div class="role">&lt;script&gt;alert(1)&lt;/script&gt;</div>`)
+}
+
+func TestRender_NilTranscript(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, RenderMarkdown(&buf, nil))
+	assert.Error(t, RenderHTML(&buf, nil))
+}
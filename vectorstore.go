@@ -0,0 +1,196 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// VectorStore stores embedded records and finds the records nearest to a vector. Adapters for
+// pgvector and Qdrant are in the vectorstores package; MemoryVectorStore keeps records in
+// memory.
+type VectorStore interface {
+	// Upsert inserts records, replacing records with the same ID
+	Upsert(ctx context.Context, records []*VectorRecord) error
+	// Query returns the records nearest to query.Vector, most similar first
+	Query(ctx context.Context, query *VectorQuery) ([]*VectorMatch, error)
+	// Delete removes the records with one of ids that match filter. Nil ids match every
+	// record, but ids and filter cannot both be empty.
+	Delete(ctx context.Context, ids []string, filter VectorFilter) error
+}
+
+// VectorRecord is a text with its embedding
+type VectorRecord struct {
+	ID       string         `json:"id"`
+	Vector   []float64      `json:"vector,omitempty"`
+	Content  string         `json:"content"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// VectorFilter matches records whose metadata has all of the given values
+type VectorFilter map[string]any
+
+// Matches reports whether metadata has all values of the filter
+func (f VectorFilter) Matches(metadata map[string]any) bool {
+	for key, value := range f {
+		actual, ok := metadata[key]
+		if !ok || !filterValueEqual(actual, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterValueEqual compares metadata values, treating numbers of different types as equal
+// when their values are, since metadata read back from JSON holds float64
+func filterValueEqual(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := reflect.ValueOf(v); n.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(n.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(n.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return n.Float(), true
+	}
+	return 0, false
+}
+
+// VectorQuery finds the records nearest to Vector
+type VectorQuery struct {
+	Vector []float64 `json:"vector"`
+	// TopK is the maximum number of matches, 10 when 0
+	TopK int `json:"topK,omitempty"`
+	// Filter limits the matches to records with the given metadata
+	Filter VectorFilter `json:"filter,omitempty"`
+}
+
+// Limit returns the maximum number of matches of the query
+func (q *VectorQuery) Limit() int {
+	if q.TopK <= 0 {
+		return 10
+	}
+	return q.TopK
+}
+
+// VectorMatch is a record found by a query. The Vector of the record is not returned.
+type VectorMatch struct {
+	Record *VectorRecord `json:"record"`
+	// Score is the cosine similarity of the record to the query, higher is more similar
+	Score float64 `json:"score"`
+}
+
+// ValidateVectorQuery checks that query can be run
+func ValidateVectorQuery(query *VectorQuery) error {
+	if query == nil {
+		return NewValidationError("query", "cannot be nil", nil)
+	}
+	if len(query.Vector) == 0 {
+		return NewValidationError("vector", "cannot be empty", nil)
+	}
+	return nil
+}
+
+// ValidateVectorRecords checks that records can be upserted
+func ValidateVectorRecords(records []*VectorRecord) error {
+	for i, record := range records {
+		if record == nil {
+			return NewValidationError("records", "cannot contain nil", i)
+		}
+		if record.ID == "" {
+			return NewValidationError("id", "cannot be empty", i)
+		}
+		if len(record.Vector) == 0 {
+			return NewValidationError("vector", "cannot be empty", record.ID)
+		}
+	}
+	return nil
+}
+
+// ValidateVectorDelete checks that a delete does not remove every record by accident
+func ValidateVectorDelete(ids []string, filter VectorFilter) error {
+	if ids != nil && len(ids) == 0 {
+		return NewValidationError("ids", "cannot be empty", nil)
+	}
+	if ids == nil && len(filter) == 0 {
+		return NewValidationError("filter", "cannot be empty without ids", nil)
+	}
+	return nil
+}
+
+// MemoryVectorStore is a VectorStore that keeps records in memory and compares the query to
+// every record, for tests and small corpora
+type MemoryVectorStore struct {
+	mu      sync.RWMutex
+	records map[string]*VectorRecord
+}
+
+var _ VectorStore = (*MemoryVectorStore)(nil)
+
+// NewMemoryVectorStore creates an empty MemoryVectorStore
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{records: map[string]*VectorRecord{}}
+}
+
+func (s *MemoryVectorStore) Upsert(ctx context.Context, records []*VectorRecord) error {
+	if err := ValidateVectorRecords(records); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range records {
+		stored := *record
+		s.records[record.ID] = &stored
+	}
+	return nil
+}
+
+func (s *MemoryVectorStore) Query(ctx context.Context, query *VectorQuery) ([]*VectorMatch, error) {
+	if err := ValidateVectorQuery(query); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matches []*VectorMatch
+	for _, record := range s.records {
+		if !query.Filter.Matches(record.Metadata) {
+			continue
+		}
+		match := *record
+		match.Vector = nil
+		matches = append(matches, &VectorMatch{Record: &match, Score: cosineSimilarity(query.Vector, record.Vector)})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Record.ID < matches[j].Record.ID
+	})
+	return matches[:min(len(matches), query.Limit())], nil
+}
+
+func (s *MemoryVectorStore) Delete(ctx context.Context, ids []string, filter VectorFilter) error {
+	if err := ValidateVectorDelete(ids, filter); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, record := range s.records {
+		if (ids == nil || slices.Contains(ids, id)) && filter.Matches(record.Metadata) {
+			delete(s.records, id)
+		}
+	}
+	return nil
+}
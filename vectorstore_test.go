@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func matchIDs(matches []*VectorMatch) []string {
+	var ids []string
+	for _, match := range matches {
+		ids = append(ids, match.Record.ID)
+	}
+	return ids
+}
+
+func TestVectorFilter(t *testing.T) {
+	metadata := map[string]any{"lang": "en", "year": float64(2024), "draft": false}
+	assert.True(t, VectorFilter(nil).Matches(nil))
+	assert.True(t, VectorFilter{"lang": "en", "year": 2024}.Matches(metadata))
+	assert.True(t, VectorFilter{"draft": false}.Matches(metadata))
+	assert.False(t, VectorFilter{"lang": "de"}.Matches(metadata))
+	assert.False(t, VectorFilter{"year": "2024"}.Matches(metadata))
+	assert.False(t, VectorFilter{"author": "ann"}.Matches(metadata))
+}
+
+func TestMemoryVectorStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryVectorStore()
+	require.NoError(t, store.Upsert(ctx, []*VectorRecord{
+		{ID: "cat", Vector: []float64{1, 0.1}, Content: "cats", Metadata: map[string]any{"kind": "animal"}},
+		{ID: "kitten", Vector: []float64{1, 0.3}, Content: "kittens", Metadata: map[string]any{"kind": "animal"}},
+		{ID: "car", Vector: []float64{0.1, 1}, Content: "cars", Metadata: map[string]any{"kind": "vehicle"}},
+	}))
+
+	matches, err := store.Query(ctx, &VectorQuery{Vector: []float64{1, 0}, TopK: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cat", "kitten"}, matchIDs(matches))
+	assert.Greater(t, matches[0].Score, matches[1].Score)
+	assert.Equal(t, "cats", matches[0].Record.Content)
+	assert.Nil(t, matches[0].Record.Vector)
+
+	matches, err = store.Query(ctx, &VectorQuery{Vector: []float64{1, 0}, Filter: VectorFilter{"kind": "vehicle"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"car"}, matchIDs(matches))
+
+	// Upsert replaces records with the same ID
+	require.NoError(t, store.Upsert(ctx, []*VectorRecord{{ID: "car", Vector: []float64{1, 0}, Content: "fast cars", Metadata: map[string]any{"kind": "vehicle"}}}))
+	matches, err = store.Query(ctx, &VectorQuery{Vector: []float64{1, 0}, TopK: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "fast cars", matches[0].Record.Content)
+
+	require.NoError(t, store.Delete(ctx, []string{"cat", "car"}, VectorFilter{"kind": "animal"}))
+	require.NoError(t, store.Delete(ctx, nil, VectorFilter{"kind": "vehicle"}))
+	matches, err = store.Query(ctx, &VectorQuery{Vector: []float64{1, 0}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"kitten"}, matchIDs(matches))
+}
+
+func TestMemoryVectorStoreValidation(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryVectorStore()
+	var validationErr *ValidationError
+
+	assert.ErrorAs(t, store.Upsert(ctx, []*VectorRecord{{ID: "a"}}), &validationErr)
+	assert.ErrorAs(t, store.Upsert(ctx, []*VectorRecord{{Vector: []float64{1}}}), &validationErr)
+	_, err := store.Query(ctx, &VectorQuery{})
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ErrorAs(t, store.Delete(ctx, nil, nil), &validationErr)
+	assert.ErrorAs(t, store.Delete(ctx, []string{}, VectorFilter{"a": 1}), &validationErr)
+}
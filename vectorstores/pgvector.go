@@ -0,0 +1,18 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package vectorstores
+
+import (
+	"database/sql"
+
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/vectorstores/pgvector"
+)
+
+// NewPgVectorStore creates a vector store for a PostgreSQL table using the pgvector extension,
+// with id text, content text, metadata jsonb and embedding vector columns. db is opened with a
+// PostgreSQL driver of your choice.
+func NewPgVectorStore(db *sql.DB, table string) (llm.VectorStore, error) {
+	return pgvector.NewPgVectorStore(db, table)
+}
@@ -0,0 +1,16 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package vectorstores
+
+import (
+	"github.com/easyagent-dev/llm"
+	"github.com/easyagent-dev/llm/internal/vectorstores/qdrant"
+)
+
+// NewQdrantVectorStore creates a vector store for a Qdrant collection using the cosine
+// distance. WithBaseURL sets the Qdrant URL, http://localhost:6333 by default, and WithAPIKey
+// the API key of Qdrant Cloud.
+func NewQdrantVectorStore(collection string, opts ...llm.ModelOption) (llm.VectorStore, error) {
+	return qdrant.NewQdrantVectorStore(collection, opts...)
+}
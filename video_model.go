@@ -0,0 +1,85 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// VideoModel defines the interface for video generation operations
+type VideoModel interface {
+	// GenerateVideo generates a video from a text prompt. Providers render videos
+	// asynchronously, so it polls until the video is ready, reporting progress to
+	// req.OnProgress.
+	GenerateVideo(ctx context.Context, req *VideoRequest) (*VideoResponse, error)
+}
+
+// Response formats of generated videos
+const (
+	// VideoResponseFormatBytes downloads the video into VideoResponse.Output
+	VideoResponseFormatBytes = "bytes"
+	// VideoResponseFormatURL returns the provider's URL of the video without downloading it
+	VideoResponseFormatURL = "url"
+)
+
+// DefaultVideoPollInterval is how often the status of a video is checked by default
+const DefaultVideoPollInterval = 5 * time.Second
+
+type VideoRequest struct {
+	Model string `json:"model"`
+	// Instructions is the prompt describing the video
+	Instructions string `json:"instructions"`
+	// Artifacts holds an optional reference image, e.g. the first frame, for models that
+	// accept one
+	Artifacts []*ModelArtifact  `json:"artifacts,omitempty"`
+	Config    *VideoModelConfig `json:"config,omitempty"`
+	// OnProgress is called every time the status of the video is checked
+	OnProgress func(progress *VideoProgress) `json:"-"`
+}
+
+type VideoModelConfig struct {
+	// Duration is the length of the video in seconds; zero uses the model default
+	Duration float64 `json:"duration,omitempty"`
+	// FPS is the frame rate, on models that let it be chosen
+	FPS int `json:"fps,omitempty"`
+	// Size is the resolution as width x height, e.g. 1280x720
+	Size string `json:"size,omitempty"`
+	// ResponseFormat is one of the VideoResponseFormat constants; bytes by default
+	ResponseFormat string `json:"responseFormat,omitempty"`
+	// PollInterval is how often the status is checked; DefaultVideoPollInterval when zero
+	PollInterval time.Duration `json:"pollInterval,omitempty"`
+}
+
+// VideoPollInterval returns the poll interval of config
+func VideoPollInterval(config *VideoModelConfig) time.Duration {
+	if config == nil || config.PollInterval <= 0 {
+		return DefaultVideoPollInterval
+	}
+	return config.PollInterval
+}
+
+// VideoProgress is the status of a video being generated
+type VideoProgress struct {
+	// ID is the provider's ID of the generation job
+	ID string `json:"id"`
+	// Status is the provider's status, e.g. queued or in_progress
+	Status string `json:"status"`
+	// Progress is the completed fraction from 0 to 1, or nil when the provider does not
+	// report it
+	Progress *float64 `json:"progress,omitempty"`
+}
+
+type VideoResponse struct {
+	// Output is the video, unless a URL was requested
+	Output []byte `json:"output,omitempty"`
+	// URL is the provider's URL of the video, when the provider hosts it
+	URL string `json:"url,omitempty"`
+	// ContentType is the MIME type of the video, e.g. video/mp4
+	ContentType string      `json:"contentType"`
+	Usage       *TokenUsage `json:"usage,omitempty"`
+	Cost        *float64    `json:"cost,omitempty"`
+	// CostBreakdown splits Cost by what was billed, see CompletionResponse.CostBreakdown
+	CostBreakdown *CostBreakdown `json:"costBreakdown,omitempty"`
+}
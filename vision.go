@@ -0,0 +1,255 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	_ "image/gif"
+)
+
+// DefaultMaxImageDimension is the longest side images are downscaled to by VisionDescribe.
+// Larger images cost more tokens without improving descriptions on current models.
+const DefaultMaxImageDimension = 1568
+
+// VisionOption is a functional option for VisionDescribe and VisionDescribeDir
+type VisionOption func(*VisionOptions)
+
+// VisionOptions contains configuration options for describing images
+type VisionOptions struct {
+	// MaxDimension is the longest side images are downscaled to before they are sent, 0 to
+	// send them unchanged
+	MaxDimension int
+	// Concurrency limits the concurrent requests of VisionDescribeDir; nil uses an adaptive
+	// controller with default options
+	Concurrency *AdaptiveConcurrency
+}
+
+// WithMaxImageDimension sets the longest side images are downscaled to, 0 to disable downscaling
+func WithMaxImageDimension(pixels int) VisionOption {
+	return func(o *VisionOptions) {
+		o.MaxDimension = pixels
+	}
+}
+
+// WithVisionConcurrency sets the controller limiting the concurrent requests of VisionDescribeDir
+func WithVisionConcurrency(controller *AdaptiveConcurrency) VisionOption {
+	return func(o *VisionOptions) {
+		o.Concurrency = controller
+	}
+}
+
+// ApplyVisionOptions applies all options to create a VisionOptions struct
+func ApplyVisionOptions(opts []VisionOption) *VisionOptions {
+	options := &VisionOptions{MaxDimension: DefaultMaxImageDimension}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// VisionDescription is the streamed description of an image
+type VisionDescription struct {
+	// Stream streams the description, ending with a usage chunk that reports the actual cost
+	Stream StreamCompletionResponse
+	// Estimate is the estimated input size and cost of the request, nil when the model cannot
+	// count tokens
+	Estimate *TokenCount
+}
+
+// VisionDescribe streams the description of an image by model, as asked by prompt. The image is
+// downscaled to the maximum dimension first, which bounds its token cost.
+func VisionDescribe(ctx context.Context, model CompletionModel, artifact *ModelArtifact, prompt string, opts ...VisionOption) (*VisionDescription, error) {
+	if model == nil {
+		return nil, NewValidationError("model", "cannot be nil", nil)
+	}
+	if artifact == nil || !strings.HasPrefix(artifact.ContentType, "image/") {
+		return nil, NewValidationError("artifact", "must be an image", artifact)
+	}
+	options := ApplyVisionOptions(opts)
+
+	img, err := DownscaleImage(artifact, options.MaxDimension)
+	if err != nil {
+		return nil, err
+	}
+	req := &CompletionRequest{
+		Messages: []*ModelMessage{NewMessage(RoleUser, NewArtifactPart(img), NewTextPart(prompt))},
+	}
+
+	description := &VisionDescription{}
+	if count, err := CountTokens(model, req); err == nil {
+		description.Estimate = count
+	}
+	description.Stream, err = model.StreamComplete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return description, nil
+}
+
+// VisionResult is the description of one image of VisionDescribeDir
+type VisionResult struct {
+	Path        string      `json:"path"`
+	Description string      `json:"description,omitempty"`
+	Usage       *TokenUsage `json:"usage,omitempty"`
+	Cost        *float64    `json:"cost,omitempty"`
+	// Err is the error describing the image, if any
+	Err error `json:"-"`
+}
+
+// VisionDescribeDir describes every image of dir, not recursing into subdirectories, under the
+// concurrency controller. Results are returned in file name order; images that fail report
+// their error in the result. An error is returned only when dir cannot be read or ctx is done.
+func VisionDescribeDir(ctx context.Context, model CompletionModel, dir string, prompt string, opts ...VisionOption) ([]*VisionResult, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image directory: %w", err)
+	}
+	options := ApplyVisionOptions(opts)
+	controller := options.Concurrency
+	if controller == nil {
+		controller = NewAdaptiveConcurrency()
+	}
+
+	var results []*VisionResult
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(mime.TypeByExtension(filepath.Ext(entry.Name())), "image/") {
+			results = append(results, &VisionResult{Path: filepath.Join(dir, entry.Name())})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, result := range results {
+		release, err := controller.Acquire(ctx)
+		if err != nil {
+			break
+		}
+		wg.Add(1)
+		go func(result *VisionResult) {
+			defer wg.Done()
+			result.Err = describeFile(ctx, model, result, prompt, options)
+			release(result.Err)
+		}(result)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// describeFile describes the image at result.Path into result
+func describeFile(ctx context.Context, model CompletionModel, result *VisionResult, prompt string, options *VisionOptions) error {
+	content, err := os.ReadFile(result.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %w", err)
+	}
+	artifact := &ModelArtifact{
+		Name:        filepath.Base(result.Path),
+		ContentType: mime.TypeByExtension(filepath.Ext(result.Path)),
+		Content:     content,
+	}
+	description, err := VisionDescribe(ctx, model, artifact, prompt, WithMaxImageDimension(options.MaxDimension))
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	for chunk := range description.Stream {
+		switch c := chunk.(type) {
+		case StreamTextChunk:
+			text.WriteString(c.Text)
+		case *StreamTextBytesChunk:
+			text.Write(c.Text)
+			c.Release()
+		case StreamUsageChunk:
+			result.Usage, result.Cost = c.Usage, c.Cost
+		case StreamErrorChunk:
+			return c
+		}
+	}
+	result.Description = text.String()
+	return nil
+}
+
+// DownscaleImage returns artifact downscaled so that its longest side is at most maxDimension
+// pixels. JPEG images stay JPEG and other formats are encoded as PNG. Images that are small
+// enough, remote, or in a format that cannot be decoded, such as WebP, are returned unchanged.
+func DownscaleImage(artifact *ModelArtifact, maxDimension int) (*ModelArtifact, error) {
+	if maxDimension <= 0 || len(artifact.Content) == 0 {
+		return artifact, nil
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(artifact.Content))
+	if err != nil || max(config.Width, config.Height) <= maxDimension {
+		return artifact, nil
+	}
+	src, format, err := image.Decode(bytes.NewReader(artifact.Content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	scale := float64(maxDimension) / float64(max(config.Width, config.Height))
+	width := max(int(float64(config.Width)*scale), 1)
+	height := max(int(float64(config.Height)*scale), 1)
+	dst := resizeBox(src, width, height)
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if format == "jpeg" {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	downscaled := *artifact
+	downscaled.ContentType = contentType
+	downscaled.Content = buf.Bytes()
+	return &downscaled, nil
+}
+
+// resizeBox downscales src to width x height by averaging the source pixels each destination
+// pixel covers
+func resizeBox(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*bounds.Dy()/height, max((y+1)*bounds.Dy()/height, y*bounds.Dy()/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*bounds.Dx()/width, max((x+1)*bounds.Dx()/width, x*bounds.Dx()/width+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
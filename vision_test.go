@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/tiff"
+)
+
+// visionModel streams a description of the image it was sent, failing for images named fail
+type visionModel struct {
+	mu   sync.Mutex
+	reqs []*CompletionRequest
+}
+
+func (m *visionModel) StreamComplete(ctx context.Context, req *CompletionRequest) (StreamCompletionResponse, error) {
+	m.mu.Lock()
+	m.reqs = append(m.reqs, req)
+	m.mu.Unlock()
+
+	artifact := req.Messages[0].Parts[0].Artifact
+	cost := 0.01
+	ch := make(chan StreamChunk, 3)
+	if artifact.Name == "fail.png" {
+		ch <- StreamErrorChunk{Provider: "test", Err: errors.New("unreadable image")}
+	} else {
+		ch <- StreamTextChunk{Text: "a picture of "}
+		ch <- StreamTextChunk{Text: artifact.Name}
+		ch <- StreamUsageChunk{Usage: &TokenUsage{TotalInputTokens: 100, TotalRequests: 1}, Cost: &cost}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (m *visionModel) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	return nil, NewUnsupportedCapabilityError("vision", "completion")
+}
+
+func (m *visionModel) CountTokens(req *CompletionRequest) (*TokenCount, error) {
+	return &TokenCount{InputTokens: CountRequestTokens(HeuristicTokenizer{}, req)}, nil
+}
+
+func encodeTestImage(t *testing.T, format string, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			copy(img.Pix[img.PixOffset(x, y):], []uint8{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	case "gif":
+		require.NoError(t, gif.Encode(&buf, img, nil))
+	case "tiff":
+		require.NoError(t, tiff.Encode(&buf, img, nil))
+	default:
+		require.NoError(t, png.Encode(&buf, img))
+	}
+	return buf.Bytes()
+}
+
+func TestDownscaleImage(t *testing.T) {
+	artifact := &ModelArtifact{Name: "photo.png", ContentType: "image/png", Content: encodeTestImage(t, "png", 400, 200)}
+	downscaled, err := DownscaleImage(artifact, 100)
+	require.NoError(t, err)
+	config, format, err := image.DecodeConfig(bytes.NewReader(downscaled.Content))
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, 100, config.Width)
+	assert.Equal(t, 50, config.Height)
+	assert.Equal(t, "photo.png", downscaled.Name)
+
+	artifact = &ModelArtifact{ContentType: "image/jpeg", Content: encodeTestImage(t, "jpeg", 200, 400)}
+	downscaled, err = DownscaleImage(artifact, 100)
+	require.NoError(t, err)
+	config, format, err = image.DecodeConfig(bytes.NewReader(downscaled.Content))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, "image/jpeg", downscaled.ContentType)
+	assert.Equal(t, 50, config.Width)
+
+	// Small, remote and undecodable images are sent as they are
+	for _, artifact := range []*ModelArtifact{
+		{ContentType: "image/png", Content: encodeTestImage(t, "png", 50, 50)},
+		{ContentType: "image/png", URL: "https://example.com/image.png"},
+		{ContentType: "image/webp", Content: []byte("RIFF....WEBP")},
+	} {
+		unchanged, err := DownscaleImage(artifact, 100)
+		require.NoError(t, err)
+		assert.Same(t, artifact, unchanged)
+	}
+}
+
+func TestCountImageTokens(t *testing.T) {
+	assert.Equal(t, 765, countImageTokens(&ModelArtifact{Content: encodeTestImage(t, "png", 1024, 1024)}))
+	// 2048x4096 fits 1024x2048, then 768x1536 or 2x3 tiles
+	assert.Equal(t, 1105, countImageTokens(&ModelArtifact{Content: encodeTestImage(t, "png", 2048, 4096)}))
+	assert.Equal(t, imageTokenEstimate, countImageTokens(&ModelArtifact{URL: "https://example.com/image.png"}))
+}
+
+func TestVisionDescribe(t *testing.T) {
+	model := &visionModel{}
+	artifact := &ModelArtifact{Name: "cat.png", ContentType: "image/png", Content: encodeTestImage(t, "png", 2048, 1024)}
+
+	description, err := VisionDescribe(context.Background(), model, artifact, "Describe the image.", WithMaxImageDimension(512))
+	require.NoError(t, err)
+	var text string
+	for chunk := range description.Stream {
+		if c, ok := chunk.(StreamTextChunk); ok {
+			text += c.Text
+		}
+	}
+	assert.Equal(t, "a picture of cat.png", text)
+
+	// The image was downscaled before it was sent and estimated at its new size
+	require.Len(t, model.reqs, 1)
+	parts := model.reqs[0].Messages[0].Parts
+	require.Len(t, parts, 2)
+	config, _, err := image.DecodeConfig(bytes.NewReader(parts[0].Artifact.Content))
+	require.NoError(t, err)
+	assert.Equal(t, 512, config.Width)
+	assert.Equal(t, "Describe the image.", parts[1].Text)
+	require.NotNil(t, description.Estimate)
+	assert.Greater(t, description.Estimate.InputTokens, 255)
+
+	_, err = VisionDescribe(context.Background(), model, &ModelArtifact{ContentType: "text/plain"}, "Describe the image.")
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestVisionDescribeDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.png", "a.jpg", "fail.png"} {
+		format := "png"
+		if filepath.Ext(name) == ".jpg" {
+			format = "jpeg"
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), encodeTestImage(t, format, 16, 16), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.png"), 0o755))
+
+	model := &visionModel{}
+	results, err := VisionDescribeDir(context.Background(), model, dir, "Describe the image.",
+		WithVisionConcurrency(NewAdaptiveConcurrency(WithConcurrencyLimits(2, 1, 2))))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, filepath.Join(dir, "a.jpg"), results[0].Path)
+	assert.Equal(t, "a picture of a.jpg", results[0].Description)
+	require.NotNil(t, results[0].Cost)
+	assert.Equal(t, 0.01, *results[0].Cost)
+	assert.Equal(t, "a picture of b.png", results[1].Description)
+	assert.ErrorContains(t, results[2].Err, "unreadable image")
+
+	_, err = VisionDescribeDir(context.Background(), model, filepath.Join(dir, "missing"), "Describe the image.")
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookTolerance is how far the timestamp of a signed delivery may be from the clock
+	webhookTolerance = 5 * time.Minute
+	// webhookRetention is how long a delivery arriving before its request waits for it is kept
+	webhookRetention = 10 * time.Minute
+	// maxWebhookPayload is the size limit of a delivery
+	maxWebhookPayload = 10 << 20
+)
+
+// Webhook receives the completion of asynchronous provider jobs, such as Replicate
+// predictions, instead of polling for it. Serve it at URL, which the provider notifies when a
+// job completes; requests then wait for the delivery of their job. Deliveries are JSON objects
+// identified by their id field, signed following the Standard Webhooks specification when a
+// signing secret is set.
+type Webhook struct {
+	// URL is the public URL the Webhook is served at
+	URL    string
+	secret []byte
+
+	mu      sync.Mutex
+	waiters map[string]chan json.RawMessage
+	// early are the deliveries received before their request waits for them
+	early map[string]webhookDelivery
+}
+
+type webhookDelivery struct {
+	payload  json.RawMessage
+	received time.Time
+}
+
+var _ http.Handler = (*Webhook)(nil)
+
+// NewWebhook creates a webhook served at url. Deliveries are verified with secret, the base64
+// signing secret of the provider with or without its whsec_ prefix; an empty secret accepts
+// unsigned deliveries.
+func NewWebhook(url string, secret string) (*Webhook, error) {
+	if url == "" {
+		return nil, NewValidationError("url", "cannot be empty", url)
+	}
+	w := &Webhook{
+		URL:     url,
+		waiters: make(map[string]chan json.RawMessage),
+		early:   make(map[string]webhookDelivery),
+	}
+	if secret != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+		if err != nil {
+			return nil, NewValidationError("secret", "must be base64 encoded", nil)
+		}
+		w.secret = key
+	}
+	return w, nil
+}
+
+// Wait returns the delivery of job id, waiting for it until ctx is done
+func (w *Webhook) Wait(ctx context.Context, id string) (json.RawMessage, error) {
+	w.mu.Lock()
+	if delivery, ok := w.early[id]; ok {
+		delete(w.early, id)
+		w.mu.Unlock()
+		return delivery.payload, nil
+	}
+	ch := make(chan json.RawMessage, 1)
+	w.waiters[id] = ch
+	w.mu.Unlock()
+
+	select {
+	case payload := <-ch:
+		return payload, nil
+	case <-ctx.Done():
+		w.mu.Lock()
+		delete(w.waiters, id)
+		w.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// ServeHTTP receives a delivery, rejecting it when its signature is invalid
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(rw, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if w.secret != nil && !w.verify(r.Header, payload, time.Now()) {
+		http.Error(rw, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var job struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(payload, &job); err != nil || job.ID == "" {
+		http.Error(rw, "payload has no id", http.StatusBadRequest)
+		return
+	}
+	w.deliver(job.ID, payload)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// deliver passes payload to the request waiting for job id, or keeps it until one waits
+func (w *Webhook) deliver(id string, payload json.RawMessage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ch, ok := w.waiters[id]; ok {
+		delete(w.waiters, id)
+		ch <- payload
+		return
+	}
+	now := time.Now()
+	for earlyID, delivery := range w.early {
+		if now.Sub(delivery.received) > webhookRetention {
+			delete(w.early, earlyID)
+		}
+	}
+	w.early[id] = webhookDelivery{payload: payload, received: now}
+}
+
+// verify checks the Standard Webhooks signature of a delivery: an HMAC-SHA256 of its id,
+// timestamp and payload, sent with a timestamp close to now
+func (w *Webhook) verify(header http.Header, payload []byte, now time.Time) bool {
+	id, timestamp := header.Get("webhook-id"), header.Get("webhook-timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if id == "" || err != nil {
+		return false
+	}
+	if sent := time.Unix(seconds, 0); sent.Before(now.Add(-webhookTolerance)) || sent.After(now.Add(webhookTolerance)) {
+		return false
+	}
+	mac := hmac.New(sha256.New, w.secret)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	for _, signature := range strings.Fields(header.Get("webhook-signature")) {
+		version, sig, ok := strings.Cut(signature, ",")
+		if !ok || version != "v1" {
+			continue
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(sig); err == nil && hmac.Equal(decoded, expected) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	key := []byte("webhook signing key")
+	webhook, err := NewWebhook("https://example.com/hooks", "whsec_"+base64.StdEncoding.EncodeToString(key))
+	require.NoError(t, err)
+
+	deliver := func(payload string, sent time.Time, sign []byte) int {
+		timestamp := strconv.FormatInt(sent.Unix(), 10)
+		mac := hmac.New(sha256.New, sign)
+		mac.Write([]byte("msg_1." + timestamp + "." + payload))
+		r := httptest.NewRequest(http.MethodPost, webhook.URL, strings.NewReader(payload))
+		r.Header.Set("webhook-id", "msg_1")
+		r.Header.Set("webhook-timestamp", timestamp)
+		r.Header.Set("webhook-signature", "v1,c2lnbmF0dXJl v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		w := httptest.NewRecorder()
+		webhook.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// A delivery is passed to the request waiting for it
+	done := make(chan []byte)
+	go func() {
+		payload, err := webhook.Wait(context.Background(), "p1")
+		assert.NoError(t, err)
+		done <- payload
+	}()
+	require.Eventually(t, func() bool {
+		webhook.mu.Lock()
+		defer webhook.mu.Unlock()
+		return webhook.waiters["p1"] != nil
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, http.StatusNoContent, deliver(`{"id":"p1","status":"succeeded"}`, time.Now(), key))
+	assert.JSONEq(t, `{"id":"p1","status":"succeeded"}`, string(<-done))
+
+	// A delivery arriving before its request waits is kept for it
+	assert.Equal(t, http.StatusNoContent, deliver(`{"id":"p2"}`, time.Now(), key))
+	payload, err := webhook.Wait(context.Background(), "p2")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"p2"}`, string(payload))
+
+	assert.Equal(t, http.StatusUnauthorized, deliver(`{"id":"p3"}`, time.Now(), []byte("other key")))
+	assert.Equal(t, http.StatusUnauthorized, deliver(`{"id":"p3"}`, time.Now().Add(-time.Hour), key))
+	assert.Equal(t, http.StatusBadRequest, deliver(`{"status":"succeeded"}`, time.Now(), key))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = webhook.Wait(ctx, "p3")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, webhook.waiters)
+}
+
+func TestNewWebhook_Errors(t *testing.T) {
+	var validationErr *ValidationError
+	_, err := NewWebhook("", "")
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = NewWebhook("https://example.com/hooks", "whsec_not base64!")
+	assert.ErrorAs(t, err, &validationErr)
+}
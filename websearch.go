@@ -0,0 +1,64 @@
+// Copyright 2025 The DeepTask Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import "fmt"
+
+// WebSearchOptions configures the server-side web search tool of providers that run searches
+// on the model's behalf. Searches are counted in TokenUsage.TotalWebSearches and billed at
+// ModelPricing.WebSearch per search.
+type WebSearchOptions struct {
+	// MaxUses limits the number of searches per request; zero leaves it to the provider
+	MaxUses int
+	// AllowedDomains restricts results to these domains. It cannot be combined with BlockedDomains.
+	AllowedDomains []string
+	// BlockedDomains excludes these domains from results
+	BlockedDomains []string
+}
+
+// WithWebSearch lets the model search the web while generating the response. It is supported
+// by the Anthropic provider and ignored by providers without server-side search.
+func WithWebSearch(options WebSearchOptions) CompletionOption {
+	return func(o *CompletionOptions) {
+		o.WebSearch = &options
+	}
+}
+
+// WebSearch is a web search the provider ran for the model
+type WebSearch struct {
+	// Query is the search query written by the model
+	Query string `json:"query"`
+	// Results are the pages returned by the search
+	Results []*WebSearchResult `json:"results"`
+	// Error is the provider's error code if the search failed
+	Error string `json:"error,omitempty"`
+}
+
+// WebSearchResult is a page returned by a web search
+type WebSearchResult struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	// PageAge is the provider's estimate of when the page was last updated, if known
+	PageAge string `json:"pageAge,omitempty"`
+}
+
+// StreamWebSearchChunk reports a finished web search in the API stream
+type StreamWebSearchChunk struct {
+	Search *WebSearch `json:"search"`
+}
+
+// Type returns the type of the chunk
+func (c StreamWebSearchChunk) Type() StreamChunkType {
+	return WebSearchChunkType
+}
+
+func (c StreamWebSearchChunk) String() string {
+	if c.Search == nil {
+		return "web search"
+	}
+	if c.Search.Error != "" {
+		return fmt.Sprintf("web search %q: %s", c.Search.Query, c.Search.Error)
+	}
+	return fmt.Sprintf("web search %q: %d results", c.Search.Query, len(c.Search.Results))
+}